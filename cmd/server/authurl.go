@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ctl"
+)
+
+// authURLCommand function prints the Tailscale auth URL of a proxy, read
+// from the API of a running server, and returns the exit code.
+func authURLCommand(args []string) int {
+	fs := flag.NewFlagSet("authurl", flag.ExitOnError)
+	newClient := ctl.AddAPIFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: tsdproxyd authurl [options] <proxy>")
+		return 2
+	}
+
+	var res struct {
+		Status  string `json:"status"`
+		AuthURL string `json:"authUrl"`
+	}
+	if err := newClient().Get("/api/v1/proxies/"+url.PathEscape(fs.Arg(0))+"/authurl", &res); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if res.AuthURL == "" {
+		fmt.Fprintf(os.Stderr, "proxy %s doesn't need authentication, status is %s\n", fs.Arg(0), res.Status)
+		return 1
+	}
+
+	fmt.Println(res.AuthURL)
+
+	return 0
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+)
+
+const configUsage = `Usage: tsdproxyd config <command> [options]
+
+Commands:
+  init       write a commented starter configuration
+  validate   check a configuration without starting the server
+  print      print the effective configuration, with the defaults and the
+             secrets read from files, secrets are redacted
+`
+
+var errConfigExists = errors.New("configuration file already exists, use -force to overwrite it")
+
+// configCommand function runs the config subcommands and returns the exit
+// code.
+func configCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	var err error
+
+	switch args[0] {
+	case "init":
+		err = configInit(args[1:])
+	case "validate":
+		err = configValidate(args[1:])
+	case "print":
+		err = configPrint(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func configInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	output := fs.String("o", config.DefaultConfigFile, "file to write the configuration, - for stdout")
+	force := fs.Bool("force", false, "overwrite an existing file")
+	_ = fs.Parse(args)
+
+	data, err := config.Starter()
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if !*force {
+		if _, err := os.Stat(*output); err == nil {
+			return errConfigExists
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*output), consts.PermOwnerAll); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, data, consts.PermAllRead+consts.PermOwnerWrite); err != nil {
+		return err
+	}
+
+	fmt.Println("configuration written to", *output)
+
+	return nil
+}
+
+func configValidate(args []string) error {
+	file := configFileFlag("validate", args)
+
+	// unlike the server, validate doesn't generate a missing file
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+
+	if err := config.ReadConfig(file); err != nil {
+		return err
+	}
+
+	fmt.Println("configuration is valid:", file)
+
+	return nil
+}
+
+func configPrint(args []string) error {
+	file := configFileFlag("print", args)
+
+	if err := config.ReadConfig(file); err != nil {
+		return err
+	}
+
+	data, err := config.Effective()
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+
+	return err
+}
+
+// configFileFlag function returns the -config flag of a config subcommand,
+// the same flag of the server.
+func configFileFlag(name string, args []string) string {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	file := fs.String("config", config.DefaultConfigFile, "configuration file")
+	_ = fs.Parse(args)
+
+	return *file
+}
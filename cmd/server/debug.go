@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders/docker"
+)
+
+const debugUsage = `Usage: tsdproxyd debug <command> [options]
+
+Commands:
+  record   record the events of tsdproxy enabled containers
+  replay   replay recorded events in a replay target provider
+`
+
+// debugCommand function runs the debug subcommands and returns the exit code.
+func debugCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, debugUsage)
+		return 2
+	}
+
+	log := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+
+	switch args[0] {
+	case "record":
+		err = debugRecord(ctx, log, args[1:])
+	case "replay":
+		err = debugReplay(ctx, log, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, debugUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg(args[0] + " failed")
+		return 1
+	}
+
+	return 0
+}
+
+func debugRecord(ctx context.Context, log zerolog.Logger, args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	host := fs.String("host", "unix:///var/run/docker.sock", "docker host")
+	output := fs.String("o", "-", "file to write the events, - for stdout")
+	_ = fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	client, err := docker.New(log, "record", &config.DockerTargetProviderConfig{Host: *host})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	log.Info().Msg("recording events, press ctrl+c to stop")
+
+	return client.RecordEvents(ctx, w)
+}
+
+func debugReplay(ctx context.Context, log zerolog.Logger, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	socket := fs.String("socket", "/data/replay.sock", "socket of the replay target provider")
+	speed := fs.Float64("speed", 1, "replay speed, 0 to send all events without waiting")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: tsdproxyd debug replay [options] <file>")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sent, err := docker.ReplayEvents(ctx, f, *socket, *speed)
+	log.Info().Int("events", sent).Msg("events replayed")
+
+	return err
+}
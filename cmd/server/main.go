@@ -4,22 +4,38 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/docker/docker/client"
 	"github.com/rs/zerolog"
 
 
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/audit"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ctl"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/dashboard"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/geoip"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/cachepurge"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/certmanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/inventory"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/listsync"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
 	pm "github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/publicdns"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/service"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/tracing"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/updates"
 )
 type WebApp struct {
 	Log          zerolog.Logger
@@ -28,8 +44,19 @@ type WebApp struct {
 	Docker       *client.Client
 	ProxyManager *pm.ProxyManager
 	Dashboard    *dashboard.Dashboard
+	ListSync     *listsync.Syncer
+	Notifier     *notify.Notifier
+	CachePurger  *cachepurge.Purger
+	CertManager  *certmanager.CertManager
+	PublicDNS    *publicdns.Publisher
+	Updates      *updates.Checker
+	Audit        *audit.Log
+	Tracer       *tracing.Tracer
 }
 
+// auditFile is the audit log in the data directory.
+const auditFile = "audit.jsonl"
+
 func InitializeApp() (*WebApp, error) {
 	err := config.InitializeConfig()
 	if err != nil {
@@ -46,16 +73,67 @@ func InitializeApp() (*WebApp, error) {
 	//
 	proxymanager := pm.NewProxyManager(logger)
 
+	// Report the warnings of the configuration in the problems page
+	//
+	if warnings := config.Warnings(); len(warnings) > 0 {
+		proxymanager.Problems().Report(problems.Problem{
+			Source:  problems.SourceConfig,
+			Subject: "configuration",
+			Message: strings.Join(warnings, "\n"),
+			Fix:     "Review the migration report in the data directory and move the skipped settings by hand.",
+		})
+	}
+
+	// Notify proxy events that need attention
+	//
+	notifier := notify.New(logger, config.Config.Notifications)
+	proxymanager.SetNotifier(notifier)
+
+	// Trace the proxied requests
+	//
+	tracer := tracing.Setup(logger, config.Config.Tracing)
+
+	// Load the countries of the client addresses
+	//
+	geoip.Setup(logger, config.Config.GeoIP)
+
+	// Purge the Cloudflare cache of restarted proxies
+	//
+	purger := cachepurge.New(logger, config.Config.CachePurge)
+	proxymanager.SetCachePurger(purger)
+
 	// init Dashboard
 	//
 	dash := dashboard.NewDashboard(httpServer, logger, proxymanager)
 
+	// Check for new versions in the update channel
+	//
+	checker := updates.New(logger, config.Config.Updates)
+	dash.SetUpdates(checker)
+
+	// Record the administrative actions. The actions still run if the audit
+	// log can't be opened, like in a read-only data directory.
+	//
+	var auditLog *audit.Log
+	if !config.Config.Audit.Disabled {
+		path := filepath.Join(config.Config.Tailscale.DataDir, auditFile)
+		if auditLog, err = audit.Open(logger, path); err != nil {
+			logger.Error().Err(err).Str("file", path).Msg("Error opening audit log, audit log is disabled")
+		}
+		dash.SetAudit(auditLog)
+	}
+
 	webApp := &WebApp{
 		Log:          logger,
 		HTTP:         httpServer,
 		Health:       health,
 		ProxyManager: proxymanager,
 		Dashboard:    dash,
+		Notifier:     notifier,
+		CachePurger:  purger,
+		Updates:      checker,
+		Audit:        auditLog,
+		Tracer:       tracer,
 	}
 
 	if config.Config.LetsEncrypt.Enabled {
@@ -63,36 +141,71 @@ func InitializeApp() (*WebApp, error) {
 		if err != nil {
 			return nil, fmt.Errorf("creating certmanager: %w", err)
 		}
-
-		err = certManager.SetupCloudflareChallenge(context.Background())
-		if err != nil {
-			return nil, fmt.Errorf("setting up cloudflare challenge: %w", err)
-		}
+		certManager.SetNotifier(notifier)
+		certManager.SetProblems(proxymanager.Problems())
+		certManager.SetAudit(auditLog)
 
 		go certManager.StartRenewalProcess(context.Background())
+		certManager.StartHealthChecks(context.Background())
+
+		webApp.CertManager = certManager
+		dash.SetLetsEncrypt(certManager)
 	}
 
 	return webApp, nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "debug" {
+		os.Exit(debugCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "authurl" {
+		os.Exit(authURLCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(ctl.Command("tsdproxyd ctl", os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		os.Exit(serviceCommand(os.Args[2:]))
+	}
+
+	// Windows services are stopped by the service manager, the server
+	// runs until it's interrupted otherwise
+	//
+	if err := service.Run(runServer); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runServer function runs the server until the context is done, then
+// gracefully shuts it down with a timeout of shutdownTimeout.
+func runServer(ctx context.Context) error {
 	println("Initializing server")
 	println("Version", core.GetVersion())
 
 	app, err := InitializeApp()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 
 	app.Start()
-	defer app.Stop()
 
-	// Wait for interrupt signal to gracefully shutdown the server with a timeout of 10 seconds.
-	//
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Config.ShutdownTimeout)
+	defer cancel()
+
+	app.Stop(ctx)
+
+	return nil
 }
 
 func (app *WebApp) Start() {
@@ -101,72 +214,194 @@ func (app *WebApp) Start() {
 
 	// Start the webserver
 	//
-	go func() {
-		app.Log.Info().Msg("Initializing WebServer")
-
-		// Start the webserver
-		//
-		if config.Config.LetsEncrypt.Enabled {
-			certManager, err := certmanager.NewCertManager(config.Config.LetsEncrypt)
-			if err != nil {
-				app.Log.Fatal().Err(err).Msg("Error creating certmanager")
-				os.Exit(1)
-			}
-
-			err = certManager.ListenAndServeTLS(context.Background(), config.Config.HTTP.Hostname, int(config.Config.HTTP.Port), func(listener net.Listener, tlsConfig *tls.Config) error {
-				srv := &http.Server{
-					Addr:              fmt.Sprintf("%s:%d", config.Config.HTTP.Hostname, config.Config.HTTP.Port),
-					ReadHeaderTimeout: core.ReadHeaderTimeout,
-					TLSConfig:         tlsConfig,
-					Handler:           app.HTTP.Handler,
-				}
-				app.Health.SetReady()
-				return srv.Serve(listener)
-			})
-
-			if err != nil {
-				app.Log.Fatal().Err(err).Msg("Error starting TLS server")
-				os.Exit(1)
-			}
-			return
-		} else {
-			srv := http.Server{
-				Addr:              fmt.Sprintf("%s:%d", config.Config.HTTP.Hostname, config.Config.HTTP.Port),
-				ReadHeaderTimeout: core.ReadHeaderTimeout,
-			}
-
-			app.Health.SetReady()
-
-			if err := app.HTTP.StartServer(&srv); errors.Is(err, http.ErrServerClosed) {
-				app.Log.Fatal().Err(err).Msg("shutting down the server")
-			}
-		}
-	}()
+	go app.startHTTP()
 
 	// Setup proxy for existing containers
 	//
 	app.Log.Info().Msg("Setting up proxy proxies")
 
+	if app.Notifier != nil {
+		app.Notifier.Start(context.Background())
+	}
+	if app.CachePurger != nil {
+		app.CachePurger.Start(context.Background())
+	}
+	app.Updates.Start(context.Background())
+
 	app.ProxyManager.Start()
 
+	// Serve the dashboard as Tailscale nodes, with the proxy providers
+	// started
+	//
+	app.startTailscaleHTTP()
+
 	// Start watching docker events
 	//
 	app.ProxyManager.WatchEvents()
 
+	// Publish proxy inventory to remote stores
+	//
+	if publisher := inventory.New(app.Log, app.ProxyManager, config.Config.Inventory); publisher != nil {
+		publisher.Start(context.Background())
+	}
+
+	// Publish the public DNS names of the proxies
+	//
+	if publisher := publicdns.New(app.Log, app.ProxyManager, config.Config.PublicDNS); publisher != nil {
+		publisher.Start(context.Background())
+		app.PublicDNS = publisher
+	}
+
+	// Replicate lists with other tsdproxy instances
+	//
+	syncer, err := listsync.New(app.Log, app.ProxyManager, config.Config.Sync)
+	if err != nil {
+		app.Log.Error().Err(err).Msg("error initializing list sync")
+	} else if syncer != nil {
+		if err := syncer.Start(context.Background()); err != nil {
+			app.Log.Error().Err(err).Msg("error starting list sync")
+		} else {
+			app.ListSync = syncer
+		}
+	}
+
 	// Add Routes
 	//
 	app.Dashboard.AddRoutes()
-	core.PprofAddRoutes(app.HTTP)
 }
 
-func (app *WebApp) Stop() {
+// startHTTP method serves the dashboard on the host addresses, with the
+// Let's Encrypt certificate when enabled.
+func (app *WebApp) startHTTP() {
+	app.Log.Info().Msg("Initializing WebServer")
+
+	addrs := httpAddresses()
+
+	if app.CertManager != nil && len(addrs) > 0 {
+		host, port, _ := net.SplitHostPort(addrs[0])
+		portNumber, _ := strconv.Atoi(port)
+
+		err := app.CertManager.ListenAndServeTLS(context.Background(), host, portNumber, func(listener net.Listener, tlsConfig *tls.Config) error {
+			for _, addr := range addrs[1:] {
+				go app.listenHTTP(addr, tlsConfig)
+			}
+			app.Health.SetReady()
+			return app.serveHTTP(listener, tlsConfig)
+		})
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.Log.Fatal().Err(err).Msg("Error starting TLS server")
+		}
+		return
+	}
+
+	for _, addr := range addrs {
+		go app.listenHTTP(addr, nil)
+	}
+	app.Health.SetReady()
+}
+
+// startTailscaleHTTP method serves the dashboard with HTTPS as the Tailscale
+// nodes of the listeners.
+func (app *WebApp) startTailscaleHTTP() {
+	for _, l := range config.Config.HTTP.Listeners {
+		if l.Tailscale == nil {
+			continue
+		}
+
+		go func() {
+			listener, err := app.ProxyManager.NodeListener(context.Background(), l.Tailscale.ProxyProvider, l.Tailscale.Hostname)
+			if err != nil {
+				app.Log.Error().Err(err).Str("hostname", l.Tailscale.Hostname).Msg("Error starting the dashboard node")
+				return
+			}
+
+			if err := app.serveHTTP(listener, nil); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				app.Log.Error().Err(err).Str("hostname", l.Tailscale.Hostname).Msg("Error serving the dashboard node")
+			}
+		}()
+	}
+}
+
+// listenHTTP method serves the dashboard on a host address.
+func (app *WebApp) listenHTTP(addr string, tlsConfig *tls.Config) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		app.Log.Fatal().Err(err).Str("address", addr).Msg("Error listening")
+	}
+
+	if err := app.serveHTTP(listener, tlsConfig); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.Log.Fatal().Err(err).Msg("shutting down the server")
+	}
+}
+
+// serveHTTP method serves the dashboard on a listener, with TLS with a
+// tlsConfig.
+func (app *WebApp) serveHTTP(listener net.Listener, tlsConfig *tls.Config) error {
+	srv := &http.Server{
+		Addr:              listener.Addr().String(),
+		ReadHeaderTimeout: core.ReadHeaderTimeout,
+		TLSConfig:         tlsConfig,
+	}
+
+	return app.HTTP.Serve(srv, listener)
+}
+
+// httpAddresses function returns the host addresses of the dashboard, the
+// hostname and port without listeners.
+func httpAddresses() []string {
+	if len(config.Config.HTTP.Listeners) == 0 {
+		return []string{fmt.Sprintf("%s:%d", config.Config.HTTP.Hostname, config.Config.HTTP.Port)}
+	}
+
+	var addrs []string
+	for _, l := range config.Config.HTTP.Listeners {
+		if l.Address != "" {
+			addrs = append(addrs, l.Address)
+		}
+	}
+
+	return addrs
+}
+
+// Stop method shuts down the dashboard server and the proxies at the same
+// time, draining their active requests until the context is done.
+func (app *WebApp) Stop(ctx context.Context) {
 	app.Log.Info().Msg("Shutdown server")
 
 	app.Health.SetNotReady()
 
 	// Shutdown things here
 	//
-	app.ProxyManager.StopAllProxies()
+	httpDone := make(chan error, 1)
+	go func() {
+		httpDone <- app.HTTP.Shutdown(ctx)
+	}()
+
+	if err := app.ProxyManager.StopAllProxies(ctx); err != nil {
+		app.Log.Error().Err(err).Msg("proxies didn't stop in time")
+	}
+	if err := <-httpDone; err != nil {
+		app.Log.Error().Err(err).Msg("error stopping the webserver")
+	}
+
+	if app.PublicDNS != nil {
+		app.PublicDNS.Close()
+	}
+
+	if app.ListSync != nil {
+		if err := app.ListSync.Close(); err != nil {
+			app.Log.Error().Err(err).Msg("error stopping list sync")
+		}
+	}
+
+	if err := app.Audit.Close(); err != nil {
+		app.Log.Error().Err(err).Msg("error closing audit log")
+	}
+
+	if err := app.Tracer.Close(ctx); err != nil {
+		app.Log.Error().Err(err).Msg("error exporting traces")
+	}
 
 	app.Log.Info().Msg("Server was shutdown successfully")
 }
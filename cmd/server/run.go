@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	pm "github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders/single"
+)
+
+// runTargetProvider is the name of the target provider of the run command.
+const runTargetProvider = "run"
+
+var (
+	errRunHostname = errors.New("-hostname is required")
+	errRunTarget   = errors.New("-target must be an absolute URL, like http://localhost:3000")
+)
+
+// runCommand function starts a single proxy set with the flags, without
+// configuration file, until it's interrupted, and returns the exit code.
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	hostname := fs.String("hostname", "", "hostname of the proxy in the tailnet")
+	target := fs.String("target", "", "URL of the target, like http://localhost:3000")
+	port := fs.String("port", "443/https", "port and protocol of the proxy")
+	funnel := fs.Bool("funnel", false, "share the proxy on the internet with Tailscale Funnel")
+	ephemeral := fs.Bool("ephemeral", false, "remove the node from the tailnet when it stops")
+	authKey := fs.String("authkey", "", "Tailscale auth key, TSDPROXY_AUTHKEY by default")
+	controlURL := fs.String("controlurl", "", "Tailscale control server URL")
+	dataDir := fs.String("datadir", defaultRunDataDir(), "directory of the state of the node")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tsdproxyd run -hostname <name> -target <url> [options]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	pcfg, err := runProxyConfig(*hostname, *target, *port, *funnel, *ephemeral)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	if err := os.MkdirAll(*dataDir, consts.PermOwnerAll); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	err = config.InitializeRunConfig(config.RunConfig{
+		AuthKey:    *authKey,
+		ControlURL: *controlURL,
+		DataDir:    *dataDir,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	logger := core.NewLog()
+
+	proxymanager := pm.NewProxyManager(logger)
+	proxymanager.AddTargetProvider(single.New(logger, runTargetProvider, pcfg), runTargetProvider)
+	proxymanager.Start()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	events := proxymanager.SubscribeStatusEvents()
+	defer proxymanager.UnsubscribeStatusEvents(events)
+
+	proxymanager.WatchEvents()
+
+	printRunStatus(ctx, proxymanager, events, pcfg.Hostname)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Config.ShutdownTimeout)
+	defer cancel()
+
+	if err := proxymanager.StopAllProxies(ctx); err != nil {
+		logger.Error().Err(err).Msg("proxy didn't stop in time")
+		return 1
+	}
+
+	return 0
+}
+
+// runProxyConfig function returns the configuration of the proxy of the run
+// command.
+func runProxyConfig(hostname, target, portLabel string, funnel, ephemeral bool) (*model.Config, error) {
+	if hostname == "" {
+		return nil, errRunHostname
+	}
+
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.Scheme == "" || targetURL.Host == "" {
+		return nil, errRunTarget
+	}
+
+	port, err := model.NewPortShortLabel(portLabel)
+	if err != nil {
+		return nil, fmt.Errorf("-port: %w", err)
+	}
+	port.AddTarget(targetURL)
+	port.Tailscale.Funnel = funnel
+
+	pcfg, err := model.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	pcfg.Hostname = hostname
+	pcfg.Tailscale.Ephemeral = ephemeral
+	pcfg.ProxyAccessLog = model.DefaultProxyAccessLog
+	pcfg.Ports = model.PortConfigList{portLabel: port}
+
+	return pcfg, nil
+}
+
+// printRunStatus function prints the auth URL and the URL of the proxy when
+// its status changes, until the context is done.
+func printRunStatus(ctx context.Context, proxymanager *pm.ProxyManager, events <-chan model.ProxyEvent, hostname string) {
+	last := model.ProxyStatusInitializing
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if event.ID != hostname || event.Status == last {
+				continue
+			}
+			last = event.Status
+
+			proxy, ok := proxymanager.GetProxy(hostname)
+			if !ok {
+				continue
+			}
+
+			switch event.Status {
+			case model.ProxyStatusAuthenticating:
+				if authURL := proxy.GetAuthURL(); authURL != "" {
+					fmt.Fprintf(os.Stderr, "To authenticate %s, visit:\n\n\t%s\n\n", hostname, authURL)
+				}
+			case model.ProxyStatusRunning:
+				fmt.Fprintf(os.Stderr, "Proxy %s is running on %s\n", hostname, proxy.GetURL())
+			case model.ProxyStatusError:
+				fmt.Fprintf(os.Stderr, "Proxy %s failed to start, check the logs\n", hostname)
+			}
+		}
+	}
+}
+
+// defaultRunDataDir function returns the data directory of the run command,
+// in the user configuration directory so the node is kept between runs.
+func defaultRunDataDir() string {
+	if dir := os.Getenv("TSDPROXY_DATADIR"); dir != "" {
+		return dir
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "tsdproxy")
+	}
+
+	return filepath.Join(dir, "tsdproxy")
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/service"
+)
+
+const serviceUsage = `Usage: tsdproxyd service <command> [options]
+
+Commands:
+  install     install the server as a Windows service or a macOS launchd
+              daemon, started with the system
+  uninstall   stop and remove the service
+  start       start the service
+  stop        stop the service
+`
+
+// serviceCommand function runs the service subcommands and returns the exit
+// code.
+func serviceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, serviceUsage)
+		return 2
+	}
+
+	var err error
+
+	switch args[0] {
+	case "install":
+		err = serviceInstall(args[1:])
+	case "uninstall":
+		err = service.Uninstall()
+	case "start":
+		err = service.Start()
+	case "stop":
+		err = service.Stop()
+	default:
+		fmt.Fprint(os.Stderr, serviceUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func serviceInstall(args []string) error {
+	cfg, err := service.NewConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	fs.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "configuration file of the service")
+	fs.StringVar(&cfg.DataDir, "datadir", cfg.DataDir, "data directory of the service")
+	_ = fs.Parse(args)
+
+	if err := service.Install(cfg); err != nil {
+		return err
+	}
+
+	fmt.Printf("Service %s installed with configuration %s\n", service.Name, cfg.ConfigFile)
+	fmt.Println("Start it with: tsdproxyd service start")
+
+	return nil
+}
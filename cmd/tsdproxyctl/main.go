@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Command tsdproxyctl runs the maintenance commands in a running tsdproxy
+// server with its API, like tsdproxyd ctl, for headless servers.
+package main
+
+import (
+	"os"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ctl"
+)
+
+func main() {
+	os.Exit(ctl.Command("tsdproxyctl", os.Args[1:]))
+}
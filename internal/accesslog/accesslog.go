@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatCLF      = "clf"
+	FormatCombined = "combined"
+
+	SinkLog    = "log"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+	SinkHTTP   = "http"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown access log format")
+	ErrUnknownSink   = errors.New("unknown access log sink")
+	ErrMissingPath   = errors.New("access log file sink requires a path")
+	ErrMissingURL    = errors.New("access log http sink requires an url")
+)
+
+type (
+	// Entry struct stores the data of a proxied request.
+	Entry struct {
+		Time            time.Time
+		Err             error
+		Whois           model.Whois
+		Proxy           string
+		Port            string
+		Client          string
+		Method          string
+		Host            string
+		URL             string
+		Proto           string
+		Upstream        string
+		UserAgent       string
+		Referer         string
+		Status          int
+		Bytes           int64
+		Duration        time.Duration
+		UpstreamLatency time.Duration
+	}
+
+	// Logger struct writes access log entries of a proxy to the configured sink.
+	Logger struct {
+		sink     sink
+		tail     *Tail
+		template *template.Template
+		format   string
+		proxy    string
+		redact   model.AccessLogRedact
+		// sample logs 1 of every sample requests, count is the requests seen
+		sample uint64
+		count  atomic.Uint64
+	}
+
+	// sink interface is implemented by the access log destinations.
+	sink interface {
+		Write(e *Entry, line []byte) error
+		Close() error
+	}
+
+	// RoundTripper struct measures the upstream latency of proxied requests.
+	RoundTripper struct {
+		next http.RoundTripper
+	}
+
+	// record stores the request data known only by inner handlers.
+	record struct {
+		whois           model.Whois
+		upstream        string
+		upstreamLatency time.Duration
+	}
+
+	contextKey struct{}
+)
+
+var _ http.RoundTripper = (*RoundTripper)(nil)
+
+// New function returns a new access Logger for the proxy. Formats other than
+// the builtin ones are templates of the accessLogFormats configuration.
+func New(log zerolog.Logger, proxy string, cfg model.AccessLog) (*Logger, error) {
+	format := cfg.Format
+	if format == "" {
+		format = FormatJSON
+	}
+
+	var tmpl *template.Template
+	if format != FormatJSON && format != FormatCLF && format != FormatCombined {
+		text, ok := "", false
+		if config.Config != nil {
+			text, ok = config.Config.AccessLogFormats[format]
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+		}
+
+		var err error
+		if tmpl, err = parseTemplate(format, text); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := newSink(log, cfg, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Logger{
+		sink:     s,
+		template: tmpl,
+		format:   format,
+		proxy:    proxy,
+		redact:   cfg.Redact,
+		sample:   uint64(max(cfg.Sample, 0)),
+	}, nil
+}
+
+func newSink(log zerolog.Logger, cfg model.AccessLog, format string) (sink, error) {
+	switch cfg.Sink {
+	case "", SinkLog:
+		return &logSink{log: log, format: format}, nil
+	case SinkFile:
+		return openFileSink(cfg.File)
+	case SinkSyslog:
+		return newSyslogSink(cfg.Syslog)
+	case SinkHTTP:
+		return newHTTPSink(log, cfg.HTTP, format)
+	default:
+		return nil, ErrUnknownSink
+	}
+}
+
+// SetTail method sets the Tail that receives the lines of the access log.
+func (l *Logger) SetTail(t *Tail) {
+	l.tail = t
+}
+
+// Close method closes the sink.
+func (l *Logger) Close() error {
+	return l.sink.Close()
+}
+
+// Middleware method returns a middleware that logs the requests of a port.
+func (l *Logger) Middleware(port string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			rec := new(record)
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, rec))
+
+			lw := core.NewLogRecord(w)
+			next.ServeHTTP(lw, r)
+
+			e := &Entry{
+				Time:            start,
+				Err:             lw.Err(),
+				Proxy:           l.proxy,
+				Port:            port,
+				Client:          clientIP(r.RemoteAddr),
+				Method:          r.Method,
+				Host:            r.Host,
+				URL:             r.URL.RequestURI(),
+				Proto:           r.Proto,
+				Upstream:        rec.upstream,
+				UserAgent:       r.UserAgent(),
+				Referer:         r.Referer(),
+				Status:          lw.Status(),
+				Bytes:           lw.Bytes(),
+				Duration:        time.Since(start),
+				UpstreamLatency: rec.upstreamLatency,
+				Whois:           rec.whois,
+			}
+
+			if !l.sampled(e) {
+				return
+			}
+			redactEntry(e, l.redact)
+
+			line := l.formatEntry(e)
+
+			// sink errors can't be reported to the client, they are ignored
+			_ = l.sink.Write(e, line)
+
+			if l.tail != nil {
+				l.tail.Add(string(line))
+			}
+		})
+	}
+}
+
+// Identify function returns a middleware that records the user identity in the
+// access log entry. Must be used after the middlewares that identify the user.
+func Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec, ok := r.Context().Value(contextKey{}).(*record); ok {
+			if who, ok := model.WhoisFromContext(r.Context()); ok {
+				rec.whois = who
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewRoundTripper function returns a RoundTripper that records the upstream
+// host and latency in the access log entry of the request.
+func NewRoundTripper(next http.RoundTripper) *RoundTripper {
+	return &RoundTripper{next: next}
+}
+
+// RoundTrip method implements http.RoundTripper RoundTrip method.
+func (t *RoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r)
+
+	if rec, ok := r.Context().Value(contextKey{}).(*record); ok {
+		rec.upstream = r.URL.Host
+		rec.upstreamLatency = time.Since(start)
+	}
+
+	return resp, err
+}
+
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
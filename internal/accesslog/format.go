@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// clfTimeFormat is the time format used by the Common Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// jsonEntry is the JSON representation of an Entry.
+type jsonEntry struct {
+	Time              string  `json:"time"`
+	Proxy             string  `json:"proxy"`
+	Port              string  `json:"port"`
+	Client            string  `json:"client"`
+	Method            string  `json:"method"`
+	Host              string  `json:"host"`
+	URL               string  `json:"url"`
+	Proto             string  `json:"proto"`
+	Upstream          string  `json:"upstream,omitempty"`
+	UserAgent         string  `json:"userAgent,omitempty"`
+	Referer           string  `json:"referer,omitempty"`
+	Username          string  `json:"username,omitempty"`
+	DisplayName       string  `json:"displayName,omitempty"`
+	Error             string  `json:"error,omitempty"`
+	Status            int     `json:"status"`
+	Bytes             int64   `json:"bytes"`
+	DurationMs        float64 `json:"durationMs"`
+	UpstreamLatencyMs float64 `json:"upstreamLatencyMs"`
+}
+
+// formatEntry method returns the entry in the format of the logger.
+// Template errors fall back to JSON, so the entry is never lost.
+func (l *Logger) formatEntry(e *Entry) []byte {
+	if l.template == nil {
+		return format(l.format, e)
+	}
+
+	var b bytes.Buffer
+	if err := l.template.Execute(&b, templateData(e)); err != nil {
+		return formatJSON(e)
+	}
+
+	return b.Bytes()
+}
+
+// parseTemplate function parses a template format. It's executed with an
+// empty entry, so unknown fields are reported when the proxy starts.
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("access log format %s: %w", name, err)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, templateData(&Entry{Time: time.Now()})); err != nil {
+		return nil, fmt.Errorf("access log format %s: %w", name, err)
+	}
+
+	return tmpl, nil
+}
+
+// templateData function returns the fields of the entry available in
+// template formats.
+func templateData(e *Entry) map[string]any {
+	errMsg := ""
+	if e.Err != nil {
+		errMsg = e.Err.Error()
+	}
+
+	return map[string]any{
+		"time":            e.Time,
+		"proxy":           e.Proxy,
+		"port":            e.Port,
+		"client":          e.Client,
+		"method":          e.Method,
+		"host":            e.Host,
+		"path":            e.URL,
+		"proto":           e.Proto,
+		"userAgent":       e.UserAgent,
+		"referer":         e.Referer,
+		"user":            e.Whois.Username,
+		"displayName":     e.Whois.DisplayName,
+		"status":          e.Status,
+		"bytes":           e.Bytes,
+		"duration":        e.Duration,
+		"upstream":        e.Upstream,
+		"upstreamLatency": e.UpstreamLatency,
+		"error":           errMsg,
+	}
+}
+
+// format function returns the entry formatted as a single line.
+func format(f string, e *Entry) []byte {
+	switch f {
+	case FormatCLF:
+		return []byte(clf(e))
+	case FormatCombined:
+		return []byte(clf(e) + " " + quote(e.Referer) + " " + quote(e.UserAgent))
+	default:
+		return formatJSON(e)
+	}
+}
+
+func formatJSON(e *Entry) []byte {
+	j := jsonEntry{
+		Time:              e.Time.Format(time.RFC3339Nano),
+		Proxy:             e.Proxy,
+		Port:              e.Port,
+		Client:            e.Client,
+		Method:            e.Method,
+		Host:              e.Host,
+		URL:               e.URL,
+		Proto:             e.Proto,
+		Upstream:          e.Upstream,
+		UserAgent:         e.UserAgent,
+		Referer:           e.Referer,
+		Username:          e.Whois.Username,
+		DisplayName:       e.Whois.DisplayName,
+		Status:            e.Status,
+		Bytes:             e.Bytes,
+		DurationMs:        milliseconds(e.Duration),
+		UpstreamLatencyMs: milliseconds(e.UpstreamLatency),
+	}
+	if e.Err != nil {
+		j.Error = e.Err.Error()
+	}
+
+	// jsonEntry only has strings and numbers, it can't fail
+	b, _ := json.Marshal(j)
+
+	return b
+}
+
+// clf function returns the entry in Common Log Format.
+func clf(e *Entry) string {
+	var b strings.Builder
+
+	b.WriteString(dash(e.Client))
+	b.WriteString(" - ")
+	b.WriteString(dash(strings.ReplaceAll(e.Whois.Username, " ", "_")))
+	b.WriteString(" [")
+	b.WriteString(e.Time.Format(clfTimeFormat))
+	b.WriteString("] ")
+	b.WriteString(quote(e.Method + " " + e.URL + " " + e.Proto))
+	b.WriteString(" ")
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(e.Bytes, 10))
+
+	return b.String()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func quote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package accesslog
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// sampled method returns true if the entry is logged. With sampling, 1 of
+// every sample requests is logged, and all the errors.
+func (l *Logger) sampled(e *Entry) bool {
+	if l.sample <= 1 || e.Status >= http.StatusBadRequest {
+		return true
+	}
+
+	return (l.count.Add(1)-1)%l.sample == 0
+}
+
+// redactEntry function removes the data of the redact configuration from the
+// entry, before it's formatted.
+func redactEntry(e *Entry, cfg model.AccessLogRedact) {
+	if cfg.Query {
+		e.URL = stripQuery(e.URL)
+		e.Referer = stripQuery(e.Referer)
+	}
+
+	if cfg.Username {
+		e.Whois = model.Whois{Username: maskUsername(e.Whois.Username)}
+	}
+
+	for _, h := range cfg.Headers {
+		switch http.CanonicalHeaderKey(h) {
+		case "User-Agent":
+			e.UserAgent = ""
+		case "Referer":
+			e.Referer = ""
+		}
+	}
+}
+
+// stripQuery function returns the URL without its query string and fragment.
+func stripQuery(u string) string {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		return u[:i]
+	}
+
+	return u
+}
+
+// maskUsername function returns the username with only its first character,
+// and the domain of email addresses: alice@example.com is a***@example.com.
+func maskUsername(username string) string {
+	if username == "" {
+		return ""
+	}
+
+	name, domain, found := strings.Cut(username, "@")
+	_, size := utf8.DecodeRuneInString(name)
+	masked := name[:size] + "***"
+	if found {
+		masked += "@" + domain
+	}
+
+	return masked
+}
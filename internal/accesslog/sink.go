@@ -0,0 +1,369 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	megabyte = 1024 * 1024
+
+	httpQueueSize     = 1024
+	httpBatchSize     = 100
+	httpFlushInterval = time.Second
+	httpTimeout       = 10 * time.Second
+)
+
+var ErrSinkClosed = errors.New("access log sink closed")
+
+type (
+	// logSink writes entries to the application log.
+	logSink struct {
+		log    zerolog.Logger
+		format string
+	}
+
+	// fileSink writes entries to a file and rotates it by size.
+	// Proxies using the same path share the same fileSink.
+	fileSink struct {
+		file       *os.File
+		path       string
+		size       int64
+		maxSize    int64
+		maxBackups int
+		refs       int
+		mtx        sync.Mutex
+	}
+
+	// httpSink sends entries in batches to an HTTP endpoint.
+	httpSink struct {
+		log         zerolog.Logger
+		client      *http.Client
+		headers     map[string]string
+		queue       chan []byte
+		done        chan struct{}
+		url         string
+		contentType string
+		closed      bool
+		mtx         sync.RWMutex
+	}
+)
+
+var (
+	fileSinks    = make(map[string]*fileSink)
+	fileSinksMtx sync.Mutex
+)
+
+// Write method implements sink Write method.
+// JSON entries keep the structured fields, other formats are logged as message.
+func (s *logSink) Write(e *Entry, line []byte) error {
+	ev := s.log.Info()
+	msg := "request"
+	if e.Status >= http.StatusBadRequest {
+		ev = s.log.Error().Err(e.Err)
+		msg = "error"
+	}
+	if s.format != FormatJSON {
+		msg = string(line)
+	}
+
+	ev.
+		Int("status", e.Status).
+		Str("method", e.Method).
+		Str("host", e.Host).
+		Str("client", e.Client).
+		Str("url", e.URL).
+		Int64("bytes", e.Bytes).
+		Dur("duration", e.Duration).
+		Dur("upstreamLatency", e.UpstreamLatency).
+		Str("username", e.Whois.Username).
+		Msg(msg)
+
+	return nil
+}
+
+// Close method implements sink Close method.
+func (s *logSink) Close() error {
+	return nil
+}
+
+// openFileSink function returns the fileSink of the path, opening it if needed.
+func openFileSink(cfg model.AccessLogFile) (*fileSink, error) {
+	if cfg.Path == "" {
+		return nil, ErrMissingPath
+	}
+
+	path, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileSinksMtx.Lock()
+	defer fileSinksMtx.Unlock()
+
+	if s, ok := fileSinks[path]; ok {
+		s.refs++
+		return s, nil
+	}
+
+	s := &fileSink{
+		path:       path,
+		maxSize:    int64(cfg.MaxSize) * megabyte,
+		maxBackups: cfg.MaxBackups,
+		refs:       1,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	fileSinks[path] = s
+
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), consts.PermOwnerAll); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, consts.PermAllRead+consts.PermOwnerWrite)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.file = f
+	s.size = info.Size()
+
+	return nil
+}
+
+// Write method implements sink Write method.
+func (s *fileSink) Write(_ *Entry, line []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.file == nil {
+		return ErrSinkClosed
+	}
+
+	line = append(line, '\n')
+
+	if s.maxSize > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+
+	return err
+}
+
+// rotate method renames the current file to path.1, shifting the older backups.
+// Must be called with mtx locked.
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return s.open()
+	}
+
+	for i := s.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(s.backupName(i), s.backupName(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, s.backupName(1)); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *fileSink) backupName(i int) string {
+	return s.path + "." + strconv.Itoa(i)
+}
+
+// Close method implements sink Close method.
+// The file is closed when the last proxy using it is closed.
+func (s *fileSink) Close() error {
+	fileSinksMtx.Lock()
+	defer fileSinksMtx.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+
+	delete(fileSinks, s.path)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+func newHTTPSink(log zerolog.Logger, cfg model.AccessLogHTTP, format string) (*httpSink, error) {
+	if cfg.URL == "" {
+		return nil, ErrMissingURL
+	}
+
+	contentType := "text/plain"
+	if format == FormatJSON {
+		contentType = "application/x-ndjson"
+	}
+
+	s := &httpSink{
+		log:         log,
+		client:      &http.Client{Timeout: httpTimeout},
+		headers:     cfg.Headers,
+		url:         cfg.URL,
+		contentType: contentType,
+		queue:       make(chan []byte, httpQueueSize),
+		done:        make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+// Write method implements sink Write method.
+// Entries are dropped if the endpoint can't keep up.
+func (s *httpSink) Write(_ *Entry, line []byte) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if s.closed {
+		return ErrSinkClosed
+	}
+
+	select {
+	case s.queue <- line:
+	default:
+		s.log.Warn().Msg("access log queue full, dropping entry")
+	}
+
+	return nil
+}
+
+// run method sends the queued entries in batches.
+func (s *httpSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(httpFlushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	count := 0
+
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		if err := s.send(batch.Bytes()); err != nil {
+			s.log.Error().Err(err).Int("entries", count).Msg("error sending access log")
+		}
+		batch.Reset()
+		count = 0
+	}
+
+	for {
+		select {
+		case line, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+
+			batch.Write(line)
+			batch.WriteByte('\n')
+			count++
+
+			if count >= httpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *httpSink) send(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", s.contentType)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("access log endpoint returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Close method implements sink Close method.
+// Queued entries are sent before returning.
+func (s *httpSink) Close() error {
+	s.mtx.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mtx.Unlock()
+
+	<-s.done
+
+	return nil
+}
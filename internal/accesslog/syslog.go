@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+//go:build !windows && !plan9
+
+package accesslog
+
+import (
+	"log/syslog"
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// syslogSink writes entries to a syslog daemon.
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogSink(cfg model.AccessLogSyslog) (*syslogSink, error) {
+	w, err := syslog.Dial(cfg.Network, cfg.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, cfg.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{writer: w}, nil
+}
+
+// Write method implements sink Write method.
+func (s *syslogSink) Write(e *Entry, line []byte) error {
+	if e.Status >= http.StatusInternalServerError {
+		return s.writer.Err(string(line))
+	}
+	return s.writer.Info(string(line))
+}
+
+// Close method implements sink Close method.
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
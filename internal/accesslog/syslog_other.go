@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+//go:build windows || plan9
+
+package accesslog
+
+import (
+	"errors"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+var ErrSyslogNotSupported = errors.New("syslog access log sink is not supported on this platform")
+
+func newSyslogSink(_ model.AccessLogSyslog) (sink, error) {
+	return nil, ErrSyslogNotSupported
+}
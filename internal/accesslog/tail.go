@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package accesslog
+
+import (
+	"sync"
+)
+
+// Tail struct keeps the last access log lines of a proxy in memory and sends
+// new lines to its subscribers. It outlives the Logger, so lines are kept when
+// the access log configuration is reloaded.
+type Tail struct {
+	subscribers map[chan string]struct{}
+	lines       []string
+	size        int
+	mtx         sync.Mutex
+}
+
+// NewTail function returns a Tail that keeps the last size lines.
+func NewTail(size int) *Tail {
+	return &Tail{
+		size:        size,
+		subscribers: make(map[chan string]struct{}),
+	}
+}
+
+// Add method adds a line and sends it to the subscribers. Slow subscribers
+// lose lines instead of blocking the requests.
+func (t *Tail) Add(line string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.size {
+		t.lines = t.lines[len(t.lines)-t.size:]
+	}
+
+	for ch := range t.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// Lines method returns a copy of the kept lines, oldest first.
+func (t *Tail) Lines() []string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	lines := make([]string, len(t.lines))
+	copy(lines, t.lines)
+
+	return lines
+}
+
+// Subscribe method returns a channel that receives the new lines.
+func (t *Tail) Subscribe() chan string {
+	ch := make(chan string, t.size)
+
+	t.mtx.Lock()
+	t.subscribers[ch] = struct{}{}
+	t.mtx.Unlock()
+
+	return ch
+}
+
+// Unsubscribe method stops sending lines to the channel.
+func (t *Tail) Unsubscribe(ch chan string) {
+	t.mtx.Lock()
+	delete(t.subscribers, ch)
+	t.mtx.Unlock()
+}
@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+
+	// maxLine is the maximum size of an entry read by Query.
+	maxLine = 1 << 20
+)
+
+var ErrDisabled = errors.New("audit log is disabled")
+
+type (
+	// Log struct records the administrative actions in a JSON lines file.
+	// Entries are only appended, the file is never rewritten.
+	Log struct {
+		log  zerolog.Logger
+		file *os.File
+		path string
+		mtx  sync.Mutex
+	}
+
+	// Entry struct is an action in the audit log.
+	Entry struct {
+		Time time.Time `json:"time"`
+		// Actor is who ran the action, empty for the actions of TSDProxy
+		// like certificate renewals
+		Actor   *Actor `json:"actor,omitempty"`
+		Action  string `json:"action"`
+		Target  string `json:"target,omitempty"`
+		Detail  string `json:"detail,omitempty"`
+		Outcome string `json:"outcome"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	// Actor struct is the identity of the user of an action, from the
+	// Tailscale whois, OpenID Connect, the login or the API key.
+	Actor struct {
+		Username    string `json:"username"`
+		DisplayName string `json:"displayName,omitempty"`
+		Role        string `json:"role,omitempty"`
+		Address     string `json:"address,omitempty"`
+	}
+
+	// Filter struct selects the entries returned by Query, empty fields
+	// match all entries.
+	Filter struct {
+		Since  time.Time
+		Actor  string
+		Action string
+		Target string
+		// Limit is the maximum number of entries, the newest ones
+		Limit int
+	}
+)
+
+// Open function opens the audit log file, created if it doesn't exist.
+func Open(log zerolog.Logger, path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{
+		log:  core.ModuleLogger(log, "audit"),
+		file: file,
+		path: path,
+	}, nil
+}
+
+// Close method closes the audit log file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.file.Close()
+}
+
+// Record method appends an entry, its outcome is the error. Write errors
+// are logged, actions don't fail because of the audit log.
+func (l *Log) Record(e Entry, err error) {
+	if l == nil {
+		return
+	}
+
+	e.Time = time.Now()
+	e.Outcome = OutcomeSuccess
+	if err != nil {
+		e.Outcome = OutcomeFailure
+		e.Error = err.Error()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		l.log.Error().Err(err).Str("action", e.Action).Msg("Error encoding audit entry")
+		return
+	}
+	data = append(data, '\n')
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if _, err := l.file.Write(data); err != nil {
+		l.log.Error().Err(err).Str("action", e.Action).Msg("Error writing audit entry")
+	}
+}
+
+// Query method returns the entries matching the filter, newest first.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	if l == nil {
+		return nil, ErrDisabled
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLine)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a line cut by a crash doesn't hide the next ones
+			continue
+		}
+		if !f.match(e) {
+			continue
+		}
+
+		entries = append(entries, e)
+		if f.Limit > 0 && len(entries) > f.Limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(entries)
+
+	return entries, nil
+}
+
+// match method returns true if the entry matches the filter.
+func (f Filter) match(e Entry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.Target != "" && e.Target != f.Target {
+		return false
+	}
+	if f.Actor != "" && (e.Actor == nil || e.Actor.Username != f.Actor) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// groupsRequestTimeout is the maximum time to fetch the tailnet policy file.
+const groupsRequestTimeout = 30 * time.Second
+
+var ErrACLGroupsNotConfigured = errors.New("allowGroups requires a tailscale provider with OAuth credentials")
+
+// ACLGroups struct caches the group membership defined in the tailnet policy file.
+type ACLGroups struct {
+	log      zerolog.Logger
+	client   *tailscale.Client
+	groups   map[string][]string
+	cancel   context.CancelFunc
+	interval time.Duration
+	mtx      sync.RWMutex
+}
+
+// NewACLGroups function returns a new ACLGroups using the Tailscale API with OAuth credentials.
+func NewACLGroups(log zerolog.Logger, name, clientID, clientSecret string, interval time.Duration) *ACLGroups {
+	return &ACLGroups{
+		log: log.With().Str("aclgroups", name).Logger(),
+		client: &tailscale.Client{
+			Tailnet:   "-",
+			UserAgent: "tsdproxy",
+			HTTP: tailscale.OAuthConfig{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				Scopes:       []string{"policy_file:read"},
+			}.HTTPClient(),
+		},
+		groups:   make(map[string][]string),
+		interval: interval,
+	}
+}
+
+// Start method fetches the groups and refreshes them on the configured interval.
+func (g *ACLGroups) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	g.mtx.Lock()
+	g.cancel = cancel
+	g.mtx.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := g.refresh(ctx); err != nil {
+				g.log.Error().Err(err).Msg("error fetching tailnet groups")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop method stops refreshing the groups.
+func (g *ACLGroups) Stop() {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if g.cancel != nil {
+		g.cancel()
+	}
+}
+
+// refresh method replaces the cached groups with the ones in the policy file.
+// On error the previous groups are kept.
+func (g *ACLGroups) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, groupsRequestTimeout)
+	defer cancel()
+
+	acl, err := g.client.PolicyFile().Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	g.mtx.Lock()
+	g.groups = acl.Groups
+	g.mtx.Unlock()
+
+	g.log.Debug().Int("groups", len(acl.Groups)).Msg("tailnet groups refreshed")
+
+	return nil
+}
+
+// IsMember method returns true if the user is member of any of the groups.
+func (g *ACLGroups) IsMember(username string, groups []string) bool {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	for _, group := range groups {
+		if slices.ContainsFunc(g.groups[group], func(member string) bool {
+			return strings.EqualFold(member, username)
+		}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middleware method returns a middleware that only allows members of the groups.
+// Must be used after a middleware that identifies the user.
+func (g *ACLGroups) Middleware(groups []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			who, ok := model.WhoisFromContext(r.Context())
+			if !ok || !g.IsMember(who.Username, groups) {
+				g.log.Debug().Str("username", who.Username).Strs("groups", groups).Msg("access denied")
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
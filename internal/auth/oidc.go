@@ -0,0 +1,485 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/rs/zerolog"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// CallbackPath is the path used by identity providers to redirect back to the proxy.
+	CallbackPath = "/.tsdproxy/oidc/callback"
+
+	sessionCookieName = "tsdproxy_oidc"
+	stateCookieName   = "tsdproxy_oidc_state"
+	stateDuration     = 10 * time.Minute
+	randomBytesSize   = 32
+
+	// maxStates is the maximum number of pending logins, the oldest are
+	// dropped so anonymous requests can't grow them without limit
+	maxStates = 10000
+)
+
+var (
+	ErrInvalidState    = errors.New("invalid oidc state")
+	ErrMissingIDToken  = errors.New("missing id_token in token response")
+	ErrGroupNotAllowed = errors.New("user is not member of an allowed group")
+)
+
+// OIDCProviderNotFoundError is returned when a port references an unknown OIDC provider.
+type OIDCProviderNotFoundError struct {
+	ProviderName string
+}
+
+func (e *OIDCProviderNotFoundError) Error() string {
+	return "oidc provider " + e.ProviderName + " not found"
+}
+
+type (
+	// OIDC struct implements a OpenID Connect login flow for proxied ports.
+	OIDC struct {
+		log      zerolog.Logger
+		provider *oidc.Provider
+		verifier *oidc.IDTokenVerifier
+		sessions map[string]session
+		states   map[string]state
+		cfg      *config.OIDCConfig
+		// externalURL is the parsed cfg.ExternalURL, nil without it
+		externalURL    *url.URL
+		trustedProxies []netip.Prefix
+		name           string
+		mtx            sync.Mutex
+	}
+
+	session struct {
+		expires time.Time
+		whois   model.Whois
+	}
+
+	state struct {
+		expires     time.Time
+		redirectURL string
+		returnTo    string
+	}
+
+	claims struct {
+		Subject           string `json:"sub"`
+		Name              string `json:"name"`
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`     //nolint:tagliatelle
+		PreferredUsername string `json:"preferred_username"` //nolint:tagliatelle
+		Picture           string `json:"picture"`
+	}
+)
+
+// NewOIDC function returns a new OIDC authenticator.
+// The identity provider discovery is done on the first request.
+func NewOIDC(log zerolog.Logger, name string, cfg *config.OIDCConfig) *OIDC {
+	o := &OIDC{
+		log:      log.With().Str("oidc", name).Logger(),
+		name:     name,
+		cfg:      cfg,
+		sessions: make(map[string]session),
+		states:   make(map[string]state),
+	}
+
+	if cfg.ExternalURL != "" {
+		u, err := url.Parse(cfg.ExternalURL)
+		if err != nil {
+			o.log.Error().Err(err).Msg("invalid oidc externalURL")
+		} else {
+			o.externalURL = u
+		}
+	}
+
+	for _, p := range cfg.TrustedProxies {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			o.log.Error().Err(err).Str("trustedProxy", p).Msg("invalid oidc trusted proxy")
+			continue
+		}
+		o.trustedProxies = append(o.trustedProxies, prefix.Masked())
+	}
+
+	return o
+}
+
+// Middleware method returns a middleware that requires a valid OIDC session.
+// scheme is the protocol used by the port, needed to build the callback URL.
+func (o *OIDC) Middleware(scheme string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := o.discover(r.Context()); err != nil {
+				o.log.Error().Err(err).Msg("oidc discovery failed")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			scheme := o.scheme(r, scheme)
+
+			if r.URL.Path == CallbackPath {
+				o.callback(w, r, scheme)
+				return
+			}
+
+			if who, ok := o.Session(r); ok {
+				next.ServeHTTP(w, r.WithContext(model.WhoisNewContext(r.Context(), who)))
+				return
+			}
+
+			o.login(w, r, scheme, o.redirectURL(r, scheme))
+		})
+	}
+}
+
+// scheme method returns the scheme the user opened: the one of the
+// external URL, the X-Forwarded-Proto of a trusted proxy or the one of the
+// port.
+func (o *OIDC) scheme(r *http.Request, portScheme string) string {
+	if o.externalURL != nil {
+		return o.externalURL.Scheme
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); (proto == "http" || proto == "https") && o.fromTrustedProxy(r) {
+		return proto
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+	if portScheme == "https" {
+		return portScheme
+	}
+
+	return "http"
+}
+
+// redirectURL method returns the callback URL of the request, in the host
+// of the external URL if configured.
+func (o *OIDC) redirectURL(r *http.Request, scheme string) string {
+	if o.externalURL != nil {
+		return o.externalURL.Scheme + "://" + o.externalURL.Host + CallbackPath
+	}
+
+	return scheme + "://" + r.Host + CallbackPath
+}
+
+// fromTrustedProxy method returns true if the request comes from a
+// trusted reverse proxy.
+func (o *OIDC) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	return slices.ContainsFunc(o.trustedProxies, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// discover method initializes the identity provider if not initialized yet.
+// The discovery is requested without the lock, so a slow identity provider
+// doesn't block the sessions of other requests.
+func (o *OIDC) discover(ctx context.Context) error {
+	o.mtx.Lock()
+	discovered := o.provider != nil
+	o.mtx.Unlock()
+
+	if discovered {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, o.cfg.Issuer)
+	if err != nil {
+		return fmt.Errorf("error discovering %s: %w", o.cfg.Issuer, err)
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	// another request may have discovered it meanwhile
+	if o.provider == nil {
+		o.provider = provider
+		o.verifier = provider.Verifier(&oidc.Config{ClientID: o.cfg.ClientID})
+	}
+
+	return nil
+}
+
+func (o *OIDC) oauth2Config(redirectURL string) *oauth2.Config {
+	scopes := []string{oidc.ScopeOpenID, "profile", "email"}
+	scopes = append(scopes, o.cfg.Scopes...)
+
+	return &oauth2.Config{
+		ClientID:     o.cfg.ClientID,
+		ClientSecret: o.cfg.ClientSecret,
+		Endpoint:     o.provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// login method redirects the user to the identity provider.
+func (o *OIDC) login(w http.ResponseWriter, r *http.Request, scheme, redirectURL string) {
+	id, err := randomString()
+	if err != nil {
+		o.log.Error().Err(err).Msg("error generating oidc state")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	o.mtx.Lock()
+	o.cleanup()
+	o.evictState()
+	o.states[id] = state{
+		expires:     time.Now().Add(stateDuration),
+		redirectURL: redirectURL,
+		returnTo:    r.URL.RequestURI(),
+	}
+	o.mtx.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    id,
+		Path:     CallbackPath,
+		MaxAge:   int(stateDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, o.oauth2Config(redirectURL).AuthCodeURL(id), http.StatusFound)
+}
+
+// callback method handles the identity provider response and creates the session.
+func (o *OIDC) callback(w http.ResponseWriter, r *http.Request, scheme string) {
+	st, err := o.popState(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, err := o.oauth2Config(st.redirectURL).Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		o.log.Error().Err(err).Msg("error exchanging oidc code")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		o.log.Error().Err(ErrMissingIDToken).Msg("error validating oidc token")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		o.log.Error().Err(err).Msg("error validating oidc token")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	who, err := o.identity(idToken)
+	if err != nil {
+		o.log.Warn().Err(err).Str("subject", idToken.Subject).Msg("oidc login denied")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	id, err := randomString()
+	if err != nil {
+		o.log.Error().Err(err).Msg("error generating oidc session")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	o.mtx.Lock()
+	o.sessions[id] = session{
+		expires: time.Now().Add(o.cfg.SessionDuration),
+		whois:   who,
+	}
+	o.mtx.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(o.cfg.SessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	o.log.Info().Str("username", who.Username).Msg("oidc login")
+
+	http.Redirect(w, r, st.returnTo, http.StatusFound)
+}
+
+// identity method returns the Whois from the token claims and validates the allowed groups.
+func (o *OIDC) identity(idToken *oidc.IDToken) (model.Whois, error) {
+	var c claims
+	if err := idToken.Claims(&c); err != nil {
+		return model.Whois{}, err
+	}
+
+	if len(o.cfg.AllowedGroups) > 0 {
+		var all map[string]any
+		if err := idToken.Claims(&all); err != nil {
+			return model.Whois{}, err
+		}
+
+		if !hasAllowedGroup(all[o.cfg.GroupsClaim], o.cfg.AllowedGroups) {
+			return model.Whois{}, ErrGroupNotAllowed
+		}
+	}
+
+	// unverified emails can be set to anyone's email by the user
+	username := c.PreferredUsername
+	if c.Email != "" && c.EmailVerified {
+		username = c.Email
+	}
+	if username == "" {
+		username = c.Subject
+	}
+
+	return model.Whois{
+		ID:            c.Subject,
+		DisplayName:   c.Name,
+		Username:      username,
+		ProfilePicURL: c.Picture,
+	}, nil
+}
+
+// Session method returns the identity of the request OIDC session.
+func (o *OIDC) Session(r *http.Request) (model.Whois, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return model.Whois{}, false
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	s, ok := o.sessions[cookie.Value]
+	if !ok || time.Now().After(s.expires) {
+		delete(o.sessions, cookie.Value)
+		return model.Whois{}, false
+	}
+
+	return s.whois, true
+}
+
+// Logout method removes the request OIDC session.
+func (o *OIDC) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		o.mtx.Lock()
+		delete(o.sessions, cookie.Value)
+		o.mtx.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   o.scheme(r, "") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (o *OIDC) popState(r *http.Request) (state, error) {
+	cookie, err := r.Cookie(stateCookieName)
+	if err != nil || cookie.Value != r.URL.Query().Get("state") {
+		return state{}, ErrInvalidState
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	st, ok := o.states[cookie.Value]
+	delete(o.states, cookie.Value)
+	if !ok || time.Now().After(st.expires) {
+		return state{}, ErrInvalidState
+	}
+
+	return st, nil
+}
+
+// cleanup method removes expired states and sessions. Must be called with mtx locked.
+func (o *OIDC) cleanup() {
+	now := time.Now()
+	for k, v := range o.states {
+		if now.After(v.expires) {
+			delete(o.states, k)
+		}
+	}
+	for k, v := range o.sessions {
+		if now.After(v.expires) {
+			delete(o.sessions, k)
+		}
+	}
+}
+
+// evictState method removes the oldest pending login when there are
+// maxStates. Must be called with mtx locked.
+func (o *OIDC) evictState() {
+	if len(o.states) < maxStates {
+		return
+	}
+
+	var oldest string
+	for k, v := range o.states {
+		if oldest == "" || v.expires.Before(o.states[oldest].expires) {
+			oldest = k
+		}
+	}
+	delete(o.states, oldest)
+}
+
+func hasAllowedGroup(claim any, allowed []string) bool {
+	switch groups := claim.(type) {
+	case string:
+		return slices.Contains(allowed, groups)
+	case []any:
+		for _, g := range groups {
+			if s, ok := g.(string); ok && slices.Contains(allowed, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func randomString() (string, error) {
+	b := make([]byte, randomBytesSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package cachepurge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+	httpTimeout      = 30 * time.Second
+	maxErrorBodySize = 4096
+	// maxURLs is the number of URLs Cloudflare accepts in each purge request.
+	maxURLs = 30
+	// queueSize is the number of purges waiting to be sent, newer purges
+	// are dropped when the queue is full.
+	queueSize = 100
+)
+
+type (
+	// Purger struct purges the Cloudflare cache of proxies.
+	Purger struct {
+		log    zerolog.Logger
+		client *http.Client
+		queue  chan request
+		apiURL string
+		token  string
+		delay  time.Duration
+	}
+
+	request struct {
+		proxy string
+		cfg   model.CachePurge
+	}
+
+	// purgeRequest is the body of the purge_cache API.
+	purgeRequest struct {
+		Files           []string `json:"files,omitempty"`
+		PurgeEverything bool     `json:"purge_everything,omitempty"`
+	}
+
+	// cloudflareResponse is the envelope of Cloudflare API responses.
+	cloudflareResponse struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"errors"`
+		Success bool `json:"success"`
+	}
+)
+
+// New function returns a new Purger, nil if no API token is configured.
+func New(log zerolog.Logger, cfg config.CachePurgeConfig) *Purger {
+	if cfg.APIToken == "" {
+		return nil
+	}
+
+	return &Purger{
+		log:    core.ModuleLogger(log, "cachepurge"),
+		client: &http.Client{Timeout: httpTimeout},
+		queue:  make(chan request, queueSize),
+		apiURL: cloudflareAPIURL,
+		token:  cfg.APIToken,
+		delay:  cfg.Delay,
+	}
+}
+
+// Start method sends the queued purges until the context is done.
+func (p *Purger) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case r := <-p.queue:
+				if err := p.purge(ctx, r.cfg); err != nil {
+					p.log.Error().Err(err).Str("proxy", r.proxy).Str("zone", r.cfg.Zone).Msg("Error purging cache")
+					continue
+				}
+				p.log.Info().Str("proxy", r.proxy).Str("zone", r.cfg.Zone).Int("urls", len(r.cfg.URLs)).Msg("Cache purged")
+			}
+		}
+	}()
+}
+
+// Purge method queues a purge of the proxy cache after the delay. It never
+// blocks and does nothing in a nil Purger.
+func (p *Purger) Purge(proxy string, cfg model.CachePurge) {
+	if p == nil {
+		return
+	}
+
+	time.AfterFunc(p.delay, func() {
+		select {
+		case p.queue <- request{proxy: proxy, cfg: cfg}:
+		default:
+			p.log.Warn().Str("proxy", proxy).Msg("Cache purge queue is full, purge dropped")
+		}
+	})
+}
+
+// purge method purges the URLs of the zone, or everything without URLs.
+func (p *Purger) purge(ctx context.Context, cfg model.CachePurge) error {
+	if len(cfg.URLs) == 0 {
+		return p.send(ctx, cfg.Zone, purgeRequest{PurgeEverything: true})
+	}
+
+	for i := 0; i < len(cfg.URLs); i += maxURLs {
+		files := cfg.URLs[i:min(i+maxURLs, len(cfg.URLs))]
+		if err := p.send(ctx, cfg.Zone, purgeRequest{Files: files}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// send method sends a purge request to the Cloudflare API.
+func (p *Purger) send(ctx context.Context, zone string, body purgeRequest) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.apiURL+"/zones/"+url.PathEscape(zone)+"/purge_cache", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var cfResp cloudflareResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBodySize)).Decode(&cfResp); err != nil {
+		return fmt.Errorf("cloudflare api returned %s", resp.Status)
+	}
+
+	if !cfResp.Success {
+		msgs := make([]string, len(cfResp.Errors))
+		for i, e := range cfResp.Errors {
+			msgs[i] = fmt.Sprintf("%d: %s", e.Code, e.Message)
+		}
+		return fmt.Errorf("cloudflare api returned %s: %s", resp.Status, strings.Join(msgs, ", "))
+	}
+
+	return nil
+}
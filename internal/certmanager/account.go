@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package certmanager
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var ErrNoAccount = errors.New("no ACME account key in cache")
+
+// loadAccountKey function returns the ACME account key of the cache, a new
+// key is created and saved if there is none. The key is stored like
+// autocert stores it, so caches of previous versions keep their account.
+func loadAccountKey(ctx context.Context, cache autocert.Cache) (crypto.Signer, error) {
+	data, err := cache.Get(ctx, accountKeyName)
+	if err == nil {
+		return parseAccountKey(data)
+	}
+	if !errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, fmt.Errorf("reading ACME account key: %w", err)
+	}
+
+	key, data, err := newAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Put(ctx, accountKeyName, data); err != nil {
+		return nil, fmt.Errorf("saving ACME account key: %w", err)
+	}
+	log.Info().Msg("Created ACME account key")
+
+	return key, nil
+}
+
+// newAccountKey function returns a new ECDSA account key and its PEM
+// encoding.
+func newAccountKey() (crypto.Signer, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating ACME account key: %w", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// parseAccountKey function parses a PEM account key, in the EC, RSA or
+// PKCS #8 formats.
+func parseAccountKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid ACME account key: no PEM data")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			return k, nil
+		case *rsa.PrivateKey:
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid ACME account key: unsupported %s", block.Type)
+}
+
+// ExportAccountKey method returns the ACME account key in PEM, to back it
+// up or use the account in another ACME client.
+func (cm *CertManager) ExportAccountKey(ctx context.Context) ([]byte, error) {
+	data, err := cm.manager().cache.Get(ctx, accountKeyName)
+	if errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, ErrNoAccount
+	}
+
+	return data, err
+}
+
+// RolloverAccountKey method replaces the key of the ACME account with a new
+// one, the account and its certificates are kept.
+func (cm *CertManager) RolloverAccountKey(ctx context.Context) error {
+	m := cm.manager()
+
+	key, data, err := newAccountKey()
+	if err != nil {
+		return err
+	}
+
+	if err := m.client.AccountKeyRollover(ctx, key); err != nil {
+		return fmt.Errorf("rolling over ACME account key: %w", err)
+	}
+
+	// the old key isn't valid anymore, the new one is saved even if the
+	// request is canceled
+	if err := m.cache.Put(context.WithoutCancel(ctx), accountKeyName, data); err != nil {
+		return fmt.Errorf("saving ACME account key: %w", err)
+	}
+	cm.setAccountKey(key)
+	log.Info().Msg("ACME account key rolled over")
+
+	return nil
+}
+
+// DeactivateAccount method deactivates the ACME account, it can't be used
+// anymore. A new account, with a new key, is registered with the next
+// certificate.
+func (cm *CertManager) DeactivateAccount(ctx context.Context) error {
+	m := cm.manager()
+
+	if err := m.client.DeactivateReg(ctx); err != nil {
+		return fmt.Errorf("deactivating ACME account: %w", err)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	if err := m.cache.Delete(ctx, accountKeyName); err != nil {
+		return fmt.Errorf("deleting ACME account key: %w", err)
+	}
+	key, err := loadAccountKey(ctx, m.cache)
+	if err != nil {
+		return err
+	}
+	cm.setAccountKey(key)
+	log.Info().Msg("ACME account deactivated")
+
+	return nil
+}
+
+// setAccountKey method replaces the issuer with one using the account key.
+// The certificates are read again from the cache.
+func (cm *CertManager) setAccountKey(key crypto.Signer) {
+	cm.mtx.Lock()
+	defer cm.mtx.Unlock()
+
+	current := cm.certManager
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: current.client.DirectoryURL,
+		UserAgent:    current.client.UserAgent,
+		HTTPClient:   current.client.HTTPClient,
+	}
+
+	cm.certManager = newIssuer(client, current.solver, current.cache, current.domains)
+}
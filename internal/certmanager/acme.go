@@ -0,0 +1,314 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// obtainTimeout is the maximum time to obtain a certificate, the DNS
+// propagation included.
+const obtainTimeout = 10 * time.Minute
+
+var ErrNoDNS01Challenge = errors.New("ACME server didn't offer a dns-01 challenge")
+
+// issuer struct obtains the certificates of the domains with the ACME
+// DNS-01 challenge, solved with TXT records in Cloudflare. The certificates
+// are stored in the cache like autocert stores them, so caches of previous
+// versions keep their certificates.
+type issuer struct {
+	client *acme.Client
+	solver *cloudflareSolver
+	cache  autocert.Cache
+	// certs are the certificates read from the cache or obtained, by domain
+	certs   map[string]*tls.Certificate
+	domains []string
+	// registered is true when the account of the client key is registered
+	registered bool
+	mtx        sync.Mutex
+	// obtainMtx serializes the orders, a domain is ordered once when many
+	// handshakes miss its certificate
+	obtainMtx sync.Mutex
+}
+
+func newIssuer(client *acme.Client, solver *cloudflareSolver, cache autocert.Cache, domains []string) *issuer {
+	return &issuer{
+		client:  client,
+		solver:  solver,
+		cache:   cache,
+		domains: domains,
+		certs:   make(map[string]*tls.Certificate),
+	}
+}
+
+// GetCertificate method returns the certificate of the server name of the
+// client hello, obtained if there is none or it expired.
+func (i *issuer) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	domain := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if !slices.Contains(i.domains, domain) {
+		return nil, fmt.Errorf("disallowed host: %s", hello.ServerName)
+	}
+
+	if cert, err := i.cached(hello.Context(), domain); err == nil {
+		return cert, nil
+	} else if !errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, err
+	}
+
+	i.obtainMtx.Lock()
+	defer i.obtainMtx.Unlock()
+
+	// another handshake may have obtained it while this one waited
+	if cert, err := i.cached(hello.Context(), domain); err == nil {
+		return cert, nil
+	}
+
+	// the handshake context is canceled with the handshake, the order isn't
+	ctx, cancel := context.WithTimeout(context.Background(), obtainTimeout)
+	defer cancel()
+
+	return i.order(ctx, domain)
+}
+
+// obtain method orders a new certificate of the domain, replacing the
+// current one.
+func (i *issuer) obtain(ctx context.Context, domain string) (*tls.Certificate, error) {
+	i.obtainMtx.Lock()
+	defer i.obtainMtx.Unlock()
+
+	return i.order(ctx, domain)
+}
+
+// cached method returns the certificate of the domain in memory or in the
+// cache, autocert.ErrCacheMiss if there is none or it expired.
+func (i *issuer) cached(ctx context.Context, domain string) (*tls.Certificate, error) {
+	i.mtx.Lock()
+	cert := i.certs[domain]
+	i.mtx.Unlock()
+
+	if cert == nil {
+		data, err := i.cache.Get(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		if cert, err = parseCertificate(data); err != nil {
+			return nil, fmt.Errorf("reading certificate of %s: %w", domain, err)
+		}
+
+		i.mtx.Lock()
+		i.certs[domain] = cert
+		i.mtx.Unlock()
+	}
+
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	return cert, nil
+}
+
+// order method obtains a certificate of the domain: the order is
+// authorized with the DNS-01 challenges and finalized with a new key. Must
+// be called with obtainMtx locked.
+func (i *issuer) order(ctx context.Context, domain string) (*tls.Certificate, error) {
+	if err := i.register(ctx); err != nil {
+		return nil, err
+	}
+
+	o, err := i.client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return nil, fmt.Errorf("creating ACME order: %w", err)
+	}
+
+	for _, u := range o.AuthzURLs {
+		if err := i.authorize(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	if o, err = i.client.WaitOrder(ctx, o.URI); err != nil {
+		return nil, fmt.Errorf("waiting for ACME order: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{domain},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	der, _, err := i.client.CreateOrderCert(ctx, o.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("finalizing ACME order: %w", err)
+	}
+
+	data, err := encodeCertificate(key, der)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.cache.Put(ctx, domain, data); err != nil {
+		return nil, fmt.Errorf("saving certificate of %s: %w", domain, err)
+	}
+
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mtx.Lock()
+	i.certs[domain] = cert
+	i.mtx.Unlock()
+
+	log.Info().Str("domain", domain).Time("notAfter", cert.Leaf.NotAfter).Msg("Certificate obtained")
+
+	return cert, nil
+}
+
+// authorize method solves the DNS-01 challenge of a pending authorization.
+func (i *issuer) authorize(ctx context.Context, url string) error {
+	authz, err := i.client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("getting ACME authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return ErrNoDNS01Challenge
+	}
+
+	value, err := i.client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return err
+	}
+
+	domain := authz.Identifier.Value
+	if err := i.solver.Present(ctx, chal, domain, value); err != nil {
+		return fmt.Errorf("presenting DNS-01 challenge of %s: %w", domain, err)
+	}
+	defer func() {
+		// the records are deleted even if the order was canceled
+		if err := i.solver.CleanUp(context.WithoutCancel(ctx), chal, domain, value); err != nil {
+			log.Warn().Err(err).Str("domain", domain).Msg("Error deleting DNS-01 challenge record")
+		}
+	}()
+
+	if _, err := i.client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("accepting DNS-01 challenge of %s: %w", domain, err)
+	}
+	if _, err := i.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("validating DNS-01 challenge of %s: %w", domain, err)
+	}
+
+	return nil
+}
+
+// register method registers the account of the client key, once. Keys of
+// registered accounts, like the ones of previous versions, are reused.
+func (i *issuer) register(ctx context.Context) error {
+	i.mtx.Lock()
+	registered := i.registered
+	i.mtx.Unlock()
+
+	if registered {
+		return nil
+	}
+
+	_, err := i.client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("registering ACME account: %w", err)
+	}
+
+	i.mtx.Lock()
+	i.registered = true
+	i.mtx.Unlock()
+
+	return nil
+}
+
+// encodeCertificate function returns the key and the chain in PEM, the
+// format of the autocert cache.
+func encodeCertificate(key *ecdsa.PrivateKey, der [][]byte) ([]byte, error) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}); err != nil {
+		return nil, err
+	}
+	for _, b := range der {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: b}); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseCertificate function parses a certificate of the cache, its key
+// followed by its chain.
+func parseCertificate(data []byte) (*tls.Certificate, error) {
+	var (
+		key  crypto.Signer
+		cert tls.Certificate
+	)
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+			continue
+		}
+
+		k, err := parseAccountKey(pem.EncodeToMemory(block))
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	}
+
+	if key == nil || len(cert.Certificate) == 0 {
+		return nil, errors.New("invalid certificate: missing key or chain")
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cert.PrivateKey = key
+	cert.Leaf = leaf
+
+	return &cert, nil
+}
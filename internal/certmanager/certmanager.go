@@ -9,11 +9,16 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 	"errors"
 
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/audit"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/acme"
@@ -22,7 +27,24 @@ import (
 
 type CertManager struct {
 	config      config.LetsEncryptConfig
-	certManager *autocert.Manager
+	// domains are the domains with a certificate, see AllDomains
+	domains     []string
+	certManager *issuer
+	notifier    *notify.Notifier
+	problems    *problems.Registry
+	audit       *audit.Log
+
+	// health are the last checks of the certificates, by domain
+	health      map[string]*certHealth
+	// renewals are the scheduled renewals of the certificates, by domain
+	renewals    map[string]renewalSchedule
+	// ariURL is the renewal info endpoint of the ACME directory
+	ariURL      string
+	// nextCheck is the time of the next renewal check
+	nextCheck   time.Time
+	lastRenewal model.RenewalAttempt
+	renewing    bool
+	mtx         sync.Mutex
 }
 
 func NewCertManager(cfg config.LetsEncryptConfig) (*CertManager, error) {
@@ -33,51 +55,42 @@ func NewCertManager(cfg config.LetsEncryptConfig) (*CertManager, error) {
 		}
 	}
 
-	m := &autocert.Manager{
-		Cache:      autocert.DirCache(cacheDir),
-		Prompt:     autocert.AcceptTOS,
-		HostPolicy: func(ctx context.Context, host string) error {
-			if host == cfg.DomainName {
-				return nil
-			}
-			return fmt.Errorf("disallowed host: %s", host)
-		},
-	}
+	domains := cfg.AllDomains()
 
-	api, err := cloudflare.New(cfg.CloudflareAPIToken, "")
+	cache := autocert.DirCache(cacheDir)
+
+	solver, err := newCloudflareSolver(context.Background(), cfg, domains)
 	if err != nil {
-		return nil, fmt.Errorf("creating Cloudflare API client: %w", err)
+		return nil, err
 	}
 
-	// Fetch the zone ID
-	_, err = api.ZoneIDByName(cfg.DomainName)
+	// the account key is kept in the cache, the account is registered once
+	// and reused after restarts
+	key, err := loadAccountKey(context.Background(), cache)
 	if err != nil {
-		return nil, fmt.Errorf("getting Cloudflare zone ID: %w", err)
+		return nil, err
 	}
 
-	cm := &CertManager{
-		config:      cfg,
-		certManager: m,
+	// the certificates are obtained with the Cloudflare DNS challenge
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: acme.LetsEncryptURL,
 	}
 
-	// Configure the ACME client to use the Cloudflare DNS challenge.
-	cm.certManager.Client = &acme.Client{
-		DirectoryURL: acme.LetsEncryptURL,
-		ChallengeSolvers: map[string]acme.Solver{
-			acme.ChallengeTypeDNS01: &cloudflareSolver{
-				apiToken:  cfg.CloudflareAPIToken,
-				domainName: cfg.DomainName,
-				api:       api,
-				zoneID:    zoneID,
-			},
-		},
+	cm := &CertManager{
+		config:      cfg,
+		domains:     domains,
+		certManager: newIssuer(client, solver, cache, domains),
+		health:      make(map[string]*certHealth),
+		renewals:    make(map[string]renewalSchedule),
 	}
 
 	return cm, nil
 }
 
-func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-	return cm.certManager.GetCertificate(hello)
+// Domains method returns the domains with a certificate.
+func (cm *CertManager) Domains() []string {
+	return cm.domains
 }
 
 // GetTLSConfig returns a TLS configuration that uses Let's Encrypt certificates.
@@ -93,44 +106,19 @@ func (cm *CertManager) GetTLSConfig() (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
-func (cm *CertManager) StartRenewalProcess(ctx context.Context) {
-	if !cm.config.Enabled {
-		return
-	}
+// SetNotifier method sets the notifier of certificate renewal failures.
+func (cm *CertManager) SetNotifier(n *notify.Notifier) {
+	cm.notifier = n
+}
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				log.Info().Msg("Certificate renewal process stopped.")
-				return
-			case <-time.After(24 * time.Hour):
-				log.Info().Msg("Checking certificate expiry...")
-				certPath := filepath.Join(cm.config.CacheDir, cm.config.DomainName)
-
-				cert, err := tls.LoadX509KeyPair(certPath+".crt", certPath+".key")
-				if err != nil {
-					log.Error().Err(err).Msg("Error loading certificate")
-					continue
-				}
-
-				expiry := cert.Leaf.NotAfter
-				if time.Until(expiry) < 30*24*time.Hour {
-					log.Info().Msg("Certificate expiring soon, renewing...")
-
-					//Manually trigger renewal
-					_, err := cm.certManager.GetCertificate(&tls.ClientHelloInfo{ServerName: cm.config.DomainName})
-					if err != nil {
-						log.Error().Err(err).Msg("Error renewing certificate")
-					} else {
-						log.Info().Msg("Certificate renewed successfully.")
-					}
-				} else {
-					log.Info().Msg("Certificate is valid for more than 30 days.")
-				}
-			}
-		}
-	}()
+// SetProblems method sets the registry of certificate renewal failures.
+func (cm *CertManager) SetProblems(r *problems.Registry) {
+	cm.problems = r
+}
+
+// SetAudit method sets the audit log of the certificate issuances.
+func (cm *CertManager) SetAudit(l *audit.Log) {
+	cm.audit = l
 }
 
 func (cm *CertManager) ListenAndServeTLS(ctx context.Context, hostname string, port int, handler func(net.Listener, *tls.Config) error) error {
@@ -143,13 +131,15 @@ func (cm *CertManager) ListenAndServeTLS(ctx context.Context, hostname string, p
 		return fmt.Errorf("getting TLS config: %w", err)
 	}
 
-	// Check if certs exists
-	certPath := filepath.Join(cm.config.CacheDir, cm.config.DomainName)
-	if _, err := os.Stat(certPath+".crt"); errors.Is(err, os.ErrNotExist) {
-		log.Info().Msg("No certificate found, requesting...")
-		_, err := cm.certManager.GetCertificate(&tls.ClientHelloInfo{ServerName: cm.config.DomainName})
-		if err != nil {
-			log.Error().Err(err).Msg("Error getting certificate")
+	// request the missing certificates before the first client
+	for _, domain := range cm.domains {
+		if _, err := cm.chain(ctx, domain); errors.Is(err, ErrNoCertificate) {
+			log.Info().Str("domain", domain).Msg("No certificate found, requesting...")
+			_, err := cm.manager().GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+			if err != nil {
+				log.Error().Err(err).Str("domain", domain).Msg("Error getting certificate")
+			}
+			cm.audit.Record(audit.Entry{Action: "certificate issue", Target: domain}, err)
 		}
 	}
 
@@ -163,75 +153,124 @@ func (cm *CertManager) ListenAndServeTLS(ctx context.Context, hostname string, p
 	return handler(listener, tlsConfig)
 }
 
-func (cm *CertManager) SetupCloudflareChallenge(ctx context.Context) error {
-	if !cm.config.Enabled {
-		return nil
-	}
+// cloudflareSolver struct solves the DNS challenges of the domains in the
+// zones of a Cloudflare account.
+type cloudflareSolver struct {
+	api *cloudflare.API
+	// zones are the zones by domain, resolved when the solver is created
+	// and when a challenge of another domain is presented
+	zones map[string]zone
+	// propagationTimeout is the maximum wait for the TXT records in the
+	// nameservers of the zone
+	propagationTimeout time.Duration
+	mtx                sync.Mutex
+}
+
+// zone struct is a Cloudflare zone.
+type zone struct {
+	id   string
+	name string
+}
 
-	api, err := cloudflare.New(cm.config.CloudflareAPIToken, "")
+// newCloudflareSolver function returns a solver of the zones of the
+// domains, an error if a domain isn't in a zone of the account.
+func newCloudflareSolver(ctx context.Context, cfg config.LetsEncryptConfig, domains []string) (*cloudflareSolver, error) {
+	api, err := cloudflare.New(cfg.CloudflareAPIToken, "")
 	if err != nil {
-		return fmt.Errorf("creating Cloudflare API client: %w", err)
+		return nil, fmt.Errorf("creating Cloudflare API client: %w", err)
 	}
 
-	zoneID, err := api.ZoneIDByName(cm.config.DomainName)
-	if err != nil {
-		return fmt.Errorf("getting Cloudflare zone ID: %w", err)
+	c := &cloudflareSolver{
+		api:                api,
+		zones:              make(map[string]zone),
+		propagationTimeout: cfg.PropagationTimeout,
 	}
 
-	// Configure the ACME client to use the Cloudflare DNS challenge.
-	cm.certManager.Client = &acme.Client{
-		DirectoryURL: acme.LetsEncryptURL,
-		ChallengeSolvers: map[string]acme.Solver{
-			acme.ChallengeTypeDNS01: &cloudflareSolver{
-				apiToken:  cm.config.CloudflareAPIToken,
-				domainName: cm.config.DomainName,
-				api:       api,
-				zoneID:    zoneID,
-			},
-		},
+	for _, domain := range domains {
+		if _, err := c.zone(ctx, domain); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return c, nil
 }
 
-type cloudflareSolver struct {
-	apiToken  string
-	domainName string
-	api       *cloudflare.API
-	zoneID    string
+// zone method returns the zone of a domain, the zone with the longest name
+// the domain ends with, like example.com for a.b.example.com.
+func (c *cloudflareSolver) zone(ctx context.Context, domain string) (zone, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if z, ok := c.zones[domain]; ok {
+		return z, nil
+	}
+
+	for name := domain; strings.Contains(name, "."); {
+		var id string
+		err := withRetry(ctx, func() (err error) {
+			id, err = c.api.ZoneIDByName(name)
+			return err
+		})
+		if err == nil {
+			c.zones[domain] = zone{id: id, name: name}
+			return c.zones[domain], nil
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+
+	return zone{}, fmt.Errorf("getting Cloudflare zone ID of %s: no zone found in the account", domain)
 }
 
 func (c *cloudflareSolver) Present(ctx context.Context, challenge *acme.Challenge, domain string, value string) error {
 	// Implement the logic to create a TXT record in Cloudflare DNS.
 	log.Info().Str("domain", domain).Str("value", value).Msg("Creating TXT record in Cloudflare DNS")
 
-	recordName := "_acme-challenge." + domain
+	z, err := c.zone(ctx, domain)
+	if err != nil {
+		return err
+	}
 
-	record := cloudflare.DNSRecord{Type: "TXT", Name: recordName, Content: value, TTL: 60, Proxied: cloudflare.BoolPtr(false)}
+	recordName := "_acme-challenge." + domain
 
-	resp, err := c.api.CreateDNSRecord(ctx, c.zoneID, cloudflare.CreateDNSRecordParams{Record: record})
+	err = withRetry(ctx, func() error {
+		_, err := c.api.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(z.id), cloudflare.CreateDNSRecordParams{
+			Type:    "TXT",
+			Name:    recordName,
+			Content: value,
+			TTL:     60, //nolint:mnd
+			Proxied: cloudflare.BoolPtr(false),
+		})
+		return err
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Error creating TXT record in Cloudflare DNS")
 		return err
 	}
 
-	// Check if the response is successful
-	if len(resp.Response.Errors) > 0 {
-		log.Error().Interface("errors", resp.Response.Errors).Msg("Error creating TXT record in Cloudflare DNS")
-		return fmt.Errorf("error creating TXT record in Cloudflare DNS: %v", resp.Response.Errors)
-	}
-
-	return nil
+	// Let's Encrypt validates the challenge as soon as Present returns
+	return waitPropagation(ctx, z.name, recordName, value, c.propagationTimeout)
 }
 
 func (c *cloudflareSolver) CleanUp(ctx context.Context, challenge *acme.Challenge, domain string, value string) error {
 	// Implement the logic to delete the TXT record from Cloudflare DNS.
 	log.Info().Str("domain", domain).Str("value", value).Msg("Deleting TXT record from Cloudflare DNS")
 
+	z, err := c.zone(ctx, domain)
+	if err != nil {
+		return err
+	}
+
 	recordName := "_acme-challenge." + domain
 
 	// Get existing DNS records
-	records, _, err := c.api.DNSRecords(ctx, c.zoneID, cloudflare.DNSRecord{Type: "TXT", Name: recordName})
+	var records []cloudflare.DNSRecord
+	err = withRetry(ctx, func() (err error) {
+		records, _, err = c.api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(z.id),
+			cloudflare.ListDNSRecordsParams{Type: "TXT", Name: recordName})
+		return err
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Error getting TXT record in Cloudflare DNS")
 		return err
@@ -239,18 +278,14 @@ func (c *cloudflareSolver) CleanUp(ctx context.Context, challenge *acme.Challeng
 
 	// Delete all records with the same name
 	for _, r := range records {
-		resp, err := c.api.DeleteDNSRecord(ctx, c.zoneID, r.ID)
+		err := withRetry(ctx, func() error {
+			return c.api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(z.id), r.ID)
+		})
 		if err != nil {
 			log.Error().Err(err).Msg("Error deleting TXT record in Cloudflare DNS")
 			return err
 		}
-
-		// Check if the response is successful
-		if len(resp.Response.Errors) > 0 {
-			log.Error().Interface("errors", resp.Response.Errors).Msg("Error deleting TXT record in Cloudflare DNS")
-			return fmt.Errorf("error deleting TXT record in Cloudflare DNS: %v", resp.Response.Errors)
-		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
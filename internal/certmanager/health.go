@@ -0,0 +1,336 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
+)
+
+const (
+	// healthCheckInterval is the interval between the chain and revocation
+	// checks of the certificates.
+	healthCheckInterval = 6 * time.Hour
+	// ocspTimeout is the timeout of a request to the OCSP responder.
+	ocspTimeout = 15 * time.Second
+	// maxOCSPResponse is the maximum size of an OCSP response.
+	maxOCSPResponse = 1 << 20
+	// fixCertCheck is the suggested fix of revoked certificates and broken
+	// chains.
+	fixCertCheck = "Renew the certificate with `ctl cert renew`. If the chain is broken, delete the certificate from the cacheDir and restart TSDProxy."
+
+	ocspGood    = "good"
+	ocspRevoked = "revoked"
+	ocspUnknown = "unknown"
+)
+
+var certExpiry = metrics.NewGauge(
+	"tsdproxy_letsencrypt_certificate_expiry_timestamp_seconds",
+	"Expiry time of the Let's Encrypt certificates of the dashboard server, in unix seconds.",
+	"domain",
+)
+
+// certHealth struct stores the last check of a certificate and its OCSP
+// staple.
+type certHealth struct {
+	model.CertHealth
+	// serial is the serial number of the checked certificate, the staple is
+	// only sent with it
+	serial string
+	staple []byte
+	// nextUpdate is the expiry of the staple, set by the OCSP responder
+	nextUpdate time.Time
+	// expiryNotified is true when the expiry of the certificate was notified
+	expiryNotified bool
+}
+
+// GetCertificate method returns the certificate of the client hello, with
+// the OCSP response of the last check stapled.
+func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, err := cm.manager().GetCertificate(hello)
+	if err != nil || cert.Leaf == nil {
+		return cert, err
+	}
+
+	// the checked domains are normalized like the issued ones
+	domain := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	cm.mtx.Lock()
+	h := cm.health[domain]
+	cm.mtx.Unlock()
+
+	if h == nil || h.staple == nil || h.serial != cert.Leaf.SerialNumber.String() {
+		return cert, nil
+	}
+	if !h.nextUpdate.IsZero() && time.Now().After(h.nextUpdate) {
+		return cert, nil
+	}
+
+	// the certificate is shared by the handshakes, the staple is set on a copy
+	stapled := *cert
+	stapled.OCSPStaple = h.staple
+
+	return &stapled, nil
+}
+
+// StartHealthChecks method checks the chain and the revocation of the
+// certificates periodically, until the context is done.
+func (cm *CertManager) StartHealthChecks(ctx context.Context) {
+	if !cm.config.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			for _, domain := range cm.domains {
+				cm.checkHealth(ctx, domain)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkHealth method checks the certificate of a domain and reports the
+// revoked certificates and broken chains. Certificates that aren't issued
+// yet aren't checked.
+func (cm *CertManager) checkHealth(ctx context.Context, domain string) {
+	chain, err := cm.chain(ctx, domain)
+	if errors.Is(err, ErrNoCertificate) {
+		return
+	}
+
+	h := &certHealth{}
+	h.Checked = time.Now()
+
+	cm.mtx.Lock()
+	previous := cm.health[domain]
+	cm.mtx.Unlock()
+
+	if err == nil {
+		err = verifyChain(chain, domain)
+	}
+	if err == nil {
+		h.serial = chain[0].SerialNumber.String()
+		err = h.checkOCSP(ctx, chain, previous)
+	}
+	if err != nil {
+		h.Error = err.Error()
+	}
+
+	cm.mtx.Lock()
+	h.expiryNotified = previous != nil && previous.serial == h.serial && previous.expiryNotified
+	cm.health[domain] = h
+	cm.mtx.Unlock()
+
+	if len(chain) > 0 {
+		cm.checkExpiry(domain, chain[0], h)
+	}
+
+	if h.Error == "" {
+		cm.problems.Resolve(problems.SourceCertificateCheck, domain)
+		return
+	}
+
+	log.Error().Str("domain", domain).Str("error", h.Error).Msg("Certificate check failed")
+	cm.problems.Report(problems.Problem{
+		Source:  problems.SourceCertificateCheck,
+		Subject: domain,
+		Message: h.Error,
+		Fix:     fixCertCheck,
+	})
+
+	// a failure is notified once, not on every check
+	if previous == nil || previous.Error != h.Error {
+		cm.notifier.Notify(notify.Event{
+			Type:    notify.EventCertInvalid,
+			Message: domain + ": " + h.Error,
+		})
+	}
+}
+
+// checkExpiry method updates the expiry metric of a certificate, and
+// notifies once a certificate expiring in less than certExpiryWarning. The
+// renewals start 30 days before the expiry, so it's notified when they
+// fail.
+func (cm *CertManager) checkExpiry(domain string, leaf *x509.Certificate, h *certHealth) {
+	certExpiry.Set(leaf.NotAfter.Unix(), domain)
+
+	if time.Until(leaf.NotAfter) >= config.Config.CertExpiryWarning {
+		return
+	}
+
+	cm.mtx.Lock()
+	notified := h.expiryNotified
+	h.expiryNotified = true
+	cm.mtx.Unlock()
+
+	if notified {
+		return
+	}
+
+	log.Warn().Str("domain", domain).Time("notAfter", leaf.NotAfter).Msg("Certificate expiring soon")
+	cm.notifier.Notify(notify.Event{
+		Type:    notify.EventCertExpiring,
+		Message: domain + ": the certificate expires on " + leaf.NotAfter.Local().Format(time.DateTime) + ".",
+	})
+}
+
+// checkOCSP method requests the revocation status of the leaf certificate,
+// the response is stapled while it's good. When the responder fails, the
+// staple of the previous check is kept. Certificates without an OCSP
+// responder are only checked by their chain.
+func (h *certHealth) checkOCSP(ctx context.Context, chain []*x509.Certificate, previous *certHealth) error {
+	leaf := chain[0]
+	if len(leaf.OCSPServer) == 0 || len(chain) < 2 { //nolint:mnd
+		return nil
+	}
+	issuer := chain[1]
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return fmt.Errorf("creating OCSP request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ocspTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(req))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		// an unreachable responder isn't a certificate problem, the staple is
+		// kept until the next check
+		log.Warn().Err(err).Str("domain", leaf.Subject.CommonName).Msg("OCSP request failed")
+		h.OCSP = ocspUnknown
+		h.keepStaple(previous)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Warn().Str("status", resp.Status).Str("domain", leaf.Subject.CommonName).Msg("OCSP request failed")
+		h.OCSP = ocspUnknown
+		h.keepStaple(previous)
+		return nil
+	}
+
+	res, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		h.keepStaple(previous)
+		return fmt.Errorf("invalid OCSP response: %w", err)
+	}
+
+	switch res.Status {
+	case ocsp.Good:
+		h.OCSP = ocspGood
+		h.staple = raw
+		h.nextUpdate = res.NextUpdate
+		h.Stapled = true
+	case ocsp.Revoked:
+		h.OCSP = ocspRevoked
+		return fmt.Errorf("certificate revoked at %s", res.RevokedAt.Format(time.RFC3339))
+	default:
+		h.OCSP = ocspUnknown
+	}
+
+	return nil
+}
+
+// keepStaple method keeps the staple of the previous check of the same
+// certificate, until its next update.
+func (h *certHealth) keepStaple(previous *certHealth) {
+	if previous == nil || previous.staple == nil || previous.serial != h.serial {
+		return
+	}
+	if previous.nextUpdate.IsZero() || time.Now().After(previous.nextUpdate) {
+		return
+	}
+
+	h.staple = previous.staple
+	h.nextUpdate = previous.nextUpdate
+	h.Stapled = true
+}
+
+// chain method returns the cached certificate chain of the domain, the leaf
+// first.
+func (cm *CertManager) chain(ctx context.Context, domain string) ([]*x509.Certificate, error) {
+	data, err := cm.manager().cache.Get(ctx, domain)
+	if errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, ErrNoCertificate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, ErrNoCertificate
+	}
+
+	return chain, nil
+}
+
+// verifyChain function verifies the chain of a certificate with the system
+// roots.
+func verifyChain(chain []*x509.Certificate, domain string) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:       domain,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return fmt.Errorf("broken certificate chain: %w", err)
+	}
+
+	return nil
+}
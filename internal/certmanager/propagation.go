@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// minBackoff is the first wait of the retries, doubled on each retry up
+	// to maxBackoff.
+	minBackoff = 2 * time.Second
+	maxBackoff = 30 * time.Second
+	// apiRetries is the number of retries of a rate limited API request.
+	apiRetries = 5
+	// dnsTimeout is the timeout of a query to a nameserver.
+	dnsTimeout = 5 * time.Second
+)
+
+var errNotPropagated = errors.New("TXT record not propagated")
+
+// withRetry function runs a Cloudflare API request, retried with an
+// exponential backoff while it's rate limited.
+func withRetry(ctx context.Context, fn func() error) error {
+	backoff := minBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+
+		var rateLimited cloudflare.RatelimitError
+		if err == nil || !errors.As(err, &rateLimited) || attempt == apiRetries {
+			return err
+		}
+
+		log.Warn().Err(err).Dur("backoff", backoff).Msg("Cloudflare API rate limited, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// waitPropagation function waits until the TXT record has the value in all
+// the authoritative nameservers of the zone, checked with an exponential
+// backoff up to the timeout.
+func waitPropagation(ctx context.Context, zoneName, record, value string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := minBackoff
+
+	for {
+		err := checkPropagation(ctx, zoneName, record, value)
+		if err == nil {
+			log.Info().Str("record", record).Msg("TXT record propagated")
+			return nil
+		}
+
+		log.Debug().Err(err).Str("record", record).Dur("backoff", backoff).Msg("Waiting for TXT record propagation")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %w", record, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// checkPropagation function returns nil if all the nameservers of the zone
+// have the value in the TXT record.
+func checkPropagation(ctx context.Context, zoneName, record, value string) error {
+	nameservers, err := net.DefaultResolver.LookupNS(ctx, zoneName)
+	if err != nil {
+		return fmt.Errorf("looking up nameservers of %s: %w", zoneName, err)
+	}
+	if len(nameservers) == 0 {
+		return fmt.Errorf("no nameservers of %s", zoneName)
+	}
+
+	for _, ns := range nameservers {
+		values, err := nameserverResolver(ns.Host).LookupTXT(ctx, record)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.TrimSuffix(ns.Host, "."), err)
+		}
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("%s: %w", strings.TrimSuffix(ns.Host, "."), errNotPropagated)
+		}
+	}
+
+	return nil
+}
+
+// nameserverResolver function returns a resolver querying a nameserver
+// directly, without the caches of the recursive resolvers.
+func nameserverResolver(host string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: dnsTimeout}
+			return d.DialContext(ctx, network, net.JoinHostPort(strings.TrimSuffix(host, "."), "53"))
+		},
+	}
+}
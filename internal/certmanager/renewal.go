@@ -0,0 +1,299 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// minRenewalCheck is the minimum interval between renewal checks, the
+	// Retry-After of the renewal info is ignored below it.
+	minRenewalCheck = time.Hour
+	// renewJitter is the maximum time a renewal without renewal info is
+	// moved before NotAfter - renewBefore, so instances don't renew at once.
+	renewJitter = 48 * time.Hour
+	// ariTimeout is the timeout of a request to the ACME server.
+	ariTimeout = 30 * time.Second
+	// maxARIResponse is the maximum size of a directory or renewal info.
+	maxARIResponse = 1 << 20
+)
+
+var errNoRenewalInfo = errors.New("ACME server without renewal info")
+
+// renewalSchedule struct is the renewal of the certificate of a domain,
+// the time is picked in the renewal window.
+type renewalSchedule struct {
+	// serial is the serial number of the certificate, a new certificate
+	// gets a new schedule
+	serial string
+	start  time.Time
+	end    time.Time
+	at     time.Time
+	// ari is true if the window was suggested by the ACME server
+	ari bool
+}
+
+// renewalInfo struct is an ACME renewal info (RFC 9773) response.
+type renewalInfo struct {
+	SuggestedWindow struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"suggestedWindow"`
+	ExplanationURL string `json:"explanationURL,omitempty"`
+}
+
+// StartRenewalProcess method renews the certificates in their renewal
+// windows, until the context is done. The windows are suggested by the
+// ACME server with renewal info, or end renewBefore the expiry.
+func (cm *CertManager) StartRenewalProcess(ctx context.Context) {
+	if !cm.config.Enabled {
+		return
+	}
+
+	go func() {
+		for {
+			next := cm.checkRenewals(ctx)
+
+			cm.mtx.Lock()
+			cm.nextCheck = next
+			cm.mtx.Unlock()
+
+			select {
+			case <-ctx.Done():
+				log.Info().Msg("Certificate renewal process stopped.")
+				return
+			case <-time.After(time.Until(next)):
+			}
+		}
+	}()
+}
+
+// checkRenewals method renews the certificates in their renewal time, and
+// returns the time of the next check.
+func (cm *CertManager) checkRenewals(ctx context.Context) time.Time {
+	log.Info().Msg("Checking certificate renewals...")
+
+	next := time.Now().Add(renewalCheckInterval)
+	due := []string{}
+
+	for _, domain := range cm.domains {
+		chain, err := cm.chain(ctx, domain)
+		if errors.Is(err, ErrNoCertificate) {
+			// missing certificates are requested by ListenAndServeTLS and by
+			// the first handshakes
+			log.Debug().Str("domain", domain).Msg("No certificate to renew")
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Str("domain", domain).Msg("Error loading certificate")
+			continue
+		}
+
+		s, retry := cm.schedule(ctx, domain, chain)
+		if retry < time.Until(next) {
+			next = time.Now().Add(retry)
+		}
+
+		if time.Now().Before(s.at) {
+			log.Info().Str("domain", domain).Time("renewAt", s.at).Msg("Certificate renewal scheduled")
+			if s.at.Before(next) {
+				next = s.at
+			}
+			continue
+		}
+
+		log.Info().Str("domain", domain).Bool("ari", s.ari).Msg("Certificate in its renewal window, renewing...")
+		due = append(due, domain)
+	}
+
+	if len(due) == 0 {
+		return next
+	}
+
+	if err := cm.renew(false, due...); err != nil {
+		log.Error().Err(err).Msg("Error renewing certificate")
+		// the failed domains are retried sooner
+		if retry := time.Now().Add(minRenewalCheck); retry.Before(next) {
+			return retry
+		}
+		return next
+	}
+	log.Info().Strs("domains", due).Msg("Certificate renewed successfully.")
+
+	return next
+}
+
+// schedule method returns the renewal of the certificate of a domain, and
+// the wait until the renewal info must be requested again. The renewal time
+// is kept while the window doesn't change.
+func (cm *CertManager) schedule(ctx context.Context, domain string, chain []*x509.Certificate) (renewalSchedule, time.Duration) {
+	leaf := chain[0]
+	s := renewalSchedule{serial: leaf.SerialNumber.String()}
+	retry := renewalCheckInterval
+
+	info, retryAfter, err := cm.renewalInfo(ctx, leaf)
+	switch {
+	case err == nil:
+		s.start, s.end, s.ari = info.SuggestedWindow.Start, info.SuggestedWindow.End, true
+		if retryAfter > 0 {
+			retry = min(max(retryAfter, minRenewalCheck), renewalCheckInterval)
+		}
+		if info.ExplanationURL != "" {
+			log.Info().Str("domain", domain).Str("explanation", info.ExplanationURL).Msg("Renewal window explanation")
+		}
+	case errors.Is(err, errNoRenewalInfo):
+		s.end = leaf.NotAfter.Add(-renewBefore)
+		s.start = s.end.Add(-renewJitter)
+	default:
+		// the window of the last check is kept, or the default one
+		log.Warn().Err(err).Str("domain", domain).Msg("Error getting renewal info")
+		s.end = leaf.NotAfter.Add(-renewBefore)
+		s.start = s.end.Add(-renewJitter)
+		retry = minRenewalCheck
+	}
+
+	cm.mtx.Lock()
+	defer cm.mtx.Unlock()
+
+	previous, ok := cm.renewals[domain]
+	if ok && previous.serial == s.serial {
+		failed := err != nil && !errors.Is(err, errNoRenewalInfo)
+		if failed || previous.start.Equal(s.start) && previous.end.Equal(s.end) {
+			return previous, retry
+		}
+	}
+
+	s.at = s.start
+	if window := s.end.Sub(s.start); window > 0 {
+		s.at = s.start.Add(rand.N(window)) //nolint:gosec
+	}
+	cm.renewals[domain] = s
+
+	return s, retry
+}
+
+// renewAt method returns the scheduled renewal of a domain, the end of the
+// default window if it isn't scheduled yet.
+func (cm *CertManager) renewAt(domain string, notAfter time.Time) time.Time {
+	cm.mtx.Lock()
+	defer cm.mtx.Unlock()
+
+	if s, ok := cm.renewals[domain]; ok && !s.at.IsZero() {
+		return s.at
+	}
+
+	return notAfter.Add(-renewBefore)
+}
+
+// renewalInfo method requests the renewal window of a certificate to the
+// ACME server, with the Retry-After of the response.
+func (cm *CertManager) renewalInfo(ctx context.Context, leaf *x509.Certificate) (*renewalInfo, time.Duration, error) {
+	if len(leaf.AuthorityKeyId) == 0 {
+		return nil, 0, errNoRenewalInfo
+	}
+
+	endpoint, err := cm.renewalInfoURL(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the certificate identifier is the authority key identifier and the DER
+	// bytes of the serial number, RFC 9773 section 4.1
+	serial := leaf.SerialNumber.Bytes()
+	if len(serial) > 0 && serial[0]&0x80 != 0 {
+		serial = append([]byte{0}, serial...)
+	}
+	id := base64.RawURLEncoding.EncodeToString(leaf.AuthorityKeyId) + "." +
+		base64.RawURLEncoding.EncodeToString(serial)
+
+	var info renewalInfo
+	header, err := getJSON(ctx, strings.TrimSuffix(endpoint, "/")+"/"+id, &info)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.SuggestedWindow.Start.IsZero() || info.SuggestedWindow.End.Before(info.SuggestedWindow.Start) {
+		return nil, 0, errors.New("invalid renewal window")
+	}
+
+	var retryAfter time.Duration
+	if s, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(s) * time.Second
+	}
+
+	return &info, retryAfter, nil
+}
+
+// renewalInfoURL method returns the renewal info endpoint of the ACME
+// directory, read once. x/crypto/acme doesn't read it.
+func (cm *CertManager) renewalInfoURL(ctx context.Context) (string, error) {
+	cm.mtx.Lock()
+	endpoint := cm.ariURL
+	directoryURL := cm.certManager.client.DirectoryURL
+	cm.mtx.Unlock()
+
+	if endpoint != "" {
+		return endpoint, nil
+	}
+	if directoryURL == "" {
+		return "", errNoRenewalInfo
+	}
+
+	var dir struct {
+		RenewalInfo string `json:"renewalInfo"`
+	}
+	if _, err := getJSON(ctx, directoryURL, &dir); err != nil {
+		return "", fmt.Errorf("reading ACME directory: %w", err)
+	}
+	if dir.RenewalInfo == "" {
+		return "", errNoRenewalInfo
+	}
+
+	cm.mtx.Lock()
+	cm.ariURL = dir.RenewalInfo
+	cm.mtx.Unlock()
+
+	return dir.RenewalInfo, nil
+}
+
+// getJSON function requests an URL of the ACME server and decodes its JSON
+// response.
+func getJSON(ctx context.Context, url string, v any) (http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, ariTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxARIResponse)).Decode(v); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	return resp.Header, nil
+}
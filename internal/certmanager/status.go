@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/audit"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
+)
+
+const (
+	// renewalCheckInterval is the maximum interval between renewal checks.
+	renewalCheckInterval = 24 * time.Hour
+	// renewBefore is the time before expiry a certificate is renewed, when
+	// the ACME server doesn't suggest a renewal window.
+	renewBefore = 30 * 24 * time.Hour
+	// accountKeyName is the autocert cache key of the ACME account key.
+	accountKeyName = "acme_account+key"
+	// fixRenewal is the suggested fix of renewal failures.
+	fixRenewal = "Check the Cloudflare API token has the Zone:DNS Edit permission on the zones of the domains, then renew it with `ctl cert renew`."
+)
+
+var (
+	ErrRenewalInProgress = errors.New("certificate renewal already in progress")
+	ErrNoCertificate     = errors.New("no certificate in cache")
+)
+
+// Status method returns the state of the Let's Encrypt account and
+// certificates.
+func (cm *CertManager) Status(ctx context.Context) model.LetsEncryptStatus {
+	cm.mtx.Lock()
+	status := model.LetsEncryptStatus{
+		NextCheck:   cm.nextCheck,
+		LastRenewal: cm.lastRenewal,
+	}
+	cm.mtx.Unlock()
+
+	status.Account = cm.account(ctx)
+
+	status.Certificates = make([]model.LetsEncryptCert, 0, len(cm.domains))
+	for _, domain := range cm.domains {
+		cert := model.LetsEncryptCert{}
+		info, err := cm.certificate(ctx, domain)
+		if err != nil {
+			cert.Domain = domain
+			cert.Error = err.Error()
+		} else {
+			cert.CertInfo = info
+			cert.RenewAt = cm.renewAt(domain, info.NotAfter)
+		}
+
+		cm.mtx.Lock()
+		if h := cm.health[domain]; h != nil {
+			cert.Health = h.CertHealth
+		}
+		cm.mtx.Unlock()
+
+		status.Certificates = append(status.Certificates, cert)
+	}
+
+	return status
+}
+
+// Renew method starts a forced renewal of the certificates in background,
+// its result is the last renewal of Status.
+func (cm *CertManager) Renew() error {
+	cm.mtx.Lock()
+	renewing := cm.renewing
+	cm.mtx.Unlock()
+
+	if renewing {
+		return ErrRenewalInProgress
+	}
+
+	go func() {
+		if err := cm.renew(true, cm.domains...); err != nil {
+			log.Error().Err(err).Msg("Error renewing certificate")
+			return
+		}
+		log.Info().Msg("Certificate renewed successfully.")
+	}()
+
+	return nil
+}
+
+// renew method gets new certificates of the domains and records the
+// attempt. Renewal failures are notified for each domain.
+func (cm *CertManager) renew(forced bool, domains ...string) error {
+	cm.mtx.Lock()
+	if cm.renewing {
+		cm.mtx.Unlock()
+		return ErrRenewalInProgress
+	}
+	cm.renewing = true
+	cm.mtx.Unlock()
+
+	m := cm.manager()
+
+	detail := "renewal"
+	if forced {
+		detail = "forced renewal"
+	}
+
+	var errs []error
+	for _, domain := range domains {
+		ctx, cancel := context.WithTimeout(context.Background(), obtainTimeout)
+		_, err := m.obtain(ctx, domain)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", domain, err))
+		}
+		cm.reportRenewal(domain, err)
+		cm.audit.Record(audit.Entry{Action: "certificate issue", Target: domain, Detail: detail}, err)
+	}
+	err := errors.Join(errs...)
+
+	cm.mtx.Lock()
+	cm.renewing = false
+	cm.lastRenewal = model.RenewalAttempt{Time: time.Now(), Forced: forced}
+	if err != nil {
+		cm.lastRenewal.Error = err.Error()
+	}
+	cm.mtx.Unlock()
+
+	return err
+}
+
+// reportRenewal method notifies the renewal failure of a domain, or
+// resolves the problem of a previous failure.
+func (cm *CertManager) reportRenewal(domain string, err error) {
+	if err == nil {
+		cm.problems.Resolve(problems.SourceCertificate, domain)
+		return
+	}
+
+	cm.notifier.Notify(notify.Event{
+		Type:    notify.EventCertRenewalFailed,
+		Message: domain + ": " + err.Error(),
+	})
+	cm.problems.Report(problems.Problem{
+		Source:  problems.SourceCertificate,
+		Subject: domain,
+		Message: "renewal failed: " + err.Error(),
+		Fix:     fixRenewal,
+	})
+}
+
+// account method returns the registration of the ACME account. The account
+// is registered with the first certificate.
+func (cm *CertManager) account(ctx context.Context) model.ACMEAccount {
+	m := cm.manager()
+
+	if _, err := m.cache.Get(ctx, accountKeyName); err != nil {
+		if errors.Is(err, autocert.ErrCacheMiss) {
+			return model.ACMEAccount{}
+		}
+		return model.ACMEAccount{Error: err.Error()}
+	}
+
+	account := model.ACMEAccount{Registered: true}
+
+	reg, err := m.client.GetReg(ctx, "")
+	if err != nil {
+		account.Error = err.Error()
+		return account
+	}
+	account.URI = reg.URI
+	account.Status = reg.Status
+
+	return account
+}
+
+// certificate method returns the cached certificate of the domain.
+func (cm *CertManager) certificate(ctx context.Context, domain string) (model.CertInfo, error) {
+	chain, err := cm.chain(ctx, domain)
+	if err != nil {
+		return model.CertInfo{}, err
+	}
+	leaf := chain[0]
+
+	return model.CertInfo{
+		Domain:    domain,
+		Issuer:    leaf.Issuer.CommonName,
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+	}, nil
+}
+
+// manager method returns the issuer, replaced with the account key.
+func (cm *CertManager) manager() *issuer {
+	cm.mtx.Lock()
+	defer cm.mtx.Unlock()
+
+	return cm.certManager
+}
@@ -0,0 +1,322 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package compression compresses the responses of the targets with brotli or
+// gzip, for targets that don't compress their responses.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	// DefaultMinSize is the minimum size of the compressed responses of
+	// ports without minSize, smaller responses don't get smaller.
+	DefaultMinSize = 1024
+	// brotliLevel is fast enough to compress on the fly.
+	brotliLevel = 4
+
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// DefaultTypes are the compressed MIME types of ports without types.
+var DefaultTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/manifest+json",
+	"application/wasm",
+	"application/xml",
+	"application/*+json",
+	"application/*+xml",
+	"image/svg+xml",
+}
+
+var (
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+)
+
+type (
+	// writer struct is a http.ResponseWriter that compresses the body when
+	// the response can be compressed. The body is buffered until minSize
+	// to know its size.
+	writer struct {
+		http.ResponseWriter
+		encoder  io.WriteCloser
+		encoding string
+		types    []string
+		buf      bytes.Buffer
+		minSize  int
+		status   int
+		// decided is set when the response is being written, compressed
+		// or not
+		decided bool
+	}
+)
+
+// Middleware function returns a middleware that compresses the responses of
+// next, with the encoding preferred by the client.
+func Middleware(cfg model.Compression) func(next http.Handler) http.Handler {
+	types := cfg.Types
+	if len(types) == 0 {
+		types = DefaultTypes
+	}
+	minSize := cfg.MinSize
+	if minSize <= 0 {
+		minSize = DefaultMinSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiate(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &writer{
+				ResponseWriter: w,
+				encoding:       encoding,
+				types:          types,
+				minSize:        minSize,
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// WriteHeader method implements http.ResponseWriter WriteHeader method. The
+// header is written when the body is compressed or not.
+func (w *writer) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+
+	// informational responses, like upgrades, are written as they are
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		if status == http.StatusSwitchingProtocols {
+			w.decided = true
+		}
+		return
+	}
+
+	w.status = status
+	if !w.compressible() {
+		_ = w.start(false)
+	}
+}
+
+// Write method implements http.ResponseWriter Write method.
+func (w *writer) Write(b []byte) (int, error) {
+	if w.status == 0 && !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	switch {
+	case w.encoder != nil:
+		return w.encoder.Write(b)
+	case w.decided:
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush method implements http.Flusher Flush method. Streamed responses are
+// compressed from the first flush, whatever their size.
+func (w *writer) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			return
+		}
+		_ = w.start(true)
+	}
+
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap method returns the original http.ResponseWriter, used by
+// http.ResponseController.
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible method returns true if the response can be compressed, from
+// its status and headers.
+func (w *writer) compressible() bool {
+	h := w.Header()
+
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified ||
+		w.status == http.StatusPartialContent || h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	if strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < w.minSize {
+			return false
+		}
+	}
+
+	// event streams are flushed on every event
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+
+	for _, t := range w.types {
+		if matchType(t, mediaType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// start method writes the header and the buffered body, compressed with
+// compress.
+func (w *writer) start(compress bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if compress {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		// the compressed body isn't the same as the one of the ETag
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.encoder = newEncoder(w.encoding, w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+
+	return err
+}
+
+// close method writes the responses smaller than minSize and finishes the
+// compressed ones.
+func (w *writer) close() {
+	if !w.decided && w.status != 0 {
+		_ = w.start(false)
+	}
+
+	if w.encoder == nil {
+		return
+	}
+
+	_ = w.encoder.Close()
+
+	switch e := w.encoder.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(e)
+	case *brotli.Writer:
+		brotliPool.Put(e)
+	}
+}
+
+// newEncoder function returns the encoder of the encoding writing to w.
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == encodingBrotli {
+		if e, ok := brotliPool.Get().(*brotli.Writer); ok {
+			e.Reset(w)
+			return e
+		}
+		return brotli.NewWriterLevel(w, brotliLevel)
+	}
+
+	if e, ok := gzipPool.Get().(*gzip.Writer); ok {
+		e.Reset(w)
+		return e
+	}
+
+	return gzip.NewWriter(w)
+}
+
+// negotiate function returns the encoding of the Accept-Encoding header,
+// brotli is preferred over gzip with the same quality.
+func negotiate(accept string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		if name != encodingBrotli && name != encodingGzip || q <= 0 {
+			continue
+		}
+		if q > bestQ || q == bestQ && name == encodingBrotli {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// matchType function returns true if the media type matches the pattern,
+// where * matches any characters, like text/* or application/*+json.
+func matchType(pattern, mediaType string) bool {
+	pattern = strings.ToLower(pattern)
+
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return pattern == mediaType
+	}
+
+	return len(mediaType) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix)
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+)
+
+// streamWriter struct is a http.ResponseWriter that compresses an event
+// stream. The encoder is flushed with every event, so the events reach the
+// client at once, and keeps its dictionary between events, so the repeated
+// markup of the events is sent once.
+type streamWriter struct {
+	http.ResponseWriter
+	encoder io.WriteCloser
+}
+
+// Stream function returns a writer that compresses the event stream of w
+// with the encoding preferred by the client, and the function that finishes
+// it. Without a supported encoding, w is returned.
+func Stream(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	encoding := negotiate(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return w, func() {}
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", encoding)
+	h.Add("Vary", "Accept-Encoding")
+
+	sw := &streamWriter{
+		ResponseWriter: w,
+		encoder:        newEncoder(encoding, w),
+	}
+
+	return sw, sw.close
+}
+
+// Write method implements http.ResponseWriter Write method.
+func (w *streamWriter) Write(b []byte) (int, error) {
+	return w.encoder.Write(b)
+}
+
+// Flush method implements http.Flusher Flush method, sending the events
+// compressed so far.
+func (w *streamWriter) Flush() {
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap method returns the original http.ResponseWriter, used by
+// http.ResponseController.
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close method finishes the compressed stream.
+func (w *streamWriter) close() {
+	_ = w.encoder.Close()
+
+	switch e := w.encoder.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(e)
+	case *brotli.Writer:
+		brotliPool.Put(e)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/rs/zerolog/log"
@@ -20,43 +23,409 @@ type (
 	config struct {
 		DefaultProxyProvider string `validate:"required" default:"default" yaml:"defaultProxyProvider"`
 
-		Docker    map[string]*DockerTargetProviderConfig `validate:"dive,required" yaml:"docker"`
-		Lists     map[string]*ListTargetProviderConfig   `validate:"dive,required" yaml:"lists"`
-		Tailscale TailscaleProxyProviderConfig           `yaml:"tailscale"`
+		Docker     map[string]*DockerTargetProviderConfig   `validate:"dive,required" yaml:"docker"`
+		Lists      map[string]*ListTargetProviderConfig     `validate:"dive,required" yaml:"lists"`
+		HostScan   map[string]*HostScanTargetProviderConfig `validate:"dive,required" yaml:"hostScan"`
+		Replay     map[string]*ReplayTargetProviderConfig   `validate:"dive,required" yaml:"replay"`
+		Tailscale  TailscaleProxyProviderConfig             `yaml:"tailscale"`
+		SSHTunnels map[string]*SSHTunnelProxyProviderConfig `validate:"dive,required" yaml:"sshTunnels"`
+		Ngrok      map[string]*NgrokProxyProviderConfig     `validate:"dive,required" yaml:"ngrok"`
+		OIDC       map[string]*OIDCConfig                   `validate:"dive,required" yaml:"oidc"`
 
-		HTTP HTTPConfig `yaml:"http"`
-		Log  LogConfig  `yaml:"log"`
+		HTTP        HTTPConfig        `yaml:"http"`
+		Dashboard   DashboardConfig   `yaml:"dashboard"`
+		Log         LogConfig         `yaml:"log"`
 		LetsEncrypt LetsEncryptConfig `yaml:"letsEncrypt"`
+		Inventory   InventoryConfig   `yaml:"inventory"`
+		Sync        SyncConfig        `yaml:"sync"`
+		CachePurge  CachePurgeConfig  `yaml:"cachePurge"`
+		PublicDNS   PublicDNSConfig   `yaml:"publicDns"`
+		History     HistoryConfig     `yaml:"history"`
+		Startup     StartupConfig     `yaml:"startup"`
+		Limits      LimitsConfig      `yaml:"limits"`
+		Updates     UpdatesConfig     `yaml:"updates"`
+		Secrets     SecretsConfig     `yaml:"secrets"`
+		Audit       AuditConfig       `yaml:"audit"`
+		Debug       DebugConfig       `yaml:"debug"`
+		Tracing     TracingConfig     `yaml:"tracing"`
+		GeoIP       GeoIPConfig       `yaml:"geoip"`
 
-		ProxyAccessLog bool `validate:"boolean" default:"true" yaml:"proxyAccessLog"`
+		Notifications map[string]*NotificationConfig `validate:"dive,required" yaml:"notifications"`
+
+		// AccessLogFormats are text/template access log formats, selected by
+		// name in the accessLog format of the proxies.
+		AccessLogFormats map[string]string `yaml:"accessLogFormats,omitempty"`
+		// ErrorPages is the directory of the html/template error pages shown
+		// when a target is down or a proxy is in maintenance.
+		ErrorPages string `validate:"omitempty,dir" yaml:"errorPages,omitempty"`
+
+		ProxyAccessLog    bool          `validate:"boolean" default:"true" yaml:"proxyAccessLog"`
+		ProxyDrainTimeout time.Duration `validate:"min=0" default:"30s" yaml:"proxyDrainTimeout"`
+		// ShutdownTimeout is the maximum time of a graceful shutdown, the
+		// remaining connections are closed after it
+		ShutdownTimeout time.Duration `validate:"min=1s" default:"40s" yaml:"shutdownTimeout"`
+		// CertExpiryWarning is the time before the expiry of a certificate
+		// it's shown as expiring and notified
+		CertExpiryWarning time.Duration `validate:"min=1h" default:"336h" yaml:"certExpiryWarning"`
+		// HostnameConflicts is the policy of the proxies with the hostname of
+		// a running proxy: reject or suffix
+		HostnameConflicts string `validate:"oneof=reject suffix" default:"reject" yaml:"hostnameConflicts"`
+	}
+
+	// SecretsConfig struct stores the secret backends of the secret
+	// references, like vault:kv/tsdproxy#cf_token.
+	SecretsConfig struct {
+		Vault VaultConfig `yaml:"vault"`
+	}
+
+	// VaultConfig struct stores the HashiCorp Vault server of the vault:
+	// references. Address and token default to the VAULT_ADDR and
+	// VAULT_TOKEN environment variables of the Vault CLI.
+	VaultConfig struct {
+		Address   string `validate:"omitempty,url" yaml:"address,omitempty"`
+		Token     string `validate:"omitempty" yaml:"token,omitempty"`
+		TokenFile string `validate:"omitempty" yaml:"tokenFile,omitempty"`
+		Namespace string `validate:"omitempty" yaml:"namespace,omitempty"`
+		// KVVersion is the version of the KV secrets engine, 2 adds data/
+		// after the mount in the path of the references
+		KVVersion int           `validate:"oneof=1 2" default:"2" yaml:"kvVersion"`
+		Timeout   time.Duration `validate:"min=1s" default:"10s" yaml:"timeout"`
 	}
 
 	// LetsEncryptConfig stores Let's Encrypt configuration
 	LetsEncryptConfig struct {
-		Enabled bool `validate:"boolean" default:"false" yaml:"enabled"`
+		Enabled            bool   `validate:"boolean" default:"false" yaml:"enabled"`
 		CloudflareAPIToken string `validate:"omitempty" yaml:"cloudflareApiToken"`
-		DomainName string `validate:"omitempty" yaml:"domainName"`
-		CacheDir string `validate:"dir" default:"/data/certs" yaml:"cacheDir"`
+		DomainName         string `validate:"omitempty" yaml:"domainName"`
+		// Domains are more domains with a certificate, in any zone of the
+		// Cloudflare account of the token
+		Domains  []string `validate:"dive,hostname" yaml:"domains,omitempty"`
+		CacheDir string   `validate:"dir" default:"/data/certs" yaml:"cacheDir"`
+		// PropagationTimeout is the maximum wait for the challenge records in
+		// the nameservers of the zone
+		PropagationTimeout time.Duration `validate:"min=10s" default:"2m" yaml:"propagationTimeout"`
+	}
+
+	// InventoryConfig stores the proxy inventory publisher configuration.
+	// The inventory is published when proxies change and on every interval.
+	InventoryConfig struct {
+		CloudflareKV CloudflareKVConfig `yaml:"cloudflareKV"`
+		Worker       WorkerConfig       `yaml:"worker"`
+		Interval     time.Duration      `validate:"min=1m" default:"5m" yaml:"interval"`
+	}
+
+	// SyncConfig stores the replication of list files between tsdproxy instances.
+	// Files are sent with Taildrop by a dedicated node of the proxy provider.
+	SyncConfig struct {
+		Hostname      string        `validate:"omitempty,hostname_rfc1123" yaml:"hostname,omitempty"`
+		ProxyProvider string        `validate:"omitempty" yaml:"proxyProvider,omitempty"`
+		Lists         []string      `yaml:"lists,omitempty"`
+		Peers         []string      `yaml:"peers,omitempty"`
+		Interval      time.Duration `validate:"min=1s" default:"30s" yaml:"interval"`
+	}
+
+	// LimitsConfig stores the connection limits of the proxy ports, used by
+	// the ports that don't set their own.
+	LimitsConfig struct {
+		// MaxHeaderBytes is the maximum size of the request headers.
+		MaxHeaderBytes int `validate:"min=4096" default:"1048576" yaml:"maxHeaderBytes"`
+		// MaxConnections is the maximum of concurrent connections of each port,
+		// 0 for unlimited. Other connections wait in the accept queue.
+		MaxConnections int `validate:"min=0" default:"0" yaml:"maxConnections"`
+		// AcceptBackoff is the maximum wait to accept connections again after
+		// an accept error, like too many open files.
+		AcceptBackoff time.Duration `validate:"min=5ms" default:"1s" yaml:"acceptBackoff"`
+		// MaxRequests is the maximum of requests in flight of all proxies,
+		// shared between the proxies. 0 sizes it from the CPUs and memory of
+		// the host, -1 for unlimited.
+		MaxRequests int `validate:"min=-1" default:"0" yaml:"maxRequests"`
+		// QueueTimeout is the maximum wait of a request for the request
+		// budget, before it's rejected with 503.
+		QueueTimeout time.Duration `validate:"min=0" default:"5s" yaml:"queueTimeout"`
+	}
+
+	// UpdatesConfig stores the check of new releases of the update channel,
+	// stable releases or also the beta pre-releases.
+	UpdatesConfig struct {
+		Channel  string        `validate:"oneof=stable beta" default:"stable" yaml:"channel"`
+		Interval time.Duration `validate:"min=1h" default:"24h" yaml:"interval"`
+		// Disabled stops checking for new releases.
+		Disabled bool `validate:"boolean" default:"false" yaml:"disabled,omitempty"`
+	}
+
+	// AuditConfig stores the audit log of the administrative actions, saved
+	// in the data directory.
+	AuditConfig struct {
+		// Disabled stops recording the actions.
+		Disabled bool `validate:"boolean" default:"false" yaml:"disabled,omitempty"`
+	}
+
+	// TracingConfig stores the OpenTelemetry traces of the proxied requests,
+	// exported to an OTLP/HTTP endpoint. Tracing is disabled without an
+	// endpoint.
+	TracingConfig struct {
+		// Headers are sent with the exported traces, like API keys.
+		Headers map[string]string `yaml:"headers,omitempty"`
+		// Endpoint is the OTLP/HTTP traces URL, like http://tempo:4318/v1/traces
+		Endpoint    string `validate:"omitempty,url" yaml:"endpoint,omitempty"`
+		ServiceName string `default:"tsdproxy" yaml:"serviceName"`
+		// SampleRatio is the ratio of the requests without a sampled parent
+		// that are traced.
+		SampleRatio float64 `validate:"gte=0,lte=1" default:"1" yaml:"sampleRatio"`
+	}
+
+	// GeoIPConfig stores the MaxMind database of the countries of the client
+	// addresses, used by the allowedCountries and deniedCountries of the
+	// ports.
+	GeoIPConfig struct {
+		// Database is the path of a GeoLite2 or GeoIP2 Country or City
+		// database, in the MaxMind DB format.
+		Database string `validate:"omitempty,file" yaml:"database,omitempty"`
+	}
+
+	// DebugConfig stores the diagnostics endpoints, only available to the
+	// dashboard admins.
+	DebugConfig struct {
+		// Disabled removes the pprof, profile and status endpoints.
+		Disabled bool `validate:"boolean" default:"false" yaml:"disabled,omitempty"`
+	}
+
+	// HistoryConfig stores the status history of the proxies, saved in the data directory.
+	HistoryConfig struct {
+		Enabled   bool          `validate:"boolean" default:"true" yaml:"enabled"`
+		Retention time.Duration `validate:"min=1h" default:"720h" yaml:"retention"`
+	}
+
+	// StartupConfig stores the start of the proxies, limited to avoid
+	// logging in many nodes to the control server at once.
+	StartupConfig struct {
+		// Concurrency is the number of proxies starting at the same time, 0
+		// for no limit
+		Concurrency int `validate:"min=0" default:"10" yaml:"concurrency"`
+		// Jitter is the maximum random delay before starting each proxy
+		Jitter time.Duration `validate:"min=0" default:"0s" yaml:"jitter"`
+		// RestoreTimeout is the time the target providers have to list the
+		// restored proxies, they are removed after it
+		RestoreTimeout time.Duration `validate:"min=1s" default:"2m" yaml:"restoreTimeout"`
+		// Restore starts the last known proxies before the target providers
+		// list them
+		Restore bool `validate:"boolean" default:"false" yaml:"restore"`
+	}
+
+	// NotificationConfig stores a notification sink. Events filters the sent
+	// events, all events are sent if empty.
+	NotificationConfig struct {
+		Type      string                  `validate:"required,oneof=webhook slack ntfy email" yaml:"type"`
+		URL       string                  `validate:"omitempty,url" yaml:"url,omitempty"`
+		Token     string                  `validate:"omitempty" yaml:"token,omitempty"`
+		TokenFile string                  `validate:"omitempty" yaml:"tokenFile,omitempty"`
+		Events    []string                `validate:"dive,oneof=proxyError authNeeded hostnameConflict certRenewalFailed certInvalid certExpiring providerDisconnected providerReconnected stateRecovered" yaml:"events,omitempty"`
+		Email     EmailNotificationConfig `yaml:"email,omitempty"`
+	}
+
+	// EmailNotificationConfig stores the SMTP server of email notifications.
+	EmailNotificationConfig struct {
+		Host         string   `validate:"omitempty,ip|hostname" yaml:"host,omitempty"`
+		Username     string   `validate:"omitempty" yaml:"username,omitempty"`
+		Password     string   `validate:"omitempty" yaml:"password,omitempty"`
+		PasswordFile string   `validate:"omitempty" yaml:"passwordFile,omitempty"`
+		From         string   `validate:"omitempty,email" yaml:"from,omitempty"`
+		To           []string `validate:"dive,email" yaml:"to,omitempty"`
+		Port         uint16   `validate:"numeric,min=1,max=65535" default:"587" yaml:"port"`
+	}
+
+	// CloudflareKVConfig stores the Workers KV namespace where the inventory is written.
+	CloudflareKVConfig struct {
+		AccountID    string `validate:"required_with=NamespaceID" yaml:"accountId,omitempty"`
+		NamespaceID  string `validate:"required_with=AccountID" yaml:"namespaceId,omitempty"`
+		APIToken     string `validate:"omitempty" yaml:"apiToken,omitempty"`
+		APITokenFile string `validate:"omitempty" yaml:"apiTokenFile,omitempty"`
+		Key          string `validate:"required" default:"tsdproxy-inventory" yaml:"key"`
+	}
+
+	// CachePurgeConfig stores the Cloudflare API token used to purge the
+	// cache of proxies after they are restarted or updated.
+	CachePurgeConfig struct {
+		APIToken     string `validate:"omitempty" yaml:"apiToken,omitempty"`
+		APITokenFile string `validate:"omitempty" yaml:"apiTokenFile,omitempty"`
+		// Delay is the time to wait for the new target to be ready.
+		Delay time.Duration `validate:"min=0" default:"10s" yaml:"delay"`
+	}
+
+	// PublicDNSConfig stores the Cloudflare zone where the records of the
+	// proxies with a public DNS name are published. Every tsdproxy instance
+	// serving the same name adds its own records while the proxy is healthy,
+	// so the name resolves to all the healthy instances.
+	PublicDNSConfig struct {
+		APIToken     string `validate:"omitempty" yaml:"apiToken,omitempty"`
+		APITokenFile string `validate:"omitempty" yaml:"apiTokenFile,omitempty"`
+		ZoneID       string `validate:"required_with=Addresses" yaml:"zoneId,omitempty"`
+		// Addresses are the public addresses of this instance, each one is a
+		// record. Instances with more addresses get more of the traffic.
+		Addresses []string `validate:"dive,ip" yaml:"addresses,omitempty"`
+		// TTL is the time to live of the records in seconds, 1 is automatic.
+		TTL      int           `validate:"min=1" default:"60" yaml:"ttl"`
+		Proxied  bool          `validate:"boolean" default:"false" yaml:"proxied"`
+		Interval time.Duration `validate:"min=10s" default:"1m" yaml:"interval"`
+	}
+
+	// WorkerConfig stores a Worker endpoint that receives the inventory,
+	// for example to store it in a Durable Object.
+	WorkerConfig struct {
+		URL       string `validate:"omitempty,url" yaml:"url,omitempty"`
+		Token     string `validate:"omitempty" yaml:"token,omitempty"`
+		TokenFile string `validate:"omitempty" yaml:"tokenFile,omitempty"`
 	}
 
 	// LogConfig stores logging configuration.
 	LogConfig struct {
-		Level string `validate:"required,oneof=debug info warn error fatal panic trace" default:"info" yaml:"level"`
-		JSON  bool   `validate:"boolean" default:"false" yaml:"json"`
+		// Modules are the levels of the modules that override Level, like
+		// proxymanager: debug
+		Modules map[string]string `validate:"dive,oneof=debug info warn error fatal panic trace" yaml:"modules,omitempty"`
+		Level   string            `validate:"required,oneof=debug info warn error fatal panic trace" default:"info" yaml:"level"`
+		JSON    bool              `validate:"boolean" default:"false" yaml:"json"`
 	}
 
 	// HTTPConfig stores HTTP configuration.
 	HTTPConfig struct {
 		Hostname string `validate:"ip|hostname,required" default:"0.0.0.0" yaml:"hostname"`
-		Port     uint16 `validate:"numeric,min=1,max=65535,required" default:"8080" yaml:"port"`
+		// Listeners replace hostname and port with one or more listeners,
+		// on the host or as Tailscale nodes.
+		Listeners []HTTPListenerConfig `validate:"dive" yaml:"listeners,omitempty"`
+		Port      uint16               `validate:"numeric,min=1,max=65535,required" default:"8080" yaml:"port"`
+	}
+
+	// HTTPListenerConfig stores a listener of the dashboard server, an
+	// address of the host or a Tailscale node.
+	HTTPListenerConfig struct {
+		// Tailscale serves the dashboard with HTTPS as a node of a proxy
+		// provider.
+		Tailscale *HTTPTailscaleConfig `yaml:"tailscale,omitempty"`
+		// Address is the host address, like 127.0.0.1:8080.
+		Address string `validate:"required_without=Tailscale,excluded_with=Tailscale,omitempty,hostname_port" yaml:"address,omitempty"`
+	}
+
+	// HTTPTailscaleConfig stores the Tailscale node of a dashboard listener.
+	HTTPTailscaleConfig struct {
+		Hostname string `validate:"required,hostname" yaml:"hostname"`
+		// ProxyProvider is the proxy provider of the node,
+		// defaultProxyProvider when empty.
+		ProxyProvider string `yaml:"proxyProvider,omitempty"`
+	}
+
+	// DashboardConfig stores dashboard configuration.
+	DashboardConfig struct {
+		Auth DashboardAuthConfig `yaml:"auth"`
+		// Groups is the order of the proxy groups, other groups are shown
+		// after them sorted by name.
+		Groups []string `yaml:"groups"`
+		// Embed allows other dashboards to iframe proxy cards and status
+		// widgets with signed tokens.
+		Embed DashboardEmbedConfig `yaml:"embed"`
+	}
+
+	// DashboardEmbedConfig stores the embedding of the dashboard widgets.
+	DashboardEmbedConfig struct {
+		// FrameAncestors are the origins allowed to iframe the widgets, any
+		// origin when empty.
+		FrameAncestors []string `yaml:"frameAncestors,omitempty"`
+		// MaxTTL is the maximum lifetime of the embed tokens.
+		MaxTTL  time.Duration `validate:"min=1m" default:"8760h" yaml:"maxTTL"`
+		Enabled bool          `validate:"boolean" default:"false" yaml:"enabled"`
+	}
+
+	// DashboardAuthConfig stores dashboard authentication configuration.
+	// Requests from TrustedProxies are identified by the tsdproxy user headers,
+	// other requests require a local user or an OIDC login.
+	DashboardAuthConfig struct {
+		Users           map[string]*DashboardUserConfig `validate:"dive,required" yaml:"users,omitempty"`
+		APIKeys         map[string]*APIKeyConfig        `validate:"dive,required" yaml:"apiKeys,omitempty"`
+		OIDC            string                          `validate:"omitempty" yaml:"oidc,omitempty"`
+		DefaultRole     string                          `validate:"oneof=viewer admin" default:"viewer" yaml:"defaultRole"`
+		Admins          []string                        `yaml:"admins,omitempty"`
+		TrustedProxies  []string                        `validate:"dive,cidr" default:"[\"127.0.0.1/32\",\"::1/128\"]" yaml:"trustedProxies"`
+		SessionDuration time.Duration                   `validate:"min=1m" default:"24h" yaml:"sessionDuration"`
+		Enabled         bool                            `validate:"boolean" default:"false" yaml:"enabled"`
+	}
+
+	// DashboardUserConfig stores a dashboard local user.
+	DashboardUserConfig struct {
+		PasswordHash string `validate:"required" yaml:"passwordHash"`
+		Role         string `validate:"omitempty,oneof=viewer admin" yaml:"role,omitempty"`
+	}
+
+	// APIKeyConfig stores a key of the API, sent in the Authorization Bearer header.
+	APIKeyConfig struct {
+		Key     string `validate:"omitempty" yaml:"key,omitempty"`
+		KeyFile string `validate:"omitempty" yaml:"keyFile,omitempty"`
+		Role    string `validate:"oneof=viewer admin" default:"viewer" yaml:"role"`
 	}
 
 	// DockerTargetProviderConfig struct stores Docker target provider configuration.
 	DockerTargetProviderConfig struct {
+		// Host is the Docker daemon, unix://, tcp:// or ssh://user@host
 		Host                     string `validate:"required,uri" default:"unix:///var/run/docker.sock" yaml:"host"`
 		TargetHostname           string `validate:"ip|hostname" default:"172.31.0.1" yaml:"targetHostname"`
 		DefaultProxyProvider     string `validate:"omitempty" yaml:"defaultProxyProvider,omitempty"`
 		TryDockerInternalNetwork bool   `validate:"boolean" default:"false" yaml:"tryDockerInternalNetwork"`
+		// TraefikLabels proxies the containers enabled in Traefik, with the
+		// hostname, port and TLS of their Traefik labels when they don't
+		// have tsdproxy labels.
+		TraefikLabels bool `validate:"boolean" default:"false" yaml:"traefikLabels,omitempty"`
+		// HostnameTemplate is the text/template of the hostname of the
+		// containers without tsdproxy.name, like {{.ContainerName}}-{{.Project}}.
+		HostnameTemplate string `yaml:"hostnameTemplate,omitempty"`
+		// HostnameMaxLength truncates the normalized hostnames, 63 is the
+		// limit of a DNS label.
+		HostnameMaxLength int `validate:"min=1,max=63" default:"63" yaml:"hostnameMaxLength,omitempty"`
+		// SSH is the connection to ssh:// hosts.
+		SSH DockerSSHConfig `yaml:"ssh,omitempty"`
+	}
+
+	// DockerSSHConfig struct stores the SSH connection to a Docker host.
+	DockerSSHConfig struct {
+		// KeyFile is the private key, the SSH agent of SSH_AUTH_SOCK is
+		// used without it.
+		KeyFile    string `validate:"omitempty,file" yaml:"keyFile,omitempty"`
+		Passphrase string `yaml:"passphrase,omitempty"`
+		// KnownHostsFile verifies the key of the host, ~/.ssh/known_hosts
+		// by default.
+		KnownHostsFile string `validate:"omitempty,file" yaml:"knownHostsFile,omitempty"`
+		// Socket is the Docker socket in the host, /var/run/docker.sock by
+		// default.
+		Socket string `yaml:"socket,omitempty"`
+		// InsecureIgnoreHostKey accepts any key of the host.
+		InsecureIgnoreHostKey bool `yaml:"insecureIgnoreHostKey,omitempty"`
+	}
+
+	// NgrokProxyProviderConfig struct stores a proxy provider that exposes
+	// the ports of the proxies with ngrok tunnels, started with the API of
+	// an ngrok agent.
+	NgrokProxyProviderConfig struct {
+		// AgentURL is the address of the API of the ngrok agent
+		AgentURL string `validate:"url" default:"http://127.0.0.1:4040" yaml:"agentURL"`
+		// ListenAddress is the address of the ports the agent forwards to
+		ListenAddress string `validate:"ip" default:"127.0.0.1" yaml:"listenAddress"`
+		// ForwardHost is the host the agent connects to, the listen address
+		// without it
+		ForwardHost string `yaml:"forwardHost,omitempty"`
+		// Domain is the reserved domain of the http tunnels, a "*" is
+		// replaced by the hostname of the proxy, ngrok assigns a random
+		// domain without it
+		Domain string `yaml:"domain,omitempty"`
+		// OAuth protects the http tunnels with an ngrok OAuth provider
+		OAuth NgrokOAuthConfig `yaml:"oauth,omitempty"`
+	}
+
+	// NgrokOAuthConfig struct stores the OAuth options of ngrok http
+	// tunnels.
+	NgrokOAuthConfig struct {
+		// Provider is the ngrok OAuth provider, like google or github,
+		// tunnels aren't protected without it
+		Provider     string   `yaml:"provider,omitempty"`
+		Scopes       []string `yaml:"scopes,omitempty"`
+		AllowEmails  []string `yaml:"allowEmails,omitempty"`
+		AllowDomains []string `yaml:"allowDomains,omitempty"`
 	}
 
 	// TailscaleProxyProviderConfig struct stores Tailscale ProxyProvider configuration
@@ -73,29 +442,199 @@ type (
 		ClientSecret string `default:"" validate:"omitempty" yaml:"clientSecret,omitempty"`
 		Tags         string `default:"" validate:"omitempty" yaml:"tags,omitempty"`
 		ControlURL   string `default:"https://controlplane.tailscale.com" validate:"uri" yaml:"controlUrl"`
+		// GroupsRefreshInterval is the interval to refresh the tailnet ACL groups, requires OAuth
+		GroupsRefreshInterval time.Duration `default:"5m" validate:"gt=0" yaml:"groupsRefreshInterval"`
+		// SharedNode is the hostname of a single node hosting all the proxies
+		// of the provider, empty for a node per proxy
+		SharedNode string `validate:"omitempty,hostname_rfc1123" yaml:"sharedNode,omitempty"`
+		// Headscale enables the Headscale API, with controlUrl pointing at Headscale
+		Headscale HeadscaleConfig `yaml:"headscale,omitempty"`
+		// AuthKeyExpiry is the expiry of the auth keys created with OAuth
+		AuthKeyExpiry time.Duration `default:"24h" validate:"min=2h,max=2160h" yaml:"authKeyExpiry"`
+		// CleanupDevices deletes the device of a removed proxy from the
+		// tailnet, requires OAuth or Headscale
+		CleanupDevices bool `yaml:"cleanupDevices,omitempty"`
+		// StateRetention deletes the state directories of the nodes
+		// unused for longer, like the ones of removed proxies, 0 keeps them
+		StateRetention time.Duration `validate:"omitempty,min=1h" yaml:"stateRetention,omitempty"`
+		// EphemeralMemoryState keeps the state of the ephemeral nodes in
+		// memory instead of the data directory
+		EphemeralMemoryState bool `yaml:"ephemeralMemoryState,omitempty"`
+	}
+
+	// SSHTunnelProxyProviderConfig struct stores a proxy provider that
+	// exposes the ports of the proxies with reverse SSH tunnels opened in a
+	// bastion host, for networks without Tailscale.
+	SSHTunnelProxyProviderConfig struct {
+		// Host is the bastion host, with the port if it isn't 22
+		Host string `validate:"required" yaml:"host"`
+		User string `validate:"required" yaml:"user"`
+		// KeyFile is the private key, the SSH agent of SSH_AUTH_SOCK is
+		// used without it.
+		KeyFile    string `validate:"omitempty,file" yaml:"keyFile,omitempty"`
+		Passphrase string `yaml:"passphrase,omitempty"`
+		// KnownHostsFile verifies the key of the bastion, ~/.ssh/known_hosts
+		// by default.
+		KnownHostsFile string `validate:"omitempty,file" yaml:"knownHostsFile,omitempty"`
+		// BindAddress is the address of the tunnels in the bastion, its
+		// loopback address by default. Other addresses require GatewayPorts
+		// in the sshd of the bastion.
+		BindAddress string `validate:"omitempty,ip|hostname" yaml:"bindAddress,omitempty"`
+		// PublicHost is the host of the URLs of the proxies, the bastion
+		// host by default
+		PublicHost string `validate:"omitempty,ip|hostname" yaml:"publicHost,omitempty"`
+		// MinPort and MaxPort are the range of the ports of the tunnels in
+		// the bastion
+		MinPort int `validate:"required,min=1,max=65535" yaml:"minPort"`
+		MaxPort int `validate:"required,gtefield=MinPort,max=65535" yaml:"maxPort"`
+		// KeepAlive is the interval of the keepalives of the connection,
+		// broken connections are opened again
+		KeepAlive time.Duration `validate:"min=1s" default:"30s" yaml:"keepAlive"`
+		// InsecureIgnoreHostKey accepts any key of the bastion.
+		InsecureIgnoreHostKey bool `yaml:"insecureIgnoreHostKey,omitempty"`
+	}
+
+	// HeadscaleConfig struct stores the Headscale API configuration, used to
+	// create the pre-authorized keys of the nodes.
+	HeadscaleConfig struct {
+		APIKey     string `validate:"omitempty" yaml:"apiKey,omitempty"`
+		APIKeyFile string `validate:"omitempty" yaml:"apiKeyFile,omitempty"`
+		// User owns the nodes, its ID since Headscale 0.26 or its name before
+		User string `validate:"omitempty" yaml:"user,omitempty"`
+	}
+
+	// HostScanTargetProviderConfig struct stores a host listening ports scanner configuration.
+	// Discovered services are proposed in the dashboard and, when approved, written to a list.
+	HostScanTargetProviderConfig struct {
+		Ports          map[uint16]string `validate:"required,min=1" yaml:"ports"`
+		List           string            `validate:"required" yaml:"list"`
+		TargetHostname string            `validate:"ip|hostname" default:"127.0.0.1" yaml:"targetHostname"`
+		ProcDir        string            `validate:"dir" default:"/proc" yaml:"procDir"`
+		Interval       time.Duration     `validate:"min=1s" default:"1m" yaml:"interval"`
+	}
+
+	// ReplayTargetProviderConfig struct stores a debug target provider that
+	// replays recorded Docker events received in a unix socket.
+	ReplayTargetProviderConfig struct {
+		Socket               string `validate:"required" default:"/data/replay.sock" yaml:"socket"`
+		TargetHostname       string `validate:"ip|hostname" default:"172.31.0.1" yaml:"targetHostname"`
+		DefaultProxyProvider string `validate:"omitempty" yaml:"defaultProxyProvider,omitempty"`
+	}
+
+	// OIDCConfig struct stores an OpenID Connect authentication provider configuration.
+	OIDCConfig struct {
+		Issuer           string        `validate:"required,url" yaml:"issuer"`
+		ClientID         string        `validate:"required" yaml:"clientId"`
+		ClientSecret     string        `default:"" validate:"omitempty" yaml:"clientSecret,omitempty"`
+		ClientSecretFile string        `default:"" validate:"omitempty" yaml:"clientSecretFile,omitempty"`
+		GroupsClaim      string        `default:"groups" validate:"required" yaml:"groupsClaim"`
+		Scopes           []string      `yaml:"scopes,omitempty"`
+		AllowedGroups    []string      `yaml:"allowedGroups,omitempty"`
+		SessionDuration  time.Duration `default:"24h" validate:"min=1m" yaml:"sessionDuration"`
+		// ExternalURL is the URL the users open, when it isn't the one of the
+		// port, like behind a reverse proxy. The callback is in its host.
+		ExternalURL string `validate:"omitempty,url" yaml:"externalURL,omitempty"`
+		// TrustedProxies are the reverse proxies whose X-Forwarded-Proto
+		// header is the scheme the users open.
+		TrustedProxies []string `validate:"dive,cidr" yaml:"trustedProxies,omitempty"`
 	}
 
 	// ListTargetProviderConfig struct stores a proxy list target provider configuration.
+	// Filename is a file, a directory of yaml files or a glob. URL is a
+	// remote list, an http(s) URL or an s3://bucket/key object, polled
+	// every PollInterval.
 	ListTargetProviderConfig struct {
-		Filename              string `validate:"required,file" yaml:"filename"`
-		DefaultProxyProvider  string `validate:"omitempty" yaml:"defaultProxyProvider,omitempty"`
-		DefaultProxyAccessLog bool   `default:"true" validate:"boolean" yaml:"defaultProxyAccessLog"`
+		S3                    ListS3Config  `yaml:"s3,omitempty"`
+		Filename              string        `validate:"required_without=URL,excluded_with=URL" yaml:"filename,omitempty"`
+		URL                   string        `validate:"omitempty,url" yaml:"url,omitempty"`
+		Token                 string        `validate:"omitempty" yaml:"token,omitempty"`
+		DefaultProxyProvider  string        `validate:"omitempty" yaml:"defaultProxyProvider,omitempty"`
+		PollInterval          time.Duration `validate:"gte=0" yaml:"pollInterval,omitempty"`
+		DefaultProxyAccessLog bool          `default:"true" validate:"boolean" yaml:"defaultProxyAccessLog"`
+	}
+
+	// ListS3Config struct is the S3 bucket of a remote list. Without
+	// accessKeyId, the credentials of the AWS environment are used.
+	ListS3Config struct {
+		Region          string `validate:"omitempty" yaml:"region,omitempty"`
+		Endpoint        string `validate:"omitempty,url" yaml:"endpoint,omitempty"`
+		AccessKeyID     string `validate:"omitempty" yaml:"accessKeyId,omitempty"`
+		SecretAccessKey string `validate:"omitempty" yaml:"secretAccessKey,omitempty"`
+		PathStyle       bool   `validate:"boolean" yaml:"pathStyle,omitempty"`
 	}
 )
 
+// AllDomains method returns the domains with a certificate, the domain name
+// followed by the other domains.
+func (c LetsEncryptConfig) AllDomains() []string {
+	domains := make([]string, 0, len(c.Domains)+1)
+	for _, d := range append([]string{c.DomainName}, c.Domains...) {
+		d = strings.ToLower(strings.TrimSuffix(d, "."))
+		if d != "" && !slices.Contains(domains, d) {
+			domains = append(domains, d)
+		}
+	}
+
+	return domains
+}
+
+// IsEnabled method returns true if the Workers KV namespace is configured.
+func (c *CloudflareKVConfig) IsEnabled() bool {
+	return c.AccountID != "" && c.NamespaceID != ""
+}
+
+// IsEnabled method returns true if the Worker endpoint is configured.
+func (c *WorkerConfig) IsEnabled() bool {
+	return c.URL != ""
+}
+
+// IsEnabled method returns true if lists are replicated to peers.
+func (c *SyncConfig) IsEnabled() bool {
+	return len(c.Lists) > 0 && len(c.Peers) > 0
+}
+
+// IsFile method returns true if the list is a local file, not a directory,
+// a glob or a URL.
+func (c *ListTargetProviderConfig) IsFile() bool {
+	if c.URL != "" || strings.ContainsAny(c.Filename, `*?[\`) {
+		return false
+	}
+
+	info, err := os.Stat(c.Filename)
+
+	return err != nil || !info.IsDir()
+}
+
+// DefaultConfigFile is the configuration file without the -config flag.
+const DefaultConfigFile = "/config/tsdproxy.yaml"
+
 // Config  is a global variable to store configuration.
 var Config *config
 
+// warnings are the problems found loading the configuration that don't stop
+// the server.
+var warnings []string
+
+// Warnings function returns the warnings of the configuration load, like
+// the upstream settings that couldn't be migrated.
+func Warnings() []string {
+	return warnings
+}
+
 // GetConfig loads, validates and returns configuration.
 func InitializeConfig() error {
-	Config = &config{}
-	Config.Tailscale.Providers = make(map[string]*TailscaleServerConfig)
-	Config.Docker = make(map[string]*DockerTargetProviderConfig)
-	Config.Lists = make(map[string]*ListTargetProviderConfig)
-
-	file := flag.String("config", "/config/tsdproxy.yaml", "loag configuration from file")
+	file := flag.String("config", DefaultConfigFile, "loag configuration from file")
 	flag.Parse()
 
+	Config = newConfig()
+
+	// configurations and data directories of upstream tsdproxy are
+	// migrated before they are loaded
+	report := new(migrationReport)
+	if err := migrateLegacyConfig(*file, report); err != nil {
+		return fmt.Errorf("migrating configuration: %w", err)
+	}
+
 	fileConfig := NewConfigFile(log.Logger, *file, Config)
 
 	println("loading configuration from:", *file)
@@ -116,21 +655,100 @@ func InitializeConfig() error {
 		}
 	}
 
+	if err := Config.complete(*file); err != nil {
+		return err
+	}
+
+	migrateLegacyDataDir(report)
+	report.save()
+	warnings = report.warnings()
+
+	return nil
+}
+
+// ReadConfig function loads and validates a configuration file like
+// InitializeConfig, without migrating or saving it. Without the file, the
+// configuration is generated from the defaults and the environment like on
+// the first start.
+func ReadConfig(file string) error {
+	Config = newConfig()
+
+	fileConfig := NewConfigFile(log.Logger, file, Config)
+	if err := fileConfig.Load(); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := defaults.Set(Config); err != nil {
+			return err
+		}
+		Config.generateDefaultProviders()
+	}
+
+	return Config.complete(file)
+}
+
+// newConfig function returns an empty configuration.
+func newConfig() *config {
+	c := &config{}
+	c.Tailscale.Providers = make(map[string]*TailscaleServerConfig)
+	c.SSHTunnels = make(map[string]*SSHTunnelProxyProviderConfig)
+	c.Ngrok = make(map[string]*NgrokProxyProviderConfig)
+	c.Docker = make(map[string]*DockerTargetProviderConfig)
+	c.Lists = make(map[string]*ListTargetProviderConfig)
+	c.OIDC = make(map[string]*OIDCConfig)
+	c.HostScan = make(map[string]*HostScanTargetProviderConfig)
+	c.Replay = make(map[string]*ReplayTargetProviderConfig)
+	c.Notifications = make(map[string]*NotificationConfig)
+
+	return c
+}
+
+// complete method sets the defaults and the environment variables of the
+// configuration loaded from file, reads the secret files and validates it.
+func (c *config) complete(file string) error {
 	// Load default values.
 	// Make sure to set default values after loading from file
 	// unless defaults of map type are not loaded.
-	if err := defaults.Set(Config); err != nil {
+	if err := defaults.Set(c); err != nil {
 		fmt.Printf("Error loading defaults: %v", err)
 	}
 
+	// environment variables override the file and the defaults
+	if err := c.applyEnv(); err != nil {
+		return err
+	}
+
+	if err := c.loadSecretFiles(); err != nil {
+		return err
+	}
+
+	// secret references, like vault:kv/tsdproxy#cf_token
+	if err := c.resolveSecrets(); err != nil {
+		return err
+	}
+
+	// validate config
+	return c.validate(file)
+}
+
+// loadSecretFiles method reads the secrets set with files.
+func (c *config) loadSecretFiles() error {
 	// load auth keys from files
-	for _, d := range Config.Tailscale.Providers {
+	for _, d := range c.Tailscale.Providers {
+		if d != nil && d.Headscale.APIKeyFile != "" {
+			key, err := c.getAuthKeyFromFile(d.Headscale.APIKeyFile)
+			if err != nil {
+				return err
+			}
+			d.Headscale.APIKey = strings.TrimSpace(key)
+		}
+
 		if d != nil && d.ClientSecret != "" && d.ClientID != "" {
 			continue
 		}
 
 		if d != nil && d.AuthKeyFile != "" {
-			authkey, err := Config.getAuthKeyFromFile(d.AuthKeyFile)
+			authkey, err := c.getAuthKeyFromFile(d.AuthKeyFile)
 			if err != nil {
 				return err
 			}
@@ -138,9 +756,81 @@ func InitializeConfig() error {
 		}
 	}
 
-	// validate config
-	if err := Config.validate(); err != nil {
-		return err
+	// load oidc client secrets from files
+	for _, o := range c.OIDC {
+		if o != nil && o.ClientSecretFile != "" {
+			secret, err := c.getAuthKeyFromFile(o.ClientSecretFile)
+			if err != nil {
+				return err
+			}
+			o.ClientSecret = strings.TrimSpace(secret)
+		}
+	}
+
+	// load cache purge token from file
+	if f := c.CachePurge.APITokenFile; f != "" {
+		token, err := c.getAuthKeyFromFile(f)
+		if err != nil {
+			return err
+		}
+		c.CachePurge.APIToken = strings.TrimSpace(token)
+	}
+
+	// load public dns token from file
+	if f := c.PublicDNS.APITokenFile; f != "" {
+		token, err := c.getAuthKeyFromFile(f)
+		if err != nil {
+			return err
+		}
+		c.PublicDNS.APIToken = strings.TrimSpace(token)
+	}
+
+	// load inventory tokens from files
+	if f := c.Inventory.CloudflareKV.APITokenFile; f != "" {
+		token, err := c.getAuthKeyFromFile(f)
+		if err != nil {
+			return err
+		}
+		c.Inventory.CloudflareKV.APIToken = strings.TrimSpace(token)
+	}
+	if f := c.Inventory.Worker.TokenFile; f != "" {
+		token, err := c.getAuthKeyFromFile(f)
+		if err != nil {
+			return err
+		}
+		c.Inventory.Worker.Token = strings.TrimSpace(token)
+	}
+
+	// load api keys from files
+	for _, k := range c.Dashboard.Auth.APIKeys {
+		if k != nil && k.KeyFile != "" {
+			key, err := c.getAuthKeyFromFile(k.KeyFile)
+			if err != nil {
+				return err
+			}
+			k.Key = strings.TrimSpace(key)
+		}
+	}
+
+	// load notification secrets from files
+	for _, n := range c.Notifications {
+		if n == nil {
+			continue
+		}
+		if n.TokenFile != "" {
+			token, err := c.getAuthKeyFromFile(n.TokenFile)
+			if err != nil {
+				return err
+			}
+			n.Token = strings.TrimSpace(token)
+		}
+		if n.Email.PasswordFile != "" {
+			password, err := c.getAuthKeyFromFile(n.Email.PasswordFile)
+			if err != nil {
+				return err
+			}
+			n.Email.Password = strings.TrimSpace(password)
+		}
 	}
 
 	return nil
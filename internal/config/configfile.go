@@ -45,7 +45,7 @@ func (f *ConfigFile) Load() error {
 
 	err = unmarshalStrict(data, f.data)
 	if err != nil {
-		return err
+		return decodeError(f.filename, err)
 	}
 
 	return nil
@@ -0,0 +1,272 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creasty/defaults"
+)
+
+const (
+	// envPrefix is the prefix of the environment variables of the fields,
+	// like TSDPROXY_HTTP_PORT for http.port.
+	envPrefix = "TSDPROXY"
+	// envFileSuffix reads the value of a variable from a file, like
+	// TSDPROXY_LETSENCRYPT_CLOUDFLAREAPITOKEN_FILE.
+	envFileSuffix = "_FILE"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envOverrides struct sets the fields of the configuration from the
+// environment variables, named by the upper case yaml path of the field.
+// Entries of maps, like the providers, are added when they are only in the
+// environment.
+type envOverrides struct {
+	vars map[string]string
+}
+
+// applyEnv method overrides the fields of the configuration with the
+// environment variables.
+func (c *config) applyEnv() error {
+	e := &envOverrides{vars: make(map[string]string)}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, envPrefix+"_") {
+			e.vars[k] = v
+		}
+	}
+	if len(e.vars) == 0 {
+		return nil
+	}
+
+	return e.apply(reflect.ValueOf(c).Elem(), envPrefix)
+}
+
+// apply method sets a value and its fields from the variables starting with
+// name.
+func (e *envOverrides) apply(v reflect.Value, name string) error {
+	switch {
+	case v.Type() == durationType:
+		return e.setScalar(v, name)
+
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return e.apply(v.Elem(), name)
+
+	case v.Kind() == reflect.Struct:
+		for i := range v.NumField() {
+			field := yamlName(v.Type().Field(i))
+			if field == "" || !v.Field(i).CanSet() {
+				continue
+			}
+			if err := e.apply(v.Field(i), name+"_"+strings.ToUpper(field)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case v.Kind() == reflect.Map:
+		return e.applyMap(v, name)
+
+	case v.Kind() == reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			return nil
+		}
+		return e.setScalar(v, name)
+	}
+
+	return e.setScalar(v, name)
+}
+
+// applyMap method sets the entries of a map. Maps of structs are matched
+// by the names of the fields of the struct, like
+// TSDPROXY_DOCKER_REMOTE_HOST for the host of the remote entry.
+func (e *envOverrides) applyMap(v reflect.Value, name string) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+
+	elemType := v.Type().Elem()
+	isStruct := elemType.Kind() == reflect.Pointer && elemType.Elem().Kind() == reflect.Struct
+
+	for k := range e.vars {
+		rest, ok := strings.CutPrefix(k, name+"_")
+		if !ok {
+			continue
+		}
+
+		key := rest
+		if isStruct {
+			if key = entryName(rest, elemType.Elem()); key == "" {
+				continue
+			}
+		} else {
+			key = strings.TrimSuffix(key, envFileSuffix)
+		}
+
+		if mapKey(v, key).IsValid() {
+			continue
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		entry := reflect.New(elemType).Elem()
+		if isStruct {
+			entry = reflect.New(elemType.Elem())
+			if err := defaults.Set(entry.Interface()); err != nil {
+				return err
+			}
+		}
+		v.SetMapIndex(reflect.ValueOf(strings.ToLower(key)).Convert(v.Type().Key()), entry)
+	}
+
+	for _, k := range v.MapKeys() {
+		entryVar := name + "_" + strings.ToUpper(k.String())
+
+		if isStruct {
+			if err := e.apply(v.MapIndex(k), entryVar); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// map values aren't addressable, they are set on a copy
+		entry := reflect.New(elemType).Elem()
+		entry.Set(v.MapIndex(k))
+		if err := e.apply(entry, entryVar); err != nil {
+			return err
+		}
+		v.SetMapIndex(k, entry)
+	}
+
+	return nil
+}
+
+// setScalar method sets a value from its variable, or from the file of its
+// _FILE variable.
+func (e *envOverrides) setScalar(v reflect.Value, name string) error {
+	value, ok := e.vars[name]
+	if file, isFile := e.vars[name+envFileSuffix]; isFile && !ok {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", name+envFileSuffix, err)
+		}
+		value, ok = strings.TrimSpace(string(data)), true
+	}
+	if !ok {
+		return nil
+	}
+
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("environment variable %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// setValue function sets a value from its text, lists are separated by
+// commas.
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Slice:
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(elem, item); err != nil {
+				return err
+			}
+			list = reflect.Append(list, elem)
+		}
+		v.Set(list)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// entryName function returns the name of the map entry of a variable, the
+// part before the first field of the struct, like REMOTE in
+// REMOTE_HEADSCALE_APIKEY. Empty if no field matches.
+func entryName(rest string, t reflect.Type) string {
+	name := ""
+	for i := range t.NumField() {
+		field := yamlName(t.Field(i))
+		if field == "" {
+			continue
+		}
+		field = "_" + strings.ToUpper(field)
+
+		for idx := strings.Index(rest, field); idx > 0; {
+			end := idx + len(field)
+			if end == len(rest) || rest[end] == '_' {
+				if name == "" || idx < len(name) {
+					name = rest[:idx]
+				}
+				break
+			}
+			next := strings.Index(rest[end:], field)
+			if next < 0 {
+				break
+			}
+			idx = end + next
+		}
+	}
+
+	return name
+}
+
+// mapKey function returns the value of the key of a map, matched without
+// case since the variables are upper case. Invalid if not found.
+func mapKey(v reflect.Value, key string) reflect.Value {
+	for _, k := range v.MapKeys() {
+		if strings.EqualFold(k.String(), key) {
+			return v.MapIndex(k)
+		}
+	}
+
+	return reflect.Value{}
+}
@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// migrationReportFile is the file in the data directory with the report
+	// of the last migration.
+	migrationReportFile = "migration-report.yaml"
+	// migrationBackupDir is the directory in the data directory with the
+	// backups of the migrated node directories.
+	migrationBackupDir = "migration-backup"
+	// tailscaleStateFile is the state file of a tsnet node directory.
+	tailscaleStateFile = "tailscaled.state"
+)
+
+type (
+	// migrationReport struct is the report of the migration of the
+	// configuration and data directory of upstream tsdproxy.
+	migrationReport struct {
+		Time      time.Time `yaml:"time"`
+		Backup    string    `yaml:"backup,omitempty"`
+		Migrated  []string  `yaml:"migrated,omitempty"`
+		Skipped   []string  `yaml:"skipped,omitempty"`
+		Errors    []string  `yaml:"errors,omitempty"`
+		hasChange bool
+	}
+)
+
+// legacyConfigKeys are the keys of the upstream configuration renamed in
+// this configuration.
+var legacyConfigKeys = map[string]string{
+	"files": "lists",
+}
+
+// migrateLegacyConfig function renames the upstream keys of the
+// configuration file, the original file is kept with the .bak extension.
+func migrateLegacyConfig(file string, report *migrationReport) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		// invalid files are reported by the configuration load
+		return nil //nolint:nilerr
+	}
+
+	root := doc.Content[0]
+	changed := false
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		newKey, ok := legacyConfigKeys[key.Value]
+		if !ok {
+			continue
+		}
+		if hasKey(root, newKey) {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("config key %q, %q already exists", key.Value, newKey))
+			continue
+		}
+		report.Migrated = append(report.Migrated, fmt.Sprintf("config key %q renamed to %q", key.Value, newKey))
+		key.Value = newKey
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2) //nolint:mnd
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+
+	backup := file + ".bak"
+	if err := os.WriteFile(backup, data, consts.PermOwnerRead+consts.PermOwnerWrite); err != nil {
+		return fmt.Errorf("backup of %s: %w", file, err)
+	}
+	report.Migrated = append(report.Migrated, "config file backup in "+backup)
+	report.hasChange = true
+
+	return os.WriteFile(file, out.Bytes(), consts.PermAllRead+consts.PermOwnerWrite)
+}
+
+// migrateLegacyDataDir function moves the node directories of upstream
+// tsdproxy 0.x, stored in the data directory without the provider, to the
+// directory of the default proxy provider. The nodes keep their state, so
+// they don't need to log in again. The directories are copied to the backup
+// directory before they are moved.
+func migrateLegacyDataDir(report *migrationReport) {
+	dataDir := Config.Tailscale.DataDir
+	provider := Config.DefaultProxyProvider
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return
+	}
+
+	backupDir := filepath.Join(dataDir, migrationBackupDir, time.Now().UTC().Format("20060102T150405Z"))
+
+	for _, e := range entries {
+		name := e.Name()
+		src := filepath.Join(dataDir, name)
+
+		// provider directories hold node directories, not state files
+		if !e.IsDir() || name == migrationBackupDir || Config.Tailscale.Providers[name] != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(src, tailscaleStateFile)); err != nil {
+			continue
+		}
+
+		dst := filepath.Join(dataDir, provider, name)
+		if _, err := os.Stat(dst); err == nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("node %q, %s already exists", name, dst))
+			continue
+		}
+
+		if err := copyDir(src, filepath.Join(backupDir, name)); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("node %q backup: %v", name, err))
+			continue
+		}
+		report.Backup = backupDir
+		report.hasChange = true
+
+		if err := os.MkdirAll(filepath.Dir(dst), consts.PermOwnerAll); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("node %q: %v", name, err))
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("node %q: %v", name, err))
+			continue
+		}
+
+		report.Migrated = append(report.Migrated, fmt.Sprintf("node %q moved to %s", name, dst))
+	}
+}
+
+// save method logs the migration and saves its report in the data
+// directory, nothing is saved if nothing was migrated.
+func (r *migrationReport) save() {
+	for _, m := range r.Migrated {
+		log.Info().Str("module", "migration").Msg(m)
+	}
+	for _, m := range r.Skipped {
+		log.Warn().Str("module", "migration").Msg("Skipped " + m)
+	}
+	for _, m := range r.Errors {
+		log.Error().Str("module", "migration").Msg(m)
+	}
+
+	if !r.hasChange && len(r.Errors) == 0 {
+		return
+	}
+
+	r.Time = time.Now().UTC()
+	file := NewConfigFile(log.Logger, filepath.Join(Config.Tailscale.DataDir, migrationReportFile), r)
+	if err := file.Save(); err != nil {
+		log.Error().Err(err).Str("module", "migration").Msg("Error saving migration report")
+	}
+}
+
+// warnings method returns the skipped and failed migrations.
+func (r *migrationReport) warnings() []string {
+	w := make([]string, 0, len(r.Skipped)+len(r.Errors))
+	for _, m := range r.Skipped {
+		w = append(w, "migration skipped "+m)
+	}
+	for _, m := range r.Errors {
+		w = append(w, "migration failed: "+m)
+	}
+
+	return w
+}
+
+// hasKey function returns true if the mapping node has the key.
+func hasKey(node *yaml.Node, key string) bool {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyDir function copies the regular files of a directory tree.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, consts.PermOwnerAll)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, consts.PermOwnerRead+consts.PermOwnerWrite)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
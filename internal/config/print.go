@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"strings"
+
+	"github.com/creasty/defaults"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the secrets in the printed configuration.
+const redacted = "<redacted>"
+
+// starterHeader is the head comment of the starter configuration.
+const starterHeader = `TSDProxy configuration, generated with "tsdproxyd config init".
+See the server configuration in the documentation for all the options.`
+
+// starterComments are the comments of the starter configuration by field
+// path, * matches any name of a map.
+var starterComments = map[string]string{
+	"defaultProxyProvider":                "proxy provider of the proxies without one",
+	"docker":                              "Docker target providers by name",
+	"docker.*.host":                       "Docker socket or daemon address, ssh://user@host for SSH",
+	"docker.*.targetHostname":             "hostname or IP of the Docker host, reachable from tsdproxy",
+	"docker.*.tryDockerInternalNetwork":   "try the container IP before the published port",
+	"lists":                               "list target providers by name, see providers/lists",
+	"hostScan":                            "discover the listening ports of the host, see providers/hostscan",
+	"replay":                              "debug provider replaying recorded Docker events",
+	"tailscale.providers":                 "Tailscale proxy providers by name",
+	"tailscale.providers.*":               "set authKey, authKeyFile, or clientId and clientSecret",
+	"tailscale.providers.*.controlUrl":    "Tailscale or Headscale control server",
+	"tailscale.providers.*.authKeyExpiry": "expiry of the auth keys created with OAuth",
+	"tailscale.dataDir":                   "state of the Tailscale nodes",
+	"oidc":                                "OpenID Connect providers by name, see advanced/oidc",
+	"http":                                "dashboard and API server",
+	"http.listeners":                      "host addresses and Tailscale nodes instead of hostname and port",
+	"dashboard.auth":                      "see advanced/dashboard-auth",
+	"log.level":                           "debug, info, warn, error, fatal, panic or trace",
+	"log.modules":                         "levels of the modules, like docker: debug",
+	"letsEncrypt":                         "certificates of the dashboard server",
+	"certExpiryWarning":                   "warn about certificates expiring in less than this time",
+	"inventory":                           "publish the proxies to Cloudflare, see advanced/inventory",
+	"sync":                                "replicate lists between instances, see advanced/list-sync",
+	"cachePurge":                          "see advanced/cache-purge",
+	"audit":                               "log of the administrative actions, see advanced/audit-log",
+	"debug":                               "pprof and diagnostics endpoints for admins, see advanced/diagnostics",
+	"publicDns":                           "see advanced/public-dns",
+	"history":                             "status history, shown as uptime in the dashboard",
+	"limits":                              "see advanced/rate-limits",
+	"limits.maxConnections":               "0 for unlimited",
+	"limits.maxRequests":                  "0 from the CPUs and memory of the host, -1 for unlimited",
+	"notifications":                       "see advanced/notifications",
+	"proxyAccessLog":                      "access log of the proxies without their own setting",
+	"proxyDrainTimeout":                   "wait for active requests when a proxy is stopped",
+	"shutdownTimeout":                     "maximum time of a graceful shutdown, set the stop timeout of the container above it",
+	"secrets":                             "backends of the secret references, see advanced/secrets",
+	"updates.channel":                     "stable or beta, beta includes pre-releases",
+}
+
+// secretKeys are the keys of the secrets, replaced when the configuration
+// is printed.
+var secretKeys = map[string]bool{
+	"authKey":            true,
+	"clientSecret":       true,
+	"apiKey":             true,
+	"apiToken":           true,
+	"cloudflareApiToken": true,
+	"token":              true,
+	"password":           true,
+	"passphrase":         true,
+	"secretAccessKey":    true,
+}
+
+// secretPaths are the secrets with keys used by other fields, by path.
+var secretPaths = []string{"dashboard.auth.apiKeys.*.key", "tracing.headers.*"}
+
+// Starter function returns a commented configuration with the defaults and
+// the providers of the environment, like the one generated on the first
+// start.
+func Starter() ([]byte, error) {
+	c := newConfig()
+	if err := defaults.Set(c); err != nil {
+		return nil, err
+	}
+	c.generateDefaultProviders()
+	if err := defaults.Set(c); err != nil {
+		return nil, err
+	}
+
+	var node yaml.Node
+	if err := node.Encode(c); err != nil {
+		return nil, err
+	}
+	node.HeadComment = starterHeader
+	commentNode(&node, nil)
+
+	return yaml.Marshal(&node)
+}
+
+// Effective function returns the loaded configuration, with the defaults
+// and the secrets read from files, and the secrets redacted.
+func Effective() ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(Config); err != nil {
+		return nil, err
+	}
+	redactNode(&node, nil)
+
+	return yaml.Marshal(&node)
+}
+
+// commentNode function adds the starterComments to the keys of a mapping
+// node, above the sections and after the values.
+func commentNode(node *yaml.Node, path []string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		keyPath := append(path[:len(path):len(path)], key.Value)
+
+		for pattern, comment := range starterComments {
+			if matchPath(strings.Split(pattern, "."), keyPath) {
+				if value.Kind == yaml.ScalarNode {
+					key.LineComment = comment
+				} else {
+					key.HeadComment = comment
+				}
+				break
+			}
+		}
+		commentNode(value, keyPath)
+	}
+}
+
+// isSecret function returns true if the field of the path is a secret.
+func isSecret(path []string) bool {
+	if len(path) > 0 && secretKeys[path[len(path)-1]] {
+		return true
+	}
+	for _, pattern := range secretPaths {
+		if matchPath(strings.Split(pattern, "."), path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPath function returns true if the path matches the pattern.
+func matchPath(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// redactNode function replaces the values of the secrets set in a node and
+// its children.
+func redactNode(node *yaml.Node, path []string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, n := range node.Content {
+			redactNode(n, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			redactNode(node.Content[i+1], append(path[:len(path):len(path)], node.Content[i].Value))
+		}
+	case yaml.ScalarNode:
+		if node.Value != "" && isSecret(path) {
+			node.Value = redacted
+			node.Style = 0
+			node.Tag = "!!str"
+		}
+	}
+}
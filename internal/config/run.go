@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"github.com/creasty/defaults"
+)
+
+// RunConfig struct is the Tailscale provider of the run command, the
+// environment variables are used for the empty fields.
+type RunConfig struct {
+	AuthKey    string
+	ControlURL string
+	DataDir    string
+}
+
+// InitializeRunConfig function sets the configuration of the run command,
+// without configuration file: the defaults and the environment, with the
+// default Tailscale provider and without target providers.
+func InitializeRunConfig(run RunConfig) error {
+	Config = newConfig()
+
+	if err := defaults.Set(Config); err != nil {
+		return err
+	}
+	Config.generateTailscaleConfig()
+
+	ts := Config.Tailscale.Providers[TailscaleDefaultProviderName]
+	if run.AuthKey != "" {
+		ts.AuthKey = run.AuthKey
+	}
+	if run.ControlURL != "" {
+		ts.ControlURL = run.ControlURL
+	}
+	if run.DataDir != "" {
+		Config.Tailscale.DataDir = run.DataDir
+	}
+	// Let's Encrypt isn't used by the run command
+	Config.LetsEncrypt.CacheDir = Config.Tailscale.DataDir
+
+	return Config.complete("")
+}
@@ -0,0 +1,293 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	// dockerSecretsDir is the directory of the Docker and Compose secrets.
+	dockerSecretsDir = "/run/secrets"
+
+	secretFile   = "file"
+	secretDocker = "secret"
+	secretVault  = "vault"
+)
+
+var errSecretNotFound = errors.New("secret not found")
+
+// secretResolver struct resolves the secret references of the
+// configuration, like file:/run/secrets/tskey, secret:tskey or
+// vault:kv/tsdproxy#cf_token. The Vault paths are read once.
+type secretResolver struct {
+	vault      VaultConfig
+	client     *http.Client
+	vaultCache map[string]map[string]any
+}
+
+// resolveSecrets method replaces the secret references in the secrets of
+// the configuration with their values.
+func (c *config) resolveSecrets() error {
+	r := &secretResolver{
+		vault:      c.Secrets.Vault,
+		vaultCache: make(map[string]map[string]any),
+	}
+
+	if err := r.setupVault(); err != nil {
+		return err
+	}
+
+	return r.resolve(reflect.ValueOf(c).Elem(), nil)
+}
+
+// setupVault method completes the Vault server with the environment of the
+// Vault CLI and reads the token file.
+func (r *secretResolver) setupVault() error {
+	if r.vault.Address == "" {
+		r.vault.Address = os.Getenv("VAULT_ADDR")
+	}
+	if r.vault.Namespace == "" {
+		r.vault.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	// the token can't be in Vault, only in files
+	if ref, ok := parseSecretRef(r.vault.Token); ok && ref.scheme != secretVault {
+		token, err := r.lookup(ref)
+		if err != nil {
+			return fmt.Errorf("secrets.vault.token: %w", err)
+		}
+		r.vault.Token = token
+	}
+	if r.vault.TokenFile != "" {
+		token, err := os.ReadFile(r.vault.TokenFile)
+		if err != nil {
+			return fmt.Errorf("secrets.vault.tokenFile: %w", err)
+		}
+		r.vault.Token = strings.TrimSpace(string(token))
+	}
+	if r.vault.Token == "" {
+		r.vault.Token = os.Getenv("VAULT_TOKEN")
+	}
+
+	r.client = &http.Client{Timeout: r.vault.Timeout}
+
+	return nil
+}
+
+// resolve method resolves the secrets of a value and its fields, the path
+// is used to find the secrets like in the printed configuration.
+func (r *secretResolver) resolve(v reflect.Value, path []string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return r.resolve(v.Elem(), path)
+
+	case reflect.Struct:
+		for i := range v.NumField() {
+			field := yamlName(v.Type().Field(i))
+			if field == "" || !v.Field(i).CanSet() {
+				continue
+			}
+			if err := r.resolve(v.Field(i), append(path[:len(path):len(path)], field)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, k := range v.MapKeys() {
+			entryPath := append(path[:len(path):len(path)], k.String())
+
+			if v.Type().Elem().Kind() == reflect.Pointer {
+				if err := r.resolve(v.MapIndex(k), entryPath); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// map values aren't addressable, they are set on a copy
+			entry := reflect.New(v.Type().Elem()).Elem()
+			entry.Set(v.MapIndex(k))
+			if err := r.resolve(entry, entryPath); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, entry)
+		}
+
+	case reflect.String:
+		if !isSecret(path) {
+			return nil
+		}
+		ref, ok := parseSecretRef(v.String())
+		if !ok {
+			return nil
+		}
+		value, err := r.lookup(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+		}
+		v.SetString(value)
+	}
+
+	return nil
+}
+
+// secretRef struct is a reference to a secret, like vault:kv/tsdproxy#cf_token.
+type secretRef struct {
+	scheme string
+	path   string
+	key    string
+}
+
+// parseSecretRef function parses a secret reference, false if the value is
+// not a reference.
+func parseSecretRef(s string) (secretRef, bool) {
+	scheme, rest, ok := strings.Cut(s, ":")
+	if !ok || rest == "" {
+		return secretRef{}, false
+	}
+
+	switch scheme {
+	case secretFile, secretDocker:
+		return secretRef{scheme: scheme, path: rest}, true
+	case secretVault:
+		path, key, _ := strings.Cut(rest, "#")
+		return secretRef{scheme: scheme, path: strings.Trim(path, "/"), key: key}, true
+	}
+
+	return secretRef{}, false
+}
+
+// lookup method returns the value of a secret reference.
+func (r *secretResolver) lookup(ref secretRef) (string, error) {
+	switch ref.scheme {
+	case secretFile:
+		return readSecretFile(ref.path)
+	case secretDocker:
+		if strings.ContainsRune(ref.path, '/') {
+			return "", fmt.Errorf("invalid Docker secret name %q", ref.path)
+		}
+		return readSecretFile(filepath.Join(dockerSecretsDir, ref.path))
+	case secretVault:
+		return r.lookupVault(ref)
+	}
+
+	return "", fmt.Errorf("unknown secret backend %q", ref.scheme)
+}
+
+// readSecretFile function reads a secret file, without the spaces and new
+// lines around the value.
+func readSecretFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// lookupVault method reads a key of a secret of the Vault KV secrets engine.
+func (r *secretResolver) lookupVault(ref secretRef) (string, error) {
+	if ref.key == "" {
+		return "", fmt.Errorf("vault:%s: missing #key", ref.path)
+	}
+	if r.vault.Address == "" || r.vault.Token == "" {
+		return "", errors.New("vault references need secrets.vault address and token")
+	}
+
+	data, ok := r.vaultCache[ref.path]
+	if !ok {
+		var err error
+		if data, err = r.readVault(ref.path); err != nil {
+			return "", fmt.Errorf("vault:%s: %w", ref.path, err)
+		}
+		r.vaultCache[ref.path] = data
+	}
+
+	value, ok := data[ref.key]
+	if !ok {
+		return "", fmt.Errorf("vault:%s#%s: %w", ref.path, ref.key, errSecretNotFound)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// readVault method reads the data of a secret of the KV secrets engine, the
+// first part of the path is the mount.
+func (r *secretResolver) readVault(path string) (map[string]any, error) {
+	apiPath := path
+	if r.vault.KVVersion == 2 {
+		mount, secret, ok := strings.Cut(path, "/")
+		if !ok {
+			return nil, errors.New("the path needs the mount and the secret")
+		}
+		apiPath = mount + "/data/" + secret
+	}
+
+	endpoint, err := url.JoinPath(r.vault.Address, "v1", apiPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.vault.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", r.vault.Token)
+	if r.vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.vault.Namespace)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errSecretNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("vault request failed: %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	data := body.Data
+	if r.vault.KVVersion == 2 {
+		// KV version 2 has the metadata of the secret next to the data
+		nested, _ := data["data"].(map[string]any)
+		data = nested
+	}
+	if data == nil {
+		return nil, errSecretNotFound
+	}
+
+	return data, nil
+}
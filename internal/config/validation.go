@@ -0,0 +1,286 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// yamlLineError matches the errors of yaml.v3 with their line, like
+	// "line 3: field foo not found in type config.config".
+	yamlLineError = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	// namespaceKey matches the map keys and slice indexes of the
+	// validator namespaces, like providers[default].
+	namespaceKey = regexp.MustCompile(`\[([^\]]*)\]`)
+)
+
+type (
+	// FieldError struct is an error of a configuration field, with the
+	// position of the field in the file when it's known.
+	FieldError struct {
+		Err    error
+		File   string
+		Field  string
+		Line   int
+		Column int
+	}
+
+	// ValidationError struct is the list of errors found in a configuration
+	// file, so they can be fixed at once.
+	ValidationError struct {
+		Errors []*FieldError
+	}
+
+	// validation struct collects the errors of a configuration file,
+	// finding the fields in the yaml nodes of the file.
+	validation struct {
+		root *yaml.Node
+		file string
+		errs []*FieldError
+	}
+)
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+
+	if e.File != "" {
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d", e.Line)
+		}
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+		b.WriteString(": ")
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field + ": ")
+	}
+	b.WriteString(e.Err.Error())
+
+	return b.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return "invalid configuration: " + e.Errors[0].Error()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration, %d errors:", len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n  " + err.Error())
+	}
+
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// newValidation function returns the validation of a configuration file.
+// Without the file the errors have no position.
+func newValidation(file string) *validation {
+	v := &validation{file: file}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return v
+	}
+
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) == nil {
+		v.root = &root
+	}
+
+	return v
+}
+
+// add method adds the error of a field, with its path in the file like
+// docker.local.defaultProxyProvider.
+func (v *validation) add(field string, err error) {
+	line, column := v.position(field)
+
+	v.errs = append(v.errs, &FieldError{
+		File:   v.file,
+		Field:  field,
+		Line:   line,
+		Column: column,
+		Err:    err,
+	})
+}
+
+// addStruct method adds the errors of the validator.
+func (v *validation) addStruct(err error) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		v.add("", err)
+		return
+	}
+
+	for _, e := range validationErrors {
+		v.add(namespaceField(e.Namespace()), errors.New(validationMessage(e)))
+	}
+}
+
+// err method returns the collected errors, nil without errors.
+func (v *validation) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Errors: v.errs}
+}
+
+// position method returns the line and column of a field in the file. For
+// fields missing in the file, like required fields, it's the position of
+// their closest parent.
+func (v *validation) position(field string) (int, int) {
+	if v.root == nil || len(v.root.Content) == 0 || field == "" {
+		return 0, 0
+	}
+
+	// fields of sections missing in the file have no position
+	node := v.root.Content[0]
+	line, column := 0, 0
+
+	for _, key := range strings.Split(field, ".") {
+		next := childNode(node, key)
+		if next == nil {
+			// fields without yaml name, like inline structs, aren't in
+			// the file
+			continue
+		}
+		node = next
+		line, column = node.Line, node.Column
+	}
+
+	return line, column
+}
+
+// childNode function returns the value of a key in a mapping node or of an
+// index in a sequence node.
+func childNode(node *yaml.Node, key string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				// errors of values are reported on their key, values of
+				// maps and sequences start in the next lines
+				if node.Content[i+1].Kind == yaml.ScalarNode {
+					return node.Content[i+1]
+				}
+				child := *node.Content[i+1]
+				child.Line, child.Column = node.Content[i].Line, node.Content[i].Column
+				return &child
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i]
+		}
+	}
+
+	return nil
+}
+
+// namespaceField function returns the path of a validator namespace, like
+// tailscale.providers.default.authKey for
+// config.tailscale.providers[default].authKey.
+func namespaceField(namespace string) string {
+	_, field, _ := strings.Cut(namespace, ".")
+
+	return namespaceKey.ReplaceAllString(field, ".$1")
+}
+
+// validationMessage function returns the message of a validator error.
+func validationMessage(e validator.FieldError) string {
+	var msg string
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		msg = "must be one of " + strings.ReplaceAll(e.Param(), " ", ", ")
+	case "file":
+		msg = "file not found"
+	case "dir":
+		msg = "directory not found"
+	case "url", "uri", "http_url":
+		msg = "must be a URL"
+	case "email":
+		msg = "must be an email address"
+	case "min", "gte":
+		msg = "must be at least " + e.Param()
+	case "max", "lte":
+		msg = "must be at most " + e.Param()
+	case "gt":
+		msg = "must be greater than " + e.Param()
+	case "ip|hostname", "hostname":
+		msg = "must be an IP address or a hostname"
+	default:
+		msg = "failed the " + e.Tag() + " validation"
+	}
+
+	if v := fmt.Sprint(e.Value()); v != "" && e.Kind() != reflect.Map && e.Kind() != reflect.Slice && e.Kind() != reflect.Struct {
+		msg += fmt.Sprintf(", got %q", v)
+	}
+
+	return msg
+}
+
+// yamlName function returns the yaml name of a struct field, used as the
+// field names of the validator errors.
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+// decodeError function returns the errors of yaml.v3 with the file and their
+// line, like the unknown fields of a strict decoding.
+func decodeError(file string, err error) error {
+	var messages []string
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	v := &validation{file: file}
+	for _, msg := range messages {
+		fe := &FieldError{File: file, Err: errors.New(strings.TrimPrefix(msg, "yaml: "))}
+		if m := yamlLineError.FindStringSubmatch(msg); m != nil {
+			fe.Line, _ = strconv.Atoi(m[1])
+			fe.Err = errors.New(m[2])
+		}
+		v.errs = append(v.errs, fe)
+	}
+
+	return v.err()
+}
@@ -6,7 +6,9 @@ package config
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -19,63 +21,202 @@ func (e *DefaultProxyProviderNotFoundError) Error() string {
 	return "Default proxy provider " + e.ProviderName + " not found"
 }
 
-var ErrNoDefaultProxyProvider = errors.New("no default proxy provider")
+type ListNotFoundError struct {
+	ListName string
+}
+
+func (e *ListNotFoundError) Error() string {
+	return "List " + e.ListName + " not found"
+}
+
+type OIDCNotFoundError struct {
+	OIDCName string
+}
 
-// validate method  Validate configurations.
-func (c *config) validate() error {
+func (e *OIDCNotFoundError) Error() string {
+	return "OIDC " + e.OIDCName + " not found"
+}
+
+var (
+	ErrNoDefaultProxyProvider   = errors.New("no default proxy provider")
+	ErrMissingCloudflareKVToken = errors.New("inventory cloudflareKV requires apiToken or apiTokenFile")
+	ErrMissingSyncHostname      = errors.New("sync requires a hostname")
+	ErrMissingNotificationURL   = errors.New("notification requires a url")
+	ErrInvalidEmailNotification = errors.New("email notification requires host, from and to")
+	ErrReservedAccessLogFormat  = errors.New("access log format name is reserved")
+	ErrMissingAPIKey            = errors.New("api key requires key or keyFile")
+	ErrListNotFile              = errors.New("list must be a local file, not a directory, glob or URL")
+	ErrDuplicateProxyProvider   = errors.New("proxy provider name is already used by a tailscale provider")
+)
+
+// validate method validates the configuration loaded from file. All the
+// errors are reported at once, with their position in the file.
+func (c *config) validate(file string) error {
 	println("Validating configuration...")
 	validate := validator.New()
+	validate.RegisterTagNameFunc(yamlName)
+
+	v := newValidation(file)
 
 	if err := validate.Struct(Config); err != nil {
-		// validationErrors := err.(validator.ValidationErrors)
-		var validationErrors validator.ValidationErrors
-		if errors.As(err, &validationErrors) {
-			for _, e := range validationErrors {
-				fmt.Println(e)
-			}
-			return err
-		}
+		v.addStruct(err)
 	}
 
-	// TODO: add validation for each provider
-	// TODO: add default proxy provider to each proxy if not defined
-	//
-
 	// Set default Proxy Provider if not set.
 	//
 	if c.DefaultProxyProvider != "" {
 		if !c.hasProxyProvider(c.DefaultProxyProvider) {
-			return &DefaultProxyProviderNotFoundError{ProviderName: c.DefaultProxyProvider}
+			v.add("defaultProxyProvider", &DefaultProxyProviderNotFoundError{ProviderName: c.DefaultProxyProvider})
 		}
 	} else {
-		var temp string
-		var err error
-		if temp, err = c.getDefaultProxyProvider(); err != nil {
-			return err
+		temp, err := c.getDefaultProxyProvider()
+		if err != nil {
+			v.add("tailscale.providers", err)
 		}
 		c.DefaultProxyProvider = strings.ToLower(temp)
 	}
 
-	// add default proxy provider to docker providers
+	// add default proxy provider to the target providers
 	//
-	err := c.addDefaultProxyProviderToDockerProviders()
-	if err != nil {
-		return err
+	c.addDefaultProxyProviderToDockerProviders(v)
+
+	// proxies select their proxy provider by name
+	for name := range c.SSHTunnels {
+		if _, ok := c.Tailscale.Providers[name]; ok {
+			v.add("sshTunnels."+name, ErrDuplicateProxyProvider)
+		}
+	}
+	for name := range c.Ngrok {
+		_, tailscale := c.Tailscale.Providers[name]
+		_, sshTunnel := c.SSHTunnels[name]
+		if tailscale || sshTunnel {
+			v.add("ngrok."+name, ErrDuplicateProxyProvider)
+		}
 	}
-	return nil
+
+	// host scanners write approved services to a list provider
+	for name, h := range c.HostScan {
+		if l, ok := c.Lists[h.List]; !ok {
+			v.add("hostScan."+name+".list", &ListNotFoundError{ListName: h.List})
+		} else if !l.IsFile() {
+			v.add("hostScan."+name+".list", ErrListNotFile)
+		}
+	}
+
+	c.validateSync(v)
+	c.validateAccessLogFormats(v)
+	c.validateHostnameTemplates(v)
+	c.validateNotifications(v)
+
+	if c.Dashboard.Auth.OIDC != "" {
+		if _, ok := c.OIDC[c.Dashboard.Auth.OIDC]; !ok {
+			v.add("dashboard.auth.oidc", &OIDCNotFoundError{OIDCName: c.Dashboard.Auth.OIDC})
+		}
+	}
+
+	for name, k := range c.Dashboard.Auth.APIKeys {
+		if k.Key == "" {
+			v.add("dashboard.auth.apiKeys."+name, ErrMissingAPIKey)
+		}
+	}
+
+	if c.Inventory.CloudflareKV.IsEnabled() && c.Inventory.CloudflareKV.APIToken == "" {
+		v.add("inventory.cloudflareKV", ErrMissingCloudflareKVToken)
+	}
+
+	return v.err()
 }
 
-func (c *config) addDefaultProxyProviderToDockerProviders() error {
-	for _, p := range c.Docker {
+// validateSync method validates the lists and the proxy provider used by sync.
+func (c *config) validateSync(v *validation) {
+	if !c.Sync.IsEnabled() {
+		return
+	}
+
+	if c.Sync.Hostname == "" {
+		v.add("sync.hostname", ErrMissingSyncHostname)
+	}
+
+	for i, l := range c.Sync.Lists {
+		if list, ok := c.Lists[l]; !ok {
+			v.add("sync.lists."+strconv.Itoa(i), &ListNotFoundError{ListName: l})
+		} else if !list.IsFile() {
+			v.add("sync.lists."+strconv.Itoa(i), ErrListNotFile)
+		}
+	}
+
+	if c.Sync.ProxyProvider != "" && !c.hasProxyProvider(c.Sync.ProxyProvider) {
+		v.add("sync.proxyProvider", &DefaultProxyProviderNotFoundError{ProviderName: c.Sync.ProxyProvider})
+	}
+}
+
+// validateAccessLogFormats method validates the names and the syntax of the
+// access log templates. Fields are validated when the proxies start.
+func (c *config) validateAccessLogFormats(v *validation) {
+	for name, text := range c.AccessLogFormats {
+		switch name {
+		case "", "json", "clf", "combined":
+			v.add("accessLogFormats."+name, fmt.Errorf("%w: %q", ErrReservedAccessLogFormat, name))
+			continue
+		}
+
+		if _, err := template.New(name).Parse(text); err != nil {
+			v.add("accessLogFormats."+name, err)
+		}
+	}
+}
+
+// validateHostnameTemplates method validates the syntax of the hostname
+// templates of the Docker target providers.
+func (c *config) validateHostnameTemplates(v *validation) {
+	for name, d := range c.Docker {
+		if d.HostnameTemplate == "" {
+			continue
+		}
+
+		if _, err := template.New(name).Parse(d.HostnameTemplate); err != nil {
+			v.add("docker."+name+".hostnameTemplate", err)
+		}
+	}
+}
+
+// validateNotifications method validates the fields required by each
+// notification type.
+func (c *config) validateNotifications(v *validation) {
+	for name, n := range c.Notifications {
+		switch {
+		case n.Type == "email":
+			if n.Email.Host == "" || n.Email.From == "" || len(n.Email.To) == 0 {
+				v.add("notifications."+name+".email", ErrInvalidEmailNotification)
+			}
+		case n.URL == "":
+			v.add("notifications."+name, ErrMissingNotificationURL)
+		}
+	}
+}
+
+// addDefaultProxyProviderToDockerProviders method sets the default proxy
+// provider of the target providers without one, and validates the others.
+func (c *config) addDefaultProxyProviderToDockerProviders(v *validation) {
+	for name, p := range c.Docker {
 		if p.DefaultProxyProvider == "" {
 			p.DefaultProxyProvider = c.DefaultProxyProvider
-		} else {
-			if !c.hasProxyProvider(p.DefaultProxyProvider) {
-				return &DefaultProxyProviderNotFoundError{ProviderName: p.DefaultProxyProvider}
-			}
+		} else if !c.hasProxyProvider(p.DefaultProxyProvider) {
+			v.add("docker."+name+".defaultProxyProvider", &DefaultProxyProviderNotFoundError{ProviderName: p.DefaultProxyProvider})
+		}
+	}
+	for name, p := range c.Replay {
+		if p.DefaultProxyProvider == "" {
+			p.DefaultProxyProvider = c.DefaultProxyProvider
+		} else if !c.hasProxyProvider(p.DefaultProxyProvider) {
+			v.add("replay."+name+".defaultProxyProvider", &DefaultProxyProviderNotFoundError{ProviderName: p.DefaultProxyProvider})
+		}
+	}
+	for name, p := range c.Lists {
+		if p.DefaultProxyProvider != "" && !c.hasProxyProvider(p.DefaultProxyProvider) {
+			v.add("lists."+name+".defaultProxyProvider", &DefaultProxyProviderNotFoundError{ProviderName: p.DefaultProxyProvider})
 		}
 	}
-	return nil
 }
 
 func (c *config) getDefaultProxyProvider() (string, error) {
@@ -91,5 +232,15 @@ func (c *config) hasProxyProvider(name string) bool {
 			return true
 		}
 	}
+	for n := range c.SSHTunnels {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	for n := range c.Ngrok {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
 	return false
 }
@@ -5,8 +5,12 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"sync"
 
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/otel/codes"
@@ -21,6 +25,12 @@ type HTTPServer struct {
 	Log         zerolog.Logger
 	Mux         *http.ServeMux
 	middlewares []Middleware
+	// servers are the started servers, stopped by Shutdown
+	servers []*http.Server
+	// onShutdown are called when Shutdown starts, to end long requests
+	// like event streams
+	onShutdown []func()
+	mtx        sync.Mutex
 }
 
 // NewHTTPServer creates and returns a new App with an initialized ServeMux and middleware slice.
@@ -57,10 +67,21 @@ func (a *HTTPServer) Post(pattern string, handler http.Handler) {
 	a.Handle("POST "+pattern, handler)
 }
 
+// Put method add a PUT handler
+func (a *HTTPServer) Put(pattern string, handler http.Handler) {
+	a.Handle("PUT "+pattern, handler)
+}
+
+// Delete method add a DELETE handler
+func (a *HTTPServer) Delete(pattern string, handler http.Handler) {
+	a.Handle("DELETE "+pattern, handler)
+}
+
 // StartServer starts a custom http server.
 func (a *HTTPServer) StartServer(s *http.Server) error {
 	// set Logger the first middlewares
 	s.Handler = LoggerMiddleware(a.Log, a.Mux)
+	a.track(s)
 
 	if s.TLSConfig != nil {
 		// add logger middleware
@@ -70,6 +91,59 @@ func (a *HTTPServer) StartServer(s *http.Server) error {
 	return s.ListenAndServe()
 }
 
+// Serve method serves a custom http server on a listener, with TLS if the
+// server has a TLS configuration.
+func (a *HTTPServer) Serve(s *http.Server, l net.Listener) error {
+	s.Handler = LoggerMiddleware(a.Log, a.Mux)
+	a.track(s)
+
+	if s.TLSConfig != nil {
+		return s.ServeTLS(l, "", "")
+	}
+
+	return s.Serve(l)
+}
+
+// OnShutdown method registers a function called when Shutdown starts.
+func (a *HTTPServer) OnShutdown(f func()) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.onShutdown = append(a.onShutdown, f)
+}
+
+// Shutdown method stops the servers, waiting for the active requests
+// until the context is done. The remaining connections are closed.
+func (a *HTTPServer) Shutdown(ctx context.Context) error {
+	a.mtx.Lock()
+	servers := a.servers
+	hooks := a.onShutdown
+	a.mtx.Unlock()
+
+	for _, f := range hooks {
+		f()
+	}
+
+	var errs error
+	for _, s := range servers {
+		err := s.Shutdown(ctx)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			a.Log.Warn().Str("addr", s.Addr).Msg("Drain timeout, closing active connections")
+			err = s.Close()
+		}
+		errs = errors.Join(errs, err)
+	}
+
+	return errs
+}
+
+func (a *HTTPServer) track(s *http.Server) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.servers = append(a.servers, s)
+}
+
 func (a *HTTPServer) JSONResponse(w http.ResponseWriter, _ *http.Request, result interface{}) {
 	body, err := json.Marshal(result)
 	if err != nil {
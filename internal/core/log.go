@@ -29,27 +29,62 @@ func NewLog() zerolog.Logger {
 		logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
 	}
 
-	log.Logger = logger
 	logLevel, err := zerolog.ParseLevel(config.Config.Log.Level)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Could not parse log level")
 	}
 
+	modules := make(map[string]zerolog.Level, len(config.Config.Log.Modules))
+	for module, level := range config.Config.Log.Modules {
+		if modules[module], err = zerolog.ParseLevel(level); err != nil {
+			logger.Fatal().Err(err).Str("module", module).Msg("Could not parse log level")
+		}
+	}
+
+	// the levels of the modules are applied by the hook, they can be changed
+	// while running
+	logger = logger.Hook(levelHook{})
+	log.Logger = logger
+
 	if logLevel == zerolog.DebugLevel || logLevel == zerolog.TraceLevel {
 		logger = logger.With().Caller().Logger()
 	}
 
-	zerolog.SetGlobalLevel(logLevel)
-	logger.Info().Str("Log level", config.Config.Log.Level).Msg("Log Settings")
+	storeLevels(&logLevels{level: logLevel, modules: modules})
+	logger.Info().Str("Log level", config.Config.Log.Level).Interface("modules", config.Config.Log.Modules).Msg("Log Settings")
 
 	return logger
 }
 
-// LogRecord warps a http.ResponseWriter and records the status.
+// LogRecord warps a http.ResponseWriter and records the status and bytes written.
 type LogRecord struct {
 	err error
 	http.ResponseWriter
 	status int
+	bytes  int64
+}
+
+// NewLogRecord function returns a LogRecord with the default status.
+func NewLogRecord(w http.ResponseWriter) *LogRecord {
+	return &LogRecord{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+// Status method returns the response status code.
+func (r *LogRecord) Status() int {
+	return r.status
+}
+
+// Bytes method returns the number of bytes written in the response body.
+func (r *LogRecord) Bytes() int64 {
+	return r.bytes
+}
+
+// Err method returns the last error writing the response.
+func (r *LogRecord) Err() error {
+	return r.err
 }
 
 // WriteHeader overrides ResponseWriter.WriteHeader to keep track of the response code.
@@ -60,6 +95,7 @@ func (r *LogRecord) WriteHeader(status int) {
 
 func (r *LogRecord) Write(data []byte) (int, error) {
 	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
 	if err != nil {
 		r.err = err
 	}
@@ -82,10 +118,7 @@ func (r *LogRecord) Flush() {
 // LoggerMiddleware is a middleware function that logs incoming HTTP requests.
 func LoggerMiddleware(l zerolog.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		lw := &LogRecord{
-			ResponseWriter: w,
-			status:         http.StatusOK,
-		}
+		lw := NewLogRecord(w)
 
 		// Call the next handler in the chain
 		// lw := &loggingResponseWriter{ResponseWriter: w, status: http.StatusOK}
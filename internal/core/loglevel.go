@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// logLevels struct stores the log level of the server and the levels of
+// the modules that override it. It's replaced on changes, never modified.
+type logLevels struct {
+	modules map[string]zerolog.Level
+	level   zerolog.Level
+}
+
+// moduleKey is the context key of the module of a logger.
+type moduleKey struct{}
+
+var levels atomic.Pointer[logLevels]
+
+func init() {
+	levels.Store(&logLevels{level: zerolog.InfoLevel, modules: map[string]zerolog.Level{}})
+}
+
+// ModuleLogger function returns the logger of a module, its level can be
+// changed with log.modules and SetLogLevel.
+func ModuleLogger(l zerolog.Logger, module string) zerolog.Logger {
+	return l.With().
+		Str("module", module).
+		Ctx(context.WithValue(context.Background(), moduleKey{}, module)).
+		Logger()
+}
+
+// LogLevels function returns the log level of the server and the levels of
+// the modules.
+func LogLevels() (string, map[string]string) {
+	current := levels.Load()
+
+	modules := make(map[string]string, len(current.modules))
+	for module, level := range current.modules {
+		modules[module] = level.String()
+	}
+
+	return current.level.String(), modules
+}
+
+// SetLogLevel function changes the log level of a module, or of the server
+// when module is empty. An empty level removes the level of the module.
+func SetLogLevel(module, level string) error {
+	var lvl zerolog.Level
+	if level != "" || module == "" {
+		var err error
+		if lvl, err = zerolog.ParseLevel(level); err != nil || lvl == zerolog.NoLevel {
+			return fmt.Errorf("invalid log level %q", level)
+		}
+	}
+
+	current := levels.Load()
+	next := &logLevels{level: current.level, modules: maps.Clone(current.modules)}
+
+	switch {
+	case module == "":
+		next.level = lvl
+	case level == "":
+		delete(next.modules, module)
+	default:
+		next.modules[module] = lvl
+	}
+
+	storeLevels(next)
+
+	return nil
+}
+
+// storeLevels function saves the levels, the global level is the lowest so
+// the events of the modules with lower levels aren't dropped by zerolog.
+func storeLevels(l *logLevels) {
+	minLevel := l.level
+	for _, level := range l.modules {
+		minLevel = min(minLevel, level)
+	}
+
+	levels.Store(l)
+	zerolog.SetGlobalLevel(minLevel)
+}
+
+// levelHook struct drops the events below the level of their module, or of
+// the server for the loggers without module.
+type levelHook struct{}
+
+// Run method implements zerolog.Hook Run method.
+func (levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level == zerolog.NoLevel {
+		return
+	}
+
+	current := levels.Load()
+	threshold := current.level
+	if module, ok := e.GetCtx().Value(moduleKey{}).(string); ok {
+		if l, ok := current.modules[module]; ok {
+			threshold = l
+		}
+	}
+
+	if level < threshold {
+		e.Discard()
+	}
+}
@@ -8,12 +8,14 @@ import (
 	"net/http/pprof"
 )
 
-func PprofAddRoutes(http *HTTPServer) {
-	http.Get("/debug/pprof/", pprofIndex())
-	http.Get("/debug/pprof/cmdline", pprofCmdline())
-	http.Get("/debug/pprof/profile", pprofProfile())
-	http.Get("/debug/pprof/symbol", pprofSymbol())
-	http.Get("/debug/pprof/trace", pprofTrace())
+// PprofAddRoutes function adds the pprof routes, wrapped by mw to require
+// an authenticated admin.
+func PprofAddRoutes(http *HTTPServer, mw Middleware) {
+	http.Get("/debug/pprof/", mw(pprofIndex()))
+	http.Get("/debug/pprof/cmdline", mw(pprofCmdline()))
+	http.Get("/debug/pprof/profile", mw(pprofProfile()))
+	http.Get("/debug/pprof/symbol", mw(pprofSymbol()))
+	http.Get("/debug/pprof/trace", mw(pprofTrace()))
 }
 
 func pprofIndex() http.HandlerFunc {
@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"runtime/trace"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProfileCPU   = "cpu"
+	ProfileTrace = "trace"
+
+	// DefaultProfileDuration is the duration of the cpu and trace profiles
+	// without a duration.
+	DefaultProfileDuration = 30 * time.Second
+	// MaxProfileDuration is the maximum duration of the cpu and trace
+	// profiles.
+	MaxProfileDuration = 5 * time.Minute
+)
+
+var (
+	// ProfileKinds are the profiles captured by CaptureProfile. The cpu and
+	// trace profiles run for a duration, the others are a snapshot.
+	ProfileKinds = []string{ProfileCPU, ProfileTrace, "heap", "allocs", "goroutine", "block", "mutex", "threadcreate"}
+
+	ErrInvalidProfile = errors.New("invalid profile")
+	ErrProfileRunning = errors.New("a cpu or trace profile is already running")
+
+	// profileMtx allows a single cpu or trace profile, the runtime only runs
+	// one at a time.
+	profileMtx sync.Mutex
+)
+
+// ProfileFile struct is a captured profile in the profile directory.
+type ProfileFile struct {
+	Created time.Time `json:"created"`
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+}
+
+// CaptureProfile function writes a profile to a new file in dir. The cpu and
+// trace profiles run for d, or until the context is done.
+func CaptureProfile(ctx context.Context, dir, kind string, d time.Duration) (ProfileFile, error) {
+	if !slices.Contains(ProfileKinds, kind) {
+		return ProfileFile{}, fmt.Errorf("%w: %s", ErrInvalidProfile, kind)
+	}
+	if d <= 0 {
+		d = DefaultProfileDuration
+	}
+	d = min(d, MaxProfileDuration)
+
+	if kind == ProfileCPU || kind == ProfileTrace {
+		if !profileMtx.TryLock() {
+			return ProfileFile{}, ErrProfileRunning
+		}
+		defer profileMtx.Unlock()
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil { //nolint:mnd
+		return ProfileFile{}, err
+	}
+
+	ext := ".pprof"
+	if kind == ProfileTrace {
+		ext = ".trace"
+	}
+	name := kind + "-" + time.Now().Format("20060102-150405") + ext
+	path := filepath.Join(dir, name)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return ProfileFile{}, err
+	}
+
+	err = writeProfile(ctx, file, kind, d)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return ProfileFile{}, err
+	}
+
+	return statProfile(dir, name)
+}
+
+// writeProfile function writes a profile to the file.
+func writeProfile(ctx context.Context, file *os.File, kind string, d time.Duration) error {
+	wait := func() {
+		select {
+		case <-ctx.Done():
+		case <-time.After(d):
+		}
+	}
+
+	switch kind {
+	case ProfileCPU:
+		if err := pprof.StartCPUProfile(file); err != nil {
+			return err
+		}
+		wait()
+		pprof.StopCPUProfile()
+	case ProfileTrace:
+		if err := trace.Start(file); err != nil {
+			return err
+		}
+		wait()
+		trace.Stop()
+	default:
+		p := pprof.Lookup(kind)
+		if p == nil {
+			return fmt.Errorf("%w: %s", ErrInvalidProfile, kind)
+		}
+		if err := p.WriteTo(file, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListProfiles function returns the profiles in dir, newest first.
+func ListProfiles(dir string) ([]ProfileFile, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []ProfileFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]ProfileFile, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if f, err := statProfile(dir, e.Name()); err == nil {
+			files = append(files, f)
+		}
+	}
+
+	slices.SortFunc(files, func(a, b ProfileFile) int {
+		return b.Created.Compare(a.Created)
+	})
+
+	return files, nil
+}
+
+// ProfilePath function returns the path of a profile in dir, names with
+// directories are rejected.
+func ProfilePath(dir, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidProfile, name)
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+func statProfile(dir, name string) (ProfileFile, error) {
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		return ProfileFile{}, err
+	}
+
+	return ProfileFile{
+		Name:    name,
+		Size:    info.Size(),
+		Created: info.ModTime(),
+	}, nil
+}
@@ -4,6 +4,7 @@
 package core
 
 import (
+	"runtime"
 	"runtime/debug"
 	"strings"
 )
@@ -13,8 +14,22 @@ var (
 	realVersion    *string
 	isDirty        *bool
 	AppNameVersion = AppName + "-" + GetVersion()
+
+	// GitCommit and BuildDate are set by the release builds, other builds
+	// use the VCS information of the build.
+	GitCommit string
+	BuildDate string
 )
 
+// BuildInfo struct stores the version and build metadata of the binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Dirty     bool   `json:"dirty"`
+}
+
 const (
 	AppName   = "TSDProxy-Cloudflare"
 	AppAuthor = "Yi Chen Chong"
@@ -51,3 +66,28 @@ func getIsDirty() bool {
 	}
 	return *isDirty
 }
+
+// GetBuildInfo function returns the version and build metadata of the
+// binary.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   GetVersion(),
+		Commit:    GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Dirty:     getIsDirty(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, v := range bi.Settings {
+			switch {
+			case v.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = v.Value
+			case v.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = v.Value
+			}
+		}
+	}
+
+	return info
+}
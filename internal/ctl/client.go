@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+)
+
+const (
+	apiTimeout = 10 * time.Second
+	// apiKeyEnv is the environment variable with the API key used if -key
+	// isn't set.
+	apiKeyEnv = "TSDPROXY_API_KEY"
+)
+
+var errAuthRequired = errors.New("dashboard authentication is enabled, use -key with an API key " +
+	"or -user from a trusted proxy address")
+
+// APIClient struct sends requests to the API of a running server.
+type APIClient struct {
+	client *http.Client
+	addr   string
+	key    string
+	user   string
+}
+
+// AddAPIFlags function adds the flags to connect to the API and returns a
+// function that creates the client after the flags are parsed.
+func AddAPIFlags(fs *flag.FlagSet) func() *APIClient {
+	addr := fs.String("addr", "http://127.0.0.1:8080", "address of the tsdproxy server")
+	key := fs.String("key", "", "API key, $"+apiKeyEnv+" by default")
+	user := fs.String("user", "", "username sent to the server, from a trusted proxy address")
+
+	return func() *APIClient {
+		if *key == "" {
+			*key = os.Getenv(apiKeyEnv)
+		}
+
+		return &APIClient{
+			addr: strings.TrimRight(*addr, "/"),
+			key:  *key,
+			user: *user,
+			// unauthenticated requests are redirected to the login page
+			client: &http.Client{
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			},
+		}
+	}
+}
+
+// do method sends a request to the API and returns the response if it's
+// successful. The caller closes the body.
+func (c *APIClient) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.key != "" {
+		req.Header.Set("Authorization", "Bearer "+c.key)
+	}
+	if c.user != "" {
+		req.Header.Set(consts.HeaderUsername, c.user)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices && resp.StatusCode < http.StatusBadRequest ||
+		resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, errAuthRequired
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+
+		var res struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || res.Message == "" {
+			return nil, fmt.Errorf("server returned %s", resp.Status)
+		}
+
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, res.Message)
+	}
+
+	return resp, nil
+}
+
+// Get method decodes the JSON response of a GET request to v.
+func (c *APIClient) Get(path string, v any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// post method sends a POST request without body.
+func (c *APIClient) post(path string) error {
+	return c.call(http.MethodPost, path)
+}
+
+// delete method sends a DELETE request.
+func (c *APIClient) delete(path string) error {
+	return c.call(http.MethodDelete, path)
+}
+
+// call method sends a request without body and discards the response.
+func (c *APIClient) call(method, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, method, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// send method sends a POST request with the body and decodes the JSON
+// response to v.
+func (c *APIClient) send(path string, body io.Reader, v any) error {
+	return c.sendMethod(http.MethodPost, path, body, v)
+}
+
+// put method sends a PUT request with the body and decodes the JSON
+// response to v.
+func (c *APIClient) put(path string, body io.Reader, v any) error {
+	return c.sendMethod(http.MethodPut, path, body, v)
+}
+
+// sendMethod method sends a request with the body and decodes the JSON
+// response to v.
+func (c *APIClient) sendMethod(method, path string, body io.Reader, v any) error {
+	return c.sendTimeout(method, path, body, v, apiTimeout)
+}
+
+// sendTimeout method sends a request with the body and decodes the JSON
+// response to v, for requests that take longer than apiTimeout.
+func (c *APIClient) sendTimeout(method, path string, body io.Reader, v any, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// stream method copies the response of a GET request to w until the server
+// closes it or the context is done.
+func (c *APIClient) stream(ctx context.Context, path string, w io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
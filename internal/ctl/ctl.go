@@ -0,0 +1,946 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package ctl runs the maintenance commands in a running server with its
+// API, for the ctl command of tsdproxyd and the tsdproxyctl binary.
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+const ctlUsage = `Usage: %s [options] <command>
+
+Commands:
+  list                     list the proxies
+  status                   show the server status
+  status <proxy>           show the status, URL and ports of a proxy
+  restart <proxy>          restart a proxy
+  stats [proxy]            show the connections, requests and traffic of the
+                           proxies, or of the ports of a proxy
+  logs [-f] <proxy>        show the access log of a proxy
+  cert list                list the TLS certificates of the proxies
+  cert status              show the Let's Encrypt account, renewals and
+                           certificate of the server
+  cert renew               renew the Let's Encrypt certificate of the server
+  cert account key         print the Let's Encrypt account key in PEM
+  cert account rollover    replace the Let's Encrypt account key
+  cert account deactivate  deactivate the Let's Encrypt account, a new one
+                           is registered with the next certificate
+  provider reload <name>   read the targets of a target provider again
+  log level                print the log levels of the server and the modules
+  log level [module] <level>
+                           change a log level until the server restarts,
+                           - removes the level of a module
+  plan <provider> <file>   show the changes of a new list file without
+                           applying them, - reads the file from stdin
+  maintenance on <proxy> [message]
+                           serve the maintenance page instead of the target
+  maintenance off <proxy>  serve the target of a proxy again
+  embed [-widget card|status] [-ttl duration] <proxy>
+                           create a read-only widget URL of a proxy to
+                           embed in other dashboards
+  debug status             print a snapshot of the internal state of the server
+  debug profiles           list the captured profiles
+  debug profile [-duration d] [-o file] <kind>
+                           capture a cpu, trace, heap, allocs, goroutine,
+                           block, mutex or threadcreate profile in the data
+                           directory, -o downloads it
+  audit [-actor user] [-action action] [-target target] [-since time]
+        [-limit n]         show the administrative actions, newest first
+
+Options:
+`
+
+var errUsage = errors.New("invalid command")
+
+type (
+	// ctl struct runs the ctl commands.
+	ctl struct {
+		client *APIClient
+		json   bool
+	}
+
+	ctlProxy struct {
+		StatusChanged  time.Time `json:"statusChanged"`
+		Uptime         *float64  `json:"uptime"`
+		Name           string    `json:"name"`
+		Status         string    `json:"status"`
+		URL            string    `json:"url"`
+		AuthURL        string    `json:"authUrl"`
+		TargetProvider string    `json:"targetProvider"`
+		ProxyProvider  string    `json:"proxyProvider"`
+		Ports          []string  `json:"ports"`
+	}
+
+	ctlProxyDetail struct {
+		Maintenance *struct {
+			Message string `json:"message"`
+		} `json:"maintenance"`
+		PortErrors map[string]string `json:"portErrors"`
+		ctlProxy
+		Disabled bool `json:"disabled"`
+	}
+
+	ctlStatus struct {
+		Proxies         map[string]int `json:"proxies"`
+		Version         string         `json:"version"`
+		TargetProviders []struct {
+			Name       string `json:"name"`
+			Status     string `json:"status"`
+			Error      string `json:"error"`
+			Reloadable bool   `json:"reloadable"`
+		} `json:"targetProviders"`
+		Warnings []string `json:"warnings"`
+	}
+
+	ctlPlan struct {
+		Proxies []struct {
+			Name    string   `json:"name"`
+			Action  string   `json:"action"`
+			Error   string   `json:"error"`
+			Changes []string `json:"changes"`
+		} `json:"proxies"`
+		DNS []struct {
+			Name   string `json:"name"`
+			Proxy  string `json:"proxy"`
+			Action string `json:"action"`
+		} `json:"dns"`
+		Certificates []struct {
+			Proxy string   `json:"proxy"`
+			Ports []string `json:"ports"`
+		} `json:"certificates"`
+	}
+
+	ctlEmbed struct {
+		Expires time.Time `json:"expires"`
+		Token   string    `json:"token"`
+		Path    string    `json:"path"`
+	}
+
+	ctlLogLevel struct {
+		Modules map[string]string `json:"modules"`
+		Level   string            `json:"level"`
+	}
+
+	ctlProfile struct {
+		Created time.Time `json:"created"`
+		Name    string    `json:"name"`
+		Size    int64     `json:"size"`
+	}
+
+	ctlAuditEntry struct {
+		Time  time.Time `json:"time"`
+		Actor *struct {
+			Username string `json:"username"`
+		} `json:"actor"`
+		Action  string `json:"action"`
+		Target  string `json:"target"`
+		Outcome string `json:"outcome"`
+		Error   string `json:"error"`
+	}
+
+	ctlStats struct {
+		Ports map[string]ctlPortStats `json:"ports"`
+		Name  string                  `json:"name"`
+		ctlPortStats
+	}
+
+	ctlPortStats struct {
+		Active       int64   `json:"active"`
+		Connections  uint64  `json:"connections"`
+		Requests     uint64  `json:"requests"`
+		BytesIn      uint64  `json:"bytesIn"`
+		BytesOut     uint64  `json:"bytesOut"`
+		RequestRate  float64 `json:"requestRate"`
+		BytesInRate  float64 `json:"bytesInRate"`
+		BytesOutRate float64 `json:"bytesOutRate"`
+	}
+
+	ctlLetsEncrypt struct {
+		NextCheck   *time.Time `json:"nextCheck"`
+		LastRenewal *struct {
+			Time   time.Time `json:"time"`
+			Error  string    `json:"error"`
+			Forced bool      `json:"forced"`
+			OK     bool      `json:"ok"`
+		} `json:"lastRenewal"`
+		Account struct {
+			URI        string `json:"uri"`
+			Status     string `json:"status"`
+			Error      string `json:"error"`
+			Registered bool   `json:"registered"`
+		} `json:"account"`
+		Certificates []struct {
+			NotAfter    *time.Time `json:"notAfter"`
+			RenewAt     *time.Time `json:"renewAt"`
+			Domain      string     `json:"domain"`
+			Issuer      string     `json:"issuer"`
+			Error       string     `json:"error"`
+			Valid       bool       `json:"valid"`
+			ExpiresSoon bool       `json:"expiresSoon"`
+		} `json:"certificates"`
+	}
+
+	ctlCert struct {
+		NotAfter *time.Time `json:"notAfter"`
+		Proxy    string     `json:"proxy"`
+		Domain   string     `json:"domain"`
+		Issuer   string     `json:"issuer"`
+		Error    string     `json:"error"`
+	}
+)
+
+// Command function runs a command in a running server with its API and
+// returns the exit code. name is the command shown in the usage.
+func Command(name string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	newClient := AddAPIFlags(fs)
+	jsonOutput := fs.Bool("json", false, "print the responses in JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), ctlUsage, name)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	c := &ctl{client: newClient(), json: *jsonOutput}
+
+	err := c.run(fs.Args())
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// run method runs the command in args.
+func (c *ctl) run(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "list" && len(args) == 0:
+		return c.list()
+	case cmd == "status" && len(args) == 0:
+		return c.status()
+	case cmd == "status" && len(args) == 1:
+		return c.proxyStatus(args[0])
+	case cmd == "restart" && len(args) == 1:
+		return c.restart(args[0])
+	case cmd == "stats" && len(args) == 0:
+		return c.stats()
+	case cmd == "stats" && len(args) == 1:
+		return c.proxyStats(args[0])
+	case cmd == "logs":
+		return c.logs(args)
+	case cmd == "cert" && len(args) == 1 && args[0] == "list":
+		return c.certs()
+	case cmd == "cert" && len(args) == 1 && args[0] == "status":
+		return c.certStatus()
+	case cmd == "cert" && len(args) == 1 && args[0] == "renew":
+		return c.renewCert()
+	case cmd == "cert" && len(args) == 2 && args[0] == "account": //nolint:mnd
+		return c.certAccount(args[1])
+	case cmd == "provider" && len(args) == 2 && args[0] == "reload": //nolint:mnd
+		return c.reloadProvider(args[1])
+	case cmd == "log" && len(args) >= 1 && len(args) <= 3 && args[0] == "level": //nolint:mnd
+		return c.logLevel(args[1:])
+	case cmd == "plan" && len(args) == 2: //nolint:mnd
+		return c.plan(args[0], args[1])
+	case cmd == "maintenance" && len(args) >= 2 && args[0] == "on": //nolint:mnd
+		return c.startMaintenance(args[1], strings.Join(args[2:], " "))
+	case cmd == "maintenance" && len(args) == 2 && args[0] == "off": //nolint:mnd
+		return c.endMaintenance(args[1])
+	case cmd == "embed":
+		return c.embed(args)
+	case cmd == "audit":
+		return c.audit(args)
+	case cmd == "debug" && len(args) == 1 && args[0] == "status":
+		return c.debugStatus()
+	case cmd == "debug" && len(args) == 1 && args[0] == "profiles":
+		return c.profiles()
+	case cmd == "debug" && len(args) >= 1 && args[0] == "profile":
+		return c.profile(args[1:])
+	}
+
+	return errUsage
+}
+
+// list method prints the proxies.
+func (c *ctl) list() error {
+	var proxies []ctlProxy
+	if done, err := c.get("/api/v1/proxies", &proxies); done || err != nil {
+		return err
+	}
+
+	w := newTable("NAME", "STATUS", "URL", "PORTS", "PROVIDER", "UPTIME")
+	for _, p := range proxies {
+		u := p.URL
+		if p.AuthURL != "" {
+			u = p.AuthURL
+		}
+		uptime := "-"
+		if p.Uptime != nil {
+			uptime = fmt.Sprintf("%.1f%%", *p.Uptime*100) //nolint:mnd
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Name, p.Status, orDash(u),
+			orDash(strings.Join(p.Ports, ",")), p.TargetProvider, uptime)
+	}
+
+	return w.Flush()
+}
+
+// status method prints the status of the server.
+func (c *ctl) status() error {
+	var s ctlStatus
+	if done, err := c.get("/api/v1/status", &s); done || err != nil {
+		return err
+	}
+
+	fmt.Printf("Version: %s\n", s.Version)
+
+	fmt.Println("Proxies:")
+	for _, status := range slices.Sorted(maps.Keys(s.Proxies)) {
+		fmt.Printf("  %s: %d\n", status, s.Proxies[status])
+	}
+
+	fmt.Println("Target providers:")
+	for _, p := range s.TargetProviders {
+		reload := ""
+		if p.Reloadable {
+			reload = " (reloadable)"
+		}
+		fmt.Printf("  %s%s: %s\n", p.Name, reload, orDash(p.Status))
+		if p.Error != "" {
+			fmt.Printf("    %s\n", p.Error)
+		}
+	}
+
+	if len(s.Warnings) > 0 {
+		fmt.Println("Warnings:")
+		for _, warning := range s.Warnings {
+			fmt.Printf("  %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+// proxyStatus method prints the status of a proxy.
+func (c *ctl) proxyStatus(name string) error {
+	var p ctlProxyDetail
+	if done, err := c.get("/api/v1/proxies/"+url.PathEscape(name), &p); done || err != nil {
+		return err
+	}
+
+	status := p.Status
+	if p.Disabled {
+		status += " (disabled)"
+	}
+	if !p.StatusChanged.IsZero() {
+		status += " since " + p.StatusChanged.Local().Format(time.DateTime)
+	}
+
+	fmt.Printf("Name: %s\n", p.Name)
+	fmt.Printf("Status: %s\n", status)
+	fmt.Printf("URL: %s\n", orDash(p.URL))
+	if p.AuthURL != "" {
+		fmt.Printf("Auth URL: %s\n", p.AuthURL)
+	}
+	if p.Uptime != nil {
+		fmt.Printf("Uptime: %.1f%%\n", *p.Uptime*100) //nolint:mnd
+	}
+	fmt.Printf("Target provider: %s\n", p.TargetProvider)
+	fmt.Printf("Proxy provider: %s\n", p.ProxyProvider)
+	if p.Maintenance != nil {
+		fmt.Printf("Maintenance: %s\n", orDash(p.Maintenance.Message))
+	}
+
+	fmt.Println("Ports:")
+	for _, port := range p.Ports {
+		if err, ok := p.PortErrors[port]; ok {
+			fmt.Printf("  %s: %s\n", port, err)
+			continue
+		}
+		fmt.Printf("  %s\n", port)
+	}
+
+	return nil
+}
+
+// stats method prints the connection statistics of the proxies.
+func (c *ctl) stats() error {
+	var stats []ctlStats
+	if done, err := c.get("/api/v1/stats", &stats); done || err != nil {
+		return err
+	}
+
+	w := newTable("NAME", "ACTIVE", "CONNECTIONS", "REQUESTS", "REQ/S", "IN", "OUT", "IN/S", "OUT/S")
+	for _, s := range stats {
+		s.print(w, s.Name)
+	}
+
+	return w.Flush()
+}
+
+// proxyStats method prints the connection statistics of the ports of a
+// proxy.
+func (c *ctl) proxyStats(name string) error {
+	var stats ctlStats
+	if done, err := c.get("/api/v1/proxies/"+url.PathEscape(name)+"/stats", &stats); done || err != nil {
+		return err
+	}
+
+	w := newTable("PORT", "ACTIVE", "CONNECTIONS", "REQUESTS", "REQ/S", "IN", "OUT", "IN/S", "OUT/S")
+	for _, port := range slices.Sorted(maps.Keys(stats.Ports)) {
+		stats.Ports[port].print(w, port)
+	}
+
+	return w.Flush()
+}
+
+// print method prints the statistics in a table row.
+func (s ctlPortStats) print(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f\t%d\t%d\t%.0f\t%.0f\n", name, s.Active, s.Connections,
+		s.Requests, s.RequestRate, s.BytesIn, s.BytesOut, s.BytesInRate, s.BytesOutRate)
+}
+
+// restart method restarts a proxy, the server restarts it in background.
+func (c *ctl) restart(name string) error {
+	if err := c.client.post("/api/v1/proxies/" + url.PathEscape(name) + "/restart"); err != nil {
+		return err
+	}
+
+	fmt.Printf("proxy %s restarting\n", name)
+
+	return nil
+}
+
+// logs method prints the access log of a proxy, and the new lines until
+// interrupted with -f.
+func (c *ctl) logs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	follow := fs.Bool("f", false, "follow the new lines")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	path := "/api/v1/proxies/" + url.PathEscape(fs.Arg(0)) + "/logs"
+	if !*follow {
+		ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+		defer cancel()
+
+		return c.client.stream(ctx, path, os.Stdout)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return c.client.stream(ctx, path+"?follow=true", os.Stdout)
+}
+
+// certs method prints the TLS certificates of the proxies.
+func (c *ctl) certs() error {
+	var certs []ctlCert
+	if done, err := c.get("/api/v1/certs", &certs); done || err != nil {
+		return err
+	}
+
+	w := newTable("PROXY", "DOMAIN", "ISSUER", "EXPIRES")
+	for _, cert := range certs {
+		if cert.Error != "" {
+			fmt.Fprintf(w, "%s\t-\t-\terror: %s\n", cert.Proxy, cert.Error)
+			continue
+		}
+
+		expires := "-"
+		if cert.NotAfter != nil {
+			expires = cert.NotAfter.Local().Format(time.DateTime)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cert.Proxy, cert.Domain, orDash(cert.Issuer), expires)
+	}
+
+	return w.Flush()
+}
+
+// certStatus method prints the Let's Encrypt account, the last renewal and
+// the certificates of the server.
+func (c *ctl) certStatus() error {
+	var le ctlLetsEncrypt
+	if done, err := c.get("/api/v1/letsencrypt", &le); done || err != nil {
+		return err
+	}
+
+	account := "not registered"
+	if le.Account.Registered {
+		account = orDash(le.Account.Status) + " " + le.Account.URI
+	}
+	fmt.Printf("Account: %s\n", account)
+	if le.Account.Error != "" {
+		fmt.Printf("  %s\n", le.Account.Error)
+	}
+
+	if r := le.LastRenewal; r != nil {
+		result := "ok"
+		if !r.OK {
+			result = "failed: " + r.Error
+		}
+		forced := ""
+		if r.Forced {
+			forced = " (forced)"
+		}
+		fmt.Printf("Last renewal: %s%s, %s\n", r.Time.Local().Format(time.DateTime), forced, result)
+	}
+	if le.NextCheck != nil {
+		fmt.Printf("Next check: %s\n", le.NextCheck.Local().Format(time.DateTime))
+	}
+
+	fmt.Println()
+
+	w := newTable("DOMAIN", "ISSUER", "EXPIRES", "RENEW AT", "STATE")
+	for _, cert := range le.Certificates {
+		state := "valid"
+		switch {
+		case cert.Error != "":
+			state = "error: " + cert.Error
+		case !cert.Valid:
+			state = "invalid"
+		case cert.ExpiresSoon:
+			state = "expires soon"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", cert.Domain, orDash(cert.Issuer),
+			formatTime(cert.NotAfter), formatTime(cert.RenewAt), state)
+	}
+
+	return w.Flush()
+}
+
+// renewCert method starts a forced renewal of the Let's Encrypt certificate
+// of the server, its result is returned by /api/v1/letsencrypt.
+func (c *ctl) renewCert() error {
+	if err := c.client.post("/api/v1/letsencrypt/renew"); err != nil {
+		return err
+	}
+
+	fmt.Println("certificate renewal started")
+
+	return nil
+}
+
+// certAccount method runs an operation of the Let's Encrypt account.
+func (c *ctl) certAccount(action string) error {
+	switch action {
+	case "key":
+		ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+		defer cancel()
+
+		return c.client.stream(ctx, "/api/v1/letsencrypt/account/key", os.Stdout)
+	case "rollover":
+		if err := c.client.post("/api/v1/letsencrypt/account/rollover"); err != nil {
+			return err
+		}
+		fmt.Println("account key rolled over")
+	case "deactivate":
+		if err := c.client.post("/api/v1/letsencrypt/account/deactivate"); err != nil {
+			return err
+		}
+		fmt.Println("account deactivated")
+	default:
+		return errUsage
+	}
+
+	return nil
+}
+
+// reloadProvider method reads the targets of a target provider again.
+func (c *ctl) reloadProvider(name string) error {
+	if err := c.client.post("/api/v1/providers/" + url.PathEscape(name) + "/reload"); err != nil {
+		return err
+	}
+
+	fmt.Printf("target provider %s reloaded\n", name)
+
+	return nil
+}
+
+// logLevel method prints the log levels, or changes the level of the
+// server or of a module.
+func (c *ctl) logLevel(args []string) error {
+	var raw json.RawMessage
+
+	if len(args) == 0 {
+		if err := c.client.Get("/api/v1/log/level", &raw); err != nil {
+			return err
+		}
+	} else {
+		req := map[string]string{"level": args[len(args)-1]}
+		if len(args) == 2 { //nolint:mnd
+			req["module"] = args[0]
+		}
+		if req["level"] == "-" {
+			req["level"] = ""
+		}
+		body, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		if err := c.client.put("/api/v1/log/level", bytes.NewReader(body), &raw); err != nil {
+			return err
+		}
+	}
+
+	if c.json {
+		return printJSON(raw)
+	}
+
+	var l ctlLogLevel
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return err
+	}
+
+	fmt.Printf("level: %s\n", l.Level)
+	for _, module := range slices.Sorted(maps.Keys(l.Modules)) {
+		fmt.Printf("  %s: %s\n", module, l.Modules[module])
+	}
+
+	return nil
+}
+
+// plan method prints the changes that a list file would make to the proxies
+// of a target provider.
+func (c *ctl) plan(provider, filename string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if filename == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	path := "/api/v1/providers/" + url.PathEscape(provider) + "/plan"
+
+	if c.json {
+		var raw json.RawMessage
+		if err := c.client.send(path, bytes.NewReader(data), &raw); err != nil {
+			return err
+		}
+		return printJSON(raw)
+	}
+
+	var p ctlPlan
+	if err := c.client.send(path, bytes.NewReader(data), &p); err != nil {
+		return err
+	}
+
+	if len(p.Proxies) == 0 && len(p.DNS) == 0 && len(p.Certificates) == 0 {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	if len(p.Proxies) > 0 {
+		w := newTable("PROXY", "ACTION", "CHANGES")
+		for _, proxy := range p.Proxies {
+			changes := strings.Join(proxy.Changes, ", ")
+			if proxy.Error != "" {
+				changes = "error: " + proxy.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", proxy.Name, proxy.Action, orDash(changes))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(p.DNS) > 0 {
+		fmt.Println("\nPublic DNS:")
+		for _, d := range p.DNS {
+			fmt.Printf("  %s %s (%s)\n", d.Action, d.Name, d.Proxy)
+		}
+	}
+
+	if len(p.Certificates) > 0 {
+		fmt.Println("\nCertificates:")
+		for _, cert := range p.Certificates {
+			fmt.Printf("  %s (%s)\n", cert.Proxy, strings.Join(cert.Ports, ", "))
+		}
+	}
+
+	return nil
+}
+
+// startMaintenance method puts a proxy in maintenance with the message, the
+// default message of the server when it's empty.
+func (c *ctl) startMaintenance(name, message string) error {
+	body, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		return err
+	}
+
+	var res struct{}
+	if err := c.client.send("/api/v1/proxies/"+url.PathEscape(name)+"/maintenance", bytes.NewReader(body), &res); err != nil {
+		return err
+	}
+
+	fmt.Printf("proxy %s in maintenance\n", name)
+
+	return nil
+}
+
+// endMaintenance method ends the maintenance of a proxy.
+func (c *ctl) endMaintenance(name string) error {
+	if err := c.client.delete("/api/v1/proxies/" + url.PathEscape(name) + "/maintenance"); err != nil {
+		return err
+	}
+
+	fmt.Printf("proxy %s maintenance ended\n", name)
+
+	return nil
+}
+
+// embed method creates a widget URL of a proxy.
+func (c *ctl) embed(args []string) error {
+	fs := flag.NewFlagSet("embed", flag.ContinueOnError)
+	widget := fs.String("widget", "card", "widget: card or status")
+	ttl := fs.Duration("ttl", 0, "lifetime of the URL, 720h by default")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	req := map[string]string{"proxy": fs.Arg(0), "widget": *widget}
+	if *ttl > 0 {
+		req["ttl"] = ttl.String()
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		var raw json.RawMessage
+		if err := c.client.send("/api/v1/embed", bytes.NewReader(body), &raw); err != nil {
+			return err
+		}
+		return printJSON(raw)
+	}
+
+	var e ctlEmbed
+	if err := c.client.send("/api/v1/embed", bytes.NewReader(body), &e); err != nil {
+		return err
+	}
+
+	fmt.Println(c.client.addr + e.Path)
+	fmt.Printf("expires %s\n", e.Expires.Local().Format(time.DateTime))
+
+	return nil
+}
+
+// debugStatus method prints the snapshot of the internal state of the
+// server, always in JSON.
+func (c *ctl) debugStatus() error {
+	var raw json.RawMessage
+	if err := c.client.Get("/debug/status", &raw); err != nil {
+		return err
+	}
+
+	return printJSON(raw)
+}
+
+// profiles method prints the captured profiles.
+func (c *ctl) profiles() error {
+	var files []ctlProfile
+	if done, err := c.get("/api/v1/debug/profiles", &files); done || err != nil {
+		return err
+	}
+
+	w := newTable("NAME", "SIZE", "CREATED")
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", f.Name, f.Size, f.Created.Local().Format(time.DateTime))
+	}
+
+	return w.Flush()
+}
+
+// profile method captures a profile in the server, and downloads it with -o.
+func (c *ctl) profile(args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	duration := fs.Duration("duration", 0, "duration of the cpu and trace profiles, 30s by default")
+	output := fs.String("o", "", "download the profile to a file")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	req := map[string]string{"kind": fs.Arg(0)}
+	if *duration > 0 {
+		req["duration"] = duration.String()
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	// the cpu and trace profiles respond after their duration
+	timeout := apiTimeout + max(*duration, core.DefaultProfileDuration)
+
+	var f ctlProfile
+	if err := c.client.sendTimeout(http.MethodPost, "/api/v1/debug/profiles", bytes.NewReader(body), &f, timeout); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Printf("profile %s captured, %d bytes\n", f.Name, f.Size)
+		return nil
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	if err := c.client.stream(ctx, "/api/v1/debug/profiles/"+url.PathEscape(f.Name), file); err != nil {
+		return err
+	}
+
+	fmt.Printf("profile %s saved to %s\n", f.Name, *output)
+
+	return file.Close()
+}
+
+// audit method prints the entries of the audit log.
+func (c *ctl) audit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	actor := fs.String("actor", "", "only the actions of a user")
+	action := fs.String("action", "", "only an action, like \"proxy restart\"")
+	target := fs.String("target", "", "only the actions on a target")
+	since := fs.String("since", "", "only the actions since a time in RFC 3339 or a duration, like 24h")
+	limit := fs.Int("limit", 0, "maximum number of actions, 100 by default")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"actor": *actor, "action": *action, "target": *target, "since": *since} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+
+	path := "/api/v1/audit"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var entries []ctlAuditEntry
+	if done, err := c.get(path, &entries); done || err != nil {
+		return err
+	}
+
+	w := newTable("TIME", "ACTOR", "ACTION", "TARGET", "OUTCOME")
+	for _, e := range entries {
+		actor := "-"
+		if e.Actor != nil {
+			actor = e.Actor.Username
+		}
+		outcome := e.Outcome
+		if e.Error != "" {
+			outcome += ": " + e.Error
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.DateTime), actor,
+			e.Action, orDash(e.Target), outcome)
+	}
+
+	return w.Flush()
+}
+
+// get method reads a response of the API to v. With -json, the response is
+// printed and done is true.
+func (c *ctl) get(path string, v any) (bool, error) {
+	if !c.json {
+		return false, c.client.Get(path, v)
+	}
+
+	var raw json.RawMessage
+	if err := c.client.Get(path, &raw); err != nil {
+		return true, err
+	}
+
+	return true, printJSON(raw)
+}
+
+// printJSON function prints a JSON response indented.
+func printJSON(raw json.RawMessage) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+
+	_, err := out.WriteTo(os.Stdout)
+
+	return err
+}
+
+// newTable function returns a tabwriter with the header written.
+func newTable(header ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:mnd
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	return w
+}
+
+// formatTime function returns the local time, - without a time.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format(time.DateTime)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
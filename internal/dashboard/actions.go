@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+)
+
+// stopHandler stops a proxy, the new status is sent in the stream
+func (dash *Dashboard) stopHandler() http.HandlerFunc {
+	return dash.proxyAction("stop", dash.pm.StopProxy)
+}
+
+// restartHandler restarts a proxy, the new status is sent in the stream
+func (dash *Dashboard) restartHandler() http.HandlerFunc {
+	return dash.proxyAction("restart", dash.pm.RestartProxy)
+}
+
+// disableHandler stops a proxy and keeps it stopped until enabled again
+func (dash *Dashboard) disableHandler() http.HandlerFunc {
+	return dash.proxyAction("disable", dash.pm.DisableProxy)
+}
+
+// enableHandler starts a disabled proxy
+func (dash *Dashboard) enableHandler() http.HandlerFunc {
+	return dash.proxyAction("enable", dash.pm.EnableProxy)
+}
+
+// maintenanceHandler serves the maintenance page in the ports of a proxy
+// until its maintenance ends
+func (dash *Dashboard) maintenanceHandler() http.HandlerFunc {
+	return dash.proxyAction("maintenance", func(name string) error {
+		return dash.pm.StartMaintenance(name, "")
+	})
+}
+
+// endMaintenanceHandler serves the target of a proxy in maintenance again
+func (dash *Dashboard) endMaintenanceHandler() http.HandlerFunc {
+	return dash.proxyAction("end maintenance", dash.pm.EndMaintenance)
+}
+
+// proxyAction method returns a handler that runs the action in background,
+// stopping a proxy waits for active requests to finish.
+func (dash *Dashboard) proxyAction(action string, fn func(name string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		if _, ok := dash.pm.GetProxy(name); !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("proxy", name).Str("action", action).Str("username", user.Username).Msg("proxy action")
+
+		record := dash.audit(r, "proxy "+action, name)
+
+		go func() {
+			err := fn(name)
+			if err != nil && !errors.Is(err, proxymanager.ErrProxyNotFound) {
+				dash.Log.Error().Err(err).Str("proxy", name).Str("action", action).Msg("Error running proxy action")
+			}
+			record(err)
+		}()
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
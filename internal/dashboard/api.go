@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/history"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+)
+
+// uptimeDays is the number of days of the uptime shown in the dashboard.
+const uptimeDays = 30
+
+type (
+	// historyResponse struct is the status history of a proxy in the API.
+	historyResponse struct {
+		Since   time.Time             `json:"since"`
+		Uptime  *float64              `json:"uptime,omitempty"`
+		Name    string                `json:"name"`
+		History []historyResponseItem `json:"history"`
+	}
+
+	historyResponseItem struct {
+		Time   time.Time `json:"time"`
+		Status string    `json:"status"`
+	}
+
+	// authURLResponse struct is the Tailscale auth URL of a proxy in the API.
+	authURLResponse struct {
+		Name    string `json:"name"`
+		Status  string `json:"status"`
+		AuthURL string `json:"authUrl,omitempty"`
+	}
+
+	apiError struct {
+		Message string `json:"message"`
+	}
+)
+
+// historyAPIHandler returns the status history and uptime of a proxy, of the
+// last days in the days query parameter, 30 by default. Removed proxies keep
+// their history until it's older than the retention.
+func (dash *Dashboard) historyAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		days := uptimeDays
+		if d := r.URL.Query().Get("days"); d != "" {
+			var err error
+			if days, err = strconv.Atoi(d); err != nil || days < 1 {
+				dash.HTTP.JSONResponseCode(w, r, apiError{Message: "invalid days"}, http.StatusBadRequest)
+				return
+			}
+		}
+
+		now := time.Now()
+		since := now.AddDate(0, 0, -days)
+
+		h, err := dash.pm.GetHistory(name, since)
+		if errors.Is(err, proxymanager.ErrHistoryDisabled) {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			dash.Log.Error().Err(err).Str("proxy", name).Msg("Error reading proxy history")
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: http.StatusText(http.StatusInternalServerError)},
+				http.StatusInternalServerError)
+			return
+		}
+
+		res := historyResponse{
+			Name:    name,
+			Since:   since,
+			History: make([]historyResponseItem, len(h)),
+		}
+		for i, c := range h {
+			res.History[i] = historyResponseItem{Time: c.Time, Status: c.Status.String()}
+		}
+		if uptime, ok := history.Uptime(h, since, now); ok {
+			res.Uptime = &uptime
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// authURLAPIHandler returns the Tailscale auth URL of a proxy, only set while
+// the proxy is waiting to be authenticated.
+func (dash *Dashboard) authURLAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		p, ok := dash.pm.GetProxy(name)
+		if !ok {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: proxymanager.ErrProxyNotFound.Error()}, http.StatusNotFound)
+			return
+		}
+
+		status := p.GetStatus()
+
+		res := authURLResponse{
+			Name:   name,
+			Status: status.String(),
+		}
+		if status == model.ProxyStatusAuthenticating {
+			res.AuthURL = p.GetAuthURL()
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// uptimeLabel method returns the uptime of a proxy shown in the dashboard,
+// empty if unknown.
+func (dash *Dashboard) uptimeLabel(name string) string {
+	uptime, ok := dash.pm.GetUptime(name, time.Now().AddDate(0, 0, -uptimeDays))
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("up %.1f%% last %d days", uptime*100, uptimeDays) //nolint:mnd
+}
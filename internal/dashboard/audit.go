@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/audit"
+)
+
+// defaultAuditLimit is the number of entries returned by the audit API
+// without limit.
+const defaultAuditLimit = 100
+
+// SetAudit method sets the audit log of the administrative actions.
+func (dash *Dashboard) SetAudit(l *audit.Log) {
+	dash.mtx.Lock()
+	dash.auditLog = l
+	dash.mtx.Unlock()
+}
+
+func (dash *Dashboard) getAudit() *audit.Log {
+	dash.mtx.RLock()
+	defer dash.mtx.RUnlock()
+
+	return dash.auditLog
+}
+
+// audit method returns a function that records an action of the user of
+// the request with its outcome. The user is read from the request, so the
+// function can be called after the request, like by background actions.
+func (dash *Dashboard) audit(r *http.Request, action, target string) func(err error) {
+	user, _ := UserFromContext(r.Context())
+	entry := audit.Entry{
+		Action: action,
+		Target: target,
+		Actor: &audit.Actor{
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			Role:        string(user.Role),
+			Address:     r.RemoteAddr,
+		},
+	}
+	l := dash.getAudit()
+
+	return func(err error) {
+		l.Record(entry, err)
+	}
+}
+
+// auditAPIHandler returns the entries of the audit log, filtered by actor,
+// action, target and since.
+func (dash *Dashboard) auditAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := audit.Filter{
+			Actor:  q.Get("actor"),
+			Action: q.Get("action"),
+			Target: q.Get("target"),
+			Limit:  defaultAuditLimit,
+		}
+
+		if s := q.Get("since"); s != "" {
+			since, err := parseSince(s)
+			if err != nil {
+				dash.HTTP.JSONResponseCode(w, r, apiError{Message: "invalid since: " + s}, http.StatusBadRequest)
+				return
+			}
+			f.Since = since
+		}
+		if s := q.Get("limit"); s != "" {
+			limit, err := strconv.Atoi(s)
+			if err != nil || limit < 0 {
+				dash.HTTP.JSONResponseCode(w, r, apiError{Message: "invalid limit: " + s}, http.StatusBadRequest)
+				return
+			}
+			f.Limit = limit
+		}
+
+		entries, err := dash.getAudit().Query(f)
+		if errors.Is(err, audit.ErrDisabled) {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		dash.HTTP.JSONResponse(w, r, entries)
+	}
+}
+
+// parseSince function parses a time in RFC 3339, or a duration before now
+// like 24h.
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
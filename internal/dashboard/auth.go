@@ -0,0 +1,362 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/auth"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	RoleViewer Role = "viewer"
+	RoleAdmin  Role = "admin"
+
+	apiPath       = "/api/"
+	loginPath     = "/login"
+	loginOIDCPath = "/login/oidc"
+	logoutPath    = "/logout"
+	metricsPath   = "/metrics"
+
+	authCookieName  = "tsdproxy_dashboard"
+	randomBytesSize = 32
+)
+
+// dummyHash is compared when the user doesn't exist, so unknown and known
+// users take the same time to be rejected.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("tsdproxy"), bcrypt.DefaultCost)
+
+type (
+	// Role type is the permission level of a dashboard user.
+	Role string
+
+	// User struct is an authenticated dashboard user.
+	User struct {
+		Role Role
+		model.Whois
+	}
+
+	// authenticator struct identifies dashboard users and their roles.
+	authenticator struct {
+		log            zerolog.Logger
+		pm             *proxymanager.ProxyManager
+		sessions       map[string]authSession
+		cfg            config.DashboardAuthConfig
+		trustedProxies []netip.Prefix
+		mtx            sync.Mutex
+	}
+
+	authSession struct {
+		expires time.Time
+		user    User
+	}
+)
+
+// Allows method returns true if the role has the permissions of required.
+func (r Role) Allows(required Role) bool {
+	return r == RoleAdmin || r == required
+}
+
+// UserFromContext function returns the dashboard user of the request.
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(model.ContextKeyDashboardUser).(User)
+
+	return user, ok
+}
+
+func newAuthenticator(log zerolog.Logger, pm *proxymanager.ProxyManager, cfg config.DashboardAuthConfig) *authenticator {
+	a := &authenticator{
+		log:      log,
+		pm:       pm,
+		cfg:      cfg,
+		sessions: make(map[string]authSession),
+	}
+
+	for _, p := range cfg.TrustedProxies {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			log.Error().Err(err).Str("trustedProxy", p).Msg("invalid trusted proxy")
+			continue
+		}
+		a.trustedProxies = append(a.trustedProxies, prefix.Masked())
+	}
+
+	return a
+}
+
+// middleware method requires an authenticated user when authentication is enabled.
+// Without authentication, only the Tailscale users sent by a trusted proxy
+// are admins, the other users are anonymous viewers.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.cfg.Enabled {
+			user := a.anonymous(r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), model.ContextKeyDashboardUser, user)))
+			return
+		}
+
+		user, ok := a.identify(r)
+		if !ok {
+			a.unauthorized(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), model.ContextKeyDashboardUser, user)))
+	})
+}
+
+// anonymous method returns the user of a request without authentication.
+// The user headers are only accepted from the trusted proxies, the other
+// clients could send any user.
+func (a *authenticator) anonymous(r *http.Request) User {
+	if a.isTrustedProxy(r) {
+		if who := headerWhois(r); who.Username != "" {
+			return User{Role: RoleAdmin, Whois: who}
+		}
+	}
+
+	return User{Role: RoleViewer}
+}
+
+// requireRole function returns a middleware that only allows users with the role.
+func requireRole(role Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := UserFromContext(r.Context())
+			if !ok || !user.Role.Allows(role) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// identify method returns the user from an API key, a trusted proxy, a local
+// session or an OIDC session.
+func (a *authenticator) identify(r *http.Request) (User, bool) {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return a.apiKeyUser(key)
+	}
+
+	if a.isTrustedProxy(r) {
+		if who := headerWhois(r); who.Username != "" {
+			return User{Role: a.role(who.Username), Whois: who}, true
+		}
+	}
+
+	if cookie, err := r.Cookie(authCookieName); err == nil {
+		a.mtx.Lock()
+		s, ok := a.sessions[cookie.Value]
+		if ok && time.Now().After(s.expires) {
+			delete(a.sessions, cookie.Value)
+			ok = false
+		}
+		a.mtx.Unlock()
+
+		if ok {
+			return s.user, true
+		}
+	}
+
+	if oidc := a.oidc(); oidc != nil {
+		if who, ok := oidc.Session(r); ok {
+			return User{Role: a.role(who.Username), Whois: who}, true
+		}
+	}
+
+	return User{}, false
+}
+
+// apiKeyUser method returns the user of an API key, named as the key.
+func (a *authenticator) apiKeyUser(key string) (User, bool) {
+	for name, k := range a.cfg.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return User{
+				Role:  Role(k.Role),
+				Whois: model.Whois{ID: name, Username: name, DisplayName: name},
+			}, true
+		}
+	}
+
+	a.log.Warn().Msg("invalid api key")
+
+	return User{}, false
+}
+
+// unauthorized method redirects page requests to the login and rejects the others.
+func (a *authenticator) unauthorized(w http.ResponseWriter, r *http.Request) {
+	isPage := r.Method == http.MethodGet && r.Header.Get("Datastar-Request") == "" &&
+		!strings.HasPrefix(r.URL.Path, apiPath) && r.URL.Path != metricsPath
+
+	switch {
+	case isPage && len(a.cfg.Users) > 0:
+		http.Redirect(w, r, loginPath, http.StatusFound)
+	case isPage && a.oidc() != nil:
+		http.Redirect(w, r, loginOIDCPath, http.StatusFound)
+	default:
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}
+}
+
+// login method validates a local user and creates the session.
+func (a *authenticator) login(w http.ResponseWriter, r *http.Request, username, password string) bool {
+	hash := dummyHash
+	u, ok := a.cfg.Users[username]
+	if ok {
+		hash = []byte(u.PasswordHash)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		a.log.Warn().Str("username", username).Msg("dashboard login failed")
+		return false
+	}
+
+	id, err := randomString()
+	if err != nil {
+		a.log.Error().Err(err).Msg("error generating dashboard session")
+		return false
+	}
+
+	role := Role(u.Role)
+	if role == "" {
+		role = a.role(username)
+	}
+
+	a.mtx.Lock()
+	a.cleanup()
+	a.sessions[id] = authSession{
+		expires: time.Now().Add(a.cfg.SessionDuration),
+		user: User{
+			Role:  role,
+			Whois: model.Whois{ID: username, Username: username, DisplayName: username},
+		},
+	}
+	a.mtx.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     authCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(a.cfg.SessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	a.log.Info().Str("username", username).Msg("dashboard login")
+
+	return true
+}
+
+// logout method removes the local and OIDC sessions of the request.
+func (a *authenticator) logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(authCookieName); err == nil {
+		a.mtx.Lock()
+		delete(a.sessions, cookie.Value)
+		a.mtx.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     authCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	if oidc := a.oidc(); oidc != nil {
+		oidc.Logout(w, r)
+	}
+}
+
+// role method returns the role of users without a configured role.
+func (a *authenticator) role(username string) Role {
+	isAdmin := slices.ContainsFunc(a.cfg.Admins, func(admin string) bool {
+		return strings.EqualFold(admin, username)
+	})
+	if isAdmin {
+		return RoleAdmin
+	}
+
+	return Role(a.cfg.DefaultRole)
+}
+
+func (a *authenticator) oidc() *auth.OIDC {
+	if a.cfg.OIDC == "" {
+		return nil
+	}
+
+	return a.pm.OIDCProviders[a.cfg.OIDC]
+}
+
+func (a *authenticator) isTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	return slices.ContainsFunc(a.trustedProxies, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// cleanup method removes expired sessions. Must be called with mtx locked.
+func (a *authenticator) cleanup() {
+	now := time.Now()
+	for k, v := range a.sessions {
+		if now.After(v.expires) {
+			delete(a.sessions, k)
+		}
+	}
+}
+
+// headerWhois function returns the user identified by a tsdproxy proxy.
+func headerWhois(r *http.Request) model.Whois {
+	return model.Whois{
+		ID:            r.Header.Get(consts.HeaderUsername),
+		Username:      r.Header.Get(consts.HeaderUsername),
+		DisplayName:   r.Header.Get(consts.HeaderDisplayName),
+		ProfilePicURL: r.Header.Get(consts.HeaderProfilePicURL),
+	}
+}
+
+// isSecure function returns true if the dashboard is served with https,
+// cookies must not require https when the dashboard is served with http.
+func isSecure(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func randomString() (string, error) {
+	b := make([]byte, randomBytesSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
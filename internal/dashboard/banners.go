@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+
+	datastar "github.com/starfederation/datastar/sdk/go"
+)
+
+const (
+	// maxBannerRequest is the maximum size of a banner request.
+	maxBannerRequest = 16 << 10
+	// bannerTimeLayout is the layout of the datetime-local inputs.
+	bannerTimeLayout = "2006-01-02T15:04"
+)
+
+var errBannerTime = errors.New("invalid banner start or end")
+
+type (
+	// bannerRequest struct is a new banner in the API.
+	bannerRequest struct {
+		Start   time.Time `json:"start"`
+		End     time.Time `json:"end"`
+		Message string    `json:"message"`
+		Proxies []string  `json:"proxies"`
+	}
+
+	// bannerResponse struct is a banner in the API.
+	bannerResponse struct {
+		Start   time.Time `json:"start"`
+		End     time.Time `json:"end"`
+		ID      string    `json:"id"`
+		Message string    `json:"message"`
+		Proxies []string  `json:"proxies"`
+		Active  bool      `json:"active"`
+	}
+)
+
+// bannersHandler returns the banners page
+func (dash *Dashboard) bannersHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ui.RenderTempl(w, r, pages.Banners(dash.pm.GetBanners())); err != nil {
+			dash.Log.Error().Err(err).Msg("Error rendering banners")
+		}
+	}
+}
+
+// bannerAddHandler adds a banner from the banners page. The times of the
+// form are in the browser timezone.
+func (dash *Dashboard) bannerAddHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var signals struct {
+			Message string `json:"message"`
+			Start   string `json:"start"`
+			End     string `json:"end"`
+			Proxies string `json:"proxies"`
+			TZ      int    `json:"tz"`
+		}
+		if err := datastar.ReadSignals(r, &signals); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// getTimezoneOffset is the minutes from local time to UTC
+		loc := time.FixedZone("", -signals.TZ*60) //nolint:mnd
+
+		banner := model.Banner{Message: signals.Message}
+		for _, p := range strings.Split(signals.Proxies, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				banner.Proxies = append(banner.Proxies, p)
+			}
+		}
+
+		var err error
+		if signals.Start != "" {
+			banner.Start, err = time.ParseInLocation(bannerTimeLayout, signals.Start, loc)
+		}
+		if err == nil {
+			banner.End, err = time.ParseInLocation(bannerTimeLayout, signals.End, loc)
+		}
+		if err != nil {
+			err = errBannerTime
+		} else {
+			banner, err = dash.addBanner(r, banner)
+		}
+
+		message, failed := "Added", false
+		if err != nil {
+			message, failed = err.Error(), true
+		}
+
+		dash.renderBannerList(w, r, message, failed)
+	}
+}
+
+// bannerDeleteHandler deletes a banner from the banners page
+func (dash *Dashboard) bannerDeleteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		message, failed := "Deleted", false
+		if err := dash.deleteBanner(r, r.PathValue("id")); err != nil {
+			message, failed = err.Error(), true
+		}
+
+		dash.renderBannerList(w, r, message, failed)
+	}
+}
+
+func (dash *Dashboard) renderBannerList(w http.ResponseWriter, r *http.Request, message string, failed bool) {
+	sse := datastar.NewSSE(w, r)
+	if err := sse.MergeFragmentTempl(pages.BannerList(dash.pm.GetBanners(), message, failed)); err != nil {
+		dash.Log.Error().Err(err).Msg("Error sending banners")
+	}
+}
+
+// bannersAPIHandler returns the banners
+func (dash *Dashboard) bannersAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		banners := dash.pm.GetBanners()
+
+		now := time.Now()
+		res := make([]bannerResponse, 0, len(banners))
+		for _, b := range banners {
+			res = append(res, newBannerResponse(b, now))
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// addBannerAPIHandler adds a banner, it's shown from its start, now if
+// it's not set, until its end.
+func (dash *Dashboard) addBannerAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req bannerRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBannerRequest)).Decode(&req); err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		banner, err := dash.addBanner(r, model.Banner{
+			Start:   req.Start,
+			End:     req.End,
+			Message: req.Message,
+			Proxies: req.Proxies,
+		})
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		dash.HTTP.JSONResponseCode(w, r, newBannerResponse(banner, time.Now()), http.StatusCreated)
+	}
+}
+
+// deleteBannerAPIHandler deletes a banner
+func (dash *Dashboard) deleteBannerAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := dash.deleteBanner(r, r.PathValue("id")); err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (dash *Dashboard) addBanner(r *http.Request, banner model.Banner) (model.Banner, error) {
+	user, _ := UserFromContext(r.Context())
+	dash.Log.Info().Str("username", user.Username).Msg("adding banner")
+
+	banner, err := dash.pm.AddBanner(banner)
+	dash.audit(r, "banner add", banner.ID)(err)
+
+	return banner, err
+}
+
+func (dash *Dashboard) deleteBanner(r *http.Request, id string) error {
+	user, _ := UserFromContext(r.Context())
+	dash.Log.Info().Str("banner", id).Str("username", user.Username).Msg("deleting banner")
+
+	err := dash.pm.DeleteBanner(id)
+	dash.audit(r, "banner delete", id)(err)
+	if err != nil && !errors.Is(err, proxymanager.ErrBannerNotFound) {
+		dash.Log.Error().Err(err).Str("banner", id).Msg("Error deleting banner")
+	}
+
+	return err
+}
+
+func newBannerResponse(b model.Banner, now time.Time) bannerResponse {
+	proxies := b.Proxies
+	if proxies == nil {
+		proxies = []string{}
+	}
+
+	return bannerResponse{
+		Start:   b.Start,
+		End:     b.End,
+		ID:      b.ID,
+		Message: b.Message,
+		Proxies: proxies,
+		Active:  b.Active(now),
+	}
+}
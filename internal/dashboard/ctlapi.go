@@ -0,0 +1,442 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+)
+
+const (
+	// certTimeout is the time to read the certificates of a proxy.
+	certTimeout = 10 * time.Second
+	// logsCheckInterval is the interval to check if a followed proxy was
+	// replaced, like when it's restarted.
+	logsCheckInterval = 5 * time.Second
+	// maxPlanRequest is the maximum size of the file of a plan request.
+	maxPlanRequest = 1 << 20
+
+	providerConnected    = "connected"
+	providerDisconnected = "disconnected"
+)
+
+type (
+	// statusResponse struct is the status of the server in the API.
+	statusResponse struct {
+		// Startup is the progress of the proxies being started, nil when
+		// none is
+		Startup         *proxymanager.StartupProgress `json:"startup,omitempty"`
+		Proxies         map[string]int                `json:"proxies"`
+		Version         string                        `json:"version"`
+		TargetProviders []providerResponse            `json:"targetProviders"`
+		Warnings        []string                      `json:"warnings"`
+	}
+
+	// providerResponse struct is a target provider in the API, Error is
+	// set when it lost the connection to its source.
+	providerResponse struct {
+		Name       string `json:"name"`
+		Status     string `json:"status"`
+		Error      string `json:"error,omitempty"`
+		Reloadable bool   `json:"reloadable"`
+	}
+
+	// proxyResponse struct is a proxy in the API.
+	proxyResponse struct {
+		StatusChanged  time.Time                 `json:"statusChanged"`
+		Uptime         *float64                  `json:"uptime,omitempty"`
+		Maintenance    *proxymanager.Maintenance `json:"maintenance,omitempty"`
+		PortErrors     map[string]string         `json:"portErrors,omitempty"`
+		Name           string                    `json:"name"`
+		Label          string                    `json:"label"`
+		Status         string                    `json:"status"`
+		URL            string                    `json:"url,omitempty"`
+		AuthURL        string                    `json:"authUrl,omitempty"`
+		Group          string                    `json:"group,omitempty"`
+		TargetProvider string                    `json:"targetProvider"`
+		ProxyProvider  string                    `json:"proxyProvider"`
+		Ports          []string                  `json:"ports"`
+		Disabled       bool                      `json:"disabled"`
+	}
+
+	// proxyDetailResponse struct is a proxy with the source of its
+	// configuration fields in the API.
+	proxyDetailResponse struct {
+		Provenance model.Provenance `json:"provenance"`
+		proxyResponse
+	}
+
+	// stateResponse struct is the state directory of a node in the API.
+	stateResponse struct {
+		Unused        *time.Time `json:"unused,omitempty"`
+		ProxyProvider string     `json:"proxyProvider"`
+		Name          string     `json:"name"`
+		Path          string     `json:"path"`
+		Size          int64      `json:"size"`
+	}
+
+	// cachePurgeResponse struct is the result of a response cache purge.
+	cachePurgeResponse struct {
+		Purged int `json:"purged"`
+	}
+
+	// certResponse struct is a certificate of a proxy in the API.
+	certResponse struct {
+		NotBefore *time.Time `json:"notBefore,omitempty"`
+		NotAfter  *time.Time `json:"notAfter,omitempty"`
+		Proxy     string     `json:"proxy"`
+		Domain    string     `json:"domain,omitempty"`
+		Issuer    string     `json:"issuer,omitempty"`
+		Error     string     `json:"error,omitempty"`
+	}
+)
+
+// statusAPIHandler returns the version, the number of proxies by status,
+// the startup progress, the target providers and the warnings of the server.
+func (dash *Dashboard) statusAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := statusResponse{
+			Version:         core.GetVersion(),
+			Proxies:         make(map[string]int),
+			TargetProviders: dash.providersResponse(),
+			Warnings:        dash.getWarnings(r.Context()),
+		}
+
+		for _, p := range dash.apiProxies(r) {
+			status := p.GetStatus()
+			res.Proxies[status.String()]++
+		}
+
+		if progress := dash.pm.GetStartupProgress(); progress.Total > 0 {
+			res.Startup = &progress
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// providersResponse method returns the target providers sorted by name.
+func (dash *Dashboard) providersResponse() []providerResponse {
+	names, reloadable := dash.pm.GetTargetProviders()
+	disconnected := dash.pm.GetDisconnectedTargetProviders()
+
+	res := make([]providerResponse, 0, len(names))
+	for _, name := range names {
+		p := providerResponse{
+			Name:       name,
+			Status:     providerConnected,
+			Reloadable: reloadable[name],
+		}
+		if err, ok := disconnected[name]; ok {
+			p.Status = providerDisconnected
+			p.Error = err
+		}
+		res = append(res, p)
+	}
+
+	return res
+}
+
+// proxiesAPIHandler returns the proxies sorted by name.
+func (dash *Dashboard) proxiesAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxies := dash.apiProxies(r)
+
+		res := make([]proxyResponse, 0, len(proxies))
+		for _, name := range slices.Sorted(maps.Keys(proxies)) {
+			res = append(res, dash.proxyResponse(name, proxies[name]))
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// proxyAPIHandler returns a proxy.
+func (dash *Dashboard) proxyAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		p, ok := dash.apiProxies(r)[name]
+		if !ok {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: proxymanager.ErrProxyNotFound.Error()}, http.StatusNotFound)
+			return
+		}
+
+		dash.HTTP.JSONResponse(w, r, proxyDetailResponse{
+			proxyResponse: dash.proxyResponse(name, p),
+			Provenance:    p.Config().Provenance,
+		})
+	}
+}
+
+// logsAPIHandler returns the last access log lines of a proxy in plain text.
+// With the follow query parameter, new lines are sent until the client
+// disconnects.
+func (dash *Dashboard) logsAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		p, ok := dash.apiProxies(r)[name]
+		if !ok {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: proxymanager.ErrProxyNotFound.Error()}, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		tail := p.GetAccessLogTail()
+		follow := r.URL.Query().Has("follow")
+
+		var lines chan string
+		if follow {
+			lines = tail.Subscribe()
+			defer func() { tail.Unsubscribe(lines) }()
+		}
+
+		for _, line := range tail.Lines() {
+			fmt.Fprintln(w, line)
+		}
+
+		if !follow {
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		_ = rc.Flush()
+
+		ticker := time.NewTicker(logsCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case line := <-lines:
+				fmt.Fprintln(w, line)
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			case <-ticker.C:
+				// a restarted proxy has a new access log
+				current, ok := dash.pm.GetProxy(name)
+				if !ok {
+					return
+				}
+				if current != p {
+					tail.Unsubscribe(lines)
+					p = current
+					tail = p.GetAccessLogTail()
+					lines = tail.Subscribe()
+				}
+			}
+		}
+	}
+}
+
+// certsAPIHandler returns the TLS certificates of the running proxies.
+// Certificates that can't be read are returned with the error.
+func (dash *Dashboard) certsAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxies := dash.apiProxies(r)
+
+		res := []certResponse{}
+
+		for _, name := range slices.Sorted(maps.Keys(proxies)) {
+			p := proxies[name]
+			if p.GetStatus() != model.ProxyStatusRunning {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), certTimeout)
+			certs, err := p.GetCertificates(ctx)
+			cancel()
+
+			if errors.Is(err, proxymanager.ErrCertsUnsupported) {
+				continue
+			}
+			if err != nil {
+				res = append(res, certResponse{Proxy: name, Error: err.Error()})
+				continue
+			}
+
+			for _, c := range certs {
+				res = append(res, certResponse{
+					Proxy:     name,
+					Domain:    c.Domain,
+					Issuer:    c.Issuer,
+					NotBefore: &c.NotBefore,
+					NotAfter:  &c.NotAfter,
+				})
+			}
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// stateAPIHandler returns the state directories of the nodes of the proxy
+// providers, with their size and since when they're unused.
+func (dash *Dashboard) stateAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dirs, err := dash.pm.GetStateDirs()
+		if err != nil {
+			dash.Log.Error().Err(err).Msg("Error reading state directories")
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		res := []stateResponse{}
+		for _, provider := range slices.Sorted(maps.Keys(dirs)) {
+			for _, d := range dirs[provider] {
+				res = append(res, stateResponse{
+					ProxyProvider: provider,
+					Name:          d.Name,
+					Path:          d.Path,
+					Size:          d.Size,
+					Unused:        d.Unused,
+				})
+			}
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// reloadProviderAPIHandler reads the targets of a target provider again.
+func (dash *Dashboard) reloadProviderAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("provider", name).Str("username", user.Username).Msg("target provider reload")
+
+		err := dash.pm.ReloadTargetProvider(r.Context(), name)
+		dash.audit(r, "provider reload", name)(err)
+		switch {
+		case errors.Is(err, proxymanager.ErrTargetProviderNotFound):
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusNotFound)
+		case errors.Is(err, proxymanager.ErrReloadUnsupported):
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusConflict)
+		case err != nil:
+			dash.Log.Error().Err(err).Str("provider", name).Msg("Error reloading target provider")
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// planAPIHandler returns the changes that the configuration file in the
+// request body would make to the proxies of a target provider, without
+// applying them.
+func (dash *Dashboard) planAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPlanRequest))
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		plan, err := dash.pm.Plan(name, data)
+		switch {
+		case errors.Is(err, proxymanager.ErrTargetProviderNotFound):
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusNotFound)
+		case errors.Is(err, proxymanager.ErrPlanUnsupported):
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusConflict)
+		case err != nil:
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusUnprocessableEntity)
+		default:
+			dash.HTTP.JSONResponse(w, r, plan)
+		}
+	}
+}
+
+// cachePurgeAPIHandler removes the cached responses of a proxy, only the
+// ones with a path matching the path query parameter when it's set.
+func (dash *Dashboard) cachePurgeAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		pattern := r.URL.Query().Get("path")
+
+		p, ok := dash.pm.GetProxy(name)
+		if !ok {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: proxymanager.ErrProxyNotFound.Error()}, http.StatusNotFound)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("proxy", name).Str("path", pattern).Str("username", user.Username).Msg("response cache purge")
+
+		purged := p.PurgeCache(pattern)
+		dash.audit(r, "proxy cache purge", name)(nil)
+
+		dash.HTTP.JSONResponse(w, r, cachePurgeResponse{Purged: purged})
+	}
+}
+
+// apiProxies method returns the proxies of the API. Proxies hidden in the
+// dashboard are only returned to admins.
+func (dash *Dashboard) apiProxies(r *http.Request) proxymanager.ProxyList {
+	proxies := dash.pm.GetProxies()
+
+	if user, ok := UserFromContext(r.Context()); ok && user.Role.Allows(RoleAdmin) {
+		return proxies
+	}
+
+	maps.DeleteFunc(proxies, func(_ string, p *proxymanager.Proxy) bool {
+		return !p.Config().Dashboard.Visible
+	})
+
+	return proxies
+}
+
+// proxyResponse method returns a proxy in the API.
+func (dash *Dashboard) proxyResponse(name string, p *proxymanager.Proxy) proxyResponse {
+	status := p.GetStatus()
+
+	res := proxyResponse{
+		Name:           name,
+		Label:          proxyLabel(name, p),
+		Status:         status.String(),
+		StatusChanged:  p.GetStatusChanged(),
+		Group:          p.Config().Dashboard.Group,
+		TargetProvider: p.Config().TargetProvider,
+		ProxyProvider:  p.Config().ProxyProvider,
+		Ports:          slices.Sorted(maps.Keys(p.Config().Ports)),
+		PortErrors:     p.GetPortErrors(),
+		Disabled:       dash.pm.IsDisabled(name),
+	}
+
+	switch status {
+	case model.ProxyStatusRunning:
+		res.URL = p.GetURL()
+	case model.ProxyStatusAuthenticating:
+		res.AuthURL = p.GetAuthURL()
+	}
+
+	if uptime, ok := dash.pm.GetUptime(name, time.Now().AddDate(0, 0, -uptimeDays)); ok {
+		res.Uptime = &uptime
+	}
+	if m, ok := dash.pm.GetMaintenance(name); ok {
+		res.Maintenance = &m
+	}
+
+	if res.Disabled {
+		res.Status = "Disabled"
+	}
+
+	return res
+}
@@ -4,12 +4,22 @@
 package dashboard
 
 import (
+	"context"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/audit"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/auth"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/components"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/updates"
 	"github.com/yichenchong/tsdproxy-cloudflare/web"
 
 	"github.com/rs/zerolog"
@@ -19,57 +29,194 @@ type Dashboard struct {
 	Log        zerolog.Logger
 	HTTP       *core.HTTPServer
 	pm         *proxymanager.ProxyManager
+	auth       *authenticator
 	sseClients map[string]*sseClient
-	mtx        sync.RWMutex
+	// updates are the proxy events streamed to the clients, only subscribed
+	// while clients are connected
+	updates <-chan model.ProxyEvent
+	// letsEncrypt is the certificate manager, nil if Let's Encrypt is disabled
+	letsEncrypt LetsEncrypt
+	// updateChecker checks the releases of the update channel
+	updateChecker *updates.Checker
+	// auditLog records the administrative actions, nil if disabled
+	auditLog *audit.Log
+	embed    embedSigner
+	// done is closed when the server shuts down, to end the streams
+	done      chan struct{}
+	closeOnce sync.Once
+	mtx       sync.RWMutex
 }
 
 func NewDashboard(http *core.HTTPServer, log zerolog.Logger, pm *proxymanager.ProxyManager) *Dashboard {
 	dash := &Dashboard{
-		Log:        log.With().Str("module", "dashboard").Logger(),
+		Log:        core.ModuleLogger(log, "dashboard"),
 		HTTP:       http,
 		pm:         pm,
+		auth:       newAuthenticator(core.ModuleLogger(log, "dashboard"), pm, config.Config.Dashboard.Auth),
 		sseClients: make(map[string]*sseClient),
+		done:       make(chan struct{}),
 	}
 
-	go dash.streamProxyUpdates()
-
 	return dash
 }
 
 // AddRoutes method add dashboard related routes to the http server
 func (dash *Dashboard) AddRoutes() {
-	dash.HTTP.Get("/stream", dash.streamHandler())
+	admin := requireRole(RoleAdmin)
+
+	// the streams never end, the server waits for them on shutdown
+	dash.HTTP.OnShutdown(dash.closeStreams)
+
+	dash.HTTP.Get(loginPath, dash.loginPageHandler())
+	dash.HTTP.Post(loginPath, dash.loginHandler())
+	dash.HTTP.Get(loginOIDCPath, dash.oidcLoginHandler())
+	dash.HTTP.Get(auth.CallbackPath, dash.oidcLoginHandler())
+	dash.HTTP.Post(logoutPath, dash.logoutHandler())
+
+	dash.HTTP.Get("/stream", dash.auth.middleware(dash.streamHandler()))
+	dash.HTTP.Get("/discovered", dash.auth.middleware(dash.discoveredHandler()))
+	dash.HTTP.Post("/discovered/{provider}/{id}/approve", dash.auth.middleware(admin(dash.approveHandler())))
+	dash.HTTP.Get("/api/v1/status", dash.auth.middleware(dash.statusAPIHandler()))
+	dash.HTTP.Get("/api/v1/version", dash.auth.middleware(dash.versionAPIHandler()))
+	dash.HTTP.Get("/api/v1/audit", dash.auth.middleware(admin(dash.auditAPIHandler())))
+	dash.HTTP.Get("/api/v1/log/level", dash.auth.middleware(admin(dash.logLevelAPIHandler())))
+	dash.HTTP.Put("/api/v1/log/level", dash.auth.middleware(admin(dash.setLogLevelAPIHandler())))
+	dash.HTTP.Get("/api/v1/proxies", dash.auth.middleware(dash.proxiesAPIHandler()))
+	dash.HTTP.Get("/api/v1/proxies/{name}", dash.auth.middleware(dash.proxyAPIHandler()))
+	dash.HTTP.Post("/api/v1/proxies/{name}/restart", dash.auth.middleware(admin(dash.restartHandler())))
+	dash.HTTP.Post("/api/v1/proxies/{name}/cache/purge", dash.auth.middleware(admin(dash.cachePurgeAPIHandler())))
+	dash.HTTP.Post("/api/v1/proxies/{name}/maintenance", dash.auth.middleware(admin(dash.maintenanceAPIHandler())))
+	dash.HTTP.Delete("/api/v1/proxies/{name}/maintenance", dash.auth.middleware(admin(dash.endMaintenanceAPIHandler())))
+	dash.HTTP.Get("/api/v1/proxies/{name}/logs", dash.auth.middleware(dash.logsAPIHandler()))
+	dash.HTTP.Get("/api/v1/certs", dash.auth.middleware(dash.certsAPIHandler()))
+	dash.HTTP.Get("/api/v1/state", dash.auth.middleware(admin(dash.stateAPIHandler())))
+	// the metrics have the names of the proxies and targets, scrapers use an
+	// admin API key
+	dash.HTTP.Get(metricsPath, dash.auth.middleware(admin(metrics.Handler())))
+	dash.HTTP.Get("/api/v1/letsencrypt", dash.auth.middleware(dash.letsEncryptAPIHandler()))
+	dash.HTTP.Post("/api/v1/letsencrypt/renew", dash.auth.middleware(admin(dash.letsEncryptRenewHandler())))
+	dash.HTTP.Get("/api/v1/letsencrypt/account/key", dash.auth.middleware(admin(dash.letsEncryptAccountKeyHandler())))
+	dash.HTTP.Post("/api/v1/letsencrypt/account/rollover",
+		dash.auth.middleware(admin(dash.letsEncryptAccountHandler("rollover", LetsEncrypt.RolloverAccountKey))))
+	dash.HTTP.Post("/api/v1/letsencrypt/account/deactivate",
+		dash.auth.middleware(admin(dash.letsEncryptAccountHandler("deactivate", LetsEncrypt.DeactivateAccount))))
+	dash.HTTP.Post("/api/v1/providers/{name}/reload", dash.auth.middleware(admin(dash.reloadProviderAPIHandler())))
+	dash.HTTP.Post("/api/v1/providers/{name}/plan", dash.auth.middleware(admin(dash.planAPIHandler())))
+	dash.HTTP.Get("/api/v1/proxies/{name}/history", dash.auth.middleware(dash.historyAPIHandler()))
+	dash.HTTP.Get("/api/v1/proxies/{name}/stats", dash.auth.middleware(dash.proxyStatsAPIHandler()))
+	dash.HTTP.Get("/api/v1/stats", dash.auth.middleware(dash.statsAPIHandler()))
+	dash.HTTP.Get("/api/v1/proxies/{name}/authurl", dash.auth.middleware(dash.authURLAPIHandler()))
+	dash.HTTP.Get("/api/v1/banners", dash.auth.middleware(dash.bannersAPIHandler()))
+	dash.HTTP.Post("/api/v1/banners", dash.auth.middleware(admin(dash.addBannerAPIHandler())))
+	dash.HTTP.Delete("/api/v1/banners/{id}", dash.auth.middleware(admin(dash.deleteBannerAPIHandler())))
+	dash.HTTP.Get("/api/v1/problems", dash.auth.middleware(dash.problemsAPIHandler()))
+	dash.HTTP.Post("/api/v1/embed", dash.auth.middleware(admin(dash.embedAPIHandler())))
+	dash.HTTP.Get(embedPath+"{token}", dash.embedHandler())
+	dash.HTTP.Get(embedPath+"{token}/icon", dash.embedIconHandler())
+	dash.HTTP.Get("/lists", dash.auth.middleware(admin(dash.listsHandler())))
+	dash.HTTP.Get("/lists/{name}", dash.auth.middleware(admin(dash.listEditorHandler())))
+	dash.HTTP.Post("/lists/{name}", dash.auth.middleware(admin(dash.listSaveHandler())))
+	dash.HTTP.Get("/banners", dash.auth.middleware(admin(dash.bannersHandler())))
+	dash.HTTP.Post("/banners", dash.auth.middleware(admin(dash.bannerAddHandler())))
+	dash.HTTP.Post("/banners/{id}/delete", dash.auth.middleware(admin(dash.bannerDeleteHandler())))
+	dash.HTTP.Get("/problems", dash.auth.middleware(dash.problemsHandler()))
+	dash.HTTP.Get("/problems/list", dash.auth.middleware(dash.problemListHandler()))
+	dash.HTTP.Get("/proxies/list", dash.auth.middleware(dash.listHandler()))
+	dash.HTTP.Get("/proxy/{name}", dash.auth.middleware(dash.detailHandler()))
+	dash.HTTP.Get("/proxy/{name}/stream", dash.auth.middleware(dash.detailStreamHandler()))
+	dash.HTTP.Get("/proxies/{name}/icon", dash.auth.middleware(dash.iconHandler()))
+	dash.HTTP.Post("/proxies/{name}/stop", dash.auth.middleware(admin(dash.stopHandler())))
+	dash.HTTP.Post("/proxies/{name}/restart", dash.auth.middleware(admin(dash.restartHandler())))
+	dash.HTTP.Post("/proxies/{name}/disable", dash.auth.middleware(admin(dash.disableHandler())))
+	dash.HTTP.Post("/proxies/{name}/enable", dash.auth.middleware(admin(dash.enableHandler())))
+	dash.HTTP.Post("/proxies/{name}/maintenance", dash.auth.middleware(admin(dash.maintenanceHandler())))
+	dash.HTTP.Post("/proxies/{name}/maintenance/end", dash.auth.middleware(admin(dash.endMaintenanceHandler())))
+
+	if !config.Config.Debug.Disabled {
+		dash.addDebugRoutes(admin)
+	}
+
+	// static assets are public, the index requires login
+	dash.HTTP.Get("/{$}", dash.auth.middleware(web.Static))
 	dash.HTTP.Get("/", web.Static)
 }
 
 // index is the HandlerFunc to index page of dashboard
-func (dash *Dashboard) renderList(ch chan SSEMessage) {
+func (dash *Dashboard) renderList(client *sseClient) {
 	dash.mtx.RLock()
 	defer dash.mtx.RUnlock()
 
+	client.render.Lock()
+	defer client.render.Unlock()
+
 	// force remove elements of proxy-list inn case of client reconnect
-	ch <- SSEMessage{
+	client.channel <- SSEMessage{
 		Type:    EventRemoveMessage,
 		Message: "#proxy-list>*",
 	}
+	client.resetGroups()
 
-	proxies := dash.pm.GetProxies()
-	_ = proxies
-	for name, p := range dash.pm.Proxies {
-		if p.Config.Dashboard.Visible {
-			dash.renderProxy(ch, name, EventAppend)
+	type entry struct {
+		name string
+		key  string
+	}
+
+	view := client.getView()
+	entries := []entry{}
+
+	for name, p := range dash.pm.GetProxies() {
+		label := proxyLabel(name, p)
+		if view.match(name, label, p) {
+			entries = append(entries, entry{name: name, key: view.sortKey(name, label, p)})
 		}
 	}
 
-	dash.streamSortList(ch)
+	slices.SortFunc(entries, func(a, b entry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	for _, e := range entries {
+		dash.sendProxy(client, e.name, EventAppend)
+	}
+
+	client.channel <- SSEMessage{
+		Type: EventMerge,
+		Comp: pages.Warnings(dash.getWarnings(context.Background())),
+	}
+	dash.renderStartupProgress(client.channel)
+
+	dash.renderFilters(client)
+	dash.streamSortList(client.channel)
+}
+
+// renderProxy method sends a proxy to the client, proxies that don't match
+// the client view are removed.
+func (dash *Dashboard) renderProxy(client *sseClient, name string, ev EventType) {
+	client.render.Lock()
+	defer client.render.Unlock()
+
+	dash.sendProxy(client, name, ev)
 }
 
-func (dash *Dashboard) renderProxy(ch chan SSEMessage, name string, ev EventType) {
+func (dash *Dashboard) sendProxy(client *sseClient, name string, ev EventType) {
 	p, ok := dash.pm.GetProxy(name)
 	if !ok {
 		return
 	}
 
+	label := proxyLabel(name, p)
+	view := client.getView()
+
+	if !view.match(name, label, p) {
+		if client.forget(name) {
+			client.channel <- SSEMessage{
+				Type:    EventRemoveMessage,
+				Message: "#" + name,
+			}
+		}
+		return
+	}
+
 	status := p.GetStatus()
 
 	url := p.GetURL()
@@ -77,24 +224,27 @@ func (dash *Dashboard) renderProxy(ch chan SSEMessage, name string, ev EventType
 		url = p.GetAuthURL()
 	}
 
-	icon := p.Config.Dashboard.Icon
-	if icon == "" {
-		icon = model.DefaultDashboardIcon
-	}
+	icon := proxyIcon(p)
+	iconURL := proxyIconURL(name, p)
 
-	label := p.Config.Dashboard.Label
-	if label == "" {
-		label = name
-	}
-
-	ports := make([]model.PortConfig, len(p.Config.Ports))
+	ports := make([]model.PortConfig, len(p.Config().Ports))
 	i := 0
-	for _, target := range p.Config.Ports {
+	for _, target := range p.Config().Ports {
 		ports[i] = target
 		i++
 	}
+	// sorted, so the card renders the same while the ports don't change
+	slices.SortFunc(ports, func(a, b model.PortConfig) int {
+		return strings.Compare(a.String(), b.String())
+	})
 
 	enabled := status == model.ProxyStatusAuthenticating || status == model.ProxyStatusRunning
+	_, inMaintenance := dash.pm.GetMaintenance(name)
+
+	certExpiry := ""
+	if notAfter, ok := dash.pm.CertExpiring(name); ok {
+		certExpiry = notAfter.Local().Format(time.DateOnly)
+	}
 
 	a := pages.ProxyData{
 		Enabled:     enabled,
@@ -102,12 +252,94 @@ func (dash *Dashboard) renderProxy(ch chan SSEMessage, name string, ev EventType
 		URL:         url,
 		ProxyStatus: status,
 		Icon:        icon,
+		IconURL:     iconURL,
 		Label:       label,
 		Ports:       ports,
+		PortErrors:  p.GetPortErrors(),
+		Protocols:   p.GetProtocols(),
+		Health:      portHealth(p),
+		Uptime:      dash.uptimeLabel(name),
+		CertExpiry:  certExpiry,
+
+		ProxyProvider: p.Config().ProxyProvider,
+		Tailnet:       p.GetTailnet(),
+
+		Disabled:    dash.pm.IsDisabled(name),
+		Maintenance: inMaintenance,
+		CanManage:   client.user.Role.Allows(RoleAdmin),
+		SortKey:     view.sortKey(name, label, p),
 	}
 
-	ch <- SSEMessage{
-		Type: ev,
-		Comp: pages.Proxy(a),
+	group := p.Config().Dashboard.Group
+	placed := dash.placeProxy(client, name, group, ev)
+
+	dash.sendCard(client, a, placed, groupSelector(group))
+
+	// the proxy was moved to other group or its sort key may have changed
+	if placed != ev || (ev == EventMerge && view.Sort != SortByName) {
+		dash.streamSortList(client.channel)
 	}
 }
+
+// portHealth function returns the health of the ports with health check:
+// healthy, degraded with some targets down, or down.
+func portHealth(p *proxymanager.Proxy) map[string]string {
+	health := make(map[string]string)
+	for name, targets := range p.GetTargetHealth() {
+		checked, down := false, 0
+		for _, t := range targets {
+			checked = checked || t.Check != ""
+			if t.Down {
+				down++
+			}
+		}
+		if !checked {
+			continue
+		}
+
+		switch down {
+		case 0:
+			health[name] = "healthy"
+		case len(targets):
+			health[name] = "down"
+		default:
+			health[name] = "degraded"
+		}
+	}
+
+	return health
+}
+
+// proxyIcon function returns the icon of the proxy in the dashboard.
+func proxyIcon(p *proxymanager.Proxy) string {
+	if p.Config().Dashboard.Icon == "" {
+		return model.DefaultDashboardIcon
+	}
+
+	return p.Config().Dashboard.Icon
+}
+
+// proxyIconURL function returns the icon URL of the proxy in the dashboard.
+func proxyIconURL(name string, p *proxymanager.Proxy) string {
+	// use the icon fetched from the target application
+	if m := p.GetMetadata(); m != nil && m.Icon != nil {
+		return "/proxies/" + name + "/icon"
+	}
+
+	return components.IconURL(proxyIcon(p))
+}
+
+// proxyLabel function returns the label of the proxy in the dashboard.
+func proxyLabel(name string, p *proxymanager.Proxy) string {
+	label := p.Config().Dashboard.Label
+	if label == "" {
+		label = name
+	}
+
+	// use the title fetched from the target application
+	if m := p.GetMetadata(); m != nil && m.Title != "" {
+		label = m.Title
+	}
+
+	return label
+}
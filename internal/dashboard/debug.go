@@ -0,0 +1,229 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/updates"
+)
+
+const (
+	// profileDir is the directory of the captured profiles in the data
+	// directory.
+	profileDir = "profiles"
+	// maxProfileRequest is the maximum size of a profile request.
+	maxProfileRequest = 4 << 10
+)
+
+// startTime is the start of the server, for its uptime.
+var startTime = time.Now()
+
+type (
+	// profileRequest struct is the API request to capture a profile.
+	profileRequest struct {
+		Kind string `json:"kind"`
+		// Duration is the duration of the cpu and trace profiles, like 30s
+		Duration string `json:"duration,omitempty"`
+	}
+
+	// debugStatusResponse struct is a snapshot of the internal state of
+	// the server.
+	debugStatusResponse struct {
+		Started         time.Time            `json:"started"`
+		LetsEncrypt     *letsEncryptResponse `json:"letsEncrypt,omitempty"`
+		LogModules      map[string]string    `json:"logModules"`
+		Updates         updates.Status       `json:"updates"`
+		Build           core.BuildInfo       `json:"build"`
+		Uptime          string               `json:"uptime"`
+		LogLevel        string               `json:"logLevel"`
+		Proxies         []proxyResponse      `json:"proxies"`
+		TargetProviders []providerResponse   `json:"targetProviders"`
+		Problems        []problems.Problem   `json:"problems"`
+		Warnings        []string             `json:"warnings"`
+		Runtime         runtimeStatus        `json:"runtime"`
+		Streams         int                  `json:"streams"`
+		Audit           bool                 `json:"audit"`
+	}
+
+	// runtimeStatus struct is the state of the Go runtime.
+	runtimeStatus struct {
+		LastGC       *time.Time `json:"lastGC,omitempty"`
+		GCPauseTotal string     `json:"gcPauseTotal"`
+		Goroutines   int        `json:"goroutines"`
+		CPUs         int        `json:"cpus"`
+		GOMAXPROCS   int        `json:"gomaxprocs"`
+		HeapAlloc    uint64     `json:"heapAlloc"`
+		HeapInuse    uint64     `json:"heapInuse"`
+		HeapObjects  uint64     `json:"heapObjects"`
+		Sys          uint64     `json:"sys"`
+		NumGC        uint32     `json:"numGC"`
+	}
+)
+
+// addDebugRoutes method adds the pprof and diagnostics routes, only for
+// admins.
+func (dash *Dashboard) addDebugRoutes(admin func(next http.Handler) http.Handler) {
+	adminOnly := func(next http.Handler) http.Handler {
+		return dash.auth.middleware(admin(next))
+	}
+
+	core.PprofAddRoutes(dash.HTTP, adminOnly)
+	dash.HTTP.Get("/debug/status", adminOnly(dash.debugStatusHandler()))
+	dash.HTTP.Get("/api/v1/debug/profiles", adminOnly(dash.profilesAPIHandler()))
+	dash.HTTP.Post("/api/v1/debug/profiles", adminOnly(dash.captureProfileAPIHandler()))
+	dash.HTTP.Get("/api/v1/debug/profiles/{name}", adminOnly(dash.profileDownloadHandler()))
+}
+
+// debugStatusHandler returns a snapshot of the internal state of the
+// server: runtime, proxies, providers, problems, certificates and logging.
+func (dash *Dashboard) debugStatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		level, modules := core.LogLevels()
+
+		res := debugStatusResponse{
+			Build:           core.GetBuildInfo(),
+			Started:         startTime,
+			Uptime:          time.Since(startTime).Round(time.Second).String(),
+			Runtime:         newRuntimeStatus(),
+			LogLevel:        level,
+			LogModules:      modules,
+			Proxies:         []proxyResponse{},
+			TargetProviders: dash.providersResponse(),
+			Problems:        dash.pm.Problems().List(),
+			Warnings:        dash.getWarnings(r.Context()),
+			Updates:         dash.getUpdates().Status(),
+			Audit:           dash.getAudit() != nil,
+		}
+
+		proxies := dash.pm.GetProxies()
+		for _, name := range slices.Sorted(maps.Keys(proxies)) {
+			res.Proxies = append(res.Proxies, dash.proxyResponse(name, proxies[name]))
+		}
+
+		if le := dash.getLetsEncrypt(); le != nil {
+			status := newLetsEncryptResponse(le.Status(r.Context()))
+			res.LetsEncrypt = &status
+		}
+
+		dash.mtx.RLock()
+		res.Streams = len(dash.sseClients)
+		dash.mtx.RUnlock()
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// profilesAPIHandler returns the captured profiles, newest first.
+func (dash *Dashboard) profilesAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		files, err := core.ListProfiles(profilesPath())
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		dash.HTTP.JSONResponse(w, r, files)
+	}
+}
+
+// captureProfileAPIHandler captures a profile to a file in the data
+// directory. The cpu and trace profiles respond after their duration.
+func (dash *Dashboard) captureProfileAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req profileRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxProfileRequest)).Decode(&req); err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		var d time.Duration
+		if req.Duration != "" {
+			var err error
+			if d, err = time.ParseDuration(req.Duration); err != nil {
+				dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+
+		file, err := core.CaptureProfile(r.Context(), profilesPath(), req.Kind, d)
+		dash.audit(r, "debug profile", req.Kind)(err)
+		switch {
+		case errors.Is(err, core.ErrInvalidProfile):
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		case errors.Is(err, core.ErrProfileRunning):
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusConflict)
+			return
+		case err != nil:
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("profile", file.Name).Str("username", user.Username).Msg("profile captured")
+
+		dash.HTTP.JSONResponse(w, r, file)
+	}
+}
+
+// profileDownloadHandler returns a captured profile.
+func (dash *Dashboard) profileDownloadHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, err := core.ProfilePath(profilesPath(), r.PathValue("name"))
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: "profile not found"}, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(path)+`"`)
+		http.ServeFile(w, r, path)
+	}
+}
+
+// profilesPath function returns the directory of the captured profiles.
+func profilesPath() string {
+	return filepath.Join(config.Config.Tailscale.DataDir, profileDir)
+}
+
+// newRuntimeStatus function returns the state of the Go runtime.
+func newRuntimeStatus() runtimeStatus {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	s := runtimeStatus{
+		Goroutines:   runtime.NumGoroutine(),
+		CPUs:         runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapObjects:  m.HeapObjects,
+		Sys:          m.Sys,
+		NumGC:        m.NumGC,
+		GCPauseTotal: time.Duration(m.PauseTotalNs).String(), //nolint:gosec
+	}
+	if m.LastGC > 0 {
+		lastGC := time.Unix(0, int64(m.LastGC)) //nolint:gosec
+		s.LastGC = &lastGC
+	}
+
+	return s
+}
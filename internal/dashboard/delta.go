@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+
+	"github.com/a-h/templ"
+)
+
+var cardUpdates = metrics.NewCounter(
+	"tsdproxy_dashboard_card_updates_total",
+	"Proxy card updates sent to the dashboard clients, by the part of the card sent.",
+	"part",
+)
+
+// cardParts struct stores the parts of a proxy card sent to a client.
+type cardParts struct {
+	// shell are the fields rendered outside the parts, a change sends the
+	// whole card
+	shell string
+	state string
+	ports string
+	// stats are the connection statistics signals sent to the client, sent
+	// again when the whole card is sent
+	stats map[string]string
+}
+
+// sendCard method sends the card of a proxy to the client. Updates of a
+// card already in the client only send the parts that changed, or nothing
+// if the card didn't change, since status events are frequent.
+func (dash *Dashboard) sendCard(client *sseClient, a pages.ProxyData, ev EventType, selector string) {
+	parts, err := renderCard(a)
+
+	client.mtx.Lock()
+	old, known := client.cards[a.Name]
+	if err == nil {
+		if ev == EventMerge && known && old.shell == parts.shell {
+			parts.stats = old.stats
+		}
+		client.cards[a.Name] = parts
+	} else {
+		delete(client.cards, a.Name)
+	}
+	client.mtx.Unlock()
+
+	if err != nil {
+		dash.Log.Error().Err(err).Str("proxy", a.Name).Msg("Error rendering proxy card")
+	}
+
+	if ev != EventMerge || !known || err != nil || old.shell != parts.shell {
+		cardUpdates.Inc("card")
+		client.channel <- SSEMessage{
+			Type:     ev,
+			Comp:     pages.Proxy(a),
+			Selector: selector,
+		}
+		return
+	}
+
+	if old.state != parts.state {
+		cardUpdates.Inc("state")
+		client.channel <- SSEMessage{
+			Type:    EventMergeMessage,
+			Message: parts.state,
+		}
+	}
+	if old.ports != parts.ports {
+		cardUpdates.Inc("ports")
+		client.channel <- SSEMessage{
+			Type:    EventMergeMessage,
+			Message: parts.ports,
+		}
+	}
+}
+
+// renderCard function renders the parts of the card of a proxy.
+func renderCard(a pages.ProxyData) (cardParts, error) {
+	state, err := renderString(pages.ProxyState(a))
+	if err != nil {
+		return cardParts{}, err
+	}
+	ports, err := renderString(pages.ProxyPorts(a))
+	if err != nil {
+		return cardParts{}, err
+	}
+
+	return cardParts{
+		shell: strings.Join([]string{
+			a.Name, a.Icon, a.IconURL, a.Label, a.SortKey, a.ProxyProvider, a.Tailnet,
+		}, "\x00"),
+		state: state,
+		ports: ports,
+	}, nil
+}
+
+// renderString function renders a component to a string.
+func renderString(c templ.Component) (string, error) {
+	var buf bytes.Buffer
+	if err := c.Render(context.Background(), &buf); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
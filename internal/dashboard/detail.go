@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+
+	datastar "github.com/starfederation/datastar/sdk/go"
+)
+
+const (
+	// detailRefreshInterval is the interval to refresh the node details
+	detailRefreshInterval = 30 * time.Second
+	// maxDetailLogLines is the number of access log lines shown in the detail page
+	maxDetailLogLines = 100
+
+	timeFormat = "2006-01-02 15:04:05"
+)
+
+// detailHandler returns the detail page of a proxy
+func (dash *Dashboard) detailHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		p, ok := dash.pm.GetProxy(name)
+		if !ok || !p.Config().Dashboard.Visible {
+			http.NotFound(w, r)
+			return
+		}
+
+		data := pages.ProxyDetailData{
+			Name:    name,
+			Label:   proxyLabel(name, p),
+			IconURL: proxyIconURL(name, p),
+			URL:     p.GetURL(),
+		}
+
+		if err := ui.RenderTempl(w, r, pages.ProxyDetail(data)); err != nil {
+			dash.Log.Error().Err(err).Msg("Error rendering proxy detail page")
+		}
+	}
+}
+
+// detailStreamHandler streams the status, node details and access log of a proxy
+func (dash *Dashboard) detailStreamHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		p, ok := dash.pm.GetProxy(name)
+		if !ok || !p.Config().Dashboard.Visible {
+			http.NotFound(w, r)
+			return
+		}
+
+		sse := datastar.NewSSE(w, r)
+
+		events := dash.pm.SubscribeStatusEvents()
+		defer dash.pm.UnsubscribeStatusEvents(events)
+
+		// subscribe before reading the kept lines, so no line is lost
+		tail := p.GetAccessLogTail()
+		lines := tail.Subscribe()
+		defer func() { tail.Unsubscribe(lines) }()
+
+		count := len(tail.Lines())
+		err := sse.MergeFragmentTempl(pages.AccessLog(tail.Lines(), !p.Config().ProxyAccessLog))
+		if err == nil {
+			err = sse.MergeFragmentTempl(pages.ProxyInfo(dash.proxyInfo(r.Context(), p)))
+		}
+
+		ticker := time.NewTicker(detailRefreshInterval)
+		defer ticker.Stop()
+
+		for err == nil {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-ticker.C:
+				err = sse.MergeFragmentTempl(pages.ProxyInfo(dash.proxyInfo(r.Context(), p)))
+
+			case line := <-lines:
+				err = sse.MergeFragmentTempl(pages.AccessLogLine(line),
+					datastar.WithMergeMode(datastar.FragmentMergeModeAppend),
+					datastar.WithSelector("#access-log"),
+				)
+				count++
+				if err == nil && count > maxDetailLogLines {
+					err = sse.RemoveFragments("#access-log>.line:first-child")
+					count--
+				}
+
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.ID != name {
+					continue
+				}
+
+				// restarted proxies are new instances with a new access log tail
+				if newProxy, ok := dash.pm.GetProxy(name); ok && newProxy != p {
+					tail.Unsubscribe(lines)
+					p = newProxy
+					tail = p.GetAccessLogTail()
+					lines = tail.Subscribe()
+
+					count = len(tail.Lines())
+					err = sse.MergeFragmentTempl(pages.AccessLog(tail.Lines(), !p.Config().ProxyAccessLog))
+					if err != nil {
+						break
+					}
+				}
+
+				err = sse.MergeFragmentTempl(pages.ProxyInfo(dash.proxyInfo(r.Context(), p)))
+			}
+		}
+
+		dash.Log.Debug().Err(err).Str("proxy", name).Msg("proxy detail stream closed")
+	}
+}
+
+// proxyInfo method returns the status, node details and configuration of a proxy.
+// Secrets like auth keys are not included.
+func (dash *Dashboard) proxyInfo(ctx context.Context, p *proxymanager.Proxy) pages.ProxyInfoData {
+	status := p.GetStatus()
+
+	data := pages.ProxyInfoData{
+		Status: status.String(),
+		Uptime: dash.uptimeLabel(p.Config().Hostname),
+	}
+	if dash.pm.IsDisabled(p.Config().Hostname) {
+		data.Status = "Disabled"
+	} else if _, ok := dash.pm.GetMaintenance(p.Config().Hostname); ok {
+		data.Status = "Maintenance"
+	}
+
+	for _, h := range p.GetStatusHistory() {
+		data.History = append(data.History, pages.StatusChangeData{
+			Time:   h.Time.Format(timeFormat),
+			Status: h.Status.String(),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if node, err := p.GetNodeInfo(ctx); err != nil {
+		data.NodeError = err.Error()
+	} else {
+		keyExpiry := "never"
+		if node.KeyExpiry != nil {
+			keyExpiry = node.KeyExpiry.Local().Format(timeFormat)
+		}
+
+		data.Node = []pages.InfoItem{
+			{Name: "DNS name", Value: node.DNSName},
+			{Name: "IPs", Value: strings.Join(node.IPs, ", ")},
+			{Name: "Online", Value: strconv.FormatBool(node.Online)},
+			{Name: "Key expiry", Value: keyExpiry},
+		}
+	}
+
+	cfg := p.Config()
+	accessLog := "disabled"
+	if cfg.ProxyAccessLog {
+		accessLog = cfg.AccessLog.Format + " (" + cfg.AccessLog.Sink + ")"
+	}
+
+	data.Config = []pages.InfoItem{
+		{Name: "Hostname", Value: cfg.Hostname},
+		{Name: "Target provider", Value: cfg.TargetProvider},
+		{Name: "Proxy provider", Value: cfg.ProxyProvider},
+		{Name: "Tailnet", Value: p.GetTailnet()},
+		{Name: "Tags", Value: cfg.Tailscale.Tags},
+		{Name: "Ephemeral", Value: strconv.FormatBool(cfg.Tailscale.Ephemeral)},
+		{Name: "Group", Value: cfg.Dashboard.Group},
+		{Name: "Access log", Value: accessLog},
+	}
+
+	for _, field := range slices.Sorted(maps.Keys(cfg.Provenance)) {
+		data.Sources = append(data.Sources, pages.InfoItem{Name: field, Value: cfg.Provenance[field]})
+	}
+
+	health := p.GetTargetHealth()
+	protocols := p.GetProtocols()
+	for name, port := range cfg.Ports {
+		pd := pages.PortData{Name: name, Protocol: protocols[name]}
+		for i, t := range port.GetTargets() {
+			td := pages.TargetData{URL: t.Redacted()}
+			if h := health[name]; i < len(h) {
+				td.Health = targetHealthLabel(h[i])
+			}
+			pd.Targets = append(pd.Targets, td)
+		}
+		data.Ports = append(data.Ports, pd)
+	}
+	slices.SortFunc(data.Ports, func(a, b pages.PortData) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return data
+}
+
+// targetHealthLabel function returns the health of a target in the details
+// page, with its circuit breaker and the result of its health check.
+func targetHealthLabel(h model.TargetHealth) string {
+	label := fmt.Sprintf("score %.2f, latency %s, errors %.0f%%, %d requests",
+		h.Score, h.Latency.Round(time.Millisecond), h.ErrorRate*100, h.Requests)
+	if h.Breaker != "" {
+		label += ", circuit breaker " + h.Breaker
+	}
+	if h.Check == "" {
+		return label
+	}
+
+	state := "up"
+	if h.Down {
+		state = "down"
+	}
+	label += fmt.Sprintf(", %s check %s after %d checks", h.Check, state, h.Checks)
+	if h.LastError != "" {
+		label += ": " + h.LastError
+	}
+
+	return label
+}
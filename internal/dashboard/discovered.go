@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+
+	datastar "github.com/starfederation/datastar/sdk/go"
+)
+
+// discoveredHandler returns the services proposed by the discovery target providers
+func (dash *Dashboard) discoveredHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dash.renderDiscovered(w, r)
+	}
+}
+
+// approveHandler approves a discovered service and refreshes the discovered list
+func (dash *Dashboard) approveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider := r.PathValue("provider")
+		id := r.PathValue("id")
+
+		err := dash.pm.ApproveDiscovered(provider, id)
+		dash.audit(r, "discovered approve", provider+"/"+id)(err)
+		if err != nil {
+			dash.Log.Error().Err(err).Str("provider", provider).Str("id", id).Msg("Error approving service")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		dash.renderDiscovered(w, r)
+	}
+}
+
+func (dash *Dashboard) renderDiscovered(w http.ResponseWriter, r *http.Request) {
+	sse := datastar.NewSSE(w, r)
+
+	user, _ := UserFromContext(r.Context())
+	canApprove := user.Role.Allows(RoleAdmin)
+
+	if err := sse.MergeFragmentTempl(pages.Discovered(dash.pm.GetDiscovered(), canApprove)); err != nil {
+		dash.Log.Error().Err(err).Msg("Error sending discovered services")
+	}
+}
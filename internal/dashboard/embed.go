@@ -0,0 +1,323 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/components"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+)
+
+const (
+	WidgetCard   = "card"
+	WidgetStatus = "status"
+
+	embedPath = "/embed/"
+	// embedKeyFile is the signing key of the embed tokens in the data
+	// directory, removing it revokes all the tokens.
+	embedKeyFile = "embed.key"
+	// defaultEmbedTTL is the lifetime of the tokens created without ttl.
+	defaultEmbedTTL = 30 * 24 * time.Hour
+	// maxEmbedRequest is the maximum size of an embed token request.
+	maxEmbedRequest = 4 << 10
+	// embedRefresh is the interval the widgets are reloaded by the browser.
+	embedRefresh = 30 * time.Second
+)
+
+var (
+	ErrEmbedDisabled = errors.New("dashboard embedding is disabled")
+	ErrEmbedWidget   = errors.New("widget must be card or status")
+	ErrEmbedTTL      = errors.New("ttl is longer than the embed maxTTL")
+)
+
+type (
+	// embedSigner struct signs and verifies the embed tokens with a key
+	// kept in the data directory.
+	embedSigner struct {
+		key  []byte
+		once sync.Once
+		err  error
+	}
+
+	// embedClaims struct is the content of an embed token, the widget of a
+	// proxy until it expires.
+	embedClaims struct {
+		Proxy   string `json:"p"`
+		Widget  string `json:"w"`
+		Expires int64  `json:"exp"`
+	}
+
+	// embedRequest struct is the API request of an embed token.
+	embedRequest struct {
+		Proxy  string `json:"proxy"`
+		Widget string `json:"widget"`
+		TTL    string `json:"ttl"`
+	}
+
+	// embedResponse struct is an embed token in the API.
+	embedResponse struct {
+		Expires time.Time `json:"expires"`
+		Token   string    `json:"token"`
+		Path    string    `json:"path"`
+	}
+)
+
+// loadKey method reads the signing key, it's created on first use.
+func (s *embedSigner) loadKey() error {
+	s.once.Do(func() {
+		filename := filepath.Join(config.Config.Tailscale.DataDir, embedKeyFile)
+
+		data, err := os.ReadFile(filename)
+		if err == nil {
+			s.key, s.err = base64.RawStdEncoding.DecodeString(strings.TrimSpace(string(data)))
+			return
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			s.err = err
+			return
+		}
+
+		s.key = make([]byte, randomBytesSize)
+		if _, s.err = rand.Read(s.key); s.err != nil {
+			return
+		}
+		s.err = os.WriteFile(filename, []byte(base64.RawStdEncoding.EncodeToString(s.key)),
+			consts.PermOwnerRead+consts.PermOwnerWrite)
+	})
+
+	return s.err
+}
+
+// sign method returns the token of the claims.
+func (s *embedSigner) sign(c embedClaims) (string, error) {
+	if err := s.loadKey(); err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// verify method returns the claims of a valid token that didn't expire.
+func (s *embedSigner) verify(token string) (embedClaims, bool) {
+	var c embedClaims
+
+	if s.loadKey() != nil {
+		return c, false
+	}
+
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return c, false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return c, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &c) != nil {
+		return c, false
+	}
+
+	return c, time.Now().Unix() < c.Expires
+}
+
+func (s *embedSigner) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+
+	return h.Sum(nil)
+}
+
+// embedAPIHandler creates an embed token of a proxy widget.
+func (dash *Dashboard) embedAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := config.Config.Dashboard.Embed
+		if !cfg.Enabled {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: ErrEmbedDisabled.Error()}, http.StatusNotFound)
+			return
+		}
+
+		var req embedRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEmbedRequest)).Decode(&req); err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		if _, ok := dash.pm.GetProxy(req.Proxy); !ok {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: proxymanager.ErrProxyNotFound.Error()}, http.StatusNotFound)
+			return
+		}
+
+		claims, err := newEmbedClaims(req, cfg.MaxTTL)
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		token, err := dash.embed.sign(claims)
+		if err != nil {
+			dash.Log.Error().Err(err).Msg("Error signing embed token")
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("proxy", req.Proxy).Str("widget", claims.Widget).
+			Str("username", user.Username).Msg("embed token created")
+		dash.audit(r, "embed create", req.Proxy)(nil)
+
+		dash.HTTP.JSONResponse(w, r, embedResponse{
+			Token:   token,
+			Path:    embedPath + token,
+			Expires: time.Unix(claims.Expires, 0).UTC(),
+		})
+	}
+}
+
+// newEmbedClaims function returns the claims of an embed token request.
+func newEmbedClaims(req embedRequest, maxTTL time.Duration) (embedClaims, error) {
+	widget := req.Widget
+	if widget == "" {
+		widget = WidgetCard
+	}
+	if widget != WidgetCard && widget != WidgetStatus {
+		return embedClaims{}, ErrEmbedWidget
+	}
+
+	ttl := min(defaultEmbedTTL, maxTTL)
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			return embedClaims{}, err
+		}
+		if ttl > maxTTL {
+			return embedClaims{}, ErrEmbedTTL
+		}
+	}
+
+	return embedClaims{
+		Proxy:   req.Proxy,
+		Widget:  widget,
+		Expires: time.Now().Add(ttl).Unix(),
+	}, nil
+}
+
+// embedHandler renders the widget of an embed token, without authentication.
+func (dash *Dashboard) embedHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PathValue("token")
+
+		p, claims, ok := dash.embedProxy(token)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		status := p.GetStatus()
+		statusLabel := status.String()
+		if dash.pm.IsDisabled(claims.Proxy) {
+			statusLabel = "Disabled"
+		} else if _, ok := dash.pm.GetMaintenance(claims.Proxy); ok {
+			statusLabel = "Maintenance"
+		}
+
+		iconURL := components.IconURL(proxyIcon(p))
+		if m := p.GetMetadata(); m != nil && m.Icon != nil {
+			iconURL = embedPath + token + "/icon"
+		}
+
+		data := pages.EmbedData{
+			Widget:  claims.Widget,
+			Label:   proxyLabel(claims.Proxy, p),
+			IconURL: iconURL,
+			Status:  statusLabel,
+			Uptime:  dash.uptimeLabel(claims.Proxy),
+			Dark:    r.URL.Query().Get("theme") == "dark",
+		}
+		if status == model.ProxyStatusRunning {
+			data.URL = p.GetURL()
+		}
+
+		embedHeaders(w)
+		if err := ui.RenderTempl(w, r, pages.Embed(data)); err != nil {
+			dash.Log.Error().Err(err).Msg("Error rendering embed")
+		}
+	}
+}
+
+// embedIconHandler returns the icon fetched from the target application of
+// the proxy of an embed token.
+func (dash *Dashboard) embedIconHandler() http.HandlerFunc {
+	icon := dash.iconHandler()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, claims, ok := dash.embedProxy(r.PathValue("token"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		r.SetPathValue("name", claims.Proxy)
+		icon(w, r)
+	}
+}
+
+// embedProxy method returns the proxy of a valid embed token.
+func (dash *Dashboard) embedProxy(token string) (*proxymanager.Proxy, embedClaims, bool) {
+	if !config.Config.Dashboard.Embed.Enabled {
+		return nil, embedClaims{}, false
+	}
+
+	claims, ok := dash.embed.verify(token)
+	if !ok {
+		return nil, claims, false
+	}
+
+	p, ok := dash.pm.GetProxy(claims.Proxy)
+
+	return p, claims, ok
+}
+
+// embedHeaders function sets the headers of the embed pages, allowed to be
+// framed by the frameAncestors. The token is in the URL, so it isn't sent
+// in the Referer of the links.
+func embedHeaders(w http.ResponseWriter) {
+	ancestors := "*"
+	if origins := config.Config.Dashboard.Embed.FrameAncestors; len(origins) > 0 {
+		ancestors = strings.Join(origins, " ")
+	}
+
+	w.Header().Set("Content-Security-Policy", "frame-ancestors "+ancestors)
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Refresh", strconv.Itoa(int(embedRefresh.Seconds())))
+}
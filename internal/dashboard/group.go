@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+)
+
+const ungroupedID = "ungrouped"
+
+// groupSelector function returns the selector where the proxies of the group are appended.
+func groupSelector(group string) string {
+	return "#" + groupID(group) + ">.proxies"
+}
+
+// groupID function returns the html id of a group section.
+func groupID(group string) string {
+	if group == "" {
+		return ungroupedID
+	}
+
+	id := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(group))
+
+	return "group-" + id
+}
+
+// groupOrder function returns the position of the group in the dashboard.
+// Configured groups are shown first, then the other groups sorted by name,
+// and proxies without group are shown last.
+func groupOrder(group string) int {
+	groups := config.Config.Dashboard.Groups
+
+	if group == "" {
+		return len(groups) + 1
+	}
+
+	if i := slices.Index(groups, group); i >= 0 {
+		return i
+	}
+
+	return len(groups)
+}
+
+// placeProxy method adds the group section of the proxy to the client if
+// not added yet. A proxy whose group changed is removed from the old group
+// and appended to the new one, the returned event must be used to render it.
+func (dash *Dashboard) placeProxy(client *sseClient, name, group string, ev EventType) EventType {
+	client.mtx.Lock()
+	old, known := client.proxyGroups[name]
+	_, hasGroup := client.groups[group]
+	client.proxyGroups[name] = group
+	client.groups[group] = struct{}{}
+	client.mtx.Unlock()
+
+	// the proxy isn't in the client, it was filtered out
+	if !known {
+		ev = EventAppend
+	}
+
+	if ev == EventMerge && old != group {
+		client.channel <- SSEMessage{
+			Type:    EventRemoveMessage,
+			Message: "#" + name,
+		}
+		ev = EventAppend
+	}
+
+	if ev == EventAppend && !hasGroup {
+		client.channel <- SSEMessage{
+			Type: EventAppend,
+			Comp: pages.Group(pages.GroupData{
+				ID:    groupID(group),
+				Name:  group,
+				Order: groupOrder(group),
+			}),
+			Selector: "#proxy-list",
+		}
+	}
+
+	return ev
+}
+
+// forget method removes a proxy from the client groups, returns true if the
+// proxy was in the client.
+func (client *sseClient) forget(name string) bool {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+
+	_, ok := client.proxyGroups[name]
+	delete(client.proxyGroups, name)
+	delete(client.cards, name)
+
+	return ok
+}
+
+// resetGroups method forgets the groups added to the client.
+func (client *sseClient) resetGroups() {
+	client.mtx.Lock()
+	client.groups = make(map[string]struct{})
+	client.proxyGroups = make(map[string]string)
+	client.cards = make(map[string]cardParts)
+	client.mtx.Unlock()
+}
@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// iconHandler returns the icon fetched from the target application of a proxy
+func (dash *Dashboard) iconHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := dash.pm.GetProxy(r.PathValue("name"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		m := p.GetMetadata()
+		if m == nil || m.Icon == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// icons may be svg, don't allow them to run scripts when opened directly
+		w.Header().Set("Content-Type", m.IconType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(m.Icon)))
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+
+		_, _ = w.Write(m.Icon)
+	}
+}
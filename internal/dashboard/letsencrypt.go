@@ -0,0 +1,258 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+var errLetsEncryptDisabled = apiError{Message: "Let's Encrypt is not enabled"}
+
+type (
+	// LetsEncrypt interface is the Let's Encrypt certificate manager of the
+	// server.
+	LetsEncrypt interface {
+		Status(ctx context.Context) model.LetsEncryptStatus
+		// Renew starts a forced renewal of the certificates in background
+		Renew() error
+		// ExportAccountKey returns the ACME account key in PEM
+		ExportAccountKey(ctx context.Context) ([]byte, error)
+		RolloverAccountKey(ctx context.Context) error
+		DeactivateAccount(ctx context.Context) error
+	}
+
+	// letsEncryptResponse struct is the Let's Encrypt state in the API.
+	letsEncryptResponse struct {
+		NextCheck    *time.Time               `json:"nextCheck,omitempty"`
+		LastRenewal  *renewalResponse         `json:"lastRenewal,omitempty"`
+		Account      accountResponse          `json:"account"`
+		Certificates []letsEncryptCertificate `json:"certificates"`
+	}
+
+	accountResponse struct {
+		URI        string `json:"uri,omitempty"`
+		Status     string `json:"status,omitempty"`
+		Error      string `json:"error,omitempty"`
+		Registered bool   `json:"registered"`
+	}
+
+	renewalResponse struct {
+		Time   time.Time `json:"time"`
+		Error  string    `json:"error,omitempty"`
+		Forced bool      `json:"forced"`
+		OK     bool      `json:"ok"`
+	}
+
+	letsEncryptCertificate struct {
+		NotBefore *time.Time          `json:"notBefore,omitempty"`
+		NotAfter  *time.Time          `json:"notAfter,omitempty"`
+		RenewAt   *time.Time          `json:"renewAt,omitempty"`
+		Health    *certHealthResponse `json:"health,omitempty"`
+		Domain    string              `json:"domain"`
+		Issuer    string              `json:"issuer,omitempty"`
+		Error     string              `json:"error,omitempty"`
+		Valid     bool                `json:"valid"`
+		// ExpiresSoon is true if the certificate expires in less than
+		// certExpiryWarning
+		ExpiresSoon bool `json:"expiresSoon"`
+	}
+
+	// certHealthResponse struct is the last chain and revocation check of a
+	// certificate.
+	certHealthResponse struct {
+		Checked time.Time `json:"checked"`
+		OCSP    string    `json:"ocsp,omitempty"`
+		Error   string    `json:"error,omitempty"`
+		Stapled bool      `json:"stapled"`
+		OK      bool      `json:"ok"`
+	}
+)
+
+// SetLetsEncrypt method sets the Let's Encrypt certificate manager, its
+// state is returned by the API.
+func (dash *Dashboard) SetLetsEncrypt(le LetsEncrypt) {
+	dash.mtx.Lock()
+	dash.letsEncrypt = le
+	dash.mtx.Unlock()
+}
+
+func (dash *Dashboard) getLetsEncrypt() LetsEncrypt {
+	dash.mtx.RLock()
+	defer dash.mtx.RUnlock()
+
+	return dash.letsEncrypt
+}
+
+// getWarnings method returns the warnings of the proxy providers and the
+// Let's Encrypt certificates expiring in less than certExpiryWarning.
+func (dash *Dashboard) getWarnings(ctx context.Context) []string {
+	warnings := dash.pm.GetWarnings()
+
+	le := dash.getLetsEncrypt()
+	if le == nil {
+		return warnings
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, certTimeout)
+	defer cancel()
+
+	for _, c := range le.Status(ctx).Certificates {
+		if c.Error == "" && time.Until(c.NotAfter) < config.Config.CertExpiryWarning {
+			warnings = append(warnings, fmt.Sprintf("The certificate of %s expires on %s.",
+				c.Domain, c.NotAfter.Local().Format(time.DateOnly)))
+		}
+	}
+
+	return warnings
+}
+
+// letsEncryptAPIHandler returns the account registration, the certificates
+// and the renewals of Let's Encrypt.
+func (dash *Dashboard) letsEncryptAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		le := dash.getLetsEncrypt()
+		if le == nil {
+			dash.HTTP.JSONResponseCode(w, r, errLetsEncryptDisabled, http.StatusNotFound)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), certTimeout)
+		defer cancel()
+
+		dash.HTTP.JSONResponse(w, r, newLetsEncryptResponse(le.Status(ctx)))
+	}
+}
+
+// letsEncryptRenewHandler starts a forced renewal of the Let's Encrypt
+// certificates, its result is the last renewal of the status.
+func (dash *Dashboard) letsEncryptRenewHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		le := dash.getLetsEncrypt()
+		if le == nil {
+			dash.HTTP.JSONResponseCode(w, r, errLetsEncryptDisabled, http.StatusNotFound)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("username", user.Username).Msg("certificate renewal")
+
+		err := le.Renew()
+		dash.audit(r, "certificate renew", "")(err)
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// letsEncryptAccountKeyHandler returns the ACME account key in PEM, to back
+// it up.
+func (dash *Dashboard) letsEncryptAccountKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		le := dash.getLetsEncrypt()
+		if le == nil {
+			dash.HTTP.JSONResponseCode(w, r, errLetsEncryptDisabled, http.StatusNotFound)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("username", user.Username).Msg("ACME account key export")
+
+		key, err := le.ExportAccountKey(r.Context())
+		dash.audit(r, "acme account export", "")(err)
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(key)
+	}
+}
+
+// letsEncryptAccountHandler runs an operation of the ACME account, like the
+// key rollover or the deactivation.
+func (dash *Dashboard) letsEncryptAccountHandler(action string, op func(LetsEncrypt, context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		le := dash.getLetsEncrypt()
+		if le == nil {
+			dash.HTTP.JSONResponseCode(w, r, errLetsEncryptDisabled, http.StatusNotFound)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("username", user.Username).Str("action", action).Msg("ACME account")
+
+		ctx, cancel := context.WithTimeout(r.Context(), certTimeout)
+		defer cancel()
+
+		err := op(le, ctx)
+		dash.audit(r, "acme account "+action, "")(err)
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// newLetsEncryptResponse function returns the API response of the state.
+func newLetsEncryptResponse(s model.LetsEncryptStatus) letsEncryptResponse {
+	res := letsEncryptResponse{
+		Account: accountResponse{
+			URI:        s.Account.URI,
+			Status:     s.Account.Status,
+			Error:      s.Account.Error,
+			Registered: s.Account.Registered,
+		},
+		Certificates: make([]letsEncryptCertificate, 0, len(s.Certificates)),
+	}
+
+	if !s.NextCheck.IsZero() {
+		res.NextCheck = &s.NextCheck
+	}
+	if !s.LastRenewal.Time.IsZero() {
+		res.LastRenewal = &renewalResponse{
+			Time:   s.LastRenewal.Time,
+			Error:  s.LastRenewal.Error,
+			Forced: s.LastRenewal.Forced,
+			OK:     s.LastRenewal.Error == "",
+		}
+	}
+
+	now := time.Now()
+	for _, c := range s.Certificates {
+		cert := letsEncryptCertificate{Domain: c.Domain, Error: c.Error}
+		if c.Error == "" {
+			cert.Issuer = c.Issuer
+			cert.NotBefore = &c.NotBefore
+			cert.NotAfter = &c.NotAfter
+			cert.RenewAt = &c.RenewAt
+			cert.Valid = now.After(c.NotBefore) && now.Before(c.NotAfter)
+			cert.ExpiresSoon = c.NotAfter.Sub(now) < config.Config.CertExpiryWarning
+		}
+		if h := c.Health; !h.Checked.IsZero() {
+			cert.Health = &certHealthResponse{
+				Checked: h.Checked,
+				OCSP:    h.OCSP,
+				Error:   h.Error,
+				Stapled: h.Stapled,
+				OK:      h.Error == "",
+			}
+		}
+		res.Certificates = append(res.Certificates, cert)
+	}
+
+	return res
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+
+	datastar "github.com/starfederation/datastar/sdk/go"
+)
+
+// listsHandler opens the editor of the first list
+func (dash *Dashboard) listsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lists := dash.pm.GetEditors()
+		if len(lists) > 0 {
+			http.Redirect(w, r, "/lists/"+lists[0], http.StatusFound)
+			return
+		}
+
+		if err := ui.RenderTempl(w, r, pages.ListEditor(pages.ListEditorData{})); err != nil {
+			dash.Log.Error().Err(err).Msg("Error rendering list editor")
+		}
+	}
+}
+
+// listEditorHandler returns the editor of a list
+func (dash *Dashboard) listEditorHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		editor, err := dash.pm.GetEditor(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		source, err := editor.Source()
+		if err != nil {
+			dash.Log.Error().Err(err).Str("list", name).Msg("Error reading list")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		data := pages.ListEditorData{
+			Name:   name,
+			Lists:  dash.pm.GetEditors(),
+			Source: string(source),
+		}
+
+		if err := ui.RenderTempl(w, r, pages.ListEditor(data)); err != nil {
+			dash.Log.Error().Err(err).Msg("Error rendering list editor")
+		}
+	}
+}
+
+// listSaveHandler validates and saves a list, the proxies are updated when
+// the list provider detects the file change
+func (dash *Dashboard) listSaveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		editor, err := dash.pm.GetEditor(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		var signals struct {
+			Source string `json:"source"`
+		}
+		if err := datastar.ReadSignals(r, &signals); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("list", name).Str("username", user.Username).Msg("saving list")
+
+		result := pages.ListEditorResult("Saved", false)
+		err = editor.SaveSource([]byte(signals.Source))
+		dash.audit(r, "list save", name)(err)
+		if err != nil {
+			dash.Log.Info().Err(err).Str("list", name).Msg("list not saved")
+			result = pages.ListEditorResult(err.Error(), true)
+		}
+
+		sse := datastar.NewSSE(w, r)
+		if err := sse.MergeFragmentTempl(result); err != nil {
+			dash.Log.Error().Err(err).Msg("Error sending list editor result")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+)
+
+// loginPageHandler shows the local login form
+func (dash *Dashboard) loginPageHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !dash.auth.cfg.Enabled {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		dash.renderLogin(w, r, "")
+	}
+}
+
+// loginHandler validates the local login form
+func (dash *Dashboard) loginHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !dash.auth.cfg.Enabled {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		if !dash.auth.login(w, r, r.PostFormValue("username"), r.PostFormValue("password")) {
+			dash.renderLogin(w, r, "Invalid username or password")
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// oidcLoginHandler starts the OIDC login and handles its callback
+func (dash *Dashboard) oidcLoginHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		oidc := dash.auth.oidc()
+		if !dash.auth.cfg.Enabled || oidc == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		scheme := "http"
+		if isSecure(r) {
+			scheme = "https"
+		}
+
+		oidc.Middleware(scheme)(http.RedirectHandler("/", http.StatusFound)).ServeHTTP(w, r)
+	}
+}
+
+// logoutHandler removes the user sessions
+func (dash *Dashboard) logoutHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dash.auth.logout(w, r)
+
+		http.Redirect(w, r, loginPath, http.StatusSeeOther)
+	}
+}
+
+func (dash *Dashboard) renderLogin(w http.ResponseWriter, r *http.Request, loginError string) {
+	data := pages.LoginData{
+		Local: len(dash.auth.cfg.Users) > 0,
+		OIDC:  dash.auth.oidc() != nil,
+		Error: loginError,
+	}
+
+	if err := ui.RenderTempl(w, r, pages.Login(data)); err != nil {
+		dash.Log.Error().Err(err).Msg("Error rendering login page")
+	}
+}
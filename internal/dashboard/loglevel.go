@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+// maxLogLevelRequest is the maximum size of a log level request.
+const maxLogLevelRequest = 4 << 10
+
+type (
+	// logLevelRequest struct is the API request to change the log level of
+	// the server, or of a module. An empty level removes the level of the
+	// module.
+	logLevelRequest struct {
+		Module string `json:"module"`
+		Level  string `json:"level"`
+	}
+
+	// logLevelResponse struct is the log level of the server and the levels
+	// of the modules that override it.
+	logLevelResponse struct {
+		Modules map[string]string `json:"modules"`
+		Level   string            `json:"level"`
+	}
+)
+
+// logLevelAPIHandler returns the current log levels.
+func (dash *Dashboard) logLevelAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dash.HTTP.JSONResponse(w, r, newLogLevelResponse())
+	}
+}
+
+// setLogLevelAPIHandler changes a log level until the server restarts.
+func (dash *Dashboard) setLogLevelAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req logLevelRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLogLevelRequest)).Decode(&req); err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		err := core.SetLogLevel(req.Module, req.Level)
+		dash.audit(r, "log level", req.Module)(err)
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("logModule", req.Module).Str("level", req.Level).Str("username", user.Username).Msg("log level changed")
+
+		dash.HTTP.JSONResponse(w, r, newLogLevelResponse())
+	}
+}
+
+func newLogLevelResponse() logLevelResponse {
+	level, modules := core.LogLevels()
+
+	return logLevelResponse{Level: level, Modules: modules}
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxMaintenanceRequest is the maximum size of a maintenance request.
+const maxMaintenanceRequest = 16 << 10
+
+// maintenanceRequest struct is the API request to put a proxy in
+// maintenance, the body is optional.
+type maintenanceRequest struct {
+	Message string `json:"message"`
+}
+
+// maintenanceAPIHandler puts a proxy in maintenance, or updates the message
+// of a proxy already in maintenance.
+func (dash *Dashboard) maintenanceAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		var req maintenanceRequest
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMaintenanceRequest)).Decode(&req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		err = dash.pm.StartMaintenance(name, req.Message)
+		dash.audit(r, "proxy maintenance", name)(err)
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("proxy", name).Str("username", user.Username).Msg("maintenance started")
+
+		m, _ := dash.pm.GetMaintenance(name)
+		dash.HTTP.JSONResponse(w, r, m)
+	}
+}
+
+// endMaintenanceAPIHandler ends the maintenance of a proxy.
+func (dash *Dashboard) endMaintenanceAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		err := dash.pm.EndMaintenance(name)
+		dash.audit(r, "proxy end maintenance", name)(err)
+		if err != nil {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		dash.Log.Info().Str("proxy", name).Str("username", user.Username).Msg("maintenance ended")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
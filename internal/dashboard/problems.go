@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+
+	datastar "github.com/starfederation/datastar/sdk/go"
+)
+
+// problemsHandler returns the problems page
+func (dash *Dashboard) problemsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ui.RenderTempl(w, r, pages.Problems(dash.pm.Problems().List())); err != nil {
+			dash.Log.Error().Err(err).Msg("Error rendering problems")
+		}
+	}
+}
+
+// problemListHandler refreshes the problem list of the problems page
+func (dash *Dashboard) problemListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sse := datastar.NewSSE(w, r)
+		if err := sse.MergeFragmentTempl(pages.ProblemList(dash.pm.Problems().List())); err != nil {
+			dash.Log.Error().Err(err).Msg("Error sending problems")
+		}
+	}
+}
+
+// problemsAPIHandler returns the problems of the server
+func (dash *Dashboard) problemsAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dash.HTTP.JSONResponse(w, r, dash.pm.Problems().List())
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+)
+
+type (
+	// statsResponse struct is the connection statistics of a proxy in the
+	// API.
+	statsResponse struct {
+		Ports map[string]portStatsResponse `json:"ports"`
+		Name  string                       `json:"name"`
+		portStatsResponse
+	}
+
+	// portStatsResponse struct is the connection statistics of a port in the
+	// API, the rates are per second.
+	portStatsResponse struct {
+		Active       int64   `json:"active"`
+		Connections  uint64  `json:"connections"`
+		Requests     uint64  `json:"requests"`
+		BytesIn      uint64  `json:"bytesIn"`
+		BytesOut     uint64  `json:"bytesOut"`
+		RequestRate  float64 `json:"requestRate"`
+		BytesInRate  float64 `json:"bytesInRate"`
+		BytesOutRate float64 `json:"bytesOutRate"`
+	}
+)
+
+// statsAPIHandler returns the connection statistics of the proxies sorted by
+// name.
+func (dash *Dashboard) statsAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxies := dash.apiProxies(r)
+
+		res := make([]statsResponse, 0, len(proxies))
+		for _, name := range slices.Sorted(maps.Keys(proxies)) {
+			res = append(res, newStatsResponse(name, proxies[name]))
+		}
+
+		dash.HTTP.JSONResponse(w, r, res)
+	}
+}
+
+// proxyStatsAPIHandler returns the connection statistics of a proxy.
+func (dash *Dashboard) proxyStatsAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		p, ok := dash.apiProxies(r)[name]
+		if !ok {
+			dash.HTTP.JSONResponseCode(w, r, apiError{Message: proxymanager.ErrProxyNotFound.Error()}, http.StatusNotFound)
+			return
+		}
+
+		dash.HTTP.JSONResponse(w, r, newStatsResponse(name, p))
+	}
+}
+
+// newStatsResponse function returns the connection statistics of a proxy,
+// with the totals of its ports.
+func newStatsResponse(name string, p *proxymanager.Proxy) statsResponse {
+	res := statsResponse{
+		Name:  name,
+		Ports: make(map[string]portStatsResponse),
+	}
+
+	for port, s := range p.GetConnStats() {
+		res.Ports[port] = portStatsResponse(s)
+		res.add(s)
+	}
+
+	return res
+}
+
+// add method adds the statistics of a port to the totals.
+func (res *statsResponse) add(s model.PortStats) {
+	res.Active += s.Active
+	res.Connections += s.Connections
+	res.Requests += s.Requests
+	res.BytesIn += s.BytesIn
+	res.BytesOut += s.BytesOut
+	res.RequestRate += s.RequestRate
+	res.BytesInRate += s.BytesInRate
+	res.BytesOutRate += s.BytesOutRate
+}
+
+// statsSignals method returns the connection statistics signals of the
+// cards of the client that changed since they were last sent, nil if none
+// changed.
+func (dash *Dashboard) statsSignals(client *sseClient) []byte {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+
+	signals := make(map[string]string)
+	for name, card := range client.cards {
+		p, ok := dash.pm.GetProxy(name)
+		if !ok {
+			continue
+		}
+
+		stats := cardStats(newStatsResponse(name, p))
+		for stat, value := range stats {
+			if card.stats[stat] != value {
+				signals[pages.StatsSignal(name, stat)] = value
+			}
+		}
+		card.stats = stats
+		client.cards[name] = card
+	}
+
+	if len(signals) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(signals)
+	if err != nil {
+		dash.Log.Error().Err(err).Msg("Error encoding stats signals")
+		return nil
+	}
+
+	return b
+}
+
+// cardStats function returns the connection statistics shown in a proxy
+// card, by signal.
+func cardStats(s statsResponse) map[string]string {
+	return map[string]string{
+		"active": fmt.Sprintf("%d conn", s.Active),
+		"rps":    fmt.Sprintf("%.1f req/s", s.RequestRate),
+		"in":     "↓ " + formatRate(s.BytesInRate),
+		"out":    "↑ " + formatRate(s.BytesOutRate),
+	}
+}
+
+// formatRate function returns a rate in bytes per second with a binary unit.
+func formatRate(rate float64) string {
+	const unit = 1024
+
+	units := []string{"B/s", "KiB/s", "MiB/s", "GiB/s"}
+	i := 0
+	for rate >= unit && i < len(units)-1 {
+		rate /= unit
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", rate, units[i])
+	}
+
+	return fmt.Sprintf("%.1f %s", rate, units[i])
+}
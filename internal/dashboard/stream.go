@@ -4,10 +4,15 @@
 package dashboard
 
 import (
+	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 
-	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/compression"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
 
 	"github.com/a-h/templ"
 	datastar "github.com/starfederation/datastar/sdk/go"
@@ -28,31 +33,67 @@ const (
 type (
 	EventType int
 	sseClient struct {
-		channel chan SSEMessage
+		channel     chan SSEMessage
+		groups      map[string]struct{}
+		proxyGroups map[string]string
+		// cards are the parts of the proxy cards sent to the client, to
+		// send only the parts that changed
+		cards map[string]cardParts
+		user  User
+		view  listView
+		mtx   sync.Mutex
+		// render serializes the messages of a proxy list render
+		render sync.Mutex
 	}
 
 	SSEMessage struct {
 		Comp    templ.Component
 		Message string
-		Type    EventType
+		// Selector is the element where EventAppend appends Comp
+		Selector string
+		Type     EventType
 	}
 )
 
+// closeStreams method ends the streams of the connected clients, they
+// reconnect to the next server.
+func (dash *Dashboard) closeStreams() {
+	dash.closeOnce.Do(func() {
+		close(dash.done)
+	})
+}
+
 // Handler for the `/stream` endpoint
 func (dash *Dashboard) streamHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sessionID := r.Header.Get("X-Session-ID")
 
+		// the stream is compressed as a whole, events are flushed at once
+		w, closeStream := compression.Stream(w, r)
+		defer closeStream()
+
 		sse := datastar.NewSSE(w, r)
 
 		// Create a new client
+		user, _ := UserFromContext(r.Context())
 		client := &sseClient{
-			channel: make(chan SSEMessage, chanSizeSSEQueue),
+			channel:     make(chan SSEMessage, chanSizeSSEQueue),
+			groups:      make(map[string]struct{}),
+			proxyGroups: make(map[string]string),
+			cards:       make(map[string]cardParts),
+			user:        user,
+			view:        readView(r),
 		}
 
 		// Register client
 		dash.mtx.Lock()
 		dash.sseClients[sessionID] = client
+		// the first client starts the updates, it gets the current state
+		// from renderList
+		if dash.updates == nil {
+			dash.updates = dash.pm.SubscribeStatusEvents()
+			go dash.streamProxyUpdates(dash.updates)
+		}
 		dash.mtx.Unlock()
 
 		dash.Log.Info().Msg("New Client connected")
@@ -60,10 +101,15 @@ func (dash *Dashboard) streamHandler() http.HandlerFunc {
 		defer dash.removeSSEClient(sessionID)
 
 		go func() {
-			dash.renderList(client.channel)
+			dash.renderList(client)
 			dash.updateUser(r, client.channel)
+			dash.updateVersion(client.channel)
 		}()
 
+		// the connection statistics of the cards are sent periodically
+		statsTicker := time.NewTicker(proxymanager.StatsInterval)
+		defer statsTicker.Stop()
+
 		var err error
 
 		// Send messages to the client
@@ -72,13 +118,19 @@ func (dash *Dashboard) streamHandler() http.HandlerFunc {
 			select {
 			case <-r.Context().Done():
 				break LOOP
+			case <-dash.done:
+				break LOOP
+			case <-statsTicker.C:
+				if signals := dash.statsSignals(client); signals != nil {
+					err = sse.MergeSignals(signals)
+				}
 			case message := <-client.channel:
 				switch message.Type {
 				case EventAppend:
 					err = sse.MergeFragmentTempl(
 						message.Comp,
 						datastar.WithMergeMode(datastar.FragmentMergeModeAppend),
-						datastar.WithSelector("#proxy-list"),
+						datastar.WithSelector(message.Selector),
 					)
 
 				case EventMerge:
@@ -109,17 +161,22 @@ func (dash *Dashboard) streamHandler() http.HandlerFunc {
 }
 
 func (dash *Dashboard) updateUser(r *http.Request, ch chan SSEMessage) {
-	username := r.Header.Get(consts.HeaderUsername)
-	displayName := r.Header.Get(consts.HeaderDisplayName)
-	profilePicURL := r.Header.Get(consts.HeaderProfilePicURL)
-
-	signals := `{user_username: '` + username +
-		`', user_displayName: '` + displayName +
-		`', user_profilePicUrl: '` + profilePicURL + `'}`
+	user, _ := UserFromContext(r.Context())
+
+	signals, err := json.Marshal(map[string]string{
+		"user_username":      user.Username,
+		"user_displayName":   user.DisplayName,
+		"user_profilePicUrl": user.ProfilePicURL,
+		"user_role":          string(user.Role),
+	})
+	if err != nil {
+		dash.Log.Error().Err(err).Msg("Error encoding user signals")
+		return
+	}
 
 	ch <- SSEMessage{
 		Type:    EventUpdateSignals,
-		Message: signals,
+		Message: string(signals),
 	}
 }
 
@@ -130,34 +187,70 @@ func (dash *Dashboard) removeSSEClient(name string) {
 		delete(dash.sseClients, name)
 		close(client.channel)
 	}
+
+	// without clients the updates aren't rendered, the last client stops
+	// them
+	var updates <-chan model.ProxyEvent
+	if len(dash.sseClients) == 0 {
+		updates, dash.updates = dash.updates, nil
+	}
 	dash.mtx.Unlock()
 
+	if updates != nil {
+		dash.pm.UnsubscribeStatusEvents(updates)
+		dash.Log.Debug().Msg("No clients connected, proxy updates stopped")
+	}
+
 	dash.Log.Info().Msg("Client disconnected")
 }
 
-func (dash *Dashboard) streamProxyUpdates() {
-	for event := range dash.pm.SubscribeStatusEvents() {
+// streamProxyUpdates method renders the proxy events to the clients until
+// the events are unsubscribed.
+func (dash *Dashboard) streamProxyUpdates(events <-chan model.ProxyEvent) {
+	for event := range events {
 		dash.mtx.RLock()
 		for _, sseClient := range dash.sseClients {
 			switch event.Status {
 			case model.ProxyStatusInitializing:
-				dash.renderProxy(sseClient.channel, event.ID, EventAppend)
+				dash.renderProxy(sseClient, event.ID, EventAppend)
+				dash.renderFilters(sseClient)
 				dash.streamSortList(sseClient.channel)
 
 			case model.ProxyStatusStopped:
-				sseClient.channel <- SSEMessage{
-					Type:    EventRemoveMessage,
-					Message: "#" + event.ID,
+				// stopped proxies are shown until they are removed
+				if _, ok := dash.pm.GetProxy(event.ID); ok {
+					dash.renderProxy(sseClient, event.ID, EventMerge)
+					continue
+				}
+
+				if sseClient.forget(event.ID) {
+					sseClient.channel <- SSEMessage{
+						Type:    EventRemoveMessage,
+						Message: "#" + event.ID,
+					}
 				}
+				dash.renderFilters(sseClient)
 
 			default:
-				dash.renderProxy(sseClient.channel, event.ID, EventMerge)
+				dash.renderProxy(sseClient, event.ID, EventMerge)
 			}
+			dash.renderStartupProgress(sseClient.channel)
 		}
 		dash.mtx.RUnlock()
 	}
 }
 
+// renderStartupProgress method sends the progress of the proxies being
+// started, empty when none is.
+func (dash *Dashboard) renderStartupProgress(channel chan SSEMessage) {
+	progress := dash.pm.GetStartupProgress()
+
+	channel <- SSEMessage{
+		Type: EventMerge,
+		Comp: pages.StartupProgress(progress.Started, progress.Total),
+	}
+}
+
 func (dash *Dashboard) streamSortList(channel chan SSEMessage) {
 	channel <- SSEMessage{
 		Type:    EventScript,
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/updates"
+)
+
+// versionResponse struct is the version and build of the server in the API.
+type versionResponse struct {
+	core.BuildInfo
+	Channel  string         `json:"channel"`
+	Features []string       `json:"features"`
+	Updates  updates.Status `json:"updates"`
+}
+
+// SetUpdates method sets the update checker, its channel and result are
+// shown in the dashboard footer and returned by the API.
+func (dash *Dashboard) SetUpdates(u *updates.Checker) {
+	dash.mtx.Lock()
+	dash.updateChecker = u
+	dash.mtx.Unlock()
+}
+
+func (dash *Dashboard) getUpdates() *updates.Checker {
+	dash.mtx.RLock()
+	defer dash.mtx.RUnlock()
+
+	return dash.updateChecker
+}
+
+// versionAPIHandler returns the version, build and enabled features of the
+// server.
+func (dash *Dashboard) versionAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := dash.getUpdates()
+
+		dash.HTTP.JSONResponse(w, r, versionResponse{
+			BuildInfo: core.GetBuildInfo(),
+			Channel:   config.Config.Updates.Channel,
+			Features:  enabledFeatures(),
+			Updates:   u.Status(),
+		})
+	}
+}
+
+// updateVersion method sends the version of the footer to the client.
+func (dash *Dashboard) updateVersion(ch chan SSEMessage) {
+	status := dash.getUpdates().Status()
+
+	latest := ""
+	if status.UpdateAvailable {
+		latest = status.Latest.Version
+	}
+
+	signals, err := json.Marshal(map[string]string{
+		"version_version": core.GetVersion(),
+		"version_channel": config.Config.Updates.Channel,
+		"version_latest":  latest,
+	})
+	if err != nil {
+		dash.Log.Error().Err(err).Msg("Error encoding version signals")
+		return
+	}
+
+	ch <- SSEMessage{
+		Type:    EventUpdateSignals,
+		Message: string(signals),
+	}
+}
+
+// enabledFeatures function returns the optional features enabled in the
+// configuration.
+func enabledFeatures() []string {
+	cfg := config.Config
+
+	features := []string{}
+	add := func(name string, enabled bool) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+
+	add("letsEncrypt", cfg.LetsEncrypt.Enabled)
+	add("dashboardAuth", cfg.Dashboard.Auth.Enabled)
+	add("embed", cfg.Dashboard.Embed.Enabled)
+	add("oidc", len(cfg.OIDC) > 0)
+	add("inventory", cfg.Inventory.CloudflareKV.IsEnabled() || cfg.Inventory.Worker.IsEnabled())
+	add("listSync", cfg.Sync.IsEnabled())
+	add("cachePurge", cfg.CachePurge.APIToken != "")
+	add("publicDns", cfg.PublicDNS.APIToken != "")
+	add("history", cfg.History.Enabled)
+	add("notifications", len(cfg.Notifications) > 0)
+	add("errorPages", cfg.ErrorPages != "")
+	add("requestBudget", cfg.Limits.MaxRequests >= 0)
+	add("updateCheck", !cfg.Updates.Disabled)
+
+	return features
+}
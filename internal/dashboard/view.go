@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package dashboard
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/ui/pages"
+
+	datastar "github.com/starfederation/datastar/sdk/go"
+)
+
+const (
+	SortByName    = "name"
+	SortByStatus  = "status"
+	SortByChanged = "changed"
+)
+
+// statusOrder is the order of the proxies sorted by status.
+var statusOrder = []model.ProxyStatus{
+	model.ProxyStatusRunning,
+	model.ProxyStatusAuthenticating,
+	model.ProxyStatusStarting,
+	model.ProxyStatusInitializing,
+	model.ProxyStatusStopping,
+	model.ProxyStatusStopped,
+	model.ProxyStatusError,
+	model.ProxyStatusConflict,
+}
+
+// listView struct stores the search, filters and sort of a client, read
+// from the datastar signals.
+type listView struct {
+	Search   string `json:"search"`
+	Status   string `json:"filter_status"`   //nolint:tagliatelle
+	Provider string `json:"filter_provider"` //nolint:tagliatelle
+	Group    string `json:"filter_group"`    //nolint:tagliatelle
+	Sort     string `json:"sort"`
+}
+
+// listHandler applies the search, filters and sort of the client signals
+// and renders the proxy list again.
+func (dash *Dashboard) listHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		view := readView(r)
+
+		dash.mtx.RLock()
+		client, ok := dash.sseClients[r.Header.Get("X-Session-ID")]
+		dash.mtx.RUnlock()
+
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		client.setView(view)
+
+		go dash.renderList(client)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// readView function returns the view of the request signals.
+func readView(r *http.Request) listView {
+	var view listView
+	if err := datastar.ReadSignals(r, &view); err != nil {
+		view = listView{}
+	}
+
+	if view.Sort != SortByStatus && view.Sort != SortByChanged {
+		view.Sort = SortByName
+	}
+
+	return view
+}
+
+// match method returns true if the proxy is shown with the view.
+func (v listView) match(name, label string, p *proxymanager.Proxy) bool {
+	if !p.Config().Dashboard.Visible {
+		return false
+	}
+
+	status := p.GetStatus()
+	if v.Status != "" && status.String() != v.Status {
+		return false
+	}
+
+	if v.Provider != "" && p.Config().ProxyProvider != v.Provider {
+		return false
+	}
+
+	if v.Group != "" && p.Config().Dashboard.Group != v.Group {
+		return false
+	}
+
+	if v.Search == "" {
+		return true
+	}
+
+	search := strings.ToLower(v.Search)
+
+	return slices.ContainsFunc([]string{name, label, p.Config().Dashboard.Group, p.Config().ProxyProvider},
+		func(s string) bool {
+			return strings.Contains(strings.ToLower(s), search)
+		})
+}
+
+// sortKey method returns the key used to sort the proxies in the dashboard.
+// Proxies with the same status are sorted by name, the last changed are shown first.
+func (v listView) sortKey(name, label string, p *proxymanager.Proxy) string {
+	switch v.Sort {
+	case SortByStatus:
+		return fmt.Sprintf("%02d-%s", slices.Index(statusOrder, p.GetStatus()), name)
+	case SortByChanged:
+		return fmt.Sprintf("%019d-%s", math.MaxInt64-p.GetStatusChanged().UnixNano(), name)
+	default:
+		return strings.ToLower(label) + "-" + name
+	}
+}
+
+// filterOptions method returns the filter options of the visible proxies.
+func (dash *Dashboard) filterOptions(view listView) pages.FiltersData {
+	data := pages.FiltersData{}
+
+	for i := model.ProxyStatusInitializing; i <= model.ProxyStatusConflict; i++ {
+		data.Statuses = append(data.Statuses, i.String())
+	}
+
+	for _, p := range dash.pm.GetProxies() {
+		if !p.Config().Dashboard.Visible {
+			continue
+		}
+		if !slices.Contains(data.Providers, p.Config().ProxyProvider) {
+			data.Providers = append(data.Providers, p.Config().ProxyProvider)
+		}
+		if g := p.Config().Dashboard.Group; g != "" && !slices.Contains(data.Groups, g) {
+			data.Groups = append(data.Groups, g)
+		}
+	}
+
+	// keep the selected options even without proxies
+	if view.Provider != "" && !slices.Contains(data.Providers, view.Provider) {
+		data.Providers = append(data.Providers, view.Provider)
+	}
+	if view.Group != "" && !slices.Contains(data.Groups, view.Group) {
+		data.Groups = append(data.Groups, view.Group)
+	}
+
+	slices.Sort(data.Providers)
+	slices.Sort(data.Groups)
+
+	return data
+}
+
+// renderFilters method sends the filter options to the client.
+func (dash *Dashboard) renderFilters(client *sseClient) {
+	client.channel <- SSEMessage{
+		Type: EventMerge,
+		Comp: pages.Filters(dash.filterOptions(client.getView())),
+	}
+}
+
+func (client *sseClient) setView(view listView) {
+	client.mtx.Lock()
+	client.view = view
+	client.mtx.Unlock()
+}
+
+func (client *sseClient) getView() listView {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+
+	return client.view
+}
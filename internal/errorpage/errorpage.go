@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package errorpage renders the pages shown by the proxies instead of the
+// responses of their targets, when the targets are down or the proxies are
+// in maintenance.
+package errorpage
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maintenanceName is the template of the maintenance pages.
+	maintenanceName = "maintenance.html"
+	// errorName is the template of the errors without their own template.
+	errorName = "error.html"
+	// defaultName is the built-in template, used without custom templates.
+	defaultName = "default"
+)
+
+const defaultTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Proxy}} - {{.Title}}</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0;background:#f5f5f5;color:#222}
+@media (prefers-color-scheme:dark){body{background:#1d232a;color:#ddd}}
+main{max-width:32rem;padding:2rem;text-align:center}
+h1{font-size:1.5rem;margin:0 0 .5rem}
+p{margin:.5rem 0;opacity:.8}
+.status{font-size:.875rem;opacity:.6}
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Proxy}}</h1>
+<p>{{.Message}}</p>
+{{if .Maintenance}}{{if not .Since.IsZero}}<p class="status">In maintenance since {{.Since.Format "2006-01-02 15:04"}}</p>{{end}}{{else}}<p class="status">{{.Status}} {{.Title}}</p>{{end}}
+</main>
+</body>
+</html>
+`
+
+// defaultPages are the pages of a nil Pages, with the built-in template.
+var defaultPages = &Pages{tmpl: newTemplate()}
+
+type (
+	// Data struct is the data of the error page templates.
+	Data struct {
+		Since       time.Time
+		Proxy       string
+		Title       string
+		Message     string
+		Status      int
+		Maintenance bool
+	}
+
+	// Pages struct renders the error pages, with the templates of a
+	// directory or the built-in one.
+	Pages struct {
+		tmpl *template.Template
+	}
+)
+
+// Load function returns the error pages of the html files in dir, named
+// after the status code, like 502.html, error.html for the other errors or
+// maintenance.html. Without dir only the built-in page is used.
+func Load(dir string) (*Pages, error) {
+	tmpl := newTemplate()
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tmpl.New(filepath.Base(f)).Parse(string(data)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Pages{tmpl: tmpl}, nil
+}
+
+// Write method writes the error page of the data with its status. Clients
+// that don't accept HTML get the message in plain text. A nil Pages uses the
+// built-in page.
+func (p *Pages) Write(w http.ResponseWriter, r *http.Request, d Data) {
+	if d.Title == "" {
+		d.Title = http.StatusText(d.Status)
+	}
+
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	if d.Maintenance {
+		h.Set("Retry-After", "120")
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, d.Message, d.Status)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := p.render(&buf, d); err != nil {
+		http.Error(w, d.Message, d.Status)
+		return
+	}
+
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(buf.Len()))
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(d.Status)
+
+	if r.Method != http.MethodHead {
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// render method executes the first template found for the data: the
+// maintenance or status one, error.html and the built-in one.
+func (p *Pages) render(buf *bytes.Buffer, d Data) error {
+	if p == nil {
+		p = defaultPages
+	}
+
+	names := []string{strconv.Itoa(d.Status) + ".html", errorName}
+	if d.Maintenance {
+		names = []string{maintenanceName}
+	}
+
+	var err error
+	for _, name := range append(names, defaultName) {
+		t := p.tmpl.Lookup(name)
+		if t == nil {
+			continue
+		}
+		// a broken custom template falls back to the next one
+		if err = t.Execute(buf, d); err == nil {
+			return nil
+		}
+		buf.Reset()
+	}
+
+	return err
+}
+
+// newTemplate function returns the template set with the built-in page.
+func newTemplate() *template.Template {
+	return template.Must(template.New(defaultName).Parse(defaultTemplate))
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package geoip returns the countries of the client addresses, from a
+// MaxMind DB file like GeoLite2 Country.
+package geoip
+
+import (
+	"errors"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+var ErrNoDatabase = errors.New("geoip database not configured")
+
+// global is the database of the countries, nil without one.
+var global atomic.Pointer[Reader]
+
+// Setup function loads the database of the configuration. Errors are
+// logged, and the countries are unknown without a database.
+func Setup(log zerolog.Logger, cfg config.GeoIPConfig) {
+	if cfg.Database == "" {
+		return
+	}
+
+	log = core.ModuleLogger(log, "geoip")
+
+	r, err := Open(cfg.Database)
+	if err != nil {
+		log.Error().Err(err).Str("database", cfg.Database).Msg("error loading geoip database")
+		return
+	}
+	global.Store(r)
+
+	log.Info().Str("database", cfg.Database).Msg("geoip database loaded")
+}
+
+// Enabled function returns true if a database is loaded.
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+// Country function returns the ISO 3166-1 code of the country of the
+// address, false if it isn't in the database, like tailnet and private
+// addresses.
+func Country(addr netip.Addr) (string, bool) {
+	r := global.Load()
+	if r == nil {
+		return "", false
+	}
+
+	data, err := r.Lookup(addr)
+	if err != nil || data == nil {
+		return "", false
+	}
+
+	record, _ := data.(map[string]any)
+	for _, key := range []string{"country", "registered_country"} {
+		country, _ := record[key].(map[string]any)
+		if code, ok := country["iso_code"].(string); ok && code != "" {
+			return code, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,341 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+)
+
+// metadataMarker starts the metadata section at the end of the database.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the size of the zeros between the search tree and
+// the data section.
+const dataSectionSeparator = 16
+
+// Types of the data section fields.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+var (
+	ErrInvalidDatabase = errors.New("invalid MaxMind database")
+	ErrUnsupportedType = errors.New("unsupported MaxMind database field type")
+)
+
+// Reader struct looks up addresses in a MaxMind DB file, loaded in memory.
+type Reader struct {
+	buffer     []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node of the IPv4 addresses in IPv6 databases
+	ipv4Start uint
+}
+
+// Open function loads the MaxMind DB file of the path.
+func Open(path string) (*Reader, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReader(buffer)
+}
+
+func newReader(buffer []byte) (*Reader, error) {
+	start := bytes.LastIndex(buffer, metadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+	start += len(metadataMarker)
+
+	metadata, _, err := decoder{data: buffer[start:]}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+	}
+
+	m, _ := metadata.(map[string]any)
+	r := &Reader{
+		buffer:     buffer,
+		nodeCount:  metadataUint(m, "node_count"),
+		recordSize: metadataUint(m, "record_size"),
+		ipVersion:  metadataUint(m, "ip_version"),
+	}
+
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: record size %d", ErrInvalidDatabase, r.recordSize)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(start) {
+		return nil, fmt.Errorf("%w: search tree larger than the file", ErrInvalidDatabase)
+	}
+	r.data = buffer[treeSize+dataSectionSeparator : start-len(metadataMarker)]
+
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+
+	return r, nil
+}
+
+// Lookup method returns the data of the network of the address, nil if the
+// address isn't in the database.
+func (r *Reader) Lookup(addr netip.Addr) (any, error) {
+	addr = addr.Unmap()
+
+	node, bits := uint(0), addr.BitLen()
+	switch {
+	case addr.Is4() && r.ipVersion == 6:
+		node = r.ipv4Start
+	case addr.Is6() && r.ipVersion == 4:
+		return nil, nil
+	}
+
+	ip := addr.AsSlice()
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-i%8)) & 1
+		node = r.record(node, bit)
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, fmt.Errorf("%w: search tree without data", ErrInvalidDatabase)
+	}
+
+	value, _, err := decoder{data: r.data}.decode(node - r.nodeCount - dataSectionSeparator)
+
+	return value, err
+}
+
+// record method returns the left (bit 0) or right (bit 1) record of a node
+// of the search tree.
+func (r *Reader) record(node, bit uint) uint {
+	b := r.buffer
+	switch r.recordSize {
+	case 24:
+		o := node*6 + bit*3
+		return uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+	case 28:
+		o := node * 7
+		if bit == 0 {
+			return uint(b[o+3]&0xf0)<<20 | uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+		}
+		return uint(b[o+3]&0x0f)<<24 | uint(b[o+4])<<16 | uint(b[o+5])<<8 | uint(b[o+6])
+	default:
+		o := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(b[o:]))
+	}
+}
+
+// decoder struct decodes the fields of a data section, pointers are offsets
+// in data.
+type decoder struct {
+	data []byte
+}
+
+// decode method returns the field at the offset and the offset after it.
+func (d decoder) decode(offset uint) (any, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		pointer, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer)
+		return value, next, err
+	}
+
+	if typ == typeMap {
+		return d.decodeMap(size, offset)
+	}
+	if typ == typeArray {
+		return d.decodeArray(size, offset)
+	}
+	if typ == typeBool {
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.data)) {
+		return nil, 0, fmt.Errorf("%w: field past the end of the data", ErrInvalidDatabase)
+	}
+	b := d.data[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return bytes.Clone(b), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double of %d bytes", ErrInvalidDatabase, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float of %d bytes", ErrInvalidDatabase, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64, typeInt32:
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		if typ == typeInt32 {
+			return int32(v), next, nil //nolint:gosec
+		}
+		return v, next, nil
+	case typeUint128:
+		// not used by the country and city databases, kept as bytes
+		return bytes.Clone(b), next, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: %d", ErrUnsupportedType, typ)
+	}
+}
+
+// control method returns the type and size of the field at the offset, and
+// the offset of its payload.
+func (d decoder) control(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(d.data)) {
+		return 0, 0, 0, fmt.Errorf("%w: offset past the end of the data", ErrInvalidDatabase)
+	}
+
+	c := d.data[offset]
+	offset++
+
+	typ := int(c >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.data)) {
+			return 0, 0, 0, fmt.Errorf("%w: offset past the end of the data", ErrInvalidDatabase)
+		}
+		typ = 7 + int(d.data[offset])
+		offset++
+	}
+
+	size := uint(c & 0x1f)
+	if typ == typePointer || size < 29 {
+		return typ, size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.data)) {
+		return 0, 0, 0, fmt.Errorf("%w: size past the end of the data", ErrInvalidDatabase)
+	}
+	var v uint
+	for _, b := range d.data[offset : offset+n] {
+		v = v<<8 | uint(b)
+	}
+	switch n {
+	case 1:
+		size = 29 + v
+	case 2:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+
+	return typ, size, offset + n, nil
+}
+
+// pointer method returns the offset of a pointer with the size bits of its
+// control byte, and the offset after it.
+func (d decoder) pointer(size, offset uint) (uint, uint, error) {
+	n := (size>>3)&0x3 + 1
+	if offset+n > uint(len(d.data)) {
+		return 0, 0, fmt.Errorf("%w: pointer past the end of the data", ErrInvalidDatabase)
+	}
+
+	var v uint
+	if n != 4 {
+		v = size & 0x7
+	}
+	for _, b := range d.data[offset : offset+n] {
+		v = v<<8 | uint(b)
+	}
+
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+
+	return v, offset + n, nil
+}
+
+func (d decoder) decodeMap(size, offset uint) (any, uint, error) {
+	m := make(map[string]any, size)
+	for range size {
+		key, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, next, err := d.decode(next)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		k, ok := key.(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: map key isn't a string", ErrInvalidDatabase)
+		}
+		m[k] = value
+		offset = next
+	}
+
+	return m, offset, nil
+}
+
+func (d decoder) decodeArray(size, offset uint) (any, uint, error) {
+	a := make([]any, 0, size)
+	for range size {
+		value, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		a = append(a, value)
+		offset = next
+	}
+
+	return a, offset, nil
+}
+
+// metadataUint function returns an unsigned field of the metadata, 0 if
+// it's missing.
+func metadataUint(m map[string]any, key string) uint {
+	v, _ := m[key].(uint64)
+	return uint(v)
+}
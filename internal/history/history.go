@@ -0,0 +1,224 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	// openTimeout is the time to wait for the lock of the database file.
+	openTimeout = 5 * time.Second
+	// pruneInterval is the interval to delete the transitions older than the retention.
+	pruneInterval = time.Hour
+	// keySize is the size of the keys, a big endian unix time in nanoseconds.
+	keySize = 8
+)
+
+var ErrInvalidRecord = errors.New("invalid history record")
+
+// Store struct records the status transitions of the proxies in a bbolt
+// database, with a bucket by proxy and the transition time as key.
+type Store struct {
+	log       zerolog.Logger
+	db        *bolt.DB
+	done      chan struct{}
+	retention time.Duration
+}
+
+// Open function opens the database and starts deleting the transitions older
+// than retention.
+func Open(log zerolog.Logger, path string, retention time.Duration) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout}) //nolint:mnd
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		log:       core.ModuleLogger(log, "history"),
+		db:        db,
+		retention: retention,
+		done:      make(chan struct{}),
+	}
+
+	go s.pruneLoop()
+
+	return s, nil
+}
+
+// Close method closes the database.
+func (s *Store) Close() error {
+	close(s.done)
+	return s.db.Close()
+}
+
+// Record method records a status transition of a proxy. Statuses equal to
+// the last recorded one are ignored.
+func (s *Store) Record(name string, status model.ProxyStatus, t time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+
+		if _, v := b.Cursor().Last(); v != nil && string(v) == status.String() {
+			return nil
+		}
+
+		return b.Put(timeKey(t), []byte(status.String()))
+	})
+}
+
+// History method returns the transitions of a proxy since the time, oldest
+// first. The transition before since is included, as it's the status at since.
+func (s *Store) History(name string, since time.Time) ([]model.StatusChange, error) {
+	history := []model.StatusChange{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(name))
+		if b == nil {
+			return nil
+		}
+
+		// start with the status at since, the last transition before it
+		c := b.Cursor()
+		k, v := c.Seek(timeKey(since))
+		switch {
+		case k == nil:
+			k, v = c.Last()
+		case keyTime(k).After(since):
+			if k, v = c.Prev(); k == nil {
+				k, v = c.First()
+			}
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			status, err := model.ParseProxyStatus(string(v))
+			if err != nil {
+				return ErrInvalidRecord
+			}
+			history = append(history, model.StatusChange{Time: keyTime(k), Status: status})
+		}
+
+		return nil
+	})
+
+	return history, err
+}
+
+// Uptime method returns the rate of time, from 0 to 1, the proxy was running
+// since the time. Time before the first transition is not counted, ok is
+// false if there are no transitions.
+func (s *Store) Uptime(name string, since, now time.Time) (float64, bool, error) {
+	history, err := s.History(name, since)
+	if err != nil || len(history) == 0 {
+		return 0, false, err
+	}
+
+	uptime, ok := Uptime(history, since, now)
+
+	return uptime, ok, nil
+}
+
+// Uptime function returns the rate of time the transitions were running
+// between since and now. ok is false if no time was recorded.
+func Uptime(history []model.StatusChange, since, now time.Time) (float64, bool) {
+	var running, total time.Duration
+
+	for i, h := range history {
+		start := h.Time
+		if start.Before(since) {
+			start = since
+		}
+
+		end := now
+		if i+1 < len(history) {
+			end = history[i+1].Time
+		}
+
+		if !end.After(start) {
+			continue
+		}
+
+		total += end.Sub(start)
+		if h.Status == model.ProxyStatusRunning {
+			running += end.Sub(start)
+		}
+	}
+
+	if total == 0 {
+		return 0, false
+	}
+
+	return float64(running) / float64(total), true
+}
+
+// pruneLoop method deletes old transitions until the store is closed.
+func (s *Store) pruneLoop() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		s.prune(time.Now().Add(-s.retention))
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune method deletes the transitions before the time, except the last one
+// of each proxy, which is its status at the time.
+func (s *Store) prune(before time.Time) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, b *bolt.Bucket) error {
+			var old [][]byte
+
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && keyTime(k).Before(before); k, _ = c.Next() {
+				old = append(old, bytes.Clone(k))
+			}
+
+			// keep the status at before
+			if len(old) > 0 {
+				old = old[:len(old)-1]
+			}
+
+			for _, k := range old {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		s.log.Error().Err(err).Msg("Error deleting old status history")
+	}
+}
+
+// timeKey function returns the key of a time, sorted like the time.
+func timeKey(t time.Time) []byte {
+	key := make([]byte, keySize)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano())) //nolint:gosec
+
+	return key
+}
+
+// keyTime function returns the time of a key.
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key))) //nolint:gosec
+}
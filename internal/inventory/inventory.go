@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// publishDelay groups proxy events in a single publish
+	publishDelay   = 5 * time.Second
+	publishTimeout = 30 * time.Second
+)
+
+type (
+	// Publisher struct pushes the proxy inventory to remote stores.
+	Publisher struct {
+		log      zerolog.Logger
+		pm       *proxymanager.ProxyManager
+		targets  []target
+		interval time.Duration
+	}
+
+	// target interface is implemented by the remote stores.
+	target interface {
+		Name() string
+		Publish(ctx context.Context, data []byte) error
+	}
+
+	// Inventory struct is the published document.
+	Inventory struct {
+		UpdatedAt time.Time `json:"updatedAt"`
+		Proxies   []Item    `json:"proxies"`
+	}
+
+	// Item struct is a proxy in the inventory.
+	Item struct {
+		Hostname      string `json:"hostname"`
+		Label         string `json:"label"`
+		Icon          string `json:"icon"`
+		Status        string `json:"status"`
+		URL           string `json:"url"`
+		ProxyProvider string `json:"proxyProvider"`
+	}
+)
+
+// New function returns a new Publisher, nil if no remote store is configured.
+func New(log zerolog.Logger, pm *proxymanager.ProxyManager, cfg config.InventoryConfig) *Publisher {
+	p := &Publisher{
+		log:      core.ModuleLogger(log, "inventory"),
+		pm:       pm,
+		interval: cfg.Interval,
+	}
+
+	if cfg.CloudflareKV.IsEnabled() {
+		p.targets = append(p.targets, newKVTarget(cfg.CloudflareKV))
+	}
+	if cfg.Worker.IsEnabled() {
+		p.targets = append(p.targets, newWorkerTarget(cfg.Worker))
+	}
+
+	if len(p.targets) == 0 {
+		return nil
+	}
+
+	return p
+}
+
+// Start method publishes the inventory when proxies change and on every interval.
+func (p *Publisher) Start(ctx context.Context) {
+	events := p.pm.SubscribeStatusEvents()
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		delay := time.NewTimer(publishDelay)
+		defer delay.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				delay.Reset(publishDelay)
+			case <-delay.C:
+				p.publish(ctx)
+			case <-ticker.C:
+				p.publish(ctx)
+			}
+		}
+	}()
+}
+
+// publish method sends the current inventory to all targets.
+func (p *Publisher) publish(ctx context.Context) {
+	data, err := json.Marshal(p.inventory())
+	if err != nil {
+		p.log.Error().Err(err).Msg("error encoding inventory")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	for _, t := range p.targets {
+		if err := t.Publish(ctx, data); err != nil {
+			p.log.Error().Err(err).Str("target", t.Name()).Msg("error publishing inventory")
+			continue
+		}
+		p.log.Debug().Str("target", t.Name()).Msg("inventory published")
+	}
+}
+
+// inventory method returns the proxies visible in the dashboard.
+func (p *Publisher) inventory() Inventory {
+	items := []Item{}
+
+	for name, proxy := range p.pm.GetProxies() {
+		if !proxy.Config().Dashboard.Visible {
+			continue
+		}
+
+		status := proxy.GetStatus()
+
+		url := ""
+		if status == model.ProxyStatusRunning {
+			url = proxy.GetURL()
+		}
+
+		label := proxy.Config().Dashboard.Label
+		if label == "" {
+			label = name
+		}
+
+		items = append(items, Item{
+			Hostname:      name,
+			Label:         label,
+			Icon:          proxy.Config().Dashboard.Icon,
+			Status:        status.String(),
+			URL:           url,
+			ProxyProvider: proxy.Config().ProxyProvider,
+		})
+	}
+
+	slices.SortFunc(items, func(a, b Item) int {
+		return strings.Compare(a.Hostname, b.Hostname)
+	})
+
+	return Inventory{
+		UpdatedAt: time.Now().UTC(),
+		Proxies:   items,
+	}
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+)
+
+const (
+	cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+	httpTimeout      = 30 * time.Second
+	maxErrorBodySize = 4096
+)
+
+type (
+	// kvTarget writes the inventory to a Workers KV key.
+	kvTarget struct {
+		client *http.Client
+		url    string
+		token  string
+	}
+
+	// workerTarget posts the inventory to a Worker endpoint.
+	workerTarget struct {
+		client *http.Client
+		url    string
+		token  string
+	}
+
+	// cloudflareResponse is the envelope of Cloudflare API responses.
+	cloudflareResponse struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"errors"`
+		Success bool `json:"success"`
+	}
+)
+
+func newKVTarget(cfg config.CloudflareKVConfig) *kvTarget {
+	return &kvTarget{
+		client: &http.Client{Timeout: httpTimeout},
+		url: cloudflareAPIURL +
+			"/accounts/" + url.PathEscape(cfg.AccountID) +
+			"/storage/kv/namespaces/" + url.PathEscape(cfg.NamespaceID) +
+			"/values/" + url.PathEscape(cfg.Key),
+		token: cfg.APIToken,
+	}
+}
+
+// Name method implements target Name method.
+func (t *kvTarget) Name() string {
+	return "cloudflareKV"
+}
+
+// Publish method implements target Publish method.
+func (t *kvTarget) Publish(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var cfResp cloudflareResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBodySize)).Decode(&cfResp); err != nil {
+		return fmt.Errorf("cloudflare api returned %s", resp.Status)
+	}
+
+	if !cfResp.Success {
+		msgs := make([]string, len(cfResp.Errors))
+		for i, e := range cfResp.Errors {
+			msgs[i] = fmt.Sprintf("%d: %s", e.Code, e.Message)
+		}
+		return fmt.Errorf("cloudflare api returned %s: %s", resp.Status, strings.Join(msgs, ", "))
+	}
+
+	return nil
+}
+
+func newWorkerTarget(cfg config.WorkerConfig) *workerTarget {
+	return &workerTarget{
+		client: &http.Client{Timeout: httpTimeout},
+		url:    cfg.URL,
+		token:  cfg.Token,
+	}
+}
+
+// Name method implements target Name method.
+func (t *workerTarget) Name() string {
+	return "worker"
+}
+
+// Publish method implements target Publish method.
+func (t *workerTarget) Publish(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("worker returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
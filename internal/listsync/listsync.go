@@ -0,0 +1,335 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package listsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	filePrefix  = "tsdproxy-sync-"
+	maxFileSize = 1 << 20
+	stateFile   = "sync.yaml"
+
+	// conflictSuffix is added to the list filename to store a conflicting remote version
+	conflictSuffix = ".conflict-"
+)
+
+var ErrFileSharingNotSupported = errors.New("proxy provider doesn't support file sharing")
+
+type (
+	// Syncer struct replicates list files between tsdproxy instances.
+	//
+	// Every change is sent with the hash of the last version synchronized
+	// (Base). A peer applies the change if its file is that version, or if it
+	// wasn't changed since the last synchronization. Otherwise both versions
+	// were changed and the remote one is saved in a conflict file.
+	Syncer struct {
+		log      zerolog.Logger
+		node     proxyproviders.ProxyInterface
+		sharer   proxyproviders.FileSharer
+		state    *state
+		file     *config.ConfigFile
+		lists    map[string]string
+		sent     map[string]string
+		hostname string
+		peers    []string
+		interval time.Duration
+		mtx      sync.Mutex
+	}
+
+	// update struct is the file sent to peers.
+	update struct {
+		List    string `json:"list"`
+		Origin  string `json:"origin"`
+		Base    string `json:"base"`
+		Hash    string `json:"hash"`
+		Content []byte `json:"content"`
+	}
+
+	// state struct stores the hash of the last synchronized version of each list.
+	state struct {
+		Lists map[string]string `yaml:"lists"`
+	}
+)
+
+// New function returns a new Syncer, nil if sync is not configured.
+func New(log zerolog.Logger, pm *proxymanager.ProxyManager, cfg config.SyncConfig) (*Syncer, error) {
+	if !cfg.IsEnabled() {
+		return nil, nil //nolint:nilnil
+	}
+
+	log = core.ModuleLogger(log, "listsync")
+
+	providerName := cfg.ProxyProvider
+	if providerName == "" {
+		providerName = config.Config.DefaultProxyProvider
+	}
+
+	provider, ok := pm.ProxyProviders[providerName]
+	if !ok {
+		return nil, proxymanager.ErrProxyProviderNotFound
+	}
+
+	node, err := provider.NewProxy(&model.Config{
+		Hostname:      cfg.Hostname,
+		ProxyProvider: providerName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating sync node: %w", err)
+	}
+
+	sharer, ok := node.(proxyproviders.FileSharer)
+	if !ok {
+		return nil, ErrFileSharingNotSupported
+	}
+
+	s := &Syncer{
+		log:      log,
+		node:     node,
+		sharer:   sharer,
+		hostname: cfg.Hostname,
+		peers:    cfg.Peers,
+		interval: cfg.Interval,
+		lists:    make(map[string]string),
+		sent:     make(map[string]string),
+		state:    &state{Lists: make(map[string]string)},
+	}
+
+	for _, name := range cfg.Lists {
+		s.lists[name] = config.Config.Lists[name].Filename
+	}
+
+	s.file = config.NewConfigFile(log, filepath.Join(config.Config.Tailscale.DataDir, stateFile), s.state)
+	if err := s.file.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error().Err(err).Msg("error loading sync state")
+	}
+	if s.state.Lists == nil {
+		s.state.Lists = make(map[string]string)
+	}
+
+	return s, nil
+}
+
+// Start method starts the sync node, sends local changes on every interval
+// and applies the changes received from peers.
+func (s *Syncer) Start(ctx context.Context) error {
+	if err := s.node.Start(ctx); err != nil {
+		return fmt.Errorf("error starting sync node: %w", err)
+	}
+
+	// status events must be consumed
+	go func() {
+		for range s.node.WatchEvents() {
+		}
+	}()
+
+	go s.sendLoop(ctx)
+	go s.receiveLoop(ctx)
+
+	return nil
+}
+
+// Close method stops the sync node.
+func (s *Syncer) Close() error {
+	return s.node.Close()
+}
+
+func (s *Syncer) sendLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for name := range s.lists {
+				s.send(ctx, name)
+			}
+		}
+	}
+}
+
+// send method sends the list to the peers that don't have its current version.
+func (s *Syncer) send(ctx context.Context, name string) {
+	content, hash, err := s.read(name)
+	if err != nil {
+		s.log.Error().Err(err).Str("list", name).Msg("error reading list")
+		return
+	}
+	// never replicate a missing list, it would delete the list of the peers
+	if content == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	u := update{
+		List:    name,
+		Origin:  s.hostname,
+		Base:    s.state.Lists[name],
+		Hash:    hash,
+		Content: content,
+	}
+	s.mtx.Unlock()
+
+	data, err := json.Marshal(u)
+	if err != nil {
+		s.log.Error().Err(err).Str("list", name).Msg("error encoding list")
+		return
+	}
+
+	for _, peer := range s.peers {
+		key := peer + "/" + name
+
+		s.mtx.Lock()
+		sent := s.sent[key] == hash
+		s.mtx.Unlock()
+
+		if sent {
+			continue
+		}
+
+		if err := s.sharer.SendFile(ctx, peer, filePrefix+name+".json", data); err != nil {
+			s.log.Warn().Err(err).Str("list", name).Str("peer", peer).Msg("error sending list")
+			continue
+		}
+
+		s.mtx.Lock()
+		s.sent[key] = hash
+		s.mtx.Unlock()
+
+		s.log.Debug().Str("list", name).Str("peer", peer).Msg("list sent")
+	}
+}
+
+func (s *Syncer) receiveLoop(ctx context.Context) {
+	for {
+		files, err := s.sharer.ReceiveFiles(ctx, maxFileSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			s.log.Debug().Err(err).Msg("error receiving files")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.interval):
+			}
+			continue
+		}
+
+		for _, f := range files {
+			if !strings.HasPrefix(f.Name, filePrefix) {
+				s.log.Warn().Str("file", f.Name).Msg("ignoring unknown file")
+				continue
+			}
+
+			var u update
+			if err := json.Unmarshal(f.Data, &u); err != nil {
+				s.log.Error().Err(err).Str("file", f.Name).Msg("error decoding list")
+				continue
+			}
+
+			s.apply(u)
+		}
+	}
+}
+
+// apply method writes the received list if it doesn't conflict with local changes.
+func (s *Syncer) apply(u update) {
+	log := s.log.With().Str("list", u.List).Str("peer", u.Origin).Logger()
+
+	filename, ok := s.lists[u.List]
+	if !ok || !slices.Contains(s.peers, u.Origin) {
+		log.Warn().Msg("ignoring list not configured for sync")
+		return
+	}
+
+	if hashOf(u.Content) != u.Hash {
+		log.Error().Msg("ignoring corrupted list")
+		return
+	}
+
+	_, local, err := s.read(u.List)
+	if err != nil {
+		log.Error().Err(err).Msg("error reading list")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	synced := s.state.Lists[u.List]
+
+	// the peer has this version, don't send it back
+	s.sent[u.Origin+"/"+u.List] = u.Hash
+
+	switch {
+	case local == u.Hash:
+		log.Debug().Msg("list already synchronized")
+
+	case local == u.Base || local == synced:
+		if err := os.WriteFile(filename, u.Content, consts.PermAllRead+consts.PermOwnerWrite); err != nil {
+			log.Error().Err(err).Msg("error writing list")
+			return
+		}
+		log.Info().Msg("list updated from peer")
+
+	default:
+		conflict := filename + conflictSuffix + u.Origin
+		if err := os.WriteFile(conflict, u.Content, consts.PermAllRead+consts.PermOwnerWrite); err != nil {
+			log.Error().Err(err).Msg("error writing conflict file")
+			return
+		}
+		log.Warn().Str("file", conflict).Msg("list changed locally and in peer, peer version saved in conflict file")
+	}
+
+	// the next local change is based on the peer version
+	s.state.Lists[u.List] = u.Hash
+	if err := s.file.Save(); err != nil {
+		log.Error().Err(err).Msg("error saving sync state")
+	}
+}
+
+// read method returns the content and hash of a list, nil if it doesn't exist.
+func (s *Syncer) read(name string) ([]byte, string, error) {
+	content, err := os.ReadFile(s.lists[name])
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return content, hashOf(content), nil
+}
+
+func hashOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/net/html"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+const (
+	// maxPageSize is the size of the page read to find the title and icon,
+	// the head of the page is expected to be within it.
+	maxPageSize  = 512 << 10
+	maxIconSize  = 256 << 10
+	maxTitleSize = 64
+	fetchTimeout = 10 * time.Second
+	cacheTTL     = 24 * time.Hour
+)
+
+var (
+	ErrNoMetadata   = errors.New("no title or icon found")
+	ErrIconTooLarge = errors.New("icon too large")
+)
+
+type (
+	// Metadata struct stores the title and icon of a target application.
+	Metadata struct {
+		Title    string
+		IconType string
+		Icon     []byte
+	}
+
+	// Fetcher struct fetches the metadata of target applications and caches it.
+	Fetcher struct {
+		log   zerolog.Logger
+		cache map[string]cacheEntry
+		mtx   sync.Mutex
+	}
+
+	cacheEntry struct {
+		expires  time.Time
+		metadata Metadata
+	}
+)
+
+// New function returns a new Fetcher.
+func New(log zerolog.Logger) *Fetcher {
+	return &Fetcher{
+		log:   core.ModuleLogger(log, "metadata"),
+		cache: make(map[string]cacheEntry),
+	}
+}
+
+// Fetch method returns the metadata of the target, from the cache if it was
+// fetched recently. Failures are not cached so they can be retried.
+func (f *Fetcher) Fetch(ctx context.Context, target *url.URL, tlsValidate bool) (Metadata, error) {
+	key := target.String()
+
+	f.mtx.Lock()
+	entry, ok := f.cache[key]
+	f.mtx.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.metadata, nil
+	}
+
+	m, err := f.fetch(ctx, target, tlsValidate)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	f.mtx.Lock()
+	f.cache[key] = cacheEntry{metadata: m, expires: time.Now().Add(cacheTTL)}
+	f.mtx.Unlock()
+
+	return m, nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, target *url.URL, tlsValidate bool) (Metadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: !tlsValidate}, //nolint
+		},
+	}
+
+	var m Metadata
+
+	iconURL := target.ResolveReference(&url.URL{Path: "/favicon.ico"})
+
+	page, resp, err := get(ctx, client, target)
+	if err != nil {
+		return m, err
+	}
+	if len(page) > maxPageSize {
+		page = page[:maxPageSize]
+	}
+
+	title, href := parseHead(page)
+	m.Title = title
+	if href != "" {
+		if u, err := resp.Request.URL.Parse(href); err == nil {
+			iconURL = u
+		}
+	}
+
+	if icon, iconType, err := getIcon(ctx, client, iconURL); err != nil {
+		f.log.Debug().Err(err).Str("url", iconURL.String()).Msg("error fetching icon")
+	} else {
+		m.Icon = icon
+		m.IconType = iconType
+	}
+
+	if m.Title == "" && m.Icon == nil {
+		return m, ErrNoMetadata
+	}
+
+	return m, nil
+}
+
+// get function returns the body of the url, reading at most one byte more
+// than the largest allowed size.
+func get(ctx context.Context, client *http.Client, u *url.URL) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return body, resp, nil
+}
+
+func getIcon(ctx context.Context, client *http.Client, u *url.URL) ([]byte, string, error) {
+	icon, resp, err := get(ctx, client, u)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(icon) > maxIconSize {
+		return nil, "", ErrIconTooLarge
+	}
+
+	iconType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(iconType, "image/") {
+		iconType = http.DetectContentType(icon)
+	}
+	if !strings.HasPrefix(iconType, "image/") {
+		return nil, "", fmt.Errorf("%s is not an image: %s", u, iconType)
+	}
+
+	return icon, iconType, nil
+}
+
+// parseHead function returns the title and the icon href of the page.
+// "icon" links are preferred over "apple-touch-icon" links.
+func parseHead(page []byte) (string, string) {
+	var title, icon, touchIcon string
+
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return title, firstNonEmpty(icon, touchIcon)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+
+			switch string(name) {
+			case "body":
+				return title, firstNonEmpty(icon, touchIcon)
+
+			case "title":
+				if title == "" && z.Next() == html.TextToken {
+					title = cleanTitle(string(z.Text()))
+				}
+
+			case "link":
+				var rel, href string
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					switch string(key) {
+					case "rel":
+						rel = strings.ToLower(string(val))
+					case "href":
+						href = string(val)
+					}
+				}
+
+				for _, r := range strings.Fields(rel) {
+					switch {
+					case r == "icon" && icon == "":
+						icon = href
+					case r == "apple-touch-icon" && touchIcon == "":
+						touchIcon = href
+					}
+				}
+			}
+		}
+	}
+}
+
+func cleanTitle(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+
+	runes := []rune(s)
+	if len(runes) > maxTitleSize {
+		s = string(runes[:maxTitleSize])
+	}
+
+	return s
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// labelSeparator separates label values in the series key, it can't be used in labels.
+const labelSeparator = "\xff"
+
+type (
+	// Counter struct is a monotonically increasing value with labels.
+	Counter struct {
+		series     map[string]*atomic.Uint64
+		name       string
+		help       string
+		labelNames []string
+		mtx        sync.RWMutex
+	}
+
+	// Gauge struct is a value with labels that can go up and down.
+	Gauge struct {
+		series     map[string]*atomic.Int64
+		name       string
+		help       string
+		labelNames []string
+		mtx        sync.RWMutex
+	}
+
+	metric interface {
+		write(w io.Writer)
+	}
+)
+
+var (
+	registry    = make(map[string]metric)
+	registryMtx sync.Mutex
+)
+
+// NewCounter function creates and registers a counter.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     make(map[string]*atomic.Uint64),
+	}
+
+	register(name, c)
+
+	return c
+}
+
+// Inc method increments the counter of the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add method adds v to the counter of the label values.
+func (c *Counter) Add(v uint64, labelValues ...string) {
+	key := strings.Join(labelValues, labelSeparator)
+
+	c.mtx.RLock()
+	s, ok := c.series[key]
+	c.mtx.RUnlock()
+
+	if !ok {
+		c.mtx.Lock()
+		if s, ok = c.series[key]; !ok {
+			s = new(atomic.Uint64)
+			c.series[key] = s
+		}
+		c.mtx.Unlock()
+	}
+
+	s.Add(v)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+
+	for _, key := range sortedKeys(c.series) {
+		fmt.Fprintf(w, "%s%s %d\n", c.name, labels(c.labelNames, key), c.series[key].Load())
+	}
+}
+
+// NewGauge function creates and registers a gauge.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     make(map[string]*atomic.Int64),
+	}
+
+	register(name, g)
+
+	return g
+}
+
+// Add method adds v, negative to decrease, to the gauge of the label values.
+func (g *Gauge) Add(v int64, labelValues ...string) {
+	key := strings.Join(labelValues, labelSeparator)
+
+	g.mtx.RLock()
+	s, ok := g.series[key]
+	g.mtx.RUnlock()
+
+	if !ok {
+		g.mtx.Lock()
+		if s, ok = g.series[key]; !ok {
+			s = new(atomic.Int64)
+			g.series[key] = s
+		}
+		g.mtx.Unlock()
+	}
+
+	s.Add(v)
+}
+
+// Set method sets the gauge of the label values to v.
+func (g *Gauge) Set(v int64, labelValues ...string) {
+	key := strings.Join(labelValues, labelSeparator)
+
+	g.mtx.Lock()
+	s, ok := g.series[key]
+	if !ok {
+		s = new(atomic.Int64)
+		g.series[key] = s
+	}
+	g.mtx.Unlock()
+
+	s.Store(v)
+}
+
+// Delete method removes the gauge of the label values, for series of
+// things that don't exist anymore.
+func (g *Gauge) Delete(labelValues ...string) {
+	key := strings.Join(labelValues, labelSeparator)
+
+	g.mtx.Lock()
+	delete(g.series, key)
+	g.mtx.Unlock()
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+
+	for _, key := range sortedKeys(g.series) {
+		fmt.Fprintf(w, "%s%s %d\n", g.name, labels(g.labelNames, key), g.series[key].Load())
+	}
+}
+
+func register(name string, m metric) {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic("metric " + name + " already registered")
+	}
+	registry[name] = m
+}
+
+// Handler function returns a http.Handler that writes all metrics in the
+// Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		registryMtx.Lock()
+		defer registryMtx.Unlock()
+
+		for _, name := range sortedKeys(registry) {
+			registry[name].write(w)
+		}
+	})
+}
+
+func labels(names []string, key string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	values := strings.Split(key, labelSeparator)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + `="` + escape(value) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
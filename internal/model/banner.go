@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Banner struct stores a notice injected into the HTML pages of the proxies
+// between Start and End.
+type Banner struct {
+	Start   time.Time `yaml:"start,omitempty"`
+	End     time.Time `yaml:"end"`
+	ID      string    `yaml:"id"`
+	Message string    `yaml:"message"`
+	// Proxies are the proxies showing the banner, all if empty
+	Proxies []string `yaml:"proxies,omitempty"`
+}
+
+// Active method returns true if the banner is shown at now.
+func (b Banner) Active(now time.Time) bool {
+	return !now.Before(b.Start) && now.Before(b.End)
+}
+
+// AppliesTo method returns true if the banner is shown by the proxy.
+func (b Banner) AppliesTo(proxy string) bool {
+	return len(b.Proxies) == 0 || slices.Contains(b.Proxies, proxy)
+}
@@ -0,0 +1,14 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+import "time"
+
+// CertInfo struct stores the details of a TLS certificate of a proxy.
+type CertInfo struct {
+	NotBefore time.Time
+	NotAfter  time.Time
+	Domain    string
+	Issuer    string
+}
@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+// PortStats struct stores the connection statistics of a port since the
+// proxy started.
+type PortStats struct {
+	Active      int64
+	Connections uint64
+	Requests    uint64
+	BytesIn     uint64
+	BytesOut    uint64
+	// RequestRate, BytesInRate and BytesOutRate are per second, over the
+	// last sample interval.
+	RequestRate  float64
+	BytesInRate  float64
+	BytesOutRate float64
+}
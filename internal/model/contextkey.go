@@ -4,7 +4,8 @@
 package model
 
 const (
-	ContextKeyWhois ContextKey = "contextkey.whois"
+	ContextKeyWhois         ContextKey = "contextkey.whois"
+	ContextKeyDashboardUser ContextKey = "contextkey.dashboarduser"
 )
 
 type (
@@ -10,6 +10,8 @@ const (
 	DefaultProxyProvider  = ""
 	DefaultTLSValidate    = true
 
+	DefaultRedirectToHTTPS = false
+
 	// tailscale defaults
 	DefaultTailscaleEphemeral    = false
 	DefaultTailscaleRunWebClient = false
@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+type (
+	// DiscoveredService struct stores a service found by a target provider
+	// that waits for the user approval to be proxied.
+	DiscoveredService struct {
+		TargetProvider string
+		ID             string
+		Name           string
+		Target         string
+		Port           uint16
+	}
+)
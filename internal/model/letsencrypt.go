@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+import "time"
+
+type (
+	// LetsEncryptStatus struct stores the state of the Let's Encrypt
+	// certificates of the server.
+	LetsEncryptStatus struct {
+		// NextCheck is the time of the next scheduled renewal check
+		NextCheck    time.Time
+		LastRenewal  RenewalAttempt
+		Account      ACMEAccount
+		Certificates []LetsEncryptCert
+	}
+
+	// ACMEAccount struct stores the Let's Encrypt account registration.
+	ACMEAccount struct {
+		URI    string
+		Status string
+		Error  string
+		// Registered is true if the account key exists, it's registered with
+		// the first certificate
+		Registered bool
+	}
+
+	// RenewalAttempt struct stores the result of a certificate renewal.
+	RenewalAttempt struct {
+		Time   time.Time
+		Error  string
+		Forced bool
+	}
+
+	// LetsEncryptCert struct stores a Let's Encrypt certificate of a domain.
+	LetsEncryptCert struct {
+		// RenewAt is the time the certificate is renewed by the renewal checks
+		RenewAt time.Time
+		Error   string
+		Health  CertHealth
+		CertInfo
+	}
+
+	// CertHealth struct stores the result of the last chain and revocation
+	// check of a certificate.
+	CertHealth struct {
+		Checked time.Time
+		// OCSP is the revocation status of the certificate, good, revoked,
+		// unknown or empty if the CA has no OCSP responder
+		OCSP  string
+		Error string
+		// Stapled is true if the OCSP response is stapled to the handshakes
+		Stapled bool
+	}
+)
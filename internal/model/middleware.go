@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	middlewareOptionsSeparator = ":"
+	middlewareOptionSeparator  = ";"
+	middlewareValueSeparator   = "="
+)
+
+var ErrInvalidMiddleware = errors.New("invalid middleware")
+
+// PortMiddleware struct is a middleware of the chain of a port, with its
+// options.
+type PortMiddleware struct {
+	Options map[string]string `yaml:"options,omitempty"`
+	Name    string            `validate:"required" yaml:"name"`
+}
+
+// ParseMiddleware function parses a middleware of a label, like
+// "headers:X-Env=prod;X-Team=ops", the options are optional.
+func ParseMiddleware(s string) (PortMiddleware, error) {
+	name, options, _ := strings.Cut(strings.TrimSpace(s), middlewareOptionsSeparator)
+
+	m := PortMiddleware{Name: strings.TrimSpace(name)}
+	if m.Name == "" {
+		return m, fmt.Errorf("%w: %q", ErrInvalidMiddleware, s)
+	}
+
+	for _, option := range strings.Split(options, middlewareOptionSeparator) {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(option, middlewareValueSeparator)
+		if !ok || strings.TrimSpace(key) == "" {
+			return m, fmt.Errorf("%w: option %q of %s", ErrInvalidMiddleware, option, m.Name)
+		}
+
+		if m.Options == nil {
+			m.Options = make(map[string]string)
+		}
+		m.Options[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,14 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+import "time"
+
+// NodeInfo struct stores the details of the proxy node in the network.
+type NodeInfo struct {
+	KeyExpiry *time.Time
+	DNSName   string
+	IPs       []string
+	Online    bool
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+package model
+
+// Actions of the proxies in a plan.
+const (
+	PlanCreate  PlanAction = "create"
+	PlanReload  PlanAction = "reload"
+	PlanRestart PlanAction = "restart"
+	PlanStop    PlanAction = "stop"
+	PlanError   PlanAction = "error"
+)
+
+// Actions of the public DNS names in a plan.
+const (
+	PlanPublish   PlanAction = "publish"
+	PlanUnpublish PlanAction = "unpublish"
+)
+
+type (
+	PlanAction string
+
+	// Plan struct stores the changes that a new configuration of a target
+	// provider would make, without applying them.
+	Plan struct {
+		Provider string      `json:"provider"`
+		Proxies  []ProxyPlan `json:"proxies"`
+		DNS      []DNSPlan   `json:"dns"`
+		Certs    []CertPlan  `json:"certificates"`
+	}
+
+	// ProxyPlan struct is the action of a proxy in a plan. Changes are the
+	// configuration fields that changed, and the ports restarted by a reload.
+	ProxyPlan struct {
+		Name    string     `json:"name"`
+		Action  PlanAction `json:"action"`
+		Changes []string   `json:"changes,omitempty"`
+		Error   string     `json:"error,omitempty"`
+	}
+
+	// DNSPlan struct is a public DNS name published or unpublished in a plan.
+	DNSPlan struct {
+		Name   string     `json:"name"`
+		Proxy  string     `json:"proxy"`
+		Action PlanAction `json:"action"`
+	}
+
+	// CertPlan struct is a TLS certificate ordered by a new node in a plan.
+	CertPlan struct {
+		Proxy string   `json:"proxy"`
+		Ports []string `json:"ports"`
+	}
+)
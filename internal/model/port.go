@@ -6,24 +6,163 @@ package model
 import (
 	"errors"
 	"fmt"
+	"net/netip"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type (
 	PortConfig struct {
-		name          string `validate:"string" yaml:"name"`
-		ProxyProtocol string `validate:"string" yaml:"proxyProtocol"`
-		targets       []*url.URL
-		ProxyPort     int           `validate:"hostname_port" yaml:"proxyPort"`
-		TLSValidate   bool          `validate:"boolean" yaml:"tlsValidate"`
-		IsRedirect    bool          `validate:"boolean" yaml:"isRedirect"`
-		Tailscale     TailscalePort `validate:"dive" yaml:"tailscale"`
+		name              string `validate:"string" yaml:"name"`
+		ProxyProtocol     string `validate:"string" yaml:"proxyProtocol"`
+		targets           []*url.URL
+		ProxyPort         int           `validate:"hostname_port" yaml:"proxyPort"`
+		TLSValidate       bool          `validate:"boolean" yaml:"tlsValidate"`
+		IsRedirect        bool          `validate:"boolean" yaml:"isRedirect"`
+		DirectoryListing  bool          `validate:"boolean" yaml:"directoryListing"`
+		OIDC              string        `validate:"string" yaml:"oidc"`
+		AllowGroups       []string      `yaml:"allowGroups,omitempty"`
+		MaxRequestBody    int64         `validate:"gte=0" yaml:"maxRequestBody,omitempty"`
+		RequestsPerSecond float64       `validate:"gte=0" yaml:"requestsPerSecond,omitempty"`
+		Burst             int           `validate:"gte=0" yaml:"burst,omitempty"`
+		MaxHeaderBytes    int           `validate:"gte=0" yaml:"maxHeaderBytes,omitempty"`
+		MaxConnections    int           `validate:"gte=0" yaml:"maxConnections,omitempty"`
+		AcceptBackoff     time.Duration `validate:"gte=0" yaml:"acceptBackoff,omitempty"`
+		IPFamily          string        `validate:"omitempty,oneof=dual ipv4 ipv6" yaml:"ipFamily,omitempty"`
+		Tailscale         TailscalePort `validate:"dive" yaml:"tailscale"`
+		MTLS              MTLS          `validate:"dive" yaml:"mtls"`
+		// TargetProxyProtocol is the PROXY protocol version sent to the targets
+		TargetProxyProtocol string `validate:"omitempty,oneof=v1 v2" yaml:"targetProxyProtocol,omitempty"`
+		// AcceptProxyProtocol requires a PROXY protocol header in the
+		// connections of the port, sent by a load balancer in front of it
+		AcceptProxyProtocol bool `validate:"boolean" yaml:"acceptProxyProtocol,omitempty"`
+		// Cache is the response cache of the port
+		Cache Cache `validate:"dive" yaml:"cache,omitempty"`
+		// Compression compresses the responses of targets that don't
+		Compression Compression `validate:"dive" yaml:"compression,omitempty"`
+		// HealthCheck actively checks the targets of tcp ports
+		HealthCheck HealthCheck `validate:"dive" yaml:"healthCheck,omitempty"`
+		// Retry sends the requests that failed to connect to the next target
+		Retry Retry `validate:"dive" yaml:"retry,omitempty"`
+		// CircuitBreaker stops sending requests to targets that fail
+		CircuitBreaker CircuitBreaker `validate:"dive" yaml:"circuitBreaker,omitempty"`
+		// Middlewares is the ordered chain of the requests of the port, the
+		// default chain without middlewares
+		Middlewares []PortMiddleware `validate:"dive" yaml:"middlewares,omitempty"`
+		// Upstream is the TLS of the connections to the https targets
+		Upstream Upstream `validate:"dive" yaml:"upstream,omitempty"`
+		// AllowedCIDRs are the only client addresses accepted by the port,
+		// all of them without any, and DeniedCIDRs the addresses rejected
+		AllowedCIDRs []string `validate:"dive,cidr" yaml:"allowedCIDRs,omitempty"`
+		DeniedCIDRs  []string `validate:"dive,cidr" yaml:"deniedCIDRs,omitempty"`
+		// AllowedCountries and DeniedCountries filter the clients by the
+		// ISO 3166-1 code of their country, with the geoip database
+		AllowedCountries []string `validate:"dive,iso3166_1_alpha2" yaml:"allowedCountries,omitempty"`
+		DeniedCountries  []string `validate:"dive,iso3166_1_alpha2" yaml:"deniedCountries,omitempty"`
+	}
+
+	// Upstream struct stores the TLS of the connections of a port to its
+	// https targets, verified with TLSValidate.
+	Upstream struct {
+		// CAFile is the CA bundle that verifies the targets, instead of the
+		// CAs of the system
+		CAFile string `validate:"omitempty,file" yaml:"caFile,omitempty"`
+		// CertFile and KeyFile are the client certificate sent to the
+		// targets that require one
+		CertFile string `validate:"omitempty,file,required_with=KeyFile" yaml:"certFile,omitempty"`
+		KeyFile  string `validate:"omitempty,file,required_with=CertFile" yaml:"keyFile,omitempty"`
+		// ServerName is the name verified in the certificates of the
+		// targets, their host by default
+		ServerName string `validate:"omitempty,hostname" yaml:"serverName,omitempty"`
+	}
+
+	// HealthCheck struct stores the active health check of the targets of a
+	// tcp port. A target is down after UnhealthyThreshold failed checks in a
+	// row, and up again after HealthyThreshold successful checks.
+	HealthCheck struct {
+		// Type is tcp to connect to the target, tls to also complete a TLS
+		// handshake or expect to send Send and wait for Expect
+		Type string `validate:"omitempty,oneof=tcp tls expect" yaml:"type,omitempty"`
+		// Send and Expect are the payloads of expect checks, with \r, \n
+		// and \t escapes
+		Send               string        `yaml:"send,omitempty"`
+		Expect             string        `yaml:"expect,omitempty"`
+		Interval           time.Duration `validate:"gte=0" yaml:"interval,omitempty"`
+		Timeout            time.Duration `validate:"gte=0" yaml:"timeout,omitempty"`
+		HealthyThreshold   int           `validate:"gte=0" yaml:"healthyThreshold,omitempty"`
+		UnhealthyThreshold int           `validate:"gte=0" yaml:"unhealthyThreshold,omitempty"`
+		// TLS sends the payloads of expect checks over TLS
+		TLS bool `validate:"boolean" yaml:"tls,omitempty"`
+	}
+
+	// Retry struct stores the retries of the idempotent requests of a port
+	// with multiple targets, when the connection to a target fails.
+	Retry struct {
+		// Attempts is the maximum number of targets tried by a request,
+		// retries are disabled below 2
+		Attempts int `validate:"gte=0" yaml:"attempts,omitempty"`
+		// Budget is the ratio of retries to requests allowed, so failing
+		// targets don't multiply the load of the others
+		Budget float64 `validate:"gte=0,lte=1" yaml:"budget,omitempty"`
+	}
+
+	// CircuitBreaker struct stores the circuit breaker of the targets of a
+	// port. A target with a rolling error rate of at least ErrorRate gets no
+	// requests for OpenTimeout, then a single request closes it again or
+	// keeps it open.
+	CircuitBreaker struct {
+		// ErrorRate is the rate of failed requests that opens the breaker,
+		// from 0 to 1, the breaker is disabled with 0
+		ErrorRate float64 `validate:"gte=0,lte=1" yaml:"errorRate,omitempty"`
+		// MinRequests is the number of requests of a target before its
+		// error rate opens the breaker
+		MinRequests int           `validate:"gte=0" yaml:"minRequests,omitempty"`
+		OpenTimeout time.Duration `validate:"gte=0" yaml:"openTimeout,omitempty"`
+	}
+
+	// Cache struct stores the response cache of a port. Responses are cached
+	// for the time allowed by their Cache-Control header, or for the TTL of
+	// the first rule matching their path.
+	Cache struct {
+		Rules []CacheRule `validate:"dive" yaml:"rules,omitempty"`
+		// MaxSize is the maximum size of the cached bodies in bytes
+		MaxSize int64 `validate:"gte=0" yaml:"maxSize,omitempty"`
+		Enabled bool  `validate:"boolean" yaml:"enabled,omitempty"`
+		// Disk stores the bodies in the data directory instead of memory
+		Disk bool `validate:"boolean" yaml:"disk,omitempty"`
+	}
+
+	// Compression struct stores the response compression of a port. Only
+	// responses of the MIME types, and of at least MinSize bytes, are
+	// compressed with brotli or gzip.
+	Compression struct {
+		// Types are the compressed MIME types, like text/*, defaulting to
+		// text, JSON, JavaScript, XML and SVG
+		Types   []string `yaml:"types,omitempty"`
+		MinSize int      `validate:"gte=0" yaml:"minSize,omitempty"`
+		Enabled bool     `validate:"boolean" yaml:"enabled,omitempty"`
+	}
+
+	// CacheRule struct overrides the cache time of the paths matching a
+	// pattern, where * matches any characters. A zero TTL isn't cached.
+	CacheRule struct {
+		Path string        `validate:"required,startswith=/" yaml:"path"`
+		TTL  time.Duration `validate:"gte=0" yaml:"ttl"`
+	}
+
+	// MTLS struct stores the client certificate authentication of a port.
+	MTLS struct {
+		CAFile       string   `validate:"omitempty,file" yaml:"caFile"`
+		AllowedNames []string `yaml:"allowedNames,omitempty"`
 	}
 
 	TailscalePort struct {
 		Funnel bool `validate:"boolean" yaml:"funnel"`
+		// Path is the path of the port in the serve config of the node,
+		// ports with a path share the HTTPS port of the node
+		Path string `validate:"omitempty,startswith=/" yaml:"path,omitempty"`
 	}
 )
 
@@ -31,12 +170,35 @@ const (
 	redirectSeparator = "->"
 	proxySeparator    = ":"
 	protocolSeparator = "/"
+
+	// TargetSchemeFile is the target scheme used to serve a local directory
+	TargetSchemeFile = "file"
+	// TargetSchemeUnix is the target scheme of http targets listening in a
+	// unix socket, like unix:///var/run/app.sock
+	TargetSchemeUnix = "unix"
+
+	// Address families of the port listeners, empty is dual-stack
+	IPFamilyDual = "dual"
+	IPFamilyIPv4 = "ipv4"
+	IPFamilyIPv6 = "ipv6"
+
+	// Types of the active health checks
+	HealthCheckTCP    = "tcp"
+	HealthCheckTLS    = "tls"
+	HealthCheckExpect = "expect"
+
+	// DefaultHTTPSPort is the port of https URLs without port
+	DefaultHTTPSPort = 443
+	// HTTPSRedirectPort is the port added by RedirectToHTTPS
+	HTTPSRedirectPort     = 80
+	HTTPSRedirectPortName = "80/http"
 )
 
 var (
 	ErrInvalidPortFormat   = errors.New("invalid format, missing '" + protocolSeparator + "' or '" + redirectSeparator + "'")
 	ErrInvalidProxyConfig  = errors.New("invalid proxy configuration")
 	ErrInvalidTargetConfig = errors.New("invalid target configuration")
+	ErrInvalidCIDR         = errors.New("invalid CIDR")
 )
 
 // NewPortLongLabel parses a port configuration string and returns a PortConfig struct.
@@ -183,6 +345,12 @@ func parseRedirectTarget(segment string, config *PortConfig) error {
 	return nil
 }
 
+// SetName sets the name of the port, the name isn't saved with the
+// configuration.
+func (p *PortConfig) SetName(name string) {
+	p.name = name
+}
+
 func (p *PortConfig) GetTargets() []*url.URL {
 	return p.targets
 }
@@ -194,6 +362,36 @@ func (p *PortConfig) GetFirstTarget() *url.URL {
 	return &url.URL{}
 }
 
+// IsEnabled returns true if client certificates are required.
+func (m *MTLS) IsEnabled() bool {
+	return m.CAFile != ""
+}
+
+// IsStatic returns true if the port serves files from a local directory
+// instead of proxying to a remote target.
+func (p *PortConfig) IsStatic() bool {
+	return p.GetFirstTarget().Scheme == TargetSchemeFile
+}
+
+// NetworkSuffix returns the suffix of the network of the port listener,
+// like tcp4 or udp6, empty for dual-stack.
+func (p *PortConfig) NetworkSuffix() string {
+	switch p.IPFamily {
+	case IPFamilyIPv4:
+		return "4"
+	case IPFamilyIPv6:
+		return "6"
+	}
+
+	return ""
+}
+
+// ListensOn returns true if the port accepts connections of the family,
+// ipv4 or ipv6.
+func (p *PortConfig) ListensOn(family string) bool {
+	return p.IPFamily == "" || p.IPFamily == IPFamilyDual || p.IPFamily == family
+}
+
 func (p *PortConfig) AddTarget(target *url.URL) {
 	p.targets = append(p.targets, target)
 }
@@ -207,3 +405,18 @@ func (p *PortConfig) ReplaceTarget(origin, target *url.URL) {
 		}
 	}
 }
+
+// ParseCIDRs function returns the prefixes of a list of CIDRs, like the
+// AllowedCIDRs and DeniedCIDRs of a port.
+func ParseCIDRs(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(c))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCIDR, c)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
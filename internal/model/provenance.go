@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+// ProvenanceDefault is the source of the fields with their default value.
+const ProvenanceDefault = "default"
+
+// ProvenanceFields are the fields of a proxy configuration with a default
+// source when the target provider doesn't set them.
+var ProvenanceFields = []string{
+	"hostname",
+	"proxyProvider",
+	"tailscale.authKey",
+	"tailscale.tags",
+	"tailscale.controlUrl",
+	"tailscale.ephemeral",
+	"tailscale.runWebClient",
+	"tailscale.verbose",
+	"dashboard.label",
+	"dashboard.icon",
+	"dashboard.group",
+	"dashboard.visible",
+	"accessLog.format",
+	"accessLog.sink",
+}
+
+// Provenance is the source of the fields of a proxy configuration, like a
+// container label or a line of a list file, by the path of the field, like
+// dashboard.label or ports.443/https.
+type Provenance map[string]string
+
+// Set method sets the source of a field.
+func (p Provenance) Set(field, source string) {
+	p[field] = source
+}
+
+// SetDefault method sets the source of the fields without one to default.
+func (p Provenance) SetDefault(fields ...string) {
+	for _, f := range fields {
+		if _, ok := p[f]; !ok {
+			p[f] = ProvenanceDefault
+		}
+	}
+}
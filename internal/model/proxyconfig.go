@@ -4,6 +4,9 @@ package model
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/creasty/defaults"
 )
@@ -17,15 +20,125 @@ type (
 		TargetID       string
 		ProxyProvider  string
 		Hostname       string
-		Dashboard      Dashboard `validate:"dive"`
-		Tailscale      Tailscale `validate:"dive"`
-		ProxyAccessLog bool      `default:"true" validate:"boolean"`
+		Dashboard      Dashboard  `validate:"dive"`
+		Tailscale      Tailscale  `validate:"dive"`
+		Exec           Exec       `validate:"dive"`
+		AccessLog      AccessLog  `validate:"dive"`
+		CachePurge     CachePurge `validate:"dive"`
+		PublicDNS      PublicDNS  `validate:"dive"`
+		Readiness      Readiness  `validate:"dive"`
+		Hooks          Hooks      `validate:"dive"`
+		ProxyAccessLog bool       `default:"true" validate:"boolean"`
+		// RedirectToHTTPS adds a port 80 that redirects to the https port
+		RedirectToHTTPS bool `validate:"boolean"`
+		// Provenance is the source of the fields, set by the target provider
+		Provenance Provenance
+	}
+
+	// AccessLog struct stores the format and the sink of the proxy access log.
+	// Format is json, clf, combined or the name of a template in the
+	// accessLogFormats configuration.
+	AccessLog struct {
+		Format string          `default:"json" validate:"omitempty" yaml:"format"`
+		Sink   string          `default:"log" validate:"omitempty,oneof=log file syslog http" yaml:"sink"`
+		File   AccessLogFile   `validate:"dive" yaml:"file,omitempty"`
+		Syslog AccessLogSyslog `validate:"dive" yaml:"syslog,omitempty"`
+		HTTP   AccessLogHTTP   `validate:"dive" yaml:"http,omitempty"`
+		Redact AccessLogRedact `validate:"dive" yaml:"redact,omitempty"`
+		// Sample logs 1 of every Sample requests, errors are always logged
+		Sample int `validate:"gte=0" yaml:"sample,omitempty"`
+	}
+
+	// AccessLogRedact struct stores the data removed from the access log
+	// entries. Headers are the names of the logged headers that are dropped,
+	// User-Agent and Referer.
+	AccessLogRedact struct {
+		Headers  []string `validate:"dive,oneof=User-Agent Referer" yaml:"headers,omitempty"`
+		Query    bool     `yaml:"query,omitempty"`
+		Username bool     `yaml:"username,omitempty"`
+	}
+
+	// AccessLogFile struct stores the file sink configuration.
+	// The file is rotated when it reaches MaxSize megabytes.
+	AccessLogFile struct {
+		Path       string `yaml:"path"`
+		MaxSize    int    `default:"100" validate:"gte=0" yaml:"maxSize"`
+		MaxBackups int    `default:"5" validate:"gte=0" yaml:"maxBackups"`
+	}
+
+	// AccessLogSyslog struct stores the syslog sink configuration.
+	// An empty Network uses the local syslog daemon.
+	AccessLogSyslog struct {
+		Network string `validate:"omitempty,oneof=tcp udp unix" yaml:"network,omitempty"`
+		Address string `yaml:"address,omitempty"`
+		Tag     string `default:"tsdproxy" yaml:"tag"`
+	}
+
+	// AccessLogHTTP struct stores the HTTP endpoint sink configuration.
+	AccessLogHTTP struct {
+		Headers map[string]string `yaml:"headers,omitempty"`
+		URL     string            `validate:"omitempty,url" yaml:"url"`
+	}
+
+	// CachePurge struct stores the Cloudflare cache purged when the proxy is
+	// restarted or updated. Without URLs, everything in the zone is purged.
+	CachePurge struct {
+		Zone string   `yaml:"zone,omitempty"`
+		URLs []string `validate:"dive,url" yaml:"urls,omitempty"`
+	}
+
+	// PublicDNS struct stores the public DNS name of the proxy, published in
+	// Cloudflare with the addresses of the instance while it's healthy.
+	PublicDNS struct {
+		Name string `validate:"omitempty,fqdn" yaml:"name,omitempty"`
+	}
+
+	// Readiness struct stores the probe of the targets when the proxy starts.
+	// The proxy is Running after a target of each port passes it, until
+	// then its requests get a 503 with Retry-After. Without Path, a target
+	// passes when it accepts a connection.
+	Readiness struct {
+		// Path is requested in the http and https targets, a 2xx or 3xx
+		// response passes
+		Path     string        `validate:"omitempty,startswith=/" yaml:"path,omitempty"`
+		Interval time.Duration `validate:"gte=0" yaml:"interval,omitempty"`
+		Enabled  bool          `validate:"boolean" yaml:"enabled,omitempty"`
+	}
+
+	// Exec struct stores the configuration of a process started and supervised
+	// by the proxy. Targets should point to the port the process binds.
+	Exec struct {
+		Env     map[string]string `yaml:"env,omitempty"`
+		Command string            `yaml:"command"`
+		Dir     string            `yaml:"dir,omitempty"`
+		Args    []string          `yaml:"args,omitempty"`
+	}
+
+	// Hooks struct stores the hooks run when the proxy changes its status:
+	// starting, running, stopped or in error.
+	Hooks struct {
+		OnStart   []Hook `validate:"dive" yaml:"onStart,omitempty"`
+		OnRunning []Hook `validate:"dive" yaml:"onRunning,omitempty"`
+		OnStop    []Hook `validate:"dive" yaml:"onStop,omitempty"`
+		OnError   []Hook `validate:"dive" yaml:"onError,omitempty"`
+	}
+
+	// Hook struct stores a hook of the proxy, an HTTP POST to URL or a local
+	// Command, both receive the metadata of the proxy in JSON.
+	Hook struct {
+		Headers map[string]string `yaml:"headers,omitempty"`
+		Env     map[string]string `yaml:"env,omitempty"`
+		URL     string            `validate:"required_without=Command,excluded_with=Command,omitempty,url" yaml:"url,omitempty"`
+		Command string            `yaml:"command,omitempty"`
+		Args    []string          `yaml:"args,omitempty"`
+		Timeout time.Duration     `validate:"gte=0" yaml:"timeout,omitempty"`
 	}
 
 	// Tailscale struct stores the configuration for tailscale ProxyProvider
 	Tailscale struct {
 		Tags         string `yaml:"tags"`
 		AuthKey      string `yaml:"authKey"`
+		ControlURL   string `validate:"omitempty,uri" yaml:"controlUrl,omitempty"`
 		Ephemeral    bool   `default:"false" validate:"boolean" yaml:"ephemeral"`
 		RunWebClient bool   `default:"false" validate:"boolean" yaml:"runWebClient"`
 		Verbose      bool   `default:"false" validate:"boolean" yaml:"verbose"`
@@ -34,14 +147,83 @@ type (
 	Dashboard struct {
 		Label   string `validate:"string" yaml:"label"`
 		Icon    string `default:"tsdproxy" validate:"string" yaml:"icon"`
+		Group   string `validate:"string" yaml:"group"`
 		Visible bool   `default:"true" validate:"boolean" yaml:"visible"`
 	}
 
 	PortConfigList map[string]PortConfig
 )
 
+// IsEnabled returns true if a command is configured.
+func (e *Exec) IsEnabled() bool {
+	return e.Command != ""
+}
+
+// ForStatus method returns the name of the event of a status and its hooks,
+// statuses without hooks return no hooks.
+func (h *Hooks) ForStatus(status ProxyStatus) (string, []Hook) {
+	switch status {
+	case ProxyStatusStarting:
+		return "onStart", h.OnStart
+	case ProxyStatusRunning:
+		return "onRunning", h.OnRunning
+	case ProxyStatusStopped:
+		return "onStop", h.OnStop
+	case ProxyStatusError:
+		return "onError", h.OnError
+	default:
+		return "", nil
+	}
+}
+
+// IsEnabled returns true if a zone is configured.
+func (c *CachePurge) IsEnabled() bool {
+	return c.Zone != ""
+}
+
+// IsEnabled returns true if a name is configured.
+func (d *PublicDNS) IsEnabled() bool {
+	return d.Name != ""
+}
+
+// AddHTTPSRedirect method adds a port 80 that redirects the requests to the
+// https port of the proxy with the same host, when RedirectToHTTPS is set.
+// Proxies that already have a port 80 or no https port are not changed.
+func (c *Config) AddHTTPSRedirect() {
+	if !c.RedirectToHTTPS {
+		return
+	}
+
+	httpsPort := 0
+	for _, p := range c.Ports {
+		if p.ProxyPort == HTTPSRedirectPort {
+			return
+		}
+		if p.ProxyProtocol == "https" && !p.IsRedirect && (httpsPort == 0 || p.ProxyPort < httpsPort) {
+			httpsPort = p.ProxyPort
+		}
+	}
+	if httpsPort == 0 {
+		return
+	}
+
+	// the target without host redirects to the host of the request
+	target := &url.URL{Scheme: "https"}
+	if httpsPort != DefaultHTTPSPort {
+		target.Host = ":" + strconv.Itoa(httpsPort)
+	}
+
+	port := defaultPortConfig(HTTPSRedirectPortName)
+	port.ProxyPort = HTTPSRedirectPort
+	port.ProxyProtocol = "http"
+	port.IsRedirect = true
+	port.AddTarget(target)
+
+	c.Ports[HTTPSRedirectPortName] = port
+}
+
 func NewConfig() (*Config, error) {
-	config := new(Config)
+	config := &Config{Provenance: make(Provenance)}
 
 	err := defaults.Set(config)
 	if err != nil {
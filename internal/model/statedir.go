@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+import "time"
+
+// StateDir struct stores the details of the state directory of a node.
+type StateDir struct {
+	// Unused is when the directory stopped being used by a proxy, nil
+	// while a proxy uses it
+	Unused *time.Time
+	Name   string
+	Path   string
+	Size   int64
+}
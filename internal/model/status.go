@@ -2,9 +2,21 @@
 // SPDX-License-Identifier: MIT
 package model
 
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
 type (
 	ProxyStatus int
 
+	// StatusChange struct stores a status transition of a proxy.
+	StatusChange struct {
+		Time   time.Time
+		Status ProxyStatus
+	}
+
 	ProxyEvent struct {
 		ID      string
 		Port    string
@@ -21,6 +33,9 @@ const (
 	ProxyStatusStopping
 	ProxyStatusStopped
 	ProxyStatusError
+	// ProxyStatusConflict is the status of the proxies not started because
+	// their hostname is used by another proxy.
+	ProxyStatusConflict
 )
 
 var proxyStatusStrings = []string{
@@ -31,8 +46,21 @@ var proxyStatusStrings = []string{
 	"Stopping",
 	"Stopped",
 	"Error",
+	"Conflict",
 }
 
 func (s *ProxyStatus) String() string {
 	return proxyStatusStrings[int(*s)]
 }
+
+var ErrInvalidProxyStatus = errors.New("invalid proxy status")
+
+// ParseProxyStatus function returns the ProxyStatus of its String value.
+func ParseProxyStatus(s string) (ProxyStatus, error) {
+	i := slices.Index(proxyStatusStrings, s)
+	if i < 0 {
+		return 0, ErrInvalidProxyStatus
+	}
+
+	return ProxyStatus(i), nil
+}
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package model
+
+import "time"
+
+// TargetHealth struct stores the health of a target of a port with multiple
+// targets, used to send more requests to the healthier targets, or of a
+// target with an active health check.
+type TargetHealth struct {
+	// Target is the target URL without credentials.
+	Target string
+	// Check is the type of the active health check, empty without one.
+	Check string
+	// LastError is the error of the last failed health check.
+	LastError string
+	// Latency is the rolling average time to the response headers, or the
+	// duration of the last health check without requests.
+	Latency time.Duration
+	// ErrorRate is the rolling rate of failed requests, from 0 to 1.
+	ErrorRate float64
+	// Score is the health of the target, from 0 to 1. Requests are sent to
+	// the targets proportionally to their score.
+	Score    float64
+	Requests uint64
+	Checks   uint64
+	// Down is set when the active health check failed, no requests are
+	// sent to the target while others are up.
+	Down bool
+	// Breaker is the state of the circuit breaker of the target, closed,
+	// half-open or open, empty without one.
+	Breaker string
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package notify
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+// Events sent to the notification sinks.
+const (
+	EventProxyError           EventType = "proxyError"
+	EventAuthNeeded           EventType = "authNeeded"
+	EventHostnameConflict     EventType = "hostnameConflict"
+	EventCertRenewalFailed    EventType = "certRenewalFailed"
+	EventCertInvalid          EventType = "certInvalid"
+	EventCertExpiring         EventType = "certExpiring"
+	EventProviderDisconnected EventType = "providerDisconnected"
+	EventProviderReconnected  EventType = "providerReconnected"
+	EventStateRecovered       EventType = "stateRecovered"
+)
+
+const (
+	// queueSize is the number of events waiting to be sent, newer events
+	// are dropped when the queue is full.
+	queueSize   = 100
+	sendTimeout = 30 * time.Second
+)
+
+type (
+	EventType string
+
+	// Event struct is a notification sent to the sinks.
+	Event struct {
+		Time     time.Time `json:"time"`
+		Type     EventType `json:"type"`
+		Proxy    string    `json:"proxy,omitempty"`
+		Provider string    `json:"provider,omitempty"`
+		Message  string    `json:"message"`
+		AuthURL  string    `json:"authUrl,omitempty"`
+	}
+
+	// Notifier struct sends events to the configured sinks.
+	Notifier struct {
+		log   zerolog.Logger
+		queue chan Event
+		sinks []*sink
+	}
+
+	// sink struct is a configured notification sink with its event filter.
+	sink struct {
+		sender
+		name   string
+		events []string
+	}
+
+	// sender interface is implemented by the notification services.
+	sender interface {
+		Send(ctx context.Context, event Event) error
+	}
+)
+
+// New function returns a new Notifier, nil if no sink is configured.
+func New(log zerolog.Logger, cfg map[string]*config.NotificationConfig) *Notifier {
+	n := &Notifier{
+		log:   core.ModuleLogger(log, "notify"),
+		queue: make(chan Event, queueSize),
+	}
+
+	for name, c := range cfg {
+		var s sender
+
+		switch c.Type {
+		case "webhook":
+			s = newWebhookSender(c)
+		case "slack":
+			s = newSlackSender(c)
+		case "ntfy":
+			s = newNtfySender(c)
+		case "email":
+			s = newEmailSender(c.Email)
+		default:
+			n.log.Warn().Str("sink", name).Str("type", c.Type).Msg("Unknown notification type")
+			continue
+		}
+
+		n.sinks = append(n.sinks, &sink{sender: s, name: name, events: c.Events})
+	}
+
+	if len(n.sinks) == 0 {
+		return nil
+	}
+
+	sort.Slice(n.sinks, func(i, j int) bool {
+		return n.sinks[i].name < n.sinks[j].name
+	})
+
+	return n
+}
+
+// Start method sends the queued events until the context is done.
+func (n *Notifier) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-n.queue:
+				n.send(ctx, event)
+			}
+		}
+	}()
+}
+
+// Notify method queues an event to be sent. It never blocks, so it's safe
+// to call from status updates, and does nothing in a nil Notifier.
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		n.log.Warn().Str("type", string(event.Type)).Msg("Notification queue is full, event dropped")
+	}
+}
+
+// send method sends an event to the sinks that want it.
+func (n *Notifier) send(ctx context.Context, event Event) {
+	for _, s := range n.sinks {
+		if !s.wants(event.Type) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := s.Send(ctx, event)
+		cancel()
+
+		if err != nil {
+			n.log.Error().Err(err).Str("sink", s.name).Str("type", string(event.Type)).Msg("Error sending notification")
+			continue
+		}
+
+		n.log.Debug().Str("sink", s.name).Str("type", string(event.Type)).Msg("Notification sent")
+	}
+}
+
+// wants method returns true if the sink sends the events of the type,
+// sinks without a filter send all events.
+func (s *sink) wants(t EventType) bool {
+	return len(s.events) == 0 || slices.Contains(s.events, string(t))
+}
+
+// Title method returns a short description of the event.
+func (e Event) Title() string {
+	switch e.Type {
+	case EventProxyError:
+		return "Proxy " + e.Proxy + " failed"
+	case EventAuthNeeded:
+		return "Proxy " + e.Proxy + " needs authentication"
+	case EventCertRenewalFailed:
+		return "Certificate renewal failed"
+	case EventProviderDisconnected:
+		return "Provider " + e.Provider + " disconnected"
+	}
+
+	return string(e.Type)
+}
+
+// Text method returns the title, message and auth URL of the event in
+// plain text.
+func (e Event) Text() string {
+	text := e.Title()
+	if e.Message != "" {
+		text += "\n" + e.Message
+	}
+	if e.AuthURL != "" {
+		text += "\n" + e.AuthURL
+	}
+
+	return text
+}
@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+)
+
+const (
+	httpTimeout      = 30 * time.Second
+	maxErrorBodySize = 4096
+)
+
+type (
+	// webhookSender posts the event in JSON.
+	webhookSender struct {
+		client *http.Client
+		url    string
+		token  string
+	}
+
+	// slackSender posts the event to a Slack incoming webhook.
+	slackSender struct {
+		client *http.Client
+		url    string
+	}
+
+	// ntfySender publishes the event to a ntfy topic.
+	ntfySender struct {
+		client *http.Client
+		url    string
+		token  string
+	}
+
+	// emailSender sends the event by email, with STARTTLS if the server
+	// supports it.
+	emailSender struct {
+		cfg config.EmailNotificationConfig
+	}
+)
+
+func newWebhookSender(cfg *config.NotificationConfig) *webhookSender {
+	return &webhookSender{
+		client: &http.Client{Timeout: httpTimeout},
+		url:    cfg.URL,
+		token:  cfg.Token,
+	}
+}
+
+// Send method implements sender Send method.
+func (s *webhookSender) Send(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	if s.token != "" {
+		header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	return post(ctx, s.client, s.url, header, data)
+}
+
+func newSlackSender(cfg *config.NotificationConfig) *slackSender {
+	return &slackSender{
+		client: &http.Client{Timeout: httpTimeout},
+		url:    cfg.URL,
+	}
+}
+
+// Send method implements sender Send method.
+func (s *slackSender) Send(ctx context.Context, event Event) error {
+	text := "*" + event.Title() + "*"
+	if event.Message != "" {
+		text += "\n" + event.Message
+	}
+	if event.AuthURL != "" {
+		text += "\n<" + event.AuthURL + "|Authenticate>"
+	}
+
+	data, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	return post(ctx, s.client, s.url, http.Header{"Content-Type": {"application/json"}}, data)
+}
+
+func newNtfySender(cfg *config.NotificationConfig) *ntfySender {
+	return &ntfySender{
+		client: &http.Client{Timeout: httpTimeout},
+		url:    cfg.URL,
+		token:  cfg.Token,
+	}
+}
+
+// Send method implements sender Send method.
+func (s *ntfySender) Send(ctx context.Context, event Event) error {
+	message := event.Message
+	if message == "" {
+		message = event.Title()
+	}
+
+	header := http.Header{
+		"Title": {event.Title()},
+		"Tags":  {string(event.Type)},
+	}
+	if event.AuthURL != "" {
+		header.Set("Click", event.AuthURL)
+	}
+	if s.token != "" {
+		header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	return post(ctx, s.client, s.url, header, []byte(message))
+}
+
+func newEmailSender(cfg config.EmailNotificationConfig) *emailSender {
+	return &emailSender{cfg: cfg}
+}
+
+// Send method implements sender Send method.
+func (s *emailSender) Send(_ context.Context, event Event) error {
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "tsdproxy: "+event.Title()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(event.Text(), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(int(s.cfg.Port)))
+
+	return smtp.SendMail(addr, auth, s.cfg.From, s.cfg.To, msg.Bytes())
+}
+
+// post function posts data to the url and returns an error if the response
+// isn't successful.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package problems keeps the failures of the subsystems of the server, shown
+// together in the dashboard until they are resolved.
+package problems
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Sources of the problems.
+const (
+	SourceProvider    Source = "provider"
+	SourceProxy       Source = "proxy"
+	SourceCertificate Source = "certificate"
+	SourceDNS         Source = "dns"
+	SourceConfig      Source = "config"
+
+	// SourceCertificateCheck are the revoked certificates and broken chains
+	SourceCertificateCheck Source = "certificateCheck"
+)
+
+type (
+	Source string
+
+	// Problem struct is a failure of a subsystem. A subject has one problem
+	// per source, reporting it again updates the problem.
+	Problem struct {
+		Since   time.Time `json:"since"`
+		Updated time.Time `json:"updated"`
+		Source  Source    `json:"source"`
+		Subject string    `json:"subject"`
+		Message string    `json:"message"`
+		// Fix is the suggested fix of the problem
+		Fix string `json:"fix,omitempty"`
+	}
+
+	// Registry struct stores the problems reported by the subsystems.
+	Registry struct {
+		problems map[key]*Problem
+		mtx      sync.RWMutex
+	}
+
+	key struct {
+		source  Source
+		subject string
+	}
+)
+
+// New function returns a new empty Registry.
+func New() *Registry {
+	return &Registry{
+		problems: make(map[key]*Problem),
+	}
+}
+
+// Report method adds a problem, or updates the message of a problem of the
+// same source and subject. It does nothing in a nil Registry.
+func (r *Registry) Report(p Problem) {
+	if r == nil {
+		return
+	}
+
+	now := time.Now()
+	k := key{source: p.Source, subject: p.Subject}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	p.Since, p.Updated = now, now
+	if old, ok := r.problems[k]; ok {
+		p.Since = old.Since
+	}
+	r.problems[k] = &p
+}
+
+// Resolve method removes the problem of the source and subject, if any.
+func (r *Registry) Resolve(source Source, subject string) {
+	if r == nil {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.problems, key{source: source, subject: subject})
+}
+
+// List method returns the problems, the most recently updated first.
+func (r *Registry) List() []Problem {
+	if r == nil {
+		return []Problem{}
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	list := make([]Problem, 0, len(r.problems))
+	for _, p := range r.problems {
+		list = append(list, *p)
+	}
+
+	slices.SortFunc(list, func(a, b Problem) int {
+		return cmp.Or(
+			b.Updated.Compare(a.Updated),
+			cmp.Compare(a.Source, b.Source),
+			cmp.Compare(a.Subject, b.Subject),
+		)
+	})
+
+	return list
+}
@@ -0,0 +1,340 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	// ewmaWeight is the weight of the last request in the rolling averages.
+	ewmaWeight = 0.2
+	// referenceLatency is the latency that halves the score of a target.
+	referenceLatency = 100 * time.Millisecond
+	// minScore keeps sending some requests to unhealthy targets, so they
+	// can recover their score.
+	minScore = 0.05
+
+	// defaultRetryBudget is the ratio of retries to requests of ports
+	// without a retry budget.
+	defaultRetryBudget = 0.2
+	// maxRetryTokens is the number of retries saved by ports without
+	// requests, so ports with little traffic can retry.
+	maxRetryTokens = 10
+)
+
+type (
+	// balancer struct spreads the requests of a port between its targets,
+	// biased to the targets with lower latency and error rate.
+	balancer struct {
+		next    http.RoundTripper
+		breaker *circuitBreaker
+		targets []*target
+		retry   model.Retry
+		// retryTokens is the retry budget, each request adds the budget
+		// ratio and each retry takes one
+		retryTokens float64
+		mtx         sync.Mutex
+	}
+
+	// target struct stores the rolling latency and error rate of a target.
+	target struct {
+		url       *url.URL
+		latency   float64 // seconds
+		errorRate float64
+		requests  uint64
+		breaker   targetBreaker
+		// down is set by the health check of the port
+		down bool
+	}
+
+	balancerContextKey struct{}
+)
+
+// newBalancer function returns a balancer for the targets. The balancer is
+// also the RoundTripper that measures the requests sent with next, and
+// retries them in the next target when the connection fails.
+func newBalancer(targets []*url.URL, retry model.Retry, breaker *circuitBreaker, next http.RoundTripper) *balancer {
+	if retry.Budget <= 0 {
+		retry.Budget = defaultRetryBudget
+	}
+
+	b := &balancer{
+		next:        next,
+		breaker:     breaker,
+		retry:       retry,
+		retryTokens: maxRetryTokens,
+	}
+	for _, u := range targets {
+		b.targets = append(b.targets, &target{url: u})
+	}
+
+	return b
+}
+
+// pick method returns a target chosen randomly, weighted by its score.
+// Targets down are skipped while other targets are up, and targets with an
+// open circuit breaker are always skipped. It returns nil when the breakers
+// of all targets are open.
+func (b *balancer) pick() *target {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	allowed := make([]*target, 0, len(b.targets))
+	for _, t := range b.targets {
+		if b.breaker.allows(t, now) {
+			allowed = append(allowed, t)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	up := slices.ContainsFunc(allowed, func(t *target) bool { return !t.down })
+	weight := func(t *target) float64 {
+		if t.down && up {
+			return 0
+		}
+		return max(t.score(), minScore)
+	}
+
+	total := 0.0
+	for _, t := range allowed {
+		total += weight(t)
+	}
+
+	picked := allowed[len(allowed)-1]
+	n := rand.Float64() * total //nolint:gosec
+	for _, t := range allowed {
+		n -= weight(t)
+		if n < 0 {
+			picked = t
+			break
+		}
+	}
+	b.breaker.acquire(picked, now)
+
+	return picked
+}
+
+// withTarget function returns the request context with the target, so it's
+// measured in RoundTrip.
+func withTarget(ctx context.Context, t *target) context.Context {
+	return context.WithValue(ctx, balancerContextKey{}, t)
+}
+
+// RoundTrip method implements http.RoundTripper RoundTrip method.
+// Connection errors and 5xx responses count as failed requests, requests
+// canceled by the client are not counted. Idempotent requests that fail to
+// connect are sent to the next target, within the attempts and the retry
+// budget of the port.
+func (b *balancer) RoundTrip(r *http.Request) (*http.Response, error) {
+	t, ok := r.Context().Value(balancerContextKey{}).(*target)
+	if !ok {
+		return b.next.RoundTrip(r)
+	}
+	if t == nil {
+		return nil, ErrCircuitOpen
+	}
+
+	b.mtx.Lock()
+	b.retryTokens = min(b.retryTokens+b.retry.Budget, maxRetryTokens)
+	b.mtx.Unlock()
+
+	tried := []*target{t}
+	for {
+		resp, err := b.send(r, t)
+		if err == nil || len(tried) >= b.retry.Attempts || !isRetryable(r, err) {
+			return resp, err
+		}
+
+		next := b.nextTarget(tried)
+		if next == nil || !b.takeRetry() {
+			return resp, err
+		}
+
+		r = retryRequest(r, t, next)
+		t = next
+		tried = append(tried, t)
+	}
+}
+
+// send method sends a request to a target and measures it.
+func (b *balancer) send(r *http.Request, t *target) (*http.Response, error) {
+	start := time.Now()
+	resp, err := b.next.RoundTrip(r)
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		b.breaker.release(t)
+		return resp, err
+	}
+
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+
+	t.observe(time.Since(start), failed)
+	b.breaker.record(t, failed, time.Now())
+
+	return resp, err
+}
+
+// nextTarget method returns the target after the last one tried, in the
+// order of the targets of the port. Targets tried, down or with an open
+// circuit breaker are skipped.
+func (b *balancer) nextTarget(tried []*target) *target {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	last := slices.Index(b.targets, tried[len(tried)-1])
+	for i := 1; i < len(b.targets); i++ {
+		t := b.targets[(last+i)%len(b.targets)]
+		if !t.down && !slices.Contains(tried, t) && b.breaker.allows(t, now) {
+			b.breaker.acquire(t, now)
+			return t
+		}
+	}
+
+	return nil
+}
+
+// takeRetry method takes a retry from the budget, if there's one left.
+func (b *balancer) takeRetry() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.retryTokens < 1 {
+		return false
+	}
+	b.retryTokens--
+
+	return true
+}
+
+// isRetryable function returns true if a failed request can be sent again:
+// it's idempotent, has no body to replay and wasn't canceled by the client.
+func isRetryable(r *http.Request, err error) bool {
+	if errors.Is(err, context.Canceled) || r.Context().Err() != nil {
+		return false
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// retryRequest function returns a copy of a request sent to another target,
+// the path of the previous target is replaced with the path of the next.
+func retryRequest(r *http.Request, prev, next *target) *http.Request {
+	out := r.Clone(withTarget(r.Context(), next))
+
+	path, ok := strings.CutPrefix(r.URL.Path, strings.TrimSuffix(prev.url.Path, "/"))
+	if !ok {
+		path = r.URL.Path
+	}
+
+	out.URL.Scheme = next.url.Scheme
+	out.URL.Host = next.url.Host
+	out.URL.Path = strings.TrimSuffix(next.url.Path, "/") + path
+	out.URL.RawPath = ""
+	out.Host = ""
+
+	return out
+}
+
+// health method returns the health of all targets.
+func (b *balancer) health() []model.TargetHealth {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	health := make([]model.TargetHealth, len(b.targets))
+	for i, t := range b.targets {
+		health[i] = model.TargetHealth{
+			Target:    t.url.Redacted(),
+			Latency:   time.Duration(t.latency * float64(time.Second)),
+			ErrorRate: t.errorRate,
+			Score:     t.score(),
+			Requests:  t.requests,
+			Down:      t.down,
+		}
+		if b.breaker != nil {
+			health[i].Breaker = t.breaker.state.String()
+		}
+	}
+
+	return health
+}
+
+// close method removes the metrics of the targets.
+func (b *balancer) close() {
+	if b == nil {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.breaker.deleteMetrics(b.targets)
+}
+
+// setDown method marks a target as down or up, from the health check of
+// the port.
+func (b *balancer) setDown(i int, down bool) {
+	if b == nil {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if i < len(b.targets) {
+		b.targets[i].down = down
+	}
+}
+
+// observe method updates the rolling averages with a request.
+func (t *target) observe(latency time.Duration, failed bool) {
+	errorValue := 0.0
+	if failed {
+		errorValue = 1
+	}
+
+	// the first request sets the averages
+	if t.requests == 0 {
+		t.latency = latency.Seconds()
+		t.errorRate = errorValue
+	} else {
+		t.latency += ewmaWeight * (latency.Seconds() - t.latency)
+		t.errorRate += ewmaWeight * (errorValue - t.errorRate)
+	}
+	t.requests++
+}
+
+// score method returns the health of the target from 0 to 1. Targets without
+// requests have the best score, so they are tried.
+func (t *target) score() float64 {
+	ref := referenceLatency.Seconds()
+
+	return (1 - t.errorRate) * ref / (ref + t.latency)
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+func TestBalancerPick(t *testing.T) {
+	const openTimeout = time.Minute
+
+	now := time.Now()
+	closed := targetBreaker{}
+	open := targetBreaker{state: breakerOpen, openedAt: now}
+	expired := targetBreaker{state: breakerOpen, openedAt: now.Add(-2 * openTimeout)}
+	probing := targetBreaker{state: breakerHalfOpen, openedAt: now.Add(-2 * openTimeout), probing: true, probedAt: now}
+
+	tests := []struct {
+		name     string
+		breakers []targetBreaker
+		down     []bool
+		// allowed are the targets that can be picked, none when all the
+		// circuits are open
+		allowed []int
+	}{
+		{
+			name:     "all closed",
+			breakers: []targetBreaker{closed, closed},
+			allowed:  []int{0, 1},
+		},
+		{
+			name:     "one open",
+			breakers: []targetBreaker{open, closed},
+			allowed:  []int{1},
+		},
+		{
+			name:     "all open",
+			breakers: []targetBreaker{open, open},
+		},
+		{
+			name:     "all open or probing",
+			breakers: []targetBreaker{open, probing},
+		},
+		{
+			name:     "open timeout expired",
+			breakers: []targetBreaker{open, expired},
+			allowed:  []int{1},
+		},
+		{
+			name:     "down skipped while others are up",
+			breakers: []targetBreaker{closed, closed},
+			down:     []bool{true, false},
+			allowed:  []int{1},
+		},
+		{
+			name:     "all down",
+			breakers: []targetBreaker{closed, open},
+			down:     []bool{true, true},
+			allowed:  []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newCircuitBreaker("proxy", "port", model.CircuitBreaker{ErrorRate: 0.5, OpenTimeout: openTimeout})
+
+			targets := make([]*url.URL, len(tt.breakers))
+			for i := range targets {
+				targets[i] = &url.URL{Scheme: "http", Host: "127.0.0.1:" + strconv.Itoa(8080+i)}
+			}
+
+			// every pick starts again from the states of the case
+			for range 20 {
+				b := newBalancer(targets, model.Retry{}, breaker, http.DefaultTransport)
+				for i, tb := range tt.breakers {
+					b.targets[i].breaker = tb
+					if tt.down != nil {
+						b.targets[i].down = tt.down[i]
+					}
+				}
+
+				picked := b.pick()
+				if len(tt.allowed) == 0 {
+					if picked != nil {
+						t.Fatalf("picked %s, want none", picked.url)
+					}
+					continue
+				}
+				if picked == nil {
+					t.Fatal("picked none")
+				}
+
+				index := slices.Index(b.targets, picked)
+				if !slices.Contains(tt.allowed, index) {
+					t.Fatalf("picked target %d, want one of %v", index, tt.allowed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	// bannersFile is the file in the data directory that stores the banners.
+	bannersFile = "banners.yaml"
+	// bannerScanLimit is the size of the start of a page searched for the
+	// body tag, larger pages are sent without the banner.
+	bannerScanLimit = 64 << 10
+)
+
+var (
+	ErrBannerNotFound = errors.New("banner not found")
+	ErrBannerMessage  = errors.New("banner message is required")
+	ErrBannerEnd      = errors.New("banner end must be after its start and in the future")
+)
+
+type (
+	// bannerStore struct stores the banners added in the dashboard or the
+	// API, shared by all proxies.
+	bannerStore struct {
+		file  *config.ConfigFile
+		state bannerState
+		mtx   sync.RWMutex
+	}
+
+	bannerState struct {
+		Banners []model.Banner `yaml:"banners"`
+	}
+
+	// bannerWriter struct is a http.ResponseWriter that inserts the banner
+	// after the body tag of HTML responses.
+	bannerWriter struct {
+		http.ResponseWriter
+		banner      []byte
+		buf         bytes.Buffer
+		inject      bool
+		done        bool
+		wroteHeader bool
+	}
+)
+
+// loadBanners method loads the banners from the data directory.
+func (pm *ProxyManager) loadBanners() {
+	b := pm.banners
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.file = config.NewConfigFile(pm.log, filepath.Join(config.Config.Tailscale.DataDir, bannersFile), &b.state)
+
+	if err := b.file.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		pm.log.Error().Err(err).Msg("Error loading banners")
+	}
+}
+
+// GetBanners method returns the banners, expired ones included until they
+// are deleted.
+func (pm *ProxyManager) GetBanners() []model.Banner {
+	b := pm.banners
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	return slices.Clone(b.state.Banners)
+}
+
+// AddBanner method adds a banner and saves the banners. If they can't be
+// saved, like in a read-only data directory, they are kept in memory until
+// tsdproxy restarts.
+func (pm *ProxyManager) AddBanner(banner model.Banner) (model.Banner, error) {
+	banner.Message = strings.TrimSpace(banner.Message)
+	if banner.Message == "" {
+		return model.Banner{}, ErrBannerMessage
+	}
+	if banner.Start.IsZero() {
+		banner.Start = time.Now()
+	}
+	if !banner.End.After(banner.Start) || !banner.End.After(time.Now()) {
+		return model.Banner{}, ErrBannerEnd
+	}
+	banner.ID = uuid.New().String()
+
+	b := pm.banners
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.state.Banners = append(b.state.Banners, banner)
+	b.save(pm.log)
+
+	pm.log.Info().Str("banner", banner.ID).Time("start", banner.Start).Time("end", banner.End).Msg("Banner added")
+
+	return banner, nil
+}
+
+// DeleteBanner method deletes a banner and saves the banners.
+func (pm *ProxyManager) DeleteBanner(id string) error {
+	b := pm.banners
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	i := slices.IndexFunc(b.state.Banners, func(banner model.Banner) bool { return banner.ID == id })
+	if i < 0 {
+		return ErrBannerNotFound
+	}
+	b.state.Banners = slices.Delete(b.state.Banners, i, i+1)
+	b.save(pm.log)
+
+	pm.log.Info().Str("banner", id).Msg("Banner deleted")
+
+	return nil
+}
+
+// save method saves the banners, they are only kept in memory before they
+// are loaded. Called with the lock held.
+func (b *bannerStore) save(log zerolog.Logger) {
+	if b.file == nil {
+		return
+	}
+
+	if err := b.file.Save(); err != nil {
+		log.Error().Err(err).Msg("Error saving banners")
+	}
+}
+
+// active method returns the HTML of the banners shown by the proxy now,
+// nil if there are none.
+func (b *bannerStore) active(proxy string) []byte {
+	if b == nil {
+		return nil
+	}
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	var out []byte
+	now := time.Now()
+	for _, banner := range b.state.Banners {
+		if banner.Active(now) && banner.AppliesTo(proxy) {
+			out = append(out, `<div class="tsdproxy-banner" role="status" style="`+
+				`position:relative;z-index:2147483647;padding:8px 16px;background:#fde68a;color:#1f2937;`+
+				`font:14px/1.4 system-ui,sans-serif;text-align:center;border-bottom:1px solid #f59e0b">`+
+				html.EscapeString(banner.Message)+`</div>`...)
+		}
+	}
+
+	return out
+}
+
+// bannerMiddleware function returns a middleware that injects the active
+// banners of the proxy into its HTML pages.
+func bannerMiddleware(proxyName string, banners *bannerStore) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if banners == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			banner := banners.active(proxyName)
+			if banner == nil || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// compressed pages can't be changed, the transport decompresses
+			// the target response
+			r.Header.Del("Accept-Encoding")
+
+			bw := &bannerWriter{ResponseWriter: w, banner: banner}
+			defer bw.finish()
+
+			next.ServeHTTP(bw, r)
+		})
+	}
+}
+
+func (w *bannerWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	w.inject = mediaType == "text/html" && h.Get("Content-Encoding") == "" &&
+		code != http.StatusNoContent && code != http.StatusNotModified
+	if w.inject {
+		h.Del("Content-Length")
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bannerWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.inject || w.done {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf.Write(p)
+
+	if i := bodyTagEnd(w.buf.Bytes()); i >= 0 {
+		data := w.buf.Bytes()
+		w.done = true
+		if _, err := w.ResponseWriter.Write(data[:i]); err != nil {
+			return 0, err
+		}
+		if _, err := w.ResponseWriter.Write(w.banner); err != nil {
+			return 0, err
+		}
+		if _, err := w.ResponseWriter.Write(data[i:]); err != nil {
+			return 0, err
+		}
+		w.buf.Reset()
+	} else if w.buf.Len() > bannerScanLimit {
+		if err := w.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush method implements http.Flusher Flush method. The start of a page is
+// buffered until its body tag, the reverse proxy flushes every write of
+// responses without length.
+func (w *bannerWriter) Flush() {
+	if w.inject && !w.done {
+		return
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *bannerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish method sends the buffered start of pages without a body tag.
+func (w *bannerWriter) finish() {
+	if w.inject && !w.done {
+		_ = w.flushBuffer()
+	}
+}
+
+// flushBuffer method sends the buffered data without the banner.
+func (w *bannerWriter) flushBuffer() error {
+	w.done = true
+	_, err := w.buf.WriteTo(w.ResponseWriter)
+
+	return err
+}
+
+// bodyTagEnd function returns the position after the body start tag of a
+// page, -1 if it wasn't found.
+func bodyTagEnd(data []byte) int {
+	start := bytes.Index(bytes.ToLower(data), []byte("<body"))
+	if start < 0 {
+		return -1
+	}
+
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return -1
+	}
+
+	return start + end + 1
+}
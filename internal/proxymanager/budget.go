@@ -0,0 +1,276 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// requestsPerCPU and memoryPerRequest size the budget without
+	// maxRequests, from the CPUs and memory of the host.
+	requestsPerCPU   = 256
+	memoryPerRequest = 1 << 20
+	minBudget        = 64
+	// reservedPercent is the part of the budget reserved to the proxies
+	// shares, the rest is borrowed by the busy proxies.
+	reservedPercent = 50
+)
+
+var (
+	budgetSize = metrics.NewGauge(
+		"tsdproxy_request_budget_size",
+		"Requests in flight allowed for all the proxies.",
+	)
+	budgetInFlight = metrics.NewGauge(
+		"tsdproxy_request_budget_in_flight",
+		"Requests in flight of the proxy, counted in the request budget.",
+		"proxy",
+	)
+	budgetBorrowed = metrics.NewGauge(
+		"tsdproxy_request_budget_borrowed",
+		"Requests in flight of the proxy over its share of the request budget.",
+		"proxy",
+	)
+	budgetRejected = metrics.NewCounter(
+		"tsdproxy_request_budget_rejected_total",
+		"Requests rejected with 503 because the request budget was exhausted for queueTimeout.",
+		"proxy",
+	)
+)
+
+type (
+	// requestBudget struct limits the requests in flight of all proxies.
+	// Each proxy with requests has a share of the reserved part of the
+	// budget, and borrows from the rest while other proxies don't need it,
+	// so a busy proxy can't starve the others.
+	requestBudget struct {
+		proxies map[string]*budgetShare
+		// wake is closed when a slot is released, waking the waiting
+		// requests
+		wake    chan struct{}
+		size    int
+		total   int
+		timeout time.Duration
+		mtx     sync.Mutex
+	}
+
+	// budgetShare struct stores the requests in flight of a proxy. Proxies
+	// have a share while they have requests in flight or waiting.
+	budgetShare struct {
+		used     int
+		borrowed int
+	}
+)
+
+// newRequestBudget function returns the request budget of the limits
+// configuration, nil if it's unlimited.
+func newRequestBudget(log zerolog.Logger) *requestBudget {
+	cfg := config.Config.Limits
+
+	size := cfg.MaxRequests
+	switch {
+	case size < 0:
+		return nil
+	case size == 0:
+		size = detectBudget()
+	}
+
+	log.Info().Int("maxRequests", size).Msg("Request budget")
+	budgetSize.Add(int64(size))
+
+	return &requestBudget{
+		proxies: make(map[string]*budgetShare),
+		wake:    make(chan struct{}),
+		size:    size,
+		timeout: cfg.QueueTimeout,
+	}
+}
+
+// acquire method takes a slot for a request of the proxy, waiting up to the
+// queue timeout for a slot. It returns false if no slot was available, and
+// if the slot is borrowed from other shares.
+func (b *requestBudget) acquire(ctx context.Context, name string) (bool, bool) {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+
+	for {
+		b.mtx.Lock()
+		s, ok := b.proxies[name]
+		if !ok {
+			s = &budgetShare{}
+			b.proxies[name] = s
+		}
+
+		if admit, borrowed := b.admit(name, s); admit {
+			s.used++
+			b.total++
+			if borrowed {
+				s.borrowed++
+			}
+			b.mtx.Unlock()
+			return true, borrowed
+		}
+		wake := b.wake
+		b.mtx.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			b.mtx.Lock()
+			if s.used == 0 && b.proxies[name] == s {
+				delete(b.proxies, name)
+			}
+			b.mtx.Unlock()
+			return false, false
+		}
+	}
+}
+
+// admit method returns true if a request of the proxy can be admitted, and
+// if it borrows a slot. Borrowed slots leave the unused shares of the other
+// proxies free, so the slots released by a busy proxy go to the others
+// waiting. Called with the lock held.
+func (b *requestBudget) admit(name string, s *budgetShare) (bool, bool) {
+	if b.total >= b.size {
+		return false, false
+	}
+
+	share := b.share()
+	if s.used < share {
+		return true, false
+	}
+
+	reserved := 0
+	for n, other := range b.proxies {
+		if n != name {
+			reserved += max(0, share-other.used)
+		}
+	}
+
+	return b.total+reserved < b.size, true
+}
+
+// share method returns the reserved slots of each proxy with requests.
+// Called with the lock held.
+func (b *requestBudget) share() int {
+	return max(1, b.size*reservedPercent/100/max(1, len(b.proxies)))
+}
+
+// release method frees the slot of a request.
+func (b *requestBudget) release(name string, borrowed bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.total--
+	if s, ok := b.proxies[name]; ok {
+		s.used--
+		if borrowed {
+			s.borrowed--
+		}
+		// idle proxies don't keep a share
+		if s.used == 0 {
+			delete(b.proxies, name)
+		}
+	}
+
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+// budgetMiddleware function returns a middleware that holds a slot of the
+// budget while the request is proxied. Upgraded connections, like
+// websockets, aren't counted since they last as long as the client wants.
+func budgetMiddleware(proxyName string, b *requestBudget) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if b == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ok, borrowed := b.acquire(r.Context(), proxyName)
+			if !ok {
+				budgetRejected.Inc(proxyName)
+				w.Header().Set("Retry-After", strconv.Itoa(1))
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			budgetInFlight.Add(1, proxyName)
+			if borrowed {
+				budgetBorrowed.Add(1, proxyName)
+			}
+			defer func() {
+				b.release(proxyName, borrowed)
+				budgetInFlight.Add(-1, proxyName)
+				if borrowed {
+					budgetBorrowed.Add(-1, proxyName)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// detectBudget function returns the budget of the host, from its CPUs and
+// memory limit.
+func detectBudget() int {
+	size := runtime.GOMAXPROCS(0) * requestsPerCPU
+	if memory := memoryLimit(); memory > 0 {
+		size = min(size, int(memory/memoryPerRequest))
+	}
+
+	return max(size, minBudget)
+}
+
+// memoryLimit function returns the memory of the host, or the memory limit
+// of the container cgroup when it's lower. 0 if unknown.
+func memoryLimit() int64 {
+	var limit int64
+
+	if f, err := os.Open("/proc/meminfo"); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if v, ok := strings.CutPrefix(scanner.Text(), "MemTotal:"); ok {
+				kb, _ := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "kB")), 10, 64)
+				limit = kb << 10
+				break
+			}
+		}
+		f.Close()
+	}
+
+	// cgroup v2 and v1, unlimited cgroups are "max" or a huge number
+	for _, file := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && v > 0 && (limit == 0 || v < limit) {
+			limit = v
+		}
+		break
+	}
+
+	return limit
+}
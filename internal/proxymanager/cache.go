@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"path/filepath"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/respcache"
+)
+
+// cacheDir is the directory of the disk response caches in the data directory.
+const cacheDir = "cache"
+
+// resetCaches method removes the disk response caches of a previous run.
+func (pm *ProxyManager) resetCaches() {
+	if err := respcache.Reset(filepath.Join(config.Config.Tailscale.DataDir, cacheDir)); err != nil {
+		pm.log.Error().Err(err).Msg("Error removing response caches")
+	}
+}
+
+// newCache method returns the response cache of a port, nil if the port
+// doesn't cache responses.
+func (proxy *Proxy) newCache(name string, pconfig model.PortConfig) (*respcache.Cache, error) {
+	if !pconfig.Cache.Enabled {
+		return nil, nil //nolint:nilnil
+	}
+
+	return respcache.New(proxy.log, proxy.Config().Hostname, name, pconfig.Cache,
+		filepath.Join(config.Config.Tailscale.DataDir, cacheDir))
+}
+
+// PurgeCache method removes the cached responses of the ports with a path
+// matching the pattern, all of them with an empty pattern. Returns how many
+// responses were removed.
+func (proxy *Proxy) PurgeCache(pattern string) int {
+	proxy.mtx.Lock()
+	defer proxy.mtx.Unlock()
+
+	n := 0
+	for _, p := range proxy.ports {
+		n += p.purgeCache(pattern)
+	}
+
+	return n
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/cachepurge"
+)
+
+// SetCachePurger method sets the purger of the Cloudflare cache of proxies.
+func (pm *ProxyManager) SetCachePurger(p *cachepurge.Purger) {
+	pm.mtx.Lock()
+	pm.purger = p
+	pm.mtx.Unlock()
+}
+
+// purgeCache method purges the cache of a proxy that was restarted or
+// updated. The first start of each proxy isn't purged, so starting tsdproxy
+// doesn't purge all the caches.
+func (pm *ProxyManager) purgeCache(p *Proxy) {
+	name := p.Config().Hostname
+	cfg := p.Config().CachePurge
+
+	if !cfg.IsEnabled() {
+		return
+	}
+
+	pm.mtx.Lock()
+	_, started := pm.purgeStarted[name]
+	pm.purgeStarted[name] = struct{}{}
+	purger := pm.purger
+	pm.mtx.Unlock()
+
+	if !started {
+		return
+	}
+
+	if purger == nil {
+		pm.log.Warn().Str("proxy", name).Msg("Cache purge requires the cachePurge apiToken")
+		return
+	}
+
+	pm.log.Debug().Str("proxy", name).Msg("Purging cache")
+	purger.Purge(name, cfg)
+}
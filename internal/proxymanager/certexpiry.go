@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+)
+
+const (
+	// certCheckInterval is the interval between the expiry checks of the
+	// certificates of the proxies.
+	certCheckInterval = time.Hour
+	// certCheckDelay is the wait before the first check, for the proxies to
+	// start.
+	certCheckDelay = time.Minute
+)
+
+var certExpiry = metrics.NewGauge(
+	"tsdproxy_proxy_certificate_expiry_timestamp_seconds",
+	"Expiry time of the TLS certificates of the proxy nodes, in unix seconds.",
+	"proxy", "domain",
+)
+
+// certState struct stores the certificates of a proxy of the last check.
+type certState struct {
+	// notAfter is the expiry of the certificate that expires first
+	notAfter time.Time
+	domains  []string
+	// notified is true when the expiry of notAfter was notified
+	notified bool
+}
+
+// watchCertificates method checks the expiry of the certificates of the
+// running proxies periodically.
+func (pm *ProxyManager) watchCertificates() {
+	time.Sleep(certCheckDelay)
+
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		pm.checkCertificates()
+		<-ticker.C
+	}
+}
+
+// checkCertificates method updates the expiry of the certificates of the
+// proxies, proxies with a certificate expiring in less than
+// certExpiryWarning are updated in the dashboard and notified once.
+func (pm *ProxyManager) checkCertificates() {
+	proxies := pm.GetProxies()
+
+	for name, p := range proxies {
+		if p.GetStatus() != model.ProxyStatusRunning {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), certCheckDelay)
+		certs, err := p.GetCertificates(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		state := certState{domains: make([]string, 0, len(certs))}
+		for _, c := range certs {
+			certExpiry.Set(c.NotAfter.Unix(), name, c.Domain)
+			state.domains = append(state.domains, c.Domain)
+			if state.notAfter.IsZero() || c.NotAfter.Before(state.notAfter) {
+				state.notAfter = c.NotAfter
+			}
+		}
+
+		pm.updateCertState(p, state)
+	}
+
+	// series of removed proxies and domains
+	pm.mtx.Lock()
+	for name, state := range pm.certs {
+		if _, ok := proxies[name]; !ok {
+			for _, domain := range state.domains {
+				certExpiry.Delete(name, domain)
+			}
+			delete(pm.certs, name)
+		}
+	}
+	pm.mtx.Unlock()
+}
+
+// updateCertState method saves the certificates of a proxy, the dashboard
+// is updated when the warning changes.
+func (pm *ProxyManager) updateCertState(p *Proxy, state certState) {
+	name := p.Config().Hostname
+
+	pm.mtx.Lock()
+	old, ok := pm.certs[name]
+	for _, domain := range old.domains {
+		if !slices.Contains(state.domains, domain) {
+			certExpiry.Delete(name, domain)
+		}
+	}
+	state.notified = ok && old.notified && old.notAfter.Equal(state.notAfter)
+	expiring := isExpiring(state.notAfter)
+	send := expiring && !state.notified
+	if send {
+		state.notified = true
+	}
+	pm.certs[name] = state
+	pm.mtx.Unlock()
+
+	if send {
+		pm.log.Warn().Str("proxy", name).Time("notAfter", state.notAfter).Msg("Certificate expiring soon")
+		pm.notify(notify.Event{
+			Type:    notify.EventCertExpiring,
+			Proxy:   name,
+			Message: "The certificate expires on " + state.notAfter.Local().Format(time.DateTime) + ".",
+		})
+	}
+
+	if expiring != isExpiring(old.notAfter) {
+		pm.broadcastStatusEvents(model.ProxyEvent{
+			ID:     name,
+			Status: p.GetStatus(),
+		})
+	}
+}
+
+// CertExpiring method returns the expiry of the certificate of a proxy that
+// expires first, if it expires in less than certExpiryWarning.
+func (pm *ProxyManager) CertExpiring(name string) (time.Time, bool) {
+	pm.mtx.RLock()
+	state, ok := pm.certs[name]
+	pm.mtx.RUnlock()
+
+	if !ok || !isExpiring(state.notAfter) {
+		return time.Time{}, false
+	}
+
+	return state.notAfter, true
+}
+
+// isExpiring function returns true if a certificate expiring at notAfter
+// must be renewed soon.
+func isExpiring(notAfter time.Time) bool {
+	return !notAfter.IsZero() && time.Until(notAfter) < config.Config.CertExpiryWarning
+}
@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	defaultBreakerMinRequests = 10
+	defaultBreakerOpenTimeout = 30 * time.Second
+)
+
+// Circuit breaker states of a target, the values of the state metric.
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open for all targets")
+
+var (
+	breakerStateGauge = metrics.NewGauge(
+		"tsdproxy_circuit_breaker_state",
+		"Circuit breaker of the targets: 0 closed, 1 half-open, 2 open.",
+		"proxy", "port", "target",
+	)
+	breakerOpens = metrics.NewCounter(
+		"tsdproxy_circuit_breaker_opens_total",
+		"Times the circuit breaker of the targets opened.",
+		"proxy", "port", "target",
+	)
+)
+
+type (
+	// circuitBreaker struct stops sending requests to the targets of a port
+	// with an error rate over the threshold. An open target gets no requests
+	// until the open timeout, then a single request decides if it's closed
+	// again or open for another timeout.
+	circuitBreaker struct {
+		proxy  string
+		port   string
+		config model.CircuitBreaker
+	}
+
+	// breakerState is the state of the circuit breaker of a target.
+	breakerState int
+
+	// targetBreaker struct stores the circuit breaker of a target.
+	targetBreaker struct {
+		openedAt  time.Time
+		probedAt  time.Time
+		state     breakerState
+		errorRate float64
+		requests  int
+		// probing is set while the request of a half-open target is sent
+		probing bool
+	}
+)
+
+// newCircuitBreaker function returns the circuit breaker of a port, with the
+// defaults of the unset options. It returns nil when it's disabled.
+func newCircuitBreaker(proxyName, portName string, cfg model.CircuitBreaker) *circuitBreaker {
+	if cfg.ErrorRate <= 0 {
+		return nil
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultBreakerMinRequests
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultBreakerOpenTimeout
+	}
+
+	return &circuitBreaker{
+		proxy:  proxyName,
+		port:   portName,
+		config: cfg,
+	}
+}
+
+// allows method returns true if a request can be sent to the target: its
+// breaker is closed, or half-open without a request being sent. A half-open
+// request without a result after the open timeout is lost, and another is
+// allowed.
+func (c *circuitBreaker) allows(t *target, now time.Time) bool {
+	if c == nil {
+		return true
+	}
+
+	switch t.breaker.state {
+	case breakerOpen, breakerHalfOpen:
+		if t.breaker.probing {
+			return now.Sub(t.breaker.probedAt) >= c.config.OpenTimeout
+		}
+		return now.Sub(t.breaker.openedAt) >= c.config.OpenTimeout
+	default:
+		return true
+	}
+}
+
+// acquire method registers a request sent to the target, an open target
+// past its timeout is half-open with this request.
+func (c *circuitBreaker) acquire(t *target, now time.Time) {
+	if c == nil || t.breaker.state == breakerClosed {
+		return
+	}
+
+	c.setState(t, breakerHalfOpen)
+	t.breaker.probing = true
+	t.breaker.probedAt = now
+}
+
+// record method updates the breaker of the target with the result of a
+// request.
+func (c *circuitBreaker) record(t *target, failed bool, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	// requests sent before the breaker opened don't count
+	if t.breaker.state == breakerOpen {
+		return
+	}
+
+	if t.breaker.state == breakerHalfOpen {
+		t.breaker.probing = false
+		if failed {
+			c.open(t, now)
+			return
+		}
+		t.breaker = targetBreaker{}
+		c.setState(t, breakerClosed)
+		return
+	}
+
+	errorValue := 0.0
+	if failed {
+		errorValue = 1
+	}
+	if t.breaker.requests == 0 {
+		t.breaker.errorRate = errorValue
+	} else {
+		t.breaker.errorRate += ewmaWeight * (errorValue - t.breaker.errorRate)
+	}
+	t.breaker.requests++
+
+	if t.breaker.requests >= c.config.MinRequests && t.breaker.errorRate >= c.config.ErrorRate {
+		c.open(t, now)
+	}
+}
+
+// release method frees the half-open request of a target that wasn't
+// counted, like requests canceled by the client.
+func (c *circuitBreaker) release(t *target) {
+	if c == nil {
+		return
+	}
+
+	t.breaker.probing = false
+}
+
+// open method opens the breaker of the target for the open timeout.
+func (c *circuitBreaker) open(t *target, now time.Time) {
+	t.breaker.openedAt = now
+	c.setState(t, breakerOpen)
+	breakerOpens.Inc(c.proxy, c.port, t.url.Redacted())
+}
+
+// setState method changes the state of the breaker of the target and its
+// metric.
+func (c *circuitBreaker) setState(t *target, state breakerState) {
+	t.breaker.state = state
+	breakerStateGauge.Set(int64(state), c.proxy, c.port, t.url.Redacted())
+}
+
+// deleteMetrics method removes the state metric of the targets, for ports
+// that are closed or replaced.
+func (c *circuitBreaker) deleteMetrics(targets []*target) {
+	if c == nil {
+		return
+	}
+
+	for _, t := range targets {
+		breakerStateGauge.Delete(c.proxy, c.port, t.url.Redacted())
+	}
+}
+
+// String method returns the name of the state, shown in the dashboard.
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half-open"
+	case breakerOpen:
+		return "open"
+	case breakerClosed:
+		return "closed"
+	default:
+		return strconv.Itoa(int(s))
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+)
+
+// Policies of the proxies with the hostname of a proxy of another target.
+const (
+	// ConflictReject lists the proxy with a suffixed hostname and the
+	// Conflict status, without starting it.
+	ConflictReject = "reject"
+	// ConflictSuffix starts the proxy with a suffixed hostname.
+	ConflictSuffix = "suffix"
+)
+
+const fixHostnameConflict = "Change the hostname of one of the proxies, or stop the other proxy and start this one again."
+
+// resolveHostname method returns the hostname of a new proxy: its own, or,
+// if a proxy of another target uses it, the hostname with the lowest free
+// suffix, like myapp-2, and the proxy using it. Must be called with
+// hostnameMtx locked until the proxy is added.
+func (pm *ProxyManager) resolveHostname(pcfg *model.Config) (string, *Proxy) {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	owner, ok := pm.Proxies[pcfg.Hostname]
+	if !ok || sameTarget(owner.Config(), pcfg) {
+		return pcfg.Hostname, nil
+	}
+
+	for n := 2; ; n++ {
+		hostname := pcfg.Hostname + "-" + strconv.Itoa(n)
+		if p, ok := pm.Proxies[hostname]; !ok || sameTarget(p.Config(), pcfg) {
+			return hostname, owner
+		}
+	}
+}
+
+// hostnameConflict method logs and notifies a proxy whose hostname is used
+// by owner, started or rejected as hostname.
+func (pm *ProxyManager) hostnameConflict(pcfg *model.Config, owner *Proxy, hostname string) {
+	reject := config.Config.HostnameConflicts != ConflictSuffix
+
+	pm.log.Warn().
+		Str("proxy", pcfg.Hostname).
+		Str("targetID", pcfg.TargetID).
+		Str("owner", targetName(owner.Config())).
+		Str("hostname", hostname).
+		Bool("rejected", reject).
+		Msg("Hostname used by another proxy")
+
+	msg := "The hostname is used by " + targetName(owner.Config()) + ", "
+	if reject {
+		msg += "the proxy of " + targetName(pcfg) + " isn't started and is listed as " + hostname + "."
+	} else {
+		msg += "the proxy of " + targetName(pcfg) + " is started as " + hostname + "."
+	}
+
+	pm.notify(notify.Event{
+		Type:    notify.EventHostnameConflict,
+		Proxy:   pcfg.Hostname,
+		Message: msg,
+	})
+}
+
+// conflictMessage method returns the problem of a rejected proxy.
+func (pm *ProxyManager) conflictMessage(p *Proxy) string {
+	owner, ok := pm.GetProxy(p.conflict)
+	if !ok {
+		return "hostname " + p.conflict + " was used by another proxy"
+	}
+
+	return "hostname " + p.conflict + " is used by " + targetName(owner.Config())
+}
+
+// retryConflicts method starts the first proxy rejected because of the
+// hostname of a removed proxy, by the order of their suffixes.
+func (pm *ProxyManager) retryConflicts(hostname string) {
+	var names []string
+	for name, p := range pm.GetProxies() {
+		if p.conflict == hostname {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	// myapp-2 before myapp-10
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(a), len(b)), cmp.Compare(a, b))
+	})
+
+	pm.log.Info().Str("proxy", names[0]).Str("hostname", hostname).Msg("Hostname released, starting rejected proxy")
+
+	if err := pm.RestartProxy(names[0]); err != nil {
+		pm.log.Error().Err(err).Str("proxy", names[0]).Msg("Error starting rejected proxy")
+	}
+}
+
+// sameTarget function returns true if the configurations are of the same
+// target, the proxy of a target is replaced when it's started again.
+func sameTarget(a, b *model.Config) bool {
+	return a.TargetProvider == b.TargetProvider && a.TargetID == b.TargetID
+}
+
+// targetName function returns the target of a proxy in the messages, like
+// local/myapp.
+func targetName(pcfg *model.Config) string {
+	return pcfg.TargetProvider + "/" + pcfg.TargetID
+}
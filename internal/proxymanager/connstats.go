@@ -0,0 +1,250 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// StatsInterval is the interval between the samples of the connection
+// statistics, the rates are averaged over it.
+const StatsInterval = 2 * time.Second
+
+type (
+	// portStats struct counts the connections, requests and bytes of a
+	// port. It's kept when the port handler is swapped on reload.
+	portStats struct {
+		last        statsSample
+		lastTime    time.Time
+		active      atomic.Int64
+		connections atomic.Uint64
+		requests    atomic.Uint64
+		bytesIn     atomic.Uint64
+		bytesOut    atomic.Uint64
+		rates       [3]float64
+		mtx         sync.Mutex
+	}
+
+	// statsSample struct is the counters of a port at a point in time.
+	statsSample struct {
+		requests uint64
+		bytesIn  uint64
+		bytesOut uint64
+	}
+
+	// statsListener struct is a net.Listener that counts the connections of
+	// a port and the bytes of its connections.
+	statsListener struct {
+		net.Listener
+		stats *portStats
+	}
+
+	// statsConn struct counts the bytes read and written in a connection.
+	statsConn struct {
+		net.Conn
+		stats     *portStats
+		closeOnce sync.Once
+	}
+
+	// statsBody struct counts the bytes read from a request body.
+	statsBody struct {
+		io.ReadCloser
+		stats *portStats
+	}
+
+	// statsResponseWriter struct counts the bytes written to a response.
+	statsResponseWriter struct {
+		http.ResponseWriter
+		stats *portStats
+	}
+)
+
+// newStatsListener function returns a listener that counts the connections
+// and bytes in stats.
+//
+// TLS connections aren't wrapped, the http server requires a *tls.Conn to
+// serve https and http/2. Their close is counted by connState and their
+// bytes by middleware, without the headers.
+func newStatsListener(l net.Listener, stats *portStats) net.Listener {
+	return &statsListener{Listener: l, stats: stats}
+}
+
+// Accept method implements net.Listener Accept method.
+func (l *statsListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.stats.connections.Add(1)
+	l.stats.active.Add(1)
+
+	if _, ok := conn.(*tls.Conn); ok {
+		return conn, nil
+	}
+
+	return &statsConn{Conn: conn, stats: l.stats}, nil
+}
+
+// Read method implements net.Conn Read method.
+func (c *statsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.stats.bytesIn.Add(uint64(n)) //nolint:gosec
+
+	return n, err
+}
+
+// Write method implements net.Conn Write method.
+func (c *statsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.stats.bytesOut.Add(uint64(n)) //nolint:gosec
+
+	return n, err
+}
+
+// Close method implements net.Conn Close method.
+func (c *statsConn) Close() error {
+	c.closeOnce.Do(func() { c.stats.active.Add(-1) })
+
+	return c.Conn.Close()
+}
+
+// connState method is the http.Server ConnState hook, it counts the closed
+// TLS connections, the other connections are counted by statsConn.
+func (s *portStats) connState(conn net.Conn, state http.ConnState) {
+	if _, ok := conn.(*tls.Conn); !ok {
+		return
+	}
+
+	if state == http.StateClosed || state == http.StateHijacked {
+		s.active.Add(-1)
+	}
+}
+
+// middleware method counts the requests, and the bytes of the requests in
+// TLS connections.
+func (s *portStats) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+
+		if r.TLS != nil {
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &statsBody{ReadCloser: r.Body, stats: s}
+			}
+			w = &statsResponseWriter{ResponseWriter: w, stats: s}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Read method implements io.Reader Read method.
+func (b *statsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.stats.bytesIn.Add(uint64(n)) //nolint:gosec
+
+	return n, err
+}
+
+// Write method implements http.ResponseWriter Write method.
+func (w *statsResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.stats.bytesOut.Add(uint64(n)) //nolint:gosec
+
+	return n, err
+}
+
+// Unwrap method returns the original http.ResponseWriter, used by
+// http.ResponseController to flush and hijack.
+func (w *statsResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// sample method updates the rates with the counters since the last sample.
+func (s *portStats) sample(now time.Time) {
+	cur := statsSample{
+		requests: s.requests.Load(),
+		bytesIn:  s.bytesIn.Load(),
+		bytesOut: s.bytesOut.Load(),
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.lastTime.IsZero() {
+		if elapsed := now.Sub(s.lastTime).Seconds(); elapsed > 0 {
+			s.rates = [3]float64{
+				float64(cur.requests-s.last.requests) / elapsed,
+				float64(cur.bytesIn-s.last.bytesIn) / elapsed,
+				float64(cur.bytesOut-s.last.bytesOut) / elapsed,
+			}
+		}
+	}
+	s.last = cur
+	s.lastTime = now
+}
+
+// get method returns the statistics of the port.
+func (s *portStats) get() model.PortStats {
+	s.mtx.Lock()
+	rates := s.rates
+	s.mtx.Unlock()
+
+	return model.PortStats{
+		Active:       max(s.active.Load(), 0),
+		Connections:  s.connections.Load(),
+		Requests:     s.requests.Load(),
+		BytesIn:      s.bytesIn.Load(),
+		BytesOut:     s.bytesOut.Load(),
+		RequestRate:  rates[0],
+		BytesInRate:  rates[1],
+		BytesOutRate: rates[2],
+	}
+}
+
+// GetConnStats method returns the connection statistics of the ports, by
+// port name.
+func (proxy *Proxy) GetConnStats() map[string]model.PortStats {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	stats := make(map[string]model.PortStats, len(proxy.ports))
+	for name, p := range proxy.ports {
+		stats[name] = p.stats.get()
+	}
+
+	return stats
+}
+
+// sampleStats method updates the rates of the connection statistics of the
+// ports.
+func (proxy *Proxy) sampleStats(now time.Time) {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	for _, p := range proxy.ports {
+		p.stats.sample(now)
+	}
+}
+
+// watchStats method samples the connection statistics of the proxies
+// periodically.
+func (pm *ProxyManager) watchStats() {
+	ticker := time.NewTicker(StatsInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, p := range pm.GetProxies() {
+			p.sampleStats(now)
+		}
+	}
+}
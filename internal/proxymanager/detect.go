@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"bytes"
+	"net"
+	"sync"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+)
+
+// Protocols detected in tcp ports.
+const (
+	ProtocolTLS  = "tls"
+	ProtocolSSH  = "ssh"
+	ProtocolRDP  = "rdp"
+	ProtocolHTTP = "http"
+)
+
+var detectedConnections = metrics.NewCounter(
+	"tsdproxy_tcp_connections_total",
+	"Connections to tcp ports by the protocol detected in the first bytes sent by the client.",
+	"proxy", "port", "protocol",
+)
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("HEAD "), []byte("DELETE "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "), []byte("PRI * HTTP/2"),
+}
+
+type (
+	// detectListener struct is a net.Listener that detects the protocol of
+	// its connections. Detection is passive, it only inspects the bytes
+	// read by the server, so connections are never delayed.
+	detectListener struct {
+		net.Listener
+		onDetect func(protocol string)
+	}
+
+	// detectConn struct detects the protocol in the first read of a connection.
+	detectConn struct {
+		net.Conn
+		onDetect func(protocol string)
+		once     sync.Once
+	}
+)
+
+// newDetectListener function returns a listener that calls onDetect with the
+// protocol of each connection, "" if unknown.
+func newDetectListener(l net.Listener, onDetect func(protocol string)) net.Listener {
+	return &detectListener{Listener: l, onDetect: onDetect}
+}
+
+// Accept method implements net.Listener Accept method.
+func (l *detectListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &detectConn{Conn: conn, onDetect: l.onDetect}, nil
+}
+
+// Read method implements net.Conn Read method.
+func (c *detectConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.once.Do(func() {
+			c.onDetect(detectProtocol(b[:n]))
+		})
+	}
+
+	return n, err
+}
+
+// detectProtocol function returns the protocol of the first bytes sent by a
+// client, "" if unknown.
+func detectProtocol(b []byte) string {
+	switch {
+	// TLS handshake record with a SSL 3.0+ version
+	case len(b) >= 3 && b[0] == 0x16 && b[1] == 0x03:
+		return ProtocolTLS
+	// SSH identification string
+	case bytes.HasPrefix(b, []byte("SSH-")):
+		return ProtocolSSH
+	// TPKT header of a X.224 connection request
+	case len(b) >= 6 && b[0] == 0x03 && b[1] == 0x00 && b[5]&0xf0 == 0xe0:
+		return ProtocolRDP
+	}
+
+	for _, m := range httpMethods {
+		if bytes.HasPrefix(b, m) {
+			return ProtocolHTTP
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// disabledFile is the file in the data directory that stores the disabled proxies.
+const disabledFile = "disabled.yaml"
+
+var ErrProxyDisabled = errors.New("proxy is disabled")
+
+// disabledState struct stores the proxies disabled in the dashboard. Disabled
+// proxies are kept stopped even when their target provider starts them again.
+type disabledState struct {
+	Proxies []string `yaml:"proxies"`
+}
+
+// loadDisabled method loads the disabled proxies from the data directory.
+func (pm *ProxyManager) loadDisabled() {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	pm.disabled = &disabledState{}
+	pm.disabledFile = config.NewConfigFile(pm.log, filepath.Join(config.Config.Tailscale.DataDir, disabledFile), pm.disabled)
+
+	if err := pm.disabledFile.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		pm.log.Error().Err(err).Msg("Error loading disabled proxies")
+	}
+}
+
+// IsDisabled method returns true if the proxy was disabled in the dashboard.
+func (pm *ProxyManager) IsDisabled(name string) bool {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	return pm.disabled != nil && slices.Contains(pm.disabled.Proxies, name)
+}
+
+// DisableProxy method stops a proxy and keeps it stopped, across restarts
+// and target provider events, until EnableProxy is called.
+func (pm *ProxyManager) DisableProxy(name string) error {
+	proxy, ok := pm.GetProxy(name)
+	if !ok {
+		return ErrProxyNotFound
+	}
+
+	pm.setDisabled(name, true)
+
+	pm.log.Info().Str("proxy", name).Msg("Disabling proxy")
+
+	proxy.Close()
+
+	// the proxy may be already stopped, notify the subscribers anyway
+	pm.broadcastStatusEvents(model.ProxyEvent{
+		ID:     name,
+		Status: proxy.GetStatus(),
+	})
+
+	return nil
+}
+
+// EnableProxy method starts again a proxy disabled with DisableProxy.
+func (pm *ProxyManager) EnableProxy(name string) error {
+	if _, ok := pm.GetProxy(name); !ok {
+		return ErrProxyNotFound
+	}
+
+	pm.setDisabled(name, false)
+
+	pm.log.Info().Str("proxy", name).Msg("Enabling proxy")
+
+	return pm.RestartProxy(name)
+}
+
+// setDisabled method adds or removes a proxy from the disabled proxies and saves them.
+// If they can't be saved, like in a read-only data directory, they are kept
+// in memory until tsdproxy restarts.
+func (pm *ProxyManager) setDisabled(name string, disabled bool) {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	if pm.disabled == nil || slices.Contains(pm.disabled.Proxies, name) == disabled {
+		return
+	}
+
+	if disabled {
+		pm.disabled.Proxies = append(pm.disabled.Proxies, name)
+		slices.Sort(pm.disabled.Proxies)
+	} else {
+		pm.disabled.Proxies = slices.DeleteFunc(pm.disabled.Proxies, func(s string) bool { return s == name })
+	}
+
+	if err := pm.disabledFile.Save(); err != nil {
+		pm.log.Error().Err(err).Msg("Error saving disabled proxies")
+	}
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	processRestartDelay    = time.Second
+	processMaxRestartDelay = time.Minute
+	processStopTimeout     = 10 * time.Second
+)
+
+// process struct supervises a command configured in the proxy Exec section.
+type process struct {
+	log    zerolog.Logger
+	cancel context.CancelFunc
+	// done is closed when the current run stops, a new one for each start
+	done chan struct{}
+	cfg  model.Exec
+	mtx  sync.Mutex
+}
+
+// processLogWriter writes process output to the proxy log.
+type processLogWriter struct {
+	log zerolog.Logger
+}
+
+func (w processLogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.log.Info().Msg(line)
+	}
+	return len(p), nil
+}
+
+func newProcess(log zerolog.Logger, cfg model.Exec) *process {
+	return &process{
+		log: log.With().Str("exec", cfg.Command).Logger(),
+		cfg: cfg,
+	}
+}
+
+// start method starts the command and restarts it when it exits until stop is called.
+func (p *process) start(ctx context.Context) {
+	p.mtx.Lock()
+	ctx, p.cancel = context.WithCancel(ctx)
+	done := make(chan struct{})
+	p.done = done
+	p.mtx.Unlock()
+
+	go func() {
+		defer close(done)
+
+		delay := processRestartDelay
+		for {
+			started := time.Now()
+
+			err := p.run(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			// reset backoff if the process was running for a while
+			if time.Since(started) > processMaxRestartDelay {
+				delay = processRestartDelay
+			}
+
+			p.log.Error().Err(err).Dur("restartIn", delay).Msg("process exited")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			delay = min(delay*2, processMaxRestartDelay) //nolint:mnd
+		}
+	}()
+}
+
+// run method runs the command until it exits or the context is canceled.
+func (p *process) run(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, p.cfg.Command, p.cfg.Args...) //nolint:gosec
+	cmd.Dir = p.cfg.Dir
+	cmd.Env = os.Environ()
+	for k, v := range p.cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdout = processLogWriter{log: p.log}
+	cmd.Stderr = processLogWriter{log: p.log}
+
+	// ask the process to terminate before killing it
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = processStopTimeout
+
+	p.log.Info().Strs("args", p.cfg.Args).Msg("starting process")
+
+	return cmd.Run()
+}
+
+// stop method stops the command and waits for it to exit.
+func (p *process) stop() {
+	p.mtx.Lock()
+	cancel, done := p.cancel, p.done
+	p.mtx.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+
+	p.log.Info().Msg("process stopped")
+}
@@ -0,0 +1,341 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultCheckInterval      = 10 * time.Second
+	defaultCheckTimeout       = 5 * time.Second
+	defaultHealthyThreshold   = 2
+	defaultUnhealthyThreshold = 3
+	// maxExpectRead is the maximum size read from the target waiting for the
+	// expected payload.
+	maxExpectRead = 4 << 10
+)
+
+var ErrUnexpectedResponse = errors.New("response doesn't contain the expected payload")
+
+var healthChecks = metrics.NewCounter(
+	"tsdproxy_health_checks_total",
+	"Active health checks of the targets of tcp ports by result.",
+	"proxy", "port", "target", "result",
+)
+
+// payloadEscapes are the escapes of the send and expect payloads, labels
+// can't have control characters.
+var payloadEscapes = strings.NewReplacer(`\r`, "\r", `\n`, "\n", `\t`, "\t", `\\`, `\`)
+
+type (
+	// healthChecker struct checks the targets of a tcp port periodically,
+	// marking them down in the balancer of the port after failed checks.
+	healthChecker struct {
+		log      zerolog.Logger
+		balancer *balancer
+		onChange func()
+		cancel   context.CancelFunc
+		proxy    string
+		port     string
+		targets  []*checkTarget
+		check    model.HealthCheck
+		// tlsConfig is the TLS of the connections to the targets
+		tlsConfig *tls.Config
+		mtx       sync.Mutex
+	}
+
+	// checkTarget struct stores the results of the checks of a target.
+	checkTarget struct {
+		url       *url.URL
+		lastError string
+		latency   time.Duration
+		checks    uint64
+		// successes and failures are the checks in a row with that result
+		successes int
+		failures  int
+		down      bool
+	}
+)
+
+// newHealthChecker function returns the health checker of a port, with the
+// defaults of the unset options. onChange is called when a target goes down
+// or up.
+func newHealthChecker(proxyName, portName string, pconfig model.PortConfig, tlsConfig *tls.Config,
+	log zerolog.Logger, b *balancer, onChange func(),
+) *healthChecker {
+	check := pconfig.HealthCheck
+	if check.Interval <= 0 {
+		check.Interval = defaultCheckInterval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = min(defaultCheckTimeout, check.Interval)
+	}
+	if check.HealthyThreshold <= 0 {
+		check.HealthyThreshold = defaultHealthyThreshold
+	}
+	if check.UnhealthyThreshold <= 0 {
+		check.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	check.Send = payloadEscapes.Replace(check.Send)
+	check.Expect = payloadEscapes.Replace(check.Expect)
+
+	c := &healthChecker{
+		log:       log.With().Str("check", check.Type).Logger(),
+		balancer:  b,
+		onChange:  onChange,
+		proxy:     proxyName,
+		port:      portName,
+		check:     check,
+		tlsConfig: tlsConfig,
+	}
+	for _, u := range pconfig.GetTargets() {
+		c.targets = append(c.targets, &checkTarget{url: u})
+	}
+
+	return c
+}
+
+// start method checks the targets until the context is done or stop is
+// called.
+func (c *healthChecker) start(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	c.mtx.Lock()
+	c.cancel = cancel
+	c.mtx.Unlock()
+
+	go c.run(ctx)
+}
+
+// stop method stops checking the targets.
+func (c *healthChecker) stop() {
+	if c == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+func (c *healthChecker) run(ctx context.Context) {
+	ticker := time.NewTicker(c.check.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAll method checks all the targets concurrently.
+func (c *healthChecker) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, t := range c.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.probe(ctx, t.url)
+			// checks interrupted by the port closing don't count
+			if ctx.Err() != nil {
+				return
+			}
+			c.observe(i, time.Since(start), err)
+		}()
+	}
+	wg.Wait()
+}
+
+// probe method runs the check on a target.
+func (c *healthChecker) probe(ctx context.Context, u *url.URL) error {
+	ctx, cancel := context.WithTimeout(ctx, c.check.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, targetNetwork(u), targetAddress(u))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if c.check.Type == model.HealthCheckTLS || c.check.Type == model.HealthCheckExpect && c.check.TLS {
+		tlsConfig := c.tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		conn = tlsConn
+	}
+
+	if c.check.Type != model.HealthCheckExpect {
+		return nil
+	}
+
+	return expectPayload(conn, c.check.Send, c.check.Expect)
+}
+
+// observe method stores the result of a check, and marks the target down or
+// up when a threshold is reached.
+func (c *healthChecker) observe(i int, latency time.Duration, err error) {
+	c.mtx.Lock()
+	t := c.targets[i]
+	t.checks++
+	t.latency = latency
+
+	changed := false
+	if err == nil {
+		t.lastError = ""
+		t.failures = 0
+		t.successes++
+		if t.down && t.successes >= c.check.HealthyThreshold {
+			t.down = false
+			changed = true
+		}
+	} else {
+		t.lastError = err.Error()
+		t.successes = 0
+		t.failures++
+		if !t.down && t.failures >= c.check.UnhealthyThreshold {
+			t.down = true
+			changed = true
+		}
+	}
+	down := t.down
+	c.mtx.Unlock()
+
+	target := t.url.Redacted()
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	healthChecks.Inc(c.proxy, c.port, target, result)
+
+	if !changed {
+		return
+	}
+
+	if down {
+		c.log.Warn().Err(err).Str("target", target).Msg("Target is down")
+	} else {
+		c.log.Info().Str("target", target).Msg("Target is up")
+	}
+
+	c.balancer.setDown(i, down)
+	if c.onChange != nil {
+		c.onChange()
+	}
+}
+
+// health method returns the health of the targets, adding the results of
+// the checks to the health measured by the balancer.
+func (c *healthChecker) health(measured []model.TargetHealth) []model.TargetHealth {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	health := make([]model.TargetHealth, len(c.targets))
+	for i, t := range c.targets {
+		h := model.TargetHealth{
+			Target:  t.url.Redacted(),
+			Latency: t.latency,
+			Score:   1,
+		}
+		if i < len(measured) {
+			h = measured[i]
+		} else if t.down {
+			h.ErrorRate = 1
+			h.Score = 0
+		}
+
+		h.Check = c.check.Type
+		h.LastError = t.lastError
+		h.Checks = t.checks
+		h.Down = t.down
+		health[i] = h
+	}
+
+	return health
+}
+
+// expectPayload function sends the payload and reads the response until it
+// contains the expected payload.
+func expectPayload(conn net.Conn, send, expect string) error {
+	if send != "" {
+		if _, err := io.WriteString(conn, send); err != nil {
+			return err
+		}
+	}
+
+	if expect == "" {
+		return nil
+	}
+
+	buf := make([]byte, 0, maxExpectRead)
+	for len(buf) < cap(buf) {
+		n, err := conn.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if bytes.Contains(buf, []byte(expect)) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)
+		}
+	}
+
+	return ErrUnexpectedResponse
+}
+
+// targetAddress function returns the address of a target, with the default
+// port of its scheme when it has no port, or the path of its unix socket.
+func targetAddress(u *url.URL) string {
+	if u.Scheme == model.TargetSchemeUnix {
+		return u.Path
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
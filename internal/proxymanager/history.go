@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/history"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// historyFile is the database in the data directory with the status history.
+const historyFile = "history.db"
+
+var ErrHistoryDisabled = errors.New("status history is disabled")
+
+// openHistory method opens the status history. The history is disabled if
+// it can't be opened, like in a read-only data directory.
+func (pm *ProxyManager) openHistory() {
+	if !config.Config.History.Enabled {
+		return
+	}
+
+	path := filepath.Join(config.Config.Tailscale.DataDir, historyFile)
+
+	store, err := history.Open(pm.log, path, config.Config.History.Retention)
+	if err != nil {
+		pm.log.Error().Err(err).Str("file", path).Msg("Error opening status history, history is disabled")
+		return
+	}
+
+	pm.mtx.Lock()
+	pm.history = store
+	pm.mtx.Unlock()
+}
+
+// closeHistory method closes the status history.
+func (pm *ProxyManager) closeHistory() {
+	pm.mtx.Lock()
+	store := pm.history
+	pm.history = nil
+	pm.mtx.Unlock()
+
+	if store == nil {
+		return
+	}
+
+	if err := store.Close(); err != nil {
+		pm.log.Error().Err(err).Msg("Error closing status history")
+	}
+}
+
+// recordStatus method adds a proxy status to the history.
+func (pm *ProxyManager) recordStatus(name string, status model.ProxyStatus) {
+	pm.mtx.RLock()
+	store := pm.history
+	pm.mtx.RUnlock()
+
+	if store == nil {
+		return
+	}
+
+	if err := store.Record(name, status, time.Now()); err != nil {
+		pm.log.Error().Err(err).Str("proxy", name).Msg("Error recording proxy status")
+	}
+}
+
+// GetHistory method returns the status transitions of a proxy since the time,
+// including the transition before, which is the status at since.
+func (pm *ProxyManager) GetHistory(name string, since time.Time) ([]model.StatusChange, error) {
+	pm.mtx.RLock()
+	store := pm.history
+	pm.mtx.RUnlock()
+
+	if store == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	return store.History(name, since)
+}
+
+// GetUptime method returns the rate of time, from 0 to 1, a proxy was running
+// since the time. ok is false if the history is disabled or empty.
+func (pm *ProxyManager) GetUptime(name string, since time.Time) (float64, bool) {
+	h, err := pm.GetHistory(name, since)
+	if err != nil {
+		return 0, false
+	}
+
+	return history.Uptime(h, since, time.Now())
+}
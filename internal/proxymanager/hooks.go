@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+var ErrHookStatus = errors.New("hook returned an error status")
+
+// hookEvent struct is the metadata of the proxy sent to the hooks.
+type hookEvent struct {
+	Time           time.Time `json:"time"`
+	Event          string    `json:"event"`
+	Proxy          string    `json:"proxy"`
+	Status         string    `json:"status"`
+	URL            string    `json:"url,omitempty"`
+	TargetProvider string    `json:"targetProvider"`
+	ProxyProvider  string    `json:"proxyProvider"`
+	Ports          []string  `json:"ports"`
+}
+
+// runHooks method runs the hooks of the proxy for a status in the background,
+// one after the other. Their errors are logged.
+func (pm *ProxyManager) runHooks(p *Proxy, status model.ProxyStatus) {
+	name, hooks := p.Config().Hooks.ForStatus(status)
+	if len(hooks) == 0 {
+		return
+	}
+
+	event := hookEvent{
+		Time:           time.Now(),
+		Event:          name,
+		Proxy:          p.Config().Hostname,
+		Status:         status.String(),
+		URL:            p.GetURL(),
+		TargetProvider: p.Config().TargetProvider,
+		ProxyProvider:  p.Config().ProxyProvider,
+		Ports:          slices.Sorted(maps.Keys(p.Config().Ports)),
+	}
+
+	log := pm.log.With().Str("proxy", event.Proxy).Str("hook", name).Logger()
+
+	go func() {
+		for _, hook := range hooks {
+			if err := runHook(log, hook, event); err != nil {
+				log.Error().Err(err).Msg("error running hook")
+			}
+		}
+	}()
+}
+
+// runHook function runs a hook with the event, up to its timeout.
+func runHook(log zerolog.Logger, hook model.Hook, event hookEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if hook.Command != "" {
+		return runHookCommand(ctx, log, hook, event, data)
+	}
+
+	return postHook(ctx, hook, data)
+}
+
+// runHookCommand function runs the command of a hook with the event in its
+// standard input and environment.
+func runHookCommand(ctx context.Context, log zerolog.Logger, hook model.Hook, event hookEvent, data []byte) error {
+	cmd := exec.CommandContext(ctx, hook.Command, hook.Args...) //nolint:gosec
+	cmd.Env = append(os.Environ(),
+		"TSDPROXY_EVENT="+event.Event,
+		"TSDPROXY_PROXY="+event.Proxy,
+		"TSDPROXY_STATUS="+event.Status,
+		"TSDPROXY_URL="+event.URL,
+	)
+	for k, v := range hook.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = processLogWriter{log: log}
+	cmd.Stderr = processLogWriter{log: log}
+
+	return cmd.Run()
+}
+
+// postHook function posts the event to the URL of a hook.
+func postHook(ctx context.Context, hook model.Hook, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range hook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s returned %s", ErrHookStatus, req.URL.Host, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/geoip"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+var (
+	deniedClients = metrics.NewCounter(
+		"tsdproxy_clients_denied_total",
+		"Connections and requests rejected by the allowedCIDRs and deniedCIDRs of the port.",
+		"proxy", "port",
+	)
+	geoipBlocked = metrics.NewCounter(
+		"tsdproxy_geoip_blocked_total",
+		"Connections and requests rejected by the allowedCountries and deniedCountries of the port.",
+		"proxy", "port", "country",
+	)
+)
+
+type (
+	// ipFilter struct accepts the clients of a port by their address: denied
+	// addresses are rejected, and with allowed CIDRs only those are accepted.
+	// Countries are filtered the same way, addresses without a country, like
+	// tailnet and private addresses, are only filtered by CIDRs.
+	ipFilter struct {
+		proxy            string
+		port             string
+		allowed          []netip.Prefix
+		denied           []netip.Prefix
+		allowedCountries []string
+		deniedCountries  []string
+	}
+
+	// ipFilterListener struct is a net.Listener that closes the connections
+	// of the clients rejected by the filter.
+	ipFilterListener struct {
+		net.Listener
+		filter *ipFilter
+	}
+)
+
+// newIPFilter function returns the filter of the port, nil without CIDRs and
+// countries.
+func newIPFilter(proxyName, portName string, pconfig model.PortConfig) (*ipFilter, error) {
+	if len(pconfig.AllowedCIDRs) == 0 && len(pconfig.DeniedCIDRs) == 0 &&
+		len(pconfig.AllowedCountries) == 0 && len(pconfig.DeniedCountries) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	// without a database every client would be accepted
+	if (len(pconfig.AllowedCountries) > 0 || len(pconfig.DeniedCountries) > 0) && !geoip.Enabled() {
+		return nil, geoip.ErrNoDatabase
+	}
+
+	allowed, err := model.ParseCIDRs(pconfig.AllowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := model.ParseCIDRs(pconfig.DeniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ipFilter{
+		proxy:            proxyName,
+		port:             portName,
+		allowed:          allowed,
+		denied:           denied,
+		allowedCountries: upperAll(pconfig.AllowedCountries),
+		deniedCountries:  upperAll(pconfig.DeniedCountries),
+	}, nil
+}
+
+// allows method returns true if the client address is accepted, denied
+// CIDRs and countries take precedence over the allowed ones. Rejected
+// clients are counted in the metrics.
+func (f *ipFilter) allows(addr netip.Addr) bool {
+	if f == nil {
+		return true
+	}
+
+	addr = addr.Unmap()
+	contains := func(p netip.Prefix) bool { return p.Contains(addr) }
+
+	if slices.ContainsFunc(f.denied, contains) ||
+		(len(f.allowed) > 0 && !slices.ContainsFunc(f.allowed, contains)) {
+		deniedClients.Inc(f.proxy, f.port)
+		return false
+	}
+
+	if len(f.allowedCountries) == 0 && len(f.deniedCountries) == 0 {
+		return true
+	}
+
+	country, ok := geoip.Country(addr)
+	if !ok {
+		return true
+	}
+	if slices.Contains(f.deniedCountries, country) ||
+		(len(f.allowedCountries) > 0 && !slices.Contains(f.allowedCountries, country)) {
+		geoipBlocked.Inc(f.proxy, f.port, country)
+		return false
+	}
+
+	return true
+}
+
+// listener method returns the listener that filters the connections of l.
+func (f *ipFilter) listener(l net.Listener) net.Listener {
+	if f == nil {
+		return l
+	}
+
+	return &ipFilterListener{Listener: l, filter: f}
+}
+
+// middleware method returns a middleware that rejects the requests of the
+// clients not accepted with 403. Requests forwarded by the node of the proxy
+// are checked with the client address the node sets.
+func (f *ipFilter) middleware(proxy *Proxy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if f == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := r.RemoteAddr
+			if ca, ok := proxy.providerProxy.(proxyproviders.ClientAddresser); ok {
+				if forwarded, ok := ca.ClientAddr(r); ok {
+					// the node appends the client to the header
+					client = strings.TrimSpace(forwarded[strings.LastIndex(forwarded, ",")+1:])
+				}
+			}
+
+			addr, ok := parseClientAddr(client)
+			if !ok {
+				deniedClients.Inc(f.proxy, f.port)
+			}
+			if !ok || !f.allows(addr) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Accept method implements net.Listener Accept method. Connections from
+// loopback addresses are forwarded by the node, like the Tailscale serve
+// config, and are checked by the middleware with the address of the client.
+func (l *ipFilterListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		addr, ok := parseClientAddr(conn.RemoteAddr().String())
+		if !ok {
+			deniedClients.Inc(l.filter.proxy, l.filter.port)
+		}
+		if ok && (addr.IsLoopback() || l.filter.allows(addr)) {
+			return conn, nil
+		}
+
+		conn.Close()
+	}
+}
+
+// upperAll function returns the country codes in upper case, like the
+// codes of the database.
+func upperAll(codes []string) []string {
+	upper := make([]string, 0, len(codes))
+	for _, c := range codes {
+		upper = append(upper, strings.ToUpper(strings.TrimSpace(c)))
+	}
+
+	return upper
+}
+
+// parseClientAddr function returns the IP of a client address, with or
+// without port.
+func parseClientAddr(s string) (netip.Addr, bool) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr(), true
+	}
+
+	addr, err := netip.ParseAddr(s)
+
+	return addr, err == nil
+}
@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	rateLimitedRequests = metrics.NewCounter(
+		"tsdproxy_requests_rate_limited_total",
+		"Requests rejected with 429 because the port rate limit was exceeded.",
+		"proxy", "port",
+	)
+	bodyTooLargeRequests = metrics.NewCounter(
+		"tsdproxy_requests_body_too_large_total",
+		"Requests rejected with 413 because the body exceeded maxRequestBody.",
+		"proxy", "port",
+	)
+)
+
+// limitedBody counts the requests whose body exceeds the maximum size.
+type limitedBody struct {
+	io.ReadCloser
+	onExceeded func()
+	once       sync.Once
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.once.Do(b.onExceeded)
+	}
+
+	return n, err
+}
+
+// limitsMiddleware function returns a middleware that enforces the port
+// rate limit and maximum request body size.
+func limitsMiddleware(proxyName, portName string, pconfig model.PortConfig) func(next http.Handler) http.Handler {
+	var limiter *rate.Limiter
+	if pconfig.RequestsPerSecond > 0 {
+		burst := pconfig.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(pconfig.RequestsPerSecond))
+		}
+		limiter = rate.NewLimiter(rate.Limit(pconfig.RequestsPerSecond), burst)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if limiter == nil && pconfig.MaxRequestBody <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limiter != nil && !limiter.Allow() {
+				rateLimitedRequests.Inc(proxyName, portName)
+				w.Header().Set("Retry-After", strconv.Itoa(1))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			if pconfig.MaxRequestBody > 0 {
+				if r.ContentLength > pconfig.MaxRequestBody {
+					bodyTooLargeRequests.Inc(proxyName, portName)
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+					return
+				}
+
+				r.Body = &limitedBody{
+					ReadCloser: http.MaxBytesReader(w, r.Body, pconfig.MaxRequestBody),
+					onExceeded: func() { bodyTooLargeRequests.Inc(proxyName, portName) },
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// minAcceptBackoff is the first wait after an accept error, doubled on each
+// error up to the port acceptBackoff.
+const minAcceptBackoff = 5 * time.Millisecond
+
+type (
+	// limitListener struct is a net.Listener that limits the concurrent
+	// connections and retries temporary accept errors with a backoff.
+	limitListener struct {
+		net.Listener
+		sem        chan struct{}
+		done       chan struct{}
+		maxBackoff time.Duration
+		closeOnce  sync.Once
+	}
+
+	// limitConn struct releases its slot of the limitListener when closed.
+	limitConn struct {
+		net.Conn
+		release   func()
+		closeOnce sync.Once
+	}
+)
+
+// portLimits function returns the connection limits of a port, the global
+// limits are used for the ones not set in the port.
+func portLimits(pconfig model.PortConfig) (int, int, time.Duration) {
+	maxHeaderBytes := pconfig.MaxHeaderBytes
+	if maxHeaderBytes == 0 {
+		maxHeaderBytes = config.Config.Limits.MaxHeaderBytes
+	}
+
+	maxConnections := pconfig.MaxConnections
+	if maxConnections == 0 {
+		maxConnections = config.Config.Limits.MaxConnections
+	}
+
+	acceptBackoff := pconfig.AcceptBackoff
+	if acceptBackoff == 0 {
+		acceptBackoff = config.Config.Limits.AcceptBackoff
+	}
+
+	return maxHeaderBytes, maxConnections, acceptBackoff
+}
+
+// newLimitListener function returns a listener that accepts up to
+// maxConnections concurrent connections, 0 for unlimited. Other connections
+// wait in the accept queue until a connection is closed.
+func newLimitListener(l net.Listener, maxConnections int, maxBackoff time.Duration) net.Listener {
+	ll := &limitListener{
+		Listener:   l,
+		done:       make(chan struct{}),
+		maxBackoff: maxBackoff,
+	}
+	if maxConnections > 0 {
+		ll.sem = make(chan struct{}, maxConnections)
+	}
+
+	return ll
+}
+
+// Accept method implements net.Listener Accept method.
+func (l *limitListener) Accept() (net.Conn, error) {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-l.done:
+			return nil, net.ErrClosed
+		}
+	}
+
+	var backoff time.Duration
+
+	for {
+		conn, err := l.Listener.Accept()
+		if err == nil {
+			if l.sem == nil {
+				return conn, nil
+			}
+			return &limitConn{Conn: conn, release: l.release}, nil
+		}
+
+		if !isTemporary(err) {
+			l.release()
+			return nil, err
+		}
+
+		if backoff == 0 {
+			backoff = minAcceptBackoff
+		} else {
+			backoff *= 2
+		}
+		backoff = min(backoff, l.maxBackoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-l.done:
+			l.release()
+			return nil, net.ErrClosed
+		}
+	}
+}
+
+// Close method implements net.Listener Close method.
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// release method frees a connection slot.
+func (l *limitListener) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+// Close method implements net.Conn Close method.
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.release)
+
+	return err
+}
+
+// isTemporary function returns true if the accept error is caused by a lack
+// of resources or a timeout, so accepting again later may succeed.
+func isTemporary(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) ||
+		errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.ENOMEM) ||
+		errors.Is(err, syscall.ECONNABORTED)
+}
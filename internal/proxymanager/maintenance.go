@@ -0,0 +1,233 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/errorpage"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maintenanceFile is the file in the data directory that stores the
+	// proxies in maintenance.
+	maintenanceFile = "maintenance.yaml"
+
+	msgMaintenance = "This service is under maintenance, try again later."
+	msgTargetDown  = "This service is not responding, try again later."
+	msgTimeout     = "This service took too long to respond, try again later."
+)
+
+type (
+	// pageStore struct stores the pages shown by the proxies instead of the
+	// responses of their targets: the error pages and the maintenance of
+	// the proxies set in the dashboard or the API.
+	pageStore struct {
+		pages *errorpage.Pages
+		file  *config.ConfigFile
+		state maintenanceState
+		mtx   sync.RWMutex
+	}
+
+	maintenanceState struct {
+		Proxies map[string]Maintenance `yaml:"proxies"`
+	}
+
+	// Maintenance struct is the maintenance of a proxy, its ports serve the
+	// maintenance page until it ends.
+	Maintenance struct {
+		Since   time.Time `yaml:"since" json:"since"`
+		Message string    `yaml:"message,omitempty" json:"message,omitempty"`
+	}
+)
+
+// loadPages method loads the error pages of the configuration and the
+// proxies in maintenance from the data directory.
+func (pm *ProxyManager) loadPages() {
+	s := pm.pages
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	pages, err := errorpage.Load(config.Config.ErrorPages)
+	if err != nil {
+		pm.log.Error().Err(err).Msg("Error loading error pages, using the built-in page")
+	}
+	s.pages = pages
+
+	s.state.Proxies = make(map[string]Maintenance)
+	s.file = config.NewConfigFile(pm.log, filepath.Join(config.Config.Tailscale.DataDir, maintenanceFile), &s.state)
+
+	if err := s.file.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		pm.log.Error().Err(err).Msg("Error loading proxies in maintenance")
+	}
+}
+
+// GetMaintenance method returns the maintenance of a proxy, false if it
+// isn't in maintenance.
+func (pm *ProxyManager) GetMaintenance(name string) (Maintenance, bool) {
+	return pm.pages.maintenance(name)
+}
+
+// StartMaintenance method puts a proxy in maintenance, its ports serve the
+// maintenance page with the message until EndMaintenance is called. The
+// proxy keeps running. Starting it again updates the message.
+func (pm *ProxyManager) StartMaintenance(name, message string) error {
+	proxy, ok := pm.GetProxy(name)
+	if !ok {
+		return ErrProxyNotFound
+	}
+
+	s := pm.pages
+
+	s.mtx.Lock()
+	m, ok := s.state.Proxies[name]
+	if !ok {
+		m.Since = time.Now()
+	}
+	m.Message = strings.TrimSpace(message)
+	if s.state.Proxies == nil {
+		s.state.Proxies = make(map[string]Maintenance)
+	}
+	s.state.Proxies[name] = m
+	s.save(pm.log)
+	s.mtx.Unlock()
+
+	pm.log.Info().Str("proxy", name).Msg("Proxy in maintenance")
+
+	pm.broadcastStatusEvents(model.ProxyEvent{
+		ID:     name,
+		Status: proxy.GetStatus(),
+	})
+
+	return nil
+}
+
+// EndMaintenance method ends the maintenance of a proxy, its ports serve
+// the target again.
+func (pm *ProxyManager) EndMaintenance(name string) error {
+	proxy, ok := pm.GetProxy(name)
+	if !ok {
+		return ErrProxyNotFound
+	}
+
+	s := pm.pages
+
+	s.mtx.Lock()
+	_, ok = s.state.Proxies[name]
+	if ok {
+		delete(s.state.Proxies, name)
+		s.save(pm.log)
+	}
+	s.mtx.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	pm.log.Info().Str("proxy", name).Msg("Proxy maintenance ended")
+
+	pm.broadcastStatusEvents(model.ProxyEvent{
+		ID:     name,
+		Status: proxy.GetStatus(),
+	})
+
+	return nil
+}
+
+// save method saves the proxies in maintenance. If they can't be saved,
+// like in a read-only data directory, they are kept in memory until
+// tsdproxy restarts. Called with the lock held.
+func (s *pageStore) save(log zerolog.Logger) {
+	if s.file == nil {
+		return
+	}
+
+	if err := s.file.Save(); err != nil {
+		log.Error().Err(err).Msg("Error saving proxies in maintenance")
+	}
+}
+
+// maintenance method returns the maintenance of a proxy, false if it isn't
+// in maintenance.
+func (s *pageStore) maintenance(name string) (Maintenance, bool) {
+	if s == nil {
+		return Maintenance{}, false
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	m, ok := s.state.Proxies[name]
+
+	return m, ok
+}
+
+// write method writes the error page of a proxy.
+func (s *pageStore) write(w http.ResponseWriter, r *http.Request, d errorpage.Data) {
+	var pages *errorpage.Pages
+	if s != nil {
+		s.mtx.RLock()
+		pages = s.pages
+		s.mtx.RUnlock()
+	}
+
+	pages.Write(w, r, d)
+}
+
+// errorPage method writes the error page of the proxy when its target
+// fails.
+func (proxy *Proxy) errorPage(w http.ResponseWriter, r *http.Request, status int) {
+	message := msgTargetDown
+	if status == http.StatusGatewayTimeout {
+		message = msgTimeout
+	}
+
+	proxy.pages.write(w, r, errorpage.Data{
+		Proxy:   proxy.Config().Hostname,
+		Status:  status,
+		Message: message,
+	})
+}
+
+// maintenanceMiddleware function returns a middleware that serves the
+// maintenance page while the proxy is in maintenance.
+func maintenanceMiddleware(proxyName string, pages *pageStore) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if pages == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m, ok := pages.maintenance(proxyName)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			message := m.Message
+			if message == "" {
+				message = msgMaintenance
+			}
+
+			pages.write(w, r, errorpage.Data{
+				Proxy:       proxyName,
+				Status:      http.StatusServiceUnavailable,
+				Message:     message,
+				Since:       m.Since,
+				Maintenance: true,
+			})
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metadata"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	metadataAttempts   = 3
+	metadataRetryDelay = 10 * time.Second
+)
+
+// enrichProxy method fetches the title and icon of the target application
+// when the dashboard label or icon of the proxy were not configured.
+func (pm *ProxyManager) enrichProxy(proxy *Proxy) {
+	proxy.metadataOnce.Do(func() {
+		labelUnset, iconUnset := proxy.dashboardUnset()
+		if !labelUnset && !iconUnset {
+			return
+		}
+
+		target, tlsValidate, ok := proxy.metadataTarget()
+		if !ok {
+			return
+		}
+
+		for attempt := 1; ; attempt++ {
+			m, err := pm.metadata.Fetch(proxy.ctx, target, tlsValidate)
+			if err == nil {
+				if !labelUnset {
+					m.Title = ""
+				}
+				if !iconUnset {
+					m.Icon = nil
+				}
+				proxy.setMetadata(m)
+				return
+			}
+
+			proxy.log.Debug().Err(err).Int("attempt", attempt).Msg("error fetching target metadata")
+
+			if attempt == metadataAttempts {
+				return
+			}
+
+			select {
+			case <-proxy.ctx.Done():
+				return
+			case <-time.After(metadataRetryDelay):
+			}
+		}
+	})
+}
+
+// dashboardUnset method returns if the dashboard label and icon are the defaults.
+func (proxy *Proxy) dashboardUnset() (bool, bool) {
+	dash := proxy.Config().Dashboard
+
+	labelUnset := dash.Label == "" || dash.Label == proxy.Config().Hostname
+	iconUnset := dash.Icon == "" || dash.Icon == model.DefaultDashboardIcon
+
+	return labelUnset, iconUnset
+}
+
+// metadataTarget method returns the first http target of the proxy ports.
+func (proxy *Proxy) metadataTarget() (*url.URL, bool, bool) {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	names := make([]string, 0, len(proxy.Config().Ports))
+	for name := range proxy.Config().Ports {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		pconfig := proxy.Config().Ports[name]
+		if pconfig.IsRedirect {
+			continue
+		}
+
+		target := pconfig.GetFirstTarget()
+		if target.Scheme == "http" || target.Scheme == "https" {
+			return target, pconfig.TLSValidate, true
+		}
+	}
+
+	return nil, false, false
+}
+
+// setMetadata method stores the target metadata and notifies the subscribers.
+func (proxy *Proxy) setMetadata(m metadata.Metadata) {
+	proxy.mtx.Lock()
+	proxy.metadata = &m
+	status := proxy.status
+	proxy.mtx.Unlock()
+
+	if proxy.onUpdate != nil {
+		proxy.onUpdate(model.ProxyEvent{
+			ID:     proxy.Config().Hostname,
+			Status: status,
+		})
+	}
+}
+
+// GetMetadata method returns the metadata fetched from the target application,
+// nil if it wasn't fetched.
+func (proxy *Proxy) GetMetadata() *metadata.Metadata {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	return proxy.metadata
+}
@@ -0,0 +1,342 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/accesslog"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/auth"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/compression"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/respcache"
+)
+
+// built-in middlewares
+const (
+	MiddlewareAuth        = "auth"
+	MiddlewareCache       = "cache"
+	MiddlewareCompression = "compression"
+	MiddlewareHeaders     = "headers"
+	MiddlewareRateLimit   = "ratelimit"
+	MiddlewareScript      = "script"
+
+	// responseHeaderPrefix is the prefix of the options of the headers
+	// middleware that set response headers.
+	responseHeaderPrefix = "response."
+)
+
+type (
+	// Middleware is a middleware of the chain of a port.
+	Middleware func(next http.Handler) http.Handler
+
+	// MiddlewareFactory returns the middleware of a port with the options of
+	// its chain.
+	MiddlewareFactory func(port *MiddlewarePort, options map[string]string) (Middleware, error)
+
+	// MiddlewarePort struct is the port a middleware is created for.
+	MiddlewarePort struct {
+		Log    zerolog.Logger
+		proxy  *Proxy
+		cache  *respcache.Cache
+		Proxy  string
+		Name   string
+		Config model.PortConfig
+	}
+)
+
+var (
+	ErrUnknownMiddleware    = errors.New("unknown middleware")
+	ErrMiddlewareRegistered = errors.New("middleware already registered")
+	ErrMiddlewareOption     = errors.New("unknown middleware option")
+	ErrMiddlewareMissing    = errors.New("port configuration needs a middleware missing in its middlewares")
+
+	// defaultMiddlewares is the chain of the ports without middlewares.
+	defaultMiddlewares = []model.PortMiddleware{
+		{Name: MiddlewareRateLimit},
+		{Name: MiddlewareAuth},
+		{Name: MiddlewareCache},
+		{Name: MiddlewareCompression},
+	}
+
+	middlewaresMtx sync.RWMutex
+	middlewares    = map[string]MiddlewareFactory{
+		MiddlewareAuth:        authMiddleware,
+		MiddlewareCache:       cacheMiddleware,
+		MiddlewareCompression: compressionMiddleware,
+		MiddlewareHeaders:     headersMiddleware,
+		MiddlewareRateLimit:   rateLimitMiddleware,
+		MiddlewareScript:      scriptMiddleware,
+	}
+)
+
+// RegisterMiddleware function adds a middleware that ports can use in their
+// chain by name.
+func RegisterMiddleware(name string, factory MiddlewareFactory) error {
+	middlewaresMtx.Lock()
+	defer middlewaresMtx.Unlock()
+
+	if _, ok := middlewares[name]; ok {
+		return fmt.Errorf("%w: %s", ErrMiddlewareRegistered, name)
+	}
+	middlewares[name] = factory
+
+	return nil
+}
+
+// portMiddlewares function returns the chain of a port, the default chain
+// if it has no middlewares.
+func portMiddlewares(pconfig model.PortConfig) []model.PortMiddleware {
+	if len(pconfig.Middlewares) == 0 {
+		return defaultMiddlewares
+	}
+
+	return pconfig.Middlewares
+}
+
+// hasMiddleware function returns true if the chain of a port has a
+// middleware.
+func hasMiddleware(pconfig model.PortConfig, name string) bool {
+	return slices.ContainsFunc(portMiddlewares(pconfig), func(m model.PortMiddleware) bool {
+		return m.Name == name
+	})
+}
+
+// requiredMiddlewares function returns an error if the port configures
+// access control or limits without their middleware in its chain, the port
+// would be served without them.
+func requiredMiddlewares(pconfig model.PortConfig) error {
+	for _, m := range []struct {
+		name       string
+		configured bool
+	}{
+		{MiddlewareAuth, pconfig.OIDC != "" || len(pconfig.AllowGroups) > 0},
+		{MiddlewareRateLimit, pconfig.RequestsPerSecond > 0 || pconfig.MaxRequestBody > 0},
+	} {
+		if m.configured && !hasMiddleware(pconfig, m.name) {
+			return fmt.Errorf("%w: %s", ErrMiddlewareMissing, m.name)
+		}
+	}
+
+	return nil
+}
+
+// newChain method returns the middleware of the chain of a port, the first
+// middleware of the chain receives the requests first.
+func (proxy *Proxy) newChain(mp *MiddlewarePort) (Middleware, error) {
+	var chain []Middleware
+
+	middlewaresMtx.RLock()
+	defer middlewaresMtx.RUnlock()
+
+	for _, m := range portMiddlewares(mp.Config) {
+		factory, ok := middlewares[m.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMiddleware, m.Name)
+		}
+
+		middleware, err := factory(mp, m.Options)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring middleware %s: %w", m.Name, err)
+		}
+		chain = append(chain, middleware)
+	}
+
+	return chainMiddlewares(chain), nil
+}
+
+// chainMiddlewares function returns a middleware that runs the middlewares
+// in order.
+func chainMiddlewares(chain []Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(chain) - 1; i >= 0; i-- {
+			next = chain[i](next)
+		}
+		return next
+	}
+}
+
+// checkOptions function returns an error if an option isn't one of the
+// options of a middleware.
+func checkOptions(options map[string]string, allowed ...string) error {
+	for key := range options {
+		if !slices.Contains(allowed, key) {
+			return fmt.Errorf("%w: %s", ErrMiddlewareOption, key)
+		}
+	}
+
+	return nil
+}
+
+// noMiddleware function is the middleware of the disabled features.
+func noMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+// rateLimitMiddleware function returns the rate limit and maximum request
+// body of a port, the rps and burst options replace the ones of the port.
+func rateLimitMiddleware(mp *MiddlewarePort, options map[string]string) (Middleware, error) {
+	if err := checkOptions(options, "rps", "burst"); err != nil {
+		return nil, err
+	}
+
+	pconfig := mp.Config
+	if v, ok := options["rps"]; ok {
+		rps, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rps: %w", err)
+		}
+		pconfig.RequestsPerSecond = rps
+	}
+	if v, ok := options["burst"]; ok {
+		burst, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst: %w", err)
+		}
+		pconfig.Burst = burst
+	}
+
+	return limitsMiddleware(mp.Proxy, mp.Name, pconfig), nil
+}
+
+// authMiddleware function returns the access control of a port. Ports
+// protected by OIDC replace the provider identity with the OIDC one.
+// Ports with allowGroups only allow members of the tailnet ACL groups.
+func authMiddleware(mp *MiddlewarePort, options map[string]string) (Middleware, error) {
+	if err := checkOptions(options); err != nil {
+		return nil, err
+	}
+
+	var chain []Middleware
+
+	if mp.Config.OIDC != "" {
+		oidc, ok := mp.proxy.oidcProviders[mp.Config.OIDC]
+		if !ok {
+			return nil, &auth.OIDCProviderNotFoundError{ProviderName: mp.Config.OIDC}
+		}
+
+		chain = append(chain, oidc.Middleware(mp.Config.ProxyProtocol))
+	}
+
+	if len(mp.Config.AllowGroups) > 0 {
+		if mp.proxy.aclGroups == nil {
+			return nil, auth.ErrACLGroupsNotConfigured
+		}
+
+		chain = append(chain, mp.proxy.aclGroups.Middleware(mp.Config.AllowGroups))
+	}
+
+	// the access log records the OIDC identity
+	chain = append(chain, accesslog.Identify)
+
+	return chainMiddlewares(chain), nil
+}
+
+// cacheMiddleware function returns the response cache of a port, static
+// ports and ports without cache aren't cached.
+func cacheMiddleware(mp *MiddlewarePort, options map[string]string) (Middleware, error) {
+	if err := checkOptions(options); err != nil {
+		return nil, err
+	}
+
+	if mp.cache == nil {
+		return noMiddleware, nil
+	}
+
+	return mp.cache.Middleware, nil
+}
+
+// compressionMiddleware function returns the compression of the responses
+// of a port, the responses of static ports aren't compressed.
+func compressionMiddleware(mp *MiddlewarePort, options map[string]string) (Middleware, error) {
+	if err := checkOptions(options); err != nil {
+		return nil, err
+	}
+
+	if !mp.Config.Compression.Enabled || mp.Config.IsStatic() {
+		return noMiddleware, nil
+	}
+
+	return compression.Middleware(mp.Config.Compression), nil
+}
+
+// headersMiddleware function returns a middleware that sets the request
+// headers of the options, and the response headers of the options with the
+// "response." prefix. Headers with an empty value are removed.
+func headersMiddleware(_ *MiddlewarePort, options map[string]string) (Middleware, error) {
+	request := make(map[string]string)
+	response := make(map[string]string)
+	for key, value := range options {
+		if name, ok := strings.CutPrefix(key, responseHeaderPrefix); ok {
+			response[http.CanonicalHeaderKey(name)] = value
+			continue
+		}
+		request[http.CanonicalHeaderKey(key)] = value
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(request) > 0 {
+				r = r.Clone(r.Context())
+				setHeaders(r.Header, request)
+			}
+			if len(response) > 0 {
+				w = &headersWriter{ResponseWriter: w, headers: response}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// headersWriter struct sets the response headers of the headers middleware
+// when the response is written, replacing the headers of the target.
+type headersWriter struct {
+	http.ResponseWriter
+	headers map[string]string
+	wrote   bool
+}
+
+// WriteHeader method implements http.ResponseWriter WriteHeader method.
+func (w *headersWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.wrote = true
+		setHeaders(w.Header(), w.headers)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write method implements http.ResponseWriter Write method.
+func (w *headersWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap method returns the original http.ResponseWriter, used by
+// http.ResponseController to flush and hijack.
+func (w *headersWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setHeaders function sets the headers, removing the ones without value.
+func setHeaders(h http.Header, headers map[string]string) {
+	for name, value := range headers {
+		if value == "" {
+			h.Del(name)
+			continue
+		}
+		h.Set(name, value)
+	}
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+// nodePort is the port of the nodes of NodeListener.
+const nodePort = "443/https"
+
+// nodeListener struct is a listener of a node of a proxy provider, the node
+// is closed with the listener.
+type nodeListener struct {
+	net.Listener
+	node      proxyproviders.ProxyInterface
+	closeOnce sync.Once
+}
+
+// NodeListener method starts a node of a proxy provider and returns a HTTPS
+// listener on its port 443, with the certificate of the node. The global
+// defaultProxyProvider is used without providerName. Used to serve the
+// dashboard in the tailnet.
+func (pm *ProxyManager) NodeListener(ctx context.Context, providerName, hostname string) (net.Listener, error) {
+	if providerName == "" {
+		providerName = config.Config.DefaultProxyProvider
+	}
+	_, provider, ok := pm.lookupProxyProvider(providerName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProxyProviderNotFound, providerName)
+	}
+
+	pcfg, err := model.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	port, err := model.NewPortShortLabel(nodePort)
+	if err != nil {
+		return nil, err
+	}
+	pcfg.Hostname = hostname
+	pcfg.ProxyProvider = providerName
+	pcfg.Ports = map[string]model.PortConfig{nodePort: port}
+
+	node, err := provider.NewProxy(pcfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.Start(ctx); err != nil {
+		return nil, errors.Join(err, node.Close())
+	}
+	go pm.watchNode(hostname, node)
+
+	l, err := node.GetListener(nodePort)
+	if err != nil {
+		return nil, errors.Join(err, node.Close())
+	}
+
+	return &nodeListener{Listener: l, node: node}, nil
+}
+
+// watchNode method logs the status changes of a node of NodeListener.
+func (pm *ProxyManager) watchNode(hostname string, node proxyproviders.ProxyInterface) {
+	log := pm.log.With().Str("node", hostname).Logger()
+
+	for event := range node.WatchEvents() {
+		switch event.Status {
+		case model.ProxyStatusAuthenticating:
+			log.Warn().Str("authURL", event.AuthURL).Msg("Dashboard node needs to be authenticated")
+		case model.ProxyStatusRunning:
+			log.Info().Str("url", node.GetURL()).Msg("Dashboard node running")
+		case model.ProxyStatusError:
+			log.Error().Msg("Dashboard node failed")
+		}
+	}
+}
+
+// Close method implements net.Listener Close method, the node is closed
+// with the listener.
+func (l *nodeListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() {
+		err = errors.Join(err, l.node.Close())
+	})
+
+	return err
+}
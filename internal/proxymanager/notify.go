@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+)
+
+// SetNotifier method sets the notifier of the proxy events that need attention.
+func (pm *ProxyManager) SetNotifier(n *notify.Notifier) {
+	pm.mtx.Lock()
+	pm.notifier = n
+	pm.mtx.Unlock()
+}
+
+// notify method sends an event to the notifier, if any.
+func (pm *ProxyManager) notify(event notify.Event) {
+	pm.mtx.RLock()
+	n := pm.notifier
+	pm.mtx.RUnlock()
+
+	n.Notify(event)
+}
+
+// notifyStatus method notifies the proxy statuses that need attention,
+// errors and authentication.
+func (pm *ProxyManager) notifyStatus(p *Proxy, status model.ProxyStatus) {
+	name := p.Config().Hostname
+
+	switch status {
+	case model.ProxyStatusError:
+		portErrors := p.GetPortErrors()
+
+		msgs := make([]string, 0, len(portErrors))
+		for port, err := range portErrors {
+			msgs = append(msgs, port+": "+err)
+		}
+		slices.Sort(msgs)
+
+		pm.notify(notify.Event{
+			Type:    notify.EventProxyError,
+			Proxy:   name,
+			Message: strings.Join(msgs, "\n"),
+		})
+
+	case model.ProxyStatusAuthenticating:
+		pm.notify(notify.Event{
+			Type:    notify.EventAuthNeeded,
+			Proxy:   name,
+			Message: "Open the URL to add the proxy to the tailnet.",
+			AuthURL: p.GetAuthURL(),
+		})
+	}
+}
+
+// notifyRecovered method notifies that a proxy started a new node because
+// the state of its node was corrupted.
+func (pm *ProxyManager) notifyRecovered(p *Proxy, dir string) {
+	pm.notify(notify.Event{
+		Type:    notify.EventStateRecovered,
+		Proxy:   p.Config().Hostname,
+		Message: "The state of the node was corrupted and was moved to " + dir + ", the proxy logged in as a new node.",
+	})
+}
@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+)
+
+var ErrPlanUnsupported = errors.New("targetProvider can't plan changes")
+
+// Plan method returns the changes that a new configuration file of a target
+// provider would make to its proxies, without applying them. Proxies are
+// compared like in the events of the provider: changed proxies are reloaded
+// when possible, and restarted otherwise.
+func (pm *ProxyManager) Plan(providerName string, data []byte) (model.Plan, error) {
+	pm.mtx.RLock()
+	provider, ok := pm.TargetProviders[providerName]
+	pm.mtx.RUnlock()
+
+	if !ok {
+		return model.Plan{}, fmt.Errorf("%w: %s", ErrTargetProviderNotFound, providerName)
+	}
+
+	planner, ok := provider.(targetproviders.Planner)
+	if !ok {
+		return model.Plan{}, fmt.Errorf("%w: %s", ErrPlanUnsupported, providerName)
+	}
+
+	configs, err := planner.PlanSource(data)
+	if err != nil {
+		return model.Plan{}, err
+	}
+
+	plan := model.Plan{
+		Provider: providerName,
+		Proxies:  []model.ProxyPlan{},
+		DNS:      []model.DNSPlan{},
+		Certs:    []model.CertPlan{},
+	}
+
+	proxies := pm.GetProxies()
+	current := make(map[string]*Proxy)
+	for _, p := range proxies {
+		if p.Config().TargetProvider == providerName {
+			current[p.Config().TargetID] = p
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(configs)) {
+		pcfg := configs[id]
+		proxy := current[id]
+
+		name, _, err := pm.getProxyProvider(pcfg)
+		if err != nil {
+			plan.Proxies = append(plan.Proxies, model.ProxyPlan{Name: pcfg.Hostname, Action: model.PlanError, Error: err.Error()})
+			continue
+		}
+		pcfg.ProxyProvider = name
+
+		if proxy == nil {
+			plan.Proxies = append(plan.Proxies, model.ProxyPlan{Name: pcfg.Hostname, Action: model.PlanCreate})
+			plan.Certs = appendCert(plan.Certs, pcfg)
+			continue
+		}
+
+		changes := changedFields(proxy.Config(), pcfg)
+		if len(changes) == 0 {
+			continue
+		}
+
+		action := model.PlanRestart
+		if proxy.canReload(pcfg) {
+			action = model.PlanReload
+			changes = restartedPorts(proxy.Config(), pcfg, changes)
+		} else if proxy.Config().Hostname != pcfg.Hostname || proxy.Config().ProxyProvider != pcfg.ProxyProvider {
+			// a different node orders its own certificate
+			plan.Certs = appendCert(plan.Certs, pcfg)
+		}
+
+		plan.Proxies = append(plan.Proxies, model.ProxyPlan{Name: pcfg.Hostname, Action: action, Changes: changes})
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(current)) {
+		if _, ok := configs[id]; !ok {
+			plan.Proxies = append(plan.Proxies, model.ProxyPlan{Name: current[id].Config().Hostname, Action: model.PlanStop})
+		}
+	}
+
+	if config.Config.PublicDNS.ZoneID != "" && len(config.Config.PublicDNS.Addresses) > 0 {
+		plan.DNS = planDNS(proxies, providerName, configs)
+	}
+
+	return plan, nil
+}
+
+// appendCert function appends the certificate ordered by the new node of a
+// proxy, if it has https ports.
+func appendCert(certs []model.CertPlan, pcfg *model.Config) []model.CertPlan {
+	var ports []string
+	for k, p := range pcfg.Ports {
+		if p.ProxyProtocol == "https" {
+			ports = append(ports, k)
+		}
+	}
+	if len(ports) == 0 {
+		return certs
+	}
+	slices.Sort(ports)
+
+	return append(certs, model.CertPlan{Proxy: pcfg.Hostname, Ports: ports})
+}
+
+// changedFields function returns the configuration fields of a proxy that
+// differ between two configurations, ports are compared one by one.
+func changedFields(a, b *model.Config) []string {
+	fields := []struct {
+		a, b any
+		name string
+	}{
+		{a.Hostname, b.Hostname, "hostname"},
+		{a.ProxyProvider, b.ProxyProvider, "proxyProvider"},
+		{a.Tailscale, b.Tailscale, "tailscale"},
+		{a.Exec, b.Exec, "exec"},
+		{a.Dashboard, b.Dashboard, "dashboard"},
+		{a.AccessLog, b.AccessLog, "accessLog"},
+		{a.ProxyAccessLog, b.ProxyAccessLog, "proxyAccessLog"},
+		{a.CachePurge, b.CachePurge, "cachePurge"},
+		{a.PublicDNS, b.PublicDNS, "publicDns"},
+		{a.Readiness, b.Readiness, "readiness"},
+	}
+
+	var changes []string
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.a, f.b) {
+			changes = append(changes, f.name)
+		}
+	}
+
+	labels := slices.Sorted(maps.Keys(a.Ports))
+	for k := range b.Ports {
+		if _, ok := a.Ports[k]; !ok {
+			labels = append(labels, k)
+		}
+	}
+	slices.Sort(labels)
+
+	for _, k := range labels {
+		oldPort, inOld := a.Ports[k]
+		newPort, inNew := b.Ports[k]
+		switch {
+		case !inOld:
+			changes = append(changes, "ports."+k+" added")
+		case !inNew:
+			changes = append(changes, "ports."+k+" removed")
+		case !reflect.DeepEqual(oldPort, newPort):
+			changes = append(changes, "ports."+k)
+		}
+	}
+
+	return changes
+}
+
+// restartedPorts function marks the changed ports of a reload that need a
+// new listener, the others keep their connections.
+func restartedPorts(a, b *model.Config, changes []string) []string {
+	for i, c := range changes {
+		k, ok := strings.CutPrefix(c, "ports.")
+		if !ok {
+			continue
+		}
+		if oldPort, ok := a.Ports[k]; ok && !sameListener(oldPort, b.Ports[k]) {
+			changes[i] = c + " restarted"
+		}
+	}
+
+	return changes
+}
+
+// planDNS function returns the public DNS names published and unpublished
+// when the proxies of a target provider are replaced by configs.
+func planDNS(proxies ProxyList, providerName string, configs map[string]*model.Config) []model.DNSPlan {
+	before := make(map[string]string)
+	after := make(map[string]string)
+	for _, p := range proxies {
+		if p.Config().PublicDNS.IsEnabled() {
+			before[p.Config().PublicDNS.Name] = p.Config().Hostname
+			if p.Config().TargetProvider != providerName {
+				after[p.Config().PublicDNS.Name] = p.Config().Hostname
+			}
+		}
+	}
+	for _, pcfg := range configs {
+		if pcfg.PublicDNS.IsEnabled() {
+			after[pcfg.PublicDNS.Name] = pcfg.Hostname
+		}
+	}
+
+	dns := []model.DNSPlan{}
+	for _, name := range slices.Sorted(maps.Keys(after)) {
+		if _, ok := before[name]; !ok {
+			dns = append(dns, model.DNSPlan{Name: name, Proxy: after[name], Action: model.PlanPublish})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(before)) {
+		if _, ok := after[name]; !ok {
+			dns = append(dns, model.DNSPlan{Name: name, Proxy: before[name], Action: model.PlanUnpublish})
+		}
+	}
+
+	return dns
+}
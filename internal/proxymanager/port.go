@@ -11,45 +11,152 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/accesslog"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyprotocol"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/respcache"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/tracing"
 
 	"github.com/rs/zerolog"
 )
 
-type port struct {
-	log        zerolog.Logger
-	ctx        context.Context
-	listener   net.Listener
-	cancel     context.CancelFunc
-	httpServer *http.Server
-	mtx        sync.Mutex
+// targetDialTimeout is the connect timeout of targets with PROXY protocol.
+const targetDialTimeout = 30 * time.Second
+
+var ErrNoTargets = errors.New("port has no targets")
+
+type (
+	port struct {
+		log        zerolog.Logger
+		ctx        context.Context
+		listener   net.Listener
+		cancel     context.CancelFunc
+		httpServer *http.Server
+		handler    *swapHandler
+		balancer   *balancer
+		checker    *healthChecker
+		cache      *respcache.Cache
+		stats      *portStats
+		ipFilter   *ipFilter
+		config     model.PortConfig
+		mtx        sync.Mutex
+	}
+
+	// swapHandler is a http.Handler that can be replaced while serving requests.
+	swapHandler struct {
+		handler atomic.Pointer[http.Handler]
+	}
+)
+
+func newSwapHandler(h http.Handler) *swapHandler {
+	s := &swapHandler{}
+	s.swap(h)
+	return s
+}
+
+func (s *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*s.handler.Load()).ServeHTTP(w, r)
+}
+
+func (s *swapHandler) swap(h http.Handler) {
+	s.handler.Store(&h)
+}
+
+// newPort function returns a port that serves handler on the listener
+// provided in startWithListener.
+func newPort(ctx context.Context, pconfig model.PortConfig, log zerolog.Logger, handler http.Handler) *port {
+	ctxPort, cancel := context.WithCancel(ctx)
+
+	swap := newSwapHandler(handler)
+	stats := &portStats{}
+
+	maxHeaderBytes, _, _ := portLimits(pconfig)
+
+	httpServer := &http.Server{
+		Handler:           stats.middleware(swap),
+		ReadHeaderTimeout: core.ReadHeaderTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return ctxPort },
+		ConnState:         stats.connState,
+	}
+
+	return &port{
+		log:        log,
+		ctx:        ctxPort,
+		cancel:     cancel,
+		httpServer: httpServer,
+		handler:    swap,
+		stats:      stats,
+		config:     pconfig,
+	}
 }
 
 func newPortProxy(
 	ctx context.Context,
 	pconfig model.PortConfig,
 	log zerolog.Logger,
-	accessLog bool,
-	whoisFunc func(next http.Handler) http.Handler,
-) *port {
+	middleware Middleware,
+	tlsConfig *tls.Config,
+	breaker *circuitBreaker,
+	cache *respcache.Cache,
+	errorPage func(w http.ResponseWriter, r *http.Request, status int),
+) (*port, error) {
 	//
 	log = log.With().Str("port", pconfig.String()).Logger()
 
-	ctxPort, cancel := context.WithCancel(ctx)
+	// unix socket targets are requested with a host of their own
+	targets, sockets := upstreamTargets(pconfig.GetTargets())
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTargets, pconfig.String())
+	}
 
 	// Create the reverse proxy
 	//
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: !pconfig.TLSValidate}, //nolint
+		TLSClientConfig: tlsConfig,
+	}
+	dialer := &net.Dialer{Timeout: targetDialTimeout, KeepAlive: targetDialTimeout}
+	dial := dialer.DialContext
+	if len(sockets) > 0 {
+		dial = unixDialer(sockets, dial)
+		tr.DialContext = dial
+	}
+	// the PROXY protocol header is sent once per connection, so connections
+	// aren't reused between clients
+	if pconfig.TargetProxyProtocol != "" {
+		tr.DialContext = proxyprotocol.Dialer(pconfig.TargetProxyProtocol, dial)
+		tr.DisableKeepAlives = true
 	}
+	var transport http.RoundTripper = accesslog.NewRoundTripper(tracing.NewRoundTripper(tr))
+
+	// ports with multiple targets spread the requests between them, the
+	// balancer also runs the circuit breaker of the targets
+	var b *balancer
+	if len(targets) > 1 || breaker != nil {
+		b = newBalancer(targets, pconfig.Retry, breaker, transport)
+		transport = b
+	}
+
 	reverseProxy := &httputil.ReverseProxy{
-		Transport: tr,
+		Transport: transport,
 		Rewrite: func(r *httputil.ProxyRequest) {
-			r.SetURL(pconfig.GetFirstTarget())
+			// the target of the balancer is picked before the reverse proxy
+			if t, ok := r.In.Context().Value(balancerContextKey{}).(*target); ok && t != nil {
+				r.SetURL(t.url)
+			} else {
+				r.SetURL(targets[0])
+			}
+			if pconfig.TargetProxyProtocol != "" {
+				r.Out = r.Out.WithContext(proxyprotocol.WithSource(r.Out.Context(), r.In.RemoteAddr))
+			}
 			r.Out.Host = r.In.Host
 			r.Out.Header["X-Forwarded-For"] = r.In.Header["X-Forwarded-For"]
 
@@ -61,52 +168,88 @@ func newPortProxy(
 
 			r.SetXForwarded()
 		},
-	}
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
 
-	handler := whoisFunc(reverseProxy)
-	// add logger to proxy
-	if accessLog {
-		handler = core.LoggerMiddleware(log, handler)
-	}
+			log.Error().Err(err).Str("url", r.URL.String()).Msg("error proxying request")
 
-	// main http Server
-	httpServer := &http.Server{
-		Handler:           handler,
-		ReadHeaderTimeout: core.ReadHeaderTimeout,
-		BaseContext:       func(net.Listener) context.Context { return ctxPort },
+			// the client is gone, there is nobody to show the page to
+			if errors.Is(err, context.Canceled) {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			status := http.StatusBadGateway
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				status = http.StatusGatewayTimeout
+			case errors.Is(err, ErrCircuitOpen):
+				status = http.StatusServiceUnavailable
+			}
+			errorPage(w, r, status)
+		},
 	}
 
-	return &port{
-		log:        log,
-		ctx:        ctxPort,
-		cancel:     cancel,
-		httpServer: httpServer,
+	handler := http.Handler(reverseProxy)
+	if b != nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// with the circuits of all targets open there is nowhere to send
+			// the request
+			t := b.pick()
+			if t == nil {
+				errorPage(w, r, http.StatusServiceUnavailable)
+				return
+			}
+			reverseProxy.ServeHTTP(w, r.WithContext(withTarget(r.Context(), t)))
+		})
 	}
+
+	p := newPort(ctx, pconfig, log, middleware(handler))
+	p.balancer = b
+	p.cache = cache
+
+	return p, nil
 }
 
 func newPortRedirect(ctx context.Context, pconfig model.PortConfig, log zerolog.Logger) *port {
 	log = log.With().Str("port", pconfig.String()).Logger()
 
-	ctxPort, cancel := context.WithCancel(ctx)
+	target := pconfig.GetFirstTarget()
 
-	redirectHTTPServer := &http.Server{
-		ReadHeaderTimeout: core.ReadHeaderTimeout,
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			http.Redirect(w, r, pconfig.GetFirstTarget().String(), http.StatusMovedPermanently)
-		}),
-	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target.Hostname() != "" {
+			http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+			return
+		}
 
-	return &port{
-		log:        log,
-		ctx:        ctxPort,
-		cancel:     cancel,
-		httpServer: redirectHTTPServer,
-	}
+		// targets without host redirect to the same host and path, like the
+		// ports added by RedirectToHTTPS
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if target.Port() != "" {
+			host = net.JoinHostPort(host, target.Port())
+		}
+		u := url.URL{Scheme: target.Scheme, Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+
+	return newPort(ctx, pconfig, log, handler)
 }
 
 func (p *port) startWithListener(l net.Listener) error {
 	p.mtx.Lock()
+	_, maxConnections, acceptBackoff := portLimits(p.config)
+	l = p.ipFilter.listener(l)
+	l = newLimitListener(l, maxConnections, acceptBackoff)
+	l = newStatsListener(l, p.stats)
 	p.listener = l
+	p.checker.start(p.ctx)
 	p.mtx.Unlock()
 
 	err := p.httpServer.Serve(l)
@@ -118,11 +261,72 @@ func (p *port) startWithListener(l net.Listener) error {
 	return nil
 }
 
+// swap method replaces the port handler with the handler of other port.
+// Used to apply a new configuration without closing the listener.
+func (p *port) swap(other *port) {
+	p.handler.swap(*other.handler.handler.Load())
+	other.cancel()
+
+	p.mtx.Lock()
+	p.config = other.config
+	oldBalancer := p.balancer
+	p.balancer = other.balancer
+	oldCache := p.cache
+	p.cache = other.cache
+	oldChecker := p.checker
+	p.checker = other.checker
+	p.checker.start(p.ctx)
+	p.mtx.Unlock()
+
+	oldCache.Close()
+	oldChecker.stop()
+	oldBalancer.close()
+}
+
+// purgeCache method removes the cached responses with a path matching the
+// pattern.
+func (p *port) purgeCache(pattern string) int {
+	p.mtx.Lock()
+	c := p.cache
+	p.mtx.Unlock()
+
+	return c.Purge(pattern)
+}
+
+// targetHealth method returns the health of the targets, nil if the port
+// has a single target without health check.
+func (p *port) targetHealth() []model.TargetHealth {
+	p.mtx.Lock()
+	b := p.balancer
+	c := p.checker
+	p.mtx.Unlock()
+
+	var health []model.TargetHealth
+	if b != nil {
+		health = b.health()
+	}
+	if c != nil {
+		health = c.health(health)
+	}
+
+	return health
+}
+
+// close method stops accepting connections and waits for active requests
+// to finish up to the configured drain timeout.
 func (p *port) close() error {
 	var errs error
 
 	if p.httpServer != nil {
-		errs = errors.Join(errs, p.httpServer.Shutdown(p.ctx))
+		ctx, cancel := context.WithTimeout(context.Background(), config.Config.ProxyDrainTimeout)
+		defer cancel()
+
+		err := p.httpServer.Shutdown(ctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			// the requests still active after the drain timeout are dropped
+			err = p.httpServer.Close()
+		}
+		errs = errors.Join(errs, err)
 	}
 
 	if p.listener != nil {
@@ -130,6 +334,8 @@ func (p *port) close() error {
 	}
 
 	p.cancel()
+	p.cache.Close()
+	p.balancer.close()
 
 	return errs
 }
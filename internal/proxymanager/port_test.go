@@ -0,0 +1,325 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/respcache"
+)
+
+// TestMain function loads a minimal configuration, the ports read the drain
+// timeout from it.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "proxymanager")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	file := filepath.Join(dir, "tsdproxy.yaml")
+	data := fmt.Sprintf("proxyDrainTimeout: 1s\ntailscale:\n  dataDir: %s\n  providers:\n    default: {}\nletsEncrypt:\n  cacheDir: %s\n", dir, dir)
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := config.ReadConfig(file); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testPortConfig function returns an http port with the targets.
+func testPortConfig(t *testing.T, targets ...string) model.PortConfig {
+	t.Helper()
+
+	pconfig := model.PortConfig{ProxyPort: 80, ProxyProtocol: "http"}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pconfig.AddTarget(u)
+	}
+
+	return pconfig
+}
+
+// cacheDirs function returns the directories of the disk caches created in
+// dir.
+func cacheDirs(t *testing.T, dir string) []string {
+	t.Helper()
+
+	dirs, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dirs
+}
+
+// bodyMiddleware function returns a middleware that answers the requests
+// with body, to tell the handlers of the ports apart.
+func bodyMiddleware(body string) Middleware {
+	return func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(body))
+		})
+	}
+}
+
+func testErrorPage(w http.ResponseWriter, _ *http.Request, status int) {
+	http.Error(w, "error page", status)
+}
+
+func TestNewPortProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		targets  []string
+		breaker  *circuitBreaker
+		err      error
+		balancer bool
+	}{
+		{
+			name: "no targets",
+			err:  ErrNoTargets,
+		},
+		{
+			name:    "one target",
+			targets: []string{"http://127.0.0.1:8080"},
+		},
+		{
+			name:     "one target with circuit breaker",
+			targets:  []string{"http://127.0.0.1:8080"},
+			breaker:  newCircuitBreaker("proxy", "port", model.CircuitBreaker{ErrorRate: 0.5}),
+			balancer: true,
+		},
+		{
+			name:     "many targets",
+			targets:  []string{"http://127.0.0.1:8080", "http://127.0.0.1:8081"},
+			balancer: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newPortProxy(context.Background(), testPortConfig(t, tt.targets...), zerolog.Nop(),
+				noMiddleware, nil, tt.breaker, nil, testErrorPage)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if p != nil {
+					t.Fatal("port returned with an error")
+				}
+				return
+			}
+			defer p.close()
+
+			if (p.balancer != nil) != tt.balancer {
+				t.Errorf("balancer = %v, want %v", p.balancer != nil, tt.balancer)
+			}
+		})
+	}
+}
+
+func TestPortCircuitOpen(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("target"))
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name   string
+		open   []bool
+		status int
+		body   string
+	}{
+		{
+			name:   "all closed",
+			open:   []bool{false, false},
+			status: http.StatusOK,
+			body:   "target",
+		},
+		{
+			name:   "one open",
+			open:   []bool{true, false},
+			status: http.StatusOK,
+			body:   "target",
+		},
+		{
+			name:   "all open",
+			open:   []bool{true, true},
+			status: http.StatusServiceUnavailable,
+			body:   "error page\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newCircuitBreaker("proxy", "port", model.CircuitBreaker{ErrorRate: 0.5})
+			p, err := newPortProxy(context.Background(), testPortConfig(t, target.URL, target.URL), zerolog.Nop(),
+				noMiddleware, nil, breaker, nil, testErrorPage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.close()
+
+			for i, open := range tt.open {
+				if open {
+					p.balancer.targets[i].breaker = targetBreaker{state: breakerOpen, openedAt: time.Now()}
+				}
+			}
+
+			w := httptest.NewRecorder()
+			p.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestPortSwap(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []string
+	}{
+		{
+			name:    "same targets",
+			targets: []string{"http://127.0.0.1:8080"},
+		},
+		{
+			name:    "new targets",
+			targets: []string{"http://127.0.0.1:8081", "http://127.0.0.1:8082"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCacheDir, newCacheDir := t.TempDir(), t.TempDir()
+			oldCache, err := respcache.New(zerolog.Nop(), "proxy", "port", model.Cache{Disk: true}, oldCacheDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newCache, err := respcache.New(zerolog.Nop(), "proxy", "port", model.Cache{Disk: true}, newCacheDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			old, err := newPortProxy(context.Background(), testPortConfig(t, "http://127.0.0.1:8080"), zerolog.Nop(),
+				bodyMiddleware("old"), nil, nil, oldCache, testErrorPage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer old.close()
+
+			pconfig := testPortConfig(t, tt.targets...)
+			replacement, err := newPortProxy(context.Background(), pconfig, zerolog.Nop(),
+				bodyMiddleware("new"), nil, nil, newCache, testErrorPage)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			old.swap(replacement)
+
+			w := httptest.NewRecorder()
+			old.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Body.String() != "new" {
+				t.Errorf("handler body = %q, want %q", w.Body.String(), "new")
+			}
+			if len(old.config.GetTargets()) != len(tt.targets) {
+				t.Errorf("targets = %d, want %d", len(old.config.GetTargets()), len(tt.targets))
+			}
+			if old.balancer != replacement.balancer {
+				t.Error("balancer not replaced")
+			}
+			if old.cache != newCache {
+				t.Error("cache not replaced")
+			}
+
+			// the replaced cache is closed, the new one keeps serving
+			if dirs := cacheDirs(t, oldCacheDir); len(dirs) != 0 {
+				t.Errorf("old cache directories = %v, want none", dirs)
+			}
+			if dirs := cacheDirs(t, newCacheDir); len(dirs) != 1 {
+				t.Errorf("new cache directories = %v, want one", dirs)
+			}
+
+			// the context of the replacement is canceled, the port keeps its own
+			if replacement.ctx.Err() == nil {
+				t.Error("replacement context not canceled")
+			}
+			if old.ctx.Err() != nil {
+				t.Error("port context canceled")
+			}
+		})
+	}
+}
+
+func TestClosePorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports int
+	}{
+		{name: "no ports"},
+		{name: "one port", ports: 1},
+		{name: "many ports", ports: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &Proxy{log: zerolog.Nop()}
+
+			var ports []*port
+			var dirs []string
+			for range tt.ports {
+				dir := t.TempDir()
+				cache, err := respcache.New(zerolog.Nop(), "proxy", "port", model.Cache{Disk: true}, dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				p, err := newPortProxy(context.Background(), testPortConfig(t, "http://127.0.0.1:8080"), zerolog.Nop(),
+					noMiddleware, nil, nil, cache, testErrorPage)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ports = append(ports, p)
+				dirs = append(dirs, dir)
+			}
+
+			proxy.closePorts(ports)
+
+			for i, p := range ports {
+				if p.ctx.Err() == nil {
+					t.Errorf("port %d context not canceled", i)
+				}
+				if d := cacheDirs(t, dirs[i]); len(d) != 0 {
+					t.Errorf("port %d cache directories = %v, want none", i, d)
+				}
+			}
+		})
+	}
+}
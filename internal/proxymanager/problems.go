@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+// Suggested fixes of the problems of the proxies and providers.
+const (
+	fixProxyError        = "Check that the targets of the ports are reachable and the port options are valid, then restart the proxy."
+	fixProxyProvider     = "Check the proxyProvider of the proxy, or set defaultProxyProvider in the configuration."
+	fixProviderCreate    = "Check the configuration of the provider and restart TSDProxy."
+	fixProviderWatch     = "Check the connection to the provider, like the Docker socket, and restart TSDProxy."
+	fixProviderReconnect = "Check the connection to the provider, like the Docker socket. TSDProxy reconnects automatically."
+	fixProviderWarnings  = "Check the configuration of the provider and the permissions of its data directory."
+)
+
+// Problems method returns the registry of the problems of the server, other
+// modules report their problems in it.
+func (pm *ProxyManager) Problems() *problems.Registry {
+	return pm.problems
+}
+
+// reportStatus method reports the errors of a proxy, they are resolved when
+// the proxy runs without port errors or is stopped.
+func (pm *ProxyManager) reportStatus(p *Proxy, status model.ProxyStatus) {
+	name := p.Config().Hostname
+	portErrors := p.GetPortErrors()
+
+	switch {
+	case status == model.ProxyStatusStopped:
+		pm.problems.Resolve(problems.SourceProxy, name)
+
+	case status == model.ProxyStatusConflict:
+		pm.problems.Report(problems.Problem{
+			Source:  problems.SourceProxy,
+			Subject: name,
+			Message: pm.conflictMessage(p),
+			Fix:     fixHostnameConflict,
+		})
+
+	case status == model.ProxyStatusError || len(portErrors) > 0:
+		msgs := make([]string, 0, len(portErrors))
+		for port, err := range portErrors {
+			msgs = append(msgs, port+": "+err)
+		}
+		slices.Sort(msgs)
+
+		if len(msgs) == 0 {
+			msgs = append(msgs, "proxy failed to start")
+		}
+
+		pm.problems.Report(problems.Problem{
+			Source:  problems.SourceProxy,
+			Subject: name,
+			Message: strings.Join(msgs, "\n"),
+			Fix:     fixProxyError,
+		})
+
+	case status == model.ProxyStatusRunning:
+		pm.problems.Resolve(problems.SourceProxy, name)
+	}
+}
+
+// reportProviderError method reports a provider that couldn't be created.
+func (pm *ProxyManager) reportProviderError(name string, err error) {
+	pm.problems.Report(problems.Problem{
+		Source:  problems.SourceProvider,
+		Subject: name,
+		Message: err.Error(),
+		Fix:     fixProviderCreate,
+	})
+}
+
+// reportProviderWarnings method reports the warnings of a proxy provider.
+func (pm *ProxyManager) reportProviderWarnings(name string, w proxyproviders.Warner) {
+	warnings := w.Warnings()
+	if len(warnings) == 0 {
+		return
+	}
+
+	pm.problems.Report(problems.Problem{
+		Source:  problems.SourceConfig,
+		Subject: name,
+		Message: strings.Join(warnings, "\n"),
+		Fix:     fixProviderWarnings,
+	})
+}
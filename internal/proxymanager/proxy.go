@@ -5,33 +5,89 @@ package proxymanager
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/accesslog"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/auth"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metadata"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/respcache"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/tracing"
 
 	"github.com/rs/zerolog"
 )
 
+const (
+	// statusHistorySize is the number of status transitions kept by a proxy
+	statusHistorySize = 50
+	// accessLogTailSize is the number of access log lines kept by a proxy
+	accessLogTailSize = 100
+)
+
+var (
+	ErrReloadNotPossible   = errors.New("proxy reload not possible")
+	ErrNodeInfoUnsupported = errors.New("proxy provider doesn't report node details")
+	ErrCertsUnsupported    = errors.New("proxy provider doesn't report certificates")
+)
+
 type (
 	// Proxy struct is a struct that contains all the information needed to run a proxy.
 	Proxy struct {
 		onUpdate func(event model.ProxyEvent)
+		// onRecover is called with the directory of the corrupted state when
+		// the proxy provider started a new node
+		onRecover func(dir string)
 
 		log           zerolog.Logger
 		ctx           context.Context
 		providerProxy proxyproviders.ProxyInterface
-		Config        *model.Config
+		oidcProviders OIDCProviderList
+		aclGroups     *auth.ACLGroups
+		banners       *bannerStore
+		pages         *pageStore
+		budget        *requestBudget
+		accessLog     *accesslog.Logger
+		accessLogTail *accesslog.Tail
+		// config is replaced by Reload, read it with Config
+		config        atomic.Pointer[model.Config]
 		URL           *url.URL
 		cancel        context.CancelFunc
 		ports         map[string]*port
+		portErrors    map[string]string
+		protocols     map[string]string
+		process       *process
+		metadata      *metadata.Metadata
+		statusChanged time.Time
+		statusHistory []model.StatusChange
 		mtx           sync.RWMutex
+		metadataOnce  sync.Once
+		closeOnce     sync.Once
+		readinessOnce sync.Once
 		status        model.ProxyStatus
+		// providerStatus is the last status of the proxy provider, the proxy
+		// stays Starting while it's Running and the targets aren't ready
+		providerStatus model.ProxyStatus
+		// ready is true when the targets passed the readiness probe, or
+		// without readiness
+		ready bool
+		// conflict is the hostname of the proxy used by another proxy, when
+		// it was rejected with the Conflict status
+		conflict string
+		// restored is true while the proxy was restored from the saved
+		// proxies and its target provider didn't start it again
+		restored atomic.Bool
 	}
 )
 
@@ -39,6 +95,11 @@ type (
 func NewProxy(log zerolog.Logger,
 	pcfg *model.Config,
 	proxyProvider proxyproviders.Provider,
+	oidcProviders OIDCProviderList,
+	aclGroups *auth.ACLGroups,
+	banners *bannerStore,
+	pages *pageStore,
+	budget *requestBudget,
 ) (*Proxy, error) {
 	//
 	var err error
@@ -60,15 +121,38 @@ func NewProxy(log zerolog.Logger,
 		Str("hostname", pcfg.Hostname).
 		Msg("Proxy server created successfully")
 
+	tail := accesslog.NewTail(accessLogTailSize)
+
+	accessLog, err := newAccessLog(log, pcfg, tail)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing access log: %w", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	p := &Proxy{
 		log:           log,
-		Config:        pcfg,
 		ctx:           ctx,
 		cancel:        cancel,
 		providerProxy: pProvider,
+		oidcProviders: oidcProviders,
+		aclGroups:     aclGroups,
+		banners:       banners,
+		pages:         pages,
+		budget:        budget,
+		accessLog:     accessLog,
+		accessLogTail: tail,
 		ports:         make(map[string]*port),
+		portErrors:    make(map[string]string),
+		protocols:     make(map[string]string),
+		statusChanged: time.Now(),
+		ready:         !pcfg.Readiness.Enabled,
+	}
+	p.config.Store(pcfg)
+	p.statusHistory = []model.StatusChange{{Time: p.statusChanged, Status: model.ProxyStatusInitializing}}
+
+	if pcfg.Exec.IsEnabled() {
+		p.process = newProcess(log, pcfg.Exec)
 	}
 
 	p.initPorts()
@@ -76,28 +160,66 @@ func NewProxy(log zerolog.Logger,
 	return p, nil
 }
 
+// newAccessLog function returns the access logger of the proxy, nil if disabled.
+func newAccessLog(log zerolog.Logger, pcfg *model.Config, tail *accesslog.Tail) (*accesslog.Logger, error) {
+	if !pcfg.ProxyAccessLog {
+		return nil, nil //nolint:nilnil
+	}
+
+	l, err := accesslog.New(log, pcfg.Hostname, pcfg.AccessLog)
+	if err != nil {
+		return nil, err
+	}
+	l.SetTail(tail)
+
+	return l, nil
+}
+
 func (proxy *Proxy) Start() {
 	go func() {
 		go proxy.start()
 		for event := range proxy.providerProxy.WatchEvents() {
-			proxy.setStatus(event.Status)
+			proxy.setProviderStatus(event.Status)
 		}
 	}()
 }
 
 // Close method is a method that initiate proxy close procedure.
+// Closing a closed proxy does nothing.
 func (proxy *Proxy) Close() {
+	proxy.closeOnce.Do(func() {
+		proxy.stop(false)
+	})
+}
+
+// Remove method closes the proxy and removes its node from the network, if
+// the proxy provider supports it. Used when the target of the proxy is
+// deleted, closed proxies aren't removed.
+func (proxy *Proxy) Remove() {
+	proxy.closeOnce.Do(func() {
+		proxy.stop(true)
+	})
+}
+
+// stop method stops the proxy, removing its node with remove.
+func (proxy *Proxy) stop(remove bool) {
 	proxy.setStatus(model.ProxyStatusStopping)
 
+	// make sure all listeners are closed and active requests are drained
+	proxy.close(remove)
+
 	// cancel context
 	proxy.cancel()
 
-	// make sure all listeners are closed
-	proxy.close()
-
 	proxy.setStatus(model.ProxyStatusStopped)
 }
 
+// Config method returns the configuration of the proxy, replaced by Reload.
+// The configuration must not be modified.
+func (proxy *Proxy) Config() *model.Config {
+	return proxy.config.Load()
+}
+
 func (proxy *Proxy) GetStatus() model.ProxyStatus {
 	proxy.mtx.RLock()
 	defer proxy.mtx.RUnlock()
@@ -105,6 +227,79 @@ func (proxy *Proxy) GetStatus() model.ProxyStatus {
 	return proxy.status
 }
 
+// GetStatusHistory method returns the last status transitions of the proxy, oldest first.
+func (proxy *Proxy) GetStatusHistory() []model.StatusChange {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	return slices.Clone(proxy.statusHistory)
+}
+
+// GetAccessLogTail method returns the last access log lines of the proxy.
+func (proxy *Proxy) GetAccessLogTail() *accesslog.Tail {
+	return proxy.accessLogTail
+}
+
+// GetNodeInfo method returns the details of the proxy node.
+func (proxy *Proxy) GetNodeInfo(ctx context.Context) (model.NodeInfo, error) {
+	d, ok := proxy.providerProxy.(proxyproviders.Describer)
+	if !ok {
+		return model.NodeInfo{}, ErrNodeInfoUnsupported
+	}
+
+	return d.NodeInfo(ctx)
+}
+
+// GetCertificates method returns the TLS certificates of the proxy node.
+func (proxy *Proxy) GetCertificates(ctx context.Context) ([]model.CertInfo, error) {
+	c, ok := proxy.providerProxy.(proxyproviders.Certifier)
+	if !ok {
+		return nil, ErrCertsUnsupported
+	}
+
+	return c.Certificates(ctx)
+}
+
+// GetProtocols method returns the last protocol detected in each tcp port.
+func (proxy *Proxy) GetProtocols() map[string]string {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	return maps.Clone(proxy.protocols)
+}
+
+// GetTargetHealth method returns the health of the targets of the ports
+// with multiple targets, by port name.
+func (proxy *Proxy) GetTargetHealth() map[string][]model.TargetHealth {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	health := make(map[string][]model.TargetHealth)
+	for name, p := range proxy.ports {
+		if h := p.targetHealth(); h != nil {
+			health[name] = h
+		}
+	}
+
+	return health
+}
+
+// GetStatusChanged method returns when the status of the proxy last changed.
+func (proxy *Proxy) GetStatusChanged() time.Time {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	return proxy.statusChanged
+}
+
+// GetPortErrors method returns the errors of ports that failed to start.
+func (proxy *Proxy) GetPortErrors() map[string]string {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	return maps.Clone(proxy.portErrors)
+}
+
 func (proxy *Proxy) GetURL() string {
 	return proxy.providerProxy.GetURL()
 }
@@ -113,6 +308,10 @@ func (proxy *Proxy) GetAuthURL() string {
 	return proxy.providerProxy.GetAuthURL()
 }
 
+func (proxy *Proxy) GetTailnet() string {
+	return proxy.providerProxy.GetTailnet()
+}
+
 func (proxy *Proxy) ProviderUserMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		who := proxy.providerProxy.Whois(r)
@@ -124,13 +323,11 @@ func (proxy *Proxy) ProviderUserMiddleware(next http.Handler) http.Handler {
 }
 
 func (proxy *Proxy) initPorts() {
-	var newPort *port
-	for k, v := range proxy.Config.Ports {
-		log := proxy.log.With().Str("port", k).Logger()
-		if v.IsRedirect {
-			newPort = newPortRedirect(proxy.ctx, v, log)
-		} else {
-			newPort = newPortProxy(proxy.ctx, v, log, proxy.Config.ProxyAccessLog, proxy.ProviderUserMiddleware)
+	for k, v := range proxy.Config().Ports {
+		newPort, err := proxy.newPort(k, v, proxy.accessLog)
+		if err != nil {
+			proxy.log.Error().Err(err).Str("port", k).Msg("error configuring port")
+			continue
 		}
 
 		proxy.log.Debug().Any("port", newPort).Msg("newport")
@@ -141,12 +338,269 @@ func (proxy *Proxy) initPorts() {
 	}
 }
 
+// newPort method creates the port for a port configuration.
+func (proxy *Proxy) newPort(name string, pconfig model.PortConfig, accessLog *accesslog.Logger) (*port, error) {
+	log := proxy.log.With().Str("port", name).Logger()
+
+	filter, err := newIPFilter(proxy.Config().Hostname, name, pconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := filter.middleware(proxy)
+	banner := bannerMiddleware(proxy.Config().Hostname, proxy.banners)
+	maintenance := maintenanceMiddleware(proxy.Config().Hostname, proxy.pages)
+	budget := budgetMiddleware(proxy.Config().Hostname, proxy.budget)
+	requestMiddleware := func(next http.Handler) http.Handler {
+		return allowed(maintenance(proxy.readinessMiddleware(banner(budget(next)))))
+	}
+	if accessLog != nil {
+		checks, logMiddleware := requestMiddleware, accessLog.Middleware(name)
+		requestMiddleware = func(next http.Handler) http.Handler {
+			return logMiddleware(checks(next))
+		}
+	}
+	// the span of the request covers the access log and the checks
+	traced, trace := requestMiddleware, tracing.Middleware(proxy.Config().Hostname, name)
+	requestMiddleware = func(next http.Handler) http.Handler {
+		return trace(traced(next))
+	}
+
+	if pconfig.IsRedirect {
+		p := newPortRedirect(proxy.ctx, pconfig, log)
+		p.ipFilter = filter
+		return p, nil
+	}
+
+	// ports never fail open, access control and limits need their middleware
+	if err := requiredMiddlewares(pconfig); err != nil {
+		return nil, err
+	}
+
+	for _, m := range []struct {
+		name    string
+		enabled bool
+	}{
+		{MiddlewareCache, pconfig.Cache.Enabled},
+		{MiddlewareCompression, pconfig.Compression.Enabled},
+	} {
+		if m.enabled && !hasMiddleware(pconfig, m.name) {
+			log.Warn().Str("middleware", m.name).Msg("middleware is enabled but not in the port middlewares")
+		}
+	}
+
+	// the TLS configuration to the targets is shared with the health checks
+	var tlsConfig *tls.Config
+	if !pconfig.IsStatic() {
+		var err error
+		if tlsConfig, err = upstreamTLSConfig(pconfig); err != nil {
+			return nil, err
+		}
+	}
+
+	// cached responses are served after authentication, with the access log
+	var cache *respcache.Cache
+	if !pconfig.IsStatic() && hasMiddleware(pconfig, MiddlewareCache) {
+		var err error
+		if cache, err = proxy.newCache(name, pconfig); err != nil {
+			return nil, err
+		}
+	}
+
+	chain, err := proxy.newChain(&MiddlewarePort{
+		Log:    log,
+		Proxy:  proxy.Config().Hostname,
+		Name:   name,
+		Config: pconfig,
+		proxy:  proxy,
+		cache:  cache,
+	})
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+
+	// the user is identified before the chain, auth middlewares may replace it
+	middleware := func(next http.Handler) http.Handler {
+		return requestMiddleware(proxy.ProviderUserMiddleware(accesslog.Identify(chain(next))))
+	}
+
+	if pconfig.IsStatic() {
+		p := newPortStatic(proxy.ctx, pconfig, log, middleware)
+		p.ipFilter = filter
+		return p, nil
+	}
+
+	p, err := newPortProxy(proxy.ctx, pconfig, log, middleware, tlsConfig,
+		newCircuitBreaker(proxy.Config().Hostname, name, pconfig.CircuitBreaker), cache, proxy.errorPage)
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+	p.ipFilter = filter
+
+	switch {
+	case pconfig.HealthCheck.Type == "":
+	case pconfig.ProxyProtocol != "tcp":
+		log.Warn().Msg("health checks are only supported in tcp ports")
+	default:
+		p.checker = newHealthChecker(proxy.Config().Hostname, name, pconfig, tlsConfig, log, p.balancer, proxy.healthChanged)
+	}
+
+	return p, nil
+}
+
+// Reload method applies a new configuration without restarting the proxy provider.
+// Ports with the same listener get their handler swapped, so no connections
+// are dropped. New and changed ports are started before the removed and
+// replaced ports are drained.
+// Returns ErrReloadNotPossible if the change requires a new proxy provider node.
+func (proxy *Proxy) Reload(pcfg *model.Config) error {
+	if !proxy.canReload(pcfg) {
+		return ErrReloadNotPossible
+	}
+
+	proxy.log.Info().Msg("reloading proxy")
+
+	// keep the access log sink if its configuration didn't change
+	current := proxy.Config()
+	accessLog := proxy.accessLog
+	accessLogChanged := current.ProxyAccessLog != pcfg.ProxyAccessLog ||
+		!reflect.DeepEqual(current.AccessLog, pcfg.AccessLog)
+	if accessLogChanged {
+		var err error
+		if accessLog, err = newAccessLog(proxy.log, pcfg, proxy.accessLogTail); err != nil {
+			return fmt.Errorf("error initializing access log: %w", err)
+		}
+	}
+
+	newPorts := make(map[string]*port)
+	for k, v := range pcfg.Ports {
+		newPort, err := proxy.newPort(k, v, accessLog)
+		if err != nil {
+			// the ports built before the failure never served, but their
+			// balancers, checkers and caches are running
+			proxy.closePorts(slices.Collect(maps.Values(newPorts)))
+			if accessLogChanged && accessLog != nil {
+				accessLog.Close()
+			}
+			return err
+		}
+		newPorts[k] = newPort
+	}
+
+	proxy.mtx.Lock()
+	oldAccessLog := proxy.accessLog
+	proxy.accessLog = accessLog
+	oldPorts := proxy.ports
+	proxy.ports = make(map[string]*port)
+
+	var toStart []string
+	var toClose []*port
+	for k, newPort := range newPorts {
+		oldPort, ok := oldPorts[k]
+		if ok && sameListener(oldPort.config, newPort.config) {
+			oldPort.swap(newPort)
+			proxy.ports[k] = oldPort
+			delete(oldPorts, k)
+			continue
+		}
+		proxy.ports[k] = newPort
+		toStart = append(toStart, k)
+	}
+	for _, oldPort := range oldPorts {
+		toClose = append(toClose, oldPort)
+	}
+
+	// the configuration is replaced, not modified: readers keep a consistent
+	// copy, and the proxy provider listens the new ports with it
+	proxy.config.Store(pcfg)
+	if r, ok := proxy.providerProxy.(proxyproviders.Reconfigurer); ok {
+		r.Reconfigure(pcfg)
+	}
+	clear(proxy.portErrors)
+	proxy.mtx.Unlock()
+
+	// start the new listeners before draining the old ones, so the ports
+	// keep serving while their listener changes
+	for _, k := range toStart {
+		l, err := proxy.providerProxy.GetListener(k)
+		if err != nil && len(toClose) > 0 {
+			// the address may still be used by an old listener, drain them
+			// and listen again
+			proxy.closePorts(toClose)
+			toClose = nil
+			l, err = proxy.providerProxy.GetListener(k)
+		}
+		if err != nil {
+			proxy.log.Error().Err(err).Str("port", k).Msg("Error adding listener")
+			portConfig := pcfg.Ports[k]
+			proxy.setPortError(portConfig.String(), err)
+			continue
+		}
+		proxy.startPort(k, l)
+	}
+
+	proxy.closePorts(toClose)
+
+	if accessLogChanged && oldAccessLog != nil {
+		if err := oldAccessLog.Close(); err != nil {
+			proxy.log.Error().Err(err).Msg("error closing access log")
+		}
+	}
+
+	proxy.log.Info().Msg("proxy reloaded")
+
+	return nil
+}
+
+// closePorts method drains the ports replaced or removed by Reload.
+func (proxy *Proxy) closePorts(ports []*port) {
+	for _, p := range ports {
+		if err := p.close(); err != nil {
+			proxy.log.Error().Err(err).Msg("error closing port")
+		}
+	}
+}
+
+// canReload method returns true if the new configuration can be applied on the
+// running proxy provider node.
+func (proxy *Proxy) canReload(pcfg *model.Config) bool {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	current := proxy.Config()
+
+	return proxy.status == model.ProxyStatusRunning &&
+		current.Hostname == pcfg.Hostname &&
+		current.ProxyProvider == pcfg.ProxyProvider &&
+		reflect.DeepEqual(current.Tailscale, pcfg.Tailscale) &&
+		reflect.DeepEqual(current.Exec, pcfg.Exec)
+}
+
+// sameListener function returns true if both port configurations use the same listener.
+func sameListener(a, b model.PortConfig) bool {
+	return a.ProxyPort == b.ProxyPort &&
+		a.ProxyProtocol == b.ProxyProtocol &&
+		a.Tailscale == b.Tailscale &&
+		a.MaxHeaderBytes == b.MaxHeaderBytes &&
+		a.MaxConnections == b.MaxConnections &&
+		a.AcceptBackoff == b.AcceptBackoff &&
+		a.IPFamily == b.IPFamily &&
+		a.AcceptProxyProtocol == b.AcceptProxyProtocol &&
+		slices.Equal(a.AllowedCIDRs, b.AllowedCIDRs) &&
+		slices.Equal(a.DeniedCIDRs, b.DeniedCIDRs) &&
+		slices.Equal(a.AllowedCountries, b.AllowedCountries) &&
+		slices.Equal(a.DeniedCountries, b.DeniedCountries) &&
+		reflect.DeepEqual(a.MTLS, b.MTLS)
+}
+
 // Start method is a method that starts the proxy.
 func (proxy *Proxy) start() {
 	proxy.log.Info().Msg("starting proxy")
 
 	proxy.mtx.RLock()
-	portsConfig := proxy.Config.Ports
+	portsConfig := proxy.Config().Ports
 	portsCount := len(proxy.ports)
 	proxy.mtx.RUnlock()
 
@@ -157,12 +611,23 @@ func (proxy *Proxy) start() {
 		return
 	}
 
+	// start the supervised process before accepting connections
+	if proxy.process != nil {
+		proxy.process.start(proxy.ctx)
+	}
+
 	if err := proxy.providerProxy.Start(proxy.ctx); err != nil {
 		proxy.log.Error().Err(err).Msg("Error starting with proxy provider")
 		proxy.Close()
 		return
 	}
 
+	if r, ok := proxy.providerProxy.(proxyproviders.StateRecoverer); ok && proxy.onRecover != nil {
+		if dir := r.RecoveredState(); dir != "" {
+			proxy.onRecover(dir)
+		}
+	}
+
 	var l net.Listener
 	var err error
 
@@ -172,6 +637,8 @@ func (proxy *Proxy) start() {
 		l, err = proxy.providerProxy.GetListener(k)
 		if err != nil {
 			proxy.log.Error().Err(err).Str("port", k).Msg("Error adding listener")
+			portConfig := portsConfig[k]
+			proxy.setPortError(portConfig.String(), err)
 			continue
 		}
 
@@ -183,6 +650,13 @@ func (proxy *Proxy) startPort(name string, l net.Listener) {
 	proxy.mtx.RLock()
 	defer proxy.mtx.RUnlock()
 
+	// label tcp ports with the protocol of their clients
+	if proxy.Config().Ports[name].ProxyProtocol == "tcp" {
+		l = newDetectListener(l, func(protocol string) {
+			proxy.setProtocol(name, protocol)
+		})
+	}
+
 	// make sure port exists
 	if p, ok := proxy.ports[name]; ok {
 		go func() {
@@ -195,22 +669,97 @@ func (proxy *Proxy) startPort(name string, l net.Listener) {
 }
 
 // close method is a method that closes all listeners ans httpServer.
-func (proxy *Proxy) close() {
+// With remove, the provider proxy removes its node if it's a Remover.
+func (proxy *Proxy) close(remove bool) {
 	var errs error
-	proxy.log.Info().Str("name", proxy.Config.Hostname).Msg("stopping proxy")
+	proxy.log.Info().Str("name", proxy.Config().Hostname).Msg("stopping proxy")
+
+	// the ports are drained without the lock, a drain waits for the active
+	// requests
+	proxy.mtx.RLock()
+	ports := slices.Collect(maps.Values(proxy.ports))
+	accessLog := proxy.accessLog
+	proxy.mtx.RUnlock()
 
-	for _, p := range proxy.ports {
+	for _, p := range ports {
 		errs = errors.Join(errs, p.close())
 	}
 	if proxy.providerProxy != nil {
-		errs = errors.Join(proxy.providerProxy.Close())
+		if r, ok := proxy.providerProxy.(proxyproviders.Remover); ok && remove {
+			errs = errors.Join(errs, r.Remove())
+		} else {
+			errs = errors.Join(errs, proxy.providerProxy.Close())
+		}
+	}
+	if proxy.process != nil {
+		proxy.process.stop()
+	}
+	if accessLog != nil {
+		errs = errors.Join(errs, accessLog.Close())
 	}
 
 	if errs != nil {
 		proxy.log.Error().Err(errs).Msg("Error stopping proxy")
 	}
 
-	proxy.log.Info().Str("name", proxy.Config.Hostname).Msg("proxy stopped")
+	proxy.log.Info().Str("name", proxy.Config().Hostname).Msg("proxy stopped")
+}
+
+// setPortError method stores a port error and notifies the subscribers.
+func (proxy *Proxy) setPortError(name string, err error) {
+	proxy.mtx.Lock()
+	proxy.portErrors[name] = err.Error()
+	status := proxy.status
+	proxy.mtx.Unlock()
+
+	if proxy.onUpdate != nil {
+		proxy.onUpdate(model.ProxyEvent{
+			ID:     proxy.Config().Hostname,
+			Status: status,
+		})
+	}
+}
+
+// setProtocol method stores the protocol detected in a tcp port and notifies
+// the subscribers when it changes.
+func (proxy *Proxy) setProtocol(name, protocol string) {
+	label := protocol
+	if label == "" {
+		label = "unknown"
+	}
+	detectedConnections.Inc(proxy.Config().Hostname, name, label)
+
+	if protocol == "" {
+		return
+	}
+
+	proxy.mtx.Lock()
+	changed := proxy.protocols[name] != protocol
+	proxy.protocols[name] = protocol
+	status := proxy.status
+	proxy.mtx.Unlock()
+
+	if changed && proxy.onUpdate != nil {
+		proxy.onUpdate(model.ProxyEvent{
+			ID:     proxy.Config().Hostname,
+			Status: status,
+		})
+	}
+}
+
+// healthChanged method notifies the subscribers when a target of a port
+// goes down or up.
+func (proxy *Proxy) healthChanged() {
+	proxy.mtx.RLock()
+	status := proxy.status
+	proxy.mtx.RUnlock()
+
+	if proxy.onUpdate != nil {
+		proxy.onUpdate(model.ProxyEvent{
+			ID:     proxy.Config().Hostname,
+			Status: status,
+		})
+	}
 }
 
 func (proxy *Proxy) setStatus(status model.ProxyStatus) {
@@ -222,11 +771,16 @@ func (proxy *Proxy) setStatus(status model.ProxyStatus) {
 	}
 
 	proxy.status = status
+	proxy.statusChanged = time.Now()
+	proxy.statusHistory = append(proxy.statusHistory, model.StatusChange{Time: proxy.statusChanged, Status: status})
+	if len(proxy.statusHistory) > statusHistorySize {
+		proxy.statusHistory = proxy.statusHistory[len(proxy.statusHistory)-statusHistorySize:]
+	}
 	proxy.mtx.Unlock()
 
 	if proxy.onUpdate != nil {
 		proxy.onUpdate(model.ProxyEvent{
-			ID:     proxy.Config.Hostname,
+			ID:     proxy.Config().Hostname,
 			Status: status,
 		})
 	}
@@ -6,16 +6,31 @@ package proxymanager
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
 
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/auth"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/cachepurge"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/history"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metadata"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/notify"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders/ngrok"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders/sshtunnel"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders/tailscale"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders/docker"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders/hostscan"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders/list"
 )
 
@@ -23,6 +38,8 @@ type (
 	ProxyList          map[string]*Proxy
 	TargetProviderList map[string]targetproviders.TargetProvider
 	ProxyProviderList  map[string]proxyproviders.Provider
+	OIDCProviderList   map[string]*auth.OIDC
+	ACLGroupsList      map[string]*auth.ACLGroups
 
 	// ProxyManager struct stores data that is required to manage all proxies
 	ProxyManager struct {
@@ -32,16 +49,57 @@ type (
 
 		TargetProviders TargetProviderList
 		ProxyProviders  ProxyProviderList
+		OIDCProviders   OIDCProviderList
+		ACLGroups       ACLGroupsList
+
+		metadata *metadata.Fetcher
 
 		statusSubscribers map[chan model.ProxyEvent]struct{}
 
+		disabled     *disabledState
+		disabledFile *config.ConfigFile
+
+		banners *bannerStore
+
+		pages *pageStore
+
+		budget *requestBudget
+
+		history *history.Store
+
+		notifier *notify.Notifier
+
+		problems *problems.Registry
+
+		purger *cachepurge.Purger
+
+		startup *startupQueue
+
+		// saved are the last known proxies, restored on start
+		saved *savedProxies
+		// purgeStarted are the proxies started at least once, only restarts
+		// purge the cache
+		purgeStarted map[string]struct{}
+
+		// certs are the certificates of the proxies of the last expiry check
+		certs map[string]certState
+
+		// disconnected are the target providers that lost the connection to
+		// their source, with the error
+		disconnected map[string]string
+
 		mtx sync.RWMutex
+		// hostnameMtx serializes the hostname check and the addition of the
+		// new proxies, so hostname conflicts are resolved in order
+		hostnameMtx sync.Mutex
 	}
 )
 
 var (
 	ErrProxyProviderNotFound  = errors.New("proxyProvider not found")
 	ErrTargetProviderNotFound = errors.New("targetProvider not found")
+	ErrProxyNotFound          = errors.New("proxy not found")
+	ErrReloadUnsupported      = errors.New("targetProvider can't be reloaded")
 )
 
 // NewProxyManager function creates a new ProxyManager.
@@ -50,18 +108,38 @@ func NewProxyManager(logger zerolog.Logger) *ProxyManager {
 		Proxies:           make(ProxyList),
 		TargetProviders:   make(TargetProviderList),
 		ProxyProviders:    make(ProxyProviderList),
+		OIDCProviders:     make(OIDCProviderList),
+		ACLGroups:         make(ACLGroupsList),
+		metadata:          metadata.New(logger),
 		statusSubscribers: make(map[chan model.ProxyEvent]struct{}),
-		log:               logger.With().Str("module", "proxymanager").Logger(),
+		purgeStarted:      make(map[string]struct{}),
+		certs:             make(map[string]certState),
+		disconnected:      make(map[string]string),
+		banners:           &bannerStore{},
+		pages:             &pageStore{},
+		problems:          problems.New(),
+		log:               core.ModuleLogger(logger, "proxymanager"),
 	}
 
+	pm.startup = newStartupQueue(pm.log, config.Config.Startup.Concurrency, config.Config.Startup.Jitter)
+
 	return pm
 }
 
 // Start method starts the ProxyManager.
 func (pm *ProxyManager) Start() {
+	pm.loadDisabled()
+	pm.loadBanners()
+	pm.loadPages()
+	pm.budget = newRequestBudget(pm.log)
+	pm.resetCaches()
+	pm.openHistory()
+
 	// Add Providers
 	pm.addProxyProviders()
 	pm.addTargetProviders()
+	pm.addOIDCProviders()
+	pm.addACLGroups()
 
 	// Do not start without providers
 	if len(pm.ProxyProviders) == 0 {
@@ -73,10 +151,17 @@ func (pm *ProxyManager) Start() {
 		pm.log.Error().Msg("No Target Providers found")
 		return
 	}
+
+	pm.restoreProxies()
+
+	go pm.watchCertificates()
+	go pm.watchStats()
 }
 
-// StopAllProxies method shuts down all proxies.
-func (pm *ProxyManager) StopAllProxies() {
+// StopAllProxies method shuts down all proxies and closes the status
+// history. The proxies drain their active requests until the context is
+// done, an error is returned if they didn't stop in time.
+func (pm *ProxyManager) StopAllProxies(ctx context.Context) error {
 	pm.log.Info().Msg("Shutdown all proxies")
 	wg := sync.WaitGroup{}
 
@@ -90,13 +175,27 @@ func (pm *ProxyManager) StopAllProxies() {
 	}
 	pm.mtx.RUnlock()
 
-	wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("stopping proxies: %w", ctx.Err())
+	}
+
+	pm.closeHistory()
+
+	return nil
 }
 
 // WatchEvents method watches for events from all target providers.
 func (pm *ProxyManager) WatchEvents() {
-	for _, provider := range pm.TargetProviders {
-		go func(provider targetproviders.TargetProvider) {
+	for name, provider := range pm.TargetProviders {
+		go func(name string, provider targetproviders.TargetProvider) {
 			ctx := context.Background()
 
 			eventsChan := make(chan targetproviders.TargetEvent)
@@ -108,16 +207,89 @@ func (pm *ProxyManager) WatchEvents() {
 			for {
 				select {
 				case event := <-eventsChan:
+					// the connection events are handled in order
+					if event.Action == targetproviders.ActionDisconnected ||
+						event.Action == targetproviders.ActionConnected {
+						pm.providerConnection(name, event)
+						continue
+					}
 					go pm.HandleProxyEvent(event)
 				case err := <-errChan:
 					pm.log.Err(err).Msg("Error watching events")
+					pm.problems.Report(problems.Problem{
+						Source:  problems.SourceProvider,
+						Subject: name,
+						Message: "watching events: " + err.Error(),
+						Fix:     fixProviderWatch,
+					})
+					pm.notify(notify.Event{
+						Type:     notify.EventProviderDisconnected,
+						Provider: name,
+						Message:  err.Error(),
+					})
 					return
 				}
 			}
-		}(provider)
+		}(name, provider)
 	}
 }
 
+// providerConnection method reports a target provider that lost the
+// connection to its source, and resolves it when it's connected again.
+func (pm *ProxyManager) providerConnection(name string, event targetproviders.TargetEvent) {
+	if event.Action == targetproviders.ActionConnected {
+		pm.mtx.Lock()
+		_, ok := pm.disconnected[name]
+		delete(pm.disconnected, name)
+		pm.mtx.Unlock()
+
+		if !ok {
+			return
+		}
+
+		pm.log.Info().Str("provider", name).Msg("Target provider reconnected")
+		pm.problems.Resolve(problems.SourceProvider, name)
+		pm.notify(notify.Event{
+			Type:     notify.EventProviderReconnected,
+			Provider: name,
+			Message:  "Target provider reconnected",
+		})
+
+		return
+	}
+
+	msg := "disconnected"
+	if event.Err != nil {
+		msg = event.Err.Error()
+	}
+
+	pm.mtx.Lock()
+	pm.disconnected[name] = msg
+	pm.mtx.Unlock()
+
+	pm.log.Error().Err(event.Err).Str("provider", name).Msg("Target provider disconnected")
+	pm.problems.Report(problems.Problem{
+		Source:  problems.SourceProvider,
+		Subject: name,
+		Message: "watching events: " + msg,
+		Fix:     fixProviderReconnect,
+	})
+	pm.notify(notify.Event{
+		Type:     notify.EventProviderDisconnected,
+		Provider: name,
+		Message:  msg,
+	})
+}
+
+// GetDisconnectedTargetProviders method returns the target providers that
+// lost the connection to their source, with the error.
+func (pm *ProxyManager) GetDisconnectedTargetProviders() map[string]string {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	return maps.Clone(pm.disconnected)
+}
+
 // HandleProxyEvent method handles events from a targetprovider
 func (pm *ProxyManager) HandleProxyEvent(event targetproviders.TargetEvent) {
 	switch event.Action {
@@ -126,8 +298,7 @@ func (pm *ProxyManager) HandleProxyEvent(event targetproviders.TargetEvent) {
 	case targetproviders.ActionStopProxy:
 		pm.eventStop(event)
 	case targetproviders.ActionRestartProxy:
-		pm.eventStop(event)
-		pm.eventStart(event)
+		pm.eventRestart(event)
 	}
 }
 
@@ -144,18 +315,24 @@ func (pm *ProxyManager) SubscribeStatusEvents() <-chan model.ProxyEvent {
 }
 
 // UnsubscribeStatusEvents remove the channel subscrived in SubscribeStatusEvents
-func (pm *ProxyManager) UnsubscribeStatusEvents(ch chan model.ProxyEvent) {
+func (pm *ProxyManager) UnsubscribeStatusEvents(ch <-chan model.ProxyEvent) {
 	pm.mtx.Lock()
-	delete(pm.statusSubscribers, ch)
-	pm.mtx.Unlock()
-	close(ch)
+	defer pm.mtx.Unlock()
+
+	for c := range pm.statusSubscribers {
+		if c == ch {
+			delete(pm.statusSubscribers, c)
+			close(c)
+		}
+	}
 }
 
+// GetProxies method returns a copy of the proxy list.
 func (pm *ProxyManager) GetProxies() ProxyList {
 	pm.mtx.RLock()
 	defer pm.mtx.RUnlock()
 
-	return pm.Proxies
+	return maps.Clone(pm.Proxies)
 }
 
 func (pm *ProxyManager) GetProxy(name string) (*Proxy, bool) {
@@ -167,6 +344,198 @@ func (pm *ProxyManager) GetProxy(name string) (*Proxy, bool) {
 	return proxy, ok
 }
 
+// StopProxy method stops a proxy. The proxy is kept in the ProxyManager
+// with the stopped status, so it can be started again with RestartProxy.
+func (pm *ProxyManager) StopProxy(name string) error {
+	proxy, ok := pm.GetProxy(name)
+	if !ok {
+		return ErrProxyNotFound
+	}
+
+	pm.log.Info().Str("proxy", name).Msg("Stopping proxy")
+
+	proxy.Close()
+
+	return nil
+}
+
+// RestartProxy method stops a proxy and starts a new one with the same
+// configuration. Used to recover stopped or failed proxies. Disabled proxies
+// return ErrProxyDisabled, use EnableProxy to start them.
+func (pm *ProxyManager) RestartProxy(name string) error {
+	proxy, ok := pm.GetProxy(name)
+	if !ok {
+		return ErrProxyNotFound
+	}
+
+	if pm.IsDisabled(name) {
+		return ErrProxyDisabled
+	}
+
+	pm.log.Info().Str("proxy", name).Msg("Restarting proxy")
+
+	// the configuration of a proxy isn't modified, copy it
+	pcfg := *proxy.Config()
+
+	// rejected proxies try their hostname again
+	if proxy.conflict != "" {
+		pcfg.Hostname = proxy.conflict
+	}
+
+	pm.removeProxy(name)
+	p := pm.newAndStartProxy(pcfg.Hostname, &pcfg)
+
+	// restored proxies are still replaced by their target provider
+	if p != nil && proxy.restored.Load() {
+		p.restored.Store(true)
+	}
+
+	return nil
+}
+
+// GetDiscovered method returns the services proposed by all Discoverer target providers.
+func (pm *ProxyManager) GetDiscovered() []model.DiscoveredService {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	services := []model.DiscoveredService{}
+	for _, provider := range pm.TargetProviders {
+		if d, ok := provider.(targetproviders.Discoverer); ok {
+			services = append(services, d.GetDiscovered()...)
+		}
+	}
+
+	return services
+}
+
+// GetWarnings method returns the warnings of all Warner proxy providers.
+func (pm *ProxyManager) GetWarnings() []string {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	warnings := []string{}
+	for _, provider := range pm.ProxyProviders {
+		if w, ok := provider.(proxyproviders.Warner); ok {
+			warnings = append(warnings, w.Warnings()...)
+		}
+	}
+	slices.Sort(warnings)
+
+	return warnings
+}
+
+// GetStateDirs method returns the state directories of the StateReporter
+// proxy providers, by provider name.
+func (pm *ProxyManager) GetStateDirs() (map[string][]model.StateDir, error) {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	dirs := make(map[string][]model.StateDir)
+	for name, provider := range pm.ProxyProviders {
+		r, ok := provider.(proxyproviders.StateReporter)
+		if !ok {
+			continue
+		}
+
+		d, err := r.StateDirs()
+		if err != nil {
+			return nil, fmt.Errorf("proxy provider %s: %w", name, err)
+		}
+		if d != nil {
+			dirs[name] = d
+		}
+	}
+
+	return dirs, nil
+}
+
+// ApproveDiscovered method approves a discovered service in its target provider.
+func (pm *ProxyManager) ApproveDiscovered(providerName, id string) error {
+	pm.mtx.RLock()
+	provider, ok := pm.TargetProviders[providerName]
+	pm.mtx.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTargetProviderNotFound, providerName)
+	}
+
+	d, ok := provider.(targetproviders.Discoverer)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTargetProviderNotFound, providerName)
+	}
+
+	return d.Approve(id)
+}
+
+// GetEditors method returns the names of the Editor target providers, sorted.
+func (pm *ProxyManager) GetEditors() []string {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	names := []string{}
+	for name, provider := range pm.TargetProviders {
+		if e, ok := provider.(targetproviders.Editor); ok && e.Editable() {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// GetEditor method returns an Editor target provider.
+func (pm *ProxyManager) GetEditor(providerName string) (targetproviders.Editor, error) {
+	pm.mtx.RLock()
+	provider, ok := pm.TargetProviders[providerName]
+	pm.mtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTargetProviderNotFound, providerName)
+	}
+
+	e, ok := provider.(targetproviders.Editor)
+	if !ok || !e.Editable() {
+		return nil, fmt.Errorf("%w: %s", ErrTargetProviderNotFound, providerName)
+	}
+
+	return e, nil
+}
+
+// ReloadTargetProvider method reads the targets of a target provider again.
+// Changed proxies are reloaded by the events sent by the provider.
+func (pm *ProxyManager) ReloadTargetProvider(ctx context.Context, providerName string) error {
+	pm.mtx.RLock()
+	provider, ok := pm.TargetProviders[providerName]
+	pm.mtx.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTargetProviderNotFound, providerName)
+	}
+
+	r, ok := provider.(targetproviders.Reloader)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrReloadUnsupported, providerName)
+	}
+
+	pm.log.Info().Str("provider", providerName).Msg("Reloading target provider")
+
+	return r.Reload(ctx)
+}
+
+// GetTargetProviders method returns the names of the target providers sorted,
+// and if each one can be reloaded.
+func (pm *ProxyManager) GetTargetProviders() ([]string, map[string]bool) {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	reloadable := make(map[string]bool, len(pm.TargetProviders))
+	for name, provider := range pm.TargetProviders {
+		_, reloadable[name] = provider.(targetproviders.Reloader)
+	}
+
+	return slices.Sorted(maps.Keys(reloadable)), reloadable
+}
+
 // broadcastStatusEvents broadcasts proxy status event to all SubscribeStatusEvents
 func (pm *ProxyManager) broadcastStatusEvents(event model.ProxyEvent) {
 	pm.mtx.RLock()
@@ -185,6 +554,7 @@ func (pm *ProxyManager) addTargetProviders() {
 		p, err := docker.New(pm.log, name, provider)
 		if err != nil {
 			pm.log.Error().Err(err).Msg("Error creating Docker provider")
+			pm.reportProviderError(name, err)
 			continue
 		}
 
@@ -194,6 +564,27 @@ func (pm *ProxyManager) addTargetProviders() {
 		p, err := list.New(pm.log, name, file)
 		if err != nil {
 			pm.log.Error().Err(err).Msg("Error creating Files provider")
+			pm.reportProviderError(name, err)
+			continue
+		}
+
+		pm.addTargetProvider(p, name)
+	}
+	for name, provider := range config.Config.HostScan {
+		p, err := hostscan.New(pm.log, name, provider)
+		if err != nil {
+			pm.log.Error().Err(err).Msg("Error creating Host Scan provider")
+			pm.reportProviderError(name, err)
+			continue
+		}
+
+		pm.addTargetProvider(p, name)
+	}
+	for name, provider := range config.Config.Replay {
+		p, err := docker.NewReplay(pm.log, name, provider)
+		if err != nil {
+			pm.log.Error().Err(err).Msg("Error creating Replay provider")
+			pm.reportProviderError(name, err)
 			continue
 		}
 
@@ -208,11 +599,67 @@ func (pm *ProxyManager) addProxyProviders() {
 	for name, provider := range config.Config.Tailscale.Providers {
 		if p, err := tailscale.New(pm.log, name, provider); err != nil {
 			pm.log.Error().Err(err).Msg("Error creating Tailscale provider")
+			pm.reportProviderError(name, err)
+		} else {
+			pm.log.Debug().Str("provider", name).Msg("Created Proxy provider")
+			pm.addProxyProvider(p, name)
+			pm.reportProviderWarnings(name, p)
+		}
+	}
+
+	for name, provider := range config.Config.SSHTunnels {
+		if p, err := sshtunnel.New(pm.log, name, provider); err != nil {
+			pm.log.Error().Err(err).Msg("Error creating SSH tunnel provider")
+			pm.reportProviderError(name, err)
 		} else {
 			pm.log.Debug().Str("provider", name).Msg("Created Proxy provider")
 			pm.addProxyProvider(p, name)
 		}
 	}
+
+	for name, provider := range config.Config.Ngrok {
+		if p, err := ngrok.New(pm.log, name, provider); err != nil {
+			pm.log.Error().Err(err).Msg("Error creating ngrok provider")
+			pm.reportProviderError(name, err)
+		} else {
+			pm.log.Debug().Str("provider", name).Msg("Created Proxy provider")
+			pm.addProxyProvider(p, name)
+		}
+	}
+}
+
+// addOIDCProviders method adds OIDC authentication providers from configuration file.
+func (pm *ProxyManager) addOIDCProviders() {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	for name, cfg := range config.Config.OIDC {
+		pm.OIDCProviders[name] = auth.NewOIDC(pm.log, name, cfg)
+	}
+}
+
+// addACLGroups method starts the tailnet ACL groups sync of Tailscale providers with OAuth.
+func (pm *ProxyManager) addACLGroups() {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	for name, provider := range config.Config.Tailscale.Providers {
+		if provider.ClientID == "" || provider.ClientSecret == "" {
+			continue
+		}
+
+		groups := auth.NewACLGroups(pm.log, name, provider.ClientID, provider.ClientSecret, provider.GroupsRefreshInterval)
+		groups.Start(context.Background())
+
+		pm.ACLGroups[name] = groups
+	}
+}
+
+// AddTargetProvider method adds a TargetProvider that isn't in the
+// configuration file, like the proxy of the run command. It must be called
+// before WatchEvents.
+func (pm *ProxyManager) AddTargetProvider(provider targetproviders.TargetProvider, name string) {
+	pm.addTargetProvider(provider, name)
 }
 
 // addTargetProvider method adds a TargetProvider to the ProxyManager.
@@ -236,28 +683,54 @@ func (pm *ProxyManager) addProxy(proxy *Proxy) {
 	pm.mtx.Lock()
 	defer pm.mtx.Unlock()
 
-	pm.Proxies[proxy.Config.Hostname] = proxy
+	pm.Proxies[proxy.Config().Hostname] = proxy
 }
 
 // removeProxy method removes a Proxy from the ProxyManager.
+// The proxy is removed before closing, so subscribers of the stopped event
+// know it was removed.
 func (pm *ProxyManager) removeProxy(hostname string) {
+	pm.mtx.Lock()
 	proxy, exists := pm.Proxies[hostname]
+	delete(pm.Proxies, hostname)
+	pm.mtx.Unlock()
+
 	if !exists {
 		return
 	}
 
 	proxy.Close()
+	pm.problems.Resolve(problems.SourceProxy, hostname)
 
-	pm.mtx.Lock()
-	defer pm.mtx.Unlock()
+	pm.log.Debug().Str("proxy", hostname).Msg("Removed proxy")
+}
 
+// deleteProxy method removes a Proxy from the ProxyManager like removeProxy,
+// and removes its node from the network.
+func (pm *ProxyManager) deleteProxy(hostname string) {
+	pm.mtx.Lock()
+	proxy, exists := pm.Proxies[hostname]
 	delete(pm.Proxies, hostname)
+	pm.mtx.Unlock()
 
-	pm.log.Debug().Str("proxy", hostname).Msg("Removed proxy")
+	if !exists {
+		return
+	}
+
+	proxy.Remove()
+	pm.problems.Resolve(problems.SourceProxy, hostname)
+
+	pm.log.Debug().Str("proxy", hostname).Msg("Deleted proxy")
 }
 
 // eventStart method starts a Proxy from a event trigger
 func (pm *ProxyManager) eventStart(event targetproviders.TargetEvent) {
+	// the restored proxy of the target is reloaded with its configuration
+	if pm.claimRestored(event) {
+		pm.eventRestart(event)
+		return
+	}
+
 	pm.log.Debug().Str("targetID", event.ID).Msg("Adding target")
 
 	pcfg, err := event.TargetProvider.AddTarget(event.ID)
@@ -266,11 +739,20 @@ func (pm *ProxyManager) eventStart(event targetproviders.TargetEvent) {
 		return
 	}
 
-	pm.newAndStartProxy(pcfg.Hostname, pcfg)
+	if p := pm.newAndStartProxy(pcfg.Hostname, pcfg); p != nil && p.conflict == "" {
+		pm.saveProxy(p.Config())
+	}
 }
 
-// eventStop method stops a Proxy from a event trigger
+// eventStop method stops a Proxy from a event trigger. The target was
+// deleted, so the node of the proxy is removed.
 func (pm *ProxyManager) eventStop(event targetproviders.TargetEvent) {
+	pm.stopTarget(event, true)
+}
+
+// stopTarget method stops the Proxy of a target, removing its node with
+// remove.
+func (pm *ProxyManager) stopTarget(event targetproviders.TargetEvent, remove bool) {
 	pm.log.Debug().Str("targetID", event.ID).Msg("Stopping target")
 
 	proxy := pm.getProxyByTargetID(event.ID)
@@ -279,13 +761,58 @@ func (pm *ProxyManager) eventStop(event targetproviders.TargetEvent) {
 		return
 	}
 
-	targetprovider := pm.TargetProviders[proxy.Config.TargetProvider]
+	targetprovider := pm.TargetProviders[proxy.Config().TargetProvider]
 	if err := targetprovider.DeleteProxy(event.ID); err != nil {
 		pm.log.Error().Err(err).Msg("No proxy found for target")
 		return
 	}
 
-	pm.removeProxy(proxy.Config.Hostname)
+	if remove {
+		pm.deleteProxy(proxy.Config().Hostname)
+		pm.forgetProxy(proxy.Config())
+		go pm.retryConflicts(proxy.Config().Hostname)
+	} else {
+		pm.removeProxy(proxy.Config().Hostname)
+	}
+}
+
+// eventRestart method reloads a Proxy from a event trigger. If the proxy can't be
+// reloaded in place, it's stopped and started again.
+func (pm *ProxyManager) eventRestart(event targetproviders.TargetEvent) {
+	pm.log.Debug().Str("targetID", event.ID).Msg("Restarting target")
+
+	proxy := pm.getProxyByTargetID(event.ID)
+	if proxy == nil {
+		pm.eventStart(event)
+		return
+	}
+
+	pcfg, err := event.TargetProvider.AddTarget(event.ID)
+	if err != nil {
+		pm.log.Error().Err(err).Str("targetID", event.ID).Msg("Error adding target")
+		return
+	}
+
+	if name, _, err := pm.getProxyProvider(pcfg); err == nil {
+		pcfg.ProxyProvider = name
+	}
+
+	err = proxy.Reload(pcfg)
+	if err == nil {
+		pm.broadcastStatusEvents(model.ProxyEvent{
+			ID:     proxy.Config().Hostname,
+			Status: proxy.GetStatus(),
+		})
+		pm.purgeCache(proxy)
+		pm.saveProxy(proxy.Config())
+		return
+	}
+
+	pm.log.Info().Err(err).Str("targetID", event.ID).Msg("Restarting proxy")
+
+	// the new proxy uses the same node
+	pm.stopTarget(event, false)
+	pm.eventStart(event)
 }
 
 // getProxyByTargetID method returns a Proxy by TargetID.
@@ -294,73 +821,170 @@ func (pm *ProxyManager) getProxyByTargetID(targetID string) *Proxy {
 	defer pm.mtx.RUnlock()
 
 	for _, p := range pm.Proxies {
-		if p.Config.TargetID == targetID {
+		if p.Config().TargetID == targetID {
 			return p
 		}
 	}
 	return nil
 }
 
-// newAndStartProxy method creates a new proxy and starts it.
-func (pm *ProxyManager) newAndStartProxy(name string, proxyConfig *model.Config) {
+// newAndStartProxy method creates a new proxy and starts it, nil if it
+// can't be created.
+func (pm *ProxyManager) newAndStartProxy(name string, proxyConfig *model.Config) *Proxy {
 	pm.log.Debug().Str("proxy", name).Msg("Creating proxy")
 
-	proxyProvider, err := pm.getProxyProvider(proxyConfig)
+	proxyProviderName, proxyProvider, err := pm.getProxyProvider(proxyConfig)
 	if err != nil {
-		pm.log.Error().Err(err).Msg("Error to get ProxyProvider")
-		return
+		pm.log.Error().Err(err).Str("proxy", name).Msg("Error to get ProxyProvider")
+		pm.problems.Report(problems.Problem{
+			Source:  problems.SourceProxy,
+			Subject: name,
+			Message: err.Error(),
+			Fix:     fixProxyProvider,
+		})
+		return nil
 	}
 
-	p, err := NewProxy(pm.log, proxyConfig, proxyProvider)
+	// store the resolved ProxyProvider to be shown in dashboard
+	proxyConfig.ProxyProvider = proxyProviderName
+
+	pm.mtx.RLock()
+	aclGroups := pm.ACLGroups[proxyProviderName]
+	pm.mtx.RUnlock()
+
+	pm.hostnameMtx.Lock()
+
+	// proxies of other targets with the same hostname are suffixed
+	hostname, owner := pm.resolveHostname(proxyConfig)
+	conflict := ""
+	if owner != nil {
+		pm.hostnameConflict(proxyConfig, owner, hostname)
+		proxyConfig.Provenance.Set("hostname", "suffixed, "+proxyConfig.Hostname+" is used by "+targetName(owner.Config()))
+		if config.Config.HostnameConflicts != ConflictSuffix {
+			conflict = proxyConfig.Hostname
+		}
+		proxyConfig.Hostname = hostname
+	}
+
+	p, err := NewProxy(pm.log, proxyConfig, proxyProvider, pm.OIDCProviders, aclGroups, pm.banners, pm.pages, pm.budget)
 	if err != nil {
+		pm.hostnameMtx.Unlock()
 		pm.log.Error().Err(err).Msg("Error creating proxy")
-		return
+		pm.problems.Report(problems.Problem{
+			Source:  problems.SourceProxy,
+			Subject: name,
+			Message: err.Error(),
+			Fix:     fixProxyError,
+		})
+		return nil
 	}
 
 	// any status change in proxy will be broadcasted
+	var (
+		lastStatus atomic.Int32
+		running    atomic.Bool
+	)
 	p.onUpdate = func(event model.ProxyEvent) {
+		pm.recordStatus(event.ID, event.Status)
+		pm.proxySettled(p, event.Status)
 		pm.broadcastStatusEvents(event)
+		pm.reportStatus(p, event.Status)
+
+		// updates without a status change, like port errors, aren't notified
+		if model.ProxyStatus(lastStatus.Swap(int32(event.Status))) != event.Status { //nolint:gosec
+			pm.notifyStatus(p, event.Status)
+			pm.runHooks(p, event.Status)
+		}
+
+		if event.Status == model.ProxyStatusRunning {
+			go pm.enrichProxy(p)
+
+			if !running.Swap(true) {
+				pm.purgeCache(p)
+			}
+		}
+	}
+
+	p.onRecover = func(dir string) {
+		pm.notifyRecovered(p, dir)
 	}
+	p.conflict = conflict
 
 	pm.addProxy(p)
+	pm.hostnameMtx.Unlock()
 
 	// broadcasts ProxyStatusInitializing
+	pm.recordStatus(p.Config().Hostname, model.ProxyStatusInitializing)
 	pm.broadcastStatusEvents(model.ProxyEvent{
-		ID:     p.Config.Hostname,
+		ID:     p.Config().Hostname,
 		Status: model.ProxyStatusInitializing,
 	})
 
-	p.Start()
+	if pm.IsDisabled(name) {
+		pm.log.Info().Str("proxy", name).Msg("Proxy is disabled, not starting")
+		p.Close()
+		return p
+	}
+
+	if conflict != "" {
+		p.setStatus(model.ProxyStatusConflict)
+		return p
+	}
+
+	pm.startProxy(p)
+
+	return p
 }
 
-// getProxyProvider method returns a ProxyProvider.
-func (pm *ProxyManager) getProxyProvider(proxy *model.Config) (proxyproviders.Provider, error) {
+// getProxyProvider method returns a ProxyProvider and its name. The default
+// used by proxies without a ProxyProvider is recorded in their provenance.
+func (pm *ProxyManager) getProxyProvider(proxy *model.Config) (string, proxyproviders.Provider, error) {
 	// return ProxyProvider defined in configurtion
 	//
 	if proxy.ProxyProvider != "" {
-		p, ok := pm.ProxyProviders[proxy.ProxyProvider]
+		name, p, ok := pm.lookupProxyProvider(proxy.ProxyProvider)
 		if !ok {
-			return nil, ErrProxyProviderNotFound
+			return "", nil, fmt.Errorf("%w: %s", ErrProxyProviderNotFound, proxy.ProxyProvider)
 		}
-		return p, nil
+		return name, p, nil
 	}
 
 	// return default ProxyProvider defined in TargetProvider
 	targetProvider, ok := pm.TargetProviders[proxy.TargetProvider]
 	if !ok {
-		return nil, ErrTargetProviderNotFound
+		return "", nil, ErrTargetProviderNotFound
 	}
-	if p, ok := pm.ProxyProviders[targetProvider.GetDefaultProxyProviderName()]; ok {
-		return p, nil
+	if name, p, ok := pm.lookupProxyProvider(targetProvider.GetDefaultProxyProviderName()); ok {
+		proxy.Provenance.Set("proxyProvider", "defaultProxyProvider of target provider "+proxy.TargetProvider)
+		return name, p, nil
 	}
 
 	// return default ProxyProvider from global configurtion
 	//
-	if p, ok := pm.ProxyProviders[config.Config.DefaultProxyProvider]; ok {
-		return p, nil
+	if name, p, ok := pm.lookupProxyProvider(config.Config.DefaultProxyProvider); ok {
+		proxy.Provenance.Set("proxyProvider", "global defaultProxyProvider")
+		return name, p, nil
 	}
 
 	// return the first ProxyProvider
 	//
-	return nil, ErrProxyProviderNotFound
+	return "", nil, ErrProxyProviderNotFound
+}
+
+// lookupProxyProvider method finds a ProxyProvider by name, ignoring case
+// like the configuration validation.
+func (pm *ProxyManager) lookupProxyProvider(name string) (string, proxyproviders.Provider, bool) {
+	if name == "" {
+		return "", nil, false
+	}
+
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	for n, p := range pm.ProxyProviders {
+		if strings.EqualFold(n, name) {
+			return n, p, true
+		}
+	}
+	return "", nil, false
 }
@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/errorpage"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	defaultReadinessInterval = 2 * time.Second
+	// readinessTimeout is the maximum time of a probe of a target.
+	readinessTimeout = 5 * time.Second
+	// readinessLogInterval is the interval between the logs of the failed
+	// probes of a proxy.
+	readinessLogInterval = time.Minute
+
+	msgStarting = "This service is starting, try again in a few seconds."
+)
+
+var errNotReady = errors.New("target not ready")
+
+// setProviderStatus method sets the status reported by the proxy provider.
+// With readiness, a Running proxy stays Starting until its targets pass the
+// readiness probe.
+func (proxy *Proxy) setProviderStatus(status model.ProxyStatus) {
+	proxy.mtx.Lock()
+	proxy.providerStatus = status
+	gated := status == model.ProxyStatusRunning && !proxy.ready
+	proxy.mtx.Unlock()
+
+	if !gated {
+		proxy.setStatus(status)
+		return
+	}
+
+	// the status is set before probing, so a fast probe isn't overwritten
+	proxy.setStatus(model.ProxyStatusStarting)
+	proxy.readinessOnce.Do(func() {
+		go proxy.waitReady()
+	})
+}
+
+// waitReady method probes the targets until they are ready or the proxy is
+// closed, and marks the proxy Running.
+func (proxy *Proxy) waitReady() {
+	interval := proxy.readinessInterval()
+	proxy.log.Info().Msg("Waiting for the targets to be ready")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastLog time.Time
+	for {
+		err := proxy.probeReadiness(proxy.ctx)
+		if err == nil {
+			break
+		}
+		if time.Since(lastLog) >= readinessLogInterval {
+			proxy.log.Info().Err(err).Msg("Targets not ready yet")
+			lastLog = time.Now()
+		}
+
+		select {
+		case <-proxy.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	proxy.mtx.Lock()
+	proxy.ready = true
+	running := proxy.providerStatus == model.ProxyStatusRunning && proxy.status == model.ProxyStatusStarting
+	proxy.mtx.Unlock()
+
+	proxy.log.Info().Msg("Targets ready")
+
+	if running {
+		proxy.setStatus(model.ProxyStatusRunning)
+	}
+}
+
+// probeReadiness method returns nil if a target of each port passes the
+// readiness probe. Redirects and static ports have no targets to probe.
+func (proxy *Proxy) probeReadiness(ctx context.Context) error {
+	proxy.mtx.RLock()
+	ports := proxy.Config().Ports
+	path := proxy.Config().Readiness.Path
+	proxy.mtx.RUnlock()
+
+	for name, pconfig := range ports {
+		if pconfig.IsRedirect || pconfig.IsStatic() {
+			continue
+		}
+
+		tlsConfig, err := upstreamTLSConfig(pconfig)
+		if err != nil {
+			return fmt.Errorf("port %s: %w", name, err)
+		}
+
+		for _, u := range pconfig.GetTargets() {
+			if err = probeTarget(ctx, u, path, tlsConfig); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("port %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// probeTarget function requests the path in http and https targets, and
+// connects to the other targets.
+func probeTarget(ctx context.Context, u *url.URL, path string, tlsConfig *tls.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	if path == "" || u.Scheme != "http" && u.Scheme != "https" {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, targetNetwork(u), targetAddress(u))
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	probe := *u
+	probe.Path, probe.RawPath, probe.RawQuery = path, "", ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxExpectRead))
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s", errNotReady, resp.Status)
+	}
+
+	return nil
+}
+
+// readinessMiddleware method serves a 503 with Retry-After until the targets
+// of the proxy are ready.
+func (proxy *Proxy) readinessMiddleware(next http.Handler) http.Handler {
+	retryAfter := strconv.Itoa(max(int(proxy.readinessInterval().Seconds()), 1))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if proxy.isReady() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", retryAfter)
+		proxy.pages.write(w, r, errorpage.Data{
+			Proxy:   proxy.Config().Hostname,
+			Status:  http.StatusServiceUnavailable,
+			Message: msgStarting,
+		})
+	})
+}
+
+// isReady method returns true if the targets passed the readiness probe.
+func (proxy *Proxy) isReady() bool {
+	proxy.mtx.RLock()
+	defer proxy.mtx.RUnlock()
+
+	return proxy.ready
+}
+
+// readinessInterval method returns the interval between the readiness
+// probes.
+func (proxy *Proxy) readinessInterval() time.Duration {
+	if i := proxy.Config().Readiness.Interval; i > 0 {
+		return i
+	}
+
+	return defaultReadinessInterval
+}
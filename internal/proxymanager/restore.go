@@ -0,0 +1,239 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+)
+
+// proxiesFile is the file in the data directory that stores the last known
+// configuration of the proxies.
+const proxiesFile = "proxies.yaml"
+
+var ErrSavedProxyConfig = errors.New("saved proxy without configuration")
+
+type (
+	// savedProxies struct stores the configurations of the proxies, by
+	// target, to start them before their target providers list them again.
+	savedProxies struct {
+		file string
+		// Proxies are the saved proxies by target, like docker/myapp
+		Proxies map[string]savedProxy `yaml:"proxies"`
+		mtx     sync.Mutex
+	}
+
+	// savedProxy struct is the configuration of a proxy with the targets of
+	// its ports, they aren't saved with the configuration.
+	savedProxy struct {
+		Config  *model.Config       `yaml:"config"`
+		Targets map[string][]string `yaml:"targets"`
+	}
+)
+
+// restoreProxies method starts the saved proxies of the existing target
+// providers. They are replaced when their target providers start them again,
+// and removed if they don't within the restore timeout.
+func (pm *ProxyManager) restoreProxies() {
+	pm.saved = &savedProxies{
+		file:    filepath.Join(config.Config.Tailscale.DataDir, proxiesFile),
+		Proxies: make(map[string]savedProxy),
+	}
+
+	if !config.Config.Startup.Restore {
+		return
+	}
+
+	if err := pm.saved.load(); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			pm.log.Error().Err(err).Msg("Error loading saved proxies")
+		}
+		return
+	}
+
+	restored := 0
+	for target, sp := range pm.saved.Proxies {
+		pcfg, err := sp.restore()
+		if err != nil {
+			pm.log.Error().Err(err).Str("target", target).Msg("Error restoring saved proxy")
+			continue
+		}
+		if _, ok := pm.TargetProviders[pcfg.TargetProvider]; !ok {
+			continue
+		}
+
+		if p := pm.newAndStartProxy(pcfg.Hostname, pcfg); p != nil {
+			p.restored.Store(true)
+			restored++
+		}
+	}
+
+	if restored == 0 {
+		return
+	}
+
+	pm.log.Info().Int("proxies", restored).Msg("Saved proxies restored, validating them with the target providers")
+
+	time.AfterFunc(config.Config.Startup.RestoreTimeout, pm.expireRestored)
+}
+
+// claimRestored method returns true if the target of the event has a
+// restored proxy, which is now kept as the proxy of the target.
+func (pm *ProxyManager) claimRestored(event targetproviders.TargetEvent) bool {
+	pm.mtx.RLock()
+	defer pm.mtx.RUnlock()
+
+	for _, p := range pm.Proxies {
+		if p.Config().TargetID == event.ID &&
+			pm.TargetProviders[p.Config().TargetProvider] == event.TargetProvider &&
+			p.restored.Swap(false) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expireRestored method removes the restored proxies that their target
+// providers didn't start again. The proxies of disconnected target providers
+// are checked again after the restore timeout.
+func (pm *ProxyManager) expireRestored() {
+	retry := false
+
+	for name, p := range pm.GetProxies() {
+		if !p.restored.Load() {
+			continue
+		}
+
+		pm.mtx.RLock()
+		_, disconnected := pm.disconnected[p.Config().TargetProvider]
+		pm.mtx.RUnlock()
+
+		if disconnected {
+			retry = true
+			continue
+		}
+
+		if !p.restored.Swap(false) {
+			continue
+		}
+
+		pm.log.Info().Str("proxy", name).Msg("Restored proxy not found in its target provider, removing")
+		pm.removeProxy(name)
+		pm.forgetProxy(p.Config())
+	}
+
+	if retry {
+		time.AfterFunc(config.Config.Startup.RestoreTimeout, pm.expireRestored)
+	}
+}
+
+// saveProxy method saves the configuration of a proxy, started before its
+// target provider lists it on the next start.
+func (pm *ProxyManager) saveProxy(pcfg *model.Config) {
+	if pm.saved == nil {
+		return
+	}
+
+	sp := savedProxy{
+		Config:  pcfg,
+		Targets: make(map[string][]string, len(pcfg.Ports)),
+	}
+	for name, port := range pcfg.Ports {
+		for _, t := range port.GetTargets() {
+			sp.Targets[name] = append(sp.Targets[name], t.String())
+		}
+	}
+
+	pm.saved.mtx.Lock()
+	defer pm.saved.mtx.Unlock()
+
+	pm.saved.Proxies[targetName(pcfg)] = sp
+	if err := pm.saved.save(); err != nil {
+		pm.log.Error().Err(err).Msg("Error saving proxies")
+	}
+}
+
+// forgetProxy method deletes the saved configuration of a removed proxy.
+func (pm *ProxyManager) forgetProxy(pcfg *model.Config) {
+	if pm.saved == nil {
+		return
+	}
+
+	pm.saved.mtx.Lock()
+	defer pm.saved.mtx.Unlock()
+
+	if _, ok := pm.saved.Proxies[targetName(pcfg)]; !ok {
+		return
+	}
+
+	delete(pm.saved.Proxies, targetName(pcfg))
+	if err := pm.saved.save(); err != nil {
+		pm.log.Error().Err(err).Msg("Error saving proxies")
+	}
+}
+
+// load method reads the saved proxies, the file is from the running version
+// so unknown fields are an error.
+func (s *savedProxies) load() error {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	return dec.Decode(s)
+}
+
+// save method writes the saved proxies, readable only by the owner as they
+// include auth keys. Must be called with mtx locked.
+func (s *savedProxies) save() error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.file, data, consts.PermOwnerRead+consts.PermOwnerWrite)
+}
+
+// restore method returns the saved configuration with the names and the
+// targets of its ports.
+func (sp savedProxy) restore() (*model.Config, error) {
+	if sp.Config == nil {
+		return nil, ErrSavedProxyConfig
+	}
+
+	pcfg := sp.Config
+	for name, port := range pcfg.Ports {
+		port.SetName(name)
+		for _, t := range sp.Targets[name] {
+			u, err := url.Parse(t)
+			if err != nil {
+				return nil, err
+			}
+			port.AddTarget(u)
+		}
+		pcfg.Ports[name] = port
+	}
+	if pcfg.Provenance == nil {
+		pcfg.Provenance = make(model.Provenance)
+	}
+
+	return pcfg, nil
+}
@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	// scriptDefaultTimeout is the maximum run time of a script request
+	scriptDefaultTimeout = time.Second
+	// scriptDefaultMemory is the maximum memory of a script, in MiB
+	scriptDefaultMemory = 128
+	// scriptDefaultCPU is the maximum CPU time of a script, in seconds
+	scriptDefaultCPU = 1
+	// scriptMaxOutput is the maximum size of the result of a script
+	scriptMaxOutput = 1 << 20
+)
+
+var (
+	ErrScriptCommand      = errors.New("script middleware needs a command")
+	ErrScriptOutputTooBig = errors.New("script output too big")
+	ErrScriptPath         = errors.New("script path must start with /")
+)
+
+type (
+	// script struct runs a command for each request of a port, the command
+	// inspects the request and modifies it or answers it.
+	script struct {
+		log     zerolog.Logger
+		command []string
+		timeout time.Duration
+		// memory is the address space limit of the command, in bytes
+		memory uint64
+		// cpu is the CPU time limit of the command, in seconds
+		cpu uint64
+	}
+
+	// scriptRequest struct is the request sent to the script in its stdin.
+	scriptRequest struct {
+		Headers    http.Header `json:"headers"`
+		Method     string      `json:"method"`
+		URL        string      `json:"url"`
+		Host       string      `json:"host"`
+		RemoteAddr string      `json:"remoteAddr"`
+		Username   string      `json:"username,omitempty"`
+	}
+
+	// scriptResult struct is the result written by the script in its
+	// stdout. Without response the request is sent to the targets with the
+	// headers and path of the result.
+	scriptResult struct {
+		Response *scriptResponse `json:"response,omitempty"`
+		// Headers are set in the request, headers with an empty value are
+		// removed
+		Headers map[string]string `json:"headers,omitempty"`
+		Path    string            `json:"path,omitempty"`
+	}
+
+	// scriptResponse struct is the response of a request answered by the
+	// script.
+	scriptResponse struct {
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    string            `json:"body,omitempty"`
+		Status  int               `json:"status"`
+	}
+
+	// limitedBuffer struct is a buffer that fails when it's full.
+	limitedBuffer struct {
+		bytes.Buffer
+	}
+)
+
+// scriptMiddleware function returns a middleware that runs the command
+// option for each request, with the timeout, memory (MiB) and cpu (seconds)
+// limits of the options. Requests fail when the script fails.
+func scriptMiddleware(mp *MiddlewarePort, options map[string]string) (Middleware, error) {
+	if err := checkOptions(options, "command", "timeout", "memory", "cpu"); err != nil {
+		return nil, err
+	}
+
+	command := strings.Fields(options["command"])
+	if len(command) == 0 {
+		return nil, ErrScriptCommand
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return nil, fmt.Errorf("script command: %w", err)
+	}
+
+	s := &script{
+		log:     mp.Log.With().Str("script", command[0]).Logger(),
+		command: command,
+		timeout: scriptDefaultTimeout,
+		memory:  scriptDefaultMemory << 20,
+		cpu:     scriptDefaultCPU,
+	}
+
+	if v, ok := options["timeout"]; ok {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout: %s", v)
+		}
+		s.timeout = timeout
+	}
+	if v, ok := options["memory"]; ok {
+		memory, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || memory == 0 {
+			return nil, fmt.Errorf("invalid memory: %s", v)
+		}
+		s.memory = memory << 20
+	}
+	if v, ok := options["cpu"]; ok {
+		cpu, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || cpu == 0 {
+			return nil, fmt.Errorf("invalid cpu: %s", v)
+		}
+		s.cpu = cpu
+	}
+
+	errorPage := func(w http.ResponseWriter, _ *http.Request, status int) {
+		http.Error(w, http.StatusText(status), status)
+	}
+	if mp.proxy != nil {
+		errorPage = mp.proxy.errorPage
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := s.run(r)
+			if err != nil {
+				s.log.Error().Err(err).Str("url", r.URL.String()).Msg("script failed")
+				errorPage(w, r, http.StatusBadGateway)
+				return
+			}
+
+			if res.Response != nil {
+				setHeaders(w.Header(), res.Response.Headers)
+				w.WriteHeader(res.Response.Status)
+				_, _ = w.Write([]byte(res.Response.Body))
+				return
+			}
+
+			if len(res.Headers) > 0 || res.Path != "" {
+				r = r.Clone(r.Context())
+				setHeaders(r.Header, res.Headers)
+				if res.Path != "" {
+					r.URL.Path = res.Path
+					r.URL.RawPath = ""
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// run method runs the script with the request and returns its result. The
+// script runs with an empty environment and its limits, and it's killed
+// after the timeout.
+func (s *script) run(r *http.Request) (scriptResult, error) {
+	req := scriptRequest{
+		Headers:    r.Header,
+		Method:     r.Method,
+		URL:        r.URL.String(),
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if who, ok := model.WhoisFromContext(r.Context()); ok {
+		req.Username = who.Username
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return scriptResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	var output limitedBuffer
+
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...) //nolint:gosec
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = processLogWriter{log: s.log}
+	cmd.WaitDelay = s.timeout
+
+	if err := cmd.Start(); err != nil {
+		return scriptResult{}, err
+	}
+	// the limits are applied right after the start, before the script
+	// reads the request
+	if err := limitProcess(cmd.Process.Pid, s.memory, s.cpu); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return scriptResult{}, fmt.Errorf("limiting script: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return scriptResult{}, fmt.Errorf("script timeout: %w", ctx.Err())
+		}
+		return scriptResult{}, err
+	}
+
+	var res scriptResult
+	if output.Len() == 0 {
+		return res, nil
+	}
+	if err := json.Unmarshal(output.Bytes(), &res); err != nil {
+		return scriptResult{}, fmt.Errorf("invalid script result: %w", err)
+	}
+	if res.Path != "" && !strings.HasPrefix(res.Path, "/") {
+		return scriptResult{}, ErrScriptPath
+	}
+	if res.Response != nil && (res.Response.Status < 100 || res.Response.Status > 999) {
+		return scriptResult{}, fmt.Errorf("invalid script response status: %d", res.Response.Status)
+	}
+
+	return res, nil
+}
+
+// Write method implements io.Writer Write method, the output of a script
+// beyond scriptMaxOutput is an error.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > scriptMaxOutput {
+		return 0, ErrScriptOutputTooBig
+	}
+
+	return b.Buffer.Write(p)
+}
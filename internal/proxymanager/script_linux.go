@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package proxymanager
+
+import "golang.org/x/sys/unix"
+
+// limitProcess function limits the address space and the CPU time of a
+// process.
+func limitProcess(pid int, memory, cpu uint64) error {
+	if err := unix.Prlimit(pid, unix.RLIMIT_AS, &unix.Rlimit{Cur: memory, Max: memory}, nil); err != nil {
+		return err
+	}
+
+	return unix.Prlimit(pid, unix.RLIMIT_CPU, &unix.Rlimit{Cur: cpu, Max: cpu}, nil)
+}
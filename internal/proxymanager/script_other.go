@@ -0,0 +1,12 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package proxymanager
+
+// limitProcess function doesn't limit the processes, the scripts only have
+// their timeout outside Linux.
+func limitProcess(_ int, _, _ uint64) error {
+	return nil
+}
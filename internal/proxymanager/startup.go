@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	// startSlotTimeout is the maximum time a proxy holds a startup slot, a
+	// slow login doesn't block the other proxies.
+	startSlotTimeout = 2 * time.Minute
+	// startupLogEvery is the number of started proxies between the progress
+	// logs.
+	startupLogEvery = 10
+)
+
+type (
+	// startupQueue struct limits the proxies starting at the same time, so
+	// many proxies, like on the first start, don't log in to the control
+	// server at once. A proxy holds its slot until it's running, needs
+	// authentication or fails.
+	startupQueue struct {
+		log zerolog.Logger
+		// slots are the free startup slots, nil without limit
+		slots chan struct{}
+		// settled are closed when their proxy leaves the starting statuses
+		settled map[*Proxy]chan struct{}
+		jitter  time.Duration
+		// total and started are the progress of the proxies queued since
+		// the queue was last empty
+		total   int
+		started int
+		mtx     sync.Mutex
+	}
+
+	// StartupProgress struct is the progress of the proxies being started.
+	StartupProgress struct {
+		Started int `json:"started"`
+		Total   int `json:"total"`
+	}
+)
+
+func newStartupQueue(log zerolog.Logger, concurrency int, jitter time.Duration) *startupQueue {
+	q := &startupQueue{
+		log:     log,
+		jitter:  jitter,
+		settled: make(map[*Proxy]chan struct{}),
+	}
+
+	if concurrency > 0 {
+		q.slots = make(chan struct{}, concurrency)
+	}
+
+	return q
+}
+
+// startProxy method starts a proxy when a startup slot is free.
+func (pm *ProxyManager) startProxy(p *Proxy) {
+	q := pm.startup
+	settled := q.add(p)
+
+	go func() {
+		defer q.done(p)
+
+		if q.slots != nil {
+			q.slots <- struct{}{}
+			defer func() { <-q.slots }()
+		}
+
+		if q.jitter > 0 {
+			time.Sleep(rand.N(q.jitter)) //nolint:gosec
+		}
+
+		// the proxy may be stopped or removed while it waited
+		if p.GetStatus() != model.ProxyStatusInitializing {
+			return
+		}
+
+		p.Start()
+
+		timer := time.NewTimer(startSlotTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-settled:
+		case <-timer.C:
+			p.log.Warn().Msg("Proxy is taking too long to start, starting the next one")
+		}
+	}()
+}
+
+// proxySettled method frees the startup slot of a proxy when its status
+// isn't a starting one.
+func (pm *ProxyManager) proxySettled(p *Proxy, status model.ProxyStatus) {
+	if status == model.ProxyStatusInitializing || status == model.ProxyStatusStarting {
+		return
+	}
+
+	q := pm.startup
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if ch, ok := q.settled[p]; ok {
+		close(ch)
+		delete(q.settled, p)
+	}
+}
+
+// GetStartupProgress method returns the progress of the proxies being
+// started, with 0 total when none is.
+func (pm *ProxyManager) GetStartupProgress() StartupProgress {
+	q := pm.startup
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return StartupProgress{Started: q.started, Total: q.total}
+}
+
+// add method queues a proxy, the returned channel is closed when it
+// settles.
+func (q *startupQueue) add(p *Proxy) chan struct{} {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	ch := make(chan struct{})
+	q.settled[p] = ch
+	q.total++
+
+	return ch
+}
+
+// done method counts a started proxy, the progress is reset when all queued
+// proxies are started.
+func (q *startupQueue) done(p *Proxy) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	delete(q.settled, p)
+	q.started++
+
+	if q.total > 1 && (q.started%startupLogEvery == 0 || q.started == q.total) {
+		q.log.Info().Int("started", q.started).Int("total", q.total).Msg("Starting proxies")
+	}
+
+	if q.started == q.total {
+		q.started = 0
+		q.total = 0
+	}
+}
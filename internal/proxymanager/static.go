@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"path"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+// noListingFS wraps a http.FileSystem and hides directories without an index.html.
+type noListingFS struct {
+	fs http.FileSystem
+}
+
+func (n noListingFS) Open(name string) (http.File, error) {
+	f, err := n.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		index, err := n.fs.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, fs.ErrNotExist
+		}
+		index.Close()
+	}
+
+	return f, nil
+}
+
+func newPortStatic(
+	ctx context.Context,
+	pconfig model.PortConfig,
+	log zerolog.Logger,
+	middleware Middleware,
+) *port {
+	//
+	log = log.With().Str("port", pconfig.String()).Logger()
+
+	var root http.FileSystem = http.Dir(pconfig.GetFirstTarget().Path)
+	if !pconfig.DirectoryListing {
+		root = noListingFS{fs: root}
+	}
+
+	return newPort(ctx, pconfig, log, middleware(http.FileServer(root)))
+}
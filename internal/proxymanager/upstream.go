@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxymanager
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// unixHostPrefix is the prefix of the hosts of the unix socket targets in
+// the requests, the transport dials their socket.
+const unixHostPrefix = "unix-socket-"
+
+var ErrInvalidUpstreamCA = errors.New("no certificates found in upstream CA bundle")
+
+// upstreamTLSConfig function returns the TLS configuration of the
+// connections of a port to its https targets, with the CA bundle that
+// verifies them and the client certificate sent to them.
+func upstreamTLSConfig(pconfig model.PortConfig) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: !pconfig.TLSValidate, //nolint:gosec
+		ServerName:         pconfig.Upstream.ServerName,
+	}
+
+	if pconfig.Upstream.CAFile != "" {
+		bundle, err := os.ReadFile(pconfig.Upstream.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading upstream CA bundle: %w", err)
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(bundle) {
+			return nil, ErrInvalidUpstreamCA
+		}
+	}
+
+	if pconfig.Upstream.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(pconfig.Upstream.CertFile, pconfig.Upstream.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading upstream client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// upstreamTargets function returns the URLs of the requests to the targets,
+// unix socket targets get an http URL with a host of their own. The sockets
+// are returned by the address the transport dials.
+func upstreamTargets(targets []*url.URL) ([]*url.URL, map[string]string) {
+	urls := make([]*url.URL, 0, len(targets))
+	sockets := make(map[string]string)
+
+	for i, u := range targets {
+		if u.Scheme != model.TargetSchemeUnix {
+			urls = append(urls, u)
+			continue
+		}
+
+		host := unixHostPrefix + strconv.Itoa(i)
+		sockets[net.JoinHostPort(host, "80")] = u.Path
+		urls = append(urls, &url.URL{Scheme: "http", Host: host})
+	}
+
+	return urls, sockets
+}
+
+// unixDialer function returns a dial function that connects to the sockets
+// of their addresses, and to the other addresses with dial.
+func unixDialer(sockets map[string]string,
+	dial func(ctx context.Context, network, addr string) (net.Conn, error),
+) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if socket, ok := sockets[addr]; ok {
+			return dial(ctx, "unix", socket)
+		}
+
+		return dial(ctx, network, addr)
+	}
+}
+
+// targetNetwork function returns the network dialed to connect to a target.
+func targetNetwork(u *url.URL) string {
+	if u.Scheme == model.TargetSchemeUnix {
+		return "unix"
+	}
+
+	return "tcp"
+}
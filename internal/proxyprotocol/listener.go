@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// headerTimeout is the maximum time to receive the header of a connection.
+const headerTimeout = 10 * time.Second
+
+type (
+	// listener struct is a net.Listener that reads the PROXY protocol header
+	// of its connections, required in every connection.
+	listener struct {
+		net.Listener
+	}
+
+	// conn struct is a connection with the addresses of its PROXY protocol
+	// header. The header is read on the first use of the connection, so
+	// Accept isn't blocked by slow clients.
+	conn struct {
+		net.Conn
+		reader *bufio.Reader
+		err    error
+		src    net.Addr
+		dst    net.Addr
+		once   sync.Once
+	}
+)
+
+// NewListener function returns a listener of connections that start with a
+// PROXY protocol header, version 1 or 2. Their RemoteAddr and LocalAddr are
+// the addresses of the header, connections without a header are closed.
+func NewListener(l net.Listener) net.Listener {
+	return &listener{Listener: l}
+}
+
+// Accept method implements net.Listener Accept method.
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: c, reader: bufio.NewReader(c)}, nil
+}
+
+// Read method implements net.Conn Read method.
+func (c *conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr method implements net.Conn RemoteAddr method.
+func (c *conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.src != nil {
+		return c.src
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr method implements net.Conn LocalAddr method.
+func (c *conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.dst != nil {
+		return c.dst
+	}
+
+	return c.Conn.LocalAddr()
+}
+
+// readHeader method reads the header of the connection, closing it if the
+// header is invalid.
+func (c *conn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+	defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+
+	c.src, c.dst, c.err = readHeader(c.reader)
+	if c.err != nil {
+		c.Conn.Close()
+	}
+}
+
+func readHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	v1Prefix := []byte("PROXY ")
+
+	start, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	if bytes.Equal(start, v1Prefix) {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return parseV1(line)
+	}
+
+	header := make([]byte, v2HeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.HasPrefix(header, v2Signature) {
+		return nil, nil, ErrInvalidHeader
+	}
+	block := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, block); err != nil {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	return parseV2(header[12], header[13], block)
+}
+
+// readLine function reads a version 1 header line, up to its maximum length.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for len(line) < v1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidHeader
+		}
+		line = append(line, b)
+		if b == '\n' {
+			return line, nil
+		}
+	}
+
+	return nil, ErrInvalidHeader
+}
+
+// Dialer function returns a DialContext function that sends the header of
+// the version before the data of the connections. The source address is
+// the one of the context, the destination is the local address of the
+// request in the context.
+func Dialer(version string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		src, _ := SourceFromContext(ctx)
+		dst := ""
+		if local, ok := ctx.Value(http.LocalAddrContextKey).(net.Addr); ok {
+			dst = local.String()
+		}
+
+		header, err := Header(version, src, dst)
+		if err == nil {
+			_, err = c.Write(header)
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+
+		return c, nil
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package proxyprotocol implements the HAProxy PROXY protocol, versions 1
+// and 2, used to send the address of the client to a backend in front of
+// the connection.
+package proxyprotocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+// Versions of the PROXY protocol
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+const (
+	v2HeaderLen = 16
+	v2CmdLocal  = 0x20
+	v2CmdProxy  = 0x21
+	v2FamTCP4   = 0x11
+	v2FamTCP6   = 0x21
+	v2FamUnspec = 0x00
+	v2AddrLen4  = 12
+	v2AddrLen6  = 36
+	// v1MaxLen is the maximum length of a version 1 header
+	v1MaxLen = 107
+)
+
+// v2Signature is the start of the version 2 headers.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+
+type sourceKey struct{}
+
+// WithSource function returns a context with the address of the client,
+// sent in the header of the connections dialed with it.
+func WithSource(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, addr)
+}
+
+// SourceFromContext function returns the address of the client of the
+// context.
+func SourceFromContext(ctx context.Context) (string, bool) {
+	addr, ok := ctx.Value(sourceKey{}).(string)
+	return addr, ok
+}
+
+// Header function returns the header of a connection from src to dst. The
+// header doesn't have addresses if any of them is unknown.
+func Header(version string, src, dst string) ([]byte, error) {
+	srcAddr, err1 := netip.ParseAddrPort(src)
+	dstAddr, err2 := netip.ParseAddrPort(dst)
+	known := err1 == nil && err2 == nil
+
+	// mixed families are sent as IPv6
+	ipv4 := srcAddr.Addr().Unmap().Is4() && dstAddr.Addr().Unmap().Is4()
+
+	switch version {
+	case V1:
+		if !known {
+			return []byte("PROXY UNKNOWN\r\n"), nil
+		}
+		family, srcIP, dstIP := "TCP6", srcAddr.Addr().String(), dstAddr.Addr().String()
+		if ipv4 {
+			family, srcIP, dstIP = "TCP4", srcAddr.Addr().Unmap().String(), dstAddr.Addr().Unmap().String()
+		} else {
+			srcIP = netip.AddrFrom16(srcAddr.Addr().As16()).String()
+			dstIP = netip.AddrFrom16(dstAddr.Addr().As16()).String()
+		}
+		return fmt.Appendf(nil, "PROXY %s %s %s %d %d\r\n", family, srcIP, dstIP, srcAddr.Port(), dstAddr.Port()), nil
+
+	case V2:
+		h := append([]byte{}, v2Signature...)
+		switch {
+		case !known:
+			h = append(h, v2CmdProxy, v2FamUnspec, 0, 0)
+		case ipv4:
+			h = append(h, v2CmdProxy, v2FamTCP4)
+			h = binary.BigEndian.AppendUint16(h, v2AddrLen4)
+			s, d := srcAddr.Addr().Unmap().As4(), dstAddr.Addr().Unmap().As4()
+			h = append(append(h, s[:]...), d[:]...)
+			h = binary.BigEndian.AppendUint16(h, srcAddr.Port())
+			h = binary.BigEndian.AppendUint16(h, dstAddr.Port())
+		default:
+			h = append(h, v2CmdProxy, v2FamTCP6)
+			h = binary.BigEndian.AppendUint16(h, v2AddrLen6)
+			s, d := srcAddr.Addr().As16(), dstAddr.Addr().As16()
+			h = append(append(h, s[:]...), d[:]...)
+			h = binary.BigEndian.AppendUint16(h, srcAddr.Port())
+			h = binary.BigEndian.AppendUint16(h, dstAddr.Port())
+		}
+		return h, nil
+	}
+
+	return nil, fmt.Errorf("unsupported PROXY protocol version %q", version)
+}
+
+// parseV1 function returns the addresses of a version 1 header line, nil
+// for UNKNOWN connections.
+func parseV1(line []byte) (net.Addr, net.Addr, error) {
+	line, ok := bytes.CutSuffix(line, []byte("\r\n"))
+	if !ok {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	fields := bytes.Split(line, []byte(" "))
+	if len(fields) >= 2 && string(fields[1]) == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (string(fields[1]) != "TCP4" && string(fields[1]) != "TCP6") { //nolint:mnd
+		return nil, nil, ErrInvalidHeader
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src, dst, nil
+}
+
+func parseV1Addr(ip, port []byte) (net.Addr, error) {
+	addr, err := netip.ParseAddr(string(ip))
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	p, err := strconv.ParseUint(string(port), 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(p))), nil
+}
+
+// parseV2 function returns the addresses of the address block of a version
+// 2 header, nil for LOCAL connections and unsupported families.
+func parseV2(cmd, family byte, block []byte) (net.Addr, net.Addr, error) {
+	switch cmd {
+	case v2CmdLocal:
+		return nil, nil, nil
+	case v2CmdProxy:
+	default:
+		return nil, nil, ErrInvalidHeader
+	}
+
+	switch {
+	case family == v2FamTCP4 && len(block) >= v2AddrLen4:
+		src := netip.AddrFrom4([4]byte(block[0:4]))
+		dst := netip.AddrFrom4([4]byte(block[4:8]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(src, binary.BigEndian.Uint16(block[8:10]))),
+			net.TCPAddrFromAddrPort(netip.AddrPortFrom(dst, binary.BigEndian.Uint16(block[10:12]))), nil
+	case family == v2FamTCP6 && len(block) >= v2AddrLen6:
+		src := netip.AddrFrom16([16]byte(block[0:16]))
+		dst := netip.AddrFrom16([16]byte(block[16:32]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(src, binary.BigEndian.Uint16(block[32:34]))),
+			net.TCPAddrFromAddrPort(netip.AddrPortFrom(dst, binary.BigEndian.Uint16(block[34:36]))), nil
+	case family == v2FamTCP4 || family == v2FamTCP6:
+		return nil, nil, ErrInvalidHeader
+	}
+
+	return nil, nil, nil
+}
@@ -0,0 +1,283 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package ngrok
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+const (
+	tunnelsPath    = "/api/tunnels"
+	requestTimeout = 10 * time.Second
+	// watchInterval is the interval of the checks of the tunnels, the
+	// tunnels missing in the agent, like after it restarts, are started
+	// again.
+	watchInterval = 30 * time.Second
+)
+
+// Client struct implements proxyproviders.Provider with ngrok tunnels started
+// with the API of an ngrok agent. The agent forwards the tunnels to local
+// ports of TSDProxy.
+type Client struct {
+	log     zerolog.Logger
+	http    *http.Client
+	config  *config.NgrokProxyProviderConfig
+	tunnels map[string]*tunnel
+	agent   string
+	mtx     sync.Mutex
+}
+
+type (
+	// tunnel struct is a tunnel of the agent and its public URL, empty
+	// until the agent starts it.
+	tunnel struct {
+		request   tunnelRequest
+		publicURL string
+	}
+
+	// tunnelRequest struct is the tunnel definition sent to the agent.
+	tunnelRequest struct {
+		OAuth      *tunnelOAuth `json:"oauth,omitempty"`
+		Name       string       `json:"name"`
+		Proto      string       `json:"proto"`
+		Addr       string       `json:"addr"`
+		Domain     string       `json:"domain,omitempty"`
+		ProxyProto string       `json:"proxy_proto,omitempty"`
+	}
+
+	tunnelOAuth struct {
+		Provider     string   `json:"provider"`
+		Scopes       []string `json:"scopes,omitempty"`
+		AllowEmails  []string `json:"allow_emails,omitempty"`
+		AllowDomains []string `json:"allow_domains,omitempty"`
+	}
+
+	// tunnelResponse struct is a tunnel started by the agent.
+	tunnelResponse struct {
+		Name      string `json:"name"`
+		PublicURL string `json:"public_url"`
+	}
+
+	// tunnelsResponse struct is the list of tunnels of the agent.
+	tunnelsResponse struct {
+		Tunnels []tunnelResponse `json:"tunnels"`
+	}
+
+	// agentError struct is an error returned by the agent API.
+	agentError struct {
+		Msg string `json:"msg"`
+	}
+)
+
+var (
+	_ proxyproviders.Provider = (*Client)(nil)
+
+	ErrAgent = errors.New("ngrok agent error")
+)
+
+// New function returns the ngrok proxy provider of a configuration.
+func New(log zerolog.Logger, name string, provider *config.NgrokProxyProviderConfig) (*Client, error) {
+	log = core.ModuleLogger(log, "ngrok").With().Str("ngrok", name).Logger()
+
+	agent, err := url.Parse(provider.AgentURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ngrok agent url: %w", err)
+	}
+
+	c := &Client{
+		log:     log,
+		http:    &http.Client{Timeout: requestTimeout},
+		config:  provider,
+		tunnels: make(map[string]*tunnel),
+		agent:   strings.TrimSuffix(agent.String(), "/"),
+	}
+
+	go c.watch()
+
+	return c, nil
+}
+
+// NewProxy method implements proxyproviders.Provider NewProxy method.
+func (c *Client) NewProxy(cfg *model.Config) (proxyproviders.ProxyInterface, error) {
+	p := &Proxy{
+		log:    c.log.With().Str("Hostname", cfg.Hostname).Logger(),
+		client: c,
+		events: make(chan model.ProxyEvent),
+	}
+	p.config.Store(cfg)
+
+	return p, nil
+}
+
+// watch method starts again the tunnels missing in the agent.
+func (c *Client) watch() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.restartTunnels()
+	}
+}
+
+// restartTunnels method starts the tunnels that aren't running in the agent.
+func (c *Client) restartTunnels() {
+	c.mtx.Lock()
+	empty := len(c.tunnels) == 0
+	c.mtx.Unlock()
+	if empty {
+		return
+	}
+
+	running, err := c.listTunnels()
+	if err != nil {
+		c.log.Warn().Err(err).Msg("error listing ngrok tunnels")
+		return
+	}
+
+	c.mtx.Lock()
+	var missing []string
+	for name := range c.tunnels {
+		if _, ok := running[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	c.mtx.Unlock()
+
+	for _, name := range missing {
+		if err := c.startTunnel(name); err != nil {
+			c.log.Warn().Err(err).Str("tunnel", name).Msg("error starting ngrok tunnel")
+		}
+	}
+}
+
+// ping method returns an error if the agent API doesn't answer.
+func (c *Client) ping() error {
+	_, err := c.listTunnels()
+	return err
+}
+
+// addTunnel method registers a tunnel and starts it in the agent. Tunnels
+// that fail to start are started again by watch.
+func (c *Client) addTunnel(req tunnelRequest) error {
+	c.mtx.Lock()
+	c.tunnels[req.Name] = &tunnel{request: req}
+	c.mtx.Unlock()
+
+	return c.startTunnel(req.Name)
+}
+
+// startTunnel method starts a registered tunnel in the agent and saves its
+// public URL.
+func (c *Client) startTunnel(name string) error {
+	c.mtx.Lock()
+	t, ok := c.tunnels[name]
+	c.mtx.Unlock()
+	if !ok {
+		return nil
+	}
+
+	body, err := json.Marshal(t.request)
+	if err != nil {
+		return err
+	}
+
+	var res tunnelResponse
+	if err := c.do(http.MethodPost, tunnelsPath, body, &res); err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	if c.tunnels[name] == t {
+		t.publicURL = res.PublicURL
+	}
+	c.mtx.Unlock()
+
+	c.log.Info().Str("tunnel", name).Str("url", res.PublicURL).Msg("ngrok tunnel started")
+
+	return nil
+}
+
+// removeTunnel method stops a tunnel in the agent and unregisters it.
+func (c *Client) removeTunnel(name string) error {
+	c.mtx.Lock()
+	delete(c.tunnels, name)
+	c.mtx.Unlock()
+
+	return c.do(http.MethodDelete, tunnelsPath+"/"+url.PathEscape(name), nil, nil)
+}
+
+// publicURL method returns the public URL of a tunnel, empty if it isn't
+// started.
+func (c *Client) publicURL(name string) string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if t, ok := c.tunnels[name]; ok {
+		return t.publicURL
+	}
+
+	return ""
+}
+
+// listTunnels method returns the names of the tunnels running in the agent.
+func (c *Client) listTunnels() (map[string]struct{}, error) {
+	var res tunnelsResponse
+	if err := c.do(http.MethodGet, tunnelsPath, nil, &res); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(res.Tunnels))
+	for _, t := range res.Tunnels {
+		names[t.Name] = struct{}{}
+	}
+
+	return names, nil
+}
+
+// do method sends a request to the agent API and decodes the response in
+// out, when it isn't nil.
+func (c *Client) do(method, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(context.Background(), method, c.agent+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error connecting to ngrok agent: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var agentErr agentError
+		_ = json.NewDecoder(io.LimitReader(res.Body, 1<<16)).Decode(&agentErr)
+		return fmt.Errorf("%w: %s %s: %d %s", ErrAgent, method, path, res.StatusCode, agentErr.Msg)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
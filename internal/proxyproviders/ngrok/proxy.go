@@ -0,0 +1,278 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyprotocol"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+const (
+	minRetryInterval = time.Second
+	maxRetryInterval = time.Minute
+
+	// ngrok sends the user of the OAuth tunnels in these headers
+	headerOAuthEmail = "Ngrok-Auth-User-Email"
+	headerOAuthName  = "Ngrok-Auth-User-Name"
+)
+
+type (
+	// Proxy struct implements proxyproviders.ProxyInterface with an ngrok
+	// tunnel for each port.
+	Proxy struct {
+		log       zerolog.Logger
+		ctx       context.Context
+		client    *Client
+		config    atomic.Pointer[model.Config]
+		events    chan model.ProxyEvent
+		listeners []*tunnelListener
+		mtx       sync.Mutex
+	}
+
+	// tunnelListener struct is the local listener of a tunnel, the tunnel
+	// is stopped when it's closed.
+	tunnelListener struct {
+		net.Listener
+		client *Client
+		name   string
+		once   sync.Once
+	}
+)
+
+var (
+	_ proxyproviders.ProxyInterface = (*Proxy)(nil)
+	_ proxyproviders.Reconfigurer   = (*Proxy)(nil)
+
+	ErrProxyPortNotFound   = errors.New("proxy port not found")
+	ErrUnsupportedProtocol = errors.New("ngrok tunnels only forward http and tcp ports")
+	ErrUnsupportedMTLS     = errors.New("ngrok tunnels don't support client certificates")
+)
+
+// Start method implements proxyproviders.ProxyInterface Start method. The
+// proxy is running when the agent answers.
+func (p *Proxy) Start(ctx context.Context) error {
+	p.mtx.Lock()
+	p.ctx = ctx
+	p.mtx.Unlock()
+
+	go p.connect()
+
+	return nil
+}
+
+// connect method checks the agent until it answers or the proxy is closed.
+func (p *Proxy) connect() {
+	p.setStatus(model.ProxyStatusStarting)
+
+	retry := minRetryInterval
+	for {
+		err := p.client.ping()
+		if err == nil {
+			p.setStatus(model.ProxyStatusRunning)
+			return
+		}
+		p.log.Error().Err(err).Dur("retry", retry).Msg("error connecting to ngrok agent")
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, maxRetryInterval)
+	}
+}
+
+// setStatus method sends a status event, unless the proxy is closed.
+func (p *Proxy) setStatus(status model.ProxyStatus) {
+	select {
+	case p.events <- model.ProxyEvent{Status: status}:
+	case <-p.ctx.Done():
+	}
+}
+
+// Close method implements proxyproviders.ProxyInterface Close method, the
+// tunnels are stopped in the agent.
+func (p *Proxy) Close() error {
+	p.mtx.Lock()
+	listeners := p.listeners
+	p.listeners = nil
+	p.mtx.Unlock()
+
+	var errs error
+	for _, l := range listeners {
+		errs = errors.Join(errs, l.Close())
+	}
+
+	return errs
+}
+
+// GetListener method implements proxyproviders.ProxyInterface GetListener
+// method. The listener is a local port, forwarded by a tunnel started in the
+// agent.
+func (p *Proxy) GetListener(port string) (net.Listener, error) {
+	cfg := p.config.Load()
+	portCfg, ok := cfg.Ports[port]
+	if !ok {
+		return nil, ErrProxyPortNotFound
+	}
+
+	if portCfg.ProxyProtocol != "http" && portCfg.ProxyProtocol != "tcp" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProtocol, portCfg.ProxyProtocol)
+	}
+	if portCfg.MTLS.IsEnabled() {
+		return nil, ErrUnsupportedMTLS
+	}
+
+	provider := p.client.config
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(provider.ListenAddress, "0"))
+	if err != nil {
+		return nil, err
+	}
+
+	forwardHost := provider.ForwardHost
+	if forwardHost == "" {
+		forwardHost = provider.ListenAddress
+	}
+	localPort := ln.Addr().(*net.TCPAddr).Port
+
+	req := tunnelRequest{
+		Name:  tunnelName(cfg.Hostname, port),
+		Proto: portCfg.ProxyProtocol,
+		Addr:  net.JoinHostPort(forwardHost, strconv.Itoa(localPort)),
+	}
+	if portCfg.ProxyProtocol == "http" {
+		// the reserved domain is used by the first http port, the others
+		// get a random domain
+		if provider.Domain != "" && firstHTTPPort(cfg) == port {
+			req.Domain = strings.ReplaceAll(provider.Domain, "*", strings.ToLower(cfg.Hostname))
+		}
+		if provider.OAuth.Provider != "" {
+			req.OAuth = &tunnelOAuth{
+				Provider:     provider.OAuth.Provider,
+				Scopes:       provider.OAuth.Scopes,
+				AllowEmails:  provider.OAuth.AllowEmails,
+				AllowDomains: provider.OAuth.AllowDomains,
+			}
+		}
+	}
+	if portCfg.AcceptProxyProtocol {
+		req.ProxyProto = "2"
+	}
+
+	if err := p.client.addTunnel(req); err != nil {
+		p.log.Error().Err(err).Str("tunnel", req.Name).Msg("error starting ngrok tunnel, retrying")
+	}
+
+	l := &tunnelListener{Listener: ln, client: p.client, name: req.Name}
+
+	p.mtx.Lock()
+	p.listeners = append(p.listeners, l)
+	p.mtx.Unlock()
+
+	if portCfg.AcceptProxyProtocol {
+		return proxyprotocol.NewListener(l), nil
+	}
+
+	return l, nil
+}
+
+// Reconfigure method implements proxyproviders.Reconfigurer Reconfigure
+// method, the new ports get a tunnel with the next GetListener.
+func (p *Proxy) Reconfigure(cfg *model.Config) {
+	p.config.Store(cfg)
+}
+
+// GetURL method implements proxyproviders.ProxyInterface GetURL method, the
+// public URL of the tunnel of the first http port.
+func (p *Proxy) GetURL() string {
+	cfg := p.config.Load()
+	if port := firstHTTPPort(cfg); port != "" {
+		return p.client.publicURL(tunnelName(cfg.Hostname, port))
+	}
+
+	return ""
+}
+
+// GetAuthURL method implements proxyproviders.ProxyInterface GetAuthURL
+// method, tunnels don't need authentication.
+func (p *Proxy) GetAuthURL() string {
+	return ""
+}
+
+// GetTailnet method implements proxyproviders.ProxyInterface GetTailnet
+// method, tunnels aren't in a tailnet.
+func (p *Proxy) GetTailnet() string {
+	return ""
+}
+
+// WatchEvents method implements proxyproviders.ProxyInterface WatchEvents
+// method.
+func (p *Proxy) WatchEvents() chan model.ProxyEvent {
+	return p.events
+}
+
+// Whois method implements proxyproviders.ProxyInterface Whois method, the
+// users of tunnels protected with OAuth are sent by ngrok in the request
+// headers.
+func (p *Proxy) Whois(r *http.Request) model.Whois {
+	if p.client.config.OAuth.Provider == "" {
+		return model.Whois{}
+	}
+
+	email := r.Header.Get(headerOAuthEmail)
+	if email == "" {
+		return model.Whois{}
+	}
+
+	return model.Whois{
+		ID:          email,
+		Username:    email,
+		DisplayName: r.Header.Get(headerOAuthName),
+	}
+}
+
+// Close method implements net.Listener Close method, the tunnel is stopped
+// in the agent.
+func (l *tunnelListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		err = errors.Join(l.Listener.Close(), l.client.removeTunnel(l.name))
+	})
+
+	return err
+}
+
+// tunnelName function returns the name of the tunnel of a port of a proxy.
+func tunnelName(hostname, port string) string {
+	return strings.ToLower(hostname) + "-" + strings.ReplaceAll(port, "/", "-")
+}
+
+// firstHTTPPort function returns the first http port of a proxy, sorted by
+// name.
+func firstHTTPPort(cfg *model.Config) string {
+	for _, name := range slices.Sorted(maps.Keys(cfg.Ports)) {
+		if cfg.Ports[name].ProxyProtocol == "http" {
+			return name
+		}
+	}
+
+	return ""
+}
@@ -24,7 +24,74 @@ type (
 		GetListener(port string) (net.Listener, error)
 		GetURL() string
 		GetAuthURL() string
+		GetTailnet() string
 		WatchEvents() chan model.ProxyEvent
 		Whois(r *http.Request) model.Whois
 	}
+
+	// FileSharer interface is implemented by proxies that can send files to
+	// peers of the network and receive files from them.
+	FileSharer interface {
+		SendFile(ctx context.Context, peer, name string, data []byte) error
+		ReceiveFiles(ctx context.Context, maxSize int64) ([]File, error)
+	}
+
+	// Describer interface is implemented by proxies that report the details
+	// of their node.
+	Describer interface {
+		NodeInfo(ctx context.Context) (model.NodeInfo, error)
+	}
+
+	// Certifier interface is implemented by proxies that serve TLS
+	// certificates issued for their node.
+	Certifier interface {
+		Certificates(ctx context.Context) ([]model.CertInfo, error)
+	}
+
+	// Remover interface is implemented by proxies that delete their node
+	// from the network when the proxy is removed, instead of only closing
+	// it.
+	Remover interface {
+		Remove() error
+	}
+
+	// StateRecoverer interface is implemented by proxies that start a new
+	// node when the state of their node is corrupted. RecoveredState
+	// returns where the corrupted state was moved, empty if it wasn't.
+	StateRecoverer interface {
+		RecoveredState() string
+	}
+
+	// ClientAddresser interface is implemented by proxies that receive
+	// requests forwarded by their node, like the Tailscale serve config and
+	// Funnel. ClientAddr returns the address of the client that the node sets
+	// in X-Forwarded-For, false for the requests received directly.
+	ClientAddresser interface {
+		ClientAddr(r *http.Request) (string, bool)
+	}
+
+	// Warner interface is implemented by providers that run with degraded
+	// functionality, the warnings are shown in the dashboard.
+	Warner interface {
+		Warnings() []string
+	}
+
+	// StateReporter interface is implemented by providers that keep the
+	// state of their nodes in directories, reported with their size.
+	StateReporter interface {
+		StateDirs() ([]model.StateDir, error)
+	}
+
+	// Reconfigurer interface is implemented by proxies that read the ports
+	// of their configuration, replaced when the proxy is reloaded without a
+	// new node.
+	Reconfigurer interface {
+		Reconfigure(cfg *model.Config)
+	}
+
+	// File struct is a file received from a peer.
+	File struct {
+		Name string
+		Data []byte
+	}
 )
@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package sshtunnel
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	minRetryInterval = time.Second
+	maxRetryInterval = time.Minute
+)
+
+type (
+	// tunnelListener struct accepts the connections of a reverse tunnel in
+	// the bastion. The tunnel is opened again after the SSH connection
+	// fails, so the port keeps serving.
+	tunnelListener struct {
+		log     zerolog.Logger
+		client  *Client
+		current net.Listener
+		done    chan struct{}
+		addr    tunnelAddr
+		once    sync.Once
+		mtx     sync.Mutex
+	}
+
+	// tunnelAddr is the address of a tunnel in the bastion.
+	tunnelAddr string
+)
+
+var _ net.Listener = (*tunnelListener)(nil)
+
+// newTunnelListener function returns the listener of a tunnel, it's opened
+// on the first Accept.
+func newTunnelListener(log zerolog.Logger, client *Client, addr string) *tunnelListener {
+	return &tunnelListener{
+		log:    log.With().Str("tunnel", addr).Logger(),
+		client: client,
+		addr:   tunnelAddr(addr),
+		done:   make(chan struct{}),
+	}
+}
+
+// Accept method implements net.Listener Accept method.
+func (l *tunnelListener) Accept() (net.Conn, error) {
+	retry := minRetryInterval
+	for {
+		ln, err := l.listener()
+		if err == nil {
+			var conn net.Conn
+			if conn, err = ln.Accept(); err == nil {
+				return conn, nil
+			}
+			l.drop(ln)
+		}
+
+		select {
+		case <-l.done:
+			return nil, net.ErrClosed
+		default:
+		}
+
+		l.log.Warn().Err(err).Dur("retry", retry).Msg("ssh tunnel closed, opening it again")
+
+		select {
+		case <-l.done:
+			return nil, net.ErrClosed
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, maxRetryInterval)
+	}
+}
+
+// listener method returns the tunnel, opening it if it isn't open.
+func (l *tunnelListener) listener() (net.Listener, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	select {
+	case <-l.done:
+		return nil, net.ErrClosed
+	default:
+	}
+
+	if l.current != nil {
+		return l.current, nil
+	}
+
+	client, err := l.client.sshClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := client.Listen("tcp", string(l.addr))
+	if err != nil {
+		return nil, err
+	}
+	l.current = ln
+
+	l.log.Info().Msg("ssh tunnel opened")
+
+	return ln, nil
+}
+
+// drop method closes the tunnel if it's still the current one.
+func (l *tunnelListener) drop(ln net.Listener) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.current == ln {
+		l.current = nil
+		_ = ln.Close()
+	}
+}
+
+// Close method implements net.Listener Close method.
+func (l *tunnelListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+
+		if l.current != nil {
+			err = l.current.Close()
+			l.current = nil
+		}
+	})
+
+	return err
+}
+
+// Addr method implements net.Listener Addr method.
+func (l *tunnelListener) Addr() net.Addr {
+	return l.addr
+}
+
+// Network method implements net.Addr Network method.
+func (a tunnelAddr) Network() string {
+	return "tcp"
+}
+
+// String method implements net.Addr String method.
+func (a tunnelAddr) String() string {
+	return string(a)
+}
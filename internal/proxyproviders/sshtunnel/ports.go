@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package sshtunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+)
+
+var ErrNoFreePort = errors.New("no free port in the range of the ssh tunnels")
+
+// portAllocator struct assigns the ports of the tunnels in the bastion. The
+// ports are saved, so the URLs of the proxies don't change on restarts.
+type portAllocator struct {
+	ports map[string]int
+	file  string
+	min   int
+	max   int
+	mtx   sync.Mutex
+}
+
+// newPortAllocator function returns the allocator of a range of ports with
+// the ports saved in file, the ports out of the range are dropped.
+func newPortAllocator(file string, minPort, maxPort int) (*portAllocator, error) {
+	a := &portAllocator{
+		ports: make(map[string]int),
+		file:  file,
+		min:   minPort,
+		max:   maxPort,
+	}
+
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return a, nil
+	case err != nil:
+		return nil, err
+	}
+
+	var saved map[string]int
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	for key, port := range saved {
+		if port >= minPort && port <= maxPort {
+			a.ports[key] = port
+		}
+	}
+
+	return a, nil
+}
+
+// get method returns the port of a key, assigning the first free port of the
+// range if it has none.
+func (a *portAllocator) get(key string) (int, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if port, ok := a.ports[key]; ok {
+		return port, nil
+	}
+
+	used := make(map[int]bool, len(a.ports))
+	for _, port := range a.ports {
+		used[port] = true
+	}
+
+	for port := a.min; port <= a.max; port++ {
+		if used[port] {
+			continue
+		}
+
+		a.ports[key] = port
+		return port, a.save()
+	}
+
+	return 0, ErrNoFreePort
+}
+
+// lookup method returns the port of a key, if it has one.
+func (a *portAllocator) lookup(key string) (int, bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	port, ok := a.ports[key]
+	return port, ok
+}
+
+// release method frees the ports of the keys with a prefix.
+func (a *portAllocator) release(prefix string) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for key := range a.ports {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.ports, key)
+		}
+	}
+
+	return a.save()
+}
+
+// save method writes the ports to the file.
+func (a *portAllocator) save() error {
+	data, err := json.Marshal(a.ports)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.file), consts.PermOwnerAll); err != nil {
+		return err
+	}
+
+	return os.WriteFile(a.file, data, consts.PermAllRead+consts.PermOwnerWrite)
+}
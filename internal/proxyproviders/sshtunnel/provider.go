@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package sshtunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/sshauth"
+)
+
+const (
+	defaultSSHPort     = "22"
+	defaultBindAddress = "localhost"
+	dialTimeout        = 10 * time.Second
+
+	// portsDir is the directory of the ports of the tunnels of the
+	// providers, in the data directory.
+	portsDir = "sshtunnel"
+)
+
+// Client struct implements proxyproviders.Provider with reverse SSH tunnels
+// opened in a bastion host. The SSH connection is shared by the proxies of
+// the provider and opened again after it fails.
+type Client struct {
+	log         zerolog.Logger
+	config      *ssh.ClientConfig
+	client      *ssh.Client
+	ports       *portAllocator
+	addr        string
+	bindAddress string
+	publicHost  string
+	keepAlive   time.Duration
+	mtx         sync.Mutex
+}
+
+var (
+	_ proxyproviders.Provider = (*Client)(nil)
+
+	ErrKeepAliveTimeout = errors.New("keepalive timeout")
+)
+
+// New function returns the SSH tunnel proxy provider of a configuration.
+func New(log zerolog.Logger, name string, provider *config.SSHTunnelProxyProviderConfig) (*Client, error) {
+	log = core.ModuleLogger(log, "sshtunnel").With().Str("sshtunnel", name).Logger()
+
+	hostKeyCallback, err := sshauth.HostKeyCallback(provider.KnownHostsFile, provider.InsecureIgnoreHostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := sshauth.Auth(provider.KeyFile, provider.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := provider.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultSSHPort)
+	}
+
+	publicHost := provider.PublicHost
+	if publicHost == "" {
+		publicHost, _, _ = net.SplitHostPort(addr)
+	}
+
+	bindAddress := provider.BindAddress
+	if bindAddress == "" {
+		bindAddress = defaultBindAddress
+	}
+
+	ports, err := newPortAllocator(filepath.Join(config.Config.Tailscale.DataDir, portsDir, name+".json"),
+		provider.MinPort, provider.MaxPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		log: log,
+		config: &ssh.ClientConfig{
+			User:            provider.User,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         dialTimeout,
+		},
+		ports:       ports,
+		addr:        addr,
+		bindAddress: bindAddress,
+		publicHost:  publicHost,
+		keepAlive:   provider.KeepAlive,
+	}, nil
+}
+
+// NewProxy method implements proxyproviders.Provider NewProxy method.
+func (c *Client) NewProxy(cfg *model.Config) (proxyproviders.ProxyInterface, error) {
+	p := &Proxy{
+		log:    c.log.With().Str("Hostname", cfg.Hostname).Logger(),
+		client: c,
+		events: make(chan model.ProxyEvent),
+	}
+	p.config.Store(cfg)
+
+	return p, nil
+}
+
+// sshClient method returns the SSH connection to the bastion, connecting if
+// there isn't one.
+func (c *Client) sshClient() (*ssh.Client, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	client, err := ssh.Dial("tcp", c.addr, c.config)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to ssh bastion %s: %w", c.addr, err)
+	}
+	c.client = client
+
+	c.log.Info().Str("bastion", c.addr).Msg("connected to ssh bastion")
+
+	go c.watch(client)
+
+	return client, nil
+}
+
+// watch method sends the keepalives of a connection, a connection that is
+// closed or doesn't answer is reset, and the tunnels open it again.
+func (c *Client) watch(client *ssh.Client) {
+	done := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(c.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			c.reset(client)
+			return
+		case <-ticker.C:
+			if err := sendKeepAlive(client, c.keepAlive); err != nil {
+				c.log.Warn().Err(err).Str("bastion", c.addr).Msg("ssh bastion doesn't answer")
+				c.reset(client)
+				return
+			}
+		}
+	}
+}
+
+// sendKeepAlive function sends a keepalive request, it fails if the bastion
+// doesn't answer before the timeout.
+func sendKeepAlive(client *ssh.Client, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(timeout):
+		return ErrKeepAliveTimeout
+	}
+}
+
+// reset method closes the SSH connection if it's still the current one.
+func (c *Client) reset(client *ssh.Client) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.client == client {
+		c.client = nil
+		_ = client.Close()
+		c.log.Warn().Str("bastion", c.addr).Msg("connection to ssh bastion lost")
+	}
+}
+
+// bindAddr method returns the address of a tunnel in the bastion.
+func (c *Client) bindAddr(port int) string {
+	return net.JoinHostPort(c.bindAddress, strconv.Itoa(port))
+}
+
+// portKey function returns the key of the tunnel of a port of a proxy.
+func portKey(hostname, port string) string {
+	return strings.ToLower(hostname) + "/" + port
+}
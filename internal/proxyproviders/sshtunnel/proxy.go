@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package sshtunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyprotocol"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+// Proxy struct implements proxyproviders.ProxyInterface with a reverse
+// tunnel in the bastion for each port.
+type Proxy struct {
+	log       zerolog.Logger
+	ctx       context.Context
+	client    *Client
+	config    atomic.Pointer[model.Config]
+	events    chan model.ProxyEvent
+	listeners []*tunnelListener
+	mtx       sync.Mutex
+}
+
+var (
+	_ proxyproviders.ProxyInterface = (*Proxy)(nil)
+	_ proxyproviders.Remover        = (*Proxy)(nil)
+	_ proxyproviders.Reconfigurer   = (*Proxy)(nil)
+
+	ErrProxyPortNotFound   = errors.New("proxy port not found")
+	ErrUnsupportedProtocol = errors.New("ssh tunnels only forward http and tcp ports")
+	ErrUnsupportedMTLS     = errors.New("ssh tunnels don't support client certificates")
+)
+
+// Start method implements proxyproviders.ProxyInterface Start method. The
+// proxy is running when the bastion is connected.
+func (p *Proxy) Start(ctx context.Context) error {
+	p.mtx.Lock()
+	p.ctx = ctx
+	p.mtx.Unlock()
+
+	go p.connect()
+
+	return nil
+}
+
+// connect method connects to the bastion until it's connected or the proxy
+// is closed.
+func (p *Proxy) connect() {
+	p.setStatus(model.ProxyStatusStarting)
+
+	retry := minRetryInterval
+	for {
+		_, err := p.client.sshClient()
+		if err == nil {
+			p.setStatus(model.ProxyStatusRunning)
+			return
+		}
+		p.log.Error().Err(err).Dur("retry", retry).Msg("error connecting to ssh bastion")
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, maxRetryInterval)
+	}
+}
+
+// setStatus method sends a status event, unless the proxy is closed.
+func (p *Proxy) setStatus(status model.ProxyStatus) {
+	select {
+	case p.events <- model.ProxyEvent{Status: status}:
+	case <-p.ctx.Done():
+	}
+}
+
+// Close method implements proxyproviders.ProxyInterface Close method.
+func (p *Proxy) Close() error {
+	p.mtx.Lock()
+	listeners := p.listeners
+	p.listeners = nil
+	p.mtx.Unlock()
+
+	var errs error
+	for _, l := range listeners {
+		errs = errors.Join(errs, l.Close())
+	}
+
+	return errs
+}
+
+// Remove method implements proxyproviders.Remover Remove method, the ports
+// of the tunnels are freed for other proxies.
+func (p *Proxy) Remove() error {
+	return errors.Join(p.Close(), p.client.ports.release(portKey(p.config.Load().Hostname, "")))
+}
+
+// GetListener method implements proxyproviders.ProxyInterface GetListener
+// method, the listener is a tunnel in the bastion.
+func (p *Proxy) GetListener(port string) (net.Listener, error) {
+	cfg := p.config.Load()
+	portCfg, ok := cfg.Ports[port]
+	if !ok {
+		return nil, ErrProxyPortNotFound
+	}
+
+	if portCfg.ProxyProtocol != "http" && portCfg.ProxyProtocol != "tcp" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProtocol, portCfg.ProxyProtocol)
+	}
+	if portCfg.MTLS.IsEnabled() {
+		return nil, ErrUnsupportedMTLS
+	}
+
+	remotePort, err := p.client.ports.get(portKey(cfg.Hostname, port))
+	if err != nil {
+		return nil, err
+	}
+
+	l := newTunnelListener(p.log, p.client, p.client.bindAddr(remotePort))
+
+	p.mtx.Lock()
+	p.listeners = append(p.listeners, l)
+	p.mtx.Unlock()
+
+	if portCfg.AcceptProxyProtocol {
+		return proxyprotocol.NewListener(l), nil
+	}
+
+	return l, nil
+}
+
+// Reconfigure method implements proxyproviders.Reconfigurer Reconfigure
+// method, the new ports get a tunnel with the next GetListener.
+func (p *Proxy) Reconfigure(cfg *model.Config) {
+	p.config.Store(cfg)
+}
+
+// GetURL method implements proxyproviders.ProxyInterface GetURL method, the
+// URL of the first http port in the public host of the bastion.
+func (p *Proxy) GetURL() string {
+	cfg := p.config.Load()
+	for _, name := range slices.Sorted(maps.Keys(cfg.Ports)) {
+		if cfg.Ports[name].ProxyProtocol != "http" {
+			continue
+		}
+		if port, ok := p.client.ports.lookup(portKey(cfg.Hostname, name)); ok {
+			return "http://" + net.JoinHostPort(p.client.publicHost, strconv.Itoa(port))
+		}
+	}
+
+	return ""
+}
+
+// GetAuthURL method implements proxyproviders.ProxyInterface GetAuthURL
+// method, tunnels don't need authentication.
+func (p *Proxy) GetAuthURL() string {
+	return ""
+}
+
+// GetTailnet method implements proxyproviders.ProxyInterface GetTailnet
+// method, tunnels aren't in a tailnet.
+func (p *Proxy) GetTailnet() string {
+	return ""
+}
+
+// WatchEvents method implements proxyproviders.ProxyInterface WatchEvents
+// method.
+func (p *Proxy) WatchEvents() chan model.ProxyEvent {
+	return p.events
+}
+
+// Whois method implements proxyproviders.ProxyInterface Whois method, the
+// clients of the tunnels aren't identified.
+func (p *Proxy) Whois(_ *http.Request) model.Whois {
+	return model.Whois{}
+}
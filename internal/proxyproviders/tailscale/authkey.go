@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"tailscale.com/client/tailscale/v2"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+)
+
+// authKeyRenewBefore is the time before expiry a cached auth key is replaced.
+const authKeyRenewBefore = time.Hour
+
+// States of a cached auth key.
+const (
+	keyMissing keyState = iota
+	keyValid
+	keyExpiring
+	keyExpired
+)
+
+var ErrMissingTags = errors.New("must define tags to use OAuth")
+
+type (
+	keyState int
+
+	// oauth struct is an auth key created with OAuth, cached in the proxy
+	// data directory until the node logs in with it.
+	oauth struct {
+		Expires time.Time `yaml:"expires,omitempty"`
+		Authkey string    `yaml:"authkey"`
+		ID      string    `yaml:"id,omitempty"`
+	}
+
+	// keyAPI interface creates and deletes the auth keys of a control server.
+	keyAPI interface {
+		createKey(ctx context.Context, tags []string, ephemeral bool, expiry time.Duration) (*oauth, error)
+		deleteKey(ctx context.Context, key *oauth) error
+	}
+
+	// tailscaleAPI struct creates the auth keys with the Tailscale API.
+	tailscaleAPI struct {
+		client *tailscale.Client
+	}
+
+	// authKeySource struct creates the auth keys of a proxy with OAuth or
+	// the Headscale API.
+	authKeySource struct {
+		log    zerolog.Logger
+		api    keyAPI
+		file   string
+		tags   []string
+		expiry time.Duration
+		// lastReauth is the last login with a new key, shared by the
+		// proxies of a shared node
+		lastReauth time.Time
+		mtx        sync.Mutex
+		cache      bool
+		ephemeral  bool
+	}
+)
+
+// state method returns the state of the cached key at now. Keys cached by
+// older versions don't have the expiry and are considered expired.
+func (o *oauth) state(now time.Time) keyState {
+	switch {
+	case o.Authkey == "":
+		return keyMissing
+	case o.Expires.IsZero() || !now.Before(o.Expires):
+		return keyExpired
+	case o.Expires.Sub(now) < authKeyRenewBefore:
+		return keyExpiring
+	}
+
+	return keyValid
+}
+
+// get method returns the cached auth key, a new one if it's missing or
+// expires soon.
+func (s *authKeySource) get(ctx context.Context) (string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	data := new(oauth)
+	file := config.NewConfigFile(s.log, s.file, data)
+
+	if s.cache && file.Load() == nil {
+		switch data.state(time.Now()) {
+		case keyValid:
+			return data.Authkey, nil
+		case keyExpiring, keyExpired:
+			s.log.Info().Time("expires", data.Expires).Msg("Cached auth key expires, creating a new one")
+		case keyMissing:
+		}
+	}
+
+	return s.create(ctx, data, file)
+}
+
+// renew method returns a new auth key, replacing the cached one. Used when
+// the cached key was already used or rejected.
+func (s *authKeySource) renew(ctx context.Context) (string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	data := new(oauth)
+	file := config.NewConfigFile(s.log, s.file, data)
+	if s.cache {
+		_ = file.Load()
+	}
+
+	return s.create(ctx, data, file)
+}
+
+// allowReauth method returns true if a login with a new key can be tried,
+// at most once every reauthRetryInterval.
+func (s *authKeySource) allowReauth() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if time.Since(s.lastReauth) < reauthRetryInterval {
+		return false
+	}
+	s.lastReauth = time.Now()
+
+	return true
+}
+
+// used method removes the cached key after the node logged in, keys are
+// not reusable.
+func (s *authKeySource) used() {
+	if !s.cache {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := os.Remove(s.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.log.Error().Err(err).Msg("unable to remove oauth file")
+	}
+}
+
+// create method creates a new auth key, caches it in data and deletes the
+// previous one.
+func (s *authKeySource) create(ctx context.Context, data *oauth, file *config.ConfigFile) (string, error) {
+	key, err := s.api.createKey(ctx, s.tags, s.ephemeral, s.expiry)
+	if err != nil {
+		return "", err
+	}
+
+	s.log.Debug().Str("id", key.ID).Time("expires", key.Expires).Msg("Auth key created")
+
+	if data.ID != "" {
+		if err := s.api.deleteKey(ctx, data); err != nil {
+			s.log.Debug().Err(err).Str("id", data.ID).Msg("unable to delete previous auth key")
+		}
+	}
+
+	*data = *key
+
+	if s.cache {
+		if err := file.Save(); err != nil {
+			s.log.Error().Err(err).Msg("unable to save oauth file")
+		}
+	}
+
+	return key.Authkey, nil
+}
+
+// createKey method implements keyAPI createKey method. Keys created with
+// OAuth require tags.
+func (t *tailscaleAPI) createKey(ctx context.Context, tags []string, ephemeral bool, expiry time.Duration) (*oauth, error) {
+	if len(tags) == 0 {
+		return nil, ErrMissingTags
+	}
+
+	capabilities := tailscale.KeyCapabilities{}
+	capabilities.Devices.Create.Ephemeral = ephemeral
+	capabilities.Devices.Create.Reusable = false
+	capabilities.Devices.Create.Preauthorized = true
+	capabilities.Devices.Create.Tags = tags
+
+	key, err := t.client.Keys().CreateAuthKey(ctx, tailscale.CreateKeyRequest{
+		Capabilities:  capabilities,
+		ExpirySeconds: int64(expiry.Seconds()),
+		Description:   "tsdproxy",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth{Authkey: key.Key, ID: key.ID, Expires: key.Expires}, nil
+}
+
+// deleteKey method implements keyAPI deleteKey method.
+func (t *tailscaleAPI) deleteKey(ctx context.Context, key *oauth) error {
+	return t.client.Keys().Delete(ctx, key.ID)
+}
+
+// hasState function returns true if the node in dir already has a state,
+// so it's logged in and doesn't need an auth key to start.
+func hasState(dir string) bool {
+	_, err := os.Stat(path.Join(dir, "tailscaled.state"))
+	return err == nil
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+var (
+	_ proxyproviders.Certifier = (*Proxy)(nil)
+
+	ErrInvalidCertificate = errors.New("invalid certificate")
+)
+
+// Certificates method implements proxyproviders.Certifier Certificates method.
+// Certificates are read from the node cache, they are only requested if
+// missing or about to expire.
+func (p *Proxy) Certificates(ctx context.Context) ([]model.CertInfo, error) {
+	p.mtx.Lock()
+	lc := p.lc
+	p.mtx.Unlock()
+
+	if lc == nil {
+		return nil, ErrProxyNotStarted
+	}
+
+	st, err := lc.StatusWithoutPeers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]model.CertInfo, 0, len(st.CertDomains))
+
+	for _, domain := range st.CertDomains {
+		certPEM, _, err := lc.CertPair(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+
+		block, _ := pem.Decode(certPEM)
+		if block == nil {
+			return nil, ErrInvalidCertificate
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, model.CertInfo{
+			Domain:    domain,
+			Issuer:    cert.Issuer.CommonName,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		})
+	}
+
+	return certs, nil
+}
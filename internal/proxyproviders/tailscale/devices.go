@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+// deviceDeleteTimeout is the time to delete the device of a removed proxy.
+const deviceDeleteTimeout = 30 * time.Second
+
+var ErrCleanupDevicesAPI = errors.New("cleanupDevices requires OAuth or Headscale")
+
+// deviceAPI interface deletes the devices of a control server.
+type deviceAPI interface {
+	deleteDevice(ctx context.Context, self *ipnstate.PeerStatus) error
+}
+
+// deleteDevice method implements deviceAPI deleteDevice method.
+func (t *tailscaleAPI) deleteDevice(ctx context.Context, self *ipnstate.PeerStatus) error {
+	return t.client.Devices().Delete(ctx, string(self.ID))
+}
+
+// deleteDevice method implements deviceAPI deleteDevice method.
+func (h *headscaleAPI) deleteDevice(ctx context.Context, self *ipnstate.PeerStatus) error {
+	return h.deleteNode(ctx, self.PublicKey.String())
+}
+
+// Remove method implements proxyproviders.Remover Remove method. With
+// cleanupDevices, the device of the proxy is deleted from the tailnet after
+// the node is closed, with its state. Ephemeral and shared nodes are only
+// closed, the control server removes the first and other proxies use the
+// second. The state directory of the node is marked as unused, to be
+// deleted after the stateRetention of the provider.
+func (p *Proxy) Remove() error {
+	err := p.removeDevice()
+	if p.shared == nil {
+		p.states.release(p.config.Load().Hostname)
+	}
+
+	return err
+}
+
+// removeDevice method closes the node and deletes its device.
+func (p *Proxy) removeDevice() error {
+	p.mtx.Lock()
+	lc := p.lc
+	p.mtx.Unlock()
+
+	if p.devices == nil || p.shared != nil || lc == nil || p.tsServer.Ephemeral {
+		return p.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deviceDeleteTimeout)
+	defer cancel()
+
+	// the device is only known while the node is running
+	st, stErr := lc.StatusWithoutPeers(ctx)
+
+	err := p.Close()
+
+	if stErr != nil || st.Self == nil {
+		p.log.Error().Err(stErr).Msg("unable to read the device of the proxy, it's not deleted")
+		return err
+	}
+
+	if delErr := p.devices.deleteDevice(ctx, st.Self); delErr != nil {
+		p.log.Error().Err(delErr).Str("device", string(st.Self.ID)).Msg("unable to delete device from the tailnet")
+		return errors.Join(err, delErr)
+	}
+
+	p.log.Info().Str("device", string(st.Self.ID)).Msg("Device deleted from the tailnet")
+
+	// the state of a deleted device can't log in again
+	if err := os.RemoveAll(p.tsServer.Dir); err != nil {
+		p.log.Error().Err(err).Msg("unable to remove the data directory of the device")
+	}
+
+	return err
+}
@@ -0,0 +1,198 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"tailscale.com/client/local"
+	"tailscale.com/tsnet"
+)
+
+const (
+	headscaleTimeout      = 30 * time.Second
+	headscaleMaxErrorBody = 4096
+)
+
+var ErrHeadscaleUser = errors.New("headscale requires the user of the nodes")
+
+type (
+	// headscaleAPI struct is a client of the Headscale REST API. It creates
+	// the pre-authorized keys of the nodes, so they don't need to be
+	// registered, and deletes the ephemeral nodes when they are closed.
+	headscaleAPI struct {
+		client *http.Client
+		url    string
+		apiKey string
+		user   string
+	}
+
+	// headscaleID is an ID of the Headscale API, a uint64 encoded as a
+	// string or a number.
+	headscaleID string
+
+	headscalePreAuthKey struct {
+		Expiration time.Time   `json:"expiration"`
+		ID         headscaleID `json:"id"`
+		Key        string      `json:"key"`
+	}
+
+	headscaleNode struct {
+		ID      headscaleID `json:"id"`
+		NodeKey string      `json:"nodeKey"`
+	}
+)
+
+func newHeadscaleAPI(controlURL, apiKey, user string) *headscaleAPI {
+	return &headscaleAPI{
+		client: &http.Client{Timeout: headscaleTimeout},
+		url:    strings.TrimSuffix(controlURL, "/") + "/api/v1",
+		apiKey: apiKey,
+		user:   user,
+	}
+}
+
+// createKey method implements keyAPI createKey method.
+func (h *headscaleAPI) createKey(ctx context.Context, tags []string, ephemeral bool, expiry time.Duration) (*oauth, error) {
+	req := struct {
+		Expiration time.Time `json:"expiration"`
+		User       string    `json:"user"`
+		ACLTags    []string  `json:"aclTags,omitempty"`
+		Reusable   bool      `json:"reusable"`
+		Ephemeral  bool      `json:"ephemeral"`
+	}{
+		Expiration: time.Now().Add(expiry).UTC(),
+		User:       h.user,
+		ACLTags:    tags,
+		Ephemeral:  ephemeral,
+	}
+
+	var resp struct {
+		PreAuthKey headscalePreAuthKey `json:"preAuthKey"`
+	}
+	if err := h.do(ctx, http.MethodPost, "/preauthkey", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return &oauth{
+		Authkey: resp.PreAuthKey.Key,
+		ID:      string(resp.PreAuthKey.ID),
+		Expires: resp.PreAuthKey.Expiration,
+	}, nil
+}
+
+// deleteKey method implements keyAPI deleteKey method. Headscale doesn't
+// delete keys, so the key is expired.
+func (h *headscaleAPI) deleteKey(ctx context.Context, key *oauth) error {
+	req := struct {
+		User string `json:"user"`
+		Key  string `json:"key"`
+	}{
+		User: h.user,
+		Key:  key.Authkey,
+	}
+
+	return h.do(ctx, http.MethodPost, "/preauthkey/expire", req, nil)
+}
+
+// deleteNode method deletes the node with the node key.
+func (h *headscaleAPI) deleteNode(ctx context.Context, nodeKey string) error {
+	var resp struct {
+		Nodes []headscaleNode `json:"nodes"`
+	}
+	if err := h.do(ctx, http.MethodGet, "/node", nil, &resp); err != nil {
+		return err
+	}
+
+	for _, node := range resp.Nodes {
+		if node.NodeKey == nodeKey {
+			return h.do(ctx, http.MethodDelete, "/node/"+url.PathEscape(string(node.ID)), nil, nil)
+		}
+	}
+
+	return nil
+}
+
+// closeServer method closes a tsnet server. Ephemeral nodes are deleted
+// from Headscale, instead of waiting for its inactivity timeout. lc is the
+// local client of the started server, nil if it wasn't started.
+func (h *headscaleAPI) closeServer(log zerolog.Logger, server *tsnet.Server, lc *local.Client) error {
+	if h == nil || lc == nil || !server.Ephemeral {
+		return server.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), headscaleTimeout)
+	defer cancel()
+
+	var nodeKey string
+	if st, err := lc.StatusWithoutPeers(ctx); err == nil && st.Self != nil {
+		nodeKey = st.Self.PublicKey.String()
+	}
+
+	err := server.Close()
+
+	if nodeKey != "" {
+		if err := h.deleteNode(ctx, nodeKey); err != nil {
+			log.Error().Err(err).Msg("unable to delete ephemeral node from headscale")
+		} else {
+			log.Info().Msg("Ephemeral node deleted from headscale")
+		}
+	}
+
+	return err
+}
+
+// do method sends a request to the API and decodes the response to v.
+func (h *headscaleAPI) do(ctx context.Context, method, path string, body, v any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.url+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, headscaleMaxErrorBody))
+		return fmt.Errorf("headscale api returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// UnmarshalJSON method implements json.Unmarshaler UnmarshalJSON method.
+func (id *headscaleID) UnmarshalJSON(b []byte) error {
+	*id = headscaleID(strings.Trim(string(b), `"`))
+	return nil
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+var (
+	ErrInvalidCABundle      = errors.New("no certificates found in CA bundle")
+	ErrClientCertNotAllowed = errors.New("client certificate not allowed")
+)
+
+// newMTLSConfig function returns a TLS configuration that requires client
+// certificates signed by the configured CA bundle.
+func newMTLSConfig(cfg model.MTLS, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	bundle, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, ErrInvalidCABundle
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		ClientCAs:      pool,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if len(cfg.AllowedNames) > 0 {
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 || !isAllowedCertificate(cs.PeerCertificates[0], cfg.AllowedNames) {
+				return ErrClientCertNotAllowed
+			}
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// isAllowedCertificate function returns true if the certificate CN or any SAN is in the allowlist.
+func isAllowedCertificate(cert *x509.Certificate, allowed []string) bool {
+	if slices.Contains(allowed, cert.Subject.CommonName) {
+		return true
+	}
+
+	names := slices.Concat(cert.DNSNames, cert.EmailAddresses)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	for _, name := range names {
+		if slices.Contains(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+)
+
+var (
+	_ proxyproviders.Describer = (*Proxy)(nil)
+
+	ErrProxyNotStarted = errors.New("proxy not started")
+)
+
+// NodeInfo method implements proxyproviders.Describer NodeInfo method.
+func (p *Proxy) NodeInfo(ctx context.Context) (model.NodeInfo, error) {
+	p.mtx.Lock()
+	lc := p.lc
+	p.mtx.Unlock()
+
+	if lc == nil {
+		return model.NodeInfo{}, ErrProxyNotStarted
+	}
+
+	st, err := lc.StatusWithoutPeers(ctx)
+	if err != nil {
+		return model.NodeInfo{}, err
+	}
+	if st.Self == nil {
+		return model.NodeInfo{}, ErrProxyNotStarted
+	}
+
+	info := model.NodeInfo{
+		DNSName:   strings.TrimSuffix(st.Self.DNSName, "."),
+		KeyExpiry: st.Self.KeyExpiry,
+		Online:    st.Self.Online,
+	}
+	for _, ip := range st.Self.TailscaleIPs {
+		info.IPs = append(info.IPs, ip.String())
+	}
+
+	return info, nil
+}
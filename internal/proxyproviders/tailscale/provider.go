@@ -5,71 +5,250 @@ package tailscale
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
 
 	"github.com/rs/zerolog"
 	"tailscale.com/client/tailscale/v2"
+	"tailscale.com/ipn/store/mem"
 	"tailscale.com/tsnet"
 )
 
+// ReadOnlyWarning is shown when the data directory can't be written.
+const ReadOnlyWarning = "Tailscale data directory %s is read-only: proxies use ephemeral nodes " +
+	"with in-memory state and are registered again on every restart. Check the /data volume mount."
+
 type (
 	// Client struct implements proxyprovider for tailscale
 	Client struct {
 		log zerolog.Logger
 
-		Hostname     string
-		AuthKey      string
-		clientID     string
-		clientSecret string
-		controlURL   string
-		datadir      string
-		tags         string
-	}
-
-	oauth struct {
-		Authkey string `yaml:"authkey"`
+		// keyAPI creates auth keys with OAuth or Headscale, nil without
+		// their credentials
+		keyAPI keyAPI
+		// headscale deletes the ephemeral nodes, nil without Headscale
+		headscale *headscaleAPI
+		// devices deletes the devices of removed proxies, nil without
+		// cleanupDevices
+		devices deviceAPI
+
+		// shared is the node of all proxies in shared node mode
+		shared *sharedNode
+		// states tracks the state directories of the nodes, nil with a
+		// read-only data directory
+		states *stateDirs
+
+		Hostname      string
+		AuthKey       string
+		controlURL    string
+		datadir       string
+		tags          string
+		sharedNode    string
+		authKeyExpiry time.Duration
+		readOnly      bool
+		memoryState   bool
+		sharedMtx     sync.Mutex
 	}
 )
 
-var _ proxyproviders.Provider = (*Client)(nil)
+var (
+	_ proxyproviders.Provider      = (*Client)(nil)
+	_ proxyproviders.Warner        = (*Client)(nil)
+	_ proxyproviders.StateReporter = (*Client)(nil)
+)
 
 func New(log zerolog.Logger, name string, provider *config.TailscaleServerConfig) (*Client, error) {
+	log = core.ModuleLogger(log, "tailscale").With().Str("tailscale", name).Logger()
 	datadir := filepath.Join(config.Config.Tailscale.DataDir, name)
 
-	return &Client{
-		log:          log.With().Str("tailscale", name).Logger(),
-		Hostname:     name,
-		AuthKey:      strings.TrimSpace(provider.AuthKey),
-		clientID:     strings.TrimSpace(provider.ClientID),
-		clientSecret: strings.TrimSpace(provider.ClientSecret),
-		tags:         strings.TrimSpace(provider.Tags),
-		datadir:      datadir,
-		controlURL:   provider.ControlURL,
-	}, nil
+	// keep proxies working with misconfigured mounts instead of failing on first write
+	readOnly := !isWritable(datadir)
+	if readOnly {
+		log.Warn().Msgf(ReadOnlyWarning, datadir)
+	}
+
+	c := &Client{
+		log:           log,
+		Hostname:      name,
+		AuthKey:       strings.TrimSpace(provider.AuthKey),
+		tags:          strings.TrimSpace(provider.Tags),
+		datadir:       datadir,
+		controlURL:    provider.ControlURL,
+		sharedNode:    provider.SharedNode,
+		authKeyExpiry: provider.AuthKeyExpiry,
+		readOnly:      readOnly,
+		memoryState:   provider.EphemeralMemoryState,
+	}
+
+	if !readOnly {
+		c.states = newStateDirs(log, datadir, provider.StateRetention)
+	}
+
+	clientID := strings.TrimSpace(provider.ClientID)
+	clientSecret := strings.TrimSpace(provider.ClientSecret)
+
+	switch {
+	case provider.Headscale.APIKey != "":
+		if provider.Headscale.User == "" {
+			return nil, ErrHeadscaleUser
+		}
+		c.headscale = newHeadscaleAPI(provider.ControlURL, provider.Headscale.APIKey, provider.Headscale.User)
+		c.keyAPI = c.headscale
+		if provider.CleanupDevices {
+			c.devices = c.headscale
+		}
+
+	case clientID != "" && clientSecret != "":
+		api := &tailscaleAPI{
+			client: &tailscale.Client{
+				Tailnet:   "-",
+				UserAgent: "tsdproxy",
+				HTTP: tailscale.OAuthConfig{
+					ClientID:     clientID,
+					ClientSecret: clientSecret,
+					Scopes:       []string{"all:write"},
+				}.HTTPClient(),
+			},
+		}
+		c.keyAPI = api
+		if provider.CleanupDevices {
+			c.devices = api
+		}
+
+	case provider.CleanupDevices:
+		return nil, ErrCleanupDevicesAPI
+	}
+
+	return c, nil
+}
+
+// Warnings method implements proxyproviders.Warner Warnings method.
+func (c *Client) Warnings() []string {
+	if !c.readOnly {
+		return nil
+	}
+
+	return []string{fmt.Sprintf(ReadOnlyWarning, c.datadir)}
+}
+
+// StateDirs method implements proxyproviders.StateReporter StateDirs method.
+func (c *Client) StateDirs() ([]model.StateDir, error) {
+	if c.states == nil {
+		return nil, nil
+	}
+
+	return c.states.list()
 }
 
 // NewProxy method implements proxyprovider NewProxy method
 func (c *Client) NewProxy(config *model.Config) (proxyproviders.ProxyInterface, error) {
+	log := c.log.With().Str("Hostname", config.Hostname).Logger()
+
+	if c.sharedNode != "" {
+		node, err := c.getSharedNode(config.Hostname)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &Proxy{
+			log:       log,
+			tsServer:  node.server,
+			keys:      node.keys,
+			headscale: c.headscale,
+			devices:   c.devices,
+			states:    c.states,
+			shared:    node,
+			events:    make(chan model.ProxyEvent),
+		}
+		p.config.Store(config)
+
+		return p, nil
+	}
+
+	tserver, keys := c.newServer(config, log)
+	c.states.use(config.Hostname)
+
+	p := &Proxy{
+		log:       log,
+		tsServer:  tserver,
+		keys:      keys,
+		headscale: c.headscale,
+		devices:   c.devices,
+		states:    c.states,
+		events:    make(chan model.ProxyEvent),
+		newServer: func() (*tsnet.Server, *authKeySource) {
+			return c.newServer(config, log)
+		},
+	}
+	p.config.Store(config)
+
+	return p, nil
+}
+
+// getSharedNode method returns the shared node with the proxy added, a new
+// one if the previous was closed with its last proxy.
+func (c *Client) getSharedNode(name string) (*sharedNode, error) {
+	c.sharedMtx.Lock()
+	defer c.sharedMtx.Unlock()
+
+	if c.shared != nil && c.shared.acquire(name) {
+		return c.shared, nil
+	}
+
+	// the node uses the provider configuration, not the proxy one
+	config, err := model.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Hostname = c.sharedNode
+
+	log := c.log.With().Str("Hostname", c.sharedNode).Logger()
+	log.Info().Msg("Setting up shared tailscale server")
+
+	tserver, keys := c.newServer(config, log)
+	c.states.use(c.sharedNode)
+
+	c.shared = newSharedNode(log, tserver, keys, c.headscale)
+	c.shared.acquire(name)
+
+	return c.shared, nil
+}
+
+// newServer method returns the tsnet server of a node, with its auth key
+// source if any.
+func (c *Client) newServer(config *model.Config, log zerolog.Logger) (*tsnet.Server, *authKeySource) {
 	c.log.Debug().
 		Str("hostname", config.Hostname).
 		Msg("Setting up tailscale server")
 
-	log := c.log.With().Str("Hostname", config.Hostname).Logger()
-
 	datadir := path.Join(c.datadir, config.Hostname)
-	authKey := c.getAuthkey(config, datadir)
+	ephemeral := config.Tailscale.Ephemeral
+
+	// tsnet still needs a writable directory for its logs
+	if c.readOnly {
+		datadir = path.Join(os.TempDir(), "tsdproxy", c.Hostname, config.Hostname)
+		ephemeral = true
+	}
+
+	keys := c.newAuthKeySource(config, datadir, ephemeral)
+	authKey := c.getAuthkey(config, keys, datadir)
 
 	tserver := &tsnet.Server{
 		Hostname:     config.Hostname,
 		AuthKey:      authKey,
 		Dir:          datadir,
-		Ephemeral:    config.Tailscale.Ephemeral,
+		Ephemeral:    ephemeral,
 		RunWebClient: config.Tailscale.RunWebClient,
 		UserLogf: func(format string, args ...any) {
 			log.Info().Msgf(format, args...)
@@ -78,7 +257,13 @@ func (c *Client) NewProxy(config *model.Config) (proxyproviders.ProxyInterface,
 			log.Trace().Msgf(format, args...)
 		},
 
-		ControlURL: c.getControlURL(),
+		ControlURL: c.getControlURL(config),
+	}
+
+	// ephemeral nodes are registered again on every start, their state
+	// isn't needed after they're closed
+	if c.readOnly || (ephemeral && c.memoryState) {
+		tserver.Store = new(mem.Store)
 	}
 
 	// if verbose is set, use the info log level
@@ -88,27 +273,32 @@ func (c *Client) NewProxy(config *model.Config) (proxyproviders.ProxyInterface,
 		}
 	}
 
-	return &Proxy{
-		log:      log,
-		config:   config,
-		tsServer: tserver,
-		events:   make(chan model.ProxyEvent),
-	}, nil
+	return tserver, keys
 }
 
-// getControlURL method returns the control URL
-func (c *Client) getControlURL() string {
+// getControlURL method returns the control URL, the proxy configuration
+// overrides the provider one.
+func (c *Client) getControlURL(cfg *model.Config) string {
+	if cfg.Tailscale.ControlURL != "" {
+		return cfg.Tailscale.ControlURL
+	}
 	if c.controlURL == "" {
 		return model.DefaultTailscaleControlURL
 	}
 	return c.controlURL
 }
 
-func (c *Client) getAuthkey(config *model.Config, path string) string {
+// getAuthkey method returns the auth key of a proxy: the proxy one, one
+// created with OAuth or Headscale, or the provider one. Nodes already logged in don't need
+// a key, so none is created for them.
+func (c *Client) getAuthkey(config *model.Config, keys *authKeySource, dir string) string {
 	authKey := config.Tailscale.AuthKey
 
-	if c.clientID != "" && c.clientSecret != "" {
-		authKey = c.getOAuth(config, path)
+	if keys != nil && !(keys.cache && hasState(dir)) {
+		var err error
+		if authKey, err = keys.get(context.Background()); err != nil {
+			c.log.Error().Err(err).Msg("unable to get auth key")
+		}
 	}
 
 	if authKey == "" {
@@ -117,26 +307,11 @@ func (c *Client) getAuthkey(config *model.Config, path string) string {
 	return authKey
 }
 
-func (c *Client) getOAuth(cfg *model.Config, dir string) string {
-	data := new(oauth)
-
-	file := config.NewConfigFile(c.log, path.Join(dir, "tsdproxy.yaml"), data)
-	if err := file.Load(); err == nil {
-		if data.Authkey != "" {
-			return data.Authkey
-		}
-	}
-
-	ctx := context.Background()
-
-	tsclient := &tailscale.Client{
-		Tailnet:   "-",
-		UserAgent: "tsdproxy",
-		HTTP: tailscale.OAuthConfig{
-			ClientID:     c.clientID,
-			ClientSecret: c.clientSecret,
-			Scopes:       []string{"all:write"},
-		}.HTTPClient(),
+// newAuthKeySource method returns the auth key source of a proxy, nil
+// without OAuth or Headscale credentials.
+func (c *Client) newAuthKeySource(cfg *model.Config, dir string, ephemeral bool) *authKeySource {
+	if c.keyAPI == nil {
+		return nil
 	}
 
 	temptags := strings.Trim(strings.TrimSpace(cfg.Tailscale.Tags), "\"")
@@ -144,32 +319,35 @@ func (c *Client) getOAuth(cfg *model.Config, dir string) string {
 		temptags = strings.Trim(strings.TrimSpace(c.tags), "\"")
 	}
 
-	if temptags == "" {
-		c.log.Error().Msg("must define tags to use OAuth")
-		return ""
+	var tags []string
+	if temptags != "" {
+		tags = strings.Split(temptags, ",")
 	}
 
-	capabilities := tailscale.KeyCapabilities{}
-	capabilities.Devices.Create.Ephemeral = cfg.Tailscale.Ephemeral
-	capabilities.Devices.Create.Reusable = false
-	capabilities.Devices.Create.Preauthorized = true
-	capabilities.Devices.Create.Tags = strings.Split(temptags, ",")
-
-	ckr := tailscale.CreateKeyRequest{
-		Capabilities: capabilities,
-		Description:  "tsdproxy",
+	return &authKeySource{
+		log:       c.log.With().Str("Hostname", cfg.Hostname).Logger(),
+		api:       c.keyAPI,
+		file:      path.Join(dir, "tsdproxy.yaml"),
+		tags:      tags,
+		expiry:    c.authKeyExpiry,
+		ephemeral: ephemeral,
+		// without persistent state, each node needs a new key
+		cache: !c.readOnly,
 	}
+}
 
-	authkey, err := tsclient.Keys().Create(ctx, ckr)
-	if err != nil {
-		c.log.Error().Err(err).Msg("unable to get Oauth token")
-		return ""
+// isWritable function returns true if files can be created in dir.
+func isWritable(dir string) bool {
+	if err := os.MkdirAll(dir, consts.PermOwnerAll); err != nil {
+		return false
 	}
 
-	data.Authkey = authkey.Key
-	if err := file.Save(); err != nil {
-		c.log.Error().Err(err).Msg("unable to save oauth file")
+	f, err := os.CreateTemp(dir, ".tsdproxy-write-test-*")
+	if err != nil {
+		return false
 	}
+	f.Close()
+	os.Remove(f.Name())
 
-	return authkey.Key
+	return true
 }
@@ -5,45 +5,98 @@ package tailscale
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyprotocol"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
 
 	"github.com/rs/zerolog"
 	"tailscale.com/client/local"
 	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
 	"tailscale.com/tsnet"
 )
 
 // Proxy struct implements proxyconfig.Proxy.
 type Proxy struct {
 	log      zerolog.Logger
-	config   *model.Config
+	config   atomic.Pointer[model.Config]
 	tsServer *tsnet.Server
 	lc       *local.Client
 	ctx      context.Context
+	// keys creates auth keys to log in again, nil without OAuth or Headscale
+	keys *authKeySource
+	// headscale deletes the ephemeral node on close, nil without Headscale
+	headscale *headscaleAPI
+	// devices deletes the device when the proxy is removed, nil without
+	// cleanupDevices
+	devices deviceAPI
+	// states marks the state directory as unused when the proxy is removed
+	states *stateDirs
+	// shared is the node shared with other proxies, nil if the proxy has
+	// its own node
+	shared *sharedNode
+	// newServer creates a new node to recover from a corrupted state, nil
+	// for shared nodes
+	newServer func() (*tsnet.Server, *authKeySource)
+	// serveConfig is the serve config of the path handlers of the ports,
+	// nil until the first one is added
+	serveConfig *ipn.ServeConfig
+	// serveAddrs are the loopback addresses of the path handlers
+	serveAddrs map[string]struct{}
 
 	events chan model.ProxyEvent
 
 	authURL string
 	url     string
-	status  model.ProxyStatus
+	tailnet string
+	// recovered is the directory the corrupted state was moved to, if any
+	recovered string
+	status    model.ProxyStatus
 
-	mtx sync.Mutex
+	mtx      sync.Mutex
+	serveMtx sync.Mutex
 }
 
 var (
-	_ proxyproviders.ProxyInterface = (*Proxy)(nil)
+	_ proxyproviders.ProxyInterface  = (*Proxy)(nil)
+	_ proxyproviders.Remover         = (*Proxy)(nil)
+	_ proxyproviders.StateRecoverer  = (*Proxy)(nil)
+	_ proxyproviders.ClientAddresser = (*Proxy)(nil)
+	_ proxyproviders.Reconfigurer    = (*Proxy)(nil)
 
 	ErrProxyPortNotFound = errors.New("proxy port not found")
+	ErrFunnelIPFamily    = errors.New("funnel ports listen on both IPv4 and IPv6")
+	ErrFunnelProxy       = errors.New("funnel ports can't accept PROXY protocol")
 )
 
+// reauthRetryInterval is the minimum time between logins with a new auth key,
+// after that the auth URL is shown.
+const reauthRetryInterval = 5 * time.Minute
+
+// FunnelUnavailableError is returned when the tailnet or node doesn't allow Funnel.
+type FunnelUnavailableError struct {
+	Err  error
+	Port int
+}
+
+func (e *FunnelUnavailableError) Error() string {
+	return "funnel not enabled for this tailnet/node on port " + strconv.Itoa(e.Port) + ": " + e.Err.Error()
+}
+
+func (e *FunnelUnavailableError) Unwrap() error {
+	return e.Err
+}
+
 // Start method implements proxyconfig.Proxy Start method.
 func (p *Proxy) Start(ctx context.Context) error {
 	var (
@@ -51,7 +104,7 @@ func (p *Proxy) Start(ctx context.Context) error {
 		lc  *local.Client
 	)
 
-	if err = p.tsServer.Start(); err != nil {
+	if err = p.startRecovering(); err != nil {
 		return err
 	}
 
@@ -75,33 +128,126 @@ func (p *Proxy) GetURL() string {
 }
 
 // Close method implements proxyconfig.Proxy Close method.
+// Shared nodes are closed with their last proxy.
 func (p *Proxy) Close() error {
+	p.mtx.Lock()
+	lc := p.lc
+	p.mtx.Unlock()
+
+	if p.shared != nil {
+		return p.shared.release(p.config.Load().Hostname, lc)
+	}
 	if p.tsServer != nil {
-		return p.tsServer.Close()
+		return p.headscale.closeServer(p.log, p.tsServer, lc)
 	}
 
 	return nil
 }
 
+// Reconfigure method implements proxyproviders.Reconfigurer Reconfigure
+// method, the new ports are listened with the next GetListener.
+func (p *Proxy) Reconfigure(cfg *model.Config) {
+	p.config.Store(cfg)
+}
+
 func (p *Proxy) GetListener(port string) (net.Listener, error) {
-	portCfg, ok := p.config.Ports[port]
+	cfg := p.config.Load()
+	portCfg, ok := cfg.Ports[port]
 	if !ok {
 		return nil, ErrProxyPortNotFound
 	}
 
+	// ports with a path share the HTTPS port of the node serve config
+	if portCfg.Tailscale.Path != "" {
+		return p.listenServe(portCfg)
+	}
+
 	network := portCfg.ProxyProtocol
 	if portCfg.ProxyProtocol == "http" || portCfg.ProxyProtocol == "https" {
 		network = "tcp"
 	}
+	// tcp4, tcp6, udp4 or udp6 for ports of a single address family
+	network += portCfg.NetworkSuffix()
 	addr := ":" + strconv.Itoa(portCfg.ProxyPort)
 
+	// ports with client certificates use their own TLS configuration
+	var mtlsConfig *tls.Config
+	if portCfg.MTLS.IsEnabled() {
+		var err error
+		if mtlsConfig, err = newMTLSConfig(portCfg.MTLS, p.lc.GetCertificate); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.shared == nil {
+		return p.listen(portCfg, network, addr, mtlsConfig)
+	}
+
+	// HTTP connections are routed to the proxies by the Host header
+	route := mtlsConfig == nil && (portCfg.ProxyProtocol == "http" || portCfg.ProxyProtocol == "https")
+	key := portCfg.ProxyProtocol + portCfg.NetworkSuffix() + addr
 	if portCfg.Tailscale.Funnel {
+		key += "/funnel"
+	}
+
+	return p.shared.listen(cfg.Hostname, key, route, func() (net.Listener, error) {
+		return p.listen(portCfg, network, addr, mtlsConfig)
+	})
+}
+
+// listen method returns a listener of the node.
+func (p *Proxy) listen(portCfg model.PortConfig, network, addr string, mtlsConfig *tls.Config) (net.Listener, error) {
+	if portCfg.Tailscale.Funnel {
+		if portCfg.NetworkSuffix() != "" {
+			return nil, ErrFunnelIPFamily
+		}
+		if portCfg.AcceptProxyProtocol {
+			return nil, ErrFunnelProxy
+		}
+		if err := p.checkFunnel(portCfg.ProxyPort); err != nil {
+			return nil, err
+		}
+		if mtlsConfig != nil {
+			return p.tsServer.ListenFunnel(network, addr, tsnet.FunnelTLSConfig(mtlsConfig))
+		}
 		return p.tsServer.ListenFunnel(network, addr)
 	}
-	if portCfg.ProxyProtocol == "https" {
+	if portCfg.ProxyProtocol == "https" && mtlsConfig == nil && network == "tcp" && !portCfg.AcceptProxyProtocol {
 		return p.tsServer.ListenTLS(network, addr)
 	}
-	return p.tsServer.Listen(network, addr)
+
+	l, err := p.tsServer.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	// the header is read before the TLS handshake
+	if portCfg.AcceptProxyProtocol {
+		l = proxyprotocol.NewListener(l)
+	}
+
+	if mtlsConfig != nil {
+		return tls.NewListener(l, mtlsConfig), nil
+	}
+	// ListenTLS only supports dual-stack listeners without PROXY protocol
+	if portCfg.ProxyProtocol == "https" {
+		return tls.NewListener(l, &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: p.lc.GetCertificate}), nil
+	}
+	return l, nil
+}
+
+// checkFunnel method verifies the Funnel prerequisites (HTTPS enabled, funnel
+// node attribute and allowed port) before creating a Funnel listener.
+func (p *Proxy) checkFunnel(port int) error {
+	st, err := p.tsServer.Up(p.ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ipn.CheckFunnelAccess(uint16(port), st.Self); err != nil { //nolint:gosec
+		return &FunnelUnavailableError{Port: port, Err: err}
+	}
+
+	return nil
 }
 
 func (p *Proxy) WatchEvents() chan model.ProxyEvent {
@@ -112,8 +258,21 @@ func (p *Proxy) GetAuthURL() string {
 	return p.authURL
 }
 
+// GetTailnet method returns the name of the tailnet the proxy is connected to.
+func (p *Proxy) GetTailnet() string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.tailnet
+}
+
 func (p *Proxy) Whois(r *http.Request) model.Whois {
-	who, err := p.lc.WhoIs(r.Context(), r.RemoteAddr)
+	addr := r.RemoteAddr
+	if client, ok := p.ClientAddr(r); ok {
+		addr = client
+	}
+
+	who, err := p.lc.WhoIs(r.Context(), addr)
 	if err != nil {
 		return model.Whois{}
 	}
@@ -156,26 +315,79 @@ func (p *Proxy) watchStatus() {
 
 		switch status.BackendState {
 		case "NeedsLogin":
+			if (status.AuthURL != "" || nodeKeyExpired(status)) && p.reauth() {
+				continue
+			}
 			if status.AuthURL != "" {
 				p.setStatus(model.ProxyStatusAuthenticating, "", status.AuthURL)
 			}
 		case "Starting":
 			p.setStatus(model.ProxyStatusStarting, "", "")
 		case "Running":
+			if status.CurrentTailnet != nil {
+				p.mtx.Lock()
+				p.tailnet = status.CurrentTailnet.Name
+				p.mtx.Unlock()
+			}
+			if p.status != model.ProxyStatusRunning && p.keys != nil {
+				p.keys.used()
+			}
 			p.setStatus(model.ProxyStatusRunning, strings.TrimRight(status.Self.DNSName, "."), "")
 			if p.status != model.ProxyStatusRunning {
 				p.getTLSCertificates()
+				p.resetServe()
 			}
 		}
 	}
 }
 
+// reauth method logs the node in again with a new OAuth auth key instead of
+// waiting for an interactive login, like when its node key expired. It
+// returns false without OAuth, on errors or if the last attempt was recent.
+func (p *Proxy) reauth() bool {
+	if p.keys == nil || !p.keys.allowReauth() {
+		return false
+	}
+
+	p.log.Info().Msg("Proxy needs login, authenticating with a new auth key")
+
+	key, err := p.keys.renew(p.ctx)
+	if err != nil {
+		p.log.Error().Err(err).Msg("unable to create auth key")
+		return false
+	}
+
+	if err := p.lc.Start(p.ctx, ipn.Options{AuthKey: key}); err != nil {
+		p.log.Error().Err(err).Msg("unable to start login with auth key")
+		return false
+	}
+	if err := p.lc.StartLoginInteractive(p.ctx); err != nil {
+		p.log.Error().Err(err).Msg("unable to start login with auth key")
+		return false
+	}
+
+	return true
+}
+
+// nodeKeyExpired function returns true if the node key of the proxy expired.
+func nodeKeyExpired(status *ipnstate.Status) bool {
+	if status.Self == nil {
+		return false
+	}
+
+	return status.Self.Expired || status.Self.KeyExpiry != nil && !status.Self.KeyExpiry.After(time.Now())
+}
+
 func (p *Proxy) setStatus(status model.ProxyStatus, url string, authURL string) {
 	if p.status == status && p.url == url && p.authURL == authURL {
 		return
 	}
 
-	p.log.Debug().Str("authURL", url).Str("status", status.String()).Msg("tailscale status")
+	p.log.Debug().Str("url", url).Str("authURL", authURL).Str("status", status.String()).Msg("tailscale status")
+
+	if authURL != "" && authURL != p.authURL {
+		p.log.Info().Str("authURL", authURL).Msg("Proxy needs authentication, open the auth URL to add it to the tailnet")
+	}
 
 	p.mtx.Lock()
 	p.status = status
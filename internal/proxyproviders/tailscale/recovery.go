@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// quarantineSuffix is added to the state directories moved away, with the
+// time they were moved.
+const quarantineSuffix = ".corrupted-"
+
+// stateErrors are the messages of the tsnet errors caused by corrupted state
+// files, tsnet doesn't wrap all of them.
+var stateErrors = []string{
+	"unmarshaling known profiles",
+	"logpolicy.LoadConfig",
+	"logpolicy.Config.Validate",
+	"unexpected end of JSON input",
+	"invalid character",
+}
+
+// startRecovering method starts the tsnet server. When it fails because the
+// state of the node is corrupted, usually after an unclean shutdown, the
+// state directory is moved away and a new node is started with the auth key
+// of the proxy.
+func (p *Proxy) startRecovering() error {
+	err := p.tsServer.Start()
+	if err == nil || p.newServer == nil || !isStateCorrupted(err) {
+		return err
+	}
+
+	dir := p.tsServer.Dir
+	p.log.Warn().Err(err).Str("dir", dir).Msg("Tailscale state is corrupted, starting a new node")
+
+	quarantine := dir + quarantineSuffix + time.Now().Format("20060102-150405")
+	if err := os.Rename(dir, quarantine); err != nil {
+		return fmt.Errorf("moving corrupted state: %w", err)
+	}
+
+	// tsnet servers can't be started again, without the state the new
+	// one logs in with the auth key of the proxy or a new OAuth key
+	tserver, keys := p.newServer()
+
+	p.mtx.Lock()
+	p.tsServer = tserver
+	p.keys = keys
+	p.recovered = quarantine
+	p.mtx.Unlock()
+
+	p.log.Info().Str("quarantine", quarantine).Msg("Corrupted tailscale state moved")
+
+	return p.tsServer.Start()
+}
+
+// RecoveredState method implements proxyproviders.StateRecoverer
+// RecoveredState method.
+func (p *Proxy) RecoveredState() string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.recovered
+}
+
+// isStateCorrupted function returns true if the tsnet start error is caused
+// by a state file that can't be read.
+func isStateCorrupted(err error) bool {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, s := range stateErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"tailscale.com/ipn"
+)
+
+var (
+	ErrServeProtocol   = errors.New("tailscale path requires a dual-stack https port without client certificates or PROXY protocol")
+	ErrServeSharedNode = errors.New("tailscale path is not supported on shared nodes")
+	ErrServePathInUse  = errors.New("tailscale path already in use on the port")
+)
+
+// serveListener struct is the loopback listener of a port with a path
+// handler in the serve config of the node. The node terminates TLS and
+// proxies the requests of the path to it.
+type serveListener struct {
+	net.Listener
+	proxy *Proxy
+	host  string
+	path  string
+	port  uint16
+	once  sync.Once
+}
+
+// listenServe method adds a path handler of the port to the serve config of
+// the node and returns the listener of its requests.
+func (p *Proxy) listenServe(portCfg model.PortConfig) (net.Listener, error) {
+	if p.shared != nil {
+		return nil, ErrServeSharedNode
+	}
+	if portCfg.ProxyProtocol != "https" || portCfg.MTLS.IsEnabled() || portCfg.NetworkSuffix() != "" ||
+		portCfg.AcceptProxyProtocol {
+		return nil, ErrServeProtocol
+	}
+	if portCfg.Tailscale.Funnel {
+		if err := p.checkFunnel(portCfg.ProxyPort); err != nil {
+			return nil, err
+		}
+	}
+
+	st, err := p.tsServer.Up(p.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	host := strings.TrimSuffix(st.Self.DNSName, ".")
+	port := uint16(portCfg.ProxyPort) //nolint:gosec
+	path := portCfg.Tailscale.Path
+
+	p.serveMtx.Lock()
+	defer p.serveMtx.Unlock()
+
+	// the serve config is rebuilt from the ports of this run, handlers of
+	// previous runs point to closed listeners
+	if p.serveConfig == nil {
+		p.serveConfig = new(ipn.ServeConfig)
+		p.serveAddrs = make(map[string]struct{})
+	}
+
+	hp := ipn.HostPort(net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if p.serveConfig.WebHandlerExists(hp, path) {
+		return nil, ErrServePathInUse
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p.serveConfig.SetWebHandler(&ipn.HTTPHandler{Proxy: "http://" + l.Addr().String()}, host, port, path, true)
+	if portCfg.Tailscale.Funnel {
+		p.serveConfig.SetFunnel(host, port, true)
+	}
+
+	if err := p.lc.SetServeConfig(p.ctx, p.serveConfig); err != nil {
+		p.serveConfig.RemoveWebHandler(host, port, []string{path}, true)
+		l.Close()
+		return nil, err
+	}
+	p.serveAddrs[l.Addr().String()] = struct{}{}
+
+	p.log.Info().Str("path", path).Int("port", int(port)).Msg("Path handler added to serve config")
+
+	return &serveListener{
+		Listener: l,
+		proxy:    p,
+		host:     host,
+		path:     path,
+		port:     port,
+	}, nil
+}
+
+// Close method removes the path handler from the serve config and closes
+// the listener.
+func (l *serveListener) Close() error {
+	l.once.Do(func() {
+		l.proxy.removeServe(l)
+	})
+
+	return l.Listener.Close()
+}
+
+// removeServe method removes the path handler of a listener from the serve
+// config of the node, Funnel is turned off with the last handler of a port.
+func (p *Proxy) removeServe(l *serveListener) {
+	p.serveMtx.Lock()
+	defer p.serveMtx.Unlock()
+
+	delete(p.serveAddrs, l.Addr().String())
+	p.serveConfig.RemoveWebHandler(l.host, l.port, []string{l.path}, true)
+
+	if err := p.lc.SetServeConfig(p.ctx, p.serveConfig); err != nil {
+		p.log.Error().Err(err).Str("path", l.path).Msg("Error removing path handler from serve config")
+	}
+}
+
+// resetServe method removes the serve config left on the node by a previous
+// run when the proxy has no path handlers, it's kept in the node state.
+func (p *Proxy) resetServe() {
+	if p.shared != nil {
+		return
+	}
+
+	p.serveMtx.Lock()
+	defer p.serveMtx.Unlock()
+
+	if p.serveConfig != nil {
+		return
+	}
+
+	sc, err := p.lc.GetServeConfig(p.ctx)
+	if err != nil || sc == nil || len(sc.TCP) == 0 && len(sc.Web) == 0 {
+		return
+	}
+
+	if err := p.lc.SetServeConfig(p.ctx, new(ipn.ServeConfig)); err != nil {
+		p.log.Error().Err(err).Msg("Error removing previous serve config")
+	}
+}
+
+// ClientAddr method returns the address of the client of requests received
+// from the serve config, which are proxied from a loopback address.
+func (p *Proxy) ClientAddr(r *http.Request) (string, bool) {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return "", false
+	}
+
+	p.serveMtx.Lock()
+	_, ok = p.serveAddrs[addr.String()]
+	p.serveMtx.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	// the node sets the header to the client address
+	client := r.Header.Get("X-Forwarded-For")
+
+	return client, client != ""
+}
@@ -0,0 +1,342 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"tailscale.com/client/local"
+	"tailscale.com/tsnet"
+)
+
+const (
+	// routeTimeout is the time to read the request headers of a connection
+	// routed by the Host header.
+	routeTimeout = 10 * time.Second
+	// acceptRetryDelay is the time to wait after an accept error.
+	acceptRetryDelay = 100 * time.Millisecond
+)
+
+var (
+	ErrSharedAddressInUse = errors.New("address is used by another proxy of the shared node")
+	ErrSharedNodeClosed   = errors.New("shared node is closed")
+)
+
+type (
+	// sharedNode struct is a tsnet node that hosts the proxies of a provider.
+	// Listeners are shared by the proxies using the same address, and HTTP
+	// connections are routed by the Host header.
+	sharedNode struct {
+		log       zerolog.Logger
+		server    *tsnet.Server
+		keys      *authKeySource
+		headscale *headscaleAPI
+		listeners map[string]*sharedListener
+		proxies   map[string]struct{}
+		mtx       sync.Mutex
+		closed    bool
+	}
+
+	// sharedListener struct is a listener of the shared node that dispatches
+	// the connections to the proxies.
+	sharedListener struct {
+		log    zerolog.Logger
+		ln     net.Listener
+		node   *sharedNode
+		routes map[string]*virtualListener
+		key    string
+		mtx    sync.Mutex
+		// route is true if the connections can be routed by the Host header,
+		// otherwise the address is used by a single proxy
+		route bool
+	}
+
+	// virtualListener struct is the listener of a proxy in a shared listener.
+	virtualListener struct {
+		parent *sharedListener
+		conns  chan net.Conn
+		done   chan struct{}
+		name   string
+		once   sync.Once
+	}
+
+	// peekedConn struct is a connection with the bytes read to route it.
+	peekedConn struct {
+		net.Conn
+		r io.Reader
+	}
+)
+
+func newSharedNode(log zerolog.Logger, server *tsnet.Server, keys *authKeySource, headscale *headscaleAPI) *sharedNode {
+	return &sharedNode{
+		log:       log,
+		server:    server,
+		keys:      keys,
+		headscale: headscale,
+		listeners: make(map[string]*sharedListener),
+		proxies:   make(map[string]struct{}),
+	}
+}
+
+// acquire method adds a proxy to the node. It returns false if the node was
+// closed, so a new node is needed.
+func (n *sharedNode) acquire(name string) bool {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if n.closed {
+		return false
+	}
+
+	n.proxies[name] = struct{}{}
+
+	return true
+}
+
+// release method removes a proxy from the node and closes its listeners.
+// The node is closed with the last proxy, lc is its local client.
+func (n *sharedNode) release(name string, lc *local.Client) error {
+	n.mtx.Lock()
+	listeners := make([]*sharedListener, 0, len(n.listeners))
+	for _, l := range n.listeners {
+		listeners = append(listeners, l)
+	}
+	n.mtx.Unlock()
+
+	for _, l := range listeners {
+		l.remove(name)
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	delete(n.proxies, name)
+	if len(n.proxies) > 0 || n.closed {
+		return nil
+	}
+
+	n.closed = true
+	n.log.Info().Msg("Closing shared node")
+
+	return n.headscale.closeServer(n.log, n.server, lc)
+}
+
+// listen method returns the listener of a proxy in the address. HTTP and
+// HTTPS listeners can be shared, others are used by a single proxy.
+// newListener creates the node listener if the address isn't used yet.
+func (n *sharedNode) listen(name, key string, route bool, newListener func() (net.Listener, error)) (net.Listener, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if n.closed {
+		return nil, ErrSharedNodeClosed
+	}
+
+	l, ok := n.listeners[key]
+	if !ok {
+		ln, err := newListener()
+		if err != nil {
+			return nil, err
+		}
+
+		l = &sharedListener{
+			log:    n.log.With().Str("listener", key).Logger(),
+			ln:     ln,
+			node:   n,
+			routes: make(map[string]*virtualListener),
+			key:    key,
+			route:  route,
+		}
+		n.listeners[key] = l
+
+		go l.serve()
+	} else if l.route != route {
+		return nil, ErrSharedAddressInUse
+	}
+
+	return l.add(name)
+}
+
+// add method adds the virtual listener of a proxy.
+func (l *sharedListener) add(name string) (net.Listener, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	name = strings.ToLower(name)
+
+	if _, ok := l.routes[name]; ok || (!l.route && len(l.routes) > 0) {
+		return nil, ErrSharedAddressInUse
+	}
+
+	vl := &virtualListener{
+		parent: l,
+		conns:  make(chan net.Conn),
+		done:   make(chan struct{}),
+		name:   name,
+	}
+	l.routes[name] = vl
+
+	return vl, nil
+}
+
+// remove method closes the virtual listener of a proxy. The listener is
+// closed with the last proxy.
+func (l *sharedListener) remove(name string) {
+	l.mtx.Lock()
+	vl, ok := l.routes[strings.ToLower(name)]
+	l.mtx.Unlock()
+
+	if ok {
+		vl.Close()
+	}
+}
+
+// removeRoute method removes a virtual listener, and closes the listener if
+// it was the last one.
+func (l *sharedListener) removeRoute(vl *virtualListener) {
+	// locked in the same order as listen
+	l.node.mtx.Lock()
+	l.mtx.Lock()
+
+	if l.routes[vl.name] == vl {
+		delete(l.routes, vl.name)
+	}
+
+	last := len(l.routes) == 0
+	if last && l.node.listeners[l.key] == l {
+		delete(l.node.listeners, l.key)
+	}
+
+	l.mtx.Unlock()
+	l.node.mtx.Unlock()
+
+	if last {
+		l.ln.Close()
+	}
+}
+
+// serve method accepts the connections and dispatches them to the proxies.
+func (l *sharedListener) serve() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			l.log.Error().Err(err).Msg("error accepting connection")
+			time.Sleep(acceptRetryDelay)
+			continue
+		}
+
+		go l.dispatch(conn)
+	}
+}
+
+// dispatch method sends a connection to its proxy. With more than one proxy,
+// the connection is routed by the Host header of the first request.
+func (l *sharedListener) dispatch(conn net.Conn) {
+	l.mtx.Lock()
+	var vl *virtualListener
+	if len(l.routes) == 1 {
+		for _, r := range l.routes {
+			vl = r
+		}
+	}
+	l.mtx.Unlock()
+
+	if vl == nil {
+		var host string
+		conn, host = peekHost(conn)
+
+		l.mtx.Lock()
+		vl = l.match(host)
+		l.mtx.Unlock()
+
+		if vl == nil {
+			l.log.Debug().Str("host", host).Msg("no proxy for host")
+			_, _ = io.WriteString(conn, "HTTP/1.1 421 Misdirected Request\r\nConnection: close\r\nContent-Length: 0\r\n\r\n")
+			conn.Close()
+			return
+		}
+	}
+
+	select {
+	case vl.conns <- conn:
+	case <-vl.done:
+		conn.Close()
+	}
+}
+
+// match method returns the proxy of the host: the proxy with the host name,
+// or with its first label, like myservice in myservice.example.com.
+func (l *sharedListener) match(host string) *virtualListener {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if vl, ok := l.routes[host]; ok {
+		return vl
+	}
+
+	label, _, _ := strings.Cut(host, ".")
+
+	return l.routes[label]
+}
+
+// peekHost function reads the request headers of the connection and returns
+// the Host header, with a connection that replays the bytes read.
+func peekHost(conn net.Conn) (net.Conn, string) {
+	var buf bytes.Buffer
+
+	_ = conn.SetReadDeadline(time.Now().Add(routeTimeout))
+	req, err := http.ReadRequest(bufio.NewReader(io.TeeReader(conn, &buf)))
+	_ = conn.SetReadDeadline(time.Time{})
+
+	peeked := &peekedConn{Conn: conn, r: io.MultiReader(&buf, conn)}
+	if err != nil {
+		return peeked, ""
+	}
+
+	return peeked, req.Host
+}
+
+// Read method reads the peeked bytes before the connection.
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// Accept method implements net.Listener Accept method.
+func (vl *virtualListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-vl.conns:
+		return conn, nil
+	case <-vl.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close method implements net.Listener Close method.
+func (vl *virtualListener) Close() error {
+	vl.once.Do(func() {
+		close(vl.done)
+		vl.parent.removeRoute(vl)
+	})
+
+	return nil
+}
+
+// Addr method implements net.Listener Addr method.
+func (vl *virtualListener) Addr() net.Addr {
+	return vl.parent.ln.Addr()
+}
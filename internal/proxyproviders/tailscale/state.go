@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// stateCleanupInterval is the interval to look for unused state
+	// directories.
+	stateCleanupInterval = time.Hour
+	// unusedMarker is the file with the time a state directory stopped
+	// being used, it's kept with the directory across restarts.
+	unusedMarker = ".tsdproxy-unused"
+)
+
+// stateDirs struct tracks the state directories of the nodes of a provider,
+// and deletes the ones unused for longer than the retention.
+type stateDirs struct {
+	log zerolog.Logger
+	// inUse are the directories of the nodes created since the start
+	inUse     map[string]struct{}
+	dir       string
+	retention time.Duration
+	mtx       sync.Mutex
+}
+
+func newStateDirs(log zerolog.Logger, dir string, retention time.Duration) *stateDirs {
+	s := &stateDirs{
+		log:       log,
+		dir:       dir,
+		retention: retention,
+		inUse:     make(map[string]struct{}),
+	}
+
+	if retention > 0 {
+		go s.cleanupLoop()
+	}
+
+	return s
+}
+
+// use method marks the state directory of a node as used.
+func (s *stateDirs) use(name string) {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	s.inUse[name] = struct{}{}
+	s.mtx.Unlock()
+
+	os.Remove(filepath.Join(s.dir, name, unusedMarker))
+}
+
+// release method marks the state directory of a removed node as unused
+// since now.
+func (s *stateDirs) release(name string) {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	delete(s.inUse, name)
+	s.mtx.Unlock()
+
+	// the directory may already be deleted with the device
+	if _, err := os.Stat(filepath.Join(s.dir, name)); err == nil {
+		s.markUnused(name, time.Now())
+	}
+}
+
+// list method returns the state directories sorted by name, with their
+// size.
+func (s *stateDirs) list() ([]model.StateDir, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make([]model.StateDir, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		dir := model.StateDir{
+			Name: e.Name(),
+			Path: filepath.Join(s.dir, e.Name()),
+			Size: dirSize(filepath.Join(s.dir, e.Name())),
+		}
+		if unused, ok := s.unusedSince(e.Name()); ok {
+			dir.Unused = &unused
+		}
+		dirs = append(dirs, dir)
+	}
+
+	slices.SortFunc(dirs, func(a, b model.StateDir) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return dirs, nil
+}
+
+// cleanupLoop method deletes the unused state directories on every
+// stateCleanupInterval.
+func (s *stateDirs) cleanupLoop() {
+	ticker := time.NewTicker(stateCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.cleanup()
+	}
+}
+
+// cleanup method deletes the state directories unused for longer than the
+// retention. Directories not used since the start, like the ones of proxies
+// removed while the server was stopped and the quarantined ones, are marked
+// as unused now.
+func (s *stateDirs) cleanup() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		s.log.Error().Err(err).Msg("unable to read the data directory")
+		return
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || s.isInUse(name) {
+			continue
+		}
+
+		unused, ok := s.unusedSince(name)
+		if !ok {
+			s.markUnused(name, now)
+			continue
+		}
+		if now.Sub(unused) < s.retention {
+			continue
+		}
+
+		dir := filepath.Join(s.dir, name)
+		if err := os.RemoveAll(dir); err != nil {
+			s.log.Error().Err(err).Str("dir", dir).Msg("unable to delete unused state directory")
+			continue
+		}
+		s.log.Info().Str("dir", dir).Time("unused", unused).Msg("Unused state directory deleted")
+	}
+}
+
+func (s *stateDirs) isInUse(name string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, ok := s.inUse[name]
+	return ok
+}
+
+// unusedSince method returns the time the state directory stopped being
+// used, false if it's used.
+func (s *stateDirs) unusedSince(name string) (time.Time, bool) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name, unusedMarker))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+func (s *stateDirs) markUnused(name string, t time.Time) {
+	err := os.WriteFile(filepath.Join(s.dir, name, unusedMarker), []byte(t.Format(time.RFC3339)),
+		consts.PermOwnerRead+consts.PermOwnerWrite)
+	if err != nil {
+		s.log.Error().Err(err).Str("dir", name).Msg("unable to mark unused state directory")
+	}
+}
+
+// dirSize function returns the size of the files of a directory.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil //nolint:nilerr
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tailscale
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxyproviders"
+
+	"tailscale.com/client/tailscale/apitype"
+	// register the taildrop extension in tsnet servers
+	_ "tailscale.com/feature/taildrop"
+)
+
+// awaitFilesTimeout is the maximum time ReceiveFiles waits for files.
+const awaitFilesTimeout = time.Minute
+
+var (
+	_ proxyproviders.FileSharer = (*Proxy)(nil)
+
+	ErrFileTooLarge = errors.New("file too large")
+)
+
+// PeerNotFoundError is returned when a peer is not a Taildrop target of the node.
+type PeerNotFoundError struct {
+	Peer string
+}
+
+func (e *PeerNotFoundError) Error() string {
+	return "peer " + e.Peer + " not found or can't receive files"
+}
+
+// SendFile method implements proxyproviders.FileSharer SendFile method.
+// Files are sent with Taildrop, the peer must accept files from this node.
+func (p *Proxy) SendFile(ctx context.Context, peer, name string, data []byte) error {
+	targets, err := p.lc.FileTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		if !isPeer(t, peer) {
+			continue
+		}
+
+		return p.lc.PushFile(ctx, t.Node.StableID, int64(len(data)), name, bytes.NewReader(data))
+	}
+
+	return &PeerNotFoundError{Peer: peer}
+}
+
+// ReceiveFiles method implements proxyproviders.FileSharer ReceiveFiles method.
+// Received files are removed from the node, files larger than maxSize are discarded.
+func (p *Proxy) ReceiveFiles(ctx context.Context, maxSize int64) ([]proxyproviders.File, error) {
+	waiting, err := p.lc.AwaitWaitingFiles(ctx, awaitFilesTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]proxyproviders.File, 0, len(waiting))
+
+	for _, wf := range waiting {
+		data, err := p.readWaitingFile(ctx, wf, maxSize)
+		if err != nil {
+			p.log.Error().Err(err).Str("file", wf.Name).Msg("error receiving file")
+		} else {
+			files = append(files, proxyproviders.File{Name: wf.Name, Data: data})
+		}
+
+		if err := p.lc.DeleteWaitingFile(ctx, wf.Name); err != nil {
+			p.log.Error().Err(err).Str("file", wf.Name).Msg("error deleting received file")
+		}
+	}
+
+	return files, nil
+}
+
+func (p *Proxy) readWaitingFile(ctx context.Context, wf apitype.WaitingFile, maxSize int64) ([]byte, error) {
+	if wf.Size > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFileTooLarge, wf.Size)
+	}
+
+	rc, _, err := p.lc.GetWaitingFile(ctx, wf.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	return data, nil
+}
+
+// isPeer function returns true if the target is the node with hostname peer.
+func isPeer(t apitype.FileTarget, peer string) bool {
+	name, _, _ := strings.Cut(t.Node.Name, ".")
+
+	return strings.EqualFold(t.Node.ComputedName, peer) ||
+		strings.EqualFold(name, peer) ||
+		strings.EqualFold(t.Node.Hostinfo.Hostname(), peer)
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package publicdns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxResponseSize is the maximum size of the Cloudflare API responses.
+const maxResponseSize = 1 << 20
+
+type (
+	// cloudflareAPI struct manages the DNS records of a Cloudflare zone.
+	cloudflareAPI struct {
+		client  *http.Client
+		apiURL  string
+		token   string
+		zoneID  string
+		ttl     int
+		proxied bool
+	}
+
+	dnsRecord struct {
+		ID      string `json:"id,omitempty"`
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Content string `json:"content"`
+		Comment string `json:"comment,omitempty"`
+		TTL     int    `json:"ttl,omitempty"`
+		Proxied bool   `json:"proxied"`
+	}
+
+	// cloudflareResponse is the envelope of Cloudflare API responses.
+	cloudflareResponse struct {
+		Result json.RawMessage `json:"result"`
+		Errors []struct {
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"errors"`
+		Success bool `json:"success"`
+	}
+)
+
+// list method returns the A and AAAA records of the name.
+func (a *cloudflareAPI) list(ctx context.Context, name string) ([]dnsRecord, error) {
+	query := url.Values{"name": {name}, "per_page": {"100"}}
+
+	var records []dnsRecord
+	if err := a.do(ctx, http.MethodGet, "/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(records, func(r dnsRecord) bool { return r.Type != "A" && r.Type != "AAAA" }), nil
+}
+
+// create method adds a record to the zone.
+func (a *cloudflareAPI) create(ctx context.Context, r dnsRecord) error {
+	r.TTL = a.ttl
+	r.Proxied = a.proxied
+	r.Comment = recordComment
+
+	return a.do(ctx, http.MethodPost, "/dns_records", r, nil)
+}
+
+// delete method deletes a record of the zone.
+func (a *cloudflareAPI) delete(ctx context.Context, id string) error {
+	return a.do(ctx, http.MethodDelete, "/dns_records/"+url.PathEscape(id), nil, nil)
+}
+
+// do method sends a request to the zone API and decodes its result.
+func (a *cloudflareAPI) do(ctx context.Context, method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.apiURL+"/zones/"+url.PathEscape(a.zoneID)+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var cfResp cloudflareResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&cfResp); err != nil {
+		return fmt.Errorf("cloudflare api returned %s", resp.Status)
+	}
+
+	if !cfResp.Success {
+		msgs := make([]string, len(cfResp.Errors))
+		for i, e := range cfResp.Errors {
+			msgs[i] = fmt.Sprintf("%d: %s", e.Code, e.Message)
+		}
+		return fmt.Errorf("cloudflare api returned %s: %s", resp.Status, strings.Join(msgs, ", "))
+	}
+
+	if result != nil {
+		return json.Unmarshal(cfResp.Result, result)
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package publicdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/problems"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/proxymanager"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+	httpTimeout      = 30 * time.Second
+	// syncDelay groups proxy events in a single sync
+	syncDelay   = 5 * time.Second
+	syncTimeout = time.Minute
+	// recordComment marks the records managed by tsdproxy
+	recordComment = "tsdproxy"
+	// fixSync is the suggested fix of sync failures
+	fixSync = "Check the API token has the Zone:DNS Edit permission and publicDns.zoneId is the zone of the name."
+)
+
+type (
+	// Publisher struct publishes the records of the proxies with a public
+	// DNS name in Cloudflare. Each instance only adds and deletes the records
+	// of its own addresses, records of other instances are kept.
+	Publisher struct {
+		log       zerolog.Logger
+		pm        *proxymanager.ProxyManager
+		api       *cloudflareAPI
+		addresses []string
+		// published are the names with records of this instance, or that
+		// failed to sync
+		published map[string]struct{}
+		interval  time.Duration
+		mtx       sync.Mutex
+	}
+)
+
+// New function returns a new Publisher, nil if no zone is configured.
+func New(log zerolog.Logger, pm *proxymanager.ProxyManager, cfg config.PublicDNSConfig) *Publisher {
+	if cfg.ZoneID == "" || len(cfg.Addresses) == 0 {
+		return nil
+	}
+
+	return &Publisher{
+		log: core.ModuleLogger(log, "publicdns"),
+		pm:  pm,
+		api: &cloudflareAPI{
+			client:  &http.Client{Timeout: httpTimeout},
+			apiURL:  cloudflareAPIURL,
+			token:   cfg.APIToken,
+			zoneID:  cfg.ZoneID,
+			ttl:     cfg.TTL,
+			proxied: cfg.Proxied,
+		},
+		addresses: cfg.Addresses,
+		published: make(map[string]struct{}),
+		interval:  cfg.Interval,
+	}
+}
+
+// Start method syncs the records when proxies change and on every interval.
+func (p *Publisher) Start(ctx context.Context) {
+	events := p.pm.SubscribeStatusEvents()
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		delay := time.NewTimer(syncDelay)
+		defer delay.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				delay.Reset(syncDelay)
+			case <-delay.C:
+				p.sync(ctx)
+			case <-ticker.C:
+				p.sync(ctx)
+			}
+		}
+	}()
+}
+
+// Close method deletes the records of this instance, so the names only
+// resolve to the other instances.
+func (p *Publisher) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for name := range p.published {
+		if p.update(ctx, name, nil) == nil {
+			delete(p.published, name)
+		}
+	}
+}
+
+// sync method publishes the names of the healthy proxies and unpublishes
+// the others, including the names of proxies that were removed.
+func (p *Publisher) sync(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	// addresses of the families the ports of the healthy proxies listen on
+	names := make(map[string][]string)
+	for _, proxy := range p.pm.GetProxies() {
+		if !proxy.Config().PublicDNS.IsEnabled() {
+			continue
+		}
+		name := proxy.Config().PublicDNS.Name
+		if _, ok := names[name]; !ok {
+			names[name] = nil
+		}
+		if !isHealthy(proxy) {
+			continue
+		}
+		for _, addr := range p.addresses {
+			if listensOn(proxy, addr) && !slices.Contains(names[name], addr) {
+				names[name] = append(names[name], addr)
+			}
+		}
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for name := range p.published {
+		if _, ok := names[name]; !ok {
+			names[name] = nil
+		}
+	}
+
+	registry := p.pm.Problems()
+	for name, addresses := range names {
+		if err := p.update(ctx, name, addresses); err != nil {
+			registry.Report(problems.Problem{
+				Source:  problems.SourceDNS,
+				Subject: name,
+				Message: "public DNS sync failed: " + err.Error(),
+				Fix:     fixSync,
+			})
+			// synced again until the problem is resolved
+			p.published[name] = struct{}{}
+			continue
+		}
+		registry.Resolve(problems.SourceDNS, name)
+		if len(addresses) > 0 {
+			p.published[name] = struct{}{}
+		} else {
+			delete(p.published, name)
+		}
+	}
+}
+
+// update method adds the missing records of the addresses of the name and
+// deletes the records of the other addresses of the instance.
+func (p *Publisher) update(ctx context.Context, name string, addresses []string) error {
+	records, err := p.api.list(ctx, name)
+	if err != nil {
+		p.log.Error().Err(err).Str("name", name).Msg("Error listing DNS records")
+		return err
+	}
+
+	var errs []error
+	for _, addr := range addresses {
+		if slices.ContainsFunc(records, func(r dnsRecord) bool { return r.Content == addr }) {
+			continue
+		}
+		if err := p.api.create(ctx, dnsRecord{Type: recordType(addr), Name: name, Content: addr}); err != nil {
+			p.log.Error().Err(err).Str("name", name).Str("address", addr).Msg("Error creating DNS record")
+			errs = append(errs, err)
+			continue
+		}
+		p.log.Info().Str("name", name).Str("address", addr).Msg("DNS record published")
+	}
+
+	for _, r := range records {
+		if !slices.Contains(p.addresses, r.Content) || slices.Contains(addresses, r.Content) {
+			continue
+		}
+		if err := p.api.delete(ctx, r.ID); err != nil {
+			p.log.Error().Err(err).Str("name", name).Str("address", r.Content).Msg("Error deleting DNS record")
+			errs = append(errs, err)
+			continue
+		}
+		p.log.Info().Str("name", name).Str("address", r.Content).Msg("DNS record unpublished")
+	}
+
+	return errors.Join(errs...)
+}
+
+// isHealthy function returns true if the proxy is running without port
+// errors and every port with target health has a target answering and up.
+func isHealthy(proxy *proxymanager.Proxy) bool {
+	if proxy.GetStatus() != model.ProxyStatusRunning || len(proxy.GetPortErrors()) > 0 {
+		return false
+	}
+
+	for _, targets := range proxy.GetTargetHealth() {
+		if len(targets) > 0 && !slices.ContainsFunc(targets, func(t model.TargetHealth) bool { return t.ErrorRate < 1 && !t.Down }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listensOn function returns true if a port of the proxy listens on the
+// address family of the address, AAAA records are only published for
+// proxies listening on IPv6.
+func listensOn(proxy *proxymanager.Proxy, addr string) bool {
+	family := model.IPFamilyIPv4
+	if recordType(addr) == "AAAA" {
+		family = model.IPFamilyIPv6
+	}
+
+	for _, port := range proxy.Config().Ports {
+		if port.ListensOn(family) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordType function returns the record type of an address.
+func recordType(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return "AAAA"
+	}
+
+	return "A"
+}
@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package respcache
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recorder struct is a http.ResponseWriter that keeps a copy of the body
+// while writing it, up to the limit.
+type recorder struct {
+	http.ResponseWriter
+	body        bytes.Buffer
+	limit       int64
+	status      int
+	wroteHeader bool
+	// skip is set when the response can't be cached
+	skip bool
+}
+
+// WriteHeader method implements http.ResponseWriter WriteHeader method.
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader && status >= http.StatusOK {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write method implements http.ResponseWriter Write method.
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	if !r.skip {
+		if int64(r.body.Len()+len(b)) > r.limit {
+			r.skip = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap method returns the original http.ResponseWriter, used by
+// http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// cacheableStatus are the response status cached by default.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:               true,
+	http.StatusMovedPermanently: true,
+	http.StatusNotFound:         true,
+	http.StatusGone:             true,
+}
+
+// cacheControl function returns the directives of the Cache-Control header,
+// with their values.
+func cacheControl(h http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return directives
+}
+
+// noCache function returns true if the request asks for a response from the
+// target.
+func noCache(h http.Header) bool {
+	cc := cacheControl(h)
+	_, noCache := cc["no-cache"]
+	_, noStore := cc["no-store"]
+
+	return noCache || noStore || h.Get("Pragma") == "no-cache"
+}
+
+// storable function returns true if a response can be shared between
+// clients. Responses of rules are cached without Cache-Control.
+func storable(h http.Header) bool {
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if !strings.EqualFold(strings.TrimSpace(f), "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// freshness function returns how long a response can be cached from its
+// Cache-Control and Expires headers, zero if it can't be cached.
+func freshness(h http.Header, status int) time.Duration {
+	if !cacheableStatus[status] || !storable(h) {
+		return 0
+	}
+
+	cc := cacheControl(h)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[d]; ok {
+			return 0
+		}
+	}
+
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[d]; ok {
+			secs, err := strconv.Atoi(v)
+			if err != nil {
+				return 0
+			}
+			return time.Duration(secs) * time.Second
+		}
+	}
+
+	expires, err := http.ParseTime(h.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	date, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		date = time.Now()
+	}
+
+	return expires.Sub(date)
+}
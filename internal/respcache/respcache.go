@@ -0,0 +1,349 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package respcache implements the response cache of the ports, a LRU cache
+// of the responses of the targets kept in memory or in the data directory.
+package respcache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/metrics"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultMaxSize is the maximum size of the cached bodies of a port
+	// without maxSize.
+	DefaultMaxSize = 64 << 20
+	// maxEntryFraction limits the size of a response to a fraction of the
+	// cache, so a single response doesn't evict everything.
+	maxEntryFraction = 8
+
+	// Results of the cache requests metric
+	resultHit    = "hit"
+	resultMiss   = "miss"
+	resultBypass = "bypass"
+)
+
+var (
+	cacheRequests = metrics.NewCounter(
+		"tsdproxy_cache_requests_total",
+		"Requests to ports with response cache, by hit, miss or bypass.",
+		"proxy", "port", "result",
+	)
+	cacheBytes = metrics.NewGauge(
+		"tsdproxy_cache_bytes",
+		"Size of the cached response bodies.",
+		"proxy", "port",
+	)
+)
+
+type (
+	// Cache struct is the response cache of a port.
+	Cache struct {
+		log     zerolog.Logger
+		entries map[string]*list.Element
+		lru     *list.List
+		// dir is the directory of the bodies, empty to keep them in memory
+		dir     string
+		proxy   string
+		port    string
+		rules   []model.CacheRule
+		maxSize int64
+		size    int64
+		mtx     sync.Mutex
+	}
+
+	// entry struct is a cached response.
+	entry struct {
+		expires time.Time
+		stored  time.Time
+		header  http.Header
+		key     string
+		path    string
+		file    string
+		body    []byte
+		size    int64
+		status  int
+	}
+)
+
+// New function returns the response cache of a port. The bodies of disk
+// caches are stored in a new directory inside dir.
+func New(log zerolog.Logger, proxy, port string, cfg model.Cache, dir string) (*Cache, error) {
+	c := &Cache{
+		log:     core.ModuleLogger(log, "respcache"),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		proxy:   proxy,
+		port:    port,
+		rules:   cfg.Rules,
+		maxSize: cfg.MaxSize,
+	}
+	if c.maxSize <= 0 {
+		c.maxSize = DefaultMaxSize
+	}
+
+	if cfg.Disk {
+		if err := os.MkdirAll(dir, consts.PermOwnerAll); err != nil {
+			return nil, err
+		}
+		d, err := os.MkdirTemp(dir, url.PathEscape(proxy+"_"+port)+"-")
+		if err != nil {
+			return nil, err
+		}
+		c.dir = d
+	}
+
+	return c, nil
+}
+
+// Reset function removes the bodies of the disk caches left in dir by a
+// previous run.
+func Reset(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// Middleware method returns a middleware that serves the cached responses
+// of GET and HEAD requests and caches the responses of next.
+func (c *Cache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ttl, ruled := c.rule(r.URL.Path)
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || (ruled && ttl == 0) {
+			cacheRequests.Inc(c.proxy, c.port, resultBypass)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+
+		// clients asking for a fresh response get it from the target
+		if !noCache(r.Header) {
+			if e := c.get(key); e != nil && c.serve(w, r, e) {
+				cacheRequests.Inc(c.proxy, c.port, resultHit)
+				return
+			}
+		}
+		cacheRequests.Inc(c.proxy, c.port, resultMiss)
+
+		// HEAD responses don't have the body to cache
+		if r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, limit: c.maxSize / maxEntryFraction, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.skip {
+			return
+		}
+
+		if !ruled {
+			ttl = freshness(rec.Header(), rec.status)
+		} else if !cacheableStatus[rec.status] || !storable(rec.Header()) {
+			ttl = 0
+		}
+		if ttl <= 0 {
+			return
+		}
+
+		c.put(&entry{
+			key:     key,
+			path:    r.URL.Path,
+			status:  rec.status,
+			header:  rec.Header().Clone(),
+			body:    rec.body.Bytes(),
+			size:    int64(rec.body.Len()),
+			stored:  time.Now(),
+			expires: time.Now().Add(ttl),
+		})
+	})
+}
+
+// Purge method removes the responses with a path matching the pattern,
+// all of them with an empty pattern, and returns how many were removed.
+func (c *Cache) Purge(pattern string) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	n := 0
+	for _, el := range c.entries {
+		if e := el.Value.(*entry); pattern == "" || match(pattern, e.path) { //nolint:forcetypeassert
+			c.remove(el)
+			n++
+		}
+	}
+
+	return n
+}
+
+// Close method removes the responses and the directory of the cache. It
+// does nothing in a nil Cache.
+func (c *Cache) Close() {
+	if c == nil {
+		return
+	}
+
+	c.Purge("")
+
+	if c.dir != "" {
+		if err := os.RemoveAll(c.dir); err != nil {
+			c.log.Error().Err(err).Str("dir", c.dir).Msg("Error removing cache directory")
+		}
+	}
+}
+
+// rule method returns the TTL of the first rule matching the path.
+func (c *Cache) rule(path string) (time.Duration, bool) {
+	for _, r := range c.rules {
+		if match(r.Path, path) {
+			return r.TTL, true
+		}
+	}
+
+	return 0, false
+}
+
+// get method returns the fresh response of the key, nil if there isn't.
+func (c *Cache) get(key string) *entry {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	e := el.Value.(*entry) //nolint:forcetypeassert
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+
+	return e
+}
+
+// put method stores a response, evicting the least recently used ones to
+// keep the cache under its maximum size.
+func (c *Cache) put(e *entry) {
+	if c.dir != "" {
+		sum := sha256.Sum256([]byte(e.key))
+		e.file = filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+		if err := os.WriteFile(e.file, e.body, consts.PermOwnerRead+consts.PermOwnerWrite); err != nil {
+			c.log.Error().Err(err).Msg("Error writing cached response")
+			return
+		}
+		e.body = nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if el, ok := c.entries[e.key]; ok {
+		// the file of the old entry is replaced by the new one
+		el.Value.(*entry).file = "" //nolint:forcetypeassert
+		c.remove(el)
+	}
+
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += e.size
+	cacheBytes.Add(e.size, c.proxy, c.port)
+
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove method removes an entry, with the lock held.
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry) //nolint:forcetypeassert
+	delete(c.entries, e.key)
+	c.size -= e.size
+	cacheBytes.Add(-e.size, c.proxy, c.port)
+
+	if e.file != "" {
+		if err := os.Remove(e.file); err != nil {
+			c.log.Error().Err(err).Msg("Error removing cached response")
+		}
+	}
+}
+
+// serve method writes a cached response, false if its body can't be read.
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, e *entry) bool {
+	var body io.Reader = bytes.NewReader(e.body)
+	if e.file != "" {
+		f, err := os.Open(e.file)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		body = f
+	}
+
+	h := w.Header()
+	for k, v := range e.header {
+		h[k] = v
+	}
+	h.Set("Age", strconv.Itoa(int(time.Since(e.stored).Seconds())))
+	w.WriteHeader(e.status)
+
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, body)
+	}
+
+	return true
+}
+
+// cacheKey function returns the key of the response of a request. The
+// encoding is in the key, since the responses depend on it.
+func cacheKey(r *http.Request) string {
+	return r.Host + r.URL.RequestURI() + "\n" + r.Header.Get("Accept-Encoding")
+}
+
+// match function returns true if the path matches the pattern, where *
+// matches any characters, including /.
+func match(pattern, path string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == path
+	}
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, p)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(p):]
+	}
+
+	return len(path) >= len(last) && strings.HasSuffix(path, last)
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package service runs the server as a service of the system: a Windows
+// service or a macOS launchd daemon.
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+)
+
+const (
+	// Name is the name of the service in the service manager.
+	Name        = "tsdproxy"
+	displayName = "TSDProxy"
+	description = "Proxies Docker containers and lists to Tailscale nodes."
+)
+
+var (
+	ErrUnsupported  = errors.New("services aren't supported on this system, use systemd or Docker")
+	ErrInstalled    = errors.New("service already installed")
+	ErrNotInstalled = errors.New("service not installed")
+)
+
+// Config struct is the service installed: the server with its
+// configuration file and data directory.
+type Config struct {
+	Executable string
+	ConfigFile string
+	DataDir    string
+}
+
+// NewConfig function returns the service of the running executable, with
+// the default paths of the system.
+func NewConfig() (Config, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Config{
+		Executable: exe,
+		ConfigFile: DefaultConfigFile(),
+		DataDir:    DefaultDataDir(),
+	}, nil
+}
+
+// args method returns the arguments of the server.
+func (c Config) args() []string {
+	return []string{"-config", c.ConfigFile}
+}
+
+// env method returns the environment variables of the server, the data
+// directories aren't in the paths of the default configuration.
+func (c Config) env() []string {
+	return []string{
+		"TSDPROXY_TAILSCALE_DATADIR=" + c.DataDir,
+		"TSDPROXY_LETSENCRYPT_CACHEDIR=" + filepath.Join(c.DataDir, "certs"),
+	}
+}
+
+// makeDirs method creates the directories of the configuration file and the
+// data, they're validated when the server starts.
+func (c Config) makeDirs() error {
+	for _, dir := range []string{filepath.Dir(c.ConfigFile), c.DataDir, filepath.Join(c.DataDir, "certs")} {
+		if err := os.MkdirAll(dir, consts.PermOwnerAll); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runInteractive function calls run with a context that is done with an
+// interrupt signal. A second signal stops the server at once.
+func runInteractive(run func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return run(ctx)
+}
@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+)
+
+const (
+	label     = "com.github.yichenchong.tsdproxy"
+	plistFile = "/Library/LaunchDaemons/" + label + ".plist"
+	baseDir   = "/Library/Application Support/tsdproxy"
+	logFile   = "/Library/Logs/tsdproxy.log"
+)
+
+var plistTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{
+	"xml": func(s string) string {
+		var b strings.Builder
+		_ = xml.EscapeText(&b, []byte(s))
+		return b.String()
+	},
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{ xml .Label }}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args }}
+		<string>{{ xml . }}</string>
+{{- end }}
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+{{- range .Env }}
+		<key>{{ xml .Name }}</key>
+		<string>{{ xml .Value }}</string>
+{{- end }}
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{ xml .Log }}</string>
+	<key>StandardErrorPath</key>
+	<string>{{ xml .Log }}</string>
+</dict>
+</plist>
+`))
+
+type plistEnv struct {
+	Name  string
+	Value string
+}
+
+// DefaultConfigFile function returns the configuration file of the service.
+func DefaultConfigFile() string {
+	return filepath.Join(baseDir, "tsdproxy.yaml")
+}
+
+// DefaultDataDir function returns the data directory of the service.
+func DefaultDataDir() string {
+	return filepath.Join(baseDir, "data")
+}
+
+// Install function writes the launchd daemon of the service, started with
+// the system.
+func Install(c Config) error {
+	if _, err := os.Stat(plistFile); err == nil {
+		return ErrInstalled
+	}
+
+	if err := c.makeDirs(); err != nil {
+		return err
+	}
+
+	env := make([]plistEnv, 0, len(c.env()))
+	for _, kv := range c.env() {
+		name, value, _ := strings.Cut(kv, "=")
+		env = append(env, plistEnv{Name: name, Value: value})
+	}
+
+	var b bytes.Buffer
+	err := plistTemplate.Execute(&b, map[string]any{
+		"Label": label,
+		"Args":  append([]string{c.Executable}, c.args()...),
+		"Env":   env,
+		"Log":   logFile,
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(plistFile, b.Bytes(), consts.PermAllRead+consts.PermOwnerWrite)
+}
+
+// Uninstall function stops the service and removes its launchd daemon.
+func Uninstall() error {
+	if _, err := os.Stat(plistFile); errors.Is(err, fs.ErrNotExist) {
+		return ErrNotInstalled
+	}
+
+	// the daemon isn't loaded if it's stopped
+	_ = Stop()
+
+	return os.Remove(plistFile)
+}
+
+// Start function loads the launchd daemon of the service.
+func Start() error {
+	if _, err := os.Stat(plistFile); errors.Is(err, fs.ErrNotExist) {
+		return ErrNotInstalled
+	}
+
+	return launchctl("bootstrap", "system", plistFile)
+}
+
+// Stop function unloads the launchd daemon of the service, it's started
+// again with the system.
+func Stop() error {
+	return launchctl("bootout", "system/"+label)
+}
+
+// Run function calls run with a context that is done when the server is
+// stopped, launchd stops the daemons with SIGTERM.
+func Run(run func(ctx context.Context) error) error {
+	return runInteractive(run)
+}
+
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %w: %s", args[0], err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+//go:build !windows && !darwin
+
+package service
+
+import (
+	"context"
+)
+
+// DefaultConfigFile function returns the configuration file of the server.
+func DefaultConfigFile() string {
+	return "/config/tsdproxy.yaml"
+}
+
+// DefaultDataDir function returns the data directory of the server.
+func DefaultDataDir() string {
+	return "/data"
+}
+
+// Install function returns ErrUnsupported, services are managed with
+// systemd or Docker.
+func Install(Config) error {
+	return ErrUnsupported
+}
+
+// Uninstall function returns ErrUnsupported.
+func Uninstall() error {
+	return ErrUnsupported
+}
+
+// Start function returns ErrUnsupported.
+func Start() error {
+	return ErrUnsupported
+}
+
+// Stop function returns ErrUnsupported.
+func Stop() error {
+	return ErrUnsupported
+}
+
+// Run function calls run with a context that is done with an interrupt
+// signal.
+func Run(run func(ctx context.Context) error) error {
+	return runInteractive(run)
+}
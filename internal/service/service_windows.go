@@ -0,0 +1,239 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+)
+
+const (
+	// restartDelay is the delay of the service manager to restart the
+	// server after a failure.
+	restartDelay = 5 * time.Second
+	// stopTimeout is the time to wait for the service to stop.
+	stopTimeout = 30 * time.Second
+)
+
+var ErrStopTimeout = errors.New("timeout waiting for the service to stop")
+
+// handler struct implements svc.Handler, running the server until the
+// service manager stops it.
+type handler struct {
+	run func(ctx context.Context) error
+	err error
+}
+
+// DefaultConfigFile function returns the configuration file of the service.
+func DefaultConfigFile() string {
+	return filepath.Join(baseDir(), "tsdproxy.yaml")
+}
+
+// DefaultDataDir function returns the data directory of the service.
+func DefaultDataDir() string {
+	return filepath.Join(baseDir(), "data")
+}
+
+// Install function creates the Windows service, started with the system and
+// restarted after failures.
+func Install(c Config) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect() //nolint:errcheck
+
+	if s, err := m.OpenService(Name); err == nil {
+		s.Close()
+		return ErrInstalled
+	}
+
+	if err := c.makeDirs(); err != nil {
+		return err
+	}
+
+	s, err := m.CreateService(Name, c.Executable, mgr.Config{
+		DisplayName: displayName,
+		Description: description,
+		StartType:   mgr.StartAutomatic,
+	}, c.args()...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: restartDelay},
+	}, uint32((24 * time.Hour).Seconds()))
+	if err != nil {
+		return err
+	}
+
+	// the service manager reads the environment of the service from its
+	// registry key
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+Name, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	return key.SetStringsValue("Environment", c.env())
+}
+
+// Uninstall function stops and deletes the Windows service.
+func Uninstall() error {
+	m, s, err := openService()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect() //nolint:errcheck
+	defer s.Close()
+
+	_ = stopService(s)
+
+	return s.Delete()
+}
+
+// Start function starts the Windows service.
+func Start() error {
+	m, s, err := openService()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect() //nolint:errcheck
+	defer s.Close()
+
+	return s.Start()
+}
+
+// Stop function stops the Windows service and waits until it's stopped.
+func Stop() error {
+	m, s, err := openService()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect() //nolint:errcheck
+	defer s.Close()
+
+	return stopService(s)
+}
+
+// Run function calls run with a context that is done when the server is
+// stopped: by the service manager when it runs as a Windows service, with
+// the output in tsdproxy.log of the service directory, or by an interrupt
+// signal.
+func Run(run func(ctx context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return runInteractive(run)
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(baseDir(), "tsdproxy.log"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, consts.PermAllRead+consts.PermOwnerWrite)
+	if err == nil {
+		defer logFile.Close()
+		os.Stdout = logFile
+		os.Stderr = logFile
+	}
+
+	h := &handler{run: run}
+	if err := svc.Run(Name, h); err != nil {
+		return err
+	}
+
+	return h.err
+}
+
+// Execute method implements svc.Handler Execute method.
+func (h *handler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(ctx)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case h.err = <-done:
+			if h.err != nil {
+				return true, 1
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+// baseDir function returns the directory of the service in ProgramData.
+func baseDir() string {
+	dir := os.Getenv("ProgramData")
+	if dir == "" {
+		dir = `C:\ProgramData`
+	}
+
+	return filepath.Join(dir, Name)
+}
+
+func openService() (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := m.OpenService(Name)
+	if err != nil {
+		m.Disconnect() //nolint:errcheck
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil, nil, ErrNotInstalled
+		}
+		return nil, nil, err
+	}
+
+	return m, s, nil
+}
+
+// stopService function stops the service and waits until it's stopped.
+func stopService(s *mgr.Service) error {
+	st, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(stopTimeout)
+	for st.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return ErrStopTimeout
+		}
+		time.Sleep(time.Second)
+
+		if st, err = s.Query(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
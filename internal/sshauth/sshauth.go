@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package sshauth authenticates the SSH connections of the providers, with a
+// key file or the SSH agent, and verifies the keys of their hosts.
+package sshauth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var ErrNoAuth = errors.New("ssh connections require a keyFile or an SSH agent in SSH_AUTH_SOCK")
+
+// Auth function returns the key file authentication, or the SSH agent
+// without a key file.
+func Auth(keyFile, passphrase string) (ssh.AuthMethod, error) {
+	if keyFile != "" {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading ssh key %s: %w", keyFile, err)
+		}
+
+		return ssh.PublicKeys(signer), nil
+	}
+
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, ErrNoAuth
+	}
+
+	// the agent is connected on each login, it may be restarted
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to the ssh agent: %w", err)
+		}
+		defer conn.Close()
+
+		return agent.NewClient(conn).Signers()
+	}), nil
+}
+
+// HostKeyCallback function returns the verification of the key of the host
+// with the known hosts file, ~/.ssh/known_hosts by default.
+func HostKeyCallback(knownHostsFile string, insecure bool) (ssh.HostKeyCallback, error) {
+	if insecure {
+		return ssh.InsecureIgnoreHostKey(), nil //nolint:gosec
+	}
+
+	file := knownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	return knownhosts.New(file)
+}
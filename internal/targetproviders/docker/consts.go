@@ -17,6 +17,7 @@ const (
 	LabelName               = LabelPrefix + "name"
 	LabelContainerAccessLog = LabelPrefix + "containeraccesslog"
 	LabelProxyProvider      = LabelPrefix + "proxyprovider"
+	LabelRedirectToHTTPS    = LabelPrefix + "redirecttohttps"
 	LabelPort               = LabelPrefix + "port."
 	// Tailscale
 	LabelEphemeral    = LabelPrefix + "ephemeral"
@@ -26,17 +27,41 @@ const (
 	LabelAuthKeyFile  = LabelPrefix + "authkeyfile"
 	LabelAutoDetect   = LabelPrefix + "autodetect"
 	LabelTags         = LabelPrefix + "tags"
+	LabelControlURL   = LabelPrefix + "controlurl"
 	// Legacy
 	LabelContainerPort = LabelPrefix + "container_port"
 	LabelScheme        = LabelPrefix + "scheme"
 	LabelTLSValidate   = LabelPrefix + "tlsvalidate"
 	// Legacy Tailscale
 	LabelFunnel = LabelPrefix + "funnel"
+	// Access log labels
+	LabelAccessLogPrefix = LabelPrefix + "accesslog."
+	LabelAccessLogFormat = LabelAccessLogPrefix + "format"
+	LabelAccessLogSink   = LabelAccessLogPrefix + "sink"
+	LabelAccessLogFile   = LabelAccessLogPrefix + "file"
+	LabelAccessLogSyslog = LabelAccessLogPrefix + "syslog"
+	LabelAccessLogURL    = LabelAccessLogPrefix + "url"
+	LabelAccessLogSample = LabelAccessLogPrefix + "sample"
+	// Access log redact labels
+	LabelAccessLogRedactPrefix   = LabelAccessLogPrefix + "redact."
+	LabelAccessLogRedactQuery    = LabelAccessLogRedactPrefix + "query"
+	LabelAccessLogRedactUsername = LabelAccessLogRedactPrefix + "username"
+	LabelAccessLogRedactHeaders  = LabelAccessLogRedactPrefix + "headers"
+	// Cache purge labels
+	LabelCachePurgePrefix = LabelPrefix + "cachepurge."
+	LabelCachePurgeZone   = LabelCachePurgePrefix + "zone"
+	LabelCachePurgeURLs   = LabelCachePurgePrefix + "urls"
+	// Public DNS labels
+	LabelPublicDNS = LabelPrefix + "publicdns"
+	// Readiness labels
+	LabelReadiness     = LabelPrefix + "readiness"
+	LabelReadinessPath = LabelReadiness + ".path"
 	// Dashboard config labels
 	LabelDashboardPrefix  = LabelPrefix + "dash."
 	LabelDashboardVisible = LabelDashboardPrefix + "visible"
 	LabelDashboardLabel   = LabelDashboardPrefix + "label"
 	LabelDashboardIcon    = LabelDashboardPrefix + "icon"
+	LabelDashboardGroup   = LabelDashboardPrefix + "group"
 
 	// docker only defaults
 	DefaultTargetScheme = "http"
@@ -49,4 +74,46 @@ const (
 	// Port options
 	PortOptionNoTLSValidate   = "no_tlsvalidate"
 	PortOptionTailscaleFunnel = "tailscale_funnel"
+	PortOptionTailscalePath   = "tailscale_path="
+	PortOptionOIDC            = "oidc="
+	PortOptionMTLSCA          = "mtls_ca="
+	PortOptionMTLSAllow       = "mtls_allow="
+	PortOptionAllowGroup      = "allow_group="
+	PortOptionAllowCIDR       = "allow_cidr="
+	PortOptionDenyCIDR        = "deny_cidr="
+	PortOptionAllowCountry    = "allow_country="
+	PortOptionDenyCountry     = "deny_country="
+	PortOptionMaxBody         = "max_body="
+	PortOptionRateLimit       = "rps="
+	PortOptionBurst           = "burst="
+	PortOptionMaxHeader       = "max_header="
+	PortOptionMaxConns        = "max_conns="
+	PortOptionAcceptBackoff   = "accept_backoff="
+	PortOptionIPFamily        = "ip_family="
+	PortOptionProxyProtocol   = "proxy_protocol="
+	PortOptionAcceptProxy     = "accept_proxy_protocol"
+	PortOptionCache           = "cache"
+	PortOptionCacheDisk       = "cache_disk"
+	PortOptionCacheSize       = "cache_size="
+	PortOptionCacheRule       = "cache_rule="
+	PortOptionCompress        = "compress"
+	PortOptionCompressMin     = "compress_min="
+	PortOptionCompressType    = "compress_type="
+	PortOptionHealth          = "health="
+	PortOptionHealthInterval  = "health_interval="
+	PortOptionHealthTimeout   = "health_timeout="
+	PortOptionHealthSend      = "health_send="
+	PortOptionHealthExpect    = "health_expect="
+	PortOptionHealthRise      = "health_rise="
+	PortOptionHealthFall      = "health_fall="
+	PortOptionHealthTLS       = "health_tls"
+	PortOptionMiddleware      = "middleware="
+	PortOptionUpstreamCA      = "upstream_ca="
+	PortOptionUpstreamCert    = "upstream_cert="
+	PortOptionUpstreamKey     = "upstream_key="
+	PortOptionUpstreamName    = "upstream_server_name="
+	PortOptionUnixSocket      = "unix="
+	PortOptionBreaker         = "breaker="
+	PortOptionBreakerMin      = "breaker_min="
+	PortOptionBreakerTimeout  = "breaker_timeout="
 )
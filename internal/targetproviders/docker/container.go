@@ -9,6 +9,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
@@ -36,6 +37,12 @@ type (
 		ipAddress             []string
 		gateways              []string
 		autodetect            bool
+		// traefik reads the Traefik labels without tsdproxy labels
+		traefik bool
+		// hostnameTemplate is the hostname of the containers without
+		// tsdproxy.name, and hostnameMaxLength its length after normalization
+		hostnameTemplate  *template.Template
+		hostnameMaxLength int
 	}
 
 	ContainerOption func(*container)
@@ -144,8 +151,24 @@ func (c *container) newProxyConfig() (*model.Config, error) {
 	pcfg.Tailscale = *tailscale
 	pcfg.ProxyProvider = c.getLabelString(LabelProxyProvider, model.DefaultProxyProvider)
 	pcfg.ProxyAccessLog = c.getLabelBool(LabelContainerAccessLog, model.DefaultProxyAccessLog)
+	pcfg.RedirectToHTTPS = c.getLabelBool(LabelRedirectToHTTPS, model.DefaultRedirectToHTTPS)
+	pcfg.AccessLog.Format = c.getLabelString(LabelAccessLogFormat, pcfg.AccessLog.Format)
+	pcfg.AccessLog.Sink = c.getLabelString(LabelAccessLogSink, pcfg.AccessLog.Sink)
+	pcfg.AccessLog.File.Path = c.getLabelString(LabelAccessLogFile, "")
+	pcfg.AccessLog.Syslog.Address = c.getLabelString(LabelAccessLogSyslog, "")
+	pcfg.AccessLog.HTTP.URL = c.getLabelString(LabelAccessLogURL, "")
+	pcfg.AccessLog.Sample = c.getLabelInt(LabelAccessLogSample, 0)
+	pcfg.AccessLog.Redact.Query = c.getLabelBool(LabelAccessLogRedactQuery, false)
+	pcfg.AccessLog.Redact.Username = c.getLabelBool(LabelAccessLogRedactUsername, false)
+	pcfg.AccessLog.Redact.Headers = c.getLabelList(LabelAccessLogRedactHeaders)
+	pcfg.CachePurge.Zone = c.getLabelString(LabelCachePurgeZone, "")
+	pcfg.CachePurge.URLs = c.getLabelList(LabelCachePurgeURLs)
+	pcfg.PublicDNS.Name = c.getLabelString(LabelPublicDNS, "")
+	pcfg.Readiness.Path = c.getLabelString(LabelReadinessPath, "")
+	pcfg.Readiness.Enabled = c.getLabelBool(LabelReadiness, pcfg.Readiness.Path != "")
 	pcfg.Dashboard.Visible = c.getLabelBool(LabelDashboardVisible, model.DefaultDashboardVisible)
 	pcfg.Dashboard.Label = c.getLabelString(LabelDashboardLabel, pcfg.Hostname)
+	pcfg.Dashboard.Group = c.getLabelString(LabelDashboardGroup, "")
 
 	pcfg.Dashboard.Icon = c.getLabelString(LabelDashboardIcon, "")
 	if pcfg.Dashboard.Icon == "" {
@@ -154,6 +177,15 @@ func (c *container) newProxyConfig() (*model.Config, error) {
 
 	pcfg.Ports = c.getPorts()
 
+	// add port from the Traefik router if no port configured
+	if len(pcfg.Ports) == 0 && c.traefik {
+		if traefikPort, err := c.getTraefikPort(); err == nil {
+			pcfg.Ports[traefikPortName] = traefikPort
+		} else {
+			c.log.Debug().Err(err).Msg("no port from traefik labels")
+		}
+	}
+
 	// add port from legacy labels if no port configured
 	if len(pcfg.Ports) == 0 {
 		if legacyPort, err := c.getLegacyPort(); err == nil {
@@ -161,6 +193,10 @@ func (c *container) newProxyConfig() (*model.Config, error) {
 		}
 	}
 
+	pcfg.AddHTTPSRedirect()
+
+	c.setProvenance(pcfg)
+
 	return pcfg, nil
 }
 
@@ -182,6 +218,7 @@ func (c *container) getPorts() model.PortConfigList {
 			continue
 		}
 
+		var socket string
 		for _, v := range parts[1:] {
 			v = strings.TrimSpace(v)
 			switch v {
@@ -189,9 +226,190 @@ func (c *container) getPorts() model.PortConfigList {
 				port.TLSValidate = false
 			case PortOptionTailscaleFunnel:
 				port.Tailscale.Funnel = true
+			case PortOptionAcceptProxy:
+				port.AcceptProxyProtocol = true
+			case PortOptionCache:
+				port.Cache.Enabled = true
+			case PortOptionCacheDisk:
+				port.Cache.Enabled = true
+				port.Cache.Disk = true
+			case PortOptionCompress:
+				port.Compression.Enabled = true
+			case PortOptionHealthTLS:
+				port.HealthCheck.TLS = true
+			default:
+				if path, ok := strings.CutPrefix(v, PortOptionTailscalePath); ok {
+					port.Tailscale.Path = path
+				}
+				if name, ok := strings.CutPrefix(v, PortOptionOIDC); ok {
+					port.OIDC = name
+				}
+				if file, ok := strings.CutPrefix(v, PortOptionMTLSCA); ok {
+					port.MTLS.CAFile = file
+				}
+				if name, ok := strings.CutPrefix(v, PortOptionMTLSAllow); ok {
+					port.MTLS.AllowedNames = append(port.MTLS.AllowedNames, name)
+				}
+				if group, ok := strings.CutPrefix(v, PortOptionAllowGroup); ok {
+					port.AllowGroups = append(port.AllowGroups, group)
+				}
+				if cidr, ok := strings.CutPrefix(v, PortOptionAllowCIDR); ok {
+					port.AllowedCIDRs = append(port.AllowedCIDRs, cidr)
+				}
+				if cidr, ok := strings.CutPrefix(v, PortOptionDenyCIDR); ok {
+					port.DeniedCIDRs = append(port.DeniedCIDRs, cidr)
+				}
+				if country, ok := strings.CutPrefix(v, PortOptionAllowCountry); ok {
+					port.AllowedCountries = append(port.AllowedCountries, country)
+				}
+				if country, ok := strings.CutPrefix(v, PortOptionDenyCountry); ok {
+					port.DeniedCountries = append(port.DeniedCountries, country)
+				}
+				if size, ok := strings.CutPrefix(v, PortOptionMaxBody); ok {
+					if port.MaxRequestBody, err = strconv.ParseInt(size, 10, 64); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid max_body option")
+					}
+				}
+				if rps, ok := strings.CutPrefix(v, PortOptionRateLimit); ok {
+					if port.RequestsPerSecond, err = strconv.ParseFloat(rps, 64); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid rps option")
+					}
+				}
+				if burst, ok := strings.CutPrefix(v, PortOptionBurst); ok {
+					if port.Burst, err = strconv.Atoi(burst); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid burst option")
+					}
+				}
+				if size, ok := strings.CutPrefix(v, PortOptionMaxHeader); ok {
+					if port.MaxHeaderBytes, err = strconv.Atoi(size); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid max_header option")
+					}
+				}
+				if conns, ok := strings.CutPrefix(v, PortOptionMaxConns); ok {
+					if port.MaxConnections, err = strconv.Atoi(conns); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid max_conns option")
+					}
+				}
+				if backoff, ok := strings.CutPrefix(v, PortOptionAcceptBackoff); ok {
+					if port.AcceptBackoff, err = time.ParseDuration(backoff); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid accept_backoff option")
+					}
+				}
+				if family, ok := strings.CutPrefix(v, PortOptionIPFamily); ok {
+					port.IPFamily = family
+				}
+				if version, ok := strings.CutPrefix(v, PortOptionProxyProtocol); ok {
+					port.TargetProxyProtocol = version
+				}
+				if size, ok := strings.CutPrefix(v, PortOptionCacheSize); ok {
+					port.Cache.Enabled = true
+					if port.Cache.MaxSize, err = strconv.ParseInt(size, 10, 64); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid cache_size option")
+					}
+				}
+				if size, ok := strings.CutPrefix(v, PortOptionCompressMin); ok {
+					port.Compression.Enabled = true
+					if port.Compression.MinSize, err = strconv.Atoi(size); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid compress_min option")
+					}
+				}
+				if mediaType, ok := strings.CutPrefix(v, PortOptionCompressType); ok {
+					port.Compression.Enabled = true
+					port.Compression.Types = append(port.Compression.Types, mediaType)
+				}
+				if check, ok := strings.CutPrefix(v, PortOptionHealth); ok {
+					port.HealthCheck.Type = check
+				}
+				if interval, ok := strings.CutPrefix(v, PortOptionHealthInterval); ok {
+					if port.HealthCheck.Interval, err = time.ParseDuration(interval); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid health_interval option")
+					}
+				}
+				if timeout, ok := strings.CutPrefix(v, PortOptionHealthTimeout); ok {
+					if port.HealthCheck.Timeout, err = time.ParseDuration(timeout); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid health_timeout option")
+					}
+				}
+				if payload, ok := strings.CutPrefix(v, PortOptionHealthSend); ok {
+					port.HealthCheck.Send = payload
+				}
+				if payload, ok := strings.CutPrefix(v, PortOptionHealthExpect); ok {
+					port.HealthCheck.Expect = payload
+				}
+				if rise, ok := strings.CutPrefix(v, PortOptionHealthRise); ok {
+					if port.HealthCheck.HealthyThreshold, err = strconv.Atoi(rise); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid health_rise option")
+					}
+				}
+				if fall, ok := strings.CutPrefix(v, PortOptionHealthFall); ok {
+					if port.HealthCheck.UnhealthyThreshold, err = strconv.Atoi(fall); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid health_fall option")
+					}
+				}
+				if rule, ok := strings.CutPrefix(v, PortOptionCacheRule); ok {
+					port.Cache.Enabled = true
+					// the TTL is after the last colon, paths may have colons
+					i := strings.LastIndex(rule, ":")
+					if i < 0 {
+						c.log.Error().Str("port", k).Msg("invalid cache_rule option")
+						continue
+					}
+					ttl, err := time.ParseDuration(rule[i+1:])
+					if err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid cache_rule option")
+						continue
+					}
+					port.Cache.Rules = append(port.Cache.Rules, model.CacheRule{Path: rule[:i], TTL: ttl})
+				}
+				if middleware, ok := strings.CutPrefix(v, PortOptionMiddleware); ok {
+					m, err := model.ParseMiddleware(middleware)
+					if err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid middleware option")
+						continue
+					}
+					port.Middlewares = append(port.Middlewares, m)
+				}
+				if file, ok := strings.CutPrefix(v, PortOptionUpstreamCA); ok {
+					port.Upstream.CAFile = file
+				}
+				if file, ok := strings.CutPrefix(v, PortOptionUpstreamCert); ok {
+					port.Upstream.CertFile = file
+				}
+				if file, ok := strings.CutPrefix(v, PortOptionUpstreamKey); ok {
+					port.Upstream.KeyFile = file
+				}
+				if name, ok := strings.CutPrefix(v, PortOptionUpstreamName); ok {
+					port.Upstream.ServerName = name
+				}
+				if path, ok := strings.CutPrefix(v, PortOptionUnixSocket); ok {
+					socket = path
+				}
+				if rate, ok := strings.CutPrefix(v, PortOptionBreaker); ok {
+					if port.CircuitBreaker.ErrorRate, err = strconv.ParseFloat(rate, 64); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid breaker option")
+					}
+				}
+				if requests, ok := strings.CutPrefix(v, PortOptionBreakerMin); ok {
+					if port.CircuitBreaker.MinRequests, err = strconv.Atoi(requests); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid breaker_min option")
+					}
+				}
+				if timeout, ok := strings.CutPrefix(v, PortOptionBreakerTimeout); ok {
+					if port.CircuitBreaker.OpenTimeout, err = time.ParseDuration(timeout); err != nil {
+						c.log.Error().Err(err).Str("port", k).Msg("invalid breaker_timeout option")
+					}
+				}
 			}
 		}
 
+		// unix socket targets are shared with the container in a volume, they
+		// don't need the container address
+		if socket != "" && !port.IsRedirect {
+			port.ReplaceTarget(port.GetFirstTarget(), &url.URL{Scheme: model.TargetSchemeUnix, Path: socket})
+			ports[k] = port
+			continue
+		}
+
 		if !port.IsRedirect {
 			port, err = c.generateTargetFromFirstTarget(port)
 			if err == nil {
@@ -243,6 +461,7 @@ func (c *container) getTailscaleConfig() (*model.Tailscale, error) {
 		Verbose:      c.getLabelBool(LabelTsnetVerbose, model.DefaultTailscaleVerbose),
 		AuthKey:      authKey,
 		Tags:         tags,
+		ControlURL:   c.getLabelString(LabelControlURL, model.DefaultTailscaleControlURL),
 	}, nil
 }
 
@@ -307,20 +526,36 @@ func (c *container) getPublishedPort(internalPort string) string {
 	return ""
 }
 
-// getProxyHostname method returns the proxy URL from the container label.
+// getProxyHostname method returns the proxy hostname: the container label,
+// the Traefik router, the hostname template of the provider or the container
+// name, normalized to a valid DNS label.
 func (c *container) getProxyHostname() (string, error) {
 	c.log.Trace().Msg("getProxyHostname")
 	defer c.log.Trace().Msg("End getProxyHostname")
 
+	hostname, err := c.getRawHostname()
+	if err != nil {
+		return "", err
+	}
+
+	return normalizeHostname(hostname, c.hostnameMaxLength)
+}
+
+// getRawHostname method returns the proxy hostname before normalization.
+func (c *container) getRawHostname() (string, error) {
 	// Set custom proxy URL if present the Label in the container
 	if customName, ok := c.labels[LabelName]; ok {
-		// validate url
-		if _, err := url.Parse("https://" + customName); err != nil {
-			return "", err
-		}
 		return customName, nil
 	}
 
+	if hostname, ok := c.getTraefikHostname(); ok {
+		return hostname, nil
+	}
+
+	if hostname, ok, err := c.getTemplateHostname(); ok || err != nil {
+		return hostname, err
+	}
+
 	return c.getName(), nil
 }
 
@@ -330,6 +565,13 @@ func withTargetProviderName(name string) ContainerOption {
 	}
 }
 
+func withHostnameTemplate(tmpl *template.Template, maxLength int) ContainerOption {
+	return func(c *container) {
+		c.hostnameTemplate = tmpl
+		c.hostnameMaxLength = maxLength
+	}
+}
+
 func withDefaultBridgeAddress(address string) ContainerOption {
 	return func(c *container) {
 		c.defaultBridgeAddress = address
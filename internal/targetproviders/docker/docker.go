@@ -5,9 +5,12 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
+	"text/template"
 
 	"github.com/docker/docker/api/types"
 	ctypes "github.com/docker/docker/api/types/container"
@@ -19,6 +22,7 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
 )
@@ -27,6 +31,7 @@ type (
 	// Client struct implements TargetProvider
 	Client struct {
 		docker                   *client.Client
+		ssh                      *sshDialer
 		log                      zerolog.Logger
 		containers               map[string]*container
 		name                     string
@@ -35,22 +40,52 @@ type (
 		defaultProxyProvider     string
 		defaultBridgeAdress      string
 		tryDockerInternalNetwork bool
+		traefikLabels            bool
+		hostnameTemplate         *template.Template
+		hostnameMaxLength        int
+
+		eventsChan chan targetproviders.TargetEvent
 
 		mutex sync.Mutex
 	}
 )
 
-var _ targetproviders.TargetProvider = (*Client)(nil)
+var (
+	_ targetproviders.TargetProvider = (*Client)(nil)
+	_ targetproviders.Reloader       = (*Client)(nil)
+
+	ErrNotWatching = errors.New("docker provider is not watching events")
+)
 
 // New function returns a new Docker TargetProvider
 func New(log zerolog.Logger, name string, provider *config.DockerTargetProviderConfig) (*Client, error) {
-	newlog := log.With().Str("docker", name).Logger()
+	newlog := core.ModuleLogger(log, "docker").With().Str("docker", name).Logger()
 	newlog.Trace().Msg("New Docker TargetProvider")
 	defer newlog.Trace().Msg("End New Docker TargetProvider")
 
-	docker, err := client.NewClientWithOpts(
-		client.WithHost(provider.Host),
-		client.WithAPIVersionNegotiation())
+	opts := []client.Opt{client.WithHost(provider.Host), client.WithAPIVersionNegotiation()}
+
+	// ssh hosts are reached with the Docker socket of the host
+	var dialer *sshDialer
+	if isSSHHost(provider.Host) {
+		var err error
+		if dialer, err = newSSHDialer(provider.Host, provider.SSH); err != nil {
+			log.Error().Err(err).Msg("Error creating Docker SSH connection")
+			return nil, err
+		}
+		opts = []client.Opt{
+			client.WithHost(sshHost),
+			client.WithDialContext(dialer.DialContext),
+			client.WithAPIVersionNegotiation(),
+		}
+	}
+
+	hostnameTemplate, err := ParseHostnameTemplate(provider.HostnameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing hostnameTemplate: %w", err)
+	}
+
+	docker, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		log.Error().Err(err).Msg("Error creating Docker client")
 		return nil, err
@@ -58,12 +93,16 @@ func New(log zerolog.Logger, name string, provider *config.DockerTargetProviderC
 
 	c := &Client{
 		docker:                   docker,
+		ssh:                      dialer,
 		log:                      newlog,
 		name:                     name,
 		host:                     provider.Host,
 		defaultTargetHostname:    provider.TargetHostname,
 		defaultProxyProvider:     provider.DefaultProxyProvider,
 		tryDockerInternalNetwork: provider.TryDockerInternalNetwork,
+		traefikLabels:            provider.TraefikLabels,
+		hostnameTemplate:         hostnameTemplate,
+		hostnameMaxLength:        provider.HostnameMaxLength,
 		containers:               make(map[string]*container),
 	}
 
@@ -81,6 +120,9 @@ func (c *Client) Close() {
 	if c.docker != nil {
 		c.docker.Close()
 	}
+	if c.ssh != nil {
+		c.ssh.Close()
+	}
 }
 
 // AddTarget method implements TargetProvider AddTarget method
@@ -109,7 +151,11 @@ func (c *Client) DeleteProxy(id string) error {
 	c.log.Trace().Msgf("DeleteProxy %s", id)
 	defer c.log.Trace().Msgf("End DeleteProxy %s", id)
 
-	if _, ok := c.containers[id]; !ok {
+	c.mutex.Lock()
+	_, ok := c.containers[id]
+	c.mutex.Unlock()
+
+	if !ok {
 		return fmt.Errorf("container %s not found", id)
 	}
 
@@ -123,63 +169,101 @@ func (c *Client) GetDefaultProxyProviderName() string {
 	return c.defaultProxyProvider
 }
 
-// WatchEvents method implements TargetProvider WatchEvents method
-func (c *Client) WatchEvents(ctx context.Context, eventsChan chan targetproviders.TargetEvent, errChan chan error) {
+// WatchEvents method implements TargetProvider WatchEvents method.
+// The connection errors are sent as ActionDisconnected events, the events
+// are watched again until ctx is done.
+func (c *Client) WatchEvents(ctx context.Context, eventsChan chan targetproviders.TargetEvent, _ chan error) {
 	c.log.Trace().Msg("WatchEvents")
 	defer c.log.Trace().Msg("End WatchEvents")
-	// Filter Start/stop events for containers
-	//
-	eventsFilter := filters.NewArgs()
-	eventsFilter.Add("label", LabelIsEnabled)
-	eventsFilter.Add("type", string(devents.ContainerEventType))
-	eventsFilter.Add("event", string(devents.ActionDie))
-	eventsFilter.Add("event", string(devents.ActionStart))
+	c.mutex.Lock()
+	c.eventsChan = eventsChan
+	c.mutex.Unlock()
 
-	dockereventsChan, dockererrChan := c.docker.Events(ctx, devents.ListOptions{
-		Filters: eventsFilter,
-	})
+	go c.watch(ctx, eventsChan)
+}
 
-	go func() {
-		for {
-			select {
-			case devent := <-dockereventsChan:
+// Reload method implements targetproviders.Reloader Reload method.
+// The labels of all running containers are read again.
+func (c *Client) Reload(ctx context.Context) error {
+	c.mutex.Lock()
+	eventsChan := c.eventsChan
+	c.mutex.Unlock()
 
-				switch devent.Action {
-				case devents.ActionStart:
-					eventsChan <- c.getStartEvent(devent.Actor.ID)
-				case devents.ActionDie:
-					eventsChan <- c.getStopEvent(devent.Actor.ID)
-				}
+	if eventsChan == nil {
+		return ErrNotWatching
+	}
 
-			case err := <-dockererrChan:
-				errChan <- err
+	containers, err := c.listContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, container := range containers {
+		eventsChan <- targetproviders.TargetEvent{
+			ID:             container.ID,
+			TargetProvider: c,
+			Action:         targetproviders.ActionRestartProxy,
+		}
+	}
+
+	return nil
+}
+
+// listContainers method returns the running containers with enable set to
+// true, and the ones enabled in Traefik with traefikLabels.
+func (c *Client) listContainers(ctx context.Context) ([]ctypes.Summary, error) {
+	labels := []string{LabelIsEnabled}
+	if c.traefikLabels {
+		labels = append(labels, LabelTraefikIsEnabled)
+	}
+
+	var containers []ctypes.Summary
+	for _, label := range labels {
+		containerFilter := filters.NewArgs()
+		containerFilter.Add("label", label)
+
+		list, err := c.docker.ContainerList(ctx, ctypes.ListOptions{
+			Filters: containerFilter,
+			All:     false,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing containers: %w", err)
+		}
+
+		for _, ctn := range list {
+			if c.isEnabled(ctn.Labels) && !slices.ContainsFunc(containers, func(s ctypes.Summary) bool { return s.ID == ctn.ID }) {
+				containers = append(containers, ctn)
 			}
 		}
-	}()
+	}
 
-	go c.startAllProxies(ctx, eventsChan, errChan)
+	return containers, nil
 }
 
-func (c *Client) startAllProxies(ctx context.Context, eventsChan chan targetproviders.TargetEvent, errChan chan error) {
-	c.log.Trace().Msg("startAllProxies")
-	defer c.log.Trace().Msg("End startAllProxies")
-	// Filter containers with enable set to true
-	//
-	containerFilter := filters.NewArgs()
-	containerFilter.Add("label", LabelIsEnabled)
-
-	containers, err := c.docker.ContainerList(ctx, ctypes.ListOptions{
-		Filters: containerFilter,
-		All:     false,
-	})
-	if err != nil {
-		errChan <- fmt.Errorf("error listing containers: %w", err)
-		return
+// eventsFilter method returns the filter of the start and stop events of
+// the enabled containers. Docker filters need all the labels, so the events
+// of the containers enabled in Traefik are filtered with isEnabled.
+func (c *Client) eventsFilter() filters.Args {
+	eventsFilter := filters.NewArgs()
+	if !c.traefikLabels {
+		eventsFilter.Add("label", LabelIsEnabled)
 	}
+	eventsFilter.Add("type", string(devents.ContainerEventType))
+	eventsFilter.Add("event", string(devents.ActionDie))
+	eventsFilter.Add("event", string(devents.ActionStart))
 
-	for _, container := range containers {
-		eventsChan <- c.getStartEvent(container.ID)
+	return eventsFilter
+}
+
+// isEnabled method returns true if the labels enable tsdproxy in the
+// container. With traefikLabels, the containers enabled in Traefik are
+// enabled unless tsdproxy.enable is set.
+func (c *Client) isEnabled(labels map[string]string) bool {
+	if enable, ok := labels[LabelEnable]; ok {
+		return enable == "true"
 	}
+
+	return c.traefikLabels && labels[LabelTraefikEnable] == "true"
 }
 
 // newProxyConfig method returns a new proxyconfig.Config
@@ -191,6 +275,8 @@ func (c *Client) newProxyConfig(dcontainer ctypes.InspectResponse, dservice swar
 		withDefaultBridgeAddress(c.defaultBridgeAdress),
 		withDefaultTargetHostname(c.defaultTargetHostname),
 		withTargetProviderName(c.name),
+		withTraefikLabels(c.traefikLabels),
+		withHostnameTemplate(c.hostnameTemplate, c.hostnameMaxLength),
 	)
 
 	pcfg, err := ctn.newProxyConfig()
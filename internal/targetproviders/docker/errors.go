@@ -5,6 +5,7 @@ package docker
 
 import (
 	"errors"
+	"strconv"
 )
 
 type NoValidTargetFoundError struct {
@@ -15,8 +16,18 @@ func (n *NoValidTargetFoundError) Error() string {
 	return "no valid target found for " + n.containerName
 }
 
+// InvalidHostnameError struct is a hostname without valid DNS characters.
+type InvalidHostnameError struct {
+	Hostname string
+}
+
+func (e *InvalidHostnameError) Error() string {
+	return "invalid hostname " + strconv.Quote(e.Hostname)
+}
+
 var (
 	ErrNoPortFoundInContainer              = errors.New("no port found in container")
 	ErrNoValidTargetFoundForInternalPorts  = errors.New("no valid target found for internal ports")
 	ErrNoValidTargetFoundForPublishedPorts = errors.New("no valid target found for exposed ports")
+	ErrNoTraefikRouter                     = errors.New("no traefik router with a Host rule")
 )
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package docker
+
+import (
+	"strings"
+	"text/template"
+)
+
+const (
+	// maxHostnameLength is the limit of a DNS label.
+	maxHostnameLength = 63
+	shortIDLength     = 12
+
+	labelComposeProject = "com.docker.compose.project"
+	labelComposeService = "com.docker.compose.service"
+)
+
+// hostnameData struct is the data of the hostname templates, like
+// {{.ContainerName}}-{{.Project}}.
+type hostnameData struct {
+	ContainerName string
+	Project       string
+	Service       string
+	Hostname      string
+	Image         string
+	ID            string
+	Labels        map[string]string
+}
+
+// ParseHostnameTemplate function parses the hostname template of a Docker
+// target provider, nil without template.
+func ParseHostnameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	return template.New("hostname").Option("missingkey=zero").Parse(text)
+}
+
+// getTemplateHostname method returns the hostname of the template of the
+// target provider, false without template.
+func (c *container) getTemplateHostname() (string, bool, error) {
+	if c.hostnameTemplate == nil {
+		return "", false, nil
+	}
+
+	id := c.id
+	if len(id) > shortIDLength {
+		id = id[:shortIDLength]
+	}
+
+	var b strings.Builder
+	err := c.hostnameTemplate.Execute(&b, hostnameData{
+		ContainerName: c.getName(),
+		Project:       c.labels[labelComposeProject],
+		Service:       c.labels[labelComposeService],
+		Hostname:      c.hostname,
+		Image:         c.image,
+		ID:            id,
+		Labels:        c.labels,
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return b.String(), true, nil
+}
+
+// normalizeHostname function returns a valid DNS label of the hostname: in
+// lower case, with the invalid characters, like _ and ., replaced with -, and
+// at most maxLength characters.
+func normalizeHostname(hostname string, maxLength int) (string, error) {
+	if maxLength <= 0 || maxLength > maxHostnameLength {
+		maxLength = maxHostnameLength
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(hostname) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	normalized := b.String()
+	if len(normalized) > maxLength {
+		normalized = normalized[:maxLength]
+	}
+	normalized = strings.TrimRight(normalized, "-")
+
+	if normalized == "" {
+		return "", &InvalidHostnameError{Hostname: hostname}
+	}
+
+	return normalized, nil
+}
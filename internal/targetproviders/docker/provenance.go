@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package docker
+
+import (
+	"fmt"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+// labelFields are the labels of the fields of a proxy configuration, by the
+// path of the field.
+var labelFields = map[string]string{
+	"hostname":                  LabelName,
+	"proxyProvider":             LabelProxyProvider,
+	"proxyAccessLog":            LabelContainerAccessLog,
+	"redirectToHTTPS":           LabelRedirectToHTTPS,
+	"tailscale.authKey":         LabelAuthKey,
+	"tailscale.tags":            LabelTags,
+	"tailscale.controlUrl":      LabelControlURL,
+	"tailscale.ephemeral":       LabelEphemeral,
+	"tailscale.runWebClient":    LabelRunWebClient,
+	"tailscale.verbose":         LabelTsnetVerbose,
+	"dashboard.label":           LabelDashboardLabel,
+	"dashboard.icon":            LabelDashboardIcon,
+	"dashboard.group":           LabelDashboardGroup,
+	"dashboard.visible":         LabelDashboardVisible,
+	"accessLog.format":          LabelAccessLogFormat,
+	"accessLog.sink":            LabelAccessLogSink,
+	"accessLog.file.path":       LabelAccessLogFile,
+	"accessLog.syslog":          LabelAccessLogSyslog,
+	"accessLog.http.url":        LabelAccessLogURL,
+	"accessLog.sample":          LabelAccessLogSample,
+	"accessLog.redact.query":    LabelAccessLogRedactQuery,
+	"accessLog.redact.username": LabelAccessLogRedactUsername,
+	"accessLog.redact.headers":  LabelAccessLogRedactHeaders,
+	"cachePurge.zone":           LabelCachePurgeZone,
+	"cachePurge.urls":           LabelCachePurgeURLs,
+	"publicDns.name":            LabelPublicDNS,
+	"readiness.enabled":         LabelReadiness,
+	"readiness.path":            LabelReadinessPath,
+}
+
+// setProvenance method sets the labels of the container as the source of
+// the fields of the proxy configuration.
+func (c *container) setProvenance(pcfg *model.Config) {
+	for field, label := range labelFields {
+		if _, ok := c.labels[label]; ok {
+			pcfg.Provenance.Set(field, c.labelSource(label))
+		}
+	}
+
+	if _, ok := c.labels[LabelAuthKeyFile]; ok {
+		pcfg.Provenance.Set("tailscale.authKey", c.labelSource(LabelAuthKeyFile))
+	}
+	if _, ok := c.labels[LabelName]; !ok {
+		switch _, traefik := c.getTraefikHostname(); {
+		case traefik:
+			pcfg.Provenance.Set("hostname", c.traefikSource())
+		case c.hostnameTemplate != nil:
+			pcfg.Provenance.Set("hostname", "hostnameTemplate of docker provider "+c.targetProviderName)
+		default:
+			pcfg.Provenance.Set("hostname", "name of container "+c.getName())
+		}
+	}
+	if _, ok := c.labels[LabelDashboardIcon]; !ok {
+		pcfg.Provenance.Set("dashboard.icon", "guessed from image "+c.image)
+	}
+
+	for name := range pcfg.Ports {
+		if name == "legacy" {
+			pcfg.Provenance.Set("ports.legacy", "legacy labels on container "+c.getName())
+			continue
+		}
+		if name == traefikPortName {
+			pcfg.Provenance.Set("ports."+name, c.traefikSource())
+			continue
+		}
+		if name == model.HTTPSRedirectPortName {
+			pcfg.Provenance.Set("ports."+name, c.labelSource(LabelRedirectToHTTPS))
+			continue
+		}
+		pcfg.Provenance.Set("ports."+name, c.labelSource(name))
+	}
+
+	pcfg.Provenance.SetDefault(model.ProvenanceFields...)
+}
+
+// labelSource method returns the description of a label of the container.
+func (c *container) labelSource(label string) string {
+	return fmt.Sprintf("label %s on container %s", label, c.getName())
+}
+
+// traefikSource method returns the description of the Traefik router of the
+// container.
+func (c *container) traefikSource() string {
+	r, _ := c.getTraefikRouter()
+
+	return fmt.Sprintf("traefik router %s on container %s", r.name, c.getName())
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	ctypes "github.com/docker/docker/api/types/container"
+	devents "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+const (
+	RecordActionStart = "start"
+	RecordActionDie   = "die"
+)
+
+var ErrUnknownRecordAction = errors.New("unknown record action")
+
+// Record struct is a Docker event recorded by RecordEvents.
+// Started containers are stored inspected, so they can be replayed
+// without the containers.
+type Record struct {
+	Container *ctypes.InspectResponse `json:"container,omitempty"`
+	Service   *swarm.Service          `json:"service,omitempty"`
+	ID        string                  `json:"id"`
+	Action    string                  `json:"action"`
+	// Time since the start of the recording
+	Time time.Duration `json:"time"`
+}
+
+// RecordEvents method writes the events of tsdproxy enabled containers to w,
+// one json Record per line, until ctx is done. Running containers are
+// recorded as started at the beginning of the recording.
+func (c *Client) RecordEvents(ctx context.Context, w io.Writer) error {
+	start := time.Now()
+	enc := json.NewEncoder(w)
+
+	// subscribe before listing, so no event is lost
+	dockereventsChan, dockererrChan := c.docker.Events(ctx, devents.ListOptions{
+		Filters: c.eventsFilter(),
+	})
+
+	containers, err := c.listContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ctn := range containers {
+		if err := enc.Encode(c.newRecord(ctx, RecordActionStart, ctn.ID, 0)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-dockererrChan:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+
+		case devent := <-dockereventsChan:
+			if !c.isEnabled(devent.Actor.Attributes) {
+				continue
+			}
+
+			var action string
+			switch devent.Action {
+			case devents.ActionStart:
+				action = RecordActionStart
+			case devents.ActionDie:
+				action = RecordActionDie
+			default:
+				continue
+			}
+
+			rec := c.newRecord(ctx, action, devent.Actor.ID, time.Since(start))
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+
+			c.log.Info().Str("action", action).Str("container", devent.Actor.ID).Msg("event recorded")
+		}
+	}
+}
+
+// newRecord method returns a Record, with the container inspected if started.
+func (c *Client) newRecord(ctx context.Context, action, id string, t time.Duration) Record {
+	rec := Record{
+		ID:     id,
+		Action: action,
+		Time:   t,
+	}
+
+	if action != RecordActionStart {
+		return rec
+	}
+
+	dcontainer, err := c.docker.ContainerInspect(ctx, id)
+	if err != nil {
+		// the container may be already removed, the replay fails like the original event
+		c.log.Warn().Err(err).Str("container", id).Msg("error inspecting container")
+		return rec
+	}
+	rec.Container = &dcontainer
+
+	if serviceID, ok := dcontainer.Config.Labels["com.docker.swarm.service.id"]; ok {
+		dservice, _, err := c.docker.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
+		if err == nil {
+			rec.Service = &dservice
+		}
+	}
+
+	return rec
+}
+
+// ReplayEvents function sends the records read from r to the replay target
+// provider listening on socket. Records are sent with the recorded interval
+// divided by speed, a speed of 0 sends them without waiting.
+func ReplayEvents(ctx context.Context, r io.Reader, socket string, speed float64) (int, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return 0, fmt.Errorf("error connecting to replay provider: %w", err)
+	}
+	defer conn.Close()
+
+	enc := json.NewEncoder(conn)
+	scanner := bufio.NewScanner(r)
+	// inspected containers can be large
+	scanner.Buffer(nil, maxRecordSize)
+
+	start := time.Now()
+	sent := 0
+
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return sent, fmt.Errorf("error decoding record %d: %w", sent+1, err)
+		}
+
+		if speed > 0 {
+			wait := time.Duration(float64(rec.Time)/speed) - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return sent, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		if err := enc.Encode(rec); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, scanner.Err()
+}
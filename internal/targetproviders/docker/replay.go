@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/rs/zerolog"
+)
+
+const maxRecordSize = 4 << 20
+
+type (
+	// Replay struct implements TargetProvider with recorded Docker events,
+	// received from ReplayEvents. It's used to reproduce issues without the
+	// original containers.
+	Replay struct {
+		log                  zerolog.Logger
+		listener             net.Listener
+		records              map[string]Record
+		containers           map[string]*container
+		name                 string
+		socket               string
+		targetHostname       string
+		defaultProxyProvider string
+
+		mutex sync.Mutex
+	}
+)
+
+var (
+	_ targetproviders.TargetProvider = (*Replay)(nil)
+
+	ErrRecordNotFound = errors.New("container not found in replayed records")
+)
+
+// NewReplay function returns a new Replay TargetProvider listening on the socket.
+func NewReplay(log zerolog.Logger, name string, provider *config.ReplayTargetProviderConfig) (*Replay, error) {
+	newlog := core.ModuleLogger(log, "docker").With().Str("replay", name).Logger()
+
+	// remove the socket left by a previous run
+	if err := os.Remove(provider.Socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error removing replay socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", provider.Socket)
+	if err != nil {
+		return nil, fmt.Errorf("error listening replay socket: %w", err)
+	}
+
+	newlog.Warn().Str("socket", provider.Socket).Msg("replay target provider enabled, only use it for debugging")
+
+	return &Replay{
+		log:                  newlog,
+		listener:             listener,
+		name:                 name,
+		socket:               provider.Socket,
+		targetHostname:       provider.TargetHostname,
+		defaultProxyProvider: provider.DefaultProxyProvider,
+		records:              make(map[string]Record),
+		containers:           make(map[string]*container),
+	}, nil
+}
+
+// Close method implements TargetProvider Close method.
+func (r *Replay) Close() {
+	r.listener.Close()
+	os.Remove(r.socket)
+}
+
+// AddTarget method implements TargetProvider AddTarget method.
+// The proxy configuration is built from the container recorded with the event.
+func (r *Replay) AddTarget(id string) (*model.Config, error) {
+	r.mutex.Lock()
+	rec, ok := r.records[id]
+	r.mutex.Unlock()
+
+	if !ok || rec.Container == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	var dservice swarm.Service
+	if rec.Service != nil {
+		dservice = *rec.Service
+	}
+
+	// containers don't exist, autodetection must not be tried
+	ctn := newContainer(r.log, *rec.Container, dservice, false,
+		withDefaultTargetHostname(r.targetHostname),
+		withTargetProviderName(r.name),
+	)
+
+	pcfg, err := ctn.newProxyConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error getting proxy config: %w", err)
+	}
+
+	r.mutex.Lock()
+	r.containers[id] = ctn
+	r.mutex.Unlock()
+
+	return pcfg, nil
+}
+
+// DeleteProxy method implements TargetProvider DeleteProxy method.
+func (r *Replay) DeleteProxy(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.containers[id]; !ok {
+		return fmt.Errorf("container %s not found", id)
+	}
+
+	delete(r.containers, id)
+
+	return nil
+}
+
+// GetDefaultProxyProviderName method implements TargetProvider GetDefaultProxyProviderName method.
+func (r *Replay) GetDefaultProxyProviderName() string {
+	return r.defaultProxyProvider
+}
+
+// WatchEvents method implements TargetProvider WatchEvents method.
+// Each connection to the socket is a replay session.
+func (r *Replay) WatchEvents(ctx context.Context, eventsChan chan targetproviders.TargetEvent, errChan chan error) {
+	go func() {
+		<-ctx.Done()
+		r.listener.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := r.listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					errChan <- err
+				}
+				return
+			}
+
+			go r.replay(conn, eventsChan)
+		}
+	}()
+}
+
+// replay method sends the events of the records received in conn.
+func (r *Replay) replay(conn net.Conn, eventsChan chan targetproviders.TargetEvent) {
+	defer conn.Close()
+
+	r.log.Info().Msg("replay started")
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxRecordSize)
+
+	count := 0
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			r.log.Error().Err(err).Msg("error decoding record")
+			return
+		}
+
+		event := targetproviders.TargetEvent{
+			TargetProvider: r,
+			ID:             rec.ID,
+		}
+
+		switch rec.Action {
+		case RecordActionStart:
+			r.mutex.Lock()
+			r.records[rec.ID] = rec
+			r.mutex.Unlock()
+
+			event.Action = targetproviders.ActionStartProxy
+		case RecordActionDie:
+			event.Action = targetproviders.ActionStopProxy
+		default:
+			r.log.Error().Err(ErrUnknownRecordAction).Str("action", rec.Action).Msg("error replaying record")
+			continue
+		}
+
+		r.log.Debug().Str("action", rec.Action).Str("container", rec.ID).Msg("replaying event")
+
+		eventsChan <- event
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		r.log.Error().Err(err).Msg("error reading records")
+	}
+
+	r.log.Info().Int("events", count).Msg("replay finished")
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/sshauth"
+)
+
+const (
+	schemeSSH = "ssh"
+	// sshHost is the host of the Docker API requests over SSH, the
+	// connections are made by the SSH dialer.
+	sshHost = "http://docker.example.com"
+
+	defaultSSHPort   = "22"
+	defaultSSHSocket = "/var/run/docker.sock"
+	sshDialTimeout   = 10 * time.Second
+)
+
+var ErrSSHUser = errors.New("ssh docker hosts require a user, like ssh://user@host")
+
+// sshDialer struct connects to the Docker socket of a host over SSH. The SSH
+// connection is shared by the API requests and connected again after it
+// fails.
+type sshDialer struct {
+	config *ssh.ClientConfig
+	client *ssh.Client
+	addr   string
+	socket string
+	mtx    sync.Mutex
+}
+
+// isSSHHost function returns true if the Docker host is reached over SSH.
+func isSSHHost(host string) bool {
+	u, err := url.Parse(host)
+	return err == nil && u.Scheme == schemeSSH
+}
+
+// newSSHDialer function returns a dialer of the Docker socket of a
+// ssh://user@host[:port] host.
+func newSSHDialer(host string, cfg config.DockerSSHConfig) (*sshDialer, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, ErrSSHUser
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultSSHPort
+	}
+
+	hostKeyCallback, err := sshauth.HostKeyCallback(cfg.KnownHostsFile, cfg.InsecureIgnoreHostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := sshauth.Auth(cfg.KeyFile, cfg.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	socket := cfg.Socket
+	if socket == "" {
+		socket = defaultSSHSocket
+	}
+
+	return &sshDialer{
+		config: &ssh.ClientConfig{
+			User:            u.User.Username(),
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         sshDialTimeout,
+		},
+		addr:   net.JoinHostPort(u.Hostname(), port),
+		socket: socket,
+	}, nil
+}
+
+// DialContext method connects to the Docker socket of the host, the network
+// and address of the Docker client are ignored.
+func (d *sshDialer) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	client, err := d.sshClient()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.DialContext(ctx, "unix", d.socket)
+	if err != nil && ctx.Err() == nil {
+		// the SSH connection may be broken, the next dial connects again
+		d.reset(client)
+	}
+
+	return conn, err
+}
+
+// sshClient method returns the SSH connection to the host, connecting if
+// there isn't one.
+func (d *sshDialer) sshClient() (*ssh.Client, error) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	client, err := ssh.Dial("tcp", d.addr, d.config)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to ssh host %s: %w", d.addr, err)
+	}
+	d.client = client
+
+	return client, nil
+}
+
+// reset method closes the SSH connection if it's still the current one.
+func (d *sshDialer) reset(client *ssh.Client) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.client == client {
+		d.client = nil
+		_ = client.Close()
+	}
+}
+
+// Close method closes the SSH connection.
+func (d *sshDialer) Close() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.client == nil {
+		return nil
+	}
+
+	err := d.client.Close()
+	d.client = nil
+
+	return err
+}
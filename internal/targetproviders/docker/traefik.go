@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package docker
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+)
+
+const (
+	// Traefik labels read with traefikLabels, their names are case
+	// insensitive in Traefik
+	LabelTraefikEnable    = "traefik.enable"
+	LabelTraefikIsEnabled = LabelTraefikEnable + "=true"
+	LabelTraefikRouters   = "traefik.http.routers."
+	LabelTraefikServices  = "traefik.http.services."
+
+	// traefikPortName is the name of the port of the Traefik labels.
+	traefikPortName = "traefik"
+)
+
+var (
+	// traefikHostRule matches the Host matchers of a router rule.
+	traefikHostRule = regexp.MustCompile(`\bHost\(([^)]*)\)`)
+	// traefikHost matches the hosts of a Host matcher.
+	traefikHost = regexp.MustCompile("`([^`]+)`")
+)
+
+// traefikRouter struct stores the settings of a Traefik router used by the
+// proxy.
+type traefikRouter struct {
+	name    string
+	host    string
+	service string
+	// tls is the value of the tls label, empty without it
+	tls string
+	// hasTLSOptions is set with any tls.* label, like tls.certresolver
+	hasTLSOptions bool
+}
+
+// getTraefikRouter method returns the first router, sorted by name, with a
+// Host rule.
+func (c *container) getTraefikRouter() (traefikRouter, bool) {
+	labels := c.traefikLabels()
+
+	var names []string
+	for k := range labels {
+		if rest, ok := strings.CutPrefix(k, LabelTraefikRouters); ok {
+			name, _, _ := strings.Cut(rest, ".")
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		prefix := LabelTraefikRouters + name + "."
+
+		host := traefikRuleHost(labels[prefix+"rule"])
+		if host == "" {
+			continue
+		}
+
+		r := traefikRouter{
+			name:    name,
+			host:    host,
+			service: labels[prefix+"service"],
+			tls:     labels[prefix+"tls"],
+		}
+		for k := range labels {
+			if strings.HasPrefix(k, prefix+"tls.") {
+				r.hasTLSOptions = true
+			}
+		}
+
+		return r, true
+	}
+
+	return traefikRouter{}, false
+}
+
+// getTraefikHostname method returns the hostname of the Host rule of the
+// Traefik router, the first label of the domain name.
+func (c *container) getTraefikHostname() (string, bool) {
+	if !c.traefik {
+		return "", false
+	}
+
+	r, ok := c.getTraefikRouter()
+	if !ok {
+		return "", false
+	}
+
+	hostname, _, _ := strings.Cut(r.host, ".")
+
+	return hostname, true
+}
+
+// getTraefikPort method returns the port of the Traefik router, https
+// unless the router disables TLS, to the server port of its service.
+func (c *container) getTraefikPort() (model.PortConfig, error) {
+	r, ok := c.getTraefikRouter()
+	if !ok {
+		return model.PortConfig{}, ErrNoTraefikRouter
+	}
+
+	labels := c.traefikLabels()
+
+	service := r.service
+	if service == "" {
+		// Traefik links the routers to the only service of the container
+		services := traefikServices(labels)
+		if len(services) == 1 {
+			service = services[0]
+		}
+	}
+
+	prefix := LabelTraefikServices + service + ".loadbalancer.server."
+	targetPort := labels[prefix+"port"]
+	if targetPort == "" {
+		// without port, Traefik uses the port exposed by the container
+		targetPort = c.getIntenalPortLegacy()
+	}
+	scheme := labels[prefix+"scheme"]
+	if scheme == "" {
+		scheme = DefaultTargetScheme
+	}
+
+	proxy := "443/https"
+	if tls, err := strconv.ParseBool(r.tls); err == nil && !tls && !r.hasTLSOptions {
+		proxy = "80/http"
+	}
+
+	port, err := model.NewPortLongLabel(proxy + ":" + targetPort + "/" + scheme)
+	if err != nil {
+		return port, err
+	}
+	port.TLSValidate = model.DefaultTLSValidate
+
+	return c.generateTargetFromFirstTarget(port)
+}
+
+// traefikLabels method returns the Traefik labels of the container, with
+// lower case names.
+func (c *container) traefikLabels() map[string]string {
+	labels := make(map[string]string)
+	for k, v := range c.labels {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "traefik.") {
+			labels[k] = strings.TrimSpace(v)
+		}
+	}
+
+	return labels
+}
+
+// traefikServices function returns the names of the Traefik services of
+// the labels.
+func traefikServices(labels map[string]string) []string {
+	var services []string
+	for k := range labels {
+		if rest, ok := strings.CutPrefix(k, LabelTraefikServices); ok {
+			name, _, _ := strings.Cut(rest, ".")
+			if !slices.Contains(services, name) {
+				services = append(services, name)
+			}
+		}
+	}
+
+	return services
+}
+
+// traefikRuleHost function returns the first host of the Host matchers of a
+// router rule, like Host(`app.example.com`).
+func traefikRuleHost(rule string) string {
+	for _, m := range traefikHostRule.FindAllStringSubmatch(rule, -1) {
+		if host := traefikHost.FindStringSubmatch(m[1]); host != nil {
+			return strings.TrimSpace(host[1])
+		}
+	}
+
+	return ""
+}
+
+func withTraefikLabels(enabled bool) ContainerOption {
+	return func(c *container) {
+		c.traefik = enabled
+	}
+}
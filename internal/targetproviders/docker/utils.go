@@ -26,6 +26,18 @@ func (c *container) getLabelBool(label string, defaultValue bool) bool {
 	return value
 }
 
+// getLabelInt method returns an int from a container label.
+func (c *container) getLabelInt(label string, defaultValue int) int {
+	value := defaultValue
+	if valueString, ok := c.labels[label]; ok {
+		// if error, keep default
+		if valueInt, err := strconv.Atoi(valueString); err == nil {
+			value = valueInt
+		}
+	}
+	return value
+}
+
 // getLabelString method returns a string from a container label.
 func (c *container) getLabelString(label string, defaultValue string) string {
 	// Set default value
@@ -37,6 +49,18 @@ func (c *container) getLabelString(label string, defaultValue string) string {
 	return value
 }
 
+// getLabelList method returns the comma separated values of a label.
+func (c *container) getLabelList(label string) []string {
+	var values []string
+	for v := range strings.SplitSeq(c.labels[label], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
 // getAuthKeyFromAuthFile method returns a auth key from a file.
 func (c *container) getAuthKeyFromAuthFile(authKey string) (string, error) {
 	authKeyFile, ok := c.labels[LabelAuthKeyFile]
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package docker
+
+import (
+	"context"
+	"time"
+
+	devents "github.com/docker/docker/api/types/events"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+)
+
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+// watch method forwards the events of the containers until ctx is done. If
+// the Docker daemon can't be reached, like when it's restarted, it connects
+// again with backoff and syncs the containers with the proxies.
+func (c *Client) watch(ctx context.Context, eventsChan chan targetproviders.TargetEvent) {
+	backoff := minReconnectBackoff
+	connected := true
+
+	for {
+		err := c.watchOnce(ctx, eventsChan, func() {
+			backoff = minReconnectBackoff
+			if !connected {
+				connected = true
+				c.log.Info().Msg("Reconnected to Docker")
+				eventsChan <- targetproviders.TargetEvent{
+					TargetProvider: c,
+					Action:         targetproviders.ActionConnected,
+				}
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if connected {
+			connected = false
+			eventsChan <- targetproviders.TargetEvent{
+				TargetProvider: c,
+				Err:            err,
+				Action:         targetproviders.ActionDisconnected,
+			}
+		}
+		c.log.Error().Err(err).Str("retry", backoff.String()).Msg("Error watching Docker events")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+// watchOnce method syncs the containers and forwards their events, calling
+// synced after the sync. It returns the error that stopped the events.
+func (c *Client) watchOnce(ctx context.Context, eventsChan chan targetproviders.TargetEvent, synced func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the events are subscribed before the sync, so no event is lost
+	// between the list of the containers and the subscription
+	dockereventsChan, dockererrChan := c.docker.Events(ctx, devents.ListOptions{
+		Filters: c.eventsFilter(),
+	})
+
+	if err := c.sync(ctx, eventsChan); err != nil {
+		return err
+	}
+	synced()
+
+	for {
+		select {
+		case devent := <-dockereventsChan:
+			if !c.isEnabled(devent.Actor.Attributes) {
+				continue
+			}
+
+			switch devent.Action {
+			case devents.ActionStart:
+				eventsChan <- c.getStartEvent(devent.Actor.ID)
+			case devents.ActionDie:
+				eventsChan <- c.getStopEvent(devent.Actor.ID)
+			}
+
+		case err := <-dockererrChan:
+			return err
+		}
+	}
+}
+
+// sync method starts the proxies of the new running containers and stops
+// the proxies of the containers that aren't running anymore. The proxies
+// of the other containers are restarted, their addresses may have changed.
+func (c *Client) sync(ctx context.Context, eventsChan chan targetproviders.TargetEvent) error {
+	containers, err := c.listContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	known := make(map[string]struct{}, len(c.containers))
+	for id := range c.containers {
+		known[id] = struct{}{}
+	}
+	c.mutex.Unlock()
+
+	for _, ctn := range containers {
+		if _, ok := known[ctn.ID]; !ok {
+			eventsChan <- c.getStartEvent(ctn.ID)
+			continue
+		}
+
+		delete(known, ctn.ID)
+		eventsChan <- targetproviders.TargetEvent{
+			TargetProvider: c,
+			ID:             ctn.ID,
+			Action:         targetproviders.ActionRestartProxy,
+		}
+	}
+
+	for id := range known {
+		eventsChan <- c.getStopEvent(id)
+	}
+
+	return nil
+}
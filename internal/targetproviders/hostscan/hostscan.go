@@ -0,0 +1,236 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package hostscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Client struct implements TargetProvider and Discoverer
+	Client struct {
+		log        zerolog.Logger
+		cancel     context.CancelFunc
+		discovered map[string]model.DiscoveredService
+		config     *config.HostScanTargetProviderConfig
+		name       string
+		listFile   string
+		mtx        sync.Mutex
+	}
+
+	// listPort and listProxy are the entries written to the list file on approval.
+	listPort struct {
+		Targets []string `yaml:"targets"`
+	}
+
+	listProxy struct {
+		Ports map[string]listPort `yaml:"ports"`
+	}
+)
+
+var (
+	_ targetproviders.TargetProvider = (*Client)(nil)
+	_ targetproviders.Discoverer     = (*Client)(nil)
+
+	ErrTargetNotSupported = errors.New("host scan only proposes targets, approve them to a list")
+	ErrInvalidListFile    = errors.New("list file is not a map")
+)
+
+// New function returns a new host scan TargetProvider
+func New(log zerolog.Logger, name string, provider *config.HostScanTargetProviderConfig) (*Client, error) {
+	list, ok := config.Config.Lists[provider.List]
+	if !ok {
+		return nil, &config.ListNotFoundError{ListName: provider.List}
+	}
+
+	return &Client{
+		log:        core.ModuleLogger(log, "hostscan").With().Str("hostscan", name).Logger(),
+		name:       name,
+		config:     provider,
+		listFile:   list.Filename,
+		discovered: make(map[string]model.DiscoveredService),
+	}, nil
+}
+
+// WatchEvents method implements TargetProvider WatchEvents method.
+// Discovered services are only proxied after approval, so no events are sent.
+func (c *Client) WatchEvents(ctx context.Context, _ chan targetproviders.TargetEvent, _ chan error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c.mtx.Lock()
+	c.cancel = cancel
+	c.mtx.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			c.scan()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// GetDefaultProxyProviderName method implements TargetProvider GetDefaultProxyProviderName method
+func (c *Client) GetDefaultProxyProviderName() string {
+	return ""
+}
+
+// Close method implements TargetProvider Close method
+func (c *Client) Close() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// AddTarget method implements TargetProvider AddTarget method
+func (c *Client) AddTarget(_ string) (*model.Config, error) {
+	return nil, ErrTargetNotSupported
+}
+
+// DeleteProxy method implements TargetProvider DeleteProxy method
+func (c *Client) DeleteProxy(_ string) error {
+	return ErrTargetNotSupported
+}
+
+// GetDiscovered method implements Discoverer GetDiscovered method
+func (c *Client) GetDiscovered() []model.DiscoveredService {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	services := make([]model.DiscoveredService, 0, len(c.discovered))
+	for _, s := range c.discovered {
+		services = append(services, s)
+	}
+	slices.SortFunc(services, func(a, b model.DiscoveredService) int {
+		return int(a.Port) - int(b.Port)
+	})
+
+	return services
+}
+
+// Approve method implements Discoverer Approve method.
+// The service is added to the list file, and the list provider starts the proxy.
+func (c *Client) Approve(id string) error {
+	c.mtx.Lock()
+	service, ok := c.discovered[id]
+	c.mtx.Unlock()
+
+	if !ok {
+		return fmt.Errorf("discovered service %s not found", id)
+	}
+
+	// use a yaml.Node to keep the comments of the list file
+	var doc yaml.Node
+	file := config.NewConfigFile(c.log, c.listFile, &doc)
+	if err := file.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error reading list file: %w", err)
+	}
+
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return ErrInvalidListFile
+	}
+
+	var value yaml.Node
+	err := value.Encode(listProxy{
+		Ports: map[string]listPort{
+			"443/https": {Targets: []string{service.Target}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: service.Name}, &value)
+
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("error writing list file: %w", err)
+	}
+
+	c.mtx.Lock()
+	delete(c.discovered, id)
+	c.mtx.Unlock()
+
+	c.log.Info().Str("service", service.Name).Str("list", c.config.List).Msg("discovered service approved")
+
+	return nil
+}
+
+// scan method updates the discovered services with the allowed listening ports.
+func (c *Client) scan() {
+	ports, err := listeningPorts(c.config.ProcDir)
+	if err != nil {
+		c.log.Error().Err(err).Msg("error scanning listening ports")
+		return
+	}
+
+	existing := c.listProxies()
+
+	discovered := make(map[string]model.DiscoveredService)
+	for _, port := range ports {
+		name, ok := c.config.Ports[port]
+		if !ok {
+			continue
+		}
+		if _, ok := existing[name]; ok {
+			continue
+		}
+
+		discovered[name] = model.DiscoveredService{
+			TargetProvider: c.name,
+			ID:             name,
+			Name:           name,
+			Port:           port,
+			Target:         "http://" + net.JoinHostPort(c.config.TargetHostname, strconv.Itoa(int(port))),
+		}
+	}
+
+	c.log.Debug().Int("count", len(discovered)).Msg("host scan finished")
+
+	c.mtx.Lock()
+	c.discovered = discovered
+	c.mtx.Unlock()
+}
+
+// listProxies method returns the proxies already defined in the list file.
+func (c *Client) listProxies() map[string]any {
+	proxies := make(map[string]any)
+
+	file := config.NewConfigFile(c.log, c.listFile, &proxies)
+	if err := file.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		c.log.Error().Err(err).Msg("error reading list file")
+	}
+
+	return proxies
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package hostscan
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// tcpListenState is the state of a listening socket in /proc/net/tcp
+	tcpListenState = "0A"
+
+	procLocalAddressField = 1
+	procStateField        = 3
+)
+
+// listeningPorts function returns the listening TCP ports found in /proc/net/tcp and /proc/net/tcp6.
+func listeningPorts(procDir string) ([]uint16, error) {
+	seen := make(map[uint16]struct{})
+	ports := []uint16{}
+
+	for _, name := range []string{"tcp", "tcp6"} {
+		found, err := readProcNet(filepath.Join(procDir, "net", name))
+		if err != nil {
+			// tcp6 is not available when IPv6 is disabled
+			if errors.Is(err, fs.ErrNotExist) && name == "tcp6" {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, port := range found {
+			if _, ok := seen[port]; ok {
+				continue
+			}
+			seen[port] = struct{}{}
+			ports = append(ports, port)
+		}
+	}
+
+	return ports, nil
+}
+
+// readProcNet function parses a /proc/net/tcp file and returns the listening ports.
+func readProcNet(filename string) ([]uint16, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ports := []uint16{}
+
+	scanner := bufio.NewScanner(f)
+	// skip header
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= procStateField || fields[procStateField] != tcpListenState {
+			continue
+		}
+
+		// local address format is HEXIP:HEXPORT
+		_, hexPort, ok := strings.Cut(fields[procLocalAddressField], ":")
+		if !ok {
+			continue
+		}
+
+		port, err := strconv.ParseUint(hexPort, 16, 16)
+		if err != nil {
+			continue
+		}
+
+		ports = append(ports, uint16(port))
+	}
+
+	return ports, scanner.Err()
+}
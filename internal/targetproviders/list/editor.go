@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package list
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidList   = errors.New("invalid list")
+	ErrNoTargets     = errors.New("no targets")
+	ErrInvalidTarget = errors.New("invalid target")
+	ErrNegativeLimit = errors.New("maxRequestBody, requestsPerSecond and burst can't be negative")
+	ErrNoPurgeZone   = errors.New("cachePurge urls require a zone")
+	ErrNotEditable   = errors.New("lists of a directory, glob or URL can't be edited in the dashboard")
+)
+
+var _ targetproviders.Editor = (*Client)(nil)
+
+// Editable method returns true if the list is a local file, lists of a
+// directory or glob are edited in their files and remote lists in their
+// source.
+func (c *Client) Editable() bool {
+	return c.remote == nil && len(c.patterns) == 1 && !hasMeta(c.patterns[0])
+}
+
+// Source method returns the content of the list file.
+func (c *Client) Source() ([]byte, error) {
+	if !c.Editable() {
+		return nil, ErrNotEditable
+	}
+
+	return os.ReadFile(c.filename)
+}
+
+// SaveSource method validates the list and saves it to the list file.
+// The proxies are updated by the file watcher, like any other change.
+func (c *Client) SaveSource(data []byte) error {
+	if !c.Editable() {
+		return ErrNotEditable
+	}
+
+	// use a yaml.Node to keep the comments of the list file
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if err := validateList(data); err != nil {
+		return err
+	}
+
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	c.log.Info().Msg("saving list edited in dashboard")
+
+	return config.NewConfigFile(c.log, c.filename, &doc).Save()
+}
+
+// validateList function returns the errors of a list file. Unknown fields and
+// wrong types are errors, like when the file is loaded, and so are ports that
+// would be skipped when loading.
+func validateList(data []byte) error {
+	list, err := decodeList(data)
+	if err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%w: %s", ErrInvalidList, strings.Join(typeErr.Errors, ", "))
+		}
+		return err
+	}
+
+	proxies := list.Proxies
+
+	var errs error
+	for _, name := range slices.Sorted(maps.Keys(proxies)) {
+		if p := proxies[name].CachePurge; len(p.URLs) > 0 && !p.IsEnabled() {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", name, ErrNoPurgeZone))
+		}
+		for k, v := range proxies[name].Ports {
+			if err := validatePort(k, v); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("%s: port %s: %w", name, k, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validatePort function returns the error of a port of the list.
+func validatePort(label string, p port) error {
+	if _, err := model.NewPortShortLabel(label); err != nil {
+		return err
+	}
+
+	if p.MaxRequestBody < 0 || p.RequestsPerSecond < 0 || p.Burst < 0 ||
+		p.MaxHeaderBytes < 0 || p.MaxConnections < 0 || p.AcceptBackoff < 0 {
+		return ErrNegativeLimit
+	}
+
+	// unknown middlewares are reported when the port is created
+	for _, m := range p.Middlewares {
+		if m.Name == "" {
+			return model.ErrInvalidMiddleware
+		}
+	}
+
+	for _, cidrs := range [][]string{p.AllowedCIDRs, p.DeniedCIDRs} {
+		if _, err := model.ParseCIDRs(cidrs); err != nil {
+			return err
+		}
+	}
+
+	if len(p.Targets) == 0 {
+		return ErrNoTargets
+	}
+
+	for _, target := range p.Targets {
+		// templates are resolved when the proxy starts
+		if isTemplate(target) {
+			if _, err := parseTarget(target, validateFuncs); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrInvalidTarget, target, err)
+			}
+			continue
+		}
+
+		targetURL, err := url.Parse(target)
+		if err != nil || !isValidTarget(targetURL) {
+			return fmt.Errorf("%w: %s", ErrInvalidTarget, target)
+		}
+	}
+
+	return nil
+}
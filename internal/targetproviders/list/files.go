@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package list
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey is the key of the include directive of a list file, it can't be
+// the name of a proxy.
+const includeKey = "include"
+
+type (
+	// listFile struct is the content of a list file, the proxies and the
+	// files it includes.
+	listFile struct {
+		Proxies configProxyList `yaml:",inline"`
+		Include []string        `yaml:"include"`
+	}
+
+	// loadedList struct is the proxies of the files of a list.
+	loadedList struct {
+		proxies configProxyList
+		lines   fieldLines
+		// files are the files of the proxies, by proxy
+		files map[string]string
+		// patterns are the files and globs of the list and its includes
+		patterns []string
+		// failed are the files that couldn't be read
+		failed []string
+	}
+)
+
+// hasMeta function returns true if a path is a glob.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// listPatterns function returns the globs of the files of a list or an
+// include: the file, the yaml files of a directory, or the glob.
+func listPatterns(filename string) []string {
+	if hasMeta(filename) {
+		return []string{filepath.Clean(filename)}
+	}
+
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		return []string{
+			filepath.Join(filename, "*.yaml"),
+			filepath.Join(filename, "*.yml"),
+		}
+	}
+
+	return []string{filepath.Clean(filename)}
+}
+
+// decodeList function decodes a list file strictly, like the configuration
+// files.
+func decodeList(data []byte) (listFile, error) {
+	list := listFile{Proxies: configProxyList{}}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&list); err != nil && !errors.Is(err, io.EOF) {
+		return list, err
+	}
+
+	return list, nil
+}
+
+// loadList function reads the files of the patterns and the files they
+// include. Files are read in order and a proxy is defined by the first file
+// that has it, the errors of each file are joined.
+func loadList(patterns []string) (*loadedList, error) {
+	l := &loadedList{
+		proxies: configProxyList{},
+		lines:   fieldLines{},
+		files:   make(map[string]string),
+	}
+
+	visited := make(map[string]struct{})
+
+	var errs error
+	var load func(patterns []string)
+	load = func(patterns []string) {
+		for _, pattern := range patterns {
+			l.patterns = append(l.patterns, pattern)
+
+			files, err := filepath.Glob(pattern)
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("%s: %w", pattern, err))
+				continue
+			}
+			// a file that isn't a glob must exist
+			if len(files) == 0 && !hasMeta(pattern) {
+				errs = errors.Join(errs, fmt.Errorf("%s: %w", pattern, os.ErrNotExist))
+				l.failed = append(l.failed, pattern)
+				continue
+			}
+
+			for _, file := range files {
+				if _, ok := visited[file]; ok {
+					continue
+				}
+				visited[file] = struct{}{}
+
+				includes, err := l.loadFile(file)
+				if err != nil {
+					errs = errors.Join(errs, err)
+					l.failed = append(l.failed, file)
+					continue
+				}
+
+				// includes are relative to the file that includes them
+				var included []string
+				for _, include := range includes {
+					if !filepath.IsAbs(include) {
+						include = filepath.Join(filepath.Dir(file), include)
+					}
+					included = append(included, listPatterns(include)...)
+				}
+				load(included)
+			}
+		}
+	}
+	load(patterns)
+
+	return l, errs
+}
+
+// loadFile method reads the proxies of a file and returns the files it
+// includes.
+func (l *loadedList) loadFile(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := decodeList(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	lines, err := readFieldLines(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	var errs error
+	for _, name := range slices.Sorted(maps.Keys(list.Proxies)) {
+		if other, ok := l.files[name]; ok {
+			errs = errors.Join(errs, fmt.Errorf("%s: proxy %s is already defined in %s", file, name, other))
+			continue
+		}
+
+		l.proxies[name] = list.Proxies[name]
+		l.lines[name] = lines[name]
+		l.files[name] = file
+	}
+
+	return list.Include, errs
+}
+
+// isListFile method returns true if a file is, or can be, a file of the list.
+func (l *loadedList) isListFile(name string) bool {
+	name = filepath.Clean(name)
+	for _, pattern := range l.patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchedDirs method returns the directories of the files of the list, new
+// files are only detected in directories that aren't globs.
+func (l *loadedList) watchedDirs() []string {
+	var dirs []string
+	for _, pattern := range l.patterns {
+		dir := filepath.Dir(pattern)
+		if !hasMeta(dir) && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, file := range l.files {
+		if dir := filepath.Dir(file); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// watch method watches the directories of the files of the list, the list
+// is reloaded when one of its files changes or a new file matches it.
+func (c *Client) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	c.watcher = watcher
+	c.mtx.Unlock()
+
+	c.watchDirs()
+
+	go func() {
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				c.onFileChange(e)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				c.log.Error().Err(err).Msg("error watching list files")
+			}
+		}
+	}()
+
+	return nil
+}
+
+// watchDirs method adds the directories of the files of the list to the
+// watcher, the directories of new includes are added after a reload.
+func (c *Client) watchDirs() {
+	c.mtx.Lock()
+	watcher := c.watcher
+	list := c.list
+	c.mtx.Unlock()
+
+	if watcher == nil {
+		return
+	}
+
+	for _, dir := range list.watchedDirs() {
+		if slices.Contains(watcher.WatchList(), dir) {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			c.log.Error().Err(err).Str("dir", dir).Msg("error watching list directory")
+		}
+	}
+}
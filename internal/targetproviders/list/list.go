@@ -6,16 +6,21 @@ package list
 import (
 	"context"
 	"fmt"
-	"maps"
 	"net/url"
+	"os"
 	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
 	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
 
 	"github.com/creasty/defaults"
+	"github.com/docker/docker/client"
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog"
 )
@@ -23,35 +28,77 @@ import (
 type (
 	// Client struct implements TargetProvider
 	Client struct {
-		log           zerolog.Logger
-		file          *config.ConfigFile
-		configProxies configProxyList
-		proxies       configProxyList
-		eventsChan    chan targetproviders.TargetEvent
-		errChan       chan error
-		name          string
-		config        config.ListTargetProviderConfig
-		mtx           sync.Mutex
+		log        zerolog.Logger
+		list       *loadedList
+		remote     *remote
+		cancel     context.CancelFunc
+		proxies    configProxyList
+		watcher    *fsnotify.Watcher
+		docker     *client.Client
+		eventsChan chan targetproviders.TargetEvent
+		errChan    chan error
+		name       string
+		filename   string
+		// patterns are the globs of the files of the list
+		patterns  []string
+		config    config.ListTargetProviderConfig
+		mtx       sync.Mutex
+		reloadMtx sync.Mutex
 	}
 
 	configProxyList map[string]proxyConfig
 
 	proxyConfig struct {
-		Dashboard     model.Dashboard `validate:"dive" yaml:"dashboard"`
-		Ports         map[string]port `yaml:"ports"`
-		ProxyProvider string          `yaml:"proxyProvider"`
-		Tailscale     model.Tailscale `yaml:"tailscale"`
+		Dashboard     model.Dashboard  `validate:"dive" yaml:"dashboard"`
+		Ports         map[string]port  `yaml:"ports"`
+		ProxyProvider string           `yaml:"proxyProvider"`
+		Tailscale     model.Tailscale  `yaml:"tailscale"`
+		Exec          model.Exec       `yaml:"exec"`
+		AccessLog     model.AccessLog  `validate:"dive" yaml:"accessLog"`
+		CachePurge    model.CachePurge `yaml:"cachePurge"`
+		PublicDNS     model.PublicDNS  `yaml:"publicDns"`
+		Readiness     model.Readiness  `validate:"dive" yaml:"readiness"`
+		Hooks         model.Hooks      `validate:"dive" yaml:"hooks"`
+		// RedirectToHTTPS adds a port 80 that redirects to the https port
+		RedirectToHTTPS bool `yaml:"redirectToHTTPS,omitempty"`
 	}
 
 	port struct {
-		Targets     []string            `yaml:"targets,omitempty"`
-		Tailscale   model.TailscalePort `validate:"dive" yaml:"tailscale"`
-		IsRedirect  bool                `default:"false" validate:"boolean" yaml:"isRedirect,omitempty"`
-		TLSValidate bool                `validate:"boolean" default:"true" yaml:"tlsValidate"`
+		Targets           []string               `yaml:"targets,omitempty"`
+		Tailscale         model.TailscalePort    `validate:"dive" yaml:"tailscale"`
+		IsRedirect        bool                   `default:"false" validate:"boolean" yaml:"isRedirect,omitempty"`
+		TLSValidate       bool                   `validate:"boolean" default:"true" yaml:"tlsValidate"`
+		DirectoryListing  bool                   `default:"false" validate:"boolean" yaml:"directoryListing,omitempty"`
+		OIDC              string                 `yaml:"oidc,omitempty"`
+		AllowGroups       []string               `yaml:"allowGroups,omitempty"`
+		MaxRequestBody    int64                  `validate:"gte=0" yaml:"maxRequestBody,omitempty"`
+		RequestsPerSecond float64                `validate:"gte=0" yaml:"requestsPerSecond,omitempty"`
+		Burst             int                    `validate:"gte=0" yaml:"burst,omitempty"`
+		MaxHeaderBytes    int                    `validate:"gte=0" yaml:"maxHeaderBytes,omitempty"`
+		MaxConnections    int                    `validate:"gte=0" yaml:"maxConnections,omitempty"`
+		AcceptBackoff     time.Duration          `validate:"gte=0" yaml:"acceptBackoff,omitempty"`
+		IPFamily          string                 `validate:"omitempty,oneof=dual ipv4 ipv6" yaml:"ipFamily,omitempty"`
+		TargetProxy       string                 `validate:"omitempty,oneof=v1 v2" yaml:"targetProxyProtocol,omitempty"`
+		AcceptProxy       bool                   `validate:"boolean" yaml:"acceptProxyProtocol,omitempty"`
+		MTLS              model.MTLS             `validate:"dive" yaml:"mtls"`
+		Cache             model.Cache            `validate:"dive" yaml:"cache"`
+		Compression       model.Compression      `validate:"dive" yaml:"compression"`
+		HealthCheck       model.HealthCheck      `validate:"dive" yaml:"healthCheck"`
+		Retry             model.Retry            `validate:"dive" yaml:"retry,omitempty"`
+		CircuitBreaker    model.CircuitBreaker   `validate:"dive" yaml:"circuitBreaker,omitempty"`
+		Middlewares       []model.PortMiddleware `validate:"dive" yaml:"middlewares,omitempty"`
+		Upstream          model.Upstream         `validate:"dive" yaml:"upstream,omitempty"`
+		AllowedCIDRs      []string               `validate:"dive,cidr" yaml:"allowedCIDRs,omitempty"`
+		DeniedCIDRs       []string               `validate:"dive,cidr" yaml:"deniedCIDRs,omitempty"`
+		AllowedCountries  []string               `validate:"dive,iso3166_1_alpha2" yaml:"allowedCountries,omitempty"`
+		DeniedCountries   []string               `validate:"dive,iso3166_1_alpha2" yaml:"deniedCountries,omitempty"`
 	}
 )
 
-var _ targetproviders.TargetProvider = (*Client)(nil)
+var (
+	_ targetproviders.TargetProvider = (*Client)(nil)
+	_ targetproviders.Reloader       = (*Client)(nil)
+)
 
 func (s *proxyConfig) UnmarshalYAML(unmarshal func(any) error) error {
 	_ = defaults.Set(s)
@@ -66,24 +113,45 @@ func (s *proxyConfig) UnmarshalYAML(unmarshal func(any) error) error {
 
 // New function returns a new Files TargetProvider
 func New(log zerolog.Logger, name string, provider *config.ListTargetProviderConfig) (*Client, error) {
-	newlog := log.With().Str("file", name).Logger()
+	newlog := core.ModuleLogger(log, "list").With().Str("file", name).Logger()
+
+	filename := provider.Filename
 
-	proxiesList := configProxyList{}
+	// remote lists are read from their copy in the data directory, it's
+	// used when the list can't be downloaded
+	var rem *remote
+	if provider.URL != "" {
+		var err error
+		if rem, err = newRemote(context.Background(), newlog, name, provider); err != nil {
+			return nil, fmt.Errorf("error creating remote list: %w", err)
+		}
 
-	file := config.NewConfigFile(newlog, provider.Filename, proxiesList)
-	err := file.Load()
+		if _, err := rem.fetch(context.Background()); err != nil {
+			if _, statErr := os.Stat(rem.file); statErr != nil {
+				return nil, fmt.Errorf("error downloading list: %w", err)
+			}
+			newlog.Error().Err(err).Str("url", rem.String()).Msg("error downloading list, using the last copy")
+		}
+		filename = rem.file
+	}
+
+	patterns := listPatterns(filename)
+
+	list, err := loadList(patterns)
 	if err != nil {
 		return nil, fmt.Errorf("error reading config: %w", err)
 	}
 
 	c := &Client{
-		file:          file,
-		log:           newlog,
-		name:          name,
-		configProxies: proxiesList,
-		proxies:       make(map[string]proxyConfig),
-		eventsChan:    make(chan targetproviders.TargetEvent),
-		errChan:       make(chan error),
+		log:        newlog,
+		list:       list,
+		remote:     rem,
+		name:       name,
+		filename:   filename,
+		patterns:   patterns,
+		proxies:    make(map[string]proxyConfig),
+		eventsChan: make(chan targetproviders.TargetEvent),
+		errChan:    make(chan error),
 	}
 
 	// load default values
@@ -95,18 +163,34 @@ func New(log zerolog.Logger, name string, provider *config.ListTargetProviderCon
 	return c, nil
 }
 
-func (c *Client) WatchEvents(_ context.Context, eventsChan chan targetproviders.TargetEvent, errChan chan error) {
+func (c *Client) WatchEvents(ctx context.Context, eventsChan chan targetproviders.TargetEvent, errChan chan error) {
 	c.log.Debug().Msg("Start WatchEvents")
 
+	ctx, cancel := context.WithCancel(ctx)
+	c.mtx.Lock()
+	c.cancel = cancel
+	c.mtx.Unlock()
+
 	c.eventsChan = eventsChan
 	c.errChan = errChan
 
-	c.file.Watch()
-	c.file.OnChange(c.onFileChange)
+	if err := c.watch(); err != nil {
+		c.log.Error().Err(err).Msg("error watching list files")
+	}
+
+	// changes of remote lists are saved to their copy, and loaded by the
+	// watcher
+	if c.remote != nil {
+		go c.remote.poll(ctx)
+	}
+
+	c.mtx.Lock()
+	list := c.list
+	c.mtx.Unlock()
 
 	// start initial proxies
 	go func() {
-		for k := range c.configProxies {
+		for k := range list.proxies {
 			eventsChan <- targetproviders.TargetEvent{
 				ID:             k,
 				TargetProvider: c,
@@ -121,6 +205,18 @@ func (c *Client) GetDefaultProxyProviderName() string {
 }
 
 func (c *Client) Close() {
+	c.mtx.Lock()
+	if c.watcher != nil {
+		c.watcher.Close()
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.docker != nil {
+		c.docker.Close()
+	}
+	c.mtx.Unlock()
+
 	for name := range c.proxies {
 		c.eventsChan <- targetproviders.TargetEvent{
 			ID:             name,
@@ -131,7 +227,10 @@ func (c *Client) Close() {
 }
 
 func (c *Client) AddTarget(id string) (*model.Config, error) {
-	proxy, ok := c.configProxies[id]
+	c.mtx.Lock()
+	proxy, ok := c.list.proxies[id]
+	c.mtx.Unlock()
+
 	if !ok {
 		return nil, fmt.Errorf("target %s not found", id)
 	}
@@ -159,6 +258,19 @@ func (c *Client) DeleteProxy(id string) error {
 
 // newProxyConfig method returns a new proxyconfig.Config
 func (c *Client) newProxyConfig(name string, p proxyConfig) (*model.Config, error) {
+	pcfg, err := c.proxyConfig(name, p)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setProvenance(pcfg, name)
+	c.addTarget(p, name)
+
+	return pcfg, nil
+}
+
+// proxyConfig method returns the proxyconfig.Config of a proxy of the list.
+func (c *Client) proxyConfig(name string, p proxyConfig) (*model.Config, error) {
 	proxyProvider := c.config.DefaultProxyProvider
 	if p.ProxyProvider != "" {
 		proxyProvider = p.ProxyProvider
@@ -175,34 +287,89 @@ func (c *Client) newProxyConfig(name string, p proxyConfig) (*model.Config, erro
 	pcfg.Hostname = name
 	pcfg.TargetProvider = c.name
 	pcfg.Tailscale = p.Tailscale
+	pcfg.Exec = p.Exec
+	pcfg.AccessLog = p.AccessLog
+	pcfg.CachePurge = p.CachePurge
+	pcfg.PublicDNS = p.PublicDNS
+	pcfg.Readiness = p.Readiness
+	pcfg.Hooks = p.Hooks
 	pcfg.ProxyProvider = proxyProvider
 	pcfg.ProxyAccessLog = proxyAccessLog
 	pcfg.Ports = c.getPorts(p.Ports)
+	pcfg.RedirectToHTTPS = p.RedirectToHTTPS
+	pcfg.AddHTTPSRedirect()
 	pcfg.Dashboard = p.Dashboard
 
-	c.addTarget(p, name)
-
 	return pcfg, nil
 }
 
+// onFileChange method reloads the list when one of its files is written,
+// created or removed.
 func (c *Client) onFileChange(e fsnotify.Event) {
-	if !e.Op.Has(fsnotify.Write) {
+	if !e.Op.Has(fsnotify.Write) && !e.Op.Has(fsnotify.Create) &&
+		!e.Op.Has(fsnotify.Remove) && !e.Op.Has(fsnotify.Rename) {
 		return
 	}
-	c.log.Info().Str("filename", e.Name).Msg("config changed, reloading")
-	oldConfigProxies := maps.Clone(c.configProxies)
 
-	// Delete all entries because it's not deleted when loading from file
-	for k := range c.configProxies {
-		delete(c.configProxies, k)
+	c.mtx.Lock()
+	list := c.list
+	c.mtx.Unlock()
+
+	if !list.isListFile(e.Name) {
+		return
 	}
-	if err := c.file.Load(); err != nil {
+	c.log.Info().Str("filename", e.Name).Msg("config changed, reloading")
+
+	if err := c.reload(); err != nil {
 		c.log.Error().Err(err).Msg("error loading config")
 	}
+}
+
+// Reload method implements targetproviders.Reloader Reload method.
+// Remote lists are downloaded first.
+func (c *Client) Reload(ctx context.Context) error {
+	c.log.Info().Str("filename", c.filename).Msg("reloading")
+
+	if c.remote != nil {
+		if _, err := c.remote.fetch(ctx); err != nil {
+			return fmt.Errorf("error downloading list: %w", err)
+		}
+	}
+
+	return c.reload()
+}
+
+// reload method reads the files again and sends the events of the added,
+// removed and changed proxies. The proxies of the files that can't be read
+// are kept.
+func (c *Client) reload() error {
+	c.reloadMtx.Lock()
+	defer c.reloadMtx.Unlock()
+
+	c.mtx.Lock()
+	old := c.list
+	c.mtx.Unlock()
+
+	list, err := loadList(c.patterns)
+	for name, file := range old.files {
+		if _, ok := list.proxies[name]; !ok && slices.Contains(list.failed, file) {
+			list.proxies[name] = old.proxies[name]
+			list.lines[name] = old.lines[name]
+			list.files[name] = file
+		}
+	}
+
+	c.mtx.Lock()
+	c.list = list
+	c.mtx.Unlock()
+
+	c.watchDirs()
+
+	oldConfigProxies := old.proxies
 
 	// delete proxies that don't exist in new config
 	for name := range oldConfigProxies {
-		if _, ok := c.configProxies[name]; !ok {
+		if _, ok := list.proxies[name]; !ok {
 			c.eventsChan <- targetproviders.TargetEvent{
 				ID:             name,
 				TargetProvider: c,
@@ -211,7 +378,7 @@ func (c *Client) onFileChange(e fsnotify.Event) {
 		}
 	}
 
-	for name := range c.configProxies {
+	for name := range list.proxies {
 		// start new proxies
 		if _, ok := oldConfigProxies[name]; !ok {
 			c.eventsChan <- targetproviders.TargetEvent{
@@ -223,7 +390,7 @@ func (c *Client) onFileChange(e fsnotify.Event) {
 		}
 		// restart if the proxy configuration changed
 		//
-		if !reflect.DeepEqual(c.configProxies[name], oldConfigProxies[name]) {
+		if !reflect.DeepEqual(list.proxies[name], oldConfigProxies[name]) {
 			c.eventsChan <- targetproviders.TargetEvent{
 				ID:             name,
 				TargetProvider: c,
@@ -231,6 +398,8 @@ func (c *Client) onFileChange(e fsnotify.Event) {
 			}
 		}
 	}
+
+	return err
 }
 
 // addTarget method add a target the proxies map
@@ -245,7 +414,15 @@ func (c *Client) addTarget(cfg proxyConfig, name string) {
 func (c *Client) getPorts(l map[string]port) model.PortConfigList {
 	ports := make(model.PortConfigList)
 	for k, v := range l {
-		port, err := model.NewPortShortLabel(k)
+		// keys like 443/https/admin add the port on a tailscale path
+		label, path := k, ""
+		if i := strings.IndexByte(k, '/'); i >= 0 {
+			if j := strings.IndexByte(k[i+1:], '/'); j >= 0 {
+				label, path = k[:i+1+j], k[i+1+j:]
+			}
+		}
+
+		port, err := model.NewPortShortLabel(label)
 		if err != nil {
 			c.log.Error().Err(err).Str("port", k).Msg("error creating port config")
 		}
@@ -253,8 +430,14 @@ func (c *Client) getPorts(l map[string]port) model.PortConfigList {
 		port.IsRedirect = v.IsRedirect
 
 		for _, target := range v.Targets {
+			target, err := c.resolveTarget(target)
+			if err != nil {
+				c.log.Error().Err(err).Str("port", k).Msg("Invalid target template")
+				continue
+			}
+
 			targetURL, err := url.Parse(target)
-			if err != nil || targetURL.Scheme == "" || targetURL.Host == "" {
+			if err != nil || !isValidTarget(targetURL) {
 				c.log.Error().Err(err).Str("port", k).Str("targetUrl", target).Msg("Invalid target URL")
 				// don't add this port and continue with other targets
 				continue
@@ -269,9 +452,46 @@ func (c *Client) getPorts(l map[string]port) model.PortConfigList {
 		}
 
 		port.TLSValidate = v.TLSValidate
+		port.DirectoryListing = v.DirectoryListing
+		port.OIDC = v.OIDC
+		port.MTLS = v.MTLS
+		port.AllowGroups = v.AllowGroups
+		port.MaxRequestBody = v.MaxRequestBody
+		port.RequestsPerSecond = v.RequestsPerSecond
+		port.Burst = v.Burst
+		port.MaxHeaderBytes = v.MaxHeaderBytes
+		port.MaxConnections = v.MaxConnections
+		port.AcceptBackoff = v.AcceptBackoff
+		port.IPFamily = v.IPFamily
+		port.TargetProxyProtocol = v.TargetProxy
+		port.AcceptProxyProtocol = v.AcceptProxy
+		port.Cache = v.Cache
+		port.Compression = v.Compression
+		port.HealthCheck = v.HealthCheck
+		port.Retry = v.Retry
+		port.CircuitBreaker = v.CircuitBreaker
+		port.Middlewares = v.Middlewares
+		port.Upstream = v.Upstream
+		port.AllowedCIDRs = v.AllowedCIDRs
+		port.DeniedCIDRs = v.DeniedCIDRs
+		port.AllowedCountries = v.AllowedCountries
+		port.DeniedCountries = v.DeniedCountries
 		port.Tailscale = v.Tailscale
+		if port.Tailscale.Path == "" {
+			port.Tailscale.Path = path
+		}
 
 		ports[k] = port
 	}
 	return ports
 }
+
+// isValidTarget returns true if the target URL can be used by a port.
+// file:// and unix:// targets only need a path, all others need a scheme and
+// a host.
+func isValidTarget(target *url.URL) bool {
+	if target.Scheme == model.TargetSchemeFile || target.Scheme == model.TargetSchemeUnix {
+		return target.Path != ""
+	}
+	return target.Scheme != "" && target.Host != ""
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package list
+
+import (
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+)
+
+var _ targetproviders.Planner = (*Client)(nil)
+
+// PlanSource method returns the proxy configurations of a list file, it's
+// validated like the lists saved in the dashboard. The files it includes
+// aren't read.
+func (c *Client) PlanSource(data []byte) (map[string]*model.Config, error) {
+	if err := validateList(data); err != nil {
+		return nil, err
+	}
+
+	list, err := decodeList(data)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]*model.Config, len(list.Proxies))
+	for name, p := range list.Proxies {
+		pcfg, err := c.proxyConfig(name, p)
+		if err != nil {
+			return nil, err
+		}
+		configs[name] = pcfg
+	}
+
+	return configs, nil
+}
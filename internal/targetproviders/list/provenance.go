@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package list
+
+import (
+	"fmt"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldLines are the lines of the fields of the proxies of a list file, by
+// proxy and path of the field. The line of the proxy has an empty path.
+type fieldLines map[string]map[string]int
+
+// readFieldLines function returns the lines of the fields of a list file.
+func readFieldLines(data []byte) (fieldLines, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	lines := make(fieldLines)
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return lines, nil
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name := root.Content[i]
+		if name.Value == includeKey {
+			continue
+		}
+		proxy := map[string]int{"": name.Line}
+		addFieldLines(proxy, "", root.Content[i+1])
+		lines[name.Value] = proxy
+	}
+
+	return lines, nil
+}
+
+// addFieldLines function adds the lines of the fields of a mapping node and
+// its children. Mappings aren't fields, except the ports.
+func addFieldLines(lines map[string]int, prefix string, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := prefix + key.Value
+		if value.Kind != yaml.MappingNode || prefix == "ports." {
+			lines[path] = key.Line
+		}
+		addFieldLines(lines, path+".", value)
+	}
+}
+
+// setProvenance method sets the lines of the list file as the source of the
+// fields of the proxy configuration.
+func (c *Client) setProvenance(pcfg *model.Config, name string) {
+	c.mtx.Lock()
+	lines := c.list.lines[name]
+	file := c.list.files[name]
+	c.mtx.Unlock()
+
+	if c.remote != nil {
+		file = c.remote.String()
+	}
+
+	for path, line := range lines {
+		field := path
+		if path == "" {
+			field = "hostname"
+		}
+		pcfg.Provenance.Set(field, fmt.Sprintf("list file %s line %d", file, line))
+	}
+
+	pcfg.Provenance.SetDefault(model.ProvenanceFields...)
+}
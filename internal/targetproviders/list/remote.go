@@ -0,0 +1,270 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package list
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/consts"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+const (
+	defaultPollInterval = time.Minute
+	remoteTimeout       = 30 * time.Second
+	// maxRemoteList is the maximum size of a remote list.
+	maxRemoteList = 10 << 20
+
+	// remoteCacheDir is the directory of the copies of the remote lists, in
+	// the data directory.
+	remoteCacheDir = "lists"
+
+	schemeS3        = "s3"
+	defaultS3Region = "us-east-1"
+	// emptyPayloadHash is the SHA-256 of the empty body of the S3 requests.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var (
+	ErrRemoteStatus = errors.New("unexpected response status")
+	ErrRemoteSize   = errors.New("remote list is too big")
+	ErrS3URL        = errors.New("s3 lists require a url like s3://bucket/key")
+)
+
+// remote struct downloads a remote list to its copy in the data directory,
+// the list provider reads the copy like a local list. Changes are detected
+// with the ETag of the list.
+type remote struct {
+	log         zerolog.Logger
+	client      *http.Client
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	url         *url.URL
+	file        string
+	token       string
+	etag        string
+	s3          config.ListS3Config
+	interval    time.Duration
+	mtx         sync.Mutex
+}
+
+// newRemote function returns the remote list of a list provider, the
+// credentials of S3 lists are read from the environment without an
+// accessKeyId.
+func newRemote(ctx context.Context, log zerolog.Logger, name string, cfg *config.ListTargetProviderConfig) (*remote, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &remote{
+		log:      log,
+		client:   &http.Client{Timeout: remoteTimeout},
+		url:      u,
+		file:     filepath.Join(config.Config.Tailscale.DataDir, remoteCacheDir, name+".yaml"),
+		token:    cfg.Token,
+		s3:       cfg.S3,
+		interval: cfg.PollInterval,
+	}
+	if r.interval == 0 {
+		r.interval = defaultPollInterval
+	}
+
+	if u.Scheme != schemeS3 {
+		return r, nil
+	}
+
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, ErrS3URL
+	}
+	if r.s3.Region == "" {
+		r.s3.Region = defaultS3Region
+	}
+
+	r.signer = v4.NewSigner()
+	if r.s3.AccessKeyID != "" {
+		r.credentials = credentials.NewStaticCredentialsProvider(r.s3.AccessKeyID, r.s3.SecretAccessKey, "")
+		return r, nil
+	}
+
+	awscfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(r.s3.Region))
+	if err != nil {
+		return nil, fmt.Errorf("error loading AWS credentials: %w", err)
+	}
+	r.credentials = awscfg.Credentials
+
+	return r, nil
+}
+
+// String method returns the URL of the remote list.
+func (r *remote) String() string {
+	return r.url.String()
+}
+
+// poll method downloads the list every interval until ctx is done.
+func (r *remote) poll(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := r.fetch(ctx); err != nil {
+			r.log.Error().Err(err).Str("url", r.String()).Msg("error downloading list")
+		}
+	}
+}
+
+// fetch method downloads the list and saves it if it changed, it returns
+// true if it was saved. Invalid lists aren't saved.
+func (r *remote) fetch(ctx context.Context) (bool, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+
+	req, err := r.request(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: %s", ErrRemoteStatus, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteList+1))
+	if err != nil {
+		return false, err
+	}
+	if len(data) > maxRemoteList {
+		return false, ErrRemoteSize
+	}
+
+	if err := validateList(data); err != nil {
+		return false, fmt.Errorf("invalid list: %w", err)
+	}
+
+	etag := resp.Header.Get("ETag")
+
+	// servers without ETag send the list on every poll
+	if old, err := os.ReadFile(r.file); err == nil && bytes.Equal(old, data) {
+		r.etag = etag
+		return false, nil
+	}
+
+	if err := r.save(data); err != nil {
+		return false, err
+	}
+	r.etag = etag
+
+	r.log.Info().Str("url", r.String()).Msg("list downloaded")
+
+	return true, nil
+}
+
+// request method returns the request of the list, signed for S3 lists.
+func (r *remote) request(ctx context.Context) (*http.Request, error) {
+	target := r.url.String()
+	if r.url.Scheme == schemeS3 {
+		target = r.s3URL()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", core.AppNameVersion)
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+
+	if r.url.Scheme != schemeS3 {
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		return req, nil
+	}
+
+	creds, err := r.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading AWS credentials: %w", err)
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	err = r.signer.SignHTTP(ctx, creds, req, emptyPayloadHash, "s3", r.s3.Region, time.Now())
+
+	return req, err
+}
+
+// s3URL method returns the https URL of the S3 object, in the bucket host
+// or, with pathStyle, in the path of the endpoint.
+func (r *remote) s3URL() string {
+	endpoint := r.s3.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + r.s3.Region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+
+	bucket, key := r.url.Host, strings.TrimPrefix(r.url.Path, "/")
+	if r.s3.PathStyle {
+		u = u.JoinPath(bucket, key)
+	} else {
+		u.Host = bucket + "." + u.Host
+		u = u.JoinPath(key)
+	}
+
+	return u.String()
+}
+
+// save method writes the list to its copy, the file is replaced at once so
+// the watcher never reads a partial list.
+func (r *remote) save(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(r.file), consts.PermOwnerAll); err != nil {
+		return err
+	}
+
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, consts.PermAllRead+consts.PermOwnerWrite); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, r.file)
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package list
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// dockerTimeout is the maximum time to inspect a container of a target.
+const dockerTimeout = 10 * time.Second
+
+// validateFuncs are the functions of the templates of the targets when the
+// list is validated, they aren't called.
+var validateFuncs = targetFuncs(func(string, ...string) (string, error) { return "", nil })
+
+var (
+	ErrNoContainerIP = errors.New("container has no IP address")
+	ErrEnvNotSet     = errors.New("environment variable not set")
+)
+
+// isTemplate function returns true if a target has template placeholders,
+// like http://{{ env "HOST_IP" }}:8080.
+func isTemplate(target string) bool {
+	return strings.Contains(target, "{{")
+}
+
+// parseTarget function parses the template of a target.
+func parseTarget(target string, funcs template.FuncMap) (*template.Template, error) {
+	return template.New("target").Option("missingkey=error").Funcs(funcs).Parse(target)
+}
+
+// targetFuncs function returns the functions of the templates of the
+// targets, with dockerIP to inspect the containers.
+func targetFuncs(dockerIP func(string, ...string) (string, error)) template.FuncMap {
+	return template.FuncMap{
+		"env":      env,
+		"dockerIP": dockerIP,
+	}
+}
+
+// env function returns an environment variable, it's an error if it isn't
+// set.
+func env(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrEnvNotSet, name)
+	}
+
+	return value, nil
+}
+
+// resolveTarget method returns a target with its placeholders resolved.
+func (c *Client) resolveTarget(target string) (string, error) {
+	if !isTemplate(target) {
+		return target, nil
+	}
+
+	tmpl, err := parseTarget(target, targetFuncs(c.dockerIP))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// dockerIP method returns the IP address of a container of the Docker host
+// of the environment, in network or in the first network by name.
+func (c *Client) dockerIP(name string, network ...string) (string, error) {
+	docker, err := c.dockerClient()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dockerTimeout)
+	defer cancel()
+
+	ctn, err := docker.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	if ctn.NetworkSettings == nil {
+		return "", fmt.Errorf("%w: %s", ErrNoContainerIP, name)
+	}
+
+	networks := ctn.NetworkSettings.Networks
+	for _, n := range slices.Sorted(maps.Keys(networks)) {
+		if len(network) > 0 && n != network[0] {
+			continue
+		}
+		if ip := networks[n].IPAddress; ip != "" {
+			return ip, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNoContainerIP, name)
+}
+
+// dockerClient method returns the Docker client of the templates, it's
+// created on the first use.
+func (c *Client) dockerClient() (*client.Client, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.docker != nil {
+		return c.docker, nil
+	}
+
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("error creating Docker client: %w", err)
+	}
+	c.docker = docker
+
+	return docker, nil
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package single is the target provider of the run command, a single proxy
+// set from the command line.
+package single
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/model"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/targetproviders"
+)
+
+// Client struct implements TargetProvider
+type Client struct {
+	log   zerolog.Logger
+	proxy *model.Config
+}
+
+var _ targetproviders.TargetProvider = (*Client)(nil)
+
+// New function returns the target provider of the proxy, named name. The
+// hostname of the proxy is its target ID.
+func New(log zerolog.Logger, name string, proxy *model.Config) *Client {
+	proxy.TargetID = proxy.Hostname
+	proxy.TargetProvider = name
+
+	return &Client{
+		log:   core.ModuleLogger(log, "single").With().Str("name", name).Logger(),
+		proxy: proxy,
+	}
+}
+
+func (c *Client) WatchEvents(_ context.Context, eventsChan chan targetproviders.TargetEvent, _ chan error) {
+	go func() {
+		eventsChan <- targetproviders.TargetEvent{
+			ID:             c.proxy.TargetID,
+			TargetProvider: c,
+			Action:         targetproviders.ActionStartProxy,
+		}
+	}()
+}
+
+// GetDefaultProxyProviderName method returns an empty name, the proxy uses
+// the global default.
+func (c *Client) GetDefaultProxyProviderName() string {
+	return ""
+}
+
+// Close method does nothing, the proxy is stopped with the others on
+// shutdown.
+func (c *Client) Close() {}
+
+func (c *Client) AddTarget(id string) (*model.Config, error) {
+	if id != c.proxy.TargetID {
+		return nil, fmt.Errorf("target %s not found", id)
+	}
+
+	c.log.Debug().Str("proxy", c.proxy.Hostname).Msg("Starting proxy")
+
+	return c.proxy, nil
+}
+
+func (c *Client) DeleteProxy(id string) error {
+	if id != c.proxy.TargetID {
+		return fmt.Errorf("target %s not found", id)
+	}
+
+	return nil
+}
@@ -18,6 +18,39 @@ type (
 		AddTarget(id string) (*model.Config, error)
 		DeleteProxy(id string) error
 	}
+
+	// Discoverer interface to be implemented by target providers that propose
+	// services to be approved by the user before being proxied.
+	Discoverer interface {
+		GetDiscovered() []model.DiscoveredService
+		Approve(id string) error
+	}
+
+	// Reloader interface to be implemented by target providers that can
+	// read their targets again on request.
+	Reloader interface {
+		Reload(ctx context.Context) error
+	}
+
+	// Editor interface to be implemented by target providers whose
+	// configuration file can be edited in the dashboard.
+	Editor interface {
+		// Editable returns false if the configuration can't be edited, like
+		// a list of several files.
+		Editable() bool
+		// Source returns the content of the configuration file.
+		Source() ([]byte, error)
+		// SaveSource validates and saves the configuration file. Changes
+		// are applied when the file change is detected.
+		SaveSource(data []byte) error
+	}
+
+	// Planner interface to be implemented by target providers that can
+	// return the proxies of a new configuration file without applying it.
+	Planner interface {
+		// PlanSource returns the proxy configurations of the file by target ID.
+		PlanSource(data []byte) (map[string]*model.Config, error)
+	}
 )
 
 const (
@@ -27,6 +60,10 @@ const (
 	ActionStartProt
 	ActionStopPrort
 	ActionRestartPort
+	// ActionDisconnected and ActionConnected report the connection of a
+	// target provider to its source, like the Docker daemon, without ID.
+	ActionDisconnected
+	ActionConnected
 )
 
 type (
@@ -34,7 +71,9 @@ type (
 
 	TargetEvent struct {
 		TargetProvider TargetProvider
-		ID             string
-		Action         ActionType
+		// Err is the error of ActionDisconnected.
+		Err    error
+		ID     string
+		Action ActionType
 	}
 )
@@ -0,0 +1,272 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+const (
+	// queueSize is the number of spans waiting to be exported, newer spans
+	// are dropped when the queue is full.
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+
+	scopeName = "github.com/yichenchong/tsdproxy-cloudflare"
+)
+
+var ErrExport = errors.New("error exporting traces")
+
+type (
+	// exporter struct sends the ended spans to the OTLP/HTTP endpoint in
+	// batches, encoded in JSON.
+	exporter struct {
+		log      zerolog.Logger
+		client   *http.Client
+		headers  map[string]string
+		queue    chan *Span
+		done     chan struct{}
+		stopped  chan struct{}
+		endpoint string
+		resource []otlpKeyValue
+		once     sync.Once
+	}
+
+	// OTLP JSON encoding of the spans.
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+		Kind              SpanKind       `json:"kind"`
+	}
+	otlpStatus struct {
+		Message string `json:"message,omitempty"`
+		Code    int    `json:"code,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+)
+
+// newExporter function returns the exporter of the configuration, exporting
+// in the background.
+func newExporter(log zerolog.Logger, cfg config.TracingConfig) *exporter {
+	e := &exporter{
+		log:      log,
+		client:   &http.Client{Timeout: exportTimeout},
+		headers:  cfg.Headers,
+		endpoint: cfg.Endpoint,
+		queue:    make(chan *Span, queueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		resource: []otlpKeyValue{
+			keyValue("service.name", cfg.ServiceName),
+			keyValue("service.version", core.GetVersion()),
+		},
+	}
+
+	go e.run()
+
+	return e
+}
+
+// add method queues an ended span. It never blocks, spans are dropped when
+// the queue is full.
+func (e *exporter) add(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		e.log.Warn().Msg("trace queue is full, span dropped")
+	}
+}
+
+// run method exports the spans when a batch is full, and every flush
+// interval, until the exporter is closed.
+func (e *exporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.log.Error().Err(err).Int("spans", len(batch)).Msg("error exporting spans")
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+					if len(batch) >= batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// close method exports the queued spans and stops the exporter, waiting
+// until the context is done.
+func (e *exporter) close(ctx context.Context) error {
+	e.once.Do(func() { close(e.done) })
+
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// export method posts a batch of spans to the endpoint.
+func (e *exporter) export(batch []*Span) error {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.otlp())
+	}
+
+	data, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: e.resource},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName, Version: core.GetVersion()},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s returned %s", ErrExport, req.URL.Host, resp.Status)
+	}
+
+	return nil
+}
+
+// otlp method returns the OTLP encoding of an ended span.
+func (s *Span) otlp() otlpSpan {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.ctx.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.ctx.SpanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.status, Message: s.message},
+	}
+	if s.parent != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	for _, a := range s.attrs {
+		span.Attributes = append(span.Attributes, keyValue(a.key, a.value))
+	}
+
+	return span
+}
+
+// keyValue function returns the OTLP encoding of an attribute.
+func keyValue(key string, value any) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+
+	switch v := value.(type) {
+	case int64:
+		i := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &i
+	case bool:
+		kv.Value.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+
+	return kv
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// TraceparentHeader is the W3C trace context header.
+const TraceparentHeader = "traceparent"
+
+type (
+	// RoundTripper struct records a client span of the requests sent to the
+	// targets, and propagates it in their traceparent header.
+	RoundTripper struct {
+		next http.RoundTripper
+	}
+
+	// statusWriter struct records the status of the response.
+	statusWriter struct {
+		http.ResponseWriter
+		status int
+	}
+)
+
+// Middleware function returns the middleware that records a server span of
+// the requests of a port, child of the traceparent of the request.
+func Middleware(proxy, port string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var remote *SpanContext
+			if c, ok := ParseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+				remote = &c
+			}
+
+			ctx, span := Start(r.Context(), r.Method+" "+proxy, KindServer, remote)
+			defer span.End()
+
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+			span.SetAttribute("server.address", r.Host)
+			span.SetAttribute("client.address", r.RemoteAddr)
+			span.SetAttribute("tsdproxy.proxy", proxy)
+			span.SetAttribute("tsdproxy.port", port)
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttribute("http.response.status_code", sw.status)
+			if sw.status >= http.StatusInternalServerError {
+				span.SetErrorStatus(strconv.Itoa(sw.status))
+			}
+		})
+	}
+}
+
+// NewRoundTripper function returns a RoundTripper that traces the requests
+// sent with next.
+func NewRoundTripper(next http.RoundTripper) *RoundTripper {
+	return &RoundTripper{next: next}
+}
+
+// RoundTrip method implements http.RoundTripper RoundTrip method. The
+// latency of the target is the duration of the span, until the response
+// headers.
+func (t *RoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !Enabled() {
+		return t.next.RoundTrip(r)
+	}
+
+	ctx, span := Start(r.Context(), r.Method, KindClient, nil)
+	defer span.End()
+
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("server.address", r.URL.Host)
+	span.SetAttribute("url.full", r.URL.Redacted())
+
+	// the request belongs to the caller, the header is set in a copy
+	r = r.Clone(ctx)
+	r.Header.Set(TraceparentHeader, span.Context().Traceparent())
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+		return resp, err
+	}
+
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetErrorStatus(resp.Status)
+	}
+
+	return resp, nil
+}
+
+// WriteHeader method implements http.ResponseWriter WriteHeader method.
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap method returns the original http.ResponseWriter, so
+// http.ResponseController can flush and hijack it.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package tracing records OpenTelemetry spans of the proxied requests and
+// exports them to an OTLP/HTTP endpoint, with the W3C trace context
+// propagated to the targets.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+// Kinds of the spans, the values of OTLP.
+const (
+	KindServer SpanKind = 2
+	KindClient SpanKind = 3
+)
+
+// Status codes of the spans, the values of OTLP.
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+type (
+	// Tracer struct records the spans and sends them to the exporter.
+	Tracer struct {
+		exporter *exporter
+		ratio    float64
+	}
+
+	// SpanKind is the kind of a span, server for the requests received and
+	// client for the requests sent to the targets.
+	SpanKind int
+
+	// SpanContext struct identifies a span in its trace.
+	SpanContext struct {
+		TraceID [16]byte
+		SpanID  [8]byte
+		Sampled bool
+	}
+
+	// Span struct is an operation of a trace. A nil Span is a span that isn't
+	// traced, all its methods do nothing.
+	Span struct {
+		start   time.Time
+		end     time.Time
+		tracer  *Tracer
+		name    string
+		message string
+		attrs   []attribute
+		ctx     SpanContext
+		parent  [8]byte
+		kind    SpanKind
+		status  int
+		mtx     sync.Mutex
+		ended   bool
+	}
+
+	// attribute struct is an attribute of a span, a string, int64 or bool.
+	attribute struct {
+		value any
+		key   string
+	}
+
+	spanContextKey struct{}
+)
+
+// global is the tracer of the proxied requests, nil when tracing is off.
+var global atomic.Pointer[Tracer]
+
+// Setup function starts the tracer of the configuration, it returns nil
+// when tracing is disabled.
+func Setup(log zerolog.Logger, cfg config.TracingConfig) *Tracer {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+
+	log = core.ModuleLogger(log, "tracing")
+
+	t := &Tracer{
+		exporter: newExporter(log, cfg),
+		ratio:    cfg.SampleRatio,
+	}
+	global.Store(t)
+
+	log.Info().Str("endpoint", cfg.Endpoint).Msg("exporting traces")
+
+	return t
+}
+
+// Close method stops the tracer, exporting the spans recorded until the
+// context is done.
+func (t *Tracer) Close(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	global.CompareAndSwap(t, nil)
+
+	return t.exporter.close(ctx)
+}
+
+// Enabled function returns true if the requests are traced.
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+// Start function starts a span, child of the span of the context or of the
+// remote parent. It returns a nil span when tracing is disabled.
+func Start(ctx context.Context, name string, kind SpanKind, remote *SpanContext) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := remote
+	if s := SpanFromContext(ctx); s != nil {
+		parent = &s.ctx
+	}
+
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+	_, _ = rand.Read(s.ctx.SpanID[:])
+	if parent != nil {
+		s.ctx.TraceID = parent.TraceID
+		s.ctx.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(s.ctx.TraceID[:])
+		s.ctx.Sampled = mathrand.Float64() < t.ratio //nolint:gosec
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// SpanFromContext function returns the span of the context, nil without one.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// Context method returns the span context, propagated to the targets.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.ctx
+}
+
+// SetAttribute method sets an attribute of the span, value is a string, an
+// int, an int64 or a bool.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+
+	if i, ok := value.(int); ok {
+		value = int64(i)
+	}
+
+	s.mtx.Lock()
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+	s.mtx.Unlock()
+}
+
+// SetError method marks the span as failed with the error.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	s.status = statusError
+	s.message = err.Error()
+	s.mtx.Unlock()
+}
+
+// SetErrorStatus method marks the span as failed with a message, like the
+// responses with a 5xx status.
+func (s *Span) SetErrorStatus(message string) {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	if s.status == statusUnset {
+		s.status = statusError
+		s.message = message
+	}
+	s.mtx.Unlock()
+}
+
+// End method ends the span, sampled spans are exported.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	if s.ended {
+		s.mtx.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mtx.Unlock()
+
+	if s.ctx.Sampled {
+		s.tracer.exporter.add(s)
+	}
+}
+
+// Traceparent method returns the W3C traceparent header of the span context.
+func (c SpanContext) Traceparent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + hex.EncodeToString(c.TraceID[:]) + "-" + hex.EncodeToString(c.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent function returns the span context of a W3C traceparent
+// header, false if it isn't valid.
+func ParseTraceparent(header string) (SpanContext, bool) {
+	var c SpanContext
+
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return c, false
+	}
+
+	if _, err := hex.Decode(c.TraceID[:], []byte(parts[1])); err != nil {
+		return c, false
+	}
+	if _, err := hex.Decode(c.SpanID[:], []byte(parts[2])); err != nil {
+		return c, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return c, false
+	}
+	c.Sampled = flags[0]&1 == 1
+
+	if c.TraceID == [16]byte{} || c.SpanID == [8]byte{} {
+		return c, false
+	}
+
+	return c, true
+}
@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2025 Paulo Almeida <almeidapaulopt@gmail.com>
+// SPDX-License-Identifier: MIT
+
+// Package updates checks the releases of TSDProxy for a version newer than
+// the running one, in the configured update channel.
+package updates
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/config"
+	"github.com/yichenchong/tsdproxy-cloudflare/internal/core"
+)
+
+const (
+	releasesURL = "https://api.github.com/repos/yichenchong/tsdproxy-cloudflare/releases?per_page=30"
+	httpTimeout = 30 * time.Second
+
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+)
+
+type (
+	// Checker struct checks the releases periodically.
+	Checker struct {
+		log      zerolog.Logger
+		client   *http.Client
+		checked  time.Time
+		latest   *Release
+		url      string
+		channel  string
+		lastErr  string
+		interval time.Duration
+		disabled bool
+		mtx      sync.RWMutex
+	}
+
+	// Release struct is a release of TSDProxy.
+	Release struct {
+		Published  time.Time `json:"published"`
+		Version    string    `json:"version"`
+		URL        string    `json:"url"`
+		Prerelease bool      `json:"prerelease"`
+	}
+
+	// Status struct is the result of the last check.
+	Status struct {
+		Checked         *time.Time `json:"checked,omitempty"`
+		Latest          *Release   `json:"latest,omitempty"`
+		Channel         string     `json:"channel"`
+		Error           string     `json:"error,omitempty"`
+		Enabled         bool       `json:"enabled"`
+		UpdateAvailable bool       `json:"updateAvailable"`
+	}
+
+	// githubRelease is a release of the GitHub API.
+	githubRelease struct {
+		PublishedAt time.Time `json:"published_at"`
+		TagName     string    `json:"tag_name"`
+		HTMLURL     string    `json:"html_url"`
+		Draft       bool      `json:"draft"`
+		Prerelease  bool      `json:"prerelease"`
+	}
+)
+
+// New function returns a new Checker of the update channel.
+func New(log zerolog.Logger, cfg config.UpdatesConfig) *Checker {
+	return &Checker{
+		log:      core.ModuleLogger(log, "updates"),
+		client:   &http.Client{Timeout: httpTimeout},
+		url:      releasesURL,
+		channel:  cfg.Channel,
+		interval: cfg.Interval,
+		disabled: cfg.Disabled,
+	}
+}
+
+// Start method checks the releases until the context is done.
+func (c *Checker) Start(ctx context.Context) {
+	if c == nil || c.disabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			c.check(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Status method returns the result of the last check.
+func (c *Checker) Status() Status {
+	if c == nil {
+		return Status{}
+	}
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	s := Status{
+		Channel: c.channel,
+		Enabled: !c.disabled,
+		Latest:  c.latest,
+		Error:   c.lastErr,
+	}
+	if !c.checked.IsZero() {
+		checked := c.checked
+		s.Checked = &checked
+	}
+	if c.latest != nil {
+		s.UpdateAvailable = compareVersions(c.latest.Version, currentVersion()) > 0
+	}
+
+	return s
+}
+
+func (c *Checker) check(ctx context.Context) {
+	latest, err := c.fetchLatest(ctx)
+
+	c.mtx.Lock()
+	c.checked = time.Now()
+	if err != nil {
+		c.lastErr = err.Error()
+	} else {
+		c.lastErr = ""
+		c.latest = latest
+	}
+	c.mtx.Unlock()
+
+	if err != nil {
+		c.log.Warn().Err(err).Msg("Error checking for updates")
+		return
+	}
+
+	if latest != nil && compareVersions(latest.Version, currentVersion()) > 0 {
+		c.log.Info().Str("version", latest.Version).Str("channel", c.channel).Msg("New version available")
+	}
+}
+
+// fetchLatest method returns the newest release of the channel, nil if
+// there are no releases.
+func (c *Checker) fetchLatest(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", core.AppNameVersion)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("releases request failed: %s", resp.Status)
+	}
+
+	var releases []githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, err
+	}
+
+	var latest *Release
+	for _, r := range releases {
+		if r.Draft || r.Prerelease && c.channel != ChannelBeta {
+			continue
+		}
+
+		version := strings.TrimPrefix(r.TagName, "v")
+		if latest == nil || compareVersions(version, latest.Version) > 0 {
+			latest = &Release{
+				Published:  r.PublishedAt,
+				Version:    version,
+				URL:        r.HTMLURL,
+				Prerelease: r.Prerelease,
+			}
+		}
+	}
+
+	return latest, nil
+}
+
+// currentVersion function returns the running version, without the suffix
+// of modified builds.
+func currentVersion() string {
+	return strings.TrimSuffix(core.GetVersion(), "-dirty")
+}
+
+// compareVersions function compares two semantic versions, like 2.1.0 and
+// 2.2.0-beta.1. Pre-releases are older than their release.
+func compareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	aCore, aPre, _ := strings.Cut(a, "-")
+	bCore, bPre, _ := strings.Cut(b, "-")
+
+	if c := compareDotted(aCore, bCore); c != 0 {
+		return c
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	return compareDotted(aPre, bPre)
+}
+
+// compareDotted function compares dot separated identifiers, numerically
+// when both are numbers.
+func compareDotted(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := range max(len(as), len(bs)) {
+		if i >= len(as) {
+			return -1
+		}
+		if i >= len(bs) {
+			return 1
+		}
+
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return cmp.Compare(an, bn)
+			}
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+
+	return 0
+}
@@ -6,11 +6,19 @@ window.sortList = function() {
   const list = document.getElementById("proxy-list");
   if (!list) return;
 
-  const items = [...list.children].sort((a, b) => {
-    return a.id.localeCompare(b.id);
+  // groups are sorted by the configured order, then by name
+  const groups = [...list.children].sort((a, b) => {
+    return (a.dataset.order - b.dataset.order) || a.dataset.name.localeCompare(b.dataset.name);
   });
 
-  items.forEach(item => list.appendChild(item));
-}
+  groups.forEach(group => {
+    list.appendChild(group);
 
+    const proxies = group.querySelector(".proxies");
+    if (!proxies) return;
 
+    [...proxies.children]
+      .sort((a, b) => (a.dataset.sort < b.dataset.sort ? -1 : a.dataset.sort > b.dataset.sort ? 1 : 0))
+      .forEach(item => proxies.appendChild(item));
+  });
+}